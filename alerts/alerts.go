@@ -0,0 +1,103 @@
+// Package alerts renders GitHub's alerts, blockquotes that open with a line
+// such as "> [!NOTE]", as colored callouts with an icon and a title.
+package alerts
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/hholst80/glow/utils"
+)
+
+// kind is a kind of alert.
+type kind struct {
+	title string
+	icon  string
+	color lipgloss.AdaptiveColor
+}
+
+// kinds are the kinds of alerts, colored like GitHub's.
+var kinds = map[string]kind{
+	"note":      {"Note", "ℹ", lipgloss.AdaptiveColor{Light: "#0969DA", Dark: "#4493F8"}},
+	"tip":       {"Tip", "💡", lipgloss.AdaptiveColor{Light: "#1A7F37", Dark: "#3FB950"}},
+	"important": {"Important", "❗", lipgloss.AdaptiveColor{Light: "#8250DF", Dark: "#AB7DF8"}},
+	"warning":   {"Warning", "⚠", lipgloss.AdaptiveColor{Light: "#9A6700", Dark: "#D29922"}},
+	"caution":   {"Caution", "🛑", lipgloss.AdaptiveColor{Light: "#CF222E", Dark: "#F85149"}},
+}
+
+// alertRegex matches the line that opens an alert.
+var alertRegex = regexp.MustCompile(`(?i)^(\s*>\s*)\[!(note|tip|important|warning|caution)\]\s*$`)
+
+// markerPrefix starts the marker Process puts in place of an alert's first
+// line, for Restore to find after rendering.
+const markerPrefix = "@glow-alert-"
+
+// Process replaces the first line of each alert in markdown with a marker,
+// keeping the rest of it a blockquote for glamour to render.
+func Process(markdown string) string {
+	lines := strings.Split(markdown, "\n")
+	fence := ""
+	for i, line := range lines {
+		inCode := fence != ""
+		if fence = utils.NextFence(fence, line); inCode || fence != "" {
+			continue
+		}
+
+		// Alerts open blockquotes, rather than being lines of them
+		m := alertRegex.FindStringSubmatch(line)
+		if m == nil || (i > 0 && strings.HasPrefix(strings.TrimSpace(lines[i-1]), ">")) {
+			continue
+		}
+		// The title is a paragraph of its own
+		lines[i] = m[1] + markerPrefix + strings.ToLower(m[2]) + "@\n" + strings.TrimRight(m[1], " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Restore replaces the markers of alerts in rendered output with their icons
+// and titles, and colors the bars of their blockquotes.
+func Restore(rendered string) string {
+	if !strings.Contains(rendered, markerPrefix) {
+		return rendered
+	}
+	lines := strings.Split(rendered, "\n")
+	for i := 0; i < len(lines); i++ {
+		idx := strings.Index(lines[i], markerPrefix)
+		if idx < 0 {
+			continue
+		}
+		name, _, ok := strings.Cut(lines[i][idx+len(markerPrefix):], "@")
+		if !ok {
+			continue
+		}
+		k, ok := kinds[name]
+		if !ok {
+			continue
+		}
+
+		// The bar of the blockquote is in front of the marker, and of the
+		// lines of the blockquote below it
+		plain := ansi.Strip(lines[i])
+		indent := len(plain) - len(strings.TrimLeft(plain, " "))
+		bar := strings.TrimSpace(plain[indent:max(indent, strings.Index(plain, markerPrefix))])
+
+		style := lipgloss.NewStyle().Foreground(k.color)
+		title := k.icon + " " + k.title
+		marker := markerPrefix + name + "@"
+		padding := strings.Repeat(" ", max(0, len(marker)-lipgloss.Width(title)))
+		lines[i] = lines[i][:idx] + style.Bold(true).Render(title) + padding + lines[i][idx+len(marker):]
+		if bar == "" {
+			continue
+		}
+		for ; i < len(lines); i++ {
+			if !strings.HasPrefix(ansi.Strip(lines[i]), plain[:indent]+bar) {
+				break
+			}
+			lines[i] = strings.Replace(lines[i], bar, style.Render(bar), 1)
+		}
+		i--
+	}
+	return strings.Join(lines, "\n")
+}
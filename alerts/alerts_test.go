@@ -0,0 +1,53 @@
+package alerts
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
+)
+
+func TestProcess(t *testing.T) {
+	in := "> [!NOTE]\n> Read this.\n\n> quote\n> [!TIP]\n\n```\n> [!WARNING]\n```\n\n  >  [!Caution]  \n> Stop.\n"
+	want := "> @glow-alert-note@\n>\n> Read this.\n\n> quote\n> [!TIP]\n\n```\n> [!WARNING]\n```\n\n  >  @glow-alert-caution@\n  >\n> Stop.\n"
+	if got := Process(in); got != want {
+		t.Errorf("Process() = %q, want %q", got, want)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	lipgloss.SetHasDarkBackground(true)
+	t.Cleanup(func() { lipgloss.SetColorProfile(termenv.Ascii) })
+
+	r, err := glamour.NewTermRenderer(glamour.WithStandardStyle("dark"), glamour.WithColorProfile(termenv.TrueColor), glamour.WithWordWrap(60))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := r.Render(Process("Before\n\n> [!NOTE]\n> Read this.\n\nAfter\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = Restore(out)
+
+	plain := ansi.Strip(out)
+	if strings.Contains(plain, markerPrefix) || !strings.Contains(plain, "│ ℹ Note") {
+		t.Errorf("Expected the marker replaced by the title:\n%s", plain)
+	}
+
+	// The bar of each line of the alert is in the color of notes
+	blue := lipgloss.NewStyle().Foreground(kinds["note"].color).Render("│")
+	lines := strings.Split(strings.Trim(out, "\n"), "\n")
+	for _, line := range lines {
+		stripped := ansi.Strip(line)
+		if strings.Contains(stripped, "│") != strings.Contains(line, blue) {
+			t.Errorf("Expected the bar of the alert, and only it, colored: %q", line)
+		}
+		if w, want := ansi.StringWidth(line), ansi.StringWidth(lines[0]); w != want {
+			t.Errorf("Expected lines %d wide, got %d: %q", want, w, stripped)
+		}
+	}
+}
@@ -7,8 +7,13 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
 
+	"github.com/charmbracelet/log"
 	"github.com/charmbracelet/x/editor"
+	"github.com/hholst80/glow/mermaid"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -19,10 +24,114 @@ style: "auto"
 mouse: false
 # use pager to display markdown
 pager: false
-# word-wrap at width
+# program the pager displays markdown with (defaults to $PAGER, or less -R)
+# pagerCommand: "bat --paging=always --style=plain"
+# word-wrap at width, or "auto" to fit each document's tables and code
 width: 80
-# show all files, including hidden and ignored.
+# render without colors or other escape codes, even on a terminal
+noColor: false
+# show all files, including hidden and system ones and those git ignores.
 all: false
+# show hidden files and directories (TUI-mode only)
+showHidden: false
+# show files ignored by .gitignore and git's excludes files (TUI-mode only)
+noIgnore: false
+# how many directories deep to look for files, 0 for no limit (TUI-mode only)
+maxDepth: 0
+# follow symlinked directories when looking for files (TUI-mode only)
+followSymlinks: false
+# self-hosted GitLab and Gitea/Forgejo instances READMEs are fetched from
+# gitlabHosts: ["gitlab.example.com"]
+# giteaHosts: ["git.example.com"]
+# order of the file list: "name", "modified", "size" or "title" (TUI-mode only)
+# sort: "name"
+# metadata columns of the file list: "size", "modified", "words" and "headings" (TUI-mode only)
+# columns: ["size", "modified"]
+# lines kept above a heading jumped to from the outline, 0 for none (TUI-mode only)
+# scrollOff: 5
+# label files by their front matter title or first level 1 heading rather than
+# their path (TUI-mode only)
+# showTitles: true
+# replace shortcodes such as :rocket: with their emoji
+# emoji: false
+# re-indent JSON code blocks written on one line
+# formatJSON: false
+# color the keys of JSON and YAML code blocks by depth and their values by type
+# highlightData: false
+# how tables wider than the page are laid out: "auto" to narrow their columns,
+# or show each row as a record if words won't fit, "shrink", "records", or
+# "off"; a document's front matter may set its own with tables:
+# tables: "auto"
+# outline sidebar width: "auto" to fit the longest heading, a number of
+# columns, or a percentage of the terminal such as "30%" (TUI-mode only)
+# outlineWidth: "auto"
+# deepest heading level listed in the outline sidebar, 0 for all (TUI-mode only)
+# outlineDepth: 0
+# how to show mermaid diagrams: "image" where the terminal supports it,
+# "ascii", or "off" to show their source
+# mermaid: "image"
+# program that reads a mermaid diagram on stdin and prints it rendered
+# (defaults to the built-in ASCII renderer)
+# mermaidCommand: ""
+# how long a mermaid diagram may take to render before its source is shown
+# (default 500ms, or 10s with mermaidCommand)
+# mermaidTimeout: "500ms"
+# complexity limits beyond which flowcharts are shown as source (0 = no limit)
+# mermaidLimits:
+#   maxNodes: 0
+#   maxEdges: 20
+#   maxLines: 0
+#   maxSubgraphs: 2
+#   maxEdgesWithSubgraphs: 10
+# render mermaid diagrams however complex they are
+# mermaidForce: false
+# draw mermaid diagrams with plain ASCII (+-|>) instead of box-drawing characters
+# mermaidPlain: false
+# programs that render diagrams of other code block languages, reading each
+# diagram on stdin and printing it; installed ones are used by default, and
+# an empty command turns a language off
+# diagramCommands:
+#   d2: "d2 --stdout-format txt - -"
+#   dot: "graph-easy --from=dot --as=boxart"
+#   plantuml: "plantuml -tutxt -pipe"
+# programs that code blocks are piped through, by language; each reads a
+# block on stdin, and its output replaces the block's code, or with a map,
+# is appended below the block or rendered as markdown in its place
+# codeFilters:
+#   graphql: "prettier --parser graphql"
+#   sql:
+#     command: "sqlformat --reindent -"
+#     output: replace # or append, markdown
+# number rendered diagrams ("Figure 1") with captions below them
+# figures: false
+# list the numbered diagrams in the outline sidebar (TUI-mode only)
+# outlineFigures: false
+# colors for the TUI chrome (hex or ANSI color numbers)
+# theme:
+#   outlineTitle: "#EE6FF8"
+#   outlineText: "#626262"
+#   outlineCurrent: "#ECFD65"
+#   outlineCursorFg: "#FFFDF5"
+#   outlineCursorBg: "#3C3C3C"
+#   outlineBorder: "#3C3C3C"
+#   statusBarFg: "#7D7D7D"
+#   statusBarBg: "#242424"
+#   statusBarMessageFg: "#89F0CB"
+#   statusBarMessageBg: "#1C8760"
+#   lineNumber: "#7D7D7D"
+# directory glow new creates notes in (defaults to the current directory)
+# notesDir: "~/notes"
+# directory of glow new's templates (defaults to templates next to this file)
+# templatesDir: "~/.config/glow/templates"
+# keys of the pager's actions, in place of their defaults (TUI-mode only):
+# top, bottom, halfPageUp, halfPageDown, copy, edit, reload, help,
+# toggleOutline, focusOutline, nextHeading, prevHeading, search, nextMatch,
+# prevMatch, panLeft, panRight, diagrams, language, nextTab, prevTab,
+# followLink and toggleDetails
+# keys:
+#   toggleOutline: ["O"]
+#   nextHeading: ["J", "]"]
+#   prevHeading: ["K", "["]
 `
 
 var configCmd = &cobra.Command{
@@ -53,6 +162,119 @@ var configCmd = &cobra.Command{
 	},
 }
 
+// configEnvKeys are the settings of more than one word, which environment
+// variables named after them in snake case, such as GLOW_SHOW_OUTLINE, set.
+// Viper matches the others, such as GLOW_WIDTH, by itself.
+var configEnvKeys = []string{
+	"showHidden", "noIgnore", "maxDepth", "followSymlinks", "showTitles",
+	"pagerCommand", "showLineNumbers", "showOutline", "outlineWidth", "outlineDepth",
+	"scrollOff", "preserveNewLines", "mermaidCommand", "mermaidTimeout",
+	"mermaidForce", "mermaidPlain", "outlineFigures", "noCache", "gitlabHosts",
+	"giteaHosts", "noColor", "diagramCommands", "notesDir", "templatesDir",
+	"formatJSON", "highlightData", "codeFilters",
+}
+
+// configEnvAliases are further environment variables of settings, which the
+// one named after the setting takes precedence over. Viper also matches the
+// setting run together, such as GLOW_SCROLLOFF, by itself.
+var configEnvAliases = map[string][]string{
+	"style": {"GLOW_STYLE_FILE", "GLAMOUR_STYLE"},
+}
+
+// configEnvName returns the environment variable of a setting, such as
+// GLOW_SHOW_OUTLINE for showOutline.
+func configEnvName(key string) string {
+	var b strings.Builder
+	b.WriteString("GLOW_")
+	prev := ' '
+	for _, r := range key {
+		// Acronyms, such as the JSON of formatJSON, are one word
+		if unicode.IsUpper(r) && unicode.IsLower(prev) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+		prev = r
+	}
+	return b.String()
+}
+
+// bindConfigEnv lets environment variables override the config file, while
+// flags override both.
+func bindConfigEnv() {
+	for _, key := range configEnvKeys {
+		if _, ok := configEnvAliases[key]; !ok {
+			_ = viper.BindEnv(key, configEnvName(key))
+		}
+	}
+	for key, names := range configEnvAliases {
+		_ = viper.BindEnv(append([]string{key, configEnvName(key)}, names...)...)
+	}
+}
+
+// configStringMap returns a setting that maps names to values, given in the
+// config file as a map or in the environment as "name:value,...".
+func configStringMap(key string) map[string]string {
+	s, ok := viper.Get(key).(string)
+	if !ok {
+		return viper.GetStringMapString(key)
+	}
+	m := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		if name, value, ok := strings.Cut(pair, ":"); ok {
+			m[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	return m
+}
+
+// configCodeFilters returns the codeFilters setting: filters by code fence
+// language, each given in the config file as a command line or as a map of
+// its command and output, or in the environment as "lang:command,...".
+func configCodeFilters() (map[string]mermaid.Filter, error) {
+	raw, ok := viper.Get("codeFilters").(map[string]any)
+	if !ok {
+		filters := map[string]mermaid.Filter{}
+		for name, command := range configStringMap("codeFilters") {
+			filters[name] = mermaid.Filter{Command: command, Output: mermaid.FilterReplace}
+		}
+		return filters, nil
+	}
+
+	filters := make(map[string]mermaid.Filter, len(raw))
+	for name, v := range raw {
+		var filter mermaid.Filter
+		switch v := v.(type) {
+		case string:
+			filter.Command = v
+		case map[string]any:
+			filter.Command, _ = v["command"].(string)
+			output, _ := v["output"].(string)
+			var err error
+			if filter.Output, err = mermaid.ParseFilterOutput(output); err != nil {
+				return nil, fmt.Errorf("code filter %s: %w", name, err)
+			}
+		default:
+			return nil, fmt.Errorf("code filter %s: must be a command or a map of its command and output", name)
+		}
+		if filter.Output == "" {
+			filter.Output = mermaid.FilterReplace
+		}
+		filters[name] = filter
+	}
+	return filters, nil
+}
+
+// loadConfigFile reads the config file given with --config in place of the
+// one in the default places.
+func loadConfigFile(file string) error {
+	viper.SetConfigFile(file)
+	if err := viper.ReadInConfig(); err != nil {
+		return fmt.Errorf("unable to read config file: %w", err)
+	}
+	log.Debug("Using configuration file", "path", file)
+	return nil
+}
+
 func ensureConfigFile() error {
 	if configFile == "" {
 		configFile = viper.GetViper().ConfigFileUsed()
@@ -86,3 +308,31 @@ func ensureConfigFile() error {
 	}
 	return nil
 }
+
+// saveConfigValue sets a top-level key of the config file, replacing its line
+// or its commented out default, and leaves the rest of the file as it is.
+func saveConfigValue(key, value string) error {
+	if err := ensureConfigFile(); err != nil {
+		return err
+	}
+	b, err := os.ReadFile(configFile)
+	if err != nil {
+		return fmt.Errorf("unable to read config file: %w", err)
+	}
+
+	line := fmt.Sprintf("%s: %q", key, value)
+	keyRegex := regexp.MustCompile(`(?m)^(?:#[ \t]*)?` + regexp.QuoteMeta(key) + `:.*$`)
+	if loc := keyRegex.FindIndex(b); loc != nil {
+		b = append(b[:loc[0]:loc[0]], append([]byte(line), b[loc[1]:]...)...)
+	} else {
+		if len(b) > 0 && b[len(b)-1] != '\n' {
+			b = append(b, '\n')
+		}
+		b = append(b, line+"\n"...)
+	}
+
+	if err := os.WriteFile(configFile, b, 0o600); err != nil {
+		return fmt.Errorf("unable to write config file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hholst80/glow/mermaid"
+	"github.com/spf13/viper"
+)
+
+func TestConfigEnvName(t *testing.T) {
+	for key, want := range map[string]string{
+		"showOutline":     "GLOW_SHOW_OUTLINE",
+		"showLineNumbers": "GLOW_SHOW_LINE_NUMBERS",
+		"scrollOff":       "GLOW_SCROLL_OFF",
+		"noIgnore":        "GLOW_NO_IGNORE",
+		"formatJSON":      "GLOW_FORMAT_JSON",
+	} {
+		if got := configEnvName(key); got != want {
+			t.Errorf("configEnvName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "glow.yml")
+	if err := os.WriteFile(file, []byte("showOutline: false\nscrollOff: 3\nmaxDepth: 2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GLOW_SHOW_OUTLINE", "true")
+	if err := loadConfigFile(file); err != nil {
+		t.Fatal(err)
+	}
+
+	// The environment overrides the file, which sets the rest
+	if !viper.GetBool("showOutline") {
+		t.Error("Expected GLOW_SHOW_OUTLINE to override the config file")
+	}
+	if got := viper.GetInt("scrollOff"); got != 3 {
+		t.Errorf("Expected scrollOff 3 from the config file, got %d", got)
+	}
+
+	// Flags override both
+	t.Cleanup(func() { _ = rootCmd.Flags().Set("max-depth", "0") })
+	if err := rootCmd.ParseFlags([]string{"--max-depth", "4"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := viper.GetInt("maxDepth"); got != 4 {
+		t.Errorf("Expected --max-depth to override the config file, got %d", got)
+	}
+
+	if err := loadConfigFile(filepath.Join(t.TempDir(), "missing.yml")); err == nil {
+		t.Error("Expected an error for a missing config file")
+	}
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "glow.yml")
+	if err := os.WriteFile(file, []byte("outlineWidth: \"30\"\nscrollOff: 3\nstyle: light\ndiagramCommands:\n  d2: d2 -\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfigFile(file); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		env   map[string]string
+		flags []string
+		key   string
+		want  string
+	}{
+		{name: "default", key: "mermaid", want: string(mermaid.DefaultMode)},
+		{name: "emoji off by default", key: "emoji", want: "false"},
+		{name: "env emoji", env: map[string]string{"GLOW_EMOJI": "true"}, key: "emoji", want: "true"},
+		{name: "env over default", env: map[string]string{"GLOW_MERMAID": "off"}, key: "mermaid", want: "off"},
+		{name: "file", key: "outlineWidth", want: "30"},
+		{name: "env over file", env: map[string]string{"GLOW_OUTLINE_WIDTH": "40"}, key: "outlineWidth", want: "40"},
+		{name: "flag over env", env: map[string]string{"GLOW_OUTLINE_WIDTH": "40"}, flags: []string{"--outline-width", "50"}, key: "outlineWidth", want: "50"},
+		{name: "pager command", env: map[string]string{"GLOW_PAGER_COMMAND": "bat"}, key: "pagerCommand", want: "bat"},
+		{name: "run together over file", env: map[string]string{"GLOW_SCROLLOFF": "5"}, key: "scrollOff", want: "5"},
+		{name: "style file", env: map[string]string{"GLOW_STYLE_FILE": "~/style.json"}, key: "style", want: "~/style.json"},
+		{name: "style over glamour", env: map[string]string{"GLOW_STYLE": "dark", "GLAMOUR_STYLE": "pink"}, key: "style", want: "dark"},
+		{name: "flag over style", env: map[string]string{"GLAMOUR_STYLE": "pink"}, flags: []string{"--style", "dracula"}, key: "style", want: "dracula"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if len(tt.flags) > 0 {
+				f := rootCmd.Flags().Lookup(tt.flags[0][2:])
+				t.Cleanup(func() {
+					_ = f.Value.Set(f.DefValue)
+					f.Changed = false
+				})
+				if err := rootCmd.ParseFlags(tt.flags); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := viper.GetString(tt.key); got != tt.want {
+				t.Errorf("Expected %s %q, got %q", tt.key, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConfigStringMap(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "glow.yml")
+	if err := os.WriteFile(file, []byte("diagramCommands:\n  d2: d2 -\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfigFile(file); err != nil {
+		t.Fatal(err)
+	}
+	if got := configStringMap("diagramCommands"); !maps.Equal(got, map[string]string{"d2": "d2 -"}) {
+		t.Errorf("Expected diagramCommands from the config file, got %v", got)
+	}
+
+	t.Setenv("GLOW_DIAGRAM_COMMANDS", "dot:graph-easy, plantuml:")
+	want := map[string]string{"dot": "graph-easy", "plantuml": ""}
+	if got := configStringMap("diagramCommands"); !maps.Equal(got, want) {
+		t.Errorf("Expected %v from GLOW_DIAGRAM_COMMANDS, got %v", want, got)
+	}
+}
+
+func TestConfigCodeFilters(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "glow.yml")
+	config := "codeFilters:\n  graphql: prettier --parser graphql\n  sql:\n    command: sqlite3 data.db\n    output: markdown\n"
+	if err := os.WriteFile(file, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfigFile(file); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]mermaid.Filter{
+		"graphql": {Command: "prettier --parser graphql", Output: mermaid.FilterReplace},
+		"sql":     {Command: "sqlite3 data.db", Output: mermaid.FilterMarkdown},
+	}
+	if got, err := configCodeFilters(); err != nil || !maps.Equal(got, want) {
+		t.Errorf("Expected %v from the config file, got %v, %v", want, got, err)
+	}
+
+	t.Setenv("GLOW_CODE_FILTERS", "sql:sqlformat -")
+	want = map[string]mermaid.Filter{"sql": {Command: "sqlformat -", Output: mermaid.FilterReplace}}
+	if got, err := configCodeFilters(); err != nil || !maps.Equal(got, want) {
+		t.Errorf("Expected %v from GLOW_CODE_FILTERS, got %v, %v", want, got, err)
+	}
+}
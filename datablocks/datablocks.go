@@ -0,0 +1,284 @@
+// Package datablocks formats JSON and YAML code blocks: it re-indents
+// minified JSON, and colors the keys of both by how deeply they're nested and
+// their values by type, where chroma colors each token alike.
+package datablocks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/hholst80/glow/utils"
+)
+
+// placeholderPrefix starts the placeholder of a line of a block highlighted
+// ahead of rendering.
+const placeholderPrefix = "@glow-data-"
+
+// keyStyles color keys, by how deeply they're nested.
+var keyStyles = []lipgloss.Style{
+	lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#0550AE", Dark: "#79C0FF"}),
+	lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#8250DF", Dark: "#D2A8FF"}),
+	lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#953800", Dark: "#FFA657"}),
+}
+
+var (
+	stringStyle  = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#1A7F37", Dark: "#7EE787"})
+	numberStyle  = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#0598BC", Dark: "#56D4DD"})
+	literalStyle = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#CF222E", Dark: "#FF7B72"})
+	mutedStyle   = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#6E7781", Dark: "#8B949E"})
+)
+
+// languages are the code block languages formatted, by the name of their
+// format.
+var languages = map[string]string{"json": "json", "jsonc": "json", "yaml": "yaml", "yml": "yaml"}
+
+var (
+	// yamlKeyRegex matches a line of YAML with a key: its indentation, the
+	// dashes of the list items it opens, the key and the rest of the line.
+	yamlKeyRegex = regexp.MustCompile(`^(\s*)((?:-\s+)*)("[^"]*"|'[^']*'|[^\s#'"\-{\[][^:#]*?)(\s*:)(\s.*|)$`)
+
+	// yamlItemRegex matches a list item of YAML without a key.
+	yamlItemRegex = regexp.MustCompile(`^(\s*)((?:-\s+)+|-$)(.*)$`)
+
+	// numberRegex matches a number.
+	numberRegex = regexp.MustCompile(`^[-+]?(\d[\d_]*(\.\d*)?|\.\d+)([eE][-+]?\d+)?$|^0x[0-9a-fA-F]+$`)
+)
+
+// Preprocessor formats the JSON and YAML code blocks of a document before
+// glamour renders it. Process replaces them, and Restore puts the lines it
+// highlighted itself in place of their placeholders after rendering.
+type Preprocessor struct {
+	format    bool
+	highlight bool
+	raw       map[string]string // placeholder to the line of a block
+	blocks    int
+}
+
+// NewPreprocessor returns a Preprocessor that re-indents minified JSON if
+// format is set, and colors keys and values if highlight is.
+func NewPreprocessor(format, highlight bool) *Preprocessor {
+	return &Preprocessor{format: format, highlight: highlight, raw: map[string]string{}}
+}
+
+// Process returns markdown with its JSON and YAML code blocks formatted.
+func (p *Preprocessor) Process(markdown string) string {
+	if !p.format && !p.highlight {
+		return markdown
+	}
+	lines := strings.Split(markdown, "\n")
+	out := make([]string, 0, len(lines))
+	fence := ""
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		inCode := fence != ""
+		fence = utils.NextFence(fence, line)
+		language, ok := languages[utils.FenceLanguage(fence)]
+		if inCode || !ok {
+			out = append(out, line)
+			continue
+		}
+
+		end := i + 1
+		for end < len(lines) && utils.NextFence(fence, lines[end]) != "" {
+			end++
+		}
+		source := strings.Join(lines[i+1:end], "\n")
+		if p.format && language == "json" {
+			source = indentJSON(source)
+		}
+		switch {
+		case p.highlight && language == "json":
+			out = append(out, p.placeholders(highlightJSON(source))...)
+		case p.highlight:
+			out = append(out, p.placeholders(highlightYAML(source))...)
+		default:
+			out = append(out, line, source)
+			if end < len(lines) {
+				out = append(out, lines[end])
+			}
+		}
+		fence = ""
+		i = end
+	}
+	return strings.Join(out, "\n")
+}
+
+// placeholders returns a code block of placeholders for the lines of a
+// highlighted block, keeping the lines for Restore.
+func (p *Preprocessor) placeholders(source string) []string {
+	out := []string{"```"}
+	for i, line := range strings.Split(source, "\n") {
+		key := fmt.Sprintf("%s%d-%d@", placeholderPrefix, p.blocks, i)
+		p.raw[key] = line
+		out = append(out, key)
+	}
+	p.blocks++
+	return append(out, "```")
+}
+
+// Restore replaces the placeholders of highlighted blocks in rendered output
+// with their lines.
+func (p *Preprocessor) Restore(rendered string) string {
+	if len(p.raw) == 0 {
+		return rendered
+	}
+	lines := strings.Split(rendered, "\n")
+	for i, line := range lines {
+		idx := strings.Index(line, placeholderPrefix)
+		if idx < 0 {
+			continue
+		}
+		end := strings.Index(line[idx+len(placeholderPrefix):], "@")
+		if end < 0 {
+			continue
+		}
+		blockLine, ok := p.raw[line[idx:idx+len(placeholderPrefix)+end+1]]
+		if !ok {
+			continue
+		}
+		prefix := line[:idx]
+		if strings.Contains(prefix, "\x1b[") {
+			// End the code block's styling before the line's own
+			prefix += "\x1b[0m"
+		}
+		lines[i] = prefix + blockLine
+	}
+	return strings.Join(lines, "\n")
+}
+
+// indentJSON returns JSON written on one line indented over several, and
+// anything else as it is.
+func indentJSON(source string) string {
+	trimmed := strings.TrimSpace(source)
+	if strings.Contains(trimmed, "\n") || !json.Valid([]byte(trimmed)) {
+		return source
+	}
+	var b bytes.Buffer
+	if err := json.Indent(&b, []byte(trimmed), "", "  "); err != nil {
+		return source
+	}
+	return b.String()
+}
+
+// keyStyle returns the style of a key nested depth levels deep, from 0.
+func keyStyle(depth int) lipgloss.Style {
+	return keyStyles[max(0, depth)%len(keyStyles)]
+}
+
+// highlightJSON returns JSON with its keys colored by depth, its values by
+// type, and its punctuation and comments muted.
+func highlightJSON(source string) string {
+	var b strings.Builder
+	depth := 0
+	for i := 0; i < len(source); {
+		c := source[i]
+		j := i + 1
+		switch {
+		case c == '"':
+			for j < len(source) && source[j] != '"' && source[j] != '\n' {
+				if source[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j = min(j+1, len(source))
+			rest := strings.TrimLeft(source[j:], " \t")
+			if strings.HasPrefix(rest, ":") {
+				b.WriteString(keyStyle(depth - 1).Render(source[i:j]))
+			} else {
+				b.WriteString(stringStyle.Render(source[i:j]))
+			}
+		case c == '/' && strings.HasPrefix(source[i:], "//"):
+			for j < len(source) && source[j] != '\n' {
+				j++
+			}
+			b.WriteString(mutedStyle.Render(source[i:j]))
+		case c == '{' || c == '[':
+			depth++
+			b.WriteString(mutedStyle.Render(string(c)))
+		case c == '}' || c == ']':
+			depth--
+			b.WriteString(mutedStyle.Render(string(c)))
+		case c == ',' || c == ':':
+			b.WriteString(mutedStyle.Render(string(c)))
+		case c == '-' || (c >= '0' && c <= '9'):
+			for j < len(source) && strings.IndexByte("0123456789.eE+-", source[j]) >= 0 {
+				j++
+			}
+			b.WriteString(numberStyle.Render(source[i:j]))
+		case c >= 'a' && c <= 'z':
+			for j < len(source) && source[j] >= 'a' && source[j] <= 'z' {
+				j++
+			}
+			b.WriteString(literalStyle.Render(source[i:j]))
+		default:
+			b.WriteByte(c)
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// highlightYAML returns YAML with its keys colored by depth, its scalars by
+// type, and its punctuation and comments muted.
+func highlightYAML(source string) string {
+	lines := strings.Split(source, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") || trimmed == "---" || trimmed == "..." {
+			lines[i] = mutedStyle.Render(line)
+			continue
+		}
+		if m := yamlKeyRegex.FindStringSubmatch(line); m != nil {
+			depth := (len(m[1]) + len(m[2])) / 2
+			lines[i] = m[1] + render(mutedStyle, m[2]) + keyStyle(depth).Render(m[3]) + mutedStyle.Render(m[4]) + yamlValue(m[5])
+			continue
+		}
+		if m := yamlItemRegex.FindStringSubmatch(line); m != nil {
+			lines[i] = m[1] + render(mutedStyle, m[2]) + yamlValue(m[3])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// yamlValue returns the rest of a line of YAML after a key or a dash, its
+// scalar colored by type and its comment muted.
+func yamlValue(s string) string {
+	value, comment := s, ""
+	if idx := strings.Index(s, " #"); idx >= 0 && !strings.ContainsAny(s[:idx], `"'`) {
+		value, comment = s[:idx], s[idx:]
+	}
+	scalar := strings.TrimSpace(value)
+	lead := value[:len(value)-len(strings.TrimLeft(value, " \t"))]
+	trail := value[len(strings.TrimRight(value, " \t")):]
+
+	var styled string
+	switch lower := strings.ToLower(scalar); {
+	case scalar == "":
+	case strings.HasPrefix(scalar, `"`), strings.HasPrefix(scalar, "'"):
+		styled = stringStyle.Render(scalar)
+	case numberRegex.MatchString(scalar):
+		styled = numberStyle.Render(scalar)
+	case lower == "true" || lower == "false" || lower == "null" || lower == "~" || lower == "yes" || lower == "no":
+		styled = literalStyle.Render(scalar)
+	case strings.Trim(scalar, "|>-+") == "", strings.HasPrefix(scalar, "&"), strings.HasPrefix(scalar, "*"):
+		styled = mutedStyle.Render(scalar)
+	case strings.HasPrefix(scalar, "{"), strings.HasPrefix(scalar, "["):
+		styled = scalar
+	default:
+		styled = stringStyle.Render(scalar)
+	}
+	return lead + styled + trail + render(mutedStyle, comment)
+}
+
+// render renders s in style, leaving it empty if it is.
+func render(style lipgloss.Style, s string) string {
+	if s == "" {
+		return ""
+	}
+	return style.Render(s)
+}
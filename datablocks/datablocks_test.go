@@ -0,0 +1,81 @@
+package datablocks
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
+)
+
+func TestProcessFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "minified",
+			in:   "```json\n{\"a\":[1,2],\"b\":{}}\n```\n",
+			want: "```json\n{\n  \"a\": [\n    1,\n    2\n  ],\n  \"b\": {}\n}\n```\n",
+		},
+		{
+			name: "already indented",
+			in:   "```json\n{\n\"a\": 1}\n```\n",
+			want: "```json\n{\n\"a\": 1}\n```\n",
+		},
+		{
+			name: "invalid",
+			in:   "```json\n{\"a\":\n```\n",
+			want: "```json\n{\"a\":\n```\n",
+		},
+		{
+			name: "not json",
+			in:   "```yaml\na: 1\n```\n\n```\n{\"a\":1}\n```\n",
+			want: "```yaml\na: 1\n```\n\n```\n{\"a\":1}\n```\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewPreprocessor(true, false).Process(tt.in); got != tt.want {
+				t.Errorf("Process() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessHighlight(t *testing.T) {
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	lipgloss.SetHasDarkBackground(true)
+	t.Cleanup(func() { lipgloss.SetColorProfile(termenv.Ascii) })
+
+	source := "{\"a\": {\"b\": [1, true, \"s\"]}} // note"
+	yaml := "# c\nkey: 1\nlist:\n  - name: x # y\n  - true"
+	p := NewPreprocessor(false, true)
+	out := p.Process("```json\n" + source + "\n```\n\n```yml\n" + yaml + "\n```\n")
+	if strings.Contains(out, "json") || !strings.Contains(out, "```\n"+placeholderPrefix+"0-0@\n```") {
+		t.Fatalf("Expected the blocks replaced by placeholders, got:\n%s", out)
+	}
+
+	restored := p.Restore(out)
+	if got, want := ansi.Strip(restored), "```\n"+source+"\n```\n\n```\n"+yaml+"\n```\n"; got != want {
+		t.Errorf("Expected the text of the blocks kept, got %q, want %q", got, want)
+	}
+	for _, styled := range []string{
+		keyStyles[0].Render(`"a"`),
+		keyStyles[1].Render(`"b"`),
+		numberStyle.Render("1"),
+		literalStyle.Render("true"),
+		stringStyle.Render(`"s"`),
+		mutedStyle.Render("// note"),
+		keyStyles[0].Render("key"),
+		keyStyles[2].Render("name"),
+		stringStyle.Render("x"),
+		mutedStyle.Render(" # y"),
+	} {
+		if !strings.Contains(restored, styled) {
+			t.Errorf("Expected %q in:\n%q", styled, restored)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+// Package dedent removes the indentation blocks of text share, for the
+// converters of other markup languages to markdown.
+package dedent
+
+import "strings"
+
+// Lines returns lines without the indentation they all share. Blank lines
+// don't count, and those shorter than the indentation are left empty.
+func Lines(lines []string) []string {
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent <= 0 {
+		return lines
+	}
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if len(line) >= indent {
+			out[i] = line[indent:]
+		} else {
+			out[i] = strings.TrimLeft(line, " \t")
+		}
+	}
+	return out
+}
@@ -0,0 +1,21 @@
+package dedent
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	for _, tt := range []struct {
+		lines, want []string
+	}{
+		{[]string{"  a", "    b", "", "  c"}, []string{"a", "  b", "", "c"}},
+		{[]string{"\ta", "\t\tb"}, []string{"a", "\tb"}},
+		{[]string{"    a", " ", "    b"}, []string{"a", "", "b"}},
+		{[]string{"a", "  b"}, []string{"a", "  b"}},
+	} {
+		if got := Lines(tt.lines); !slices.Equal(got, tt.want) {
+			t.Errorf("Lines(%q) = %q, want %q", tt.lines, got, tt.want)
+		}
+	}
+}
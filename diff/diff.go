@@ -0,0 +1,300 @@
+// Package diff compares markdown documents word by word, as a document that
+// shows both with the words added and deleted marked, to be rendered and
+// then highlighted.
+package diff
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Markers of added and deleted words in a compared document. They're
+// private use characters, which renderers pass through as they are.
+const (
+	insertStart = "\uE000"
+	insertEnd   = "\uE001"
+	deleteStart = "\uE002"
+	deleteEnd   = "\uE003"
+)
+
+// maxEdits is the most differences looked for before the rest of two texts
+// is taken as replaced as a whole.
+const maxEdits = 2000
+
+type kind int
+
+const (
+	equal kind = iota
+	deleted
+	inserted
+)
+
+// edit is a token of an edit script.
+type edit struct {
+	kind kind
+	text string
+}
+
+// tokenRegex splits text into words, runs of blanks and line breaks.
+var tokenRegex = regexp.MustCompile(`\n|[ \t]+|[^ \t\n]+`)
+
+// structureRegex matches the tokens that start a block at the start of a
+// line: headings, quotes, list items and code fences.
+var structureRegex = regexp.MustCompile("^(#{1,6}|>+|[-*+]|[0-9]{1,9}[.)]|```.*|~~~.*)$")
+
+// Markdown compares two markdown documents. It returns the new one with the
+// words that were added marked, and the words that were deleted marked where
+// they were. Lines that were deleted as a whole are kept as they were, while
+// deletions within changed lines take the layout of the new lines.
+func Markdown(old, new string) string {
+	if old != "" && !strings.HasSuffix(old, "\n") {
+		old += "\n"
+	}
+	if new != "" && !strings.HasSuffix(new, "\n") {
+		new += "\n"
+	}
+
+	var w markWriter
+	w.lineStart = true
+	lines := diffTokens(splitLines(old), splitLines(new))
+	for i := 0; i < len(lines); {
+		if lines[i].kind == equal {
+			w.write(lines[i], false)
+			i++
+			continue
+		}
+
+		// A hunk of changed lines
+		var del, ins strings.Builder
+		for ; i < len(lines) && lines[i].kind != equal; i++ {
+			if lines[i].kind == deleted {
+				del.WriteString(lines[i].text)
+			} else {
+				ins.WriteString(lines[i].text)
+			}
+		}
+		delTokens := tokenRegex.FindAllString(del.String(), -1)
+		insTokens := tokenRegex.FindAllString(ins.String(), -1)
+		replacing := len(delTokens) > 0 && len(insTokens) > 0
+		for _, e := range diffTokens(delTokens, insTokens) {
+			w.write(e, replacing)
+		}
+	}
+	w.close()
+	return w.b.String()
+}
+
+// splitLines splits text into lines, keeping their line breaks.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// markWriter writes tokens, marking those added and deleted.
+type markWriter struct {
+	b         strings.Builder
+	open      kind // of the marked span being written, if any
+	lineStart bool // whether only blanks and block markers precede on the line
+	dropped   bool // whether a deleted block marker was just left out
+}
+
+// write writes a token. Replacing deletions give way to the new text's line
+// breaks and block markers.
+func (w *markWriter) write(e edit, replacing bool) {
+	t := e.text
+	dropped := w.dropped
+	w.dropped = false
+	switch {
+	case e.kind == equal:
+		w.close()
+		w.b.WriteString(t)
+		if strings.HasSuffix(t, "\n") {
+			w.lineStart = true
+		} else if !isBlank(t) && !isStructure(t, w.lineStart) {
+			w.lineStart = false
+		}
+
+	case t == "\n":
+		if e.kind == deleted && replacing {
+			// Deleted lines run on, without the block markers they start with
+			w.mark(e.kind, " ")
+			w.lineStart = true
+			return
+		}
+		w.close()
+		w.b.WriteString(t)
+		w.lineStart = true
+
+	case isBlank(t):
+		if dropped && e.kind == deleted {
+			// The blank after a block marker left out
+			return
+		}
+		if w.open == e.kind {
+			w.b.WriteString(t)
+		} else {
+			w.close()
+			w.b.WriteString(t)
+		}
+
+	case isStructure(t, w.lineStart):
+		if e.kind == deleted && replacing {
+			w.dropped = true
+			return
+		}
+		w.close()
+		w.b.WriteString(t)
+
+	default:
+		w.mark(e.kind, t)
+		w.lineStart = false
+	}
+}
+
+// mark writes text in a marked span of a kind.
+func (w *markWriter) mark(k kind, text string) {
+	if w.open != k {
+		w.close()
+		if k == inserted {
+			w.b.WriteString(insertStart)
+		} else {
+			w.b.WriteString(deleteStart)
+		}
+		w.open = k
+	}
+	w.b.WriteString(text)
+}
+
+// close ends the marked span being written, if any.
+func (w *markWriter) close() {
+	switch w.open {
+	case inserted:
+		w.b.WriteString(insertEnd)
+	case deleted:
+		w.b.WriteString(deleteEnd)
+	}
+	w.open = equal
+}
+
+func isBlank(t string) bool {
+	return strings.Trim(t, " \t") == ""
+}
+
+// isStructure reports whether a token is markdown syntax that marks would
+// break: block markers at the start of a line, and table pipes, rules and
+// underlines anywhere.
+func isStructure(t string, lineStart bool) bool {
+	if lineStart && structureRegex.MatchString(t) {
+		return true
+	}
+	return strings.Trim(t, "|-:=*_") == "" && (strings.Contains(t, "|") || len(t) >= 3)
+}
+
+// diffTokens returns the shortest edit script from a to b.
+func diffTokens(a, b []string) []edit {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	edits := make([]edit, 0, len(a)+len(b)-pre-suf)
+	for _, t := range a[:pre] {
+		edits = append(edits, edit{equal, t})
+	}
+	edits = append(edits, myers(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for _, t := range a[len(a)-suf:] {
+		edits = append(edits, edit{equal, t})
+	}
+	return edits
+}
+
+// myers returns the shortest edit script from a to b with Myers' algorithm,
+// or, past maxEdits differences, all of a deleted and all of b inserted.
+func myers(a, b []string) []edit {
+	n, m := len(a), len(b)
+	if n+m == 0 {
+		return nil
+	}
+
+	// v[k] is the furthest x reached on diagonal k; trace[d] holds the
+	// diagonals -d..d of v before step d
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+	for d := 0; d <= n+m && d <= maxEdits; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b)
+			}
+		}
+	}
+
+	edits := make([]edit, 0, n+m)
+	for _, t := range a {
+		edits = append(edits, edit{deleted, t})
+	}
+	for _, t := range b {
+		edits = append(edits, edit{inserted, t})
+	}
+	return edits
+}
+
+// backtrack follows the trace of myers back from the end of a and b.
+func backtrack(trace [][]int, a, b []string) []edit {
+	var edits []edit
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := func(k int) int { return trace[d][k+d] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v(k-1) < v(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = v(prevK)
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{equal, a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, edit{inserted, b[prevY]})
+			} else {
+				edits = append(edits, edit{deleted, a[prevX]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
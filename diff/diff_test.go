@@ -0,0 +1,130 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+// plain returns a compared document with its markers as PlainMarks.
+func plain(s string) string {
+	return Highlight(s, PlainMarks)
+}
+
+func TestMarkdown(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{
+			name: "same",
+			old:  "# Title\n\nText.\n",
+			new:  "# Title\n\nText.\n",
+			want: "# Title\n\nText.\n",
+		},
+		{
+			name: "words",
+			old:  "The quick brown fox jumps.\n",
+			new:  "The quick red fox jumps high.\n",
+			want: "The quick [-brown-]{+red+} fox [-jumps.-]{+jumps high.+}\n",
+		},
+		{
+			name: "heading",
+			old:  "# Guide\n",
+			new:  "# Guide v2\n",
+			want: "# Guide {+v2+}\n",
+		},
+		{
+			name: "added list item",
+			old:  "- one\n- two\n",
+			new:  "- one\n- two\n- three\n",
+			want: "- one\n- two\n- {+three+}\n",
+		},
+		{
+			name: "deleted lines keep their layout",
+			old:  "Keep.\n\n## Gone\n\nGone too.\n\nKeep.\n",
+			new:  "Keep.\n\nKeep.\n",
+			want: "Keep.\n\n## [-Gone-]\n\n[-Gone too.-]\n\nKeep.\n",
+		},
+		{
+			name: "changed lines take the new layout",
+			old:  "- first\n- second\n",
+			new:  "1. only\n",
+			want: "1. [-first second-]{+only+}\n",
+		},
+		{
+			name: "table cell",
+			old:  "| a | b |\n|---|---|\n| 1 | 2 |\n",
+			new:  "| a | b |\n|---|---|\n| 1 | 3 |\n",
+			want: "| a | b |\n|---|---|\n| 1 | [-2-]{+3+} |\n",
+		},
+		{
+			name: "added code block",
+			old:  "Text.\n",
+			new:  "Text.\n\n```go\nx := 1\n```\n",
+			want: "Text.\n\n```go\n{+x := 1+}\n```\n",
+		},
+		{
+			name: "missing final line break",
+			old:  "Text.",
+			new:  "Text.\n",
+			want: "Text.\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := plain(Markdown(tt.old, tt.new)); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffTokens(t *testing.T) {
+	a := strings.Split("a b c a b b a", " ")
+	b := strings.Split("c b a b a c", " ")
+	edits := diffTokens(a, b)
+
+	// The edit script turns a into b, in the fewest edits
+	var gotA, gotB []string
+	changes := 0
+	for _, e := range edits {
+		if e.kind != inserted {
+			gotA = append(gotA, e.text)
+		}
+		if e.kind != deleted {
+			gotB = append(gotB, e.text)
+		}
+		if e.kind != equal {
+			changes++
+		}
+	}
+	if strings.Join(gotA, " ") != strings.Join(a, " ") || strings.Join(gotB, " ") != strings.Join(b, " ") {
+		t.Errorf("edit script doesn't turn %v into %v: %v", a, b, edits)
+	}
+	if changes != 5 {
+		t.Errorf("edit script has %d changes, want 5", changes)
+	}
+}
+
+func TestMyersLimit(t *testing.T) {
+	a := make([]string, maxEdits+1)
+	b := make([]string, maxEdits+1)
+	for i := range a {
+		a[i], b[i] = "a", "b"
+	}
+	edits := myers(a, b)
+	if len(edits) != len(a)+len(b) || edits[0].kind != deleted || edits[len(edits)-1].kind != inserted {
+		t.Errorf("texts past the limit aren't replaced as a whole")
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	// Marks are applied again after the renderer's styles, and ended before
+	// line breaks, leaving the margins as they are
+	rendered := "\x1b[37mThe " + deleteStart + "old\x1b[0m\x1b[37m words" + deleteEnd + " " + insertStart + "new\n  line" + insertEnd + "\x1b[0m\n"
+	want := "\x1b[37mThe " + DarkMarks.Delete + "old\x1b[0m\x1b[37m " + DarkMarks.Delete + "words" + DarkMarks.DeleteEnd + " " +
+		DarkMarks.Insert + "new" + DarkMarks.InsertEnd + "\n  " + DarkMarks.Insert + "line" + DarkMarks.InsertEnd + "\x1b[0m\n"
+	if got := Highlight(rendered, DarkMarks); got != want {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,115 @@
+package diff
+
+import (
+	"strings"
+)
+
+// Marks are what the words added and deleted are shown with once rendered.
+type Marks struct {
+	Insert, InsertEnd string
+	Delete, DeleteEnd string
+
+	// Whether the marks are SGR escape sequences, which are applied again
+	// after the renderer's own and ended at the end of each line
+	Escapes bool
+}
+
+// Marks of compared documents.
+var (
+	// DarkMarks show words on green and struck through on red backgrounds
+	// that suit dark terminals
+	DarkMarks = Marks{
+		Insert: "\x1b[48;5;22m", InsertEnd: "\x1b[49m",
+		Delete: "\x1b[48;5;52;9m", DeleteEnd: "\x1b[49;29m",
+		Escapes: true,
+	}
+
+	// LightMarks show words as DarkMarks do, on backgrounds that suit light
+	// terminals
+	LightMarks = Marks{
+		Insert: "\x1b[48;5;194m", InsertEnd: "\x1b[49m",
+		Delete: "\x1b[48;5;224;9m", DeleteEnd: "\x1b[49;29m",
+		Escapes: true,
+	}
+
+	// PlainMarks show words as git's word diff does, for output without
+	// colors
+	PlainMarks = Marks{
+		Insert: "{+", InsertEnd: "+}",
+		Delete: "[-", DeleteEnd: "-]",
+	}
+)
+
+// Highlight replaces the markers of a rendered compared document with marks.
+func Highlight(rendered string, m Marks) string {
+	if !m.Escapes {
+		return strings.NewReplacer(
+			insertStart, m.Insert, insertEnd, m.InsertEnd,
+			deleteStart, m.Delete, deleteEnd, m.DeleteEnd,
+		).Replace(rendered)
+	}
+
+	var b strings.Builder
+	var start, end string // of the span being highlighted, if any
+	applied := false      // whether start is in effect
+	for i := 0; i < len(rendered); {
+		s := rendered[i:]
+		switch {
+		case strings.HasPrefix(s, insertStart), strings.HasPrefix(s, deleteStart):
+			start, end = m.Insert, m.InsertEnd
+			if strings.HasPrefix(s, deleteStart) {
+				start, end = m.Delete, m.DeleteEnd
+			}
+			applied = false
+			i += len(insertStart)
+
+		case strings.HasPrefix(s, insertEnd), strings.HasPrefix(s, deleteEnd):
+			if applied {
+				b.WriteString(end)
+			}
+			start, end, applied = "", "", false
+			i += len(insertEnd)
+
+		case s[0] == '\x1b':
+			// Copy an escape sequence; a style of the renderer's may reset ours
+			n := escapeLen(s)
+			b.WriteString(s[:n])
+			if strings.HasSuffix(s[:n], "m") {
+				applied = false
+			}
+			i += n
+
+		case s[0] == '\n':
+			if applied {
+				b.WriteString(end)
+				applied = false
+			}
+			b.WriteByte('\n')
+			i++
+
+		default:
+			// Spaces are left as they are, so the renderer's margins and
+			// padding aren't highlighted
+			if start != "" && !applied && s[0] != ' ' {
+				b.WriteString(start)
+				applied = true
+			}
+			b.WriteByte(s[0])
+			i++
+		}
+	}
+	return b.String()
+}
+
+// escapeLen returns the length of the escape sequence s starts with.
+func escapeLen(s string) int {
+	if len(s) < 2 || s[1] != '[' {
+		return 1
+	}
+	for i := 2; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/hholst80/glow/diff"
+	"github.com/muesli/termenv"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	diffGitRev string
+
+	diffCmd = &cobra.Command{
+		Use:   "diff OLD NEW",
+		Short: "Show the changes between two markdown documents, rendered",
+		Long:  paragraph(fmt.Sprintf("\n%s the new document with the words added and deleted since the old one highlighted. With --git, the document is compared with its version at a git revision.", keyword("Render"))),
+		Example: paragraph("glow diff old.md new.md\n" +
+			"glow diff --git HEAD~1 README.md\n" +
+			"glow diff old.md new.md --pager"),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if diffGitRev != "" {
+				return cobra.ExactArgs(1)(cmd, args)
+			}
+			return cobra.ExactArgs(2)(cmd, args)
+		},
+		RunE: diffDocuments,
+	}
+)
+
+func diffDocuments(cmd *cobra.Command, args []string) error {
+	// The output flags of diff take the place of the root command's
+	for _, key := range []string{"style", "width", "pager"} {
+		if f := cmd.Flags().Lookup(key); f.Changed {
+			viper.Set(key, f.Value.String())
+		}
+	}
+	if err := validateOptions(cmd); err != nil {
+		return err
+	}
+
+	var old, new []byte
+	var err error
+	newArg := args[len(args)-1]
+	if diffGitRev != "" {
+		old, err = gitShow(diffGitRev, newArg)
+	} else {
+		old, err = readArg(args[0])
+	}
+	if err != nil {
+		return err
+	}
+	if new, err = readArg(newArg); err != nil {
+		return err
+	}
+
+	compared := diff.Markdown(string(old), string(new))
+	_, out, err := renderCLI(cmd, &source{reader: io.NopCloser(strings.NewReader(compared)), URL: newArg})
+	if err != nil {
+		return err
+	}
+	out = diff.Highlight(out, diffMarks())
+
+	if pager {
+		return runPager(out)
+	}
+	if _, err := fmt.Fprint(cmd.OutOrStdout(), out); err != nil {
+		return fmt.Errorf("unable to write to writer: %w", err)
+	}
+	return nil
+}
+
+// readArg returns the markdown of a source given as an argument.
+func readArg(arg string) ([]byte, error) {
+	src, err := sourceFromArg(arg)
+	if err != nil {
+		return nil, err
+	}
+	defer src.reader.Close() //nolint:errcheck
+	b, err := io.ReadAll(src.reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read from reader: %w", err)
+	}
+	return b, nil
+}
+
+// gitShow returns a file as it was at a git revision.
+func gitShow(rev, file string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	c := exec.Command("git", "-C", filepath.Dir(file), "show", rev+":./"+filepath.Base(file)) //nolint:gosec
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("unable to read %s at %s: %s", file, rev, strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("unable to run git: %w", err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// diffMarks returns the marks that suit the style and terminal.
+func diffMarks() diff.Marks {
+	switch {
+	case style == "notty" || lipgloss.ColorProfile() == termenv.Ascii:
+		return diff.PlainMarks
+	case style == "light" || (style == "auto" && !lipgloss.HasDarkBackground()):
+		return diff.LightMarks
+	default:
+		return diff.DarkMarks
+	}
+}
+
+func init() {
+	diffCmd.Flags().StringVar(&diffGitRev, "git", "", "compare the document with its version at a git revision")
+	diffCmd.Flags().StringP("style", "s", "", "style name or JSON path")
+	diffCmd.Flags().StringP("width", "w", "0", "word-wrap at width, or auto to fit the document's tables and code (set to 0 to disable)")
+	diffCmd.Flags().BoolP("pager", "p", false, "display with pager")
+}
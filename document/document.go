@@ -0,0 +1,269 @@
+// Package document parses the structure of markdown documents: their
+// headings, links, code blocks, tasks and footnotes, for scripts, editors
+// and the pager, from a single parse of the document.
+package document
+
+import (
+	"bytes"
+
+	"github.com/hholst80/glow/utils"
+	"github.com/hholst80/glow/wikilinks"
+	"github.com/yuin/goldmark"
+	emoji "github.com/yuin/goldmark-emoji/ast"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"go.yaml.in/yaml/v3"
+)
+
+// Document is the structure of a markdown document. Lines count from 1, and
+// lists are empty rather than nil, so that they're [] in JSON.
+type Document struct {
+	// Front matter, or nil if there's none or it isn't valid YAML
+	Frontmatter map[string]any `json:"frontmatter"`
+
+	Headings   []Heading   `json:"headings"`
+	Links      []Link      `json:"links"`
+	Images     []Link      `json:"images"`
+	CodeBlocks []CodeBlock `json:"codeBlocks"`
+	Tasks      []Task      `json:"tasks"`
+	Footnotes  []Footnote  `json:"footnotes"`
+	WikiLinks  []WikiLink  `json:"wikiLinks"`
+}
+
+// Heading is a heading of a document.
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	ID    string `json:"id"` // what links to its section end with
+	Line  int    `json:"line"`
+}
+
+// Link is a link or image of a document.
+type Link struct {
+	URL  string `json:"url"`  // as written
+	Text string `json:"text"` // alt text of images
+	Line int    `json:"line"`
+}
+
+// CodeBlock is a fenced or indented code block of a document.
+type CodeBlock struct {
+	Language string `json:"language"` // "" if none is given
+	Code     string `json:"code"`
+	Line     int    `json:"line"` // of its first line of code
+}
+
+// Task is an item of a task list, "- [ ] like this".
+type Task struct {
+	Text    string `json:"text"`
+	Checked bool   `json:"checked"`
+	Line    int    `json:"line"`
+}
+
+// Footnote is the definition of a footnote, "[^1]: like this".
+type Footnote struct {
+	Label string `json:"label"` // as written between [^ and ]
+	Text  string `json:"text"`
+	Line  int    `json:"line"`
+}
+
+// WikiLink is a link to a note by its name, "[[Page Name]]".
+type WikiLink struct {
+	Target  string `json:"target"`
+	Heading string `json:"heading"` // after a #, "" if none is given
+	Label   string `json:"label"`   // the text shown
+	Line    int    `json:"line"`
+}
+
+// Parse returns the structure of a markdown document.
+func Parse(markdown []byte) Document {
+	// The front matter is blanked out rather than removed, so lines are
+	// counted in the whole document
+	body := utils.RemoveFrontmatter(markdown)
+	header := markdown[:len(markdown)-len(body)]
+	source := append(bytes.Repeat([]byte("\n"), bytes.Count(header, []byte("\n"))), body...)
+
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM, extension.Footnote),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	)
+	doc := FromAST(md.Parser().Parse(text.NewReader(source)), source, nil)
+	if fm := utils.Frontmatter(markdown); fm != nil {
+		if err := yaml.Unmarshal(fm, &doc.Frontmatter); err != nil {
+			doc.Frontmatter = nil
+		}
+	}
+	return doc
+}
+
+// FromAST returns the structure of a document parsed by goldmark from
+// source, such as for rendering it, with the lines of source mapped to those
+// of the document by lines if it isn't nil. Links to wiki links, as
+// wikilinks.Process writes them, are taken for wiki links.
+func FromAST(root ast.Node, source []byte, lines func(int) int) Document {
+	doc := Document{
+		Headings:   []Heading{},
+		Links:      []Link{},
+		Images:     []Link{},
+		CodeBlocks: []CodeBlock{},
+		Tasks:      []Task{},
+		Footnotes:  []Footnote{},
+		WikiLinks:  []WikiLink{},
+	}
+	// Blocks that are empty are on line 0
+	mapLine := func(n int) int {
+		if lines == nil || n == 0 {
+			return n
+		}
+		return lines(n)
+	}
+
+	_ = ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		if l, image, ok := link(n, source); ok {
+			l.Line = mapLine(l.Line)
+			wiki, isWiki := wikilinks.FromURL(l.URL, l.Text)
+			switch {
+			case image:
+				doc.Images = append(doc.Images, l)
+			case isWiki:
+				doc.WikiLinks = append(doc.WikiLinks, WikiLink{wiki.Target, wiki.Heading, wiki.Label, l.Line})
+			default:
+				doc.Links = append(doc.Links, l)
+			}
+		}
+		if n.Type() == ast.TypeBlock && n.FirstChild() != nil && n.FirstChild().Type() == ast.TypeInline {
+			for _, l := range wikiLinks(n, source) {
+				l.Line = mapLine(l.Line)
+				doc.WikiLinks = append(doc.WikiLinks, l)
+			}
+		}
+		switch n := n.(type) {
+		case *ast.Heading:
+			h := Heading{Level: n.Level, Text: nodeText(n, source), Line: mapLine(line(n, source))}
+			if v, ok := n.AttributeString("id"); ok {
+				if b, ok := v.([]byte); ok {
+					h.ID = string(b)
+				}
+			}
+			doc.Headings = append(doc.Headings, h)
+		case *ast.FencedCodeBlock:
+			doc.CodeBlocks = append(doc.CodeBlocks, CodeBlock{string(n.Language(source)), code(n, source), mapLine(line(n, source))})
+		case *ast.CodeBlock:
+			doc.CodeBlocks = append(doc.CodeBlocks, CodeBlock{"", code(n, source), mapLine(line(n, source))})
+		case *east.TaskCheckBox:
+			// The checkbox opens the first block of its list item
+			block := n.Parent()
+			doc.Tasks = append(doc.Tasks, Task{nodeText(block, source), n.IsChecked, mapLine(line(block, source))})
+		case *east.Footnote:
+			// Its line is that of its first paragraph
+			f := Footnote{Label: string(n.Ref), Text: nodeText(n, source)}
+			if n.FirstChild() != nil {
+				f.Line = mapLine(line(n.FirstChild(), source))
+			}
+			doc.Footnotes = append(doc.Footnotes, f)
+		}
+		return ast.WalkContinue, nil
+	})
+	return doc
+}
+
+// wikiLinks returns the wiki links of a block of text, such as a paragraph
+// or a table cell.
+func wikiLinks(n ast.Node, source []byte) []WikiLink {
+	var found []WikiLink
+	lines := n.Lines()
+	for i := range lines.Len() {
+		segment := lines.At(i)
+		for _, l := range wikilinks.Find(string(segment.Value(source))) {
+			n := bytes.Count(source[:segment.Start], []byte("\n")) + 1
+			found = append(found, WikiLink{l.Target, l.Heading, l.Label, n})
+		}
+	}
+	return found
+}
+
+// link returns the link or image a node is, and whether it's an image, or
+// false if it's neither.
+func link(n ast.Node, source []byte) (l Link, image, ok bool) {
+	switch n := n.(type) {
+	case *ast.Link:
+		return Link{string(n.Destination), nodeText(n, source), inlineLine(n, source)}, false, true
+	case *ast.Image:
+		return Link{string(n.Destination), nodeText(n, source), inlineLine(n, source)}, true, true
+	case *ast.AutoLink:
+		return Link{string(n.URL(source)), string(n.Label(source)), inlineLine(n, source)}, false, true
+	}
+	return Link{}, false, false
+}
+
+// nodeText returns the plain text of a node's inlines.
+func nodeText(n ast.Node, source []byte) string {
+	var b bytes.Buffer
+	_ = ast.Walk(n, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		switch n := n.(type) {
+		case *ast.Text:
+			if entering {
+				b.Write(n.Segment.Value(source))
+				if n.SoftLineBreak() {
+					b.WriteByte(' ')
+				}
+			}
+		case *ast.String:
+			if entering {
+				b.Write(n.Value)
+			}
+		case *emoji.Emoji:
+			if entering {
+				b.WriteString(string(n.Value.Unicode))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return string(bytes.TrimSpace(b.Bytes()))
+}
+
+// code returns the content of a code block.
+func code(n ast.Node, source []byte) string {
+	var b bytes.Buffer
+	lines := n.Lines()
+	for i := range lines.Len() {
+		segment := lines.At(i)
+		b.Write(segment.Value(source))
+	}
+	return b.String()
+}
+
+// line returns the line a block starts on, or 0 if it's empty.
+func line(n ast.Node, source []byte) int {
+	if n.Lines().Len() == 0 {
+		return 0
+	}
+	return bytes.Count(source[:n.Lines().At(0).Start], []byte("\n")) + 1
+}
+
+// inlineLine returns the line an inline node is on: that of its first text,
+// or else of the block it's in.
+func inlineLine(n ast.Node, source []byte) int {
+	offset := -1
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if t, ok := c.(*ast.Text); ok && entering {
+			offset = t.Segment.Start
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	for p := n; offset < 0 && p != nil; p = p.Parent() {
+		if p.Type() == ast.TypeBlock && p.Lines().Len() > 0 {
+			offset = p.Lines().At(0).Start
+		}
+	}
+	if offset < 0 {
+		return 0
+	}
+	return bytes.Count(source[:offset], []byte("\n")) + 1
+}
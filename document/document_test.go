@@ -0,0 +1,63 @@
+package document
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	doc := Parse([]byte("---\ntitle: Notes\n---\n\n" +
+		"# Intro `code`\n\n" +
+		"See [the site](https://example.com) and ![logo](logo.png).\n\n" +
+		"- [ ] write *docs*\n" +
+		"- [x] ship it\n\n" +
+		"```go\nfmt.Println()\n```\n\n" +
+		"    indented\n"))
+
+	if want := map[string]any{"title": "Notes"}; !reflect.DeepEqual(doc.Frontmatter, want) {
+		t.Errorf("front matter is %v, want %v", doc.Frontmatter, want)
+	}
+	if want := []Heading{{1, "Intro code", "intro-code", 5}}; !reflect.DeepEqual(doc.Headings, want) {
+		t.Errorf("headings are %+v, want %+v", doc.Headings, want)
+	}
+	if want := []Link{{"https://example.com", "the site", 7}}; !reflect.DeepEqual(doc.Links, want) {
+		t.Errorf("links are %+v, want %+v", doc.Links, want)
+	}
+	if want := []Link{{"logo.png", "logo", 7}}; !reflect.DeepEqual(doc.Images, want) {
+		t.Errorf("images are %+v, want %+v", doc.Images, want)
+	}
+	if want := []Task{{"write docs", false, 9}, {"ship it", true, 10}}; !reflect.DeepEqual(doc.Tasks, want) {
+		t.Errorf("tasks are %+v, want %+v", doc.Tasks, want)
+	}
+	want := []CodeBlock{{"go", "fmt.Println()\n", 13}, {"", "indented\n", 16}}
+	if !reflect.DeepEqual(doc.CodeBlocks, want) {
+		t.Errorf("code blocks are %+v, want %+v", doc.CodeBlocks, want)
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	doc := Parse(nil)
+	if doc.Frontmatter != nil || doc.Headings == nil || doc.Links == nil || doc.Images == nil || doc.CodeBlocks == nil || doc.Tasks == nil ||
+		doc.Footnotes == nil || doc.WikiLinks == nil {
+		t.Errorf("empty document parsed as %+v, want empty lists", doc)
+	}
+}
+
+func TestParseFootnotesAndWikiLinks(t *testing.T) {
+	doc := Parse([]byte("# See [[Intro]]\n\n" +
+		"Read [[Setup#Install|the install]] and `[[not a link]]`.[^1]\n\n" +
+		"| Page | Link |\n|---|---|\n| x | [[Table Page]] |\n\n" +
+		"```\n[[in code]]\n```\n\n" +
+		"[^1]: A *short* note.\n"))
+
+	want := []WikiLink{{"Intro", "", "Intro", 1}, {"Setup", "Install", "the install", 3}, {"Table Page", "", "Table Page", 7}}
+	if !reflect.DeepEqual(doc.WikiLinks, want) {
+		t.Errorf("wiki links are %+v, want %+v", doc.WikiLinks, want)
+	}
+	if want := []Footnote{{"1", "A short note.", 13}}; !reflect.DeepEqual(doc.Footnotes, want) {
+		t.Errorf("footnotes are %+v, want %+v", doc.Footnotes, want)
+	}
+	if want := []Heading{{1, "See [[Intro]]", "see-intro", 1}}; !reflect.DeepEqual(doc.Headings, want) {
+		t.Errorf("headings are %+v, want %+v", doc.Headings, want)
+	}
+}
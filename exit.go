@@ -0,0 +1,35 @@
+package main
+
+import "errors"
+
+// Exit codes of glow, so scripts and CI can tell failures apart.
+const (
+	exitError    = 1 // any other error, such as an invalid flag
+	exitNotFound = 2 // the source doesn't exist
+	exitRender   = 3 // the document couldn't be rendered
+	exitBroken   = 4 // glow links --check found broken links
+	exitDiagram  = 5 // a diagram failed to render, with --strict
+)
+
+// exitCodeError is an error that makes glow exit with a code of its own.
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string { return e.err.Error() }
+func (e *exitCodeError) Unwrap() error { return e.err }
+
+// withExitCode makes err exit glow with code.
+func withExitCode(code int, err error) error {
+	return &exitCodeError{code, err}
+}
+
+// exitCode returns the code glow exits with after err.
+func exitCode(err error) int {
+	var e *exitCodeError
+	if errors.As(err, &e) {
+		return e.code
+	}
+	return exitError
+}
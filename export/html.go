@@ -0,0 +1,244 @@
+// Package export renders markdown documents to files that can be shared
+// outside the terminal, styled like Glow shows them.
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/hholst80/glow/mermaid"
+	"github.com/hholst80/glow/utils"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Options control how a document is exported.
+type Options struct {
+	// Glamour style name or JSON path the colors are taken from
+	Style string
+
+	// Title of the page; empty uses the front matter title or first heading
+	Title string
+
+	// Renders a mermaid diagram as SVG; nil uses the mermaid CLI. Diagrams
+	// it can't render are drawn as text.
+	DiagramSVG func(ctx context.Context, source string) ([]byte, error)
+
+	// JavaScript run at the end of the page, if any
+	Script string
+
+	// Copy the raw HTML of the document into the page, and keep links to
+	// javascript: URLs, as documents one trusts may have them. Otherwise
+	// they're left out, so the page runs no script a remote document has.
+	Unsafe bool
+}
+
+// heading is an entry of the table of contents.
+type heading struct {
+	level int
+	id    string
+	text  string
+}
+
+// HTML writes a markdown document as a standalone HTML page, with the CSS of
+// its style inline, its mermaid diagrams as SVG and a table of contents.
+// Diagrams stop rendering once ctx is done.
+func HTML(ctx context.Context, w io.Writer, markdown []byte, opts Options) error {
+	return writeHTML(ctx, w, markdown, opts, "")
+}
+
+// newMarkdown returns the goldmark documents are exported with, so they're
+// parsed alike whatever they're exported as, with the options of a format.
+func newMarkdown(options ...goldmark.Option) goldmark.Markdown {
+	return goldmark.New(append([]goldmark.Option{
+		goldmark.WithExtensions(extension.GFM, extension.Footnote),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	}, options...)...)
+}
+
+// writeHTML writes a document as HTML, with more CSS after its style's.
+func writeHTML(ctx context.Context, w io.Writer, markdown []byte, opts Options, extraCSS string) error {
+	css, err := styleCSS(opts.Style)
+	if err != nil {
+		return err
+	}
+	css += extraCSS
+	if opts.DiagramSVG == nil {
+		opts.DiagramSVG = mermaid.NewExporter(mermaid.FormatSVG).Export
+	}
+
+	title := opts.Title
+	if title == "" {
+		title = utils.FrontmatterTitle(markdown)
+	}
+	source := utils.RemoveFrontmatter(markdown)
+
+	rendererOptions := []renderer.Option{
+		renderer.WithNodeRenderers(util.Prioritized(&codeBlockRenderer{ctx, opts.DiagramSVG}, 100)),
+	}
+	if opts.Unsafe {
+		rendererOptions = append(rendererOptions, gmhtml.WithUnsafe())
+	}
+	md := newMarkdown(goldmark.WithRendererOptions(rendererOptions...))
+	doc := md.Parser().Parse(text.NewReader(source))
+	headings := collectHeadings(doc, source)
+	if title == "" && len(headings) > 0 {
+		title = headings[0].text
+	}
+
+	var body bytes.Buffer
+	if err := md.Renderer().Render(&body, source, doc); err != nil {
+		return fmt.Errorf("unable to render html: %w", err)
+	}
+
+	_, err = fmt.Fprintf(w, `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+<style>
+%s</style>
+</head>
+<body>
+%s<main>
+%s</main>
+%s</body>
+</html>
+`, html.EscapeString(title), css, tocHTML(headings), body.String(), scriptHTML(opts.Script))
+	return err
+}
+
+// scriptHTML returns the script element of a page's script, if it has one.
+func scriptHTML(script string) string {
+	if script == "" {
+		return ""
+	}
+	return "<script>\n" + script + "\n</script>\n"
+}
+
+// collectHeadings returns the headings of a document in order.
+func collectHeadings(doc ast.Node, source []byte) []heading {
+	var headings []heading
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		h, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		var id string
+		if v, ok := h.AttributeString("id"); ok {
+			if b, ok := v.([]byte); ok {
+				id = string(b)
+			}
+		}
+		headings = append(headings, heading{h.Level, id, nodeText(h, source)})
+		return ast.WalkSkipChildren, nil
+	})
+	return headings
+}
+
+// nodeText returns the plain text of a node's inlines.
+func nodeText(n ast.Node, source []byte) string {
+	var b strings.Builder
+	_ = ast.Walk(n, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Text:
+			b.Write(n.Segment.Value(source))
+			if n.SoftLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(n.Value)
+		case *ast.CodeSpan:
+			for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+				if t, ok := c.(*ast.Text); ok {
+					b.Write(t.Segment.Value(source))
+				}
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return b.String()
+}
+
+// tocHTML returns the table of contents as nested lists, or nothing for
+// documents of fewer than two headings.
+func tocHTML(headings []heading) string {
+	if len(headings) < 2 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("<nav class=\"toc\">")
+	var open []int // levels of the open lists
+	for _, h := range headings {
+		if len(open) == 0 || h.level > open[len(open)-1] {
+			b.WriteString("\n<ul>\n")
+			open = append(open, h.level)
+		} else {
+			b.WriteString("</li>\n")
+			for len(open) > 1 && h.level < open[len(open)-1] {
+				b.WriteString("</ul>\n</li>\n")
+				open = open[:len(open)-1]
+			}
+		}
+		fmt.Fprintf(&b, "<li><a href=\"#%s\">%s</a>", html.EscapeString(h.id), html.EscapeString(h.text))
+	}
+	for range open {
+		b.WriteString("</li>\n</ul>\n")
+	}
+	b.WriteString("</nav>\n")
+	return b.String()
+}
+
+// codeBlockRenderer renders fenced code blocks, drawing mermaid diagrams.
+type codeBlockRenderer struct {
+	ctx        context.Context
+	diagramSVG func(ctx context.Context, source string) ([]byte, error)
+}
+
+func (r *codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCodeBlock)
+}
+
+func (r *codeBlockRenderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.FencedCodeBlock)
+	lang := string(n.Language(source))
+	code := blockCode(n, source)
+
+	if lang == "mermaid" {
+		_, _ = w.WriteString(r.diagram(code))
+		return ast.WalkSkipChildren, nil
+	}
+	_, _ = w.WriteString("<pre><code")
+	if lang != "" {
+		_, _ = fmt.Fprintf(w, " class=\"language-%s\"", html.EscapeString(lang))
+	}
+	_, _ = w.WriteString(">" + html.EscapeString(code) + "</code></pre>\n")
+	return ast.WalkSkipChildren, nil
+}
+
+// diagram returns a mermaid diagram as SVG, or drawn as text if it can't be
+// rendered as SVG, or as its source if it can't be drawn at all.
+func (r *codeBlockRenderer) diagram(source string) string {
+	if svg, err := r.diagramSVG(r.ctx, source); err == nil {
+		return "<figure class=\"diagram\">\n" + string(svg) + "\n</figure>\n"
+	}
+	return "<pre class=\"diagram\">" + html.EscapeString(diagramText(r.ctx, source)) + "</pre>\n"
+}
@@ -0,0 +1,177 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testDocument = "---\ntitle: Front Matter\n---\n" +
+	"# Intro\n\nSome `code` & text.\n\n" +
+	"## Setup\n\n```go\nfmt.Println(\"<hi>\")\n```\n\n" +
+	"### Details\n\n" +
+	"## Diagram\n\n```mermaid\ngraph LR\n    A --> B\n```\n"
+
+func exportHTML(t *testing.T, markdown string, opts Options) string {
+	t.Helper()
+	var b bytes.Buffer
+	if err := HTML(context.Background(), &b, []byte(markdown), opts); err != nil {
+		t.Fatalf("HTML: %v", err)
+	}
+	return b.String()
+}
+
+func svgDiagram(_ context.Context, source string) ([]byte, error) {
+	return []byte("<svg><!-- " + strings.TrimSpace(source) + " --></svg>"), nil
+}
+
+func TestHTML(t *testing.T) {
+	out := exportHTML(t, testDocument, Options{Style: "dark", DiagramSVG: svgDiagram})
+
+	for _, want := range []string{
+		"<title>Front Matter</title>",
+		`<h1 id="intro">Intro</h1>`,
+		`<h3 id="details">Details</h3>`,
+		"<code>code</code> &amp; text",
+		`<pre><code class="language-go">fmt.Println(&#34;&lt;hi&gt;&#34;)`,
+		"<figure class=\"diagram\">\n<svg><!-- graph LR\n    A --> B --></svg>",
+		"background: #1e1e1e",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "title: Front Matter") {
+		t.Error("front matter is rendered")
+	}
+}
+
+func TestHTMLTableOfContents(t *testing.T) {
+	out := exportHTML(t, testDocument, Options{DiagramSVG: svgDiagram})
+
+	want := `<nav class="toc">
+<ul>
+<li><a href="#intro">Intro</a>
+<ul>
+<li><a href="#setup">Setup</a>
+<ul>
+<li><a href="#details">Details</a></li>
+</ul>
+</li>
+<li><a href="#diagram">Diagram</a></li>
+</ul>
+</li>
+</ul>
+</nav>
+`
+	if !strings.Contains(out, want) {
+		t.Errorf("table of contents isn't\n%s\nin:\n%s", want, out)
+	}
+
+	out = exportHTML(t, "# Only\n\ntext\n", Options{})
+	if strings.Contains(out, "<nav") {
+		t.Error("a document of one heading has a table of contents")
+	}
+}
+
+func TestHTMLDiagramFallback(t *testing.T) {
+	failing := func(context.Context, string) ([]byte, error) {
+		return nil, errors.New("no mmdc")
+	}
+	out := exportHTML(t, testDocument, Options{DiagramSVG: failing})
+
+	if strings.Contains(out, "<figure") {
+		t.Error("failed diagram is shown as a figure")
+	}
+	if !strings.Contains(out, `<pre class="diagram">`) || !strings.Contains(out, "►") {
+		t.Errorf("failed diagram isn't drawn as text:\n%s", out)
+	}
+}
+
+func TestHTMLContext(t *testing.T) {
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "export")
+	var got any
+	diagram := func(ctx context.Context, source string) ([]byte, error) {
+		got = ctx.Value(key{})
+		return svgDiagram(ctx, source)
+	}
+	var b bytes.Buffer
+	if err := HTML(ctx, &b, []byte(testDocument), Options{DiagramSVG: diagram}); err != nil {
+		t.Fatal(err)
+	}
+	if got != "export" {
+		t.Error("Expected diagrams rendered with the context of the export")
+	}
+}
+
+func TestHTMLTitle(t *testing.T) {
+	out := exportHTML(t, "# Fish & Chips\n\n## Second\n", Options{})
+	if !strings.Contains(out, "<title>Fish &amp; Chips</title>") {
+		t.Errorf("title isn't the first heading:\n%s", out)
+	}
+	out = exportHTML(t, "# First\n", Options{Title: "Given"})
+	if !strings.Contains(out, "<title>Given</title>") {
+		t.Errorf("title isn't the given one:\n%s", out)
+	}
+}
+
+func TestHTMLUnsafe(t *testing.T) {
+	doc := "# Page\n\n<script>alert(1)</script>\n\n<b>bold</b> and [a link](javascript:alert(2))\n"
+
+	// Raw HTML is left out unless the document is trusted
+	out := exportHTML(t, doc, Options{})
+	for _, unwanted := range []string{"alert(1)", "<b>", "javascript:"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("output has %q:\n%s", unwanted, out)
+		}
+	}
+
+	out = exportHTML(t, doc, Options{Unsafe: true})
+	for _, want := range []string{"<script>alert(1)</script>", "<b>bold</b>", `href="javascript:alert(2)"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestStyleCSS(t *testing.T) {
+	for style, bg := range map[string]string{
+		"light": "#ffffff",
+		"auto":  "#ffffff",
+		"dark":  "#1e1e1e",
+	} {
+		css, err := styleCSS(style)
+		if err != nil {
+			t.Fatalf("%s: %v", style, err)
+		}
+		if !strings.Contains(css, "background: "+bg) {
+			t.Errorf("%s: background isn't %s:\n%s", style, bg, css)
+		}
+	}
+
+	if _, err := styleCSS("no-such-style.json"); err == nil {
+		t.Error("missing style file isn't an error")
+	}
+}
+
+func TestCSSColor(t *testing.T) {
+	s := func(s string) *string { return &s }
+	for _, tt := range []struct {
+		color *string
+		want  string
+	}{
+		{nil, "def"},
+		{s(""), "def"},
+		{s("#abcdef"), "#abcdef"},
+		{s("15"), "#ffffff"},
+		{s("0"), "#000000"},
+		{s("red"), "def"},
+	} {
+		if got := cssColor(tt.color, "def"); got != tt.want {
+			t.Errorf("cssColor(%v) = %q, want %q", tt.color, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hholst80/glow/mermaid"
+	"github.com/hholst80/glow/utils"
+	"github.com/muesli/roff"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// ManOptions control how a document is exported as a man page.
+type ManOptions struct {
+	Options
+
+	// Section of the manual the page belongs to; 0 is 1, for commands
+	Section int
+
+	// Date the page was last changed; zero is today
+	Date time.Time
+}
+
+// Man writes a markdown document as a man page in roff. A first level one
+// heading becomes the title of the page, and the headings below it its
+// sections; mermaid diagrams are drawn as text, until ctx is done.
+func Man(ctx context.Context, w io.Writer, markdown []byte, opts ManOptions) error {
+	if opts.Section == 0 {
+		opts.Section = 1
+	}
+	if opts.Date.IsZero() {
+		opts.Date = time.Now()
+	}
+
+	title := opts.Title
+	if title == "" {
+		title = utils.FrontmatterTitle(markdown)
+	}
+	source := utils.RemoveFrontmatter(markdown)
+
+	doc := newMarkdown().Parser().Parse(text.NewReader(source))
+
+	m := &manWriter{ctx: ctx, source: source, lineStart: true, sectionLevel: 6}
+	first := true
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		h, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		if first && h.Level == 1 {
+			// The first heading names the page, unless it's given another
+			// name, and isn't a section of it either way
+			m.title = h
+			if title == "" {
+				title = nodeText(h, source)
+			}
+		} else {
+			m.sectionLevel = min(m.sectionLevel, h.Level)
+		}
+		first = false
+		return ast.WalkSkipChildren, nil
+	})
+	m.blocks(doc)
+	m.newline()
+
+	var header strings.Builder
+	if m.tables {
+		// Tells man to run the page through tbl
+		header.WriteString("'\\\" t\n")
+	}
+	fmt.Fprintf(&header, ".TH %s %d %q\n", quoteArg(strings.ToUpper(title)), opts.Section, opts.Date.Format("2006-01-02"))
+	_, err := io.WriteString(w, header.String()+m.b.String())
+	return err
+}
+
+// manWriter writes the blocks of a document as roff.
+type manWriter struct {
+	ctx    context.Context
+	b      strings.Builder
+	source []byte
+
+	lineStart    bool     // whether the next text starts a line
+	title        ast.Node // heading that names the page, if any
+	sectionLevel int      // level of the headings that start sections
+	tables       bool     // whether the page has tables
+}
+
+// macro writes a request, such as ".PP", on a line of its own.
+func (m *manWriter) macro(format string, args ...any) {
+	m.newline()
+	m.raw(fmt.Sprintf(format, args...) + "\n")
+}
+
+// raw writes roff as is.
+func (m *manWriter) raw(s string) {
+	if s == "" {
+		return
+	}
+	m.b.WriteString(s)
+	m.lineStart = strings.HasSuffix(s, "\n")
+}
+
+// text writes text, escaped so roff doesn't take it for requests.
+func (m *manWriter) text(s string) {
+	s = escapeRoff(strings.ReplaceAll(s, "\n", " "))
+	if m.lineStart && (strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'")) {
+		s = `\&` + s
+	}
+	m.raw(s)
+}
+
+// newline ends the current line, if it has anything on it.
+func (m *manWriter) newline() {
+	if !m.lineStart {
+		m.raw("\n")
+	}
+}
+
+func (m *manWriter) blocks(n ast.Node) {
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		m.block(c)
+	}
+}
+
+func (m *manWriter) block(n ast.Node) {
+	switch n := n.(type) {
+	case *ast.Heading:
+		if n == m.title {
+			return
+		}
+		switch n.Level - m.sectionLevel {
+		case 0:
+			m.macro(".SH %s", quoteArg(strings.ToUpper(nodeText(n, m.source))))
+		case 1:
+			m.macro(".SS %s", quoteArg(nodeText(n, m.source)))
+		default:
+			m.macro(".PP")
+			m.raw(roff.Bold)
+			m.inlines(n)
+			m.raw(roff.PreviousFont)
+		}
+	case *ast.Paragraph:
+		m.macro(".PP")
+		m.inlines(n)
+	case *ast.TextBlock:
+		m.inlines(n)
+	case *ast.FencedCodeBlock:
+		code := blockCode(n, m.source)
+		if string(n.Language(m.source)) == "mermaid" {
+			code = diagramText(m.ctx, code)
+		}
+		m.code(code)
+	case *ast.CodeBlock:
+		m.code(blockCode(n, m.source))
+	case *ast.List:
+		nested := n.Parent() != nil && n.Parent().Kind() == ast.KindListItem
+		if nested {
+			m.macro(".RS")
+		}
+		i := n.Start
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			if n.IsOrdered() {
+				m.item(c, fmt.Sprintf("%d.", i), 4)
+				i++
+			} else {
+				m.item(c, `\(bu`, 2)
+			}
+		}
+		if nested {
+			m.macro(".RE")
+		}
+	case *ast.Blockquote:
+		m.macro(".RS 4")
+		m.blocks(n)
+		m.macro(".RE")
+	case *east.Table:
+		m.table(n)
+	case *east.FootnoteList:
+		m.macro(".PP")
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			if f, ok := c.(*east.Footnote); ok {
+				m.item(f, fmt.Sprintf("[%d]", f.Index), 4)
+			}
+		}
+	case *ast.ThematicBreak, *ast.HTMLBlock:
+	default:
+		m.blocks(n)
+	}
+}
+
+// item writes an item of a list or a footnote, tagged and indented.
+func (m *manWriter) item(n ast.Node, tag string, indent int) {
+	m.macro(".IP %s %d", tag, indent)
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch c.Kind() {
+		case ast.KindParagraph, ast.KindTextBlock:
+			if c != n.FirstChild() {
+				m.macro(`.IP "" %d`, indent)
+			}
+			m.inlines(c)
+		default:
+			m.block(c)
+		}
+	}
+}
+
+// code writes a code block, indented and unfilled.
+func (m *manWriter) code(code string) {
+	m.macro(".PP")
+	m.macro(".RS 4")
+	m.macro(".nf")
+	for _, line := range strings.Split(strings.TrimSuffix(code, "\n"), "\n") {
+		m.raw(`\&` + escapeRoff(line) + "\n")
+	}
+	m.macro(".fi")
+	m.macro(".RE")
+}
+
+// table writes a table for tbl, with its header in bold.
+func (m *manWriter) table(n *east.Table) {
+	m.tables = true
+	header := make([]string, len(n.Alignments))
+	body := make([]string, len(n.Alignments))
+	for i, a := range n.Alignments {
+		switch a {
+		case east.AlignRight:
+			header[i], body[i] = "rB", "r"
+		case east.AlignCenter:
+			header[i], body[i] = "cB", "c"
+		default:
+			header[i], body[i] = "lB", "l"
+		}
+	}
+	m.macro(".TS")
+	m.raw("allbox tab(\t);\n")
+	m.raw(strings.Join(header, " ") + "\n")
+	m.raw(strings.Join(body, " ") + ".\n")
+	for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			if cell != row.FirstChild() {
+				m.raw("\t")
+			}
+			m.inlines(cell)
+		}
+		m.newline()
+	}
+	m.macro(".TE")
+}
+
+func (m *manWriter) inlines(n ast.Node) {
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch c := c.(type) {
+		case *ast.Text:
+			m.text(string(c.Segment.Value(m.source)))
+			if c.HardLineBreak() {
+				m.macro(".br")
+			} else if c.SoftLineBreak() {
+				m.newline()
+			}
+		case *ast.String:
+			m.text(string(c.Value))
+		case *ast.CodeSpan:
+			m.raw(roff.Bold)
+			m.text(nodeText(c, m.source))
+			m.raw(roff.PreviousFont)
+		case *ast.Emphasis:
+			font := roff.Italic
+			if c.Level > 1 {
+				font = roff.Bold
+			}
+			m.raw(font)
+			m.inlines(c)
+			m.raw(roff.PreviousFont)
+		case *ast.Link:
+			m.inlines(c)
+			if dest := string(c.Destination); dest != nodeText(c, m.source) {
+				m.text(" <" + dest + ">")
+			}
+		case *ast.AutoLink:
+			m.text(string(c.URL(m.source)))
+		case *ast.Image:
+			m.text(nodeText(c, m.source))
+		case *east.TaskCheckBox:
+			if c.IsChecked {
+				m.text("[x] ")
+			} else {
+				m.text("[ ] ")
+			}
+		case *east.FootnoteLink:
+			m.text(fmt.Sprintf("[%d]", c.Index))
+		case *ast.RawHTML, *east.FootnoteBacklink:
+		default:
+			m.inlines(c)
+		}
+	}
+}
+
+// blockCode returns the content of a code block.
+func blockCode(n ast.Node, source []byte) string {
+	var b strings.Builder
+	for i := range n.Lines().Len() {
+		line := n.Lines().At(i)
+		b.Write(line.Value(source))
+	}
+	return b.String()
+}
+
+// diagramText returns a mermaid diagram drawn as text, or its source if it
+// can't be drawn.
+func diagramText(ctx context.Context, source string) string {
+	text, err := mermaid.NewExporter(mermaid.FormatTXT).Export(ctx, source)
+	if err != nil {
+		return source
+	}
+	return string(text)
+}
+
+// escapeRoff escapes text for roff, keeping hyphens plain ASCII so options
+// can be copied from the page.
+func escapeRoff(s string) string {
+	return strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(s)
+}
+
+// quoteArg quotes an argument of a request.
+func quoteArg(s string) string {
+	return `"` + strings.ReplaceAll(escapeRoff(s), `"`, `\(dq`) + `"`
+}
@@ -0,0 +1,68 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func exportMan(t *testing.T, markdown string, opts ManOptions) string {
+	t.Helper()
+	if opts.Date.IsZero() {
+		opts.Date = time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	}
+	var b bytes.Buffer
+	if err := Man(context.Background(), &b, []byte(markdown), opts); err != nil {
+		t.Fatalf("Man: %v", err)
+	}
+	return b.String()
+}
+
+func TestMan(t *testing.T) {
+	out := exportMan(t, "# glow\n\n## Synopsis\n\nRun `glow --pager` on *one* **file**.\n.dot starts a line.\n\n"+
+		"### Flags\n\n- first\n- [x] done\n\n1. one\n2. two\n\n"+
+		"```sh\nglow -p \\\n  README.md\n```\n\n> quoted\n\nSee [the docs](https://example.com).\n", ManOptions{Section: 5})
+
+	for _, want := range []string{
+		".TH \"GLOW\" 5 \"2024-05-01\"\n",
+		".SH \"SYNOPSIS\"\n",
+		".SS \"Flags\"\n",
+		"Run \\fBglow \\-\\-pager\\fP on \\fIone\\fP \\fBfile\\fP.\n\\&.dot starts a line.\n",
+		".IP \\(bu 2\nfirst\n.IP \\(bu 2\n[x] done\n",
+		".IP 1. 4\none\n.IP 2. 4\ntwo\n",
+		".nf\n\\&glow \\-p \\e\n\\&  README.md\n.fi\n",
+		".RS 4\n.PP\nquoted\n.RE\n",
+		"See the docs <https://example.com>.\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "\n\n") {
+		t.Errorf("output has empty lines, which break some roff renderers:\n%s", out)
+	}
+}
+
+func TestManTitle(t *testing.T) {
+	out := exportMan(t, "---\ntitle: Front Matter\n---\n# Intro\n\nText\n\n## Usage\n", ManOptions{})
+	if !strings.HasPrefix(out, ".TH \"FRONT MATTER\" 1 ") {
+		t.Errorf("Expected the front matter title and section 1, got:\n%s", out)
+	}
+	if strings.Contains(out, "INTRO") || !strings.Contains(out, ".SH \"USAGE\"\n") {
+		t.Errorf("Expected the first heading left out as the title, and the ones below it as sections, got:\n%s", out)
+	}
+}
+
+func TestManTable(t *testing.T) {
+	out := exportMan(t, "| Key | Action |\n|:---|---:|\n| `q` | quit |\n", ManOptions{})
+
+	if !strings.HasPrefix(out, "'\\\" t\n") {
+		t.Errorf("Expected the page to ask for tbl, got:\n%s", out)
+	}
+	want := ".TS\nallbox tab(\t);\nlB rB\nl r.\nKey\tAction\n\\fBq\\fP\tquit\n.TE\n"
+	if !strings.Contains(out, want) {
+		t.Errorf("Expected table %q, got:\n%s", want, out)
+	}
+}
@@ -0,0 +1,187 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// PDFOptions control how a document is exported as PDF.
+type PDFOptions struct {
+	Options
+
+	// Page size: a3, a4, a5, letter, legal, or WIDTHxHEIGHT in CSS units
+	// such as 15cmx20cm; empty is a4
+	PageSize string
+
+	// Page margin on every side in CSS units, such as 2cm; empty is 2cm
+	Margin string
+
+	// Program that turns HTML into PDF; empty uses the first of
+	// pdfConverters that's installed
+	Command string
+}
+
+// pageSizes are the named page sizes, width by height.
+var pageSizes = map[string][2]string{
+	"a3":     {"297mm", "420mm"},
+	"a4":     {"210mm", "297mm"},
+	"a5":     {"148mm", "210mm"},
+	"letter": {"8.5in", "11in"},
+	"legal":  {"8.5in", "14in"},
+}
+
+// lengthRegex matches the CSS lengths a page can be measured in.
+var lengthRegex = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(mm|cm|in|pt)$`)
+
+// page is the size and margin of the pages of a PDF.
+type page struct {
+	width, height, margin string
+}
+
+// parsePage returns the page of a size and margin as PDFOptions take them.
+func parsePage(size, margin string) (page, error) {
+	if size == "" {
+		size = "a4"
+	}
+	if margin == "" {
+		margin = "2cm"
+	}
+
+	var p page
+	if wh, ok := pageSizes[strings.ToLower(size)]; ok {
+		p.width, p.height = wh[0], wh[1]
+	} else {
+		w, h, ok := strings.Cut(strings.ToLower(size), "x")
+		if !ok || !lengthRegex.MatchString(w) || !lengthRegex.MatchString(h) {
+			return page{}, fmt.Errorf("invalid page size %q: use a3, a4, a5, letter, legal or WIDTHxHEIGHT, such as 15cmx20cm", size)
+		}
+		p.width, p.height = w, h
+	}
+	if !lengthRegex.MatchString(margin) {
+		return page{}, fmt.Errorf("invalid margin %q: use a length in mm, cm, in or pt, such as 2cm", margin)
+	}
+	p.margin = margin
+	return p, nil
+}
+
+// css returns the print CSS of the page.
+func (p page) css() string {
+	return fmt.Sprintf(`@page { size: %s %s; margin: %s }
+body { max-width: none; margin: 0; padding: 0; -webkit-print-color-adjust: exact; print-color-adjust: exact }
+h1, h2, h3, h4, h5, h6 { break-after: avoid }
+pre, figure, table { break-inside: avoid }
+pre { white-space: pre-wrap }
+`, p.width, p.height, p.margin)
+}
+
+// pdfConverter is a program that prints an HTML file to PDF.
+type pdfConverter struct {
+	command string
+	args    func(in, out string, p page) []string
+}
+
+func chromeArgs(in, out string, _ page) []string {
+	// Chrome takes the page size and margin from the @page rule
+	return []string{"--headless", "--disable-gpu", "--no-pdf-header-footer", "--print-to-pdf=" + out, "file://" + in}
+}
+
+// pdfConverters are the programs PDFs are made with, in the order they're
+// looked for.
+var pdfConverters = []pdfConverter{
+	{"weasyprint", func(in, out string, _ page) []string {
+		return []string{in, out}
+	}},
+	{"wkhtmltopdf", func(in, out string, p page) []string {
+		return []string{
+			"--quiet", "--enable-local-file-access",
+			"--page-width", p.width, "--page-height", p.height,
+			"-T", p.margin, "-B", p.margin, "-L", p.margin, "-R", p.margin,
+			in, out,
+		}
+	}},
+	{"chromium", chromeArgs},
+	{"chromium-browser", chromeArgs},
+	{"google-chrome", chromeArgs},
+}
+
+// findPDFConverter returns the converter of a command, by its name, or the
+// first installed one if command is empty.
+func findPDFConverter(command string) (pdfConverter, string, error) {
+	if command != "" {
+		name := strings.TrimSuffix(filepath.Base(command), filepath.Ext(command))
+		for _, c := range pdfConverters {
+			if c.command == name {
+				path, err := exec.LookPath(command)
+				if err != nil {
+					return pdfConverter{}, "", fmt.Errorf("unable to find %s: %w", command, err)
+				}
+				return c, path, nil
+			}
+		}
+		return pdfConverter{}, "", fmt.Errorf("unknown PDF converter %q: use %s", command, converterNames())
+	}
+
+	for _, c := range pdfConverters {
+		if path, err := exec.LookPath(c.command); err == nil {
+			return c, path, nil
+		}
+	}
+	return pdfConverter{}, "", fmt.Errorf("exporting PDF needs one of %s", converterNames())
+}
+
+func converterNames() string {
+	names := make([]string, len(pdfConverters))
+	for i, c := range pdfConverters {
+		names[i] = c.command
+	}
+	return strings.Join(names, ", ")
+}
+
+// PDF writes a markdown document as PDF, laid out like its HTML export on
+// pages of the given size and margin.
+func PDF(ctx context.Context, w io.Writer, markdown []byte, opts PDFOptions) error {
+	p, err := parsePage(opts.PageSize, opts.Margin)
+	if err != nil {
+		return err
+	}
+	converter, command, err := findPDFConverter(opts.Command)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "glow-export")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	var doc bytes.Buffer
+	if err := writeHTML(ctx, &doc, markdown, opts.Options, p.css()); err != nil {
+		return err
+	}
+	in := filepath.Join(dir, "document.html")
+	out := filepath.Join(dir, "document.pdf")
+	if err := os.WriteFile(in, doc.Bytes(), 0o600); err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command, converter.args(in, out, p)...) //nolint:gosec
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w: %s", converter.command, err, strings.TrimSpace(stderr.String()))
+	}
+	pdf, err := os.ReadFile(out)
+	if err != nil {
+		return fmt.Errorf("%s wrote no PDF: %w", converter.command, err)
+	}
+	_, err = w.Write(pdf)
+	return err
+}
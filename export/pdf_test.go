@@ -0,0 +1,77 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParsePage(t *testing.T) {
+	for _, tt := range []struct {
+		size, margin string
+		want         page
+	}{
+		{"", "", page{"210mm", "297mm", "2cm"}},
+		{"Letter", "1in", page{"8.5in", "11in", "1in"}},
+		{"15cmx20.5cm", "12.5mm", page{"15cm", "20.5cm", "12.5mm"}},
+	} {
+		got, err := parsePage(tt.size, tt.margin)
+		if err != nil || got != tt.want {
+			t.Errorf("parsePage(%q, %q) = %v, %v, want %v", tt.size, tt.margin, got, err, tt.want)
+		}
+	}
+
+	for _, tt := range [][2]string{{"b4", "2cm"}, {"15cm", "2cm"}, {"15x20", "2cm"}, {"a4", "2"}, {"a4", "-1cm"}} {
+		if _, err := parsePage(tt[0], tt[1]); err == nil {
+			t.Errorf("parsePage(%q, %q) isn't an error", tt[0], tt[1])
+		}
+	}
+}
+
+func TestPDF(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the PDF converter")
+	}
+
+	// A fake wkhtmltopdf that writes its arguments and the page it was given
+	dir := t.TempDir()
+	script := "#!/bin/sh\nfor a; do in=$out; out=$a; done\n{ echo \"$@\"; cat \"$in\"; } > \"$out\"\n"
+	command := filepath.Join(dir, "wkhtmltopdf")
+	if err := os.WriteFile(command, []byte(script), 0o700); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	opts := PDFOptions{
+		Options:  Options{DiagramSVG: svgDiagram},
+		PageSize: "letter",
+		Margin:   "1in",
+		Command:  command,
+	}
+	if err := PDF(context.Background(), &b, []byte(testDocument), opts); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"--page-width 8.5in --page-height 11in -T 1in -B 1in -L 1in -R 1in",
+		"@page { size: 8.5in 11in; margin: 1in }",
+		`<h1 id="intro">Intro</h1>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+
+	opts.Command = filepath.Join(dir, "missing", "weasyprint")
+	if err := PDF(context.Background(), &b, []byte(testDocument), opts); err == nil {
+		t.Error("missing converter isn't an error")
+	}
+	opts.Command = "cat"
+	if err := PDF(context.Background(), &b, []byte(testDocument), opts); err == nil {
+		t.Error("unknown converter isn't an error")
+	}
+}
@@ -0,0 +1,133 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/glamour/ansi"
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/hholst80/glow/utils"
+	"github.com/muesli/termenv"
+)
+
+// loadStyle returns a glamour style by name, or from a JSON file. Pages have
+// no terminal to take the background from, so auto is light.
+func loadStyle(style string) (ansi.StyleConfig, error) {
+	if style == "" || style == styles.AutoStyle {
+		style = styles.LightStyle
+	}
+	if config, ok := styles.DefaultStyles[style]; ok {
+		return *config, nil
+	}
+
+	data, err := os.ReadFile(utils.ExpandPath(style))
+	if err != nil {
+		return ansi.StyleConfig{}, fmt.Errorf("unable to read style: %w", err)
+	}
+	var config ansi.StyleConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return ansi.StyleConfig{}, fmt.Errorf("unable to parse style %s: %w", style, err)
+	}
+	return config, nil
+}
+
+// styleCSS returns the CSS of a page in a glamour style: its colors, with a
+// background that suits the text where the style leaves it to the terminal.
+func styleCSS(style string) (string, error) {
+	config, err := loadStyle(style)
+	if err != nil {
+		return "", err
+	}
+
+	fg := cssColor(config.Document.Color, "#1a1a1a")
+	bg := cssColor(config.Document.BackgroundColor, "")
+	if bg == "" {
+		bg = "#ffffff"
+		if isLight(fg) {
+			bg = "#1e1e1e"
+		}
+	}
+	heading := cssColor(config.Heading.Color, fg)
+	codeBg := cssColor(config.Code.BackgroundColor, "")
+	if codeBg == "" {
+		codeBg = "rgba(127, 127, 127, 0.15)"
+	}
+	blockFg, blockBg := fg, codeBg
+	if c := config.CodeBlock.Chroma; c != nil {
+		cfg, cbg := cssColor(c.Text.Color, fg), cssColor(c.Background.BackgroundColor, "")
+		// Some styles pair their code text with the terminal's background
+		// rather than their own
+		if cbg != "" && isLight(cfg) != isLight(cbg) {
+			blockFg, blockBg = cfg, cbg
+		}
+	}
+
+	var b strings.Builder
+	rule := func(selector string, props ...string) {
+		fmt.Fprintf(&b, "%s { %s }\n", selector, strings.Join(props, "; "))
+	}
+	rule("body",
+		"margin: 0 auto", "padding: 2em", "max-width: 50em",
+		"font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Helvetica, Arial, sans-serif",
+		"line-height: 1.6", "color: "+fg, "background: "+bg)
+	rule("h1, h2, h3, h4, h5, h6", "color: "+heading, "line-height: 1.25")
+	for i, h := range []ansi.StyleBlock{config.H1, config.H2, config.H3, config.H4, config.H5, config.H6} {
+		var props []string
+		if c := cssColor(h.Color, ""); c != "" {
+			props = append(props, "color: "+c)
+		}
+		if c := cssColor(h.BackgroundColor, ""); c != "" {
+			props = append(props, "background: "+c, "display: inline-block", "padding: 0 0.3em")
+		}
+		if len(props) > 0 {
+			rule("h"+strconv.Itoa(i+1), props...)
+		}
+	}
+	rule("a", "color: "+cssColor(config.Link.Color, heading))
+	rule("code", "color: "+cssColor(config.Code.Color, fg), "background: "+codeBg,
+		"padding: 0.1em 0.3em", "border-radius: 3px",
+		"font-family: ui-monospace, SFMono-Regular, Menlo, Consolas, monospace")
+	rule("pre", "color: "+blockFg, "background: "+blockBg, "padding: 1em", "overflow-x: auto", "border-radius: 6px")
+	rule("pre code", "color: inherit", "background: none", "padding: 0")
+	rule("blockquote", "margin: 0", "padding: 0 1em", "color: "+cssColor(config.BlockQuote.Color, fg),
+		"border-left: 0.25em solid "+cssColor(config.HorizontalRule.Color, "#888888"))
+	rule("hr", "border: 0", "border-top: 1px solid "+cssColor(config.HorizontalRule.Color, "#888888"))
+	rule("table", "border-collapse: collapse")
+	rule("th, td", "border: 1px solid "+cssColor(config.HorizontalRule.Color, "#888888"), "padding: 0.3em 0.8em")
+	rule("img, .diagram svg", "max-width: 100%")
+	rule(".diagram", "margin: 1em 0", "text-align: center")
+	rule("pre.diagram", "text-align: left", "line-height: 1.2")
+	rule(".toc", "margin-bottom: 2em", "padding: 0.5em 1em", "border-left: 0.25em solid "+heading)
+	rule(".toc ul", "list-style: none", "padding-left: 1.2em", "margin: 0")
+	rule(".toc > ul", "padding-left: 0")
+	return b.String(), nil
+}
+
+// cssColor returns a glamour color, a hex color or an ANSI color number, as
+// a CSS color, or def if it isn't set.
+func cssColor(c *string, def string) string {
+	if c == nil || *c == "" {
+		return def
+	}
+	if strings.HasPrefix(*c, "#") {
+		return *c
+	}
+	n, err := strconv.Atoi(*c)
+	if err != nil || n < 0 || n > 255 {
+		return def
+	}
+	return termenv.ConvertToRGB(termenv.ANSI256Color(n)).Hex()
+}
+
+// isLight reports whether a hex color is light, like text meant for a dark
+// background.
+func isLight(hex string) bool {
+	var r, g, b uint8
+	if _, err := fmt.Sscanf(hex, "#%02x%02x%02x", &r, &g, &b); err != nil {
+		return false
+	}
+	return 0.299*float64(r)+0.587*float64(g)+0.114*float64(b) > 128
+}
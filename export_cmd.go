@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/hholst80/glow/export"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	docExportFormat string
+	docExportStyle  string
+	docExportOut    string
+	docPageSize     string
+	docPageMargin   string
+	docManSection   int
+
+	exportCmd = &cobra.Command{
+		Use:     "export SOURCE",
+		Short:   "Write a document to a standalone HTML, PDF or man page file",
+		Long:    paragraph(fmt.Sprintf("\n%s a document to a standalone HTML or PDF file, with the colors of a glamour style, its mermaid diagrams as SVG and a table of contents. SVG diagrams need the mermaid CLI (mmdc); without it they're drawn as text. PDF needs weasyprint, wkhtmltopdf or Chromium. With --format man the document is written as a roff man page, its first heading naming the page.", keyword("Write"))),
+		Example: paragraph("glow export README.md --format html\nglow export README.md --style dark --out readme.html\nglow export README.md --format pdf --page-size letter --margin 1in\nglow export docs/glow.md --format man --section 1"),
+		Args:    cobra.ExactArgs(1),
+		RunE:    exportDocument,
+	}
+)
+
+func exportDocument(cmd *cobra.Command, args []string) error {
+	if docExportFormat != "html" && docExportFormat != "pdf" && docExportFormat != "man" {
+		return fmt.Errorf("unsupported export format %q: use html, pdf or man", docExportFormat)
+	}
+	if docExportFormat == "man" && (docManSection < 1 || docManSection > 9) {
+		return fmt.Errorf("invalid man page section %d: use 1 to 9", docManSection)
+	}
+	style := docExportStyle
+	if !cmd.Flags().Changed("style") {
+		style = viper.GetString("style")
+	}
+
+	src, err := sourceFromArg(args[0])
+	if err != nil {
+		return err
+	}
+	defer src.reader.Close() //nolint:errcheck
+	b, err := io.ReadAll(src.reader)
+	if err != nil {
+		return fmt.Errorf("unable to read from reader: %w", err)
+	}
+
+	var out bytes.Buffer
+	// Only the raw HTML of local files is trusted
+	opts := export.Options{Style: style, Unsafe: src.URL != "" && !isURL(src.URL)}
+	ext := docExportFormat
+	switch docExportFormat {
+	case "pdf":
+		err = export.PDF(cmd.Context(), &out, b, export.PDFOptions{Options: opts, PageSize: docPageSize, Margin: docPageMargin})
+	case "man":
+		// Man pages are named after their section, such as glow.1
+		ext = strconv.Itoa(docManSection)
+		err = export.Man(cmd.Context(), &out, b, export.ManOptions{Options: opts, Section: docManSection})
+	default:
+		err = export.HTML(cmd.Context(), &out, b, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	path := docExportOut
+	if path == "" {
+		path = exportName(args[0]) + "." + ext
+	}
+	if path == "-" {
+		_, err := cmd.OutOrStdout().Write(out.Bytes())
+		return err
+	}
+	if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil { //nolint:gosec
+		return fmt.Errorf("unable to write %s: %w", path, err)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), path)
+	return nil
+}
+
+func init() {
+	exportCmd.Flags().StringVar(&docExportFormat, "format", "html", "file format: html, pdf or man")
+	exportCmd.Flags().StringVarP(&docExportStyle, "style", "s", "", "style name or JSON path (defaults to the configured style)")
+	exportCmd.Flags().StringVar(&docPageSize, "page-size", "a4", "PDF page size: a3, a4, a5, letter, legal or WIDTHxHEIGHT, such as 15cmx20cm")
+	exportCmd.Flags().StringVar(&docPageMargin, "margin", "2cm", "PDF page margin, such as 2cm or 1in")
+	exportCmd.Flags().IntVar(&docManSection, "section", 1, "man page section, such as 1 for commands or 5 for file formats")
+	exportCmd.Flags().StringVarP(&docExportOut, "out", "o", "", "file to write, or - for stdout (default SOURCE name with the format's extension)")
+}
@@ -0,0 +1,208 @@
+// Package footnotes renders footnotes, which glamour leaves as they're
+// written: references such as "[^1]" become superscript numbers, and the
+// definitions are collected into a section at the end of the document.
+package footnotes
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hholst80/glow/utils"
+)
+
+// Footnote is a footnote of a document.
+type Footnote struct {
+	Label  string // label it's referenced by, such as "1" for "[^1]"
+	Number int    // number it's shown as, counted from 1 in order of reference
+	Text   string // markdown of its definition
+}
+
+var (
+	// definitionRegex matches the line that starts a definition.
+	definitionRegex = regexp.MustCompile(`^ {0,3}\[\^([^\]\s]+)\]:[ \t]?(.*)$`)
+
+	// referenceRegex matches a reference.
+	referenceRegex = regexp.MustCompile(`\[\^([^\]\s]+)\]`)
+)
+
+// superscripts are the superscript digits.
+var superscripts = []rune("⁰¹²³⁴⁵⁶⁷⁸⁹")
+
+// Superscript returns n in superscript digits.
+func Superscript(n int) string {
+	digits := []rune(strconv.Itoa(n))
+	for i, d := range digits {
+		digits[i] = superscripts[d-'0']
+	}
+	return string(digits)
+}
+
+// Process replaces the references to footnotes in markdown with superscript
+// numbers, and moves the definitions of the footnotes to a list at the end.
+// References without a definition, and definitions without a reference, are
+// left as they are and dropped respectively, like GitHub does.
+func Process(markdown string) string {
+	return process(markdown, false)
+}
+
+// ProcessInPlace processes markdown like Process, but leaves the lines of the
+// definitions blank rather than removing them, so the rest of the document
+// stays on the lines it was on.
+func ProcessInPlace(markdown string) string {
+	return process(markdown, true)
+}
+
+// process processes markdown, keeping the lines of definitions blank if
+// inPlace is set.
+func process(markdown string, inPlace bool) string {
+	body, footnotes := split(markdown, inPlace)
+	if len(footnotes) == 0 {
+		return body
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(body, "\n"))
+	b.WriteString("\n\n---\n\n**Footnotes**\n\n")
+	for _, f := range footnotes {
+		b.WriteString(strconv.Itoa(f.Number) + ".")
+		for i, line := range strings.Split(f.Text, "\n") {
+			switch {
+			case i == 0:
+				b.WriteString(" " + line)
+			case line != "":
+				// Indented to be part of the item
+				b.WriteString("\n    " + line)
+			default:
+				b.WriteString("\n")
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Find returns the footnotes referenced in markdown, in order of their
+// numbers.
+func Find(markdown string) []Footnote {
+	_, footnotes := split(markdown, false)
+	return footnotes
+}
+
+// split returns markdown without the definitions of footnotes, or with their
+// lines blank if inPlace is set, and with superscript numbers for their
+// references, and the footnotes referenced.
+func split(markdown string, inPlace bool) (string, []Footnote) {
+	lines := strings.Split(markdown, "\n")
+	definitions := map[string][]string{}
+	kept := make([]string, 0, len(lines))
+	fence := ""
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		inCode := fence != ""
+		if fence = utils.NextFence(fence, line); inCode || fence != "" {
+			kept = append(kept, line)
+			continue
+		}
+		m := definitionRegex.FindStringSubmatch(line)
+		if m == nil {
+			kept = append(kept, line)
+			continue
+		}
+
+		// A definition goes on over indented lines, blank lines between them,
+		// and lines that carry on its first paragraph
+		text := []string{m[2]}
+		start := i
+		paragraph := true
+	definition:
+		for ; i+1 < len(lines); i++ {
+			next := lines[i+1]
+			switch {
+			case indented(next):
+				text = append(text, strings.TrimPrefix(strings.TrimPrefix(next, "\t"), "    "))
+			case strings.TrimSpace(next) == "":
+				if i+2 >= len(lines) || !indented(lines[i+2]) {
+					break definition
+				}
+				text = append(text, "")
+				paragraph = false
+			case paragraph && !definitionRegex.MatchString(next) && !startsBlock(next):
+				text = append(text, strings.TrimSpace(next))
+			default:
+				break definition
+			}
+		}
+		if _, ok := definitions[m[1]]; !ok {
+			definitions[m[1]] = text
+		}
+		if inPlace {
+			kept = append(kept, make([]string, i-start+1)...)
+		}
+	}
+	if len(definitions) == 0 {
+		return markdown, nil
+	}
+
+	var footnotes []Footnote
+	numbers := map[string]int{}
+	number := func(label string) (int, bool) {
+		if _, ok := definitions[label]; !ok {
+			return 0, false
+		}
+		if _, ok := numbers[label]; !ok {
+			footnotes = append(footnotes, Footnote{Label: label, Number: len(footnotes) + 1})
+			numbers[label] = len(footnotes)
+		}
+		return numbers[label], true
+	}
+	fence = ""
+	for i, line := range kept {
+		inCode := fence != ""
+		if fence = utils.NextFence(fence, line); inCode || fence != "" {
+			continue
+		}
+		kept[i] = replaceReferences(line, number)
+	}
+
+	// Footnotes may reference each other, adding to the list as it goes
+	for i := 0; i < len(footnotes); i++ {
+		text := definitions[footnotes[i].Label]
+		for j, line := range text {
+			text[j] = replaceReferences(line, number)
+		}
+		footnotes[i].Text = strings.Join(text, "\n")
+	}
+	return strings.Join(kept, "\n"), footnotes
+}
+
+// replaceReferences replaces the references in line, outside of code spans,
+// with the superscript numbers number returns for their labels.
+func replaceReferences(line string, number func(label string) (int, bool)) string {
+	return utils.OutsideCodeSpans(line, func(s string) string {
+		return referenceRegex.ReplaceAllStringFunc(s, func(ref string) string {
+			if n, ok := number(referenceRegex.FindStringSubmatch(ref)[1]); ok {
+				return Superscript(n)
+			}
+			return ref
+		})
+	})
+}
+
+// indented reports whether line is indented enough to be part of a
+// definition.
+func indented(line string) bool {
+	return strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")
+}
+
+// startsBlock reports whether line starts a block, ending the paragraph
+// before it.
+func startsBlock(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	for _, prefix := range []string{"#", ">", "- ", "* ", "+ ", "```", "~~~", "|"} {
+		if strings.HasPrefix(trimmed, prefix) {
+			return true
+		}
+	}
+	return false
+}
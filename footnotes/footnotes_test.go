@@ -0,0 +1,72 @@
+package footnotes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "no footnotes",
+			in:   "Text [^1] and `code`.\n",
+			want: "Text [^1] and `code`.\n",
+		},
+		{
+			name: "numbered in order of reference",
+			in:   "A[^b] and B[^a], A again[^b].\n\n[^a]: First.\n[^b]: Second.\n",
+			want: "A¹ and B², A again¹.\n\n---\n\n**Footnotes**\n\n1. Second.\n2. First.\n",
+		},
+		{
+			name: "continuation lines",
+			in:   "Note[^n].\n\n[^n]: A long\ncontinued\n\n    paragraph.\n\n## After\n",
+			want: "Note¹.\n\n\n## After\n\n---\n\n**Footnotes**\n\n1. A long\n    continued\n\n    paragraph.\n",
+		},
+		{
+			name: "code and undefined references",
+			in:   "`[^n]` [^m] [^n]\n\n```\n[^n]\n[^n]: code\n```\n\n[^n]: Note.\n[^u]: Unused.\n",
+			want: "`[^n]` [^m] ¹\n\n```\n[^n]\n[^n]: code\n```\n\n---\n\n**Footnotes**\n\n1. Note.\n",
+		},
+		{
+			name: "definitions without references",
+			in:   "Text.\n\n[^n]: Unused.\n",
+			want: "Text.\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Process(tt.in); got != tt.want {
+				t.Errorf("Process() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessInPlace(t *testing.T) {
+	in := "Note[^n].\n\n[^n]: A long\ncontinued\n\n    paragraph.\n\n## After\n"
+	want := "Note¹.\n\n\n\n\n\n\n## After\n\n---\n\n**Footnotes**\n\n1. A long\n    continued\n\n    paragraph.\n"
+	if got := ProcessInPlace(in); got != want {
+		t.Errorf("ProcessInPlace() = %q, want %q", got, want)
+	}
+}
+
+func TestFind(t *testing.T) {
+	got := Find("One[^x].\n\n[^x]: Refers to[^y].\n[^y]: Last.\n")
+	want := []Footnote{
+		{Label: "x", Number: 1, Text: "Refers to²."},
+		{Label: "y", Number: 2, Text: "Last."},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Find() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSuperscript(t *testing.T) {
+	if got := Superscript(120); got != "¹²⁰" {
+		t.Errorf("Superscript(120) = %q", got)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hholst80/glow/utils"
+	"github.com/spf13/cobra"
+)
+
+const protoGist = "gist://"
+
+// gistAPI is where gists are fetched from.
+var gistAPI = "https://api.github.com/gists/"
+
+// gistIDPattern matches the IDs of gists, which are hexadecimal.
+var gistIDPattern = regexp.MustCompile(`^[0-9a-f]{20,32}$`)
+
+// gistFile is a file of a gist, as the GitHub API describes it.
+type gistFile struct {
+	Filename  string `json:"filename"`
+	RawURL    string `json:"raw_url"`
+	Content   string `json:"content"`
+	Truncated bool   `json:"truncated"`
+}
+
+// parseGist returns the ID of the gist an argument names: a gist URL, with
+// or without the protocol, gist://ID or a bare ID that isn't a local file.
+func parseGist(arg string) (string, bool) {
+	if id, ok := strings.CutPrefix(arg, protoGist); ok {
+		return id, gistIDPattern.MatchString(id)
+	}
+
+	if !strings.Contains(arg, "://") {
+		if gistIDPattern.MatchString(arg) {
+			_, err := os.Stat(arg)
+			return arg, errors.Is(err, fs.ErrNotExist)
+		}
+		arg = protoHTTPS + arg
+	}
+	u, err := url.Parse(arg)
+	if err != nil || u.Hostname() != "gist.github.com" {
+		return "", false
+	}
+	id := strings.TrimSuffix(path.Base(u.Path), ".git")
+	return id, gistIDPattern.MatchString(id)
+}
+
+// fetchGist gets the markdown files of a gist, sorted by name as GitHub
+// shows them.
+func fetchGist(id string) ([]gistFile, error) {
+	res, err := githubGet(gistAPI + id)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get url: %w", err)
+	}
+	defer res.Body.Close() //nolint:errcheck
+	if res.StatusCode == http.StatusNotFound {
+		return nil, withExitCode(exitNotFound, fmt.Errorf("can't find gist %s: HTTP status %d", id, res.StatusCode))
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("can't find gist %s: HTTP status %d", id, res.StatusCode)
+	}
+
+	var gist struct {
+		Files map[string]gistFile `json:"files"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&gist); err != nil {
+		return nil, fmt.Errorf("unable to parse json: %w", err)
+	}
+
+	var files []gistFile
+	for _, f := range gist.Files {
+		if !utils.IsMarkdownFile(f.Filename) {
+			continue
+		}
+		// Large files are cut short and have to be fetched on their own
+		if f.Truncated {
+			content, err := fetchGistFile(f.RawURL)
+			if err != nil {
+				return nil, err
+			}
+			f.Content = content
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("gist %s has no markdown files", id)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Filename < files[j].Filename })
+	return files, nil
+}
+
+func fetchGistFile(rawURL string) (string, error) {
+	res, err := githubGet(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("unable to get url: %w", err)
+	}
+	defer res.Body.Close() //nolint:errcheck
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP status %d", res.StatusCode)
+	}
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", fmt.Errorf("unable to read http response body: %w", err)
+	}
+	return string(b), nil
+}
+
+// gistSource returns the markdown of a gist as a source, its files joined
+// into one document if it has several.
+func gistSource(files []gistFile) *source {
+	if len(files) == 1 {
+		return &source{reader: io.NopCloser(strings.NewReader(files[0].Content)), URL: files[0].RawURL}
+	}
+	joined := make([]utils.File, 0, len(files))
+	for _, f := range files {
+		joined = append(joined, utils.File{Name: f.Filename, Content: []byte(f.Content)})
+	}
+	content, starts := utils.JoinFiles(joined)
+	return &source{reader: io.NopCloser(strings.NewReader(content)), files: starts}
+}
+
+// browseGist lists the files of a gist in the TUI, or renders the only one.
+func browseGist(cmd *cobra.Command, id string, w io.Writer) error {
+	files, err := fetchGist(id)
+	if err != nil {
+		return err
+	}
+	if len(files) == 1 {
+		return executeCLI(cmd, gistSource(files), w)
+	}
+
+	dir, err := os.MkdirTemp("", "glow-gist-")
+	if err != nil {
+		return fmt.Errorf("unable to create directory: %w", err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+	for _, f := range files {
+		// names can't leave the directory
+		name := filepath.Join(dir, filepath.Base(f.Filename))
+		if err := os.WriteFile(name, []byte(f.Content), 0o600); err != nil {
+			return fmt.Errorf("unable to write file: %w", err)
+		}
+	}
+	return runTUI(dir, "", nil, nil)
+}
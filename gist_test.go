@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseGist(t *testing.T) {
+	t.Chdir(t.TempDir())
+	const id = "aa5a315d61ae9438b18d"
+	for arg, want := range map[string]bool{
+		"gist://" + id:                           true,
+		id:                                       true,
+		"gist.github.com/" + id:                  true,
+		"https://gist.github.com/caarlos0/" + id: true,
+		"https://gist.github.com/caarlos0":       false,
+		"https://github.com/caarlos0/" + id:      false,
+		"README.md":                              false,
+	} {
+		if got, ok := parseGist(arg); ok != want || (ok && got != id) {
+			t.Errorf("parseGist(%q) = %q, %v, want %v", arg, got, ok, want)
+		}
+	}
+}
+
+func TestFetchGist(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gists/abc":
+			fmt.Fprintf(w, `{"files": {
+				"notes.md": {"filename": "notes.md", "content": "# Notes"},
+				"main.go": {"filename": "main.go", "content": "package main"},
+				"big.md": {"filename": "big.md", "content": "# B", "truncated": true, "raw_url": "http://%s/raw/big.md"}
+			}}`, r.Host)
+		case "/raw/big.md":
+			fmt.Fprint(w, "# Big")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	api := gistAPI
+	t.Cleanup(func() { gistAPI = api })
+	gistAPI = srv.URL + "/gists/"
+
+	files, err := fetchGist("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Filename != "big.md" || files[0].Content != "# Big" || files[1].Filename != "notes.md" {
+		t.Errorf("fetchGist() = %+v", files)
+	}
+
+	if _, err := fetchGist("missing"); err == nil {
+		t.Error("expected an error for a missing gist")
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hholst80/glow/utils"
+)
+
+// giteaHosts are the public Gitea and Forgejo instances. Self-hosted ones
+// are added with the giteaHosts setting.
+var giteaHosts = []string{"codeberg.org", "gitea.com"}
+
+// giteaGet gets a Gitea URL, authenticated if GITEA_TOKEN is set, which lets
+// Glow read private repositories.
+func giteaGet(u string) (*http.Response, error) {
+	return getWithToken(u, "Authorization", "token %s", "GITEA_TOKEN")
+}
+
+// parseGiteaPath returns the repository of a Gitea URL path, the ref given
+// as owner/repo@ref or owner/repo/src/branch/ref, and whether the path shows
+// a markdown file rather than the tree at the ref.
+func parseGiteaPath(path string) (owner, repo, ref string, file bool, ok bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", false, false
+	}
+	owner, repo = parts[0], strings.TrimSuffix(parts[1], ".git")
+	repo, ref, _ = strings.Cut(repo, "@")
+	if len(parts) > 4 && parts[2] == "src" {
+		ref = strings.Join(parts[4:], "/")
+		file = utils.IsMarkdownFile(parts[len(parts)-1]) && strings.Contains(parts[len(parts)-1], ".")
+	}
+	return owner, repo, ref, file, repo != ""
+}
+
+// findGiteaREADME tries to find the README of a repository on a Gitea or
+// Forgejo instance using its API.
+func findGiteaREADME(u *url.URL) (*source, error) {
+	owner, repo, ref, file, ok := parseGiteaPath(u.Path)
+	if !ok {
+		return nil, fmt.Errorf("invalid url: %s", u.String())
+	}
+
+	// a file rather than the repository's README
+	if file {
+		rawPath := strings.Replace(strings.Trim(u.Path, "/"), "/src/", "/raw/", 1)
+		return fetchRawFile(giteaGet, fmt.Sprintf("%s://%s/%s", u.Scheme, u.Host, rawPath))
+	}
+	if ref == "" {
+		ref = branch
+	}
+
+	apiURL := fmt.Sprintf("%s://%s/api/v1/repos/%s/%s/contents", u.Scheme, u.Host, owner, repo)
+	if ref != "" {
+		apiURL += "?ref=" + url.QueryEscape(ref)
+	}
+	res, err := giteaGet(apiURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get url: %w", err)
+	}
+	defer res.Body.Close() //nolint:errcheck
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New("can't find README in Gitea repository")
+	}
+
+	var entries []struct {
+		Name        string `json:"name"`
+		Type        string `json:"type"`
+		DownloadURL string `json:"download_url"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("unable to parse json: %w", err)
+	}
+
+	// the README names are tried in order of preference
+	for _, name := range readmeNames {
+		for _, e := range entries {
+			if e.Type == "file" && e.Name == name {
+				return fetchRawFile(giteaGet, e.DownloadURL)
+			}
+		}
+	}
+	return nil, errors.New("can't find README in Gitea repository")
+}
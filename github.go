@@ -7,25 +7,82 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
 )
 
+// parseGitHubPath returns the repository of a GitHub URL path, along with
+// the branch, tag or commit given as owner/repo@ref or owner/repo/tree/ref.
+// The ref is empty for the default branch.
+func parseGitHubPath(path string) (owner, repo, ref string, ok bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", false
+	}
+	owner, repo = parts[0], strings.TrimSuffix(parts[1], ".git")
+	repo, ref, _ = strings.Cut(repo, "@")
+	if len(parts) > 3 && parts[2] == "tree" {
+		ref = strings.Join(parts[3:], "/")
+	}
+	return owner, repo, ref, repo != ""
+}
+
+// githubTokenVars are the environment variables holding the token to
+// authenticate to GitHub with, which lets Glow read private repositories.
+var githubTokenVars = []string{"GITHUB_TOKEN", "GH_TOKEN"}
+
+// githubToken returns the token to authenticate to GitHub with.
+func githubToken() string {
+	for _, key := range githubTokenVars {
+		if token := os.Getenv(key); token != "" {
+			return token
+		}
+	}
+	return ""
+}
+
+// githubGet gets a GitHub URL, authenticated if there's a token.
+func githubGet(u string) (*http.Response, error) {
+	return getWithToken(u, "Authorization", "Bearer %s", githubTokenVars...)
+}
+
+// githubRawURL returns the raw URL of a file a GitHub URL shows, as in
+// github.com/owner/repo/blob/ref/path.
+func githubRawURL(u *url.URL) (string, bool) {
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 5 || parts[2] != "blob" {
+		return "", false
+	}
+	return "https://raw.githubusercontent.com/" + strings.Join(append(parts[:2:2], parts[3:]...), "/"), true
+}
+
 // findGitHubREADME tries to find the correct README filename in a repository using GitHub API.
 func findGitHubREADME(u *url.URL) (*source, error) {
-	owner, repo, ok := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	// a file rather than the repository's README
+	if rawURL, ok := githubRawURL(u); ok {
+		return fetchRawFile(githubGet, rawURL)
+	}
+
+	owner, repo, ref, ok := parseGitHubPath(u.Path)
 	if !ok {
 		return nil, fmt.Errorf("invalid url: %s", u.String())
 	}
+	if ref == "" {
+		ref = branch
+	}
 
 	type readme struct {
 		DownloadURL string `json:"download_url"`
 	}
 
 	apiURL := fmt.Sprintf("https://api.%s/repos/%s/%s/readme", u.Hostname(), owner, repo)
+	if ref != "" {
+		apiURL += "?ref=" + url.QueryEscape(ref)
+	}
 
 	//nolint:bodyclose
 	// it is closed on the caller
-	res, err := http.Get(apiURL) //nolint: gosec,noctx
+	res, err := githubGet(apiURL)
 	if err != nil {
 		return nil, fmt.Errorf("unable to get url: %w", err)
 	}
@@ -43,15 +100,19 @@ func findGitHubREADME(u *url.URL) (*source, error) {
 	if res.StatusCode == http.StatusOK {
 		//nolint:bodyclose
 		// it is closed on the caller
-		resp, err := http.Get(result.DownloadURL) //nolint: noctx
+		resp, err := githubGet(result.DownloadURL)
 		if err != nil {
 			return nil, fmt.Errorf("unable to get url: %w", err)
 		}
 
 		if resp.StatusCode == http.StatusOK {
-			return &source{resp.Body, result.DownloadURL}, nil
+			return &source{reader: resp.Body, URL: result.DownloadURL}, nil
 		}
 	}
 
+	// GitHub hides private repositories from unauthenticated requests
+	if res.StatusCode == http.StatusNotFound && githubToken() == "" {
+		return nil, errors.New("can't find README in GitHub repository (set GITHUB_TOKEN to read private repositories)")
+	}
 	return nil, errors.New("can't find README in GitHub repository")
 }
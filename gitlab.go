@@ -10,27 +10,56 @@ import (
 	"strings"
 )
 
+// gitlabGet gets a GitLab URL, authenticated if GITLAB_TOKEN is set, which
+// lets Glow read private projects.
+func gitlabGet(u string) (*http.Response, error) {
+	return getWithToken(u, "PRIVATE-TOKEN", "%s", "GITLAB_TOKEN")
+}
+
+// parseGitLabPath returns the project of a GitLab URL path, which may be in
+// subgroups, the ref given as project@ref or project/-/tree/ref, and the
+// path of a file given as project/-/blob/ref/path.
+func parseGitLabPath(path string) (project, ref, file string) {
+	project, rest, _ := strings.Cut(strings.Trim(path, "/"), "/-/")
+	project, ref, _ = strings.Cut(project, "@")
+	project = strings.TrimSuffix(project, ".git")
+	switch kind, rest, _ := strings.Cut(rest, "/"); kind {
+	case "tree":
+		ref = rest
+	case "blob", "raw":
+		file = rest
+	}
+	return project, ref, file
+}
+
 // findGitLabREADME tries to find the correct README filename in a repository using GitLab API.
 func findGitLabREADME(u *url.URL) (*source, error) {
-	owner, repo, ok := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
-	if !ok {
+	project, ref, file := parseGitLabPath(u.Path)
+	if !strings.Contains(project, "/") {
 		return nil, fmt.Errorf("invalid url: %s", u.String())
 	}
+	base := fmt.Sprintf("%s://%s/%s", u.Scheme, u.Host, project)
 
-	projectPath := url.QueryEscape(owner + "/" + repo)
+	// a file rather than the project's README
+	if file != "" {
+		return fetchRawFile(gitlabGet, base+"/-/raw/"+file)
+	}
+	if ref == "" {
+		ref = branch
+	}
 
 	type readme struct {
-		ReadmeURL string `json:"readme_url"`
+		ReadmeURL     string `json:"readme_url"`
+		DefaultBranch string `json:"default_branch"`
 	}
 
-	apiURL := fmt.Sprintf("https://%s/api/v4/projects/%s", u.Hostname(), projectPath)
+	apiURL := fmt.Sprintf("%s://%s/api/v4/projects/%s", u.Scheme, u.Host, url.PathEscape(project))
 
-	//nolint:bodyclose
-	// it is closed on the caller
-	res, err := http.Get(apiURL) //nolint: gosec,noctx
+	res, err := gitlabGet(apiURL)
 	if err != nil {
 		return nil, fmt.Errorf("unable to get url: %w", err)
 	}
+	defer res.Body.Close() //nolint:errcheck
 
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
@@ -42,19 +71,14 @@ func findGitLabREADME(u *url.URL) (*source, error) {
 		return nil, fmt.Errorf("unable to parse json: %w", err)
 	}
 
-	readmeRawURL := strings.ReplaceAll(result.ReadmeURL, "blob", "raw")
-
-	if res.StatusCode == http.StatusOK {
-		//nolint:bodyclose
-		// it is closed on the caller
-		resp, err := http.Get(readmeRawURL) //nolint: gosec,noctx
-		if err != nil {
-			return nil, fmt.Errorf("unable to get url: %w", err)
-		}
-
-		if resp.StatusCode == http.StatusOK {
-			return &source{resp.Body, readmeRawURL}, nil
+	_, readmeFile, ok := strings.Cut(result.ReadmeURL, "/-/blob/")
+	if res.StatusCode == http.StatusOK && ok {
+		// the README of the default branch is read at the ref
+		readmeFile = strings.TrimPrefix(readmeFile, result.DefaultBranch+"/")
+		if ref == "" {
+			ref = result.DefaultBranch
 		}
+		return fetchRawFile(gitlabGet, base+"/-/raw/"+ref+"/"+readmeFile)
 	}
 
 	return nil, errors.New("can't find README in GitLab repository")
@@ -1,7 +1,17 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 func TestGlowFlags(t *testing.T) {
@@ -39,3 +49,198 @@ func TestGlowFlags(t *testing.T) {
 		}
 	}
 }
+
+func TestExecuteCLIPlain(t *testing.T) {
+	oldPager, oldStyle, oldWidth := pager, style, width
+	defer func() {
+		pager, style, width = oldPager, oldStyle, oldWidth
+		raw, noColor = false, false
+	}()
+	pager, style, width = false, "dark", 80
+
+	doc := "# Title\n\nSome **bold** `code`.\n"
+	render := func() string {
+		t.Helper()
+		var out strings.Builder
+		src := &source{reader: io.NopCloser(strings.NewReader(doc)), URL: "doc.md"}
+		if err := executeCLI(&cobra.Command{}, src, &out); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	noColor = true
+	if out := render(); strings.Contains(out, "\x1b") || !strings.Contains(out, "Some bold") {
+		t.Errorf("--no-color output %q has escape codes, or lacks the text", out)
+	}
+
+	noColor, raw = false, true
+	if out := render(); out != doc {
+		t.Errorf("--raw output is %q, want %q", out, doc)
+	}
+}
+
+func TestPagerCommand(t *testing.T) {
+	t.Cleanup(func() { viper.Set("pagerCommand", nil) })
+	for _, tt := range []struct {
+		pagerCommand, pager string
+		want                []string
+	}{
+		{"", "", []string{"less", "-R"}},
+		{"", "more", []string{"more"}},
+		{"bat  --paging=always", "more", []string{"bat", "--paging=always"}},
+	} {
+		viper.Set("pagerCommand", tt.pagerCommand)
+		t.Setenv("PAGER", tt.pager)
+		if got, _ := pagerCommand(); !slices.Equal(got, tt.want) {
+			t.Errorf("pagerCommand() with pagerCommand %q and PAGER=%q = %q, want %q", tt.pagerCommand, tt.pager, got, tt.want)
+		}
+	}
+
+	t.Setenv("LESS", "FRX")
+	if _, env := pagerCommand(); len(env) != 0 {
+		t.Errorf("pagerCommand() sets %q over $LESS", env)
+	}
+}
+
+func TestRenderWatched(t *testing.T) {
+	oldPager, oldStyle, oldWidth := pager, style, width
+	defer func() { pager, style, width = oldPager, oldStyle, oldWidth }()
+	pager, style, width = false, "notty", 80
+
+	path := filepath.Join(t.TempDir(), "doc.md")
+	if err := os.WriteFile(path, []byte("# Watched\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := renderWatched(&cobra.Command{}, path, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "\x1b[H\x1b[2J") || !strings.Contains(out.String(), "# Watched") {
+		t.Errorf("renderWatched() = %q, want the document on a cleared screen", out.String())
+	}
+
+	// A file removed while it's saved is reported until it's back
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := renderWatched(&cobra.Command{}, path, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "no such file") {
+		t.Errorf("renderWatched() = %q, want the error in place of the document", out.String())
+	}
+
+	// So is a document that can't be rendered
+	if err := os.WriteFile(path, []byte("# Watched\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	style = filepath.Join(t.TempDir(), "missing.json")
+	out.Reset()
+	if err := renderWatched(&cobra.Command{}, path, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "unable to create renderer") {
+		t.Errorf("renderWatched() = %q, want the render error in place of the document", out.String())
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	_, err := sourceFromArg(filepath.Join(t.TempDir(), "missing.md"))
+	if code := exitCode(err); code != exitNotFound {
+		t.Errorf("exit code of a missing file is %d, want %d", code, exitNotFound)
+	}
+	// Not taken for a GitHub repository either
+	t.Chdir(t.TempDir())
+	_, err = sourceFromArg("a/b.txt")
+	if code := exitCode(err); code != exitNotFound {
+		t.Errorf("exit code of missing a/b.txt is %d, want %d", code, exitNotFound)
+	}
+	if code := exitCode(fmt.Errorf("wrapped: %w", withExitCode(exitBroken, errors.New("broken")))); code != exitBroken {
+		t.Errorf("exit code of a wrapped error is %d, want %d", code, exitBroken)
+	}
+	if code := exitCode(errors.New("other")); code != exitError {
+		t.Errorf("exit code of another error is %d, want %d", code, exitError)
+	}
+}
+
+func TestParseWidth(t *testing.T) {
+	for _, tt := range []struct {
+		s     string
+		width uint
+		auto  bool
+	}{
+		{"0", 0, false},
+		{"60", 60, false},
+		{"auto", 0, true},
+	} {
+		width, auto, err := parseWidth(tt.s)
+		if err != nil || width != tt.width || auto != tt.auto {
+			t.Errorf("parseWidth(%q) = %d, %t, %v, want %d, %t", tt.s, width, auto, err, tt.width, tt.auto)
+		}
+	}
+	if _, _, err := parseWidth("wide"); err == nil {
+		t.Error("Expected an error for an invalid width")
+	}
+}
+
+func TestRenderCLIAutoWidth(t *testing.T) {
+	oldPager, oldStyle, oldWidth := pager, style, width
+	defer func() {
+		pager, style, width = oldPager, oldStyle, oldWidth
+		autoWidth = false
+	}()
+	pager, style, width, autoWidth = false, "notty", 200, true
+
+	cell := strings.Repeat("x", 120)
+	prose := strings.Repeat("word ", 40)
+	doc := "| a | b |\n|---|---|\n| " + cell + " | y |\n\n" + prose + "\n"
+	_, out, err := renderCLI(&cobra.Command{}, &source{reader: io.NopCloser(strings.NewReader(doc)), URL: "doc.md"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, cell) {
+		t.Errorf("Expected the table not to be wrapped:\n%s", out)
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if strings.Contains(line, "word") && len(strings.TrimRight(line, " ")) > len(cell)+10 {
+			t.Errorf("Expected prose to wrap at the width of the table, got a line of %d", len(line))
+		}
+	}
+
+	// Without anything wide, the document is rendered at the narrowest width
+	_, out, err = renderCLI(&cobra.Command{}, &source{reader: io.NopCloser(strings.NewReader(prose)), URL: "doc.md"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if len(strings.TrimRight(line, " ")) > 80 {
+			t.Errorf("Expected prose to wrap at 80 columns, got a line of %d", len(line))
+		}
+	}
+}
+
+func TestRenderCLIEmoji(t *testing.T) {
+	oldPager, oldStyle, oldWidth, oldEmoji := pager, style, width, emoji
+	defer func() { pager, style, width, emoji = oldPager, oldStyle, oldWidth, oldEmoji }()
+	pager, style, width = false, "notty", 80
+
+	for _, tt := range []struct {
+		emoji bool
+		want  string
+	}{
+		{true, "Ship it 🚀 :rocket:"},
+		{false, "Ship it :rocket: :rocket:"},
+	} {
+		emoji = tt.emoji
+		doc := "Ship it :rocket: `:rocket:`\n"
+		_, out, err := renderCLI(&cobra.Command{}, &source{reader: io.NopCloser(strings.NewReader(doc)), URL: "doc.md"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out, tt.want) {
+			t.Errorf("emoji %t: expected %q in:\n%s", tt.emoji, tt.want, out)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/hholst80/glow/document"
+	"github.com/hholst80/glow/ui"
+	"github.com/hholst80/glow/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	grepIgnoreCase bool
+	grepContext    int
+
+	grepCmd = &cobra.Command{
+		Use:     "grep PATTERN [DIR]",
+		Short:   "Search markdown files, showing matches rendered in their sections",
+		Long:    paragraph(fmt.Sprintf("\n%s the markdown files of a directory, or a file, for a regular expression. Each match is shown under the headings of its section, rendered with the lines around it.", keyword("Search"))),
+		Example: paragraph("glow grep TODO\nglow grep -i 'mermaid|diagram' docs/\nglow grep -C 0 '^## ' README.md"),
+		Args:    cobra.RangeArgs(1, 2),
+		RunE:    grepFiles,
+	}
+
+	grepFileStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("#04B575")).Bold(true)
+	grepBreadcrumbStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#7D7D7D"))
+	grepMatchStyle      = lipgloss.NewStyle().Reverse(true)
+
+	// errNoMatches makes glow grep exit with 1 when nothing matches, as grep
+	// does.
+	errNoMatches = errors.New("no matches")
+)
+
+// grepHunk is a run of lines of a document with matches, and the lines
+// around them.
+type grepHunk struct {
+	start, end int      // lines of the hunk, counted from 1, end included
+	matches    []int    // lines that match
+	headings   []string // headings of the section of the first match
+}
+
+func grepFiles(cmd *cobra.Command, args []string) error {
+	if err := validateOptions(cmd); err != nil {
+		return err
+	}
+	pattern := args[0]
+	if grepIgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	dir := "."
+	if len(args) > 1 {
+		dir = args[1]
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return withExitCode(exitNotFound, fmt.Errorf("unable to search %s: %w", dir, err))
+	}
+	paths := []string{dir}
+	if info.IsDir() {
+		paths, err = ui.FindMarkdownFiles(dir, ui.Config{
+			ShowAllFiles:   showAllFiles,
+			ShowHidden:     showHidden || showAllFiles,
+			NoIgnore:       noIgnore,
+			MaxDepth:       maxDepth,
+			FollowSymlinks: followSymlinks,
+			Gopath:         os.Getenv("GOPATH"),
+			HomeDir:        os.Getenv("HOME"),
+		})
+		if err != nil {
+			return fmt.Errorf("unable to search %s: %w", dir, err)
+		}
+	}
+
+	r, err := glamour.NewTermRenderer(
+		glamour.WithColorProfile(lipgloss.ColorProfile()),
+		utils.GlamourStyle(style, false),
+		glamour.WithWordWrap(int(width)), //nolint:gosec
+	)
+	if err != nil {
+		return withExitCode(exitRender, fmt.Errorf("unable to create renderer: %w", err))
+	}
+
+	out := cmd.OutOrStdout()
+	matched := false
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %w", path, err)
+		}
+		lines := strings.Split(string(b), "\n")
+		for _, h := range grepDocument(b, re, grepContext) {
+			matched = true
+			fmt.Fprintln(out, grepFileStyle.Render(fmt.Sprintf("%s:%d", path, h.matches[0])))
+			if len(h.headings) > 0 {
+				fmt.Fprintln(out, grepBreadcrumbStyle.Render("  "+strings.Join(h.headings, " › ")))
+			}
+			fmt.Fprintln(out, renderHunk(r, re, lines, h))
+		}
+	}
+	if !matched {
+		// Only the exit code tells, as with grep
+		cmd.SilenceErrors = true
+		return errNoMatches
+	}
+	return nil
+}
+
+// grepDocument returns the hunks of a document with lines that match re,
+// each with lines of context around them.
+func grepDocument(markdown []byte, re *regexp.Regexp, context int) []grepHunk {
+	headings := document.Parse(markdown).Headings
+	lines := strings.Split(string(markdown), "\n")
+
+	var hunks []grepHunk
+	for i, line := range lines {
+		if !re.MatchString(line) {
+			continue
+		}
+		n := i + 1
+		if len(hunks) > 0 && hunks[len(hunks)-1].end >= n-context-1 {
+			// Close enough to share the previous hunk
+			h := &hunks[len(hunks)-1]
+			h.end = min(n+context, len(lines))
+			h.matches = append(h.matches, n)
+			continue
+		}
+
+		// The headings the line is under, one of each level
+		var section []document.Heading
+		for _, h := range headings {
+			if h.Line > n {
+				break
+			}
+			for len(section) > 0 && section[len(section)-1].Level >= h.Level {
+				section = section[:len(section)-1]
+			}
+			section = append(section, h)
+		}
+		texts := make([]string, len(section))
+		for j, h := range section {
+			texts[j] = h.Text
+		}
+		hunks = append(hunks, grepHunk{max(1, n-context), min(n+context, len(lines)), []int{n}, texts})
+	}
+	return hunks
+}
+
+// renderHunk renders the lines of a hunk, with its matches highlighted. Lines
+// in a code block are rendered as code.
+func renderHunk(r *glamour.TermRenderer, re *regexp.Regexp, lines []string, h grepHunk) string {
+	fence := ""
+	for _, line := range lines[:h.start-1] {
+		fence = utils.NextFence(fence, line)
+	}
+	snippet := lines[h.start-1 : h.end]
+	md := strings.Join(snippet, "\n")
+	if fence != "" {
+		md = fence + "\n" + md
+	}
+	for _, line := range snippet {
+		fence = utils.NextFence(fence, line)
+	}
+	if fence != "" {
+		md += "\n" + fence[:3]
+	}
+
+	out, err := r.Render(md)
+	if err != nil {
+		out = md
+	}
+	rendered := strings.Split(strings.Trim(out, "\n"), "\n")
+	for i, line := range rendered {
+		plain := ansi.Strip(line)
+		if re.MatchString(plain) {
+			rendered[i] = re.ReplaceAllStringFunc(plain, func(s string) string { return grepMatchStyle.Render(s) })
+		}
+	}
+	return strings.Join(rendered, "\n")
+}
+
+func init() {
+	grepCmd.Flags().BoolVarP(&grepIgnoreCase, "ignore-case", "i", false, "match upper and lower case alike")
+	grepCmd.Flags().IntVarP(&grepContext, "context", "C", 2, "lines shown before and after each match")
+}
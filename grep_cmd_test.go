@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/spf13/cobra"
+)
+
+const grepTestDocument = `# Guide
+
+## Install
+
+Run the installer.
+
+### Linux
+
+Use the package.
+The package is signed.
+
+## Usage
+
+` + "```sh\nglow README.md\nglow -p README.md\n```\n"
+
+func TestGrepDocument(t *testing.T) {
+	hunks := grepDocument([]byte(grepTestDocument), regexp.MustCompile("package|installer"), 1)
+	if len(hunks) != 2 {
+		t.Fatalf("Expected 2 hunks, got %+v", hunks)
+	}
+
+	// Matches close together share a hunk, under the headings of the first
+	if h := hunks[1]; h.start != 8 || h.end != 11 || !slices.Equal(h.matches, []int{9, 10}) {
+		t.Errorf("Expected lines 8 to 11 with matches on 9 and 10, got %+v", h)
+	}
+	if want := []string{"Guide", "Install", "Linux"}; !slices.Equal(hunks[1].headings, want) {
+		t.Errorf("Expected headings %q, got %q", want, hunks[1].headings)
+	}
+	if want := []string{"Guide", "Install"}; !slices.Equal(hunks[0].headings, want) {
+		t.Errorf("Expected headings %q, got %q", want, hunks[0].headings)
+	}
+}
+
+func TestRenderHunk(t *testing.T) {
+	r, err := glamour.NewTermRenderer(glamour.WithStylePath("notty"), glamour.WithWordWrap(80))
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := regexp.MustCompile("-p")
+	lines := strings.Split(grepTestDocument, "\n")
+	hunks := grepDocument([]byte(grepTestDocument), re, 0)
+	if len(hunks) != 1 {
+		t.Fatalf("Expected 1 hunk, got %+v", hunks)
+	}
+
+	// The line is rendered as the code it is, without the fence
+	out := renderHunk(r, re, lines, hunks[0])
+	if !strings.Contains(out, "    glow -p README.md") || strings.Contains(out, "```") {
+		t.Errorf("Expected the line rendered as code, got %q", out)
+	}
+}
+
+func TestGrepFiles_NoMatches(t *testing.T) {
+	oldStyle, oldWidth := style, width
+	defer func() { style, width = oldStyle, oldWidth }()
+	style, width = "notty", 80
+
+	path := filepath.Join(t.TempDir(), "guide.md")
+	if err := os.WriteFile(path, []byte(grepTestDocument), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+
+	if err := grepFiles(cmd, []string{"installer", path}); err != nil || !strings.Contains(out.String(), "installer") {
+		t.Errorf("Expected the match, got %q, %v", out.String(), err)
+	}
+
+	// Nothing matching exits with 1, as grep does
+	out.Reset()
+	err := grepFiles(cmd, []string{"missing", path})
+	if !errors.Is(err, errNoMatches) || exitCode(err) != exitError || out.String() != "" {
+		t.Errorf("Expected no matches, got %q, %v", out.String(), err)
+	}
+}
@@ -0,0 +1,340 @@
+// Package htmlblocks converts the HTML common in READMEs to markdown, where
+// glamour would print its tags or drop its content: line breaks, images,
+// links, keys, emphasis, headings, alignment wrappers, tables and <details>
+// sections, which are shown open or folded to their summary.
+package htmlblocks
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/hholst80/glow/utils"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+// Markers start the summary of a <details> section, open or folded.
+const (
+	OpenMarker   = "▾"
+	FoldedMarker = "▸"
+)
+
+// Details is a <details> section of a document.
+type Details struct {
+	Summary string // text of its <summary>, without markup
+	Open    bool   // whether its open attribute shows it open to begin with
+}
+
+var (
+	detailsRegex    = regexp.MustCompile(`(?i)^\s*<details(\s[^>]*)?>(.*)$`)
+	detailsEndRegex = regexp.MustCompile(`(?i)^(.*?)</details>\s*$`)
+	summaryRegex    = regexp.MustCompile(`(?is)^\s*<summary[^>]*>(.*?)</summary>(.*)$`)
+	openRegex       = regexp.MustCompile(`(?i)(^|\s)open(\s|=|$)`)
+
+	tableRegex    = regexp.MustCompile(`(?i)^\s*<table[\s>]`)
+	tableEndRegex = regexp.MustCompile(`(?i)</table>`)
+	rowRegex      = regexp.MustCompile(`(?is)<tr[^>]*>(.*?)</tr>`)
+	cellRegex     = regexp.MustCompile(`(?is)<t[hd][^>]*>(.*?)</t[hd]>`)
+
+	// headingRegex matches a heading on a line of its own.
+	headingRegex = regexp.MustCompile(`(?is)^\s*<h([1-6])[^>]*>(.*?)</h[1-6]>\s*$`)
+
+	// wrapperRegex matches the tags of elements that only wrap blocks, such
+	// as the divs READMEs align their logos and badges with.
+	wrapperRegex = regexp.MustCompile(`(?i)</?(?:div|p|center|picture)(?:\s[^>]*)?>`)
+
+	// ruleRegex matches a horizontal rule on a line of its own.
+	ruleRegex = regexp.MustCompile(`(?i)^\s*<hr\s*/?>\s*$`)
+
+	brRegex      = regexp.MustCompile(`(?i)<br\s*/?>`)
+	brEndRegex   = regexp.MustCompile(`(?i)<br\s*/?>\s*$`)
+	imgRegex     = regexp.MustCompile(`(?is)<img\s[^>]*>`)
+	linkRegex    = regexp.MustCompile(`(?is)<a\s[^>]*?href\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)[^>]*>(.*?)</a>`)
+	anchorRegex  = regexp.MustCompile(`(?i)</?a(\s[^>]*)?>`)
+	attrRegex    = regexp.MustCompile(`(?is)\b(src|alt)\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+	kbdRegex     = regexp.MustCompile(`(?is)<kbd>(.*?)</kbd>`)
+	strongRegex  = regexp.MustCompile(`(?i)</?(?:b|strong)>`)
+	emRegex      = regexp.MustCompile(`(?i)</?(?:i|em)>`)
+	codeRegex    = regexp.MustCompile(`(?i)</?code>`)
+	strikeRegex  = regexp.MustCompile(`(?i)</?(?:s|del|strike)>`)
+	dropRegex    = regexp.MustCompile(`(?i)</?(?:sup|sub|span|summary|source)(?:\s[^>]*)?>`)
+	tagRegex     = regexp.MustCompile(`<[^>]*>`)
+	markupRegex  = regexp.MustCompile("[*_`~]+")
+	openTagRegex = regexp.MustCompile(`(?i)<(?:img|a|source)(?:\s[^>]*)?$`)
+	spaceRegex   = regexp.MustCompile(`\s+`)
+	cellEscaper  = strings.NewReplacer("|", `\|`)
+)
+
+// Process returns markdown with its HTML converted to markdown. open tells
+// whether the nth <details> section, from 0, is shown open; a folded section
+// shows only its summary.
+func Process(markdown string, open func(n int, d Details) bool) string {
+	out, _ := convert(markdown, open)
+	return out
+}
+
+// Find returns the <details> sections of markdown, in order.
+func Find(markdown string) []Details {
+	_, details := convert(markdown, func(int, Details) bool { return true })
+	return details
+}
+
+// convert returns markdown with its HTML converted, and its <details>
+// sections. Only the lines of HTML blocks and of inline HTML are converted,
+// so code, such as indented code blocks, is left as it is.
+func convert(markdown string, open func(n int, d Details) bool) (string, []Details) {
+	if !strings.Contains(markdown, "<") {
+		return markdown, nil
+	}
+	lines, isHTML := joinTags(strings.Split(markdown, "\n"), htmlLines(markdown))
+	out := make([]string, 0, len(lines))
+	var details []Details
+	var folded []bool // of the sections the line is in, innermost last
+	hidden := func() bool {
+		for _, f := range folded {
+			if f {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if !isHTML[i] {
+			if !hidden() {
+				out = append(out, line)
+			}
+			continue
+		}
+
+		if m := detailsRegex.FindStringSubmatch(line); m != nil {
+			rest := m[2]
+			// The summary is on the line of the tag, or the next
+			if strings.TrimSpace(rest) == "" && i+1 < len(lines) && summaryRegex.MatchString(lines[i+1]) {
+				i++
+				rest = lines[i]
+			}
+			summary := "Details"
+			if s := summaryRegex.FindStringSubmatch(rest); s != nil {
+				summary, rest = inline(s[1]), s[2]
+			}
+			d := Details{Summary: plain(summary), Open: openRegex.MatchString(m[1])}
+			n := len(details)
+			details = append(details, d)
+			wasHidden := hidden()
+			isOpen := open(n, d)
+			folded = append(folded, !isOpen)
+			if wasHidden {
+				continue
+			}
+			marker := FoldedMarker
+			if isOpen {
+				marker = OpenMarker
+			}
+			out = append(out, "", marker+" "+summary, "")
+			if isOpen && strings.TrimSpace(rest) != "" {
+				out = append(out, inline(rest))
+			}
+			continue
+		}
+		if m := detailsEndRegex.FindStringSubmatch(line); m != nil && len(folded) > 0 {
+			if !hidden() && strings.TrimSpace(m[1]) != "" {
+				out = append(out, inline(m[1]))
+			}
+			folded = folded[:len(folded)-1]
+			if !hidden() {
+				out = append(out, "")
+			}
+			continue
+		}
+		if hidden() {
+			continue
+		}
+
+		switch {
+		case tableRegex.MatchString(line):
+			end := i
+			for end < len(lines)-1 && !tableEndRegex.MatchString(lines[end]) {
+				end++
+			}
+			out = append(out, table(strings.Join(lines[i:end+1], "\n"))...)
+			i = end
+		case ruleRegex.MatchString(line):
+			out = append(out, "", "---", "")
+		case headingRegex.MatchString(line):
+			m := headingRegex.FindStringSubmatch(line)
+			out = append(out, "", strings.Repeat("#", len(m[1]))+" "+strings.TrimSpace(inline(m[2])), "")
+		case wrapperRegex.MatchString(line):
+			// Blocks wrapped for their alignment, shown as they are
+			content := strings.TrimSpace(wrapperRegex.ReplaceAllString(line, ""))
+			if content == "" {
+				out = append(out, "")
+			} else {
+				out = append(out, "", inline(content), "")
+			}
+		default:
+			out = append(out, inline(line))
+		}
+	}
+	return strings.Join(out, "\n"), details
+}
+
+// htmlLines returns which lines of markdown have HTML on them, in HTML
+// blocks or inline, as goldmark parses it.
+func htmlLines(markdown string) []bool {
+	source := []byte(markdown)
+	isHTML := make([]bool, strings.Count(markdown, "\n")+1)
+	mark := func(segments *text.Segments) {
+		for i := 0; i < segments.Len(); i++ {
+			s := segments.At(i)
+			first := bytes.Count(source[:s.Start], []byte("\n"))
+			last := first + bytes.Count(source[s.Start:max(s.Start, s.Stop-1)], []byte("\n"))
+			for l := first; l <= last && l < len(isHTML); l++ {
+				isHTML[l] = true
+			}
+		}
+	}
+
+	root := goldmark.New(goldmark.WithExtensions(extension.GFM)).Parser().Parse(text.NewReader(source))
+	_ = ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.HTMLBlock:
+			mark(n.Lines())
+			if n.HasClosure() {
+				closure := text.NewSegments()
+				closure.Append(n.ClosureLine)
+				mark(closure)
+			}
+		case *ast.RawHTML:
+			mark(n.Segments)
+		}
+		return ast.WalkContinue, nil
+	})
+	return isHTML
+}
+
+// joinTags joins the lines of images and links whose tags go on over several
+// lines, so they can be converted as one, returning them and which have HTML
+// on them.
+func joinTags(lines []string, isHTML []bool) ([]string, []bool) {
+	out := make([]string, 0, len(lines))
+	outHTML := make([]bool, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		line, html := lines[i], isHTML[i]
+		for html && openTagRegex.MatchString(line) && i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+			i++
+			line += " " + strings.TrimSpace(lines[i])
+		}
+		out = append(out, line)
+		outHTML = append(outHTML, html)
+	}
+	return out, outHTML
+}
+
+// inline returns a line with its inline HTML, outside of code spans,
+// converted to markdown.
+func inline(line string) string {
+	return utils.OutsideCodeSpans(line, func(s string) string {
+		s = imgRegex.ReplaceAllStringFunc(s, image)
+		s = linkRegex.ReplaceAllStringFunc(s, func(a string) string {
+			m := linkRegex.FindStringSubmatch(a)
+			text := strings.TrimSpace(m[2])
+			if text == "" {
+				return ""
+			}
+			return "[" + text + "](" + unquote(m[1]) + ")"
+		})
+		s = anchorRegex.ReplaceAllString(s, "")
+		s = kbdRegex.ReplaceAllString(s, "`$1`")
+		s = strongRegex.ReplaceAllString(s, "**")
+		s = emRegex.ReplaceAllString(s, "*")
+		s = codeRegex.ReplaceAllString(s, "`")
+		s = strikeRegex.ReplaceAllString(s, "~~")
+		s = dropRegex.ReplaceAllString(s, "")
+		s = wrapperRegex.ReplaceAllString(s, "")
+		return lineBreaks(s)
+	})
+}
+
+// lineBreaks returns s with its <br> tags as hard line breaks; one at the end
+// of a line breaks it where it does already.
+func lineBreaks(s string) string {
+	if brEndRegex.MatchString(s) {
+		s = brEndRegex.ReplaceAllString(s, "")
+		if strings.TrimSpace(s) != "" {
+			s += "\\"
+		}
+	}
+	return brRegex.ReplaceAllString(s, "\\\n")
+}
+
+// image returns an <img> tag as a markdown image, or its alt text if it has
+// no source.
+func image(tag string) string {
+	var src, alt string
+	for _, m := range attrRegex.FindAllStringSubmatch(tag, -1) {
+		if strings.EqualFold(m[1], "src") {
+			src = unquote(m[2])
+		} else {
+			alt = unquote(m[2])
+		}
+	}
+	if src == "" {
+		return alt
+	}
+	return "![" + alt + "](" + src + ")"
+}
+
+// table returns the lines of a markdown table of an HTML table, its first row
+// the header.
+func table(html string) []string {
+	var rows [][]string
+	columns := 0
+	for _, r := range rowRegex.FindAllStringSubmatch(html, -1) {
+		var row []string
+		for _, c := range cellRegex.FindAllStringSubmatch(r[1], -1) {
+			text := brRegex.ReplaceAllString(c[1], " ")
+			text = strings.TrimSpace(spaceRegex.ReplaceAllString(text, " "))
+			row = append(row, cellEscaper.Replace(inline(text)))
+		}
+		if len(row) > 0 {
+			rows = append(rows, row)
+			columns = max(columns, len(row))
+		}
+	}
+	if len(rows) == 0 {
+		return []string{""}
+	}
+
+	line := func(row []string) string {
+		cells := make([]string, columns)
+		copy(cells, row)
+		return "| " + strings.Join(cells, " | ") + " |"
+	}
+	out := []string{"", line(rows[0]), "|" + strings.Repeat("---|", columns)}
+	for _, row := range rows[1:] {
+		out = append(out, line(row))
+	}
+	return append(out, "")
+}
+
+// plain returns text without its tags and markup, with its spaces collapsed.
+func plain(s string) string {
+	s = markupRegex.ReplaceAllString(tagRegex.ReplaceAllString(s, ""), "")
+	return strings.TrimSpace(spaceRegex.ReplaceAllString(s, " "))
+}
+
+// unquote returns an attribute's value without its quotes.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,86 @@
+package htmlblocks
+
+import (
+	"reflect"
+	"testing"
+)
+
+func allOpen(int, Details) bool { return true }
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "no html",
+			in:   "Text with `<br>` in code.\n",
+			want: "Text with `<br>` in code.\n",
+		},
+		{
+			name: "line breaks",
+			in:   "One<br>two<br/>\nthree\n",
+			want: "One\\\ntwo\\\nthree\n",
+		},
+		{
+			name: "inline elements",
+			in:   "Press <kbd>q</kbd>, <b>bold</b> <em>em</em> <a href=\"https://x.dev\">site</a> <a name=\"top\"></a>\n",
+			want: "Press `q`, **bold** *em* [site](https://x.dev) \n",
+		},
+		{
+			name: "images, across lines",
+			in:   "<img src=\"a.png\" alt=\"A\">\n<img\n  src='b.png'\n  alt=\"B\">\n<img alt=\"no source\">\n",
+			want: "![A](a.png)\n![B](b.png)\nno source\n",
+		},
+		{
+			name: "alignment wrappers and headings",
+			in:   "<div align=\"center\">\n  <h1>Title</h1>\n<p align=\"center\">Centered</p>\n</div>\n",
+			want: "\n\n# Title\n\n\nCentered\n\n\n",
+		},
+		{
+			name: "tables",
+			in:   "<table>\n<tr><th>Name</th><th>Note</th></tr>\n<tr><td>a</td><td>x | y<br>z</td></tr>\n</table>\n",
+			want: "\n| Name | Note |\n|---|---|\n| a | x \\| y z |\n\n",
+		},
+		{
+			name: "code blocks left alone",
+			in:   "```html\n<br>\n<details>\n```\n",
+			want: "```html\n<br>\n<details>\n```\n",
+		},
+		{
+			name: "indented code blocks left alone",
+			in:   "Text.\n\n    <b>bold</b><br>\n    <details>\n\n- item<br>\n",
+			want: "Text.\n\n    <b>bold</b><br>\n    <details>\n\n- item\\\n",
+		},
+		{
+			name: "details",
+			in:   "<details>\n<summary><b>More</b></summary>\n\nHidden.\n\n</details>\n",
+			want: "\n▾ **More**\n\n\nHidden.\n\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Process(tt.in, allOpen); got != tt.want {
+				t.Errorf("Process() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessFolded(t *testing.T) {
+	in := "<details open><summary>A</summary>\n\nShown.\n\n<details>\n<summary>B</summary>\nInner.\n</details>\n</details>\n\n<details>\nNo summary.\n</details>\nAfter.\n"
+	open := func(n int, d Details) bool { return d.Open }
+	want := "\n▾ A\n\n\nShown.\n\n\n▸ B\n\n\n\n\n\n▸ Details\n\n\nAfter.\n"
+	if got := Process(in, open); got != want {
+		t.Errorf("Process() = %q, want %q", got, want)
+	}
+}
+
+func TestFind(t *testing.T) {
+	in := "<details open><summary>A <i>b</i></summary>\n<details>\n<summary>Inner</summary>\n</details>\n</details>\n\n```\n<details>\n```\n<details>\n</details>\n"
+	want := []Details{{Summary: "A b", Open: true}, {Summary: "Inner"}, {Summary: "Details"}}
+	if got := Find(in); !reflect.DeepEqual(got, want) {
+		t.Errorf("Find() = %+v, want %+v", got, want)
+	}
+}
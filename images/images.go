@@ -0,0 +1,266 @@
+// Package images shows the images of a document, which the terminal can't
+// display, as boxes naming them: their alt text, file name and dimensions,
+// and a link to their source.
+package images
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"  // decode the dimensions of GIFs
+	_ "image/jpeg" // and JPEGs
+	_ "image/png"  // and PNGs
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/hholst80/glow/utils"
+)
+
+// codeMargin is how much narrower than the page glamour leaves the code
+// blocks that boxes are kept in.
+const codeMargin = 6
+
+// placeholderPrefix starts the placeholder of a line of an image's box.
+const placeholderPrefix = "@glow-image-"
+
+var (
+	// imageRegex matches an image on a line of its own, and the link it's
+	// in if it's in one.
+	imageRegex = regexp.MustCompile(`^ {0,3}(\[)?!\[((?:\\.|[^\]])*)\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)(?:\]\(\s*<?([^)\s>]+)>?[^)]*\))?\s*$`)
+
+	// svgRegex matches the root tag of an SVG, and sizeRegex the width,
+	// height or viewBox in it.
+	svgRegex  = regexp.MustCompile(`(?is)<svg\s[^>]*>`)
+	sizeRegex = regexp.MustCompile(`(?is)\b(width|height|viewBox)\s*=\s*["']([^"']*)["']`)
+
+	// escapeRegex matches a character escaped with a backslash.
+	escapeRegex = regexp.MustCompile(`\\([[:punct:]])`)
+)
+
+var (
+	boxStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.AdaptiveColor{Light: "#8E8E8E", Dark: "#5C5C5C"}).
+			Padding(0, 1)
+	altStyle   = lipgloss.NewStyle().Bold(true)
+	mutedStyle = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#6E7781", Dark: "#8B949E"})
+)
+
+// Preprocessor shows the images on lines of their own as boxes, since the
+// terminal can't display them. Process replaces them, and Restore puts their
+// boxes in place of their placeholders after rendering.
+type Preprocessor struct {
+	width      int
+	base       string // directory or URL relative sources are resolved against
+	hyperlinks bool
+	raw        map[string]string // placeholder to the line of a box
+	boxes      int
+}
+
+// NewPreprocessor returns a Preprocessor for a page width columns wide,
+// resolving relative sources against base, the directory or URL of the
+// document. hyperlinks makes the sources links the terminal opens; 0 leaves
+// images as they are.
+func NewPreprocessor(width int, base string, hyperlinks bool) *Preprocessor {
+	return &Preprocessor{width: width, base: base, hyperlinks: hyperlinks, raw: map[string]string{}}
+}
+
+// Process returns markdown with the images on lines of their own replaced
+// by placeholders for their boxes.
+func (p *Preprocessor) Process(markdown string) string {
+	if p.width <= 0 || !strings.Contains(markdown, "![") {
+		return markdown
+	}
+	lines := strings.Split(markdown, "\n")
+	out := make([]string, 0, len(lines))
+	fence := ""
+	for _, line := range lines {
+		inCode := fence != ""
+		if fence = utils.NextFence(fence, line); inCode || fence != "" {
+			out = append(out, line)
+			continue
+		}
+		m := imageRegex.FindStringSubmatch(line)
+		if m == nil || (m[1] != "") != (m[4] != "") {
+			out = append(out, line)
+			continue
+		}
+		alt := escapeRegex.ReplaceAllString(m[2], "$1")
+		out = append(out, p.placeholders(p.box(alt, m[3], m[4]))...)
+	}
+	return strings.Join(out, "\n")
+}
+
+// placeholders returns a code block of placeholders for the lines of a box,
+// which glamour keeps one to a line, keeping the lines for Restore.
+func (p *Preprocessor) placeholders(lines []string) []string {
+	out := []string{"", "```"}
+	for i, line := range lines {
+		key := fmt.Sprintf("%s%d-%d@", placeholderPrefix, p.boxes, i)
+		p.raw[key] = line
+		out = append(out, key)
+	}
+	p.boxes++
+	return append(out, "```", "")
+}
+
+// Restore replaces the placeholders of boxes in rendered output with their
+// lines.
+func (p *Preprocessor) Restore(rendered string) string {
+	if len(p.raw) == 0 {
+		return rendered
+	}
+	lines := strings.Split(rendered, "\n")
+	for i, line := range lines {
+		idx := strings.Index(line, placeholderPrefix)
+		if idx < 0 {
+			continue
+		}
+		end := strings.Index(line[idx+len(placeholderPrefix):], "@")
+		if end < 0 {
+			continue
+		}
+		boxLine, ok := p.raw[line[idx:idx+len(placeholderPrefix)+end+1]]
+		if !ok {
+			continue
+		}
+		prefix := line[:idx]
+		if strings.Contains(prefix, "\x1b[") {
+			// End the code block's styling before the box's
+			prefix += "\x1b[0m"
+		}
+		lines[i] = prefix + boxLine
+	}
+	return strings.Join(lines, "\n")
+}
+
+// box returns the lines of the box of an image: its alt text, its file name
+// and dimensions if they can be read, its source and the link it's in.
+func (p *Preprocessor) box(alt, src, link string) []string {
+	source, file := p.resolve(src)
+	if alt == "" {
+		alt = "Image"
+	}
+	details := path.Base(strings.TrimSuffix(strings.SplitN(src, "?", 2)[0], "/"))
+	if w, h, ok := dimensions(file); ok {
+		details += fmt.Sprintf(" · %d×%d", w, h)
+	}
+
+	room := max(1, p.width-codeMargin-boxStyle.GetHorizontalFrameSize())
+	content := []string{
+		altStyle.Render(ansi.Truncate(alt, room, "…")),
+		mutedStyle.Render(ansi.Truncate(details, room, "…")),
+		p.link(source, room),
+	}
+	if link != "" {
+		target, _ := p.resolve(link)
+		content = append(content, mutedStyle.Render("→ ")+p.link(target, room-2))
+	}
+	return strings.Split(boxStyle.Render(strings.Join(content, "\n")), "\n")
+}
+
+// link returns a source truncated to width, as a hyperlink to it if they're
+// shown.
+func (p *Preprocessor) link(source string, width int) string {
+	text := mutedStyle.Render(ansi.Truncate(source, max(1, width), "…"))
+	if !p.hyperlinks {
+		return text
+	}
+	target := source
+	if !strings.Contains(source, "://") {
+		if abs, err := filepath.Abs(source); err == nil {
+			target = (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()
+		}
+	}
+	return ansi.SetHyperlink(target) + text + ansi.ResetHyperlink()
+}
+
+// resolve returns a source resolved against the document's base, and the
+// local file it's in if it's in one.
+func (p *Preprocessor) resolve(src string) (source, file string) {
+	if u, err := url.Parse(src); err == nil && u.Scheme != "" {
+		if u.Scheme == "file" {
+			return src, u.Path
+		}
+		return src, ""
+	}
+	if base, err := url.Parse(p.base); err == nil && base.Scheme != "" && base.Scheme != "file" {
+		if ref, err := url.Parse(src); err == nil {
+			return base.ResolveReference(ref).String(), ""
+		}
+		return src, ""
+	}
+	file = filepath.FromSlash(src)
+	if unescaped, err := url.PathUnescape(src); err == nil {
+		file = filepath.FromSlash(unescaped)
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(strings.TrimPrefix(p.base, "file://"), file)
+	}
+	return file, file
+}
+
+// dimensions returns the width and height of the image in a file, or false
+// if it can't be read.
+func dimensions(file string) (int, int, bool) {
+	if file == "" {
+		return 0, 0, false
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, 0, false
+	}
+	defer f.Close() //nolint:errcheck
+
+	if strings.EqualFold(filepath.Ext(file), ".svg") {
+		head := make([]byte, 4096)
+		n, _ := f.Read(head)
+		return svgDimensions(string(head[:n]))
+	}
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, false
+	}
+	return config.Width, config.Height, true
+}
+
+// svgDimensions returns the width and height an SVG's root tag gives it, by
+// its width and height or else its viewBox.
+func svgDimensions(svg string) (int, int, bool) {
+	tag := svgRegex.FindString(svg)
+	var w, h float64
+	var box []string
+	for _, m := range sizeRegex.FindAllStringSubmatch(tag, -1) {
+		switch m[1] {
+		case "width":
+			w = length(m[2])
+		case "height":
+			h = length(m[2])
+		default:
+			box = strings.Fields(strings.ReplaceAll(m[2], ",", " "))
+		}
+	}
+	if (w == 0 || h == 0) && len(box) == 4 {
+		w, h = length(box[2]), length(box[3])
+	}
+	if w <= 0 || h <= 0 {
+		return 0, 0, false
+	}
+	return int(w + 0.5), int(h + 0.5), true
+}
+
+// length returns an SVG length in pixels, or 0 if it's relative, such as a
+// percentage.
+func length(s string) float64 {
+	n, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "px"), 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
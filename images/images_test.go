@@ -0,0 +1,102 @@
+package images
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func writePNG(t *testing.T, path string, w, h int) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() //nolint:errcheck
+	if err := png.Encode(f, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// render stands in for glamour, keeping the lines of code blocks and
+// dropping their fences.
+func render(markdown string) string {
+	var out []string
+	for _, line := range strings.Split(markdown, "\n") {
+		if line != "```" {
+			out = append(out, "  "+line)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+func TestProcess(t *testing.T) {
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "shot.png"), 64, 32)
+
+	in := "# Doc\n\n![A screenshot](shot.png)\n\nInline ![icon](icon.png) text.\n\n```\n![code](x.png)\n```\n"
+	p := NewPreprocessor(80, dir, false)
+	out := ansi.Strip(p.Restore(render(p.Process(in))))
+
+	for _, want := range []string{
+		"╭──────────────────────────────",
+		"│ A screenshot ",
+		"│ shot.png · 64×32 ",
+		"│ " + filepath.Join(dir, "shot.png"),
+		"Inline ![icon](icon.png) text.",
+		"![code](x.png)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, placeholderPrefix) {
+		t.Errorf("expected placeholders restored:\n%s", out)
+	}
+}
+
+func TestProcessLinked(t *testing.T) {
+	p := NewPreprocessor(60, "https://example.com/docs/", true)
+	out := p.Restore(render(p.Process("[![](img/logo.svg?v=2)](https://example.com)")))
+	plain := ansi.Strip(out)
+	for _, want := range []string{"│ Image ", "│ logo.svg ", "https://example.com/docs/img/logo.svg?v=2", "│ → https://example.com "} {
+		if !strings.Contains(plain, want) {
+			t.Errorf("expected %q in output:\n%s", want, plain)
+		}
+	}
+	if !strings.Contains(out, ansi.SetHyperlink("https://example.com/docs/img/logo.svg?v=2")) {
+		t.Errorf("expected a hyperlink to the source:\n%q", out)
+	}
+}
+
+func TestProcessOff(t *testing.T) {
+	in := "![A](a.png)\n"
+	if got := NewPreprocessor(0, ".", false).Process(in); got != in {
+		t.Errorf("Process() = %q, want it unchanged", got)
+	}
+}
+
+func TestSVGDimensions(t *testing.T) {
+	tests := []struct {
+		svg  string
+		w, h int
+		ok   bool
+	}{
+		{`<svg width="120" height="40px">`, 120, 40, true},
+		{`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 24.5 12">`, 25, 12, true},
+		{`<svg width="100%" viewBox="0,0,300,150">`, 300, 150, true},
+		{`<svg width="100%">`, 0, 0, false},
+		{`not svg`, 0, 0, false},
+	}
+	for _, tt := range tests {
+		w, h, ok := svgDimensions(tt.svg)
+		if w != tt.w || h != tt.h || ok != tt.ok {
+			t.Errorf("svgDimensions(%q) = %d, %d, %v, want %d, %d, %v", tt.svg, w, h, ok, tt.w, tt.h, tt.ok)
+		}
+	}
+}
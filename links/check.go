@@ -0,0 +1,181 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hholst80/glow/utils"
+)
+
+// checkers is how many links are checked at once.
+const checkers = 8
+
+// client checks remote links.
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Result is a checked link.
+type Result struct {
+	Link
+
+	// Whether the link leads nowhere
+	Broken bool
+
+	// What the link leads to, such as an HTTP status or "file"
+	Status string
+}
+
+// Check checks where links of a document lead. Relative links are resolved
+// against base, the document's URL or directory, and links to sections
+// against the headings of the document they're in.
+func Check(ctx context.Context, links []Link, markdown []byte, base string) []Result {
+	c := checker{markdown: markdown, base: base}
+
+	// Each destination is checked once
+	statuses := make(map[string]Result)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, checkers)
+	for _, l := range links {
+		mu.Lock()
+		_, checked := statuses[l.Dest]
+		statuses[l.Dest] = Result{}
+		mu.Unlock()
+		if checked {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(dest string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			broken, status := c.check(ctx, dest)
+			mu.Lock()
+			statuses[dest] = Result{Broken: broken, Status: status}
+			mu.Unlock()
+		}(l.Dest)
+	}
+	wg.Wait()
+
+	results := make([]Result, len(links))
+	for i, l := range links {
+		results[i] = statuses[l.Dest]
+		results[i].Link = l
+	}
+	return results
+}
+
+type checker struct {
+	markdown []byte
+	base     string
+
+	mu  sync.Mutex
+	ids map[string]map[string]bool // heading ids by document, "" for this one
+}
+
+// check returns whether a destination is broken, and what it leads to.
+func (c *checker) check(ctx context.Context, dest string) (bool, string) {
+	if dest == "" {
+		return true, "empty"
+	}
+	u, err := url.Parse(dest)
+	if err != nil {
+		return true, "invalid"
+	}
+	switch {
+	case u.Scheme == "http" || u.Scheme == "https":
+		return checkURL(ctx, u)
+	case u.Scheme != "":
+		return false, "skipped"
+	case u.Host != "":
+		u.Scheme = "https"
+		return checkURL(ctx, u)
+	case u.Path == "":
+		return c.checkSection("", c.markdown, u.Fragment)
+	}
+
+	if base, err := url.Parse(c.base); err == nil && (base.Scheme == "http" || base.Scheme == "https") {
+		return checkURL(ctx, base.ResolveReference(u))
+	}
+	path := filepath.FromSlash(u.Path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(c.base, path)
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, "missing"
+	} else if err != nil {
+		return true, err.Error()
+	}
+	if info.IsDir() {
+		return false, "directory"
+	}
+	if u.Fragment != "" && utils.IsMarkdownFile(path) {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return true, err.Error()
+		}
+		return c.checkSection(path, b, u.Fragment)
+	}
+	return false, "file"
+}
+
+// checkSection returns whether a document lacks a section, by its heading id.
+func (c *checker) checkSection(doc string, markdown []byte, id string) (bool, string) {
+	c.mu.Lock()
+	if c.ids == nil {
+		c.ids = make(map[string]map[string]bool)
+	}
+	ids, ok := c.ids[doc]
+	if !ok {
+		ids = headingIDs(markdown)
+		c.ids[doc] = ids
+	}
+	c.mu.Unlock()
+
+	if !ids[id] {
+		return true, "no such section"
+	}
+	return false, "section"
+}
+
+// checkURL returns whether a remote link is broken, and its HTTP status.
+// Servers that don't answer HEAD requests are asked with GET.
+func checkURL(ctx context.Context, u *url.URL) (bool, string) {
+	res, err := request(ctx, http.MethodHead, u)
+	if err == nil && (res.StatusCode == http.StatusMethodNotAllowed || res.StatusCode == http.StatusNotImplemented || res.StatusCode == http.StatusForbidden) {
+		res, err = request(ctx, http.MethodGet, u)
+	}
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return true, err.Error()
+	}
+	return res.StatusCode >= http.StatusBadRequest, strings.TrimSpace(res.Status)
+}
+
+func request(ctx context.Context, method string, u *url.URL) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "glow")
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	_ = res.Body.Close()
+	return res, nil
+}
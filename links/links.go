@@ -0,0 +1,54 @@
+// Package links finds the links of markdown documents and checks that they
+// lead somewhere.
+package links
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/hholst80/glow/document"
+)
+
+// Link is a link or image of a document.
+type Link struct {
+	// Where the link leads, as written
+	Dest string
+
+	// Text of the link, or alt text of the image
+	Text string
+
+	// Line of the document the link is on, from 1
+	Line int
+
+	// Whether the link is an image
+	Image bool
+}
+
+// Extract returns the links and images of a markdown document, in the order
+// of their lines. Reference links are resolved to their definitions.
+func Extract(markdown []byte) []Link {
+	doc := document.Parse(markdown)
+	links := make([]Link, 0, len(doc.Links)+len(doc.Images))
+	for _, l := range doc.Links {
+		links = append(links, Link{l.URL, l.Text, l.Line, false})
+	}
+	for _, l := range doc.Images {
+		links = append(links, Link{l.URL, l.Text, l.Line, true})
+	}
+	slices.SortStableFunc(links, func(a, b Link) int {
+		return cmp.Compare(a.Line, b.Line)
+	})
+	return links
+}
+
+// headingIDs returns the ids of a document's headings, which links to its
+// sections end with.
+func headingIDs(markdown []byte) map[string]bool {
+	ids := make(map[string]bool)
+	for _, h := range document.Parse(markdown).Headings {
+		if h.ID != "" {
+			ids[h.ID] = true
+		}
+	}
+	return ids
+}
@@ -0,0 +1,85 @@
+package links
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	doc := "# Title\n\n" +
+		"A [link](https://example.com) and\n" +
+		"![an *image*](logo.png).\n\n" +
+		"Auto <https://auto.example.com> and a [reference][ref].\n\n" +
+		"[ref]: docs/guide.md#setup\n"
+
+	want := []Link{
+		{"https://example.com", "link", 3, false},
+		{"logo.png", "an image", 4, true},
+		{"https://auto.example.com", "https://auto.example.com", 6, false},
+		{"docs/guide.md#setup", "reference", 6, false},
+	}
+	got := Extract([]byte(doc))
+	if len(got) != len(want) {
+		t.Fatalf("got %d links, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("link %d is %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(http.ResponseWriter, *http.Request) {})
+	mux.HandleFunc("/get-only", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "docs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "docs", "guide.md"), []byte("# Guide\n\n## Set up\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := []byte("# Local Section\n")
+	for _, tt := range []struct {
+		dest   string
+		broken bool
+		status string
+	}{
+		{ts.URL + "/ok", false, "200 OK"},
+		{ts.URL + "/get-only", false, "200 OK"},
+		{ts.URL + "/missing", true, "404 Not Found"},
+		{"docs/guide.md", false, "file"},
+		{"docs/guide.md#set-up", false, "section"},
+		{"docs/guide.md#setup", true, "no such section"},
+		{"docs", false, "directory"},
+		{"missing.md", true, "missing"},
+		{"#local-section", false, "section"},
+		{"#nowhere", true, "no such section"},
+		{"mailto:someone@example.com", false, "skipped"},
+		{"", true, "empty"},
+	} {
+		results := Check(context.Background(), []Link{{Dest: tt.dest}}, doc, dir)
+		if r := results[0]; r.Broken != tt.broken || r.Status != tt.status || r.Dest != tt.dest {
+			t.Errorf("Check(%q) = %v, %q, want %v, %q", tt.dest, r.Broken, r.Status, tt.broken, tt.status)
+		}
+	}
+
+	// Relative links of remote documents are requested
+	results := Check(context.Background(), []Link{{Dest: "ok"}, {Dest: "missing"}, {Dest: "ok"}}, doc, ts.URL+"/README.md")
+	if results[0].Broken || !results[1].Broken || results[2].Broken {
+		t.Errorf("relative remote links checked as %+v", results)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/hholst80/glow/links"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkLinks bool
+
+	linksCmd = &cobra.Command{
+		Use:     "links SOURCE",
+		Short:   "List the links of a document, and check where they lead",
+		Long:    paragraph(fmt.Sprintf("\n%s the links and images of a document with the lines they're on. With --check, remote links are requested, relative ones looked up as files and links to sections matched to headings; broken links make glow exit with an error.", keyword("List"))),
+		Example: paragraph("glow links README.md\nglow links README.md --check"),
+		Args:    cobra.ExactArgs(1),
+		RunE:    listLinks,
+	}
+)
+
+func listLinks(cmd *cobra.Command, args []string) error {
+	src, err := sourceFromArg(args[0])
+	if err != nil {
+		return err
+	}
+	defer src.reader.Close() //nolint:errcheck
+	b, err := io.ReadAll(src.reader)
+	if err != nil {
+		return fmt.Errorf("unable to read from reader: %w", err)
+	}
+
+	found := links.Extract(b)
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	if !checkLinks {
+		for _, l := range found {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", l.Line, l.Dest, l.Text)
+		}
+		return w.Flush() //nolint:wrapcheck
+	}
+
+	// Relative links lead from the document's URL, or its directory
+	base := src.URL
+	if base != "" && !isURL(base) {
+		base = filepath.Dir(base)
+	}
+	broken := 0
+	for _, r := range links.Check(cmd.Context(), found, b, base) {
+		mark := "ok"
+		if r.Broken {
+			mark = "broken"
+			broken++
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", r.Line, mark, r.Dest, r.Status)
+	}
+	if err := w.Flush(); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	switch broken {
+	case 0:
+		return nil
+	case 1:
+		return withExitCode(exitBroken, fmt.Errorf("1 of %d links is broken", len(found)))
+	default:
+		return withExitCode(exitBroken, fmt.Errorf("%d of %d links are broken", broken, len(found)))
+	}
+}
+
+func init() {
+	linksCmd.Flags().BoolVar(&checkLinks, "check", false, "check that the links lead somewhere")
+}
@@ -11,18 +11,29 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/glamour/styles"
+	"github.com/hholst80/glow/alerts"
+	"github.com/hholst80/glow/datablocks"
+	"github.com/hholst80/glow/footnotes"
+	"github.com/hholst80/glow/htmlblocks"
+	"github.com/hholst80/glow/images"
 	"github.com/hholst80/glow/mermaid"
+	"github.com/hholst80/glow/tables"
 	"github.com/hholst80/glow/ui"
 	"github.com/hholst80/glow/utils"
+	"github.com/hholst80/glow/wikilinks"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/x/ansi"
 	gap "github.com/muesli/go-app-paths"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"golang.org/x/term"
 )
@@ -41,14 +52,47 @@ var (
 	tui              bool
 	style            string
 	width            uint
+	autoWidth        bool
+	branch           string
 	showAllFiles     bool
+	showHidden       bool
+	noIgnore         bool
+	maxDepth         int
+	followSymlinks   bool
 	showLineNumbers  bool
 	showOutline      bool
+	outlineWidth     string
+	outlineDepth     int
+	mermaidMode      mermaid.Mode
+	mermaidCommand   string
+	mermaidTimeout   time.Duration
+	mermaidLimits    mermaid.Limits
+	mermaidForce     bool
+	mermaidPlain     bool
+	diagramCommands  map[string]string
+	codeFilters      map[string]mermaid.Filter
+	figures          bool
+	outlineFigures   bool
+	emoji            bool
+	formatJSON       bool
+	highlightData    bool
+	tableStrategy    tables.Strategy
+	noCache          bool
 	preserveNewLines bool
 	mouse            bool
+	scrollOff        *int
+	raw              bool
+	noColor          bool
+	language         string
+	watch            bool
+	strict           bool
+	quiet            bool
+
+	// givenFlags are the flags given on the command line, by name
+	givenFlags map[string]bool
 
 	rootCmd = &cobra.Command{
-		Use:   "glow [SOURCE|DIR]",
+		Use:   "glow [SOURCE...|DIR]",
 		Short: "Render markdown on the CLI, with pizzazz!",
 		Long: paragraph(
 			fmt.Sprintf("\nRender markdown on the CLI, %s!", keyword("with pizzazz")),
@@ -56,11 +100,21 @@ var (
 		SilenceErrors:    false,
 		SilenceUsage:     true,
 		TraverseChildren: true,
-		Args:             cobra.MaximumNArgs(1),
+		Args:             cobra.ArbitraryArgs,
 		ValidArgsFunction: func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
 			return nil, cobra.ShellCompDirectiveDefault
 		},
 		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			// glow config creates the file it's given
+			if cmd.Flag("config").Changed && cmd != configCmd {
+				if err := loadConfigFile(configFile); err != nil {
+					return err
+				}
+			}
+			// Output is left out, but errors are still shown
+			if quiet {
+				cmd.SetOut(io.Discard)
+			}
 			return validateOptions(cmd)
 		},
 		RunE: execute,
@@ -71,6 +125,9 @@ var (
 type source struct {
 	reader io.ReadCloser
 	URL    string
+
+	// Where the files of a joined document start
+	files []utils.JoinedFile
 }
 
 // sourceFromArg parses an argument and creates a readable source for it.
@@ -80,9 +137,18 @@ func sourceFromArg(arg string) (*source, error) {
 		return &source{reader: os.Stdin}, nil
 	}
 
+	// a gist URL or ID:
+	if id, ok := parseGist(arg); ok {
+		files, err := fetchGist(id)
+		if err != nil {
+			return nil, err
+		}
+		return gistSource(files), nil
+	}
+
 	// a GitHub or GitLab URL (even without the protocol):
-	src, err := readmeURL(arg)
-	if src != nil && err == nil {
+	src, readmeErr := readmeURL(arg)
+	if src != nil && readmeErr == nil {
 		// if there's an error, try next methods...
 		return src, nil
 	}
@@ -98,10 +164,13 @@ func sourceFromArg(arg string) (*source, error) {
 			if err != nil {
 				return nil, fmt.Errorf("unable to get url: %w", err)
 			}
+			if resp.StatusCode == http.StatusNotFound {
+				return nil, withExitCode(exitNotFound, fmt.Errorf("HTTP status %d", resp.StatusCode))
+			}
 			if resp.StatusCode != http.StatusOK {
 				return nil, fmt.Errorf("HTTP status %d", resp.StatusCode)
 			}
-			return &source{resp.Body, u.String()}, nil
+			return &source{reader: resp.Body, URL: u.String()}, nil
 		}
 	}
 
@@ -125,7 +194,7 @@ func sourceFromArg(arg string) (*source, error) {
 					}
 
 					u, _ := filepath.Abs(path)
-					src = &source{r, u}
+					src = &source{reader: r, URL: u}
 
 					// abort filepath.Walk
 					return errors.New("source found")
@@ -138,18 +207,25 @@ func sourceFromArg(arg string) (*source, error) {
 			return src, nil
 		}
 
-		return nil, errors.New("missing markdown source")
+		return nil, withExitCode(exitNotFound, errors.New("missing markdown source"))
 	}
 
 	r, err := os.Open(arg)
 	if err != nil {
+		// the argument named a repository after all
+		if readmeErr != nil {
+			return nil, readmeErr
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, withExitCode(exitNotFound, fmt.Errorf("unable to open file: %w", err))
+		}
 		return nil, fmt.Errorf("unable to open file: %w", err)
 	}
 	u, err := filepath.Abs(arg)
 	if err != nil {
 		return nil, fmt.Errorf("unable to get absolute path: %w", err)
 	}
-	return &source{r, u}, nil
+	return &source{reader: r, URL: u}, nil
 }
 
 // validateStyle checks if the style is a default style, if not, checks that
@@ -168,18 +244,79 @@ func validateStyle(style string) error {
 
 func validateOptions(cmd *cobra.Command) error {
 	// grab config values from Viper
-	width = viper.GetUint("width")
+	var err error
+	if width, autoWidth, err = parseWidth(viper.GetString("width")); err != nil {
+		return err
+	}
 	mouse = viper.GetBool("mouse")
 	pager = viper.GetBool("pager")
 	tui = viper.GetBool("tui")
 	showAllFiles = viper.GetBool("all")
+	showHidden = viper.GetBool("showHidden")
+	noIgnore = viper.GetBool("noIgnore")
+	maxDepth = viper.GetInt("maxDepth")
+	followSymlinks = viper.GetBool("followSymlinks")
 	preserveNewLines = viper.GetBool("preserveNewLines")
 	showLineNumbers = viper.GetBool("showLineNumbers")
 	showOutline = viper.GetBool("showOutline")
+	outlineWidth = viper.GetString("outlineWidth")
+	outlineDepth = viper.GetInt("outlineDepth")
+	// 0 keeps no lines above headings, so only an unset value is the default
+	scrollOff = nil
+	if viper.IsSet("scrollOff") {
+		n := viper.GetInt("scrollOff")
+		scrollOff = &n
+	}
+	mermaidCommand = viper.GetString("mermaidCommand")
+	diagramCommands = configStringMap("diagramCommands")
+	if codeFilters, err = configCodeFilters(); err != nil {
+		return err
+	}
+	figures = viper.GetBool("figures")
+	outlineFigures = viper.GetBool("outlineFigures")
+	emoji = viper.GetBool("emoji")
+	formatJSON = viper.GetBool("formatJSON")
+	highlightData = viper.GetBool("highlightData")
+	if tableStrategy, err = tables.ParseStrategy(viper.GetString("tables")); err != nil {
+		return err
+	}
+	mode, err := mermaid.ParseMode(viper.GetString("mermaid"))
+	if err != nil {
+		return err
+	}
+	mermaidMode = mode
+	mermaidTimeout = viper.GetDuration("mermaidTimeout")
+	mermaidForce = viper.GetBool("mermaidForce")
+	mermaidPlain = viper.GetBool("mermaidPlain")
+	mermaidLimits = mermaid.DefaultLimits()
+	if err := viper.UnmarshalKey("mermaidLimits", &mermaidLimits); err != nil {
+		return fmt.Errorf("error parsing mermaid limits: %w", err)
+	}
+	noCache = viper.GetBool("noCache")
+	noColor = viper.GetBool("noColor")
 
 	if pager && tui {
 		return errors.New("cannot use both pager and tui")
 	}
+	if raw && tui {
+		return errors.New("cannot use both raw and tui")
+	}
+	if watch && (pager || tui) {
+		return errors.New("cannot use watch with pager or tui")
+	}
+	if quiet && (pager || tui || watch) {
+		return errors.New("cannot use quiet with pager, tui or watch")
+	}
+
+	if err := ui.ValidateOutlineWidth(outlineWidth); err != nil {
+		return err
+	}
+	if outlineDepth < 0 || outlineDepth > 6 {
+		return fmt.Errorf("invalid outline depth %d: must be from 1 to 6, or 0 for all headings", outlineDepth)
+	}
+
+	givenFlags = map[string]bool{}
+	cmd.Flags().Visit(func(f *pflag.Flag) { givenFlags[f.Name] = true })
 
 	// validate the glamour style
 	style = viper.GetString("style")
@@ -195,7 +332,13 @@ func validateOptions(cmd *cobra.Command) error {
 	}
 
 	// Detect terminal width
-	if !cmd.Flags().Changed("width") { //nolint:nestif
+	if autoWidth {
+		// Documents pick their width, up to the terminal's
+		width = utils.MaxAutoWidth
+		if w, _, err := term.GetSize(int(os.Stdout.Fd())); isTerminal && err == nil {
+			width = uint(w) //nolint:gosec
+		}
+	} else if !cmd.Flags().Changed("width") { //nolint:nestif
 		if isTerminal && width == 0 {
 			w, _, err := term.GetSize(int(os.Stdout.Fd()))
 			if err == nil {
@@ -225,6 +368,10 @@ func stdinIsPipe() (bool, error) {
 }
 
 func execute(cmd *cobra.Command, args []string) error {
+	if watch {
+		return watchCLI(cmd, args, cmd.OutOrStdout())
+	}
+
 	// if stdin is a pipe then use stdin for input. note that you can also
 	// explicitly use a - to read from stdin.
 	if yes, err := stdinIsPipe(); err != nil {
@@ -232,13 +379,13 @@ func execute(cmd *cobra.Command, args []string) error {
 	} else if yes {
 		src := &source{reader: os.Stdin}
 		defer src.reader.Close() //nolint:errcheck
-		return executeCLI(cmd, src, os.Stdout)
+		return executeStdin(cmd, src, cmd.OutOrStdout())
 	}
 
 	switch len(args) {
 	// TUI running on cwd
 	case 0:
-		return runTUI("", "")
+		return runTUI("", "", nil, nil)
 
 	// TUI with possible dir argument
 	case 1:
@@ -248,24 +395,34 @@ func execute(cmd *cobra.Command, args []string) error {
 		if err == nil && info.IsDir() {
 			p, err := filepath.Abs(args[0])
 			if err == nil {
-				return runTUI(p, "")
+				return runTUI(p, "", nil, nil)
 			}
 		}
 		fallthrough
 
 	// CLI
 	default:
-		for _, arg := range args {
-			if err := executeArg(cmd, arg, os.Stdout); err != nil {
-				return err
+		if len(args) > 1 {
+			if tabs, ok := localFiles(args); ok && tui {
+				return runTUI("", "", nil, nil, tabs...)
 			}
+			return executeArgs(cmd, args, cmd.OutOrStdout())
 		}
+		return executeArg(cmd, args[0], cmd.OutOrStdout())
 	}
-
-	return nil
 }
 
 func executeArg(cmd *cobra.Command, arg string, w io.Writer) error {
+	// stdin is rendered as it arrives
+	if arg == "-" {
+		return executeStdin(cmd, &source{reader: os.Stdin}, w)
+	}
+
+	// the files of a gist are listed in the TUI
+	if id, ok := parseGist(arg); ok && tui {
+		return browseGist(cmd, id, w)
+	}
+
 	// create an io.Reader from the markdown source in cli-args
 	src, err := sourceFromArg(arg)
 	if err != nil {
@@ -275,12 +432,102 @@ func executeArg(cmd *cobra.Command, arg string, w io.Writer) error {
 	return executeCLI(cmd, src, w)
 }
 
+// localFiles returns the absolute paths of arguments that are all local files,
+// which the TUI opens as tabs. Others are joined into one document.
+func localFiles(args []string) ([]string, bool) {
+	paths := make([]string, 0, len(args))
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil || info.IsDir() {
+			return nil, false
+		}
+		p, err := filepath.Abs(arg)
+		if err != nil {
+			return nil, false
+		}
+		paths = append(paths, p)
+	}
+	return paths, true
+}
+
+// executeArgs renders several markdown sources as one document, each opened
+// by its name.
+func executeArgs(cmd *cobra.Command, args []string, w io.Writer) error {
+	files := make([]utils.File, 0, len(args))
+	for _, arg := range args {
+		src, err := sourceFromArg(arg)
+		if err != nil {
+			return err
+		}
+		b, err := io.ReadAll(src.reader)
+		_ = src.reader.Close()
+		if err != nil {
+			return fmt.Errorf("unable to read from reader: %w", err)
+		}
+
+		// name the README found in a directory
+		name := arg
+		if info, err := os.Stat(arg); err == nil && info.IsDir() {
+			name = filepath.Join(arg, filepath.Base(src.URL))
+		}
+		files = append(files, utils.File{Name: name, Content: b})
+	}
+
+	joined, starts := utils.JoinFiles(files)
+	src := &source{reader: io.NopCloser(strings.NewReader(joined)), files: starts}
+	return executeCLI(cmd, src, w)
+}
+
 func executeCLI(cmd *cobra.Command, src *source, w io.Writer) error {
+	// The TUI reads local files itself, rendering long ones as they're
+	// scrolled through, so they aren't rendered here first
+	if tui && !pager && src.URL != "" && !isURL(src.URL) {
+		return runTUI(src.URL, "", nil, nil)
+	}
+
+	var content, out string
+	if raw {
+		b, err := io.ReadAll(src.reader)
+		if err != nil {
+			return fmt.Errorf("unable to read from reader: %w", err)
+		}
+		content, out = string(b), string(b)
+	} else {
+		var err error
+		content, out, err = renderCLI(cmd, src)
+		if err != nil {
+			return err
+		}
+		if noColor {
+			out = ansi.Strip(out)
+		}
+	}
+
+	// display
+	switch {
+	case pager:
+		return runPager(out)
+	case tui:
+		return runTUI("", content, src.files, nil)
+	default:
+		if _, err := fmt.Fprint(w, out); err != nil {
+			return fmt.Errorf("unable to write to writer: %w", err)
+		}
+		return nil
+	}
+}
+
+// renderCLI renders a markdown source for the terminal. It returns the
+// source with its diagrams preprocessed, and the rendered output.
+func renderCLI(cmd *cobra.Command, src *source) (string, string, error) {
 	b, err := io.ReadAll(src.reader)
 	if err != nil {
-		return fmt.Errorf("unable to read from reader: %w", err)
+		return "", "", fmt.Errorf("unable to read from reader: %w", err)
 	}
 
+	b = utils.DocumentContent(src.URL, b)
+	strategy := tables.DocumentStrategy(b, tableStrategy)
+	docOptions := utils.FrontmatterRenderOptions(b)
 	b = utils.RemoveFrontmatter(b)
 
 	// render
@@ -291,83 +538,257 @@ func executeCLI(cmd *cobra.Command, src *source, w io.Writer) error {
 		baseURL = u.String() + "/"
 	}
 
-	isCode := !utils.IsMarkdownFile(src.URL)
+	lang, isCode := utils.CodeLanguage(src.URL, language)
+	content := string(b)
+	if isCode {
+		content = utils.WrapCodeBlock(string(b), lang)
+	}
+	wrap := int(width) //nolint:gosec
+	if autoWidth {
+		wrap = utils.AutoWidth(content, wrap)
+	}
+
+	// The document's front matter may set its own style, width and mermaid
+	// mode, which options given on the command line override. Its style
+	// isn't used for output that doesn't go to a terminal.
+	docStyle := style
+	if docOptions.Style != "" && style != styles.NoTTYStyle && !cmd.Flags().Changed("style") {
+		docStyle = docOptions.Style
+	}
+	if docOptions.Width > 0 && !cmd.Flags().Changed("width") {
+		wrap = docOptions.Width
+		if w, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && w > 0 {
+			wrap = min(wrap, w)
+		}
+	}
+	docMermaidMode := mermaidMode
+	if !cmd.Flags().Changed("mermaid") {
+		docMermaidMode = mermaid.DocumentMode(docOptions.Mermaid, mermaidMode)
+	}
 
 	// initialize glamour
-	r, err := glamour.NewTermRenderer(
+	options := []glamour.TermRendererOption{
 		glamour.WithColorProfile(lipgloss.ColorProfile()),
-		utils.GlamourStyle(style, isCode),
-		glamour.WithWordWrap(int(width)), //nolint:gosec
+		utils.GlamourStyle(docStyle, isCode),
+		glamour.WithWordWrap(wrap),
 		glamour.WithBaseURL(baseURL),
 		glamour.WithPreservedNewLines(),
-	)
+	}
+	if emoji {
+		options = append(options, glamour.WithEmoji())
+	}
+	r, err := glamour.NewTermRenderer(options...)
 	if err != nil {
-		return fmt.Errorf("unable to create renderer: %w", err)
+		return "", "", withExitCode(exitRender, fmt.Errorf("unable to create renderer: %w", err))
 	}
 
-	content := string(b)
-	ext := filepath.Ext(src.URL)
+	// Pipe code blocks through their filters, whose output may hold diagrams
+	filters := mermaid.NewRegistry()
+	filters.RegisterFilters(codeFilters)
+	content = mermaid.NewFilterPreprocessor(filters, wrap, docStyle).Process(content)
+
+	// Preprocess mermaid diagrams before rendering. Terminals that can show
+	// images get the diagrams as images, unless the output goes to a pager.
+	// A configured external command takes precedence over both.
+	ascii := mermaid.NewRenderer()
+	ascii.SetLimits(mermaidLimits)
+	if mermaidForce {
+		ascii.Force()
+	}
+	if mermaidPlain {
+		ascii.Plain()
+	}
+	var diagramRenderer mermaid.Renderer = ascii
+	diagramTimeout := mermaid.DefaultRenderTimeout
+	if images := mermaid.NewImageRenderer(mermaid.DetectImageProtocol()); docMermaidMode == mermaid.ModeImage && !pager && !noColor && term.IsTerminal(int(os.Stdout.Fd())) && images.Available() {
+		diagramRenderer = images
+		diagramTimeout = mermaid.DefaultCommandTimeout
+	}
+	if mermaidCommand != "" {
+		diagramRenderer = mermaid.NewCommandRenderer(mermaidCommand)
+		diagramTimeout = mermaid.DefaultCommandTimeout
+	}
+	if mermaidTimeout > 0 {
+		diagramTimeout = mermaidTimeout
+	}
+	if dir := diagramCacheDir(); dir != "" {
+		diagramRenderer = mermaid.NewCachedRenderer(diagramRenderer, dir)
+	}
+	diagrams := mermaid.NewPreprocessor(diagramRenderer, wrap, docStyle)
+	diagrams.SetTimeout(diagramTimeout)
+	languages := mermaid.NewRegistry()
+	languages.RegisterCommands(diagramCommands, diagramCacheDir())
+	diagrams.AddLanguages(languages)
+	if figures {
+		diagrams.NumberFigures()
+	}
+	if docMermaidMode == mermaid.ModeOff {
+		diagrams.LeaveMermaid()
+	}
+	content = diagrams.Process(content)
+	if failures := diagrams.Failures(); strict && len(failures) == 1 {
+		return "", "", withExitCode(exitDiagram, fmt.Errorf("a diagram failed to render: %w", failures[0]))
+	} else if strict && len(failures) > 1 {
+		return "", "", withExitCode(exitDiagram, fmt.Errorf("%d diagrams failed to render: %w", len(failures), errors.Join(failures...)))
+	}
+
+	if isCode {
+		strategy = tables.StrategyOff
+	}
+	wide := tables.NewPreprocessor(wrap, strategy)
+	data := datablocks.NewPreprocessor(formatJSON && !isCode, highlightData && !isCode)
+	// Sections of <details> can't be unfolded here, so they're shown open
+	showDetails := func(int, htmlblocks.Details) bool { return true }
+	// Images are shown as boxes, with their sources as links where the
+	// terminal shows the output itself
+	imageBase := baseURL
+	if !isURL(src.URL) {
+		imageBase = filepath.Dir(src.URL)
+	}
+	imageWidth := wrap
 	if isCode {
-		content = utils.WrapCodeBlock(string(b), ext)
+		imageWidth = 0
+	}
+	pictures := images.NewPreprocessor(imageWidth, imageBase, !pager && !noColor && term.IsTerminal(int(os.Stdout.Fd())))
+	out, err := r.Render(alerts.Process(footnotes.Process(wikilinks.Process(wide.Process(tables.ProcessCSV(pictures.Process(htmlblocks.Process(data.Process(content), showDetails))))))))
+	if err != nil {
+		return "", "", withExitCode(exitRender, fmt.Errorf("unable to render markdown: %w", err))
 	}
+	return content, alerts.Restore(pictures.Restore(wide.Restore(data.Restore(diagrams.Restore(out))))), nil
+}
 
-	// Preprocess mermaid diagrams before rendering
-	content = mermaid.ProcessMarkdown(content, int(width)) //nolint:gosec
+// runPager shows rendered output in the pagerCommand setting, $PAGER, or
+// less.
+func runPager(out string) error {
+	pa, env := pagerCommand()
+	c := exec.Command(pa[0], pa[1:]...) //nolint:gosec
+	c.Env = append(os.Environ(), env...)
+	c.Stdin = strings.NewReader(out)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("unable to run command: %w", err)
+	}
+	return nil
+}
 
-	out, err := r.Render(content)
-	if err != nil {
-		return fmt.Errorf("unable to render markdown: %w", err)
+// pagerCommand returns the pager the rendered output is piped through, such
+// as "bat --paging=always", and the environment it's run with. The
+// pagerCommand setting, or $GLOW_PAGER_COMMAND, takes precedence over $PAGER,
+// and less is told to keep the colors, unless $LESS is set, as git does.
+func pagerCommand() ([]string, []string) {
+	pagerCmd := viper.GetString("pagerCommand")
+	if pagerCmd == "" {
+		pagerCmd = os.Getenv("PAGER")
+	}
+	pa := strings.Fields(pagerCmd)
+	if len(pa) == 0 {
+		pa = []string{"less", "-R"}
 	}
 
-	// display
-	switch {
-	case pager || cmd.Flags().Changed("pager"):
-		pagerCmd := os.Getenv("PAGER")
-		if pagerCmd == "" {
-			pagerCmd = "less -r"
-		}
+	var env []string
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		env = append(env, "LESS=R")
+	}
+	return pa, env
+}
 
-		pa := strings.Split(pagerCmd, " ")
-		c := exec.Command(pa[0], pa[1:]...) //nolint:gosec
-		c.Stdin = strings.NewReader(out)
-		c.Stdout = os.Stdout
-		if err := c.Run(); err != nil {
-			return fmt.Errorf("unable to run command: %w", err)
-		}
-		return nil
-	case tui || cmd.Flags().Changed("tui"):
-		path := ""
-		if !isURL(src.URL) {
-			path = src.URL
-		}
-		return runTUI(path, content)
-	default:
-		if _, err = fmt.Fprint(w, out); err != nil {
-			return fmt.Errorf("unable to write to writer: %w", err)
-		}
-		return nil
+// diagramCacheDir returns the directory rendered mermaid diagrams are cached
+// in, or "" when caching is disabled.
+func diagramCacheDir() string {
+	if noCache {
+		return ""
+	}
+	dir, err := gap.NewScope(gap.User, "glow").CacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "mermaid")
+}
+
+// renderCacheDir returns the directory rendered documents are cached in, or
+// "" when caching is disabled.
+func renderCacheDir() string {
+	if noCache {
+		return ""
+	}
+	dir, err := gap.NewScope(gap.User, "glow").CacheDir()
+	if err != nil {
+		return ""
 	}
+	return filepath.Join(dir, "render")
 }
 
-func runTUI(path string, content string) error {
+// runTUI runs the TUI on a directory or file, a rendered document, starting
+// with the given files if it's joined, or a stream, or on several files opened
+// as tabs.
+func runTUI(path string, content string, files []utils.JoinedFile, stream io.Reader, tabs ...string) error {
 	// Read environment to get debugging stuff
 	cfg, err := env.ParseAs[ui.Config]()
 	if err != nil {
 		return fmt.Errorf("error parsing config: %v", err)
 	}
 
-	// use style set in env, or auto if unset
-	if err := validateStyle(cfg.GlamourStyle); err != nil {
-		cfg.GlamourStyle = style
-	}
-
 	cfg.Path = path
+	cfg.Files = files
+	cfg.Stream = stream
+	cfg.Tabs = tabs
+	cfg.Flags = givenFlags
+	// Viper has settings from flags, the environment and the config file,
+	// in that order
+	cfg.GlamourStyle = style
 	cfg.ShowAllFiles = showAllFiles
+	cfg.ShowHidden = showHidden || showAllFiles
+	cfg.NoIgnore = noIgnore
+	cfg.MaxDepth = maxDepth
+	cfg.FollowSymlinks = followSymlinks
+	cfg.ShowTitles = viper.GetBool("showTitles")
+	cfg.Sort = viper.GetString("sort")
+	if columns := viper.GetStringSlice("columns"); len(columns) > 0 {
+		cfg.Columns = strings.Join(columns, ",")
+	}
+	cfg.SaveSort = func(sort string) error {
+		return saveConfigValue("sort", sort)
+	}
 	cfg.ShowLineNumbers = showLineNumbers
 	cfg.ShowOutline = showOutline
+	cfg.OutlineWidth = outlineWidth
+	cfg.OutlineDepth = outlineDepth
+	cfg.Language = language
+	cfg.ScrollOff = scrollOff
 	cfg.GlamourMaxWidth = width
+	cfg.AutoWidth = autoWidth
 	cfg.EnableMouse = mouse
 	cfg.PreserveNewLines = preserveNewLines
+	cfg.MermaidCommand = mermaidCommand
+	cfg.DiagramCommands = diagramCommands
+	cfg.CodeFilters = codeFilters
+	cfg.MermaidMode = mermaidMode
+	cfg.DiagramCacheDir = diagramCacheDir()
+	cfg.RenderCacheDir = renderCacheDir()
+	if file, err := gap.NewScope(gap.User, "glow").DataPath("favorites"); err == nil {
+		cfg.FavoritesFile = file
+	}
+	if file, err := gap.NewScope(gap.User, "glow").DataPath("tags.json"); err == nil {
+		cfg.TagsFile = file
+	}
+	if file, err := gap.NewScope(gap.User, "glow").DataPath("history.json"); err == nil {
+		cfg.HistoryFile = file
+	}
+	cfg.MermaidLimits = &mermaidLimits
+	cfg.MermaidForce = mermaidForce
+	cfg.MermaidPlain = mermaidPlain
+	cfg.Figures = figures
+	cfg.Emoji = emoji
+	cfg.FormatJSON = formatJSON
+	cfg.HighlightData = highlightData
+	cfg.Tables = tableStrategy
+	cfg.OutlineFigures = outlineFigures
+	cfg.MermaidTimeout = mermaidTimeout
+	if err := viper.UnmarshalKey("theme", &cfg.Theme); err != nil {
+		return fmt.Errorf("error parsing theme: %w", err)
+	}
+	cfg.Keys = viper.GetStringMapStringSlice("keys")
 
 	// Run Bubble Tea program
 	if _, err := ui.NewProgram(cfg, content).Run(); err != nil {
@@ -385,7 +806,7 @@ func main() {
 	}
 	if err := rootCmd.Execute(); err != nil {
 		_ = closer()
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 	_ = closer()
 }
@@ -416,17 +837,35 @@ func init() {
 	rootCmd.InitDefaultCompletionCmd()
 
 	// "Glow Classic" cli arguments
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "print nothing but errors, e.g. to check documents in CI")
 	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", fmt.Sprintf("config file (default %s)", viper.GetViper().ConfigFileUsed()))
-	rootCmd.Flags().BoolVarP(&pager, "pager", "p", false, "display with pager")
+	rootCmd.Flags().BoolVarP(&pager, "pager", "p", false, "display with $GLOW_PAGER_COMMAND, $PAGER or less")
 	rootCmd.Flags().BoolVarP(&tui, "tui", "t", false, "display with tui")
 	rootCmd.Flags().StringVarP(&style, "style", "s", styles.AutoStyle, "style name or JSON path")
-	rootCmd.Flags().UintVarP(&width, "width", "w", 0, "word-wrap at width (set to 0 to disable)")
-	rootCmd.Flags().BoolVarP(&showAllFiles, "all", "a", false, "show system files and directories (TUI-mode only)")
+	rootCmd.Flags().VarP(widthValue{&width, &autoWidth}, "width", "w", "word-wrap at width, or auto to fit the document's tables and code (set to 0 to disable)")
+	rootCmd.Flags().StringVar(&branch, "branch", "", "branch, tag or commit to read GitHub repositories at")
+	rootCmd.Flags().BoolVarP(&showAllFiles, "all", "a", false, "show hidden and system files and directories, and those git ignores (TUI-mode only)")
+	rootCmd.Flags().BoolVar(&showHidden, "hidden", false, "show hidden files and directories (TUI-mode only)")
+	rootCmd.Flags().BoolVar(&noIgnore, "no-ignore", false, "show files ignored by git (TUI-mode only)")
+	rootCmd.Flags().IntVar(&maxDepth, "max-depth", 0, "how many directories deep to look for files, 0 for no limit (TUI-mode only)")
+	rootCmd.Flags().BoolVar(&followSymlinks, "follow-symlinks", false, "follow symlinked directories when looking for files (TUI-mode only)")
 	rootCmd.Flags().BoolVarP(&showLineNumbers, "line-numbers", "l", false, "show line numbers (TUI-mode only)")
 	rootCmd.Flags().BoolVarP(&showOutline, "outline", "o", false, "show outline sidebar (TUI-mode only)")
+	rootCmd.Flags().IntVar(&outlineDepth, "outline-depth", 0, "deepest heading level in the outline sidebar, 0 for all (TUI-mode only)")
+	rootCmd.Flags().StringVar(&outlineWidth, "outline-width", "", "outline sidebar width: \"auto\", columns or a percentage such as 30% (TUI-mode only)")
 	rootCmd.Flags().BoolVarP(&preserveNewLines, "preserve-new-lines", "n", false, "preserve newlines in the output")
+	rootCmd.Flags().StringVar(&language, "language", "", "language to highlight files as, over the one their extension gives, e.g. python or markdown")
+	rootCmd.Flags().BoolVar(&strict, "strict", false, "fail when a diagram can't be rendered")
+	rootCmd.Flags().BoolVar(&watch, "watch", false, "render a file again whenever it changes")
+	rootCmd.Flags().BoolVar(&raw, "raw", false, "print the markdown source as it is, without rendering it")
+	rootCmd.Flags().BoolVar(&noColor, "no-color", false, "render without colors or other escape codes, even on a terminal")
 	rootCmd.Flags().BoolVarP(&mouse, "mouse", "m", false, "enable mouse wheel (TUI-mode only)")
 	_ = rootCmd.Flags().MarkHidden("mouse")
+	rootCmd.Flags().String("mermaid", string(mermaid.DefaultMode), "show mermaid diagrams as images, ascii, or off to show their source")
+	rootCmd.Flags().BoolVar(&noCache, "no-cache", false, "don't cache rendered documents and mermaid diagrams")
+	rootCmd.Flags().BoolVar(&mermaidForce, "mermaid-force", false, "render mermaid diagrams however complex they are")
+	rootCmd.Flags().BoolVar(&mermaidPlain, "mermaid-plain", false, "draw mermaid diagrams with plain ASCII instead of box-drawing characters")
+	rootCmd.Flags().BoolVar(&figures, "figures", false, "number rendered diagrams with captions below them")
 
 	// Config bindings
 	_ = viper.BindPFlag("pager", rootCmd.Flags().Lookup("pager"))
@@ -438,13 +877,27 @@ func init() {
 	_ = viper.BindPFlag("preserveNewLines", rootCmd.Flags().Lookup("preserve-new-lines"))
 	_ = viper.BindPFlag("showLineNumbers", rootCmd.Flags().Lookup("line-numbers"))
 	_ = viper.BindPFlag("showOutline", rootCmd.Flags().Lookup("outline"))
+	_ = viper.BindPFlag("outlineDepth", rootCmd.Flags().Lookup("outline-depth"))
+	_ = viper.BindPFlag("outlineWidth", rootCmd.Flags().Lookup("outline-width"))
 	_ = viper.BindPFlag("all", rootCmd.Flags().Lookup("all"))
+	_ = viper.BindPFlag("showHidden", rootCmd.Flags().Lookup("hidden"))
+	_ = viper.BindPFlag("noIgnore", rootCmd.Flags().Lookup("no-ignore"))
+	_ = viper.BindPFlag("maxDepth", rootCmd.Flags().Lookup("max-depth"))
+	_ = viper.BindPFlag("followSymlinks", rootCmd.Flags().Lookup("follow-symlinks"))
+	_ = viper.BindPFlag("mermaid", rootCmd.Flags().Lookup("mermaid"))
+	_ = viper.BindPFlag("noCache", rootCmd.Flags().Lookup("no-cache"))
+	_ = viper.BindPFlag("mermaidForce", rootCmd.Flags().Lookup("mermaid-force"))
+	_ = viper.BindPFlag("mermaidPlain", rootCmd.Flags().Lookup("mermaid-plain"))
+	_ = viper.BindPFlag("figures", rootCmd.Flags().Lookup("figures"))
+	_ = viper.BindPFlag("noColor", rootCmd.Flags().Lookup("no-color"))
 
 	viper.SetDefault("style", styles.AutoStyle)
 	viper.SetDefault("width", 0)
-	viper.SetDefault("all", true)
+	viper.SetDefault("all", false)
+	viper.SetDefault("showTitles", true)
+	viper.SetDefault("emoji", false)
 
-	rootCmd.AddCommand(configCmd, manCmd)
+	rootCmd.AddCommand(configCmd, manCmd, mermaidCmd, exportCmd, serveCmd, diffCmd, linksCmd, parseCmd, newCmd, grepCmd)
 }
 
 func tryLoadConfigFromDefaultPlaces() {
@@ -463,6 +916,13 @@ func tryLoadConfigFromDefaultPlaces() {
 		dirs = append([]string{c}, dirs...)
 	}
 
+	// ~/.config/glow is read on every platform
+	if home, err := os.UserHomeDir(); err == nil {
+		if dir := filepath.Join(home, ".config", "glow"); !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+
 	for _, v := range dirs {
 		viper.AddConfigPath(v)
 	}
@@ -471,6 +931,7 @@ func tryLoadConfigFromDefaultPlaces() {
 	viper.SetConfigType("yaml")
 	viper.SetEnvPrefix("glow")
 	viper.AutomaticEnv()
+	bindConfigEnv()
 
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
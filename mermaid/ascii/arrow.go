@@ -111,10 +111,30 @@ func (g *graph) drawArrow(from gridCoord, to gridCoord, e *edge) (*drawing, *dra
 	}
 	log.Debugf("Drawing arrow from %v to %v with path %v", from, to, e.path)
 	dLabel := g.drawArrowLabel(e)
-	dPath, linesDrawn, lineDirs := g.drawPath(e.path)
-	dBoxStart := g.drawBoxStart(e.path, linesDrawn[0])
-	dArrowHead := g.drawArrowHead(linesDrawn[len(linesDrawn)-1], lineDirs[len(lineDirs)-1])
-	dCorners := g.drawCorners(e.path)
+	dPath, linesDrawn, lineDirs := g.drawPath(e.path, e.style.line)
+	var dBoxStart *drawing
+	if e.style.arrowStart {
+		// An arrowhead at the start points back into the source node
+		firstLine := slices.Clone(linesDrawn[0])
+		slices.Reverse(firstLine)
+		dBoxStart = g.drawArrowHead(firstLine, lineDirs[0].getOpposite())
+	} else {
+		dBoxStart = g.drawBoxStart(e.path, linesDrawn[0])
+	}
+	dArrowHead := copyCanvas(g.drawing)
+	if e.style.arrowEnd {
+		dArrowHead = g.drawArrowHead(linesDrawn[len(linesDrawn)-1], lineDirs[len(lineDirs)-1])
+	}
+	dCorners := g.drawCorners(e.path, e.style.line)
+
+	// Remember the drawn cells so linkStyle colors can be applied later
+	e.cells = nil
+	for _, line := range linesDrawn {
+		e.cells = append(e.cells, line...)
+	}
+	for _, coord := range e.path[1 : len(e.path)-1] {
+		e.cells = append(e.cells, g.gridToDrawingCoord(coord, nil))
+	}
 	return dPath, dBoxStart, dArrowHead, dCorners, dLabel
 }
 
@@ -145,7 +165,7 @@ func mergePath(path []gridCoord) []gridCoord {
 	return newPath
 }
 
-func (g *graph) drawPath(path []gridCoord) (*drawing, [][]drawingCoord, []direction) {
+func (g *graph) drawPath(path []gridCoord, style lineStyle) (*drawing, [][]drawingCoord, []direction) {
 	d := copyCanvas(g.drawing)
 	previousCoord := path[0]
 	linesDrawn := make([][]drawingCoord, 0)
@@ -164,6 +184,8 @@ func (g *graph) drawPath(path []gridCoord) (*drawing, [][]drawingCoord, []direct
 			// drawLine may return no coords if offsets collapse the line. Use at least one point so arrow and junction logic
 			// can still infer a direction.
 			s = append(s, previousDrawingCoord)
+		} else if style != lineSolid {
+			g.restyleLine(d, s, dir, style)
 		}
 		linesDrawn = append(linesDrawn, s)
 		lineDirs = append(lineDirs, dir)
@@ -172,13 +194,26 @@ func (g *graph) drawPath(path []gridCoord) (*drawing, [][]drawingCoord, []direct
 	return d, linesDrawn, lineDirs
 }
 
+// restyleLine redraws a straight line segment with the characters of a
+// dotted or thick stroke.
+func (g *graph) restyleLine(d *drawing, line []drawingCoord, dir direction, style lineStyle) {
+	h, v := lineChars(style, g.options.UseAscii)
+	char := h
+	if dir == Up || dir == Down {
+		char = v
+	}
+	for _, c := range line {
+		(*d)[c.x][c.y] = char
+	}
+}
+
 func (g *graph) drawBoxStart(path []gridCoord, firstLine []drawingCoord) *drawing {
 	d := *(copyCanvas(g.drawing))
 	from := firstLine[0]
 	dir := determineDirection(genericCoord(path[0]), genericCoord(path[1]))
 	log.Debugf("Drawing box start at %v with direction %v for line %v", from, dir, path)
 
-	if g.useAscii {
+	if g.options.UseAscii {
 		return &d
 	}
 
@@ -208,7 +243,7 @@ func (g *graph) drawArrowHead(line []drawingCoord, fallback direction) *drawing
 	}
 
 	var char string
-	if !g.useAscii {
+	if !g.options.UseAscii {
 		switch dir {
 		case Up:
 			char = "▲"
@@ -278,7 +313,7 @@ func (g *graph) drawArrowHead(line []drawingCoord, fallback direction) *drawing
 	return &d
 }
 
-func (g *graph) drawCorners(path []gridCoord) *drawing {
+func (g *graph) drawCorners(path []gridCoord, style lineStyle) *drawing {
 	d := copyCanvas(g.drawing)
 	for idx, coord := range path {
 		// Skip the first and last step
@@ -291,7 +326,7 @@ func (g *graph) drawCorners(path []gridCoord) *drawing {
 		nextDir := determineDirection(genericCoord(coord), genericCoord(path[idx+1]))
 
 		var corner string
-		if !g.useAscii {
+		if !g.options.UseAscii {
 			switch {
 			case (prevDir == Right && nextDir == Down) || (prevDir == Up && nextDir == Left):
 				corner = "┐"
@@ -304,6 +339,9 @@ func (g *graph) drawCorners(path []gridCoord) *drawing {
 			default:
 				corner = "+"
 			}
+			if style == lineThick {
+				corner = thickCorners[corner]
+			}
 		} else {
 			corner = "+"
 		}
@@ -315,39 +353,83 @@ func (g *graph) drawCorners(path []gridCoord) *drawing {
 
 func (g *graph) drawArrowLabel(e *edge) *drawing {
 	d := copyCanvas(g.drawing)
-	lenLabel := len(e.text)
+	lenLabel := textWidth(e.text)
 	if lenLabel == 0 {
 		return d
 	}
 
 	log.Debugf("Drawing text '%s' on gridline %v", e.text, e.labelLine)
-	d.drawTextOnLine(g.lineToDrawing(e.labelLine), e.text)
+	start := g.labelPosition(g.lineToDrawing(e.labelLine), e.text)
+	d.drawText(start, e.text)
+	g.labels = append(g.labels, labelBox{start: start, width: lenLabel})
+	e.textCells = nil
+	for x := 0; x < lenLabel; x++ {
+		e.textCells = append(e.textCells, drawingCoord{start.x + x, start.y})
+	}
 	return d
 }
 
-func (d *drawing) drawTextOnLine(line []drawingCoord, label string) {
+// labelBox is the area taken by an edge label that has been placed.
+type labelBox struct {
+	start drawingCoord
+	width int
+}
+
+// labelPosition returns where to write a label on a line. The label is
+// centered on the line; if that would overwrite a node or another label it is
+// moved along the line, or next to it for horizontal lines.
+func (g *graph) labelPosition(line []drawingCoord, label string) drawingCoord {
 	// Write text in middle of the line
 	//  123456789
 	// |---------|
 	//     123
 	log.Debugf("Drawing text '%s' on drawingline %v", label, line)
-	var minX, maxX, minY, maxY int
-	if line[0].x > line[1].x {
-		minX = line[1].x
-		maxX = line[0].x
+	minX, maxX := Min(line[0].x, line[1].x), Max(line[0].x, line[1].x)
+	minY, maxY := Min(line[0].y, line[1].y), Max(line[0].y, line[1].y)
+	middleX := minX + (maxX-minX)/2
+	middleY := minY + (maxY-minY)/2
+	x := Max(0, middleX-textWidth(label)/2)
+
+	candidates := []drawingCoord{{x, middleY}}
+	if minX == maxX {
+		// Vertical line: try the other rows of the line, nearest first
+		for offset := 1; offset <= (maxY-minY)/2+1; offset++ {
+			candidates = append(candidates, drawingCoord{x, middleY - offset}, drawingCoord{x, middleY + offset})
+		}
 	} else {
-		minX = line[0].x
-		maxX = line[1].x
+		// Horizontal line: try just above and below it
+		candidates = append(candidates, drawingCoord{x, middleY - 1}, drawingCoord{x, middleY + 1})
 	}
-	if line[0].y > line[1].y {
-		minY = line[1].y
-		maxY = line[0].y
-	} else {
-		minY = line[0].y
-		maxY = line[1].y
+
+	for _, c := range candidates {
+		if c.y < minY-1 || c.y > maxY+1 || c.y < 0 {
+			continue
+		}
+		if !g.labelCollides(c, textWidth(label)) {
+			return c
+		}
 	}
-	middleX := minX + (maxX-minX)/2
-	middleY := minY + (maxY-minY)/2
-	startLabelCoord := drawingCoord{x: middleX - len(label)/2, y: middleY}
-	d.drawText(startLabelCoord, label)
+	return candidates[0]
+}
+
+// labelCollides reports whether a label of the given width at start would
+// overlap a node or a previously placed label.
+func (g *graph) labelCollides(start drawingCoord, width int) bool {
+	for _, n := range g.nodes {
+		if n.drawingCoord == nil || n.drawing == nil {
+			continue
+		}
+		w, h := getDrawingSize(n.drawing)
+		if start.y >= n.drawingCoord.y && start.y <= n.drawingCoord.y+h &&
+			start.x <= n.drawingCoord.x+w && start.x+width-1 >= n.drawingCoord.x {
+			return true
+		}
+	}
+	for _, l := range g.labels {
+		// Keep a one cell gap between labels on the same row
+		if start.y == l.start.y && start.x <= l.start.x+l.width && start.x+width >= l.start.x {
+			return true
+		}
+	}
+	return false
 }
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/hholst80/glow/mermaid/ascii/diagram"
+	"github.com/hholst80/glow/mermaid/ascii/quadrant"
 	"github.com/hholst80/glow/mermaid/ascii/sequence"
 )
 
@@ -15,6 +16,10 @@ func DiagramFactory(input string) (diagram.Diagram, error) {
 		return &SequenceDiagram{}, nil
 	}
 
+	if quadrant.IsQuadrantChart(input) {
+		return &QuadrantDiagram{}, nil
+	}
+
 	lines := strings.Split(input, "\n")
 	for _, line := range lines {
 		trimmed := strings.TrimSpace(line)
@@ -49,13 +54,54 @@ func (sd *SequenceDiagram) Render(config *diagram.Config) (string, error) {
 	if sd.parsed == nil {
 		return "", fmt.Errorf("sequence diagram not parsed: call Parse() before Render()")
 	}
-	return sequence.Render(sd.parsed, config)
+	output, err := sequence.Render(sd.parsed, config)
+	if err != nil || config == nil {
+		return output, err
+	}
+	if config.MaxWidth > 0 && diagram.MaxLineWidth(output) > config.MaxWidth {
+		// Bring the participants closer together and wrap long labels
+		narrow := *config
+		narrow.SequenceParticipantSpacing = 1
+		narrow.SequenceWrap = true
+		if output, err = sequence.Render(sd.parsed, &narrow); err != nil {
+			return "", err
+		}
+	}
+	return config.Palette.Paint(output, config.UseAscii), nil
 }
 
 func (sd *SequenceDiagram) Type() string {
 	return "sequence"
 }
 
+type QuadrantDiagram struct {
+	parsed *quadrant.QuadrantChart
+}
+
+func (qd *QuadrantDiagram) Parse(input string) error {
+	parsed, err := quadrant.Parse(input)
+	if err != nil {
+		return err
+	}
+	qd.parsed = parsed
+	return nil
+}
+
+func (qd *QuadrantDiagram) Render(config *diagram.Config) (string, error) {
+	if qd.parsed == nil {
+		return "", fmt.Errorf("quadrant chart not parsed: call Parse() before Render()")
+	}
+	output, err := quadrant.Render(qd.parsed, config)
+	if err != nil || config == nil {
+		return output, err
+	}
+	return config.Palette.Paint(output, config.UseAscii), nil
+}
+
+func (qd *QuadrantDiagram) Type() string {
+	return "quadrant"
+}
+
 type GraphDiagram struct {
 	properties *graphProperties
 }
@@ -83,9 +129,16 @@ func (gd *GraphDiagram) Render(config *diagram.Config) (string, error) {
 		styleType = "cli"
 	}
 	gd.properties.styleType = styleType
-	gd.properties.useAscii = config.UseAscii
+	gd.properties.options = gd.properties.renderOptions(config)
+	gd.properties.labelWidth = config.MaxLabelWidth
+	if styleType == "cli" {
+		gd.properties.palette = config.Palette
+	}
+	if config.DirectionOverride != "" && !gd.properties.setDirection(config.DirectionOverride) {
+		return "", fmt.Errorf("unsupported direction %q", config.DirectionOverride)
+	}
 
-	return drawMap(gd.properties), nil
+	return drawMapFitting(gd.properties, config.MaxWidth), nil
 }
 
 func (gd *GraphDiagram) Type() string {
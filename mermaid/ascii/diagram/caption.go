@@ -0,0 +1,164 @@
+package diagram
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/reflow/wordwrap"
+)
+
+var (
+	// accTitleRegex matches accTitle: Title
+	accTitleRegex = regexp.MustCompile(`^\s*accTitle\s*:\s*(.*?)\s*$`)
+
+	// accDescrRegex matches accDescr: Description
+	accDescrRegex = regexp.MustCompile(`^\s*accDescr\s*:\s*(.*?)\s*$`)
+
+	// accDescrBlockRegex matches the start of accDescr { ... }, which may
+	// span several lines
+	accDescrBlockRegex = regexp.MustCompile(`^\s*accDescr\s*\{(.*)$`)
+
+	// titleRegex matches the title keyword: title Title
+	titleRegex = regexp.MustCompile(`^\s*title(?:\s*:\s*|\s+)(.*?)\s*$`)
+
+	// frontMatterTitleRegex matches the title in front matter: title: Title
+	frontMatterTitleRegex = regexp.MustCompile(`^title\s*:\s*(.*?)\s*$`)
+)
+
+// Captions holds a diagram's title and accessibility descriptions, which
+// are shown around the diagram rather than in it.
+type Captions struct {
+	// Title is set by the title keyword or in front matter
+	Title string
+
+	// AccTitle is set by accTitle
+	AccTitle string
+
+	// AccDescr is set by accDescr, and may span several lines
+	AccDescr string
+}
+
+// ParseCaptions extracts the title, accTitle and accDescr of a diagram from
+// input and returns them, along with the input with them and any front
+// matter blanked out. Flowcharts have no title keyword, as a node may be
+// named title, so their title is only taken from front matter.
+func ParseCaptions(input string) (Captions, string) {
+	var c Captions
+	lines := strings.Split(input, "\n")
+
+	start := 0
+	for start < len(lines) && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+	if start < len(lines) && strings.TrimSpace(lines[start]) == "---" {
+		for end := start + 1; end < len(lines); end++ {
+			if strings.TrimSpace(lines[end]) != "---" {
+				continue
+			}
+			for i := start; i <= end; i++ {
+				if match := frontMatterTitleRegex.FindStringSubmatch(strings.TrimSpace(lines[i])); match != nil {
+					c.Title = unquote(match[1])
+				}
+				lines[i] = ""
+			}
+			start = end + 1
+			break
+		}
+	}
+
+	header := ""
+	for i := start; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if header == "" {
+			header = trimmed
+		}
+		flowchart := strings.HasPrefix(header, "graph") || strings.HasPrefix(header, "flowchart")
+
+		switch {
+		case accTitleRegex.MatchString(trimmed):
+			c.AccTitle = accTitleRegex.FindStringSubmatch(trimmed)[1]
+		case accDescrRegex.MatchString(trimmed):
+			c.AccDescr = accDescrRegex.FindStringSubmatch(trimmed)[1]
+		case accDescrBlockRegex.MatchString(trimmed):
+			var descr []string
+			rest := accDescrBlockRegex.FindStringSubmatch(trimmed)[1]
+			for ; i < len(lines); i++ {
+				text, closed := strings.CutSuffix(strings.TrimSpace(rest), "}")
+				if text = strings.TrimSpace(text); text != "" {
+					descr = append(descr, text)
+				}
+				lines[i] = ""
+				if closed || i+1 == len(lines) {
+					break
+				}
+				rest = lines[i+1]
+			}
+			c.AccDescr = strings.Join(descr, "\n")
+			continue
+		case !flowchart && titleRegex.MatchString(trimmed):
+			c.Title = titleRegex.FindStringSubmatch(trimmed)[1]
+		default:
+			continue
+		}
+		lines[i] = ""
+	}
+	return c, strings.Join(lines, "\n")
+}
+
+// unquote removes the quotes around a front matter value.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// Render returns output with the title centered above it and the
+// description below. Without a title, the accTitle is shown instead. With a
+// palette, the title is bold and both take the label color.
+func (c Captions) Render(output string, config *Config) string {
+	title := c.Title
+	if title == "" {
+		title = c.AccTitle
+	}
+	if title == "" && c.AccDescr == "" {
+		return output
+	}
+
+	var b strings.Builder
+	if title != "" {
+		if config.MaxWidth > 0 {
+			title = ansi.Truncate(title, config.MaxWidth, "…")
+		}
+		indent := max(0, (MaxLineWidth(output)-ansi.StringWidth(title))/2)
+		b.WriteString(strings.Repeat(" ", indent) + c.paint(title, config, true) + "\n\n")
+	}
+	if c.AccDescr == "" {
+		b.WriteString(output)
+		return b.String()
+	}
+
+	descr := c.AccDescr
+	if config.MaxWidth > 0 {
+		descr = wordwrap.String(descr, config.MaxWidth)
+	}
+	b.WriteString(strings.TrimRight(output, "\n") + "\n")
+	for _, line := range strings.Split(descr, "\n") {
+		b.WriteString("\n" + c.paint(line, config, false))
+	}
+	return b.String()
+}
+
+// paint styles a caption line with the palette's label color.
+func (c Captions) paint(text string, config *Config, bold bool) string {
+	if config.Palette == nil {
+		return text
+	}
+	style := lipgloss.NewStyle().Bold(bold)
+	if config.Palette.Label != "" {
+		style = style.Foreground(lipgloss.Color(config.Palette.Label))
+	}
+	return style.Render(text)
+}
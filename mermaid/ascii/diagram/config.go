@@ -14,6 +14,20 @@ type Config struct {
 	// Verbose enables detailed logging
 	Verbose bool
 
+	// MaxWidth is the width the output should fit in, e.g. the terminal
+	// width; diagrams are compressed and their labels wrapped to fit. 0
+	// means unlimited
+	MaxWidth int
+
+	// MaxLabelWidth is the width node labels wrap at, making boxes taller
+	// rather than wider; labels can also be broken with <br>. 0 means
+	// unlimited
+	MaxLabelWidth int
+
+	// Palette colors boxes, arrows and labels in cli output; nil leaves
+	// them uncolored
+	Palette *Palette
+
 	// --- Graph-specific configuration ---
 
 	// BoxBorderPadding is the padding between text and border in graph nodes
@@ -32,6 +46,10 @@ type Config struct {
 	// This controls whether graphs use colored output (html) or plain text (cli)
 	StyleType string
 
+	// DirectionOverride replaces the direction declared in a graph's header
+	// ("LR", "RL", "TD", "TB" or "BT"), e.g. from an init directive
+	DirectionOverride string
+
 	// --- Sequence diagram-specific configuration ---
 
 	// SequenceParticipantSpacing is the horizontal space between participants
@@ -42,6 +60,9 @@ type Config struct {
 
 	// SequenceSelfMessageWidth is the width of self-message loops
 	SequenceSelfMessageWidth int
+
+	// SequenceWrap wraps long message labels and notes
+	SequenceWrap bool
 }
 
 // DefaultConfig returns a Config with sensible defaults.
@@ -57,6 +78,7 @@ func DefaultConfig() *Config {
 		PaddingBetweenY:  5,
 		GraphDirection:   "LR",
 		StyleType:        "cli",
+		MaxLabelWidth:    30,
 		// Sequence diagram defaults
 		SequenceParticipantSpacing: 5,
 		SequenceMessageSpacing:     1,
@@ -0,0 +1,170 @@
+package diagram
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// directiveRegex matches %%{...}%% directives, which may span several lines.
+var directiveRegex = regexp.MustCompile(`(?s)%%\{(.*?)\}%%`)
+
+// Directive holds the settings of %%{init: {...}}%% and %%{wrap}%%
+// directives that apply to terminal rendering.
+type Directive struct {
+	// Theme is the mermaid theme, e.g. "dark" or "forest"
+	Theme string
+
+	// Direction overrides the direction declared by a flowchart
+	Direction string
+
+	// Wrap wraps long sequence diagram labels
+	Wrap bool
+
+	// Colors are the themeVariables colors that have a place in the
+	// terminal palette
+	Colors Palette
+}
+
+// ParseDirectives extracts all directives from input and returns them merged,
+// along with the input with the directives blanked out. Settings that have no
+// meaning in a terminal, such as fonts and font sizes, are ignored.
+func ParseDirectives(input string) (Directive, string, error) {
+	var d Directive
+	var err error
+
+	stripped := directiveRegex.ReplaceAllStringFunc(input, func(match string) string {
+		if err != nil {
+			return ""
+		}
+		body := strings.TrimSpace(directiveRegex.FindStringSubmatch(match)[1])
+		err = d.parse(body)
+		return blankLines(match)
+	})
+	if err != nil {
+		return Directive{}, input, err
+	}
+	return d, stripped, nil
+}
+
+func (d *Directive) parse(body string) error {
+	name, args, _ := strings.Cut(body, ":")
+	switch strings.TrimSpace(name) {
+	case "wrap":
+		d.Wrap = true
+		return nil
+	case "init", "initialize":
+	default:
+		// Other directives don't affect the terminal output
+		return nil
+	}
+
+	var init struct {
+		Theme     string `json:"theme"`
+		Direction string `json:"direction"`
+		Wrap      *bool  `json:"wrap"`
+		Flowchart struct {
+			Direction string `json:"direction"`
+		} `json:"flowchart"`
+		Sequence struct {
+			Wrap *bool `json:"wrap"`
+		} `json:"sequence"`
+		ThemeVariables struct {
+			PrimaryColor       string `json:"primaryColor"`
+			PrimaryBorderColor string `json:"primaryBorderColor"`
+			PrimaryTextColor   string `json:"primaryTextColor"`
+			TextColor          string `json:"textColor"`
+			LineColor          string `json:"lineColor"`
+		} `json:"themeVariables"`
+	}
+	// Directives are commonly written with single quotes
+	args = strings.ReplaceAll(strings.TrimSpace(args), "'", `"`)
+	if err := json.Unmarshal([]byte(args), &init); err != nil {
+		return fmt.Errorf("invalid init directive: %w", err)
+	}
+
+	if init.Theme != "" {
+		d.Theme = init.Theme
+	}
+
+	direction := init.Direction
+	if init.Flowchart.Direction != "" {
+		direction = init.Flowchart.Direction
+	}
+	if direction != "" {
+		direction = strings.ToUpper(direction)
+		switch direction {
+		case "LR", "RL", "TD", "TB", "BT":
+		default:
+			return fmt.Errorf("invalid init directive: unsupported direction %q", direction)
+		}
+		d.Direction = direction
+	}
+
+	if init.Wrap != nil {
+		d.Wrap = *init.Wrap
+	}
+	if init.Sequence.Wrap != nil {
+		d.Wrap = *init.Sequence.Wrap
+	}
+
+	// Boxes are drawn as lines, so they take the border color, or else the
+	// fill color that mermaid derives it from
+	vars := init.ThemeVariables
+	setColor(&d.Colors.Border, vars.PrimaryBorderColor, vars.PrimaryColor)
+	setColor(&d.Colors.Arrow, vars.LineColor)
+	setColor(&d.Colors.Label, vars.PrimaryTextColor, vars.TextColor)
+	return nil
+}
+
+// setColor sets color to the first of the CSS colors that a terminal can
+// show, leaving it as is if there's none.
+func setColor(color *string, css ...string) {
+	for _, c := range css {
+		if hex := CSSColor(c); hex != "" {
+			*color = hex
+			return
+		}
+	}
+}
+
+// themePalettes are the line colors of the mermaid themes that look alike in
+// a terminal, as ANSI colors that suit both dark and light backgrounds. The
+// default, base and dark themes keep the document's colors, which already
+// suit the terminal's background.
+var themePalettes = map[string]Palette{
+	"forest":  {Border: "2", Arrow: "2"},
+	"neutral": {Border: "8", Arrow: "8"},
+}
+
+// Apply returns a copy of config with the directive's settings applied.
+func (d Directive) Apply(config *Config) *Config {
+	c := *config
+	if d.Direction != "" {
+		c.DirectionOverride = d.Direction
+	}
+	if d.Wrap {
+		c.SequenceWrap = true
+	}
+	// Colors only replace those of a palette, so that output meant to be
+	// uncolored stays that way
+	theme, themed := themePalettes[d.Theme]
+	if c.Palette != nil && (themed || d.Colors != (Palette{})) {
+		palette := *c.Palette
+		if themed {
+			palette.Border, palette.Arrow = theme.Border, theme.Arrow
+		}
+		if d.Colors.Border != "" {
+			palette.Border = d.Colors.Border
+		}
+		if d.Colors.Arrow != "" {
+			palette.Arrow = d.Colors.Arrow
+		}
+		if d.Colors.Label != "" {
+			palette.Label = d.Colors.Label
+		}
+		c.Palette = &palette
+	}
+	return &c
+}
@@ -0,0 +1,65 @@
+package diagram
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseError is a syntax error at a position in a diagram's source. Lines and
+// columns count from 1, and lines include comments and directives, so they
+// match the source as written.
+type ParseError struct {
+	Line   int
+	Column int
+
+	// Token is the offending text, if known
+	Token string
+
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	msg := fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+	if e.Token != "" {
+		msg += fmt.Sprintf(" at %q", e.Token)
+	}
+	return msg
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Line is a line of a diagram's source, with its line number.
+type Line struct {
+	Number int
+	Text   string
+
+	// Indent is the number of characters before the text starts
+	Indent int
+}
+
+// ErrorAt returns a ParseError for err at the start of the line.
+func (l Line) ErrorAt(err error, token string) *ParseError {
+	return &ParseError{Line: l.Number, Column: l.Indent + 1, Token: token, Err: err}
+}
+
+// NumberedLines splits input into trimmed lines, dropping comments and blank
+// lines but keeping the line numbers of the rest.
+func NumberedLines(input string) []Line {
+	var lines []Line
+	for i, raw := range SplitLines(input) {
+		text := raw
+		if idx := strings.Index(text, "%%"); idx != -1 {
+			text = text[:idx]
+		}
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" {
+			continue
+		}
+		indent := utf8.RuneCountInString(text[:strings.Index(text, trimmed)])
+		lines = append(lines, Line{Number: i + 1, Text: trimmed, Indent: indent})
+	}
+	return lines
+}
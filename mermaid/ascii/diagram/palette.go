@@ -0,0 +1,111 @@
+package diagram
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Palette holds the terminal colors used to draw a diagram. Colors are ANSI
+// color numbers or hex values; empty colors leave that part uncolored.
+type Palette struct {
+	// Border colors boxes, lifelines and other lines
+	Border string
+
+	// Arrow colors arrowheads and edges
+	Arrow string
+
+	// Label colors text
+	Label string
+}
+
+// ASCII diagrams draw lines and boxes with asciiLines and arrowheads and
+// points with asciiMarks.
+const (
+	asciiLines = "+-|=#.:"
+	asciiMarks = "<>^*"
+)
+
+// ColorOf returns the color a single drawing character is painted with.
+// Unicode diagrams draw lines with box-drawing characters and arrowheads and
+// points with geometric shapes.
+func (p *Palette) ColorOf(r rune, useAscii bool) string {
+	switch {
+	case r == ' ' || r == '\n':
+		return ""
+	case useAscii && strings.ContainsRune(asciiMarks, r):
+		return p.Arrow
+	case useAscii && strings.ContainsRune(asciiLines, r):
+		return p.Border
+	case !useAscii && r >= '■' && r <= '◿':
+		return p.Arrow
+	case !useAscii && r >= '─' && r <= '▟':
+		return p.Border
+	}
+	return p.Label
+}
+
+// Paint colors each character of text according to the palette, so that
+// lines, arrowheads and labels stand apart. A nil palette leaves text as is.
+func (p *Palette) Paint(text string, useAscii bool) string {
+	if p == nil {
+		return text
+	}
+
+	var b, run strings.Builder
+	runColor := ""
+	for _, r := range text {
+		if color := p.ColorOf(r, useAscii); color != runColor {
+			b.WriteString(Colorize(run.String(), runColor))
+			run.Reset()
+			runColor = color
+		}
+		run.WriteRune(r)
+	}
+	b.WriteString(Colorize(run.String(), runColor))
+	return b.String()
+}
+
+// cssColors maps the CSS color names most often used in mermaid styles to
+// hex values. Other names are ignored.
+var cssColors = map[string]string{
+	"black":  "#000000",
+	"white":  "#ffffff",
+	"red":    "#ff0000",
+	"green":  "#008000",
+	"lime":   "#00ff00",
+	"blue":   "#0000ff",
+	"yellow": "#ffff00",
+	"orange": "#ffa500",
+	"purple": "#800080",
+	"pink":   "#ffc0cb",
+	"cyan":   "#00ffff",
+	"gray":   "#808080",
+	"grey":   "#808080",
+}
+
+// CSSColor normalizes a mermaid color value to a hex color, returning "" for
+// values that can't be shown in a terminal.
+func CSSColor(c string) string {
+	c = strings.ToLower(strings.TrimSpace(c))
+	if hex, ok := cssColors[c]; ok {
+		return hex
+	}
+	if !strings.HasPrefix(c, "#") || (len(c) != 4 && len(c) != 7) {
+		return ""
+	}
+	for _, r := range c[1:] {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return ""
+		}
+	}
+	return c
+}
+
+// Colorize renders text in the given terminal color.
+func Colorize(text, color string) string {
+	if color == "" || text == "" {
+		return text
+	}
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(color)).Render(text)
+}
@@ -3,32 +3,37 @@ package diagram
 import (
 	"regexp"
 	"strings"
-)
 
-// removeComments removes Mermaid comment lines from input.
-// This function is shared between graph and sequence diagram parsers.
-// It handles both full-line comments (%% at start) and inline comments (%% after content).
-func RemoveComments(lines []string) []string {
-	cleaned := make([]string, 0, len(lines))
+	"github.com/charmbracelet/x/ansi"
+)
 
-	for _, line := range lines {
-		// Skip lines that start with %% (full-line comments)
+// StripComments blanks out %%{...}%% directives and lines starting with %% in
+// input, leaving the remaining lines untouched and on the same line numbers.
+func StripComments(input string) string {
+	input = directiveRegex.ReplaceAllStringFunc(input, blankLines)
+	lines := strings.Split(input, "\n")
+	for i, line := range lines {
 		if strings.HasPrefix(strings.TrimSpace(line), "%%") {
-			continue
+			lines[i] = ""
 		}
+	}
+	return strings.Join(lines, "\n")
+}
 
-		// Remove inline comments (anything after %%)
-		if idx := strings.Index(line, "%%"); idx != -1 {
-			line = strings.TrimSpace(line[:idx])
-		}
+// blankLines returns the line breaks of text, so that replacing text with
+// them keeps the line numbers of what follows.
+func blankLines(text string) string {
+	return strings.Repeat("\n", strings.Count(text, "\n"))
+}
 
-		// Only keep non-empty lines after comment removal
-		if len(strings.TrimSpace(line)) > 0 {
-			cleaned = append(cleaned, line)
-		}
+// MaxLineWidth returns the display width of the widest line of text,
+// ignoring colors.
+func MaxLineWidth(text string) int {
+	width := 0
+	for _, line := range strings.Split(text, "\n") {
+		width = max(width, ansi.StringWidth(line))
 	}
-
-	return cleaned
+	return width
 }
 
 // SplitLines splits input on both actual newlines and escaped newlines (for curl compatibility).
@@ -45,29 +45,63 @@ func (c drawingCoord) Direction(dir direction) drawingCoord {
 	return drawingCoord{x: c.x + dir.x, y: c.y + dir.y}
 }
 
-func selfReferenceDirection(e *edge) (direction, direction, direction, direction) {
-	if graphDirection == "LR" {
-		return Right, Down, Down, Right
+// selfReferenceDirection returns the sides an edge from a node to itself
+// leaves and enters it by. It loops around the first corner of the node
+// whose sides other edges leave free, or the first corner if none is: it may
+// leave by a side other edges leave by too, but not by one they enter by, and
+// enters by a side no other edge uses. Self-loops are routed last, so the
+// other edges' sides are known.
+func (g *graph) selfReferenceDirection(e *edge) (direction, direction, direction, direction) {
+	// Edges only ever leave by the sides forward and back edges do, so the
+	// corners are limited to those; TD has the LR ones transposed
+	corners := [][2]direction{{Right, Down}, {Right, Up}, {Down, Left}}
+	if g.layout != "LR" {
+		corners = [][2]direction{{Down, Right}, {Down, Left}, {Right, Up}}
 	}
-	return Down, Right, Right, Down
+
+	leaving := map[direction]bool{}
+	entering := map[direction]bool{}
+	for _, other := range g.edges {
+		if other == e || other.from == other.to || len(other.path) == 0 {
+			continue
+		}
+		if other.from == e.from {
+			leaving[other.startDir] = true
+		}
+		if other.to == e.from {
+			entering[other.endDir] = true
+		}
+	}
+	for _, c := range corners {
+		if !entering[c[0]] && !leaving[c[1]] && !entering[c[1]] {
+			return c[0], c[1], c[0], c[1]
+		}
+	}
+	return corners[0][0], corners[0][1], corners[0][0], corners[0][1]
 }
 
-func determineStartAndEndDir(e *edge) (direction, direction, direction, direction) {
+// isBackwards reports whether an edge flows against the layout, such as the
+// edge closing a cycle.
+func (g *graph) isBackwards(e *edge) bool {
 	if e.from == e.to {
-		return selfReferenceDirection(e)
+		return false
 	}
 	d := determineDirection(genericCoord(*e.from.gridCoord), genericCoord(*e.to.gridCoord))
-	var preferredDir, preferredOppositeDir, alternativeDir, alternativeOppositeDir direction
-
-	// Check if this is a backwards flowing edge
-	isBackwards := false
-	if graphDirection == "LR" {
+	if g.layout == "LR" {
 		// In LR mode, backwards flow is when edge goes from right to left (Left direction)
-		isBackwards = (d == Left || d == UpperLeft || d == LowerLeft)
-	} else { // TD mode
-		// In TD mode, backwards flow is when edge goes from bottom to top (Up direction)
-		isBackwards = (d == Up || d == UpperLeft || d == UpperRight)
+		return d == Left || d == UpperLeft || d == LowerLeft
 	}
+	// In TD mode, backwards flow is when edge goes from bottom to top (Up direction)
+	return d == Up || d == UpperLeft || d == UpperRight
+}
+
+func (g *graph) determineStartAndEndDir(e *edge) (direction, direction, direction, direction) {
+	if e.from == e.to {
+		return g.selfReferenceDirection(e)
+	}
+	d := determineDirection(genericCoord(*e.from.gridCoord), genericCoord(*e.to.gridCoord))
+	var preferredDir, preferredOppositeDir, alternativeDir, alternativeOppositeDir direction
+	isBackwards := g.isBackwards(e)
 
 	// LR: prefer vertical over horizontal
 	// TD: prefer horizontal over vertical
@@ -75,7 +109,7 @@ func determineStartAndEndDir(e *edge) (direction, direction, direction, directio
 	// For backwards edges, use special start positions: Down in LR mode, Right in TD mode
 	switch d {
 	case LowerRight:
-		if graphDirection == "LR" {
+		if g.layout == "LR" {
 			preferredDir = Down
 			preferredOppositeDir = Left
 			alternativeDir = Right
@@ -87,7 +121,7 @@ func determineStartAndEndDir(e *edge) (direction, direction, direction, directio
 			alternativeOppositeDir = Left
 		}
 	case UpperRight:
-		if graphDirection == "LR" {
+		if g.layout == "LR" {
 			preferredDir = Up
 			preferredOppositeDir = Left
 			alternativeDir = Right
@@ -99,7 +133,7 @@ func determineStartAndEndDir(e *edge) (direction, direction, direction, directio
 			alternativeOppositeDir = Left
 		}
 	case LowerLeft:
-		if graphDirection == "LR" {
+		if g.layout == "LR" {
 			// Backwards flow in LR mode - start from Down, arrive at Down
 			preferredDir = Down
 			preferredOppositeDir = Down // Edge goes to bottom of destination
@@ -112,7 +146,7 @@ func determineStartAndEndDir(e *edge) (direction, direction, direction, directio
 			alternativeOppositeDir = Right
 		}
 	case UpperLeft:
-		if graphDirection == "LR" {
+		if g.layout == "LR" {
 			// Backwards flow in LR mode - start from Down, arrive at Down
 			preferredDir = Down
 			preferredOppositeDir = Down // Edge goes to bottom of destination
@@ -128,13 +162,13 @@ func determineStartAndEndDir(e *edge) (direction, direction, direction, directio
 	default:
 		// Handle direct backwards flow cases
 		if isBackwards {
-			if graphDirection == "LR" && d == Left {
+			if g.layout == "LR" && d == Left {
 				// Direct left flow in LR mode - start from Down, arrive at Down
 				preferredDir = Down
 				preferredOppositeDir = Down // Edge goes to bottom of destination
 				alternativeDir = Left
 				alternativeOppositeDir = Right
-			} else if graphDirection == "TD" && d == Up {
+			} else if g.layout == "TD" && d == Up {
 				// Direct up flow in TD mode - start from Right, arrive at Right
 				preferredDir = Right
 				preferredOppositeDir = Right // Edge goes to right of destination
@@ -154,5 +188,12 @@ func determineStartAndEndDir(e *edge) (direction, direction, direction, directio
 			alternativeOppositeDir = preferredOppositeDir
 		}
 	}
+
+	// A back edge of a cycle between two nodes would take the same line as
+	// the edge it returns along, so it always loops around
+	if isBackwards && g.hasEdge(e.to, e.from) {
+		alternativeDir = preferredDir
+		alternativeOppositeDir = preferredOppositeDir
+	}
 	return preferredDir, preferredOppositeDir, alternativeDir, alternativeOppositeDir
 }
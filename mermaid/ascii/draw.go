@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/gookit/color"
+	"github.com/mattn/go-runewidth"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -47,20 +47,49 @@ func (g *graph) drawEdge(e *edge) (*drawing, *drawing, *drawing, *drawing, *draw
 }
 
 func (d *drawing) drawText(start drawingCoord, text string) {
+	cells := textCells(text)
 	// Increase dimensions if necessary.
-	d.increaseSize(start.x+len(text), start.y)
-	log.Debug("Drawing '", text, "' from ", start, " to ", drawingCoord{x: start.x + len(text), y: start.y})
-	for x := 0; x < len(text); x++ {
-		(*d)[x+start.x][start.y] = string(text[x])
+	d.increaseSize(start.x+len(cells), start.y)
+	log.Debug("Drawing '", text, "' from ", start, " to ", drawingCoord{x: start.x + len(cells), y: start.y})
+	for x, cell := range cells {
+		(*d)[x+start.x][start.y] = cell
 	}
 }
 
+// textWidth returns the number of terminal columns text takes up, counting
+// CJK characters and emoji as two.
+func textWidth(text string) int {
+	return runewidth.StringWidth(text)
+}
+
+// textCells splits text into one drawing cell per terminal column. Wide
+// characters fill their first cell and leave the second empty, and
+// zero-width characters join the character before them.
+func textCells(text string) []string {
+	cells := make([]string, 0, len(text))
+	for _, r := range text {
+		switch w := runewidth.RuneWidth(r); {
+		case w == 0 && len(cells) > 0:
+			if cells[len(cells)-1] == "" {
+				cells[len(cells)-2] += string(r)
+			} else {
+				cells[len(cells)-1] += string(r)
+			}
+		case w == 2:
+			cells = append(cells, string(r), "")
+		default:
+			cells = append(cells, string(r))
+		}
+	}
+	return cells
+}
+
 func (g *graph) drawLine(d *drawing, from drawingCoord, to drawingCoord, offsetFrom int, offsetTo int) []drawingCoord {
 	// Offset determines how far from the actual coord the line should start/stop.
 	direction := determineDirection(genericCoord(from), genericCoord(to))
 	drawnCoords := make([]drawingCoord, 0)
 	log.Debug("Drawing line from ", from, " to ", to, " direction: ", direction, " offsetFrom: ", offsetFrom, " offsetTo: ", offsetTo)
-	if !g.useAscii {
+	if !g.options.UseAscii {
 		switch direction {
 		case Up:
 			for y := from.y - offsetFrom; y >= to.y-offsetTo; y-- {
@@ -153,13 +182,15 @@ func (g *graph) drawLine(d *drawing, from drawingCoord, to drawingCoord, offsetF
 func drawMap(properties *graphProperties) string {
 	g := mkGraph(properties.data)
 	g.setStyleClasses(properties)
-	g.paddingX = properties.paddingX
-	g.paddingY = properties.paddingY
-	g.useAscii = properties.useAscii
+	g.layout = properties.layoutDirection()
+	g.direction = properties.graphDirection
+	g.palette = properties.palette
+	g.labelWidth = properties.labelWidth
 	g.setSubgraphs(properties.subgraphs)
 	g.createMapping()
 	d := g.draw()
-	if Coords {
+	g.applyStyles()
+	if g.options.ShowCoords || Coords {
 		d = d.debugDrawingWrapper()
 		d = d.debugCoordWrapper(g)
 	}
@@ -182,57 +213,38 @@ func drawBox(n *node, g graph) *drawing {
 	to := drawingCoord{w, h}
 	boxDrawing := *(mkDrawing(Max(from.x, to.x), Max(from.y, to.y)))
 	log.Debug("Drawing box from ", from, " to ", to)
-	if !g.useAscii {
-		// Draw top border
-		for x := from.x + 1; x < to.x; x++ {
-			boxDrawing[x][from.y] = "─" // Horizontal line
-		}
-		// Draw bottom border
-		for x := from.x + 1; x < to.x; x++ {
-			boxDrawing[x][to.y] = "─" // Horizontal line
-		}
-		// Draw left border
-		for y := from.y + 1; y < to.y; y++ {
-			boxDrawing[from.x][y] = "│" // Vertical line
-		}
-		// Draw right border
-		for y := from.y + 1; y < to.y; y++ {
-			boxDrawing[to.x][y] = "│" // Vertical line
-		}
-		// Draw corners
-		boxDrawing[from.x][from.y] = "┌" // Top left corner
-		boxDrawing[to.x][from.y] = "┐"   // Top right corner
-		boxDrawing[from.x][to.y] = "└"   // Bottom left corner
-		boxDrawing[to.x][to.y] = "┘"     // Bottom right corner
-	} else {
-		// Draw top border
-		for x := from.x + 1; x < to.x; x++ {
-			boxDrawing[x][from.y] = "-" // Horizontal line
-		}
-		// Draw bottom border
-		for x := from.x + 1; x < to.x; x++ {
-			boxDrawing[x][to.y] = "-" // Horizontal line
-		}
-		// Draw left border
-		for y := from.y + 1; y < to.y; y++ {
-			boxDrawing[from.x][y] = "|" // Vertical line
-		}
-		// Draw right border
-		for y := from.y + 1; y < to.y; y++ {
-			boxDrawing[to.x][y] = "|" // Vertical line
-		}
-		// Draw corners
-		boxDrawing[from.x][from.y] = "+" // Top left corner
-		boxDrawing[to.x][from.y] = "+"   // Top right corner
-		boxDrawing[from.x][to.y] = "+"   // Bottom left corner
-		boxDrawing[to.x][to.y] = "+"     // Bottom right corner
+	b := border(n.shape, g.options.UseAscii)
+	// Draw top border
+	for x := from.x + 1; x < to.x; x++ {
+		boxDrawing[x][from.y] = b.horizontal
+	}
+	// Draw bottom border
+	for x := from.x + 1; x < to.x; x++ {
+		boxDrawing[x][to.y] = b.horizontal
+	}
+	// Draw left border
+	for y := from.y + 1; y < to.y; y++ {
+		boxDrawing[from.x][y] = b.left
 	}
-	// Draw text - use displayName if set, otherwise use name
-	displayText := n.getDisplayName()
-	textY := from.y + h/2
-	textX := from.x + w/2 - CeilDiv(len(displayText), 2) + 1
-	for x := 0; x < len(displayText); x++ {
-		boxDrawing[textX+x][textY] = wrapTextInColor(string(displayText[x]), n.styleClass.styles["color"], g.styleType)
+	// Draw right border
+	for y := from.y + 1; y < to.y; y++ {
+		boxDrawing[to.x][y] = b.right
+	}
+	// Draw corners
+	boxDrawing[from.x][from.y] = b.topLeft
+	boxDrawing[to.x][from.y] = b.topRight
+	boxDrawing[from.x][to.y] = b.bottomLeft
+	boxDrawing[to.x][to.y] = b.bottomRight
+	// Draw text - use displayName if set, otherwise use name - centered
+	// vertically and each line centered horizontally
+	lines := n.labelLines(g.labelWidth)
+	textY := from.y + 1 + (h-1-len(lines))/2
+	for i, displayText := range lines {
+		cells := textCells(displayText)
+		textX := from.x + w/2 - CeilDiv(len(cells), 2) + 1
+		for x, cell := range cells {
+			boxDrawing[textX+x][textY+i] = cell
+		}
 	}
 
 	return &boxDrawing
@@ -253,7 +265,7 @@ func drawSubgraph(sg *subgraph, g graph) *drawing {
 
 	log.Debugf("Drawing subgraph %s from (%d,%d) to (%d,%d)", sg.name, from.x, from.y, to.x, to.y)
 
-	if !g.useAscii {
+	if !g.options.UseAscii {
 		// Draw top border
 		for x := from.x + 1; x < to.x; x++ {
 			subgraphDrawing[x][from.y] = "─"
@@ -319,13 +331,14 @@ func drawSubgraphLabel(sg *subgraph, g graph) (*drawing, drawingCoord) {
 
 	// Draw label centered at top
 	labelY := from.y + 1
-	labelX := from.x + width/2 - len(sg.name)/2
+	cells := textCells(sg.name)
+	labelX := from.x + width/2 - len(cells)/2
 	if labelX < from.x+1 {
 		labelX = from.x + 1
 	}
-	for i, char := range sg.name {
+	for i, cell := range cells {
 		if labelX+i < to.x {
-			labelDrawing[labelX+i][labelY] = string(char)
+			labelDrawing[labelX+i][labelY] = cell
 		}
 	}
 
@@ -334,21 +347,6 @@ func drawSubgraphLabel(sg *subgraph, g graph) (*drawing, drawingCoord) {
 	return &labelDrawing, offset
 }
 
-func wrapTextInColor(text, c, styleType string) string {
-	if c == "" {
-		return text
-	}
-	if styleType == "html" {
-		return fmt.Sprintf("<span style='color: %s'>%s</span>", c, text)
-	} else if styleType == "cli" {
-		cliColor := color.HEX(c)
-		return cliColor.Sprint(text)
-	} else {
-		log.Warnf("Unknown style type %s", styleType)
-		return text
-	}
-}
-
 func (d *drawing) increaseSize(x int, y int) {
 	currSizeX, currSizeY := getDrawingSize(d)
 	drawingWithNewSize := mkDrawing(Max(x, currSizeX), Max(y, currSizeY))
@@ -430,7 +428,7 @@ func (g *graph) mergeDrawings(baseDrawing *drawing, mergeCoord drawingCoord, dra
 				c := (*d)[x][y]
 				if c != " " {
 					currentChar := (*mergedDrawing)[x+mergeCoord.x][y+mergeCoord.y]
-					if !g.useAscii && isJunctionChar(c) && isJunctionChar(currentChar) {
+					if !g.options.UseAscii && isJunctionChar(c) && isJunctionChar(currentChar) {
 						(*mergedDrawing)[x+mergeCoord.x][y+mergeCoord.y] = mergeJunctions(currentChar, c)
 					} else {
 						(*mergedDrawing)[x+mergeCoord.x][y+mergeCoord.y] = c
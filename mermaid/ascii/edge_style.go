@@ -0,0 +1,75 @@
+package ascii
+
+import (
+	"regexp"
+	"strings"
+)
+
+// lineStyle is the stroke used to draw an edge.
+type lineStyle int
+
+const (
+	lineSolid  lineStyle = iota // -->
+	lineDotted                  // -.->
+	lineThick                   // ==>
+)
+
+// edgeStyle describes how an edge is drawn: its stroke and which ends carry
+// an arrowhead.
+type edgeStyle struct {
+	line       lineStyle
+	arrowStart bool
+	arrowEnd   bool
+}
+
+var defaultEdgeStyle = edgeStyle{line: lineSolid, arrowEnd: true}
+
+// arrowPattern matches the supported mermaid link syntaxes. Longer variants
+// come first so that e.g. <--> isn't read as -->.
+const arrowPattern = `<-->|<==>|<-\.->|-->|==>|-\.->|---|===|-\.-`
+
+var arrowRegex = regexp.MustCompile(arrowPattern)
+
+// parseEdgeStyle returns the style of a link such as "-.->" or "<==>".
+func parseEdgeStyle(arrow string) edgeStyle {
+	style := edgeStyle{
+		arrowStart: strings.HasPrefix(arrow, "<"),
+		arrowEnd:   strings.HasSuffix(arrow, ">"),
+	}
+	switch {
+	case strings.Contains(arrow, "."):
+		style.line = lineDotted
+	case strings.Contains(arrow, "="):
+		style.line = lineThick
+	}
+	return style
+}
+
+// lineChars returns the horizontal and vertical characters for a line style.
+func lineChars(style lineStyle, useAscii bool) (string, string) {
+	if useAscii {
+		switch style {
+		case lineDotted:
+			return ".", ":"
+		case lineThick:
+			return "=", "#"
+		}
+		return "-", "|"
+	}
+	switch style {
+	case lineDotted:
+		return "┄", "┆"
+	case lineThick:
+		return "═", "║"
+	}
+	return "─", "│"
+}
+
+// thickCorners maps the corners of a solid line to those of a thick line.
+var thickCorners = map[string]string{
+	"┐": "╗",
+	"┘": "╝",
+	"┌": "╔",
+	"└": "╚",
+	"+": "+",
+}
@@ -0,0 +1,59 @@
+package ascii
+
+import "github.com/hholst80/glow/mermaid/ascii/diagram"
+
+// The narrowest settings tried when fitting a graph into a width.
+const (
+	minPaddingX   = 2
+	minLabelWidth = 8
+)
+
+// drawMapFitting draws the graph like drawMap, but when the output is wider
+// than maxWidth it first brings the nodes closer together and then wraps
+// node labels until it fits. The narrowest drawing is returned if nothing
+// fits; a maxWidth of 0 means unlimited.
+func drawMapFitting(properties *graphProperties, maxWidth int) string {
+	output := drawMap(properties)
+	if maxWidth <= 0 {
+		return output
+	}
+
+	paddingX, labelWidth := properties.options.PaddingX, properties.labelWidth
+	defer func() {
+		properties.options.PaddingX, properties.labelWidth = paddingX, labelWidth
+	}()
+
+	for diagram.MaxLineWidth(output) > maxWidth && properties.options.PaddingX > minPaddingX {
+		properties.options.PaddingX--
+		output = drawMap(properties)
+	}
+
+	width := properties.widestLabel()
+	if labelWidth > 0 {
+		width = min(width, labelWidth)
+	}
+	for diagram.MaxLineWidth(output) > maxWidth && width > minLabelWidth {
+		width = Max(width*3/4, minLabelWidth)
+		properties.labelWidth = width
+		output = drawMap(properties)
+	}
+	return output
+}
+
+// widestLabel returns the length of the longest line of a node label.
+func (gp *graphProperties) widestLabel() int {
+	widest := 0
+	label := func(n textNode) int {
+		if n.displayName != "" {
+			return labelTextWidth(n.displayName)
+		}
+		return labelTextWidth(n.name)
+	}
+	for el := gp.data.Front(); el != nil; el = el.Next() {
+		widest = Max(widest, labelTextWidth(el.Key))
+		for _, e := range el.Value {
+			widest = max(widest, label(e.parent), label(e.child))
+		}
+	}
+	return widest
+}
@@ -1,21 +1,16 @@
 package ascii
 
-// Global configuration variables used by the renderer.
-// These are package-level defaults that affect rendering behavior.
+// Package-level settings, kept for compatibility. Renders are configured with
+// RenderOptions instead.
 
 var (
 	// Verbose enables debug logging.
+	//
+	// Deprecated: Verbose has no effect.
 	Verbose bool
 	// Coords enables coordinate display in output.
+	//
+	// Deprecated: set RenderOptions.ShowCoords or diagram.Config.ShowCoords
+	// instead.
 	Coords bool
-	// boxBorderPadding is the padding between text and border in nodes.
-	boxBorderPadding = 1
-	// paddingBetweenX is horizontal space between nodes.
-	paddingBetweenX = 5
-	// paddingBetweenY is vertical space between nodes.
-	paddingBetweenY = 5
-	// graphDirection is the default direction for flowcharts ("LR" or "TD").
-	graphDirection = "LR"
-	// useAscii disables extended Unicode characters when true.
-	useAscii = false
 )
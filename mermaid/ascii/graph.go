@@ -2,8 +2,10 @@ package ascii
 
 import (
 	"errors"
+	"maps"
 
 	"github.com/elliotchance/orderedmap/v2"
+	"github.com/hholst80/glow/mermaid/ascii/diagram"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -38,12 +40,15 @@ type graph struct {
 	rowHeight    map[int]int
 	styleClasses map[string]styleClass
 	styleType    string
-	paddingX     int
-	paddingY     int
+	options      RenderOptions
 	subgraphs    []*subgraph
 	offsetX      int
 	offsetY      int
-	useAscii     bool
+	labels       []labelBox // Edge labels placed so far
+	direction    string     // Declared direction; RL and BT mirror the layout
+	layout       string     // Direction the layout works in, "LR" or "TD"
+	palette      *diagram.Palette
+	labelWidth   int // Width node labels wrap at; 0 doesn't wrap
 }
 
 type subgraph struct {
@@ -73,28 +78,32 @@ func mkGraph(data *orderedmap.OrderedMap[string, []textEdge]) graph {
 		if err != nil {
 			// Try to get displayName from the first edge where this node is parent
 			displayName := ""
+			shape := shapeRect
 			if len(children) > 0 && children[0].parent.displayName != "" {
 				displayName = children[0].parent.displayName
+				shape = children[0].parent.shape
 			}
-			parentNode = &node{name: nodeName, displayName: displayName, index: index, styleClassName: ""}
+			parentNode = &node{name: nodeName, displayName: displayName, shape: shape, index: index, styleClassName: ""}
 			g.appendNode(parentNode)
 			index += 1
 		} else if parentNode.displayName == "" && len(children) > 0 && children[0].parent.displayName != "" {
 			// Update displayName if we found a better one
 			parentNode.displayName = children[0].parent.displayName
+			parentNode.shape = children[0].parent.shape
 		}
 		for _, textEdge := range children {
 			childNode, err := g.getNode(textEdge.child.name)
 			if err != nil {
-				childNode = &node{name: textEdge.child.name, displayName: textEdge.child.displayName, index: index, styleClassName: textEdge.child.styleClass}
+				childNode = &node{name: textEdge.child.name, displayName: textEdge.child.displayName, shape: textEdge.child.shape, index: index, styleClassName: textEdge.child.styleClass}
 				parentNode.styleClassName = textEdge.parent.styleClass
 				g.appendNode(childNode)
 				index += 1
 			} else if childNode.displayName == "" && textEdge.child.displayName != "" {
 				// Update displayName if we found a better one
 				childNode.displayName = textEdge.child.displayName
+				childNode.shape = textEdge.child.shape
 			}
-			e := edge{from: parentNode, to: childNode, text: textEdge.label}
+			e := edge{from: parentNode, to: childNode, text: textEdge.label, style: textEdge.style, index: textEdge.index}
 			g.edges = append(g.edges, &e)
 		}
 	}
@@ -105,13 +114,24 @@ func (g *graph) setStyleClasses(properties *graphProperties) {
 	log.Debugf("Setting style classes to %v", properties.styleClasses)
 	g.styleClasses = *properties.styleClasses
 	g.styleType = properties.styleType
-	g.paddingX = properties.paddingX
-	g.paddingY = properties.paddingY
+	g.options = properties.options
 	for _, n := range g.nodes {
+		if className, ok := properties.nodeClasses[n.name]; ok {
+			n.styleClassName = className
+		}
+		styles := make(map[string]string)
 		if n.styleClassName != "" {
 			log.Debugf("Setting style class for node %s to %s", n.name, n.styleClassName)
-			(*n).styleClass = g.styleClasses[n.styleClassName]
+			maps.Copy(styles, g.styleClasses[n.styleClassName].styles)
 		}
+		// Styles set on the node itself take precedence over its class
+		maps.Copy(styles, properties.nodeStyles[n.name])
+		(*n).styleClass = styleClass{n.styleClassName, styles}
+	}
+	for _, e := range g.edges {
+		e.styles = make(map[string]string)
+		maps.Copy(e.styles, properties.linkStyles[defaultLinkStyle])
+		maps.Copy(e.styles, properties.linkStyles[e.index])
 	}
 }
 
@@ -166,13 +186,9 @@ func (g *graph) setSubgraphs(textSubgraphs []*textSubgraph) {
 }
 
 func (g *graph) createMapping() {
-	// Set mapping coord for every node in the graph
-	highestPositionPerLevel := []int{}
-	// Init array with 0 values
-	// TODO: I'm sure there's a better way of doing this
-	for i := 0; i < 100; i++ {
-		highestPositionPerLevel = append(highestPositionPerLevel, 0)
-	}
+	// Set mapping coord for every node in the graph. Levels are unbounded,
+	// as long chains reach far.
+	highestPositionPerLevel := make(map[int]int)
 
 	// TODO: should the mapping be bottom-to-top instead of top-to-bottom?
 	// Set root nodes to level 0
@@ -205,7 +221,7 @@ func (g *graph) createMapping() {
 
 	// Separate root nodes by whether they're in subgraphs, but only if we have both types
 	// AND there are edges in subgraphs (indicating intentional layout structure)
-	shouldSeparate := graphDirection == "LR" && hasExternalRoots && hasSubgraphRootsWithEdges
+	shouldSeparate := g.layout == "LR" && hasExternalRoots && hasSubgraphRootsWithEdges
 
 	externalRootNodes := []*node{}
 	subgraphRootNodes := []*node{}
@@ -225,7 +241,7 @@ func (g *graph) createMapping() {
 	// Place external root nodes first at level 0
 	for _, n := range externalRootNodes {
 		var mappingCoord *gridCoord
-		if graphDirection == "LR" {
+		if g.layout == "LR" {
 			mappingCoord = g.reserveSpotInGrid(g.nodes[n.index], &gridCoord{x: 0, y: highestPositionPerLevel[0]})
 		} else {
 			mappingCoord = g.reserveSpotInGrid(g.nodes[n.index], &gridCoord{x: highestPositionPerLevel[0], y: 0})
@@ -241,7 +257,7 @@ func (g *graph) createMapping() {
 		subgraphLevel := 4
 		for _, n := range subgraphRootNodes {
 			var mappingCoord *gridCoord
-			if graphDirection == "LR" {
+			if g.layout == "LR" {
 				mappingCoord = g.reserveSpotInGrid(g.nodes[n.index], &gridCoord{x: subgraphLevel, y: highestPositionPerLevel[subgraphLevel]})
 			} else {
 				mappingCoord = g.reserveSpotInGrid(g.nodes[n.index], &gridCoord{x: highestPositionPerLevel[subgraphLevel], y: subgraphLevel})
@@ -256,7 +272,7 @@ func (g *graph) createMapping() {
 		log.Debugf("Creating mapping for node %s at %v", n.name, n.gridCoord)
 		var childLevel int
 		// Next column is 4 coords further. This is because every node is 3 coords wide + 1 coord inbetween.
-		if graphDirection == "LR" {
+		if g.layout == "LR" {
 			childLevel = n.gridCoord.x + 4
 		} else {
 			childLevel = n.gridCoord.y + 4
@@ -269,7 +285,7 @@ func (g *graph) createMapping() {
 			}
 
 			var mappingCoord *gridCoord
-			if graphDirection == "LR" {
+			if g.layout == "LR" {
 				mappingCoord = g.reserveSpotInGrid(g.nodes[child.index], &gridCoord{x: childLevel, y: highestPosition})
 			} else {
 				mappingCoord = g.reserveSpotInGrid(g.nodes[child.index], &gridCoord{x: highestPosition, y: childLevel})
@@ -280,14 +296,32 @@ func (g *graph) createMapping() {
 		}
 	}
 
+	g.orderLevels()
+
 	for _, n := range g.nodes {
 		g.setColumnWidth(n)
 	}
+	lanes := g.reserveSelfLoopLanes()
 
-	for _, e := range g.edges {
-		g.determinePath(e)
-		g.increaseGridSizeForPath(e.path)
-		g.determineLabelLine(e)
+	// Self-loops go last, so they can loop around a corner of their node
+	// whose sides the other edges leave free
+	for _, selfLoops := range []bool{false, true} {
+		for _, e := range g.edges {
+			if (e.from == e.to) != selfLoops {
+				continue
+			}
+			g.determinePath(e)
+			g.increaseGridSizeForPath(e.path)
+			g.determineLabelLine(e)
+		}
+	}
+	g.trimSelfLoopLanes(lanes)
+
+	switch g.direction {
+	case "RL":
+		g.mirrorLayout(true)
+	case "BT":
+		g.mirrorLayout(false)
 	}
 
 	// ! Last point before we manipulate the drawing !
@@ -4,10 +4,14 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 	"github.com/hholst80/glow/mermaid/ascii/diagram"
 	"github.com/hholst80/glow/mermaid/ascii/diagram/testutil"
+	"github.com/muesli/termenv"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -21,9 +25,9 @@ func verifyMap(t *testing.T, testCaseFile string, useAscii bool) {
 	if err != nil {
 		log.Fatal("Failed to parse mermaid: ", err)
 	}
-	properties.paddingX = tc.PaddingX
-	properties.paddingY = tc.PaddingY
-	properties.useAscii = useAscii
+	properties.options.PaddingX = tc.PaddingX
+	properties.options.PaddingY = tc.PaddingY
+	properties.options.UseAscii = useAscii
 	actualMap := drawMap(properties)
 	if tc.Expected != actualMap {
 		expectedWithSpaces := testutil.VisualizeWhitespace(tc.Expected)
@@ -64,6 +68,43 @@ func TestExtendedChars(t *testing.T) {
 	}
 }
 
+// TestMaxLabelWidth tests that long node labels wrap onto more lines at the
+// configured width.
+func TestMaxLabelWidth(t *testing.T) {
+	input := "graph TD\nA[This label is much longer than thirty columns of text] --> B"
+	config := diagram.DefaultConfig()
+	config.UseAscii = true
+	out, err := RenderDiagram(input, config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, want := range []string{"| This label is much longer than |", "|     thirty columns of text     |"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in:\n%s", want, out)
+		}
+	}
+
+	// Words longer than the width are broken
+	out, err = RenderDiagram("graph TD\nA[https://example.com/a/path/longer/than/thirty/columns] --> B", config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, want := range []string{"| https://example.com/a/path/lon |", "|    ger/than/thirty/columns     |"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in:\n%s", want, out)
+		}
+	}
+
+	config.MaxLabelWidth = 0
+	out, err = RenderDiagram(input, config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "| This label is much longer than thirty columns of text |") {
+		t.Errorf("Expected the label on one line:\n%s", out)
+	}
+}
+
 // TestGraphUseAsciiConfig tests that RenderDiagram respects config.UseAscii for graphs
 func TestGraphUseAsciiConfig(t *testing.T) {
 	mermaidInput := `graph LR
@@ -113,4 +154,156 @@ A --> B`
 	}
 }
 
+// TestRenderOptionsConcurrent tests that graphs rendered concurrently with
+// different options don't affect each other.
+func TestRenderOptionsConcurrent(t *testing.T) {
+	inputs := []string{"graph LR\nA --> B\nB --> C", "graph TD\nA --> B\nA --> C"}
+	configs := []*diagram.Config{diagram.DefaultConfig(), diagram.DefaultConfig()}
+	configs[1].UseAscii = true
+	configs[1].BoxBorderPadding = 2
+	configs[1].PaddingBetweenX = 2
+	configs[1].PaddingBetweenY = 1
+
+	type render struct {
+		input  string
+		config *diagram.Config
+		want   string
+	}
+	var renders []render
+	for _, input := range inputs {
+		for _, config := range configs {
+			want, err := RenderDiagram(input, config)
+			if err != nil {
+				t.Fatalf("RenderDiagram(%q) error = %v", input, err)
+			}
+			renders = append(renders, render{input, config, want})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		for _, r := range renders {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				got, err := RenderDiagram(r.input, r.config)
+				if err != nil {
+					t.Errorf("RenderDiagram(%q) error = %v", r.input, err)
+					return
+				}
+				if got != r.want {
+					t.Errorf("RenderDiagram(%q) concurrently =\n%s\nwant\n%s", r.input, got, r.want)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// TestRenderOptionsPaddingDirective tests that padding set in the diagram
+// source takes precedence over the config's.
+func TestRenderOptionsPaddingDirective(t *testing.T) {
+	config := diagram.DefaultConfig()
+	config.PaddingBetweenX = 1
+	want, err := RenderDiagram("graph LR\nA --> B", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := RenderDiagram("paddingX=1\ngraph LR\nA --> B", diagram.DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("paddingX directive =\n%s\nwant\n%s", got, want)
+	}
+	wide, err := RenderDiagram("paddingX=5\ngraph LR\nA --> B", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wide == want {
+		t.Errorf("paddingX directive was overridden by config:\n%s", wide)
+	}
+}
+
 // Sequence diagram tests moved to sequence_test.go
+
+// TestLabelPositionAvoidsCollisions tests that edge labels are moved off
+// node borders and other labels.
+func TestLabelPositionAvoidsCollisions(t *testing.T) {
+	g := graph{
+		nodes: []*node{
+			{name: "A", drawingCoord: &drawingCoord{0, 5}, drawing: mkDrawing(4, 4)},
+		},
+	}
+	line := []drawingCoord{{2, 2}, {2, 8}}
+
+	// The middle of the line is inside the node, so the label moves up
+	pos := g.labelPosition(line, "abc")
+	if pos.y != 4 {
+		t.Errorf("expected label on row 4, got %v", pos)
+	}
+
+	// A second label on the same line must not overwrite the first one
+	g.labels = append(g.labels, labelBox{start: pos, width: 3})
+	pos2 := g.labelPosition(line, "def")
+	if pos2.y == pos.y || (pos2.y >= 5 && pos2.y <= 9) {
+		t.Errorf("expected second label off the node and first label, got %v", pos2)
+	}
+}
+
+func TestStyleDirectives(t *testing.T) {
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	defer lipgloss.SetColorProfile(termenv.Ascii)
+
+	plain := "graph LR\nA --> B\nB --> C"
+	styled := plain + `
+classDef warn fill:#ff0,stroke:#f00,color:#000
+class B warn
+style C stroke:red
+linkStyle 1 stroke:#00f`
+
+	properties, err := mermaidFileToMap(styled, "cli")
+	if err != nil {
+		t.Fatalf("Failed to parse mermaid: %v", err)
+	}
+	if got := properties.nodeClasses["B"]; got != "warn" {
+		t.Errorf("Expected B to have class warn, got %q", got)
+	}
+	if got := properties.nodeStyles["C"]["stroke"]; got != "red" {
+		t.Errorf("Expected C to have stroke red, got %q", got)
+	}
+	if got := properties.linkStyles[1]["stroke"]; got != "#00f" {
+		t.Errorf("Expected link 1 to have stroke #00f, got %q", got)
+	}
+	if properties.data.Len() != 3 {
+		t.Errorf("Expected style directives not to create nodes, got %v", properties.data.Keys())
+	}
+
+	output := drawMap(properties)
+	if !strings.Contains(output, "\x1b[") {
+		t.Fatal("Expected styled output to contain color codes")
+	}
+
+	plainProperties, err := mermaidFileToMap(plain, "cli")
+	if err != nil {
+		t.Fatalf("Failed to parse mermaid: %v", err)
+	}
+	if got, want := ansi.Strip(output), drawMap(plainProperties); got != want {
+		t.Errorf("Styling changed the layout\nExpected:\n%s\nActual:\n%s", want, got)
+	}
+}
+
+func TestParseNodeClassAnnotation(t *testing.T) {
+	properties, err := mermaidFileToMap("graph LR\nclassDef a,b color:#f00\nA:::a --> B:::b\nC:::a", "cli")
+	if err != nil {
+		t.Fatalf("Failed to parse mermaid: %v", err)
+	}
+	for node, class := range map[string]string{"A": "a", "B": "b", "C": "a"} {
+		if got := properties.nodeClasses[node]; got != class {
+			t.Errorf("Expected %s to have class %q, got %q", node, class, got)
+		}
+	}
+	if _, ok := (*properties.styleClasses)["b"]; !ok {
+		t.Error("Expected classDef to define both classes")
+	}
+}
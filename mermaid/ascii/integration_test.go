@@ -1,6 +1,7 @@
 package ascii
 
 import (
+	"errors"
 	"github.com/hholst80/glow/mermaid/ascii/sequence"
 	"strings"
 	"testing"
@@ -157,6 +158,415 @@ func TestSequenceDiagramIntegration_ErrorHandling(t *testing.T) {
 	}
 }
 
+// TestSequenceDiagramIntegration_Blocks tests loop, alt, opt and par frames.
+func TestSequenceDiagramIntegration_Blocks(t *testing.T) {
+	input := `sequenceDiagram
+    Alice->>Bob: Hello
+    loop Every minute
+        alt is sick
+            Bob->>Alice: Not so good
+        else is well
+            Bob->>Alice: Fine
+        end
+    end
+    opt
+    end
+    par A to B
+        Alice->>Bob: Hi
+    and B to A
+        Bob->>Alice: Hey
+    end`
+
+	sd, err := sequence.Parse(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(sd.Blocks) != 4 {
+		t.Fatalf("Expected 4 blocks, got %d", len(sd.Blocks))
+	}
+	loop, alt := sd.Blocks[0], sd.Blocks[1]
+	if loop.Kind != sequence.LoopBlock || loop.Label != "Every minute" || loop.Start != 1 || loop.End != 3 {
+		t.Errorf("Unexpected loop block: %+v", loop)
+	}
+	if alt.Kind != sequence.AltBlock || alt.Depth != 1 || len(alt.Sections) != 1 || alt.Sections[0].Start != 2 {
+		t.Errorf("Unexpected alt block: %+v", alt)
+	}
+
+	output, err := RenderDiagram(input, diagram.NewTestConfig(true, "cli"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"+- loop [Every minute] ",
+		"|+- alt [is sick] ",
+		"|+. else [is well] ",
+		"+- opt ",
+		"+. and [B to A] ",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Output missing %q:\n%s", want, output)
+		}
+	}
+
+	// Every line within a frame keeps its left edge
+	for _, line := range strings.Split(output, "\n")[3:] {
+		if line != "" && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "|") && !strings.HasPrefix(line, "+") {
+			t.Errorf("Unexpected frame edge in line %q", line)
+		}
+	}
+}
+
+// TestSequenceDiagramIntegration_BlockErrors tests malformed control blocks.
+func TestSequenceDiagramIntegration_BlockErrors(t *testing.T) {
+	tests := map[string]string{
+		"unclosed block":    "sequenceDiagram\nloop forever\nA->>B: Hi",
+		"end without block": "sequenceDiagram\nA->>B: Hi\nend",
+		"else outside alt":  "sequenceDiagram\nloop forever\nA->>B: Hi\nelse\nend",
+		"and outside par":   "sequenceDiagram\nalt x\nA->>B: Hi\nand y\nend",
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := sequence.Parse(input); err == nil {
+				t.Error("Expected error but got none")
+			}
+		})
+	}
+}
+
+// TestSequenceDiagramIntegration_Notes tests note placement and spacing.
+func TestSequenceDiagramIntegration_Notes(t *testing.T) {
+	input := `sequenceDiagram
+    participant A
+    participant B
+    Note left of A: First
+    A->>B: Hello
+    Note right of A: Between
+    Note over A,B: Both`
+
+	sd, err := sequence.Parse(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(sd.Notes) != 3 {
+		t.Fatalf("Expected 3 notes, got %d", len(sd.Notes))
+	}
+	if n := sd.Notes[2]; n.Position != sequence.NoteOver || len(n.Participants) != 2 || n.Text != "Both" {
+		t.Errorf("Unexpected note: %+v", n)
+	}
+
+	output, err := RenderDiagram(input, diagram.NewTestConfig(true, "cli"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lines := strings.Split(output, "\n")
+	find := func(s string) string {
+		for _, l := range lines {
+			if strings.Contains(l, s) {
+				return l
+			}
+		}
+		t.Fatalf("Output missing %q:\n%s", s, output)
+		return ""
+	}
+
+	// A note left of the first participant starts at the left edge
+	if l := find("| First |"); !strings.HasPrefix(l, "| First |") {
+		t.Errorf("Note left of A not at the left edge: %q", l)
+	}
+	// A note right of A fits between the lifelines of A and B
+	if l := find("| Between |"); !strings.HasSuffix(l, "| Between | |") {
+		t.Errorf("Note right of A overlaps B's lifeline: %q", l)
+	}
+	// A note over both participants covers both lifelines
+	if l := find("Both"); strings.Count(l, "|") != 2 {
+		t.Errorf("Note over A,B doesn't span the lifelines: %q", l)
+	}
+
+	if _, err := sequence.Parse("sequenceDiagram\nNote left of A,B: Too many"); err == nil {
+		t.Error("Expected error for note left of two participants")
+	}
+}
+
+// TestSequenceDiagramIntegration_Autonumber tests message numbering.
+func TestSequenceDiagramIntegration_Autonumber(t *testing.T) {
+	input := `sequenceDiagram
+    Alice->>Bob: Unnumbered
+    autonumber
+    Alice->>Bob: Hello
+    Bob->>Bob: Think
+    autonumber 10 5
+    Bob-->>Alice: Hi
+    Alice->>Bob:
+    autonumber off
+    Alice->>Bob: Bye
+    autonumber 0
+    Alice->>Bob: Zero
+    Bob->>Alice: One`
+
+	sd, err := sequence.Parse(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// autonumber 0 starts counting from 0; -1 is for messages not numbered
+	want := []int{-1, 1, 2, 10, 15, -1, 0, 1}
+	for i, msg := range sd.Messages {
+		got := msg.Number
+		if !msg.Numbered {
+			got = -1
+		}
+		if got != want[i] {
+			t.Errorf("Message %d: expected number %d, got %d", i, want[i], got)
+		}
+	}
+
+	output, err := RenderDiagram(input, diagram.NewTestConfig(false, "cli"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, s := range []string{"│ Unnumbered", "│ 1. Hello", "│ 2. Think", "│ 10. Hi", "│ 15.", "│ Bye", "│ 0. Zero", "│ 1. One"} {
+		if !strings.Contains(output, s) {
+			t.Errorf("Output missing %q:\n%s", s, output)
+		}
+	}
+}
+
+// TestSequenceDiagramIntegration_Actors tests aliases and actor figures.
+func TestSequenceDiagramIntegration_Actors(t *testing.T) {
+	input := `sequenceDiagram
+    actor U as User
+    participant S as "Web Server"
+    U->>S: Login`
+
+	sd, err := sequence.Parse(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if u := sd.Participants[0]; u.ID != "U" || u.Label != "User" || !u.Actor {
+		t.Errorf("Unexpected actor: %+v", u)
+	}
+	if s := sd.Participants[1]; s.ID != "S" || s.Label != "Web Server" || s.Actor {
+		t.Errorf("Unexpected participant: %+v", s)
+	}
+
+	output, err := RenderDiagram(input, diagram.NewTestConfig(true, "cli"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lines := strings.Split(output, "\n")
+	if lines[0] != "    o" || lines[1] != "   /|\\" {
+		t.Errorf("Expected a stick figure above the actor, got:\n%s", output)
+	}
+	if !strings.Contains(output, "| User |") || !strings.Contains(output, "| Web Server |") {
+		t.Errorf("Expected aliases as labels, got:\n%s", output)
+	}
+}
+
+// TestSequenceDiagramIntegration_WideCharacters tests that labels of CJK
+// characters and emoji take two columns per character, keeping the lifelines
+// and frames after them in line.
+func TestSequenceDiagramIntegration_WideCharacters(t *testing.T) {
+	input := `sequenceDiagram
+    participant 日本
+    participant B
+    participant C
+    日本->>C: 你好世界 🚀
+    Note over B: 注意事項
+    loop 毎日
+    B->>B: 自分
+    end`
+
+	output, err := RenderDiagram(input, diagram.NewTestConfig(false, "cli"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		" │ 日本 │     │ B │     │ C │",
+		"     │ 你好世界 🚀        │",
+		"     │    │ 注意事項 │    │",
+		"┌─ loop [毎日] ─┼─────────┼───┐",
+		"│    │          │ 自分    │   │",
+	} {
+		if !strings.Contains(output, want+"\n") {
+			t.Errorf("Expected %q in:\n%s", want, output)
+		}
+	}
+}
+
+// TestDirectives tests parsing of %%{init}%% and %%{wrap}%% directives.
+func TestDirectives(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  diagram.Directive
+	}{
+		{"theme", `%%{init: {"theme": "forest"}}%%`, diagram.Directive{Theme: "forest"}},
+		{"single quotes", `%%{init: {'theme':'dark', 'flowchart': {'direction': 'td'}}}%%`, diagram.Directive{Theme: "dark", Direction: "TD"}},
+		{"sequence wrap", `%%{initialize: {"sequence": {"wrap": true}}}%%`, diagram.Directive{Wrap: true}},
+		{"wrap", `%%{wrap}%%`, diagram.Directive{Wrap: true}},
+		{"multi-line", "%%{init: {\n  \"theme\": \"neutral\"\n}}%%", diagram.Directive{Theme: "neutral"}},
+		{"unrelated", `%%{config: {"fontSize": 12}}%%`, diagram.Directive{}},
+		{
+			"theme variables",
+			`%%{init: {'themeVariables': {'primaryColor': '#BB2528', 'lineColor': 'green', 'textColor': '#fff', 'fontSize': '20px'}}}%%`,
+			diagram.Directive{Colors: diagram.Palette{Border: "#bb2528", Arrow: "#008000", Label: "#fff"}},
+		},
+		{
+			"border over fill",
+			`%%{init: {'themeVariables': {'primaryColor': '#BB2528', 'primaryBorderColor': '#7C0000', 'lineColor': 'rgb(1, 2, 3)'}}}%%`,
+			diagram.Directive{Colors: diagram.Palette{Border: "#7c0000"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, rest, err := diagram.ParseDirectives(tt.input + "\ngraph LR\nA --> B")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if d != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, d)
+			}
+			if strings.Contains(rest, "%%") {
+				t.Errorf("Directive was not removed: %q", rest)
+			}
+		})
+	}
+
+	for _, input := range []string{`%%{init: {theme}}%%`, `%%{init: {"direction": "up"}}%%`} {
+		if _, _, err := diagram.ParseDirectives(input); err == nil {
+			t.Errorf("Expected an error for %s", input)
+		}
+	}
+}
+
+// TestDirectiveThemeVariables tests that themeVariables colors replace those
+// of the palette, and that uncolored output stays uncolored.
+func TestDirectiveThemeVariables(t *testing.T) {
+	d := diagram.Directive{Colors: diagram.Palette{Arrow: "#008000"}}
+	config := diagram.DefaultConfig()
+	if got := d.Apply(config).Palette; got != nil {
+		t.Errorf("Expected no palette, got %+v", got)
+	}
+
+	config.Palette = &diagram.Palette{Border: "1", Arrow: "2", Label: "3"}
+	want := diagram.Palette{Border: "1", Arrow: "#008000", Label: "3"}
+	if got := d.Apply(config).Palette; *got != want {
+		t.Errorf("Expected %+v, got %+v", want, *got)
+	}
+	if config.Palette.Arrow != "2" {
+		t.Errorf("Expected the config's palette to be unchanged, got %+v", *config.Palette)
+	}
+}
+
+// TestDirectiveTheme tests that a theme recolors the lines of the palette,
+// below its themeVariables.
+func TestDirectiveTheme(t *testing.T) {
+	config := diagram.DefaultConfig()
+	config.Palette = &diagram.Palette{Border: "1", Arrow: "2", Label: "3"}
+	for _, tt := range []struct {
+		directive diagram.Directive
+		want      diagram.Palette
+	}{
+		{diagram.Directive{Theme: "forest"}, diagram.Palette{Border: "2", Arrow: "2", Label: "3"}},
+		{diagram.Directive{Theme: "neutral", Colors: diagram.Palette{Arrow: "#008000"}}, diagram.Palette{Border: "8", Arrow: "#008000", Label: "3"}},
+		{diagram.Directive{Theme: "dark"}, *config.Palette},
+	} {
+		if got := tt.directive.Apply(config).Palette; *got != tt.want {
+			t.Errorf("Theme %q: expected %+v, got %+v", tt.directive.Theme, tt.want, *got)
+		}
+	}
+
+	if got := (diagram.Directive{Theme: "forest"}).Apply(diagram.DefaultConfig()).Palette; got != nil {
+		t.Errorf("Expected uncolored output to stay uncolored, got %+v", *got)
+	}
+}
+
+// TestDirectiveDirection tests that an init directive overrides the
+// direction declared by a flowchart.
+func TestDirectiveDirection(t *testing.T) {
+	config := diagram.NewTestConfig(false, "cli")
+	want, err := RenderDiagram("graph TD\nA --> B", config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	got, err := RenderDiagram("%%{init: {\"flowchart\": {\"direction\": \"TD\"}}}%%\ngraph LR\nA --> B", config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got != want {
+		t.Errorf("Expected TD layout:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+// TestSequenceDiagramIntegration_Wrap tests wrapping of long labels and notes.
+func TestSequenceDiagramIntegration_Wrap(t *testing.T) {
+	input := `%%{wrap}%%
+sequenceDiagram
+    Alice->>Bob: A rather long message that needs wrapping
+    Note over Alice: A long note that should be wrapped too`
+
+	output, err := RenderDiagram(input, diagram.NewTestConfig(false, "cli"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, s := range []string{"│ A rather long", "│ message that needs", "│ A long note that  │", "│ should be wrapped │"} {
+		if !strings.Contains(output, s) {
+			t.Errorf("Output missing %q:\n%s", s, output)
+		}
+	}
+}
+
+// TestComments tests that %% comment lines are ignored by every diagram type.
+func TestComments(t *testing.T) {
+	inputs := []string{
+		"%% leading comment\ngraph LR\n  %% A --> C\n  A --> B",
+		"%% leading comment\nsequenceDiagram\n  %% Alice->>Carol: Hidden\n  Alice->>Bob: Hi",
+		"%% leading comment\nquadrantChart\n  %% Hidden: [0.1, 0.1]\n  A: [0.3, 0.6]",
+	}
+	for _, input := range inputs {
+		output, err := RenderDiagram(input, diagram.NewTestConfig(false, "cli"))
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", input, err)
+			continue
+		}
+		if strings.Contains(output, "%%") || strings.Contains(output, "C") || strings.Contains(output, "Hidden") {
+			t.Errorf("Comment was rendered:\n%s", output)
+		}
+	}
+}
+
+// TestMaxWidth tests that diagrams are compressed and their labels wrapped
+// to fit a maximum width.
+func TestMaxWidth(t *testing.T) {
+	inputs := []string{
+		"graph LR\nA[Receive the incoming request] --> B[Validate the payload carefully] --> C[Store result]",
+		"sequenceDiagram\nAlice->>Bob: Please send me the quarterly report by Friday\nBob-->>Alice: Sure thing, will do",
+	}
+	for _, input := range inputs {
+		config := diagram.NewTestConfig(false, "cli")
+		wide, err := RenderDiagram(input, config)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if diagram.MaxLineWidth(wide) <= 40 {
+			t.Fatalf("Expected the unconstrained diagram to be wider than 40:\n%s", wide)
+		}
+
+		config.MaxWidth = 40
+		narrow, err := RenderDiagram(input, config)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if w := diagram.MaxLineWidth(narrow); w > 40 {
+			t.Errorf("Expected at most 40 columns, got %d:\n%s", w, narrow)
+		}
+		for _, word := range []string{"Receive", "carefully", "quarterly", "Friday"} {
+			if strings.Contains(input, word) && !strings.Contains(narrow, word) {
+				t.Errorf("Output missing %q:\n%s", word, narrow)
+			}
+		}
+	}
+}
+
 // TestDiagramFactoryIntegration tests diagram type detection.
 func TestDiagramFactoryIntegration(t *testing.T) {
 	tests := []struct {
@@ -176,6 +586,12 @@ func TestDiagramFactoryIntegration(t *testing.T) {
     A-->B`,
 			expectedType: "graph",
 		},
+		{
+			name: "quadrant chart",
+			input: `quadrantChart
+    A: [0.5, 0.5]`,
+			expectedType: "quadrant",
+		},
 	}
 
 	for _, tt := range tests {
@@ -207,6 +623,73 @@ func TestDiagramFactoryIntegration(t *testing.T) {
 	}
 }
 
+// TestQuadrantChartIntegration tests end-to-end rendering of quadrant charts.
+func TestQuadrantChartIntegration(t *testing.T) {
+	input := `quadrantChart
+    title Reach and engagement
+    x-axis Low Reach --> High Reach
+    y-axis Low Engagement --> High Engagement
+    quadrant-1 Expand
+    quadrant-2 Promote
+    quadrant-3 Re-evaluate
+    quadrant-4 Improve
+    Campaign A: [0.3, 0.6]
+    Campaign B: [0.45, 0.23]
+    Campaign C: [0.40, 0.25]`
+
+	output, err := RenderDiagram(input, diagram.NewTestConfig(false, "cli"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		"Reach and engagement", "Low Reach", "High Reach", "Low Engagement", "High Engagement",
+		"Expand", "Promote", "Re-evaluate", "Improve",
+		"Campaign A", "Campaign B", "Campaign C", "┼", "●",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Output missing expected substring %q\nOutput:\n%s", want, output)
+		}
+	}
+
+	lines := strings.Split(output, "\n")
+	row := func(s string) int {
+		for i, l := range lines {
+			if strings.Contains(l, s) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	// Quadrant 1 is top-right and quadrant 3 bottom-left
+	if row("Expand") >= row("Re-evaluate") {
+		t.Errorf("quadrant 1 should be above quadrant 3\nOutput:\n%s", output)
+	}
+	if strings.Index(lines[row("Expand")], "Expand") < strings.Index(lines[row("Promote")], "Promote") {
+		t.Errorf("quadrant 1 should be right of quadrant 2\nOutput:\n%s", output)
+	}
+
+	// Higher points are drawn above lower ones
+	if row("Campaign A") >= row("Campaign B") {
+		t.Errorf("Campaign A should be above Campaign B\nOutput:\n%s", output)
+	}
+}
+
+// TestQuadrantChartIntegration_Errors tests invalid quadrant charts.
+func TestQuadrantChartIntegration_Errors(t *testing.T) {
+	tests := []string{
+		"quadrantChart\n    Point: [1.5, 0.2]",
+		"quadrantChart\n    not a statement",
+	}
+
+	for _, input := range tests {
+		if _, err := RenderDiagram(input, diagram.NewTestConfig(false, "cli")); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
+
 // BenchmarkSequenceDiagramRendering benchmarks the rendering performance.
 func BenchmarkSequenceDiagramRendering(b *testing.B) {
 	input := `sequenceDiagram
@@ -274,3 +757,92 @@ func TestRenderConfig_Defaults(t *testing.T) {
 		t.Error("Default config produced empty output")
 	}
 }
+
+// TestParseErrors tests that syntax errors point at their line and column in
+// the source as written, counting comments and directives.
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		line   int
+		column int
+		token  string
+	}{
+		{"sequence syntax", "sequenceDiagram\n  %% a comment\n  A->>B: Hi\n    A => B", 4, 5, "A => B"},
+		{"after directive", "%%{init: {'theme': 'dark'}}%%\nsequenceDiagram\n  A->>B: Hi\n  nonsense", 4, 3, "nonsense"},
+		{"unclosed block", "sequenceDiagram\n  A->>B: Hi\n  loop forever\n  A->>B: Again", 3, 3, ""},
+		{"block keyword", "sequenceDiagram\n  A->>B: Hi\n  end", 3, 3, "end"},
+		{"quadrant syntax", "quadrantChart\n  x-axis Low --> High\n  what is this", 3, 3, "what is this"},
+		{"graph direction", "\n\ngraph XY\n  A --> B", 3, 7, "XY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := RenderDiagram(tt.input, nil)
+			var parseErr *diagram.ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Expected a ParseError, got %v", err)
+			}
+			if parseErr.Line != tt.line || parseErr.Column != tt.column || parseErr.Token != tt.token {
+				t.Errorf("Got line %d, column %d, token %q; want line %d, column %d, token %q",
+					parseErr.Line, parseErr.Column, parseErr.Token, tt.line, tt.column, tt.token)
+			}
+		})
+	}
+}
+
+// TestCaptions tests that titles and accessibility descriptions are shown
+// around the diagram rather than parsed as part of it.
+func TestCaptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantFirst string
+		wantLast  string
+	}{
+		{
+			name:      "front matter title and accDescr",
+			input:     "---\ntitle: \"Order flow\"\n---\ngraph LR\n  accDescr: Orders go to checkout\n  A --> B",
+			wantFirst: "Order flow",
+			wantLast:  "Orders go to checkout",
+		},
+		{
+			name:      "title keyword and accDescr block",
+			input:     "sequenceDiagram\n  title Greeting\n  accDescr {\n    Alice says hello\n    to Bob\n  }\n  Alice->>Bob: Hello",
+			wantFirst: "Greeting",
+			wantLast:  "to Bob",
+		},
+		{
+			name:      "accTitle without title",
+			input:     "graph TD\n  accTitle: Pipeline\n  A --> B",
+			wantFirst: "Pipeline",
+			wantLast:  "└───┘",
+		},
+		{
+			name:      "flowchart node named title",
+			input:     "graph TD\n  title --> B",
+			wantFirst: "┌───────┐",
+			wantLast:  "└───────┘",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := RenderDiagram(tt.input, diagram.NewTestConfig(false, "cli"))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+			if first := strings.TrimSpace(lines[0]); first != tt.wantFirst {
+				t.Errorf("First line = %q, want %q\nOutput:\n%s", first, tt.wantFirst, output)
+			}
+			if last := strings.TrimSpace(lines[len(lines)-1]); last != tt.wantLast {
+				t.Errorf("Last line = %q, want %q\nOutput:\n%s", last, tt.wantLast, output)
+			}
+			for _, keyword := range []string{"accTitle", "accDescr", "---"} {
+				if strings.Contains(output, keyword) {
+					t.Errorf("Output contains %q\nOutput:\n%s", keyword, output)
+				}
+			}
+		})
+	}
+}
@@ -1,8 +1,6 @@
 package ascii
 
 import (
-	"fmt"
-
 	log "github.com/sirupsen/logrus"
 )
 
@@ -14,6 +12,11 @@ type edge struct {
 	labelLine []gridCoord
 	startDir  direction
 	endDir    direction
+	style     edgeStyle
+	index     int               // Order of definition, as used by linkStyle
+	styles    map[string]string // Styles set with linkStyle
+	cells     []drawingCoord    // Drawn line, corner and arrowhead cells
+	textCells []drawingCoord    // Drawn label cells
 }
 
 func (g *graph) determinePath(e *edge) {
@@ -21,21 +24,16 @@ func (g *graph) determinePath(e *edge) {
 	var preferredPath, alternativePath []gridCoord
 	var from, to gridCoord
 	var err error
-	preferredDir, preferredOppositeDir, alternativeDir, alternativeOppositeDir := determineStartAndEndDir(e)
+	preferredDir, preferredOppositeDir, alternativeDir, alternativeOppositeDir := g.determineStartAndEndDir(e)
 
 	from = e.from.gridCoord.Direction(preferredDir)
 	to = e.to.gridCoord.Direction(preferredOppositeDir)
 	log.Debugf("Determining preferred path from %v (direction %v) to %v (direction %v)", *e.from, preferredDir, *e.to, preferredOppositeDir)
 
 	// Get preferred path
-	preferredPath, err = g.getPath(from, to)
-	if err != nil {
-		fmt.Printf("Error getting path from %v to %v: %v", from, to, err)
-		// This is a big assumption, but if we can't get the preferred path, we assume the alternative path is better
-		e.startDir = alternativeDir
-		e.endDir = alternativeOppositeDir
-		e.path = alternativePath
-		return
+	preferredPath, preferredErr := g.getPath(from, to)
+	if preferredErr != nil {
+		log.Debugf("Error getting path from %v to %v: %v", from, to, preferredErr)
 	}
 	preferredPath = mergePath(preferredPath)
 
@@ -46,12 +44,22 @@ func (g *graph) determinePath(e *edge) {
 
 	alternativePath, err = g.getPath(from, to)
 	if err != nil {
-		fmt.Printf("Error getting path from %v to %v: %v", from, to, err)
+		log.Debugf("Error getting path from %v to %v: %v", from, to, err)
+		if preferredErr != nil {
+			return
+		}
 		e.startDir = preferredDir
 		e.endDir = preferredOppositeDir
 		e.path = preferredPath
+		return
 	}
 	alternativePath = mergePath(alternativePath)
+	if preferredErr != nil {
+		e.startDir = alternativeDir
+		e.endDir = alternativeOppositeDir
+		e.path = alternativePath
+		return
+	}
 
 	nrStepsPreferred := len(preferredPath)
 	nrStepsAlternative := len(alternativePath)
@@ -68,10 +76,20 @@ func (g *graph) determinePath(e *edge) {
 	}
 }
 
+// hasEdge reports whether the graph has an edge from one node to another.
+func (g *graph) hasEdge(from, to *node) bool {
+	for _, e := range g.edges {
+		if e.from == from && e.to == to {
+			return true
+		}
+	}
+	return false
+}
+
 func (g *graph) determineLabelLine(e *edge) {
 	// What line on the path should the label be placed?
-	lenLabel := len(e.text)
-	if lenLabel == 0 {
+	lenLabel := textWidth(e.text)
+	if lenLabel == 0 || len(e.path) < 2 {
 		return
 	}
 	prevStep := e.path[0]
@@ -1,12 +1,19 @@
 package ascii
 
 import (
+	"regexp"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/muesli/reflow/wordwrap"
+	"github.com/muesli/reflow/wrap"
 	log "github.com/sirupsen/logrus"
 )
 
 type node struct {
 	name           string // Node ID for matching/lookup
 	displayName    string // Display text (empty = use name)
+	shape          nodeShape
 	drawing        *drawing
 	drawingCoord   *drawingCoord
 	gridCoord      *gridCoord
@@ -24,6 +31,35 @@ func (n *node) getDisplayName() string {
 	return n.name
 }
 
+// lineBreakRegex matches the <br> tags that break labels onto several lines.
+var lineBreakRegex = regexp.MustCompile(`(?i)<br\s*/?>`)
+
+// labelLines returns the display text broken at <br> tags, with each line
+// wrapped at width, or left whole if width is 0. Words longer than width are
+// broken too.
+func (n *node) labelLines(width int) []string {
+	var lines []string
+	for _, line := range lineBreakRegex.Split(n.getDisplayName(), -1) {
+		line = strings.TrimSpace(line)
+		if width <= 0 {
+			lines = append(lines, line)
+			continue
+		}
+		lines = append(lines, strings.Split(wrap.String(wordwrap.String(line, width), width), "\n")...)
+	}
+	return lines
+}
+
+// labelTextWidth returns the width of the widest line of a label broken at <br>
+// tags.
+func labelTextWidth(label string) int {
+	widest := 0
+	for _, line := range lineBreakRegex.Split(label, -1) {
+		widest = Max(widest, textWidth(strings.TrimSpace(line)))
+	}
+	return widest
+}
+
 func (n node) String() string {
 	return n.name
 }
@@ -44,11 +80,16 @@ func (g *graph) setColumnWidth(n *node) {
 	// - 1 line of text
 	// - 2x padding
 	// - 2x margin
+	lines := n.labelLines(g.labelWidth)
+	textWidth := 0
+	for _, line := range lines {
+		textWidth = Max(textWidth, runewidth.StringWidth(line))
+	}
 	col1 := 1
-	col2 := 2*boxBorderPadding + len(n.getDisplayName())
+	col2 := 2*g.options.BoxBorderPadding + textWidth
 	col3 := 1
 	colsToBePlaced := []int{col1, col2, col3}
-	rowsToBePlaced := []int{1, 1 + 2*boxBorderPadding, 1} // Border, padding + line, border
+	rowsToBePlaced := []int{1, len(lines) + 2*g.options.BoxBorderPadding, 1} // Border, padding + lines, border
 
 	for idx, col := range colsToBePlaced {
 		// Set new width for column if the size increased
@@ -64,10 +105,10 @@ func (g *graph) setColumnWidth(n *node) {
 
 	// Set padding before node
 	if n.gridCoord.x > 0 {
-		g.columnWidth[n.gridCoord.x-1] = g.paddingX // TODO: x2?
+		g.columnWidth[n.gridCoord.x-1] = g.options.PaddingX // TODO: x2?
 	}
 	if n.gridCoord.y > 0 {
-		basePadding := g.paddingY
+		basePadding := g.options.PaddingY
 
 		// Add extra padding if node is in a subgraph AND has incoming edges from outside
 		// This accounts for subgraph visual overhead (border, label, padding)
@@ -87,10 +128,10 @@ func (g *graph) setColumnWidth(n *node) {
 func (g *graph) increaseGridSizeForPath(path []gridCoord) {
 	for _, c := range path {
 		if _, exists := g.columnWidth[c.x]; !exists {
-			g.columnWidth[c.x] = g.paddingX / 2
+			g.columnWidth[c.x] = g.options.PaddingX / 2
 		}
 		if _, exists := g.rowHeight[c.y]; !exists {
-			g.rowHeight[c.y] = g.paddingY / 2
+			g.rowHeight[c.y] = g.options.PaddingY / 2
 		}
 	}
 }
@@ -99,7 +140,7 @@ func (g *graph) reserveSpotInGrid(n *node, requestedCoord *gridCoord) *gridCoord
 	if g.grid[*requestedCoord] != nil {
 		log.Debugf("Coord %d,%d is already taken", requestedCoord.x, requestedCoord.y)
 		// Next column is 4 coords further. This is because every node is 3 coords wide + 1 coord inbetween.
-		if graphDirection == "LR" {
+		if g.layout == "LR" {
 			return g.reserveSpotInGrid(n, &gridCoord{x: requestedCoord.x, y: requestedCoord.y + 4})
 		} else {
 			return g.reserveSpotInGrid(n, &gridCoord{x: requestedCoord.x + 4, y: requestedCoord.y})
@@ -116,3 +157,138 @@ func (g *graph) reserveSpotInGrid(n *node, requestedCoord *gridCoord) *gridCoord
 	n.gridCoord = requestedCoord
 	return requestedCoord
 }
+
+// reserveSelfLoopLanes moves the layout right and down by a line if a node
+// of its first column or row loops to itself, so the loop has a lane on that
+// side of the node if it needs one. It returns the shift.
+func (g *graph) reserveSelfLoopLanes() gridCoord {
+	var d gridCoord
+	for _, e := range g.edges {
+		if e.from != e.to {
+			continue
+		}
+		if e.from.gridCoord.x == 0 {
+			d.x = 1
+		}
+		if e.from.gridCoord.y == 0 {
+			d.y = 1
+		}
+	}
+	if d.x == 0 && d.y == 0 {
+		return d
+	}
+
+	grid := make(map[gridCoord]*node, len(g.grid))
+	for c, n := range g.grid {
+		grid[gridCoord{x: c.x + d.x, y: c.y + d.y}] = n
+	}
+	g.grid = grid
+	for _, n := range g.nodes {
+		n.gridCoord = &gridCoord{x: n.gridCoord.x + d.x, y: n.gridCoord.y + d.y}
+	}
+	shift := func(sizes map[int]int, by int) map[int]int {
+		if by == 0 {
+			return sizes
+		}
+		shifted := map[int]int{0: 1}
+		for c, size := range sizes {
+			shifted[c+by] = size
+		}
+		return shifted
+	}
+	g.columnWidth = shift(g.columnWidth, d.x)
+	g.rowHeight = shift(g.rowHeight, d.y)
+	return d
+}
+
+// trimSelfLoopLanes collapses the lanes reserveSelfLoopLanes reserved that
+// no edge took.
+func (g *graph) trimSelfLoopLanes(lanes gridCoord) {
+	for _, e := range g.edges {
+		for _, c := range e.path {
+			if c.x == 0 {
+				lanes.x = 0
+			}
+			if c.y == 0 {
+				lanes.y = 0
+			}
+		}
+	}
+	if lanes.x > 0 {
+		g.columnWidth[0] = 0
+	}
+	if lanes.y > 0 {
+		g.rowHeight[0] = 0
+	}
+}
+
+// mirrorLayout flips the finished grid layout horizontally (for RL) or
+// vertically (for BT). The layout passes only know about LR and TD, so
+// reversed directions are laid out as their forward counterpart and mirrored
+// before anything is drawn.
+func (g *graph) mirrorLayout(horizontal bool) {
+	axis := func(c gridCoord) int {
+		if horizontal {
+			return c.x
+		}
+		return c.y
+	}
+	flip := func(c gridCoord, max int) gridCoord {
+		if horizontal {
+			return gridCoord{x: max - c.x, y: c.y}
+		}
+		return gridCoord{x: c.x, y: max - c.y}
+	}
+	flipDir := func(d direction) direction {
+		if horizontal {
+			return direction{2 - d.x, d.y}
+		}
+		return direction{d.x, 2 - d.y}
+	}
+
+	sizes := g.rowHeight
+	if horizontal {
+		sizes = g.columnWidth
+	}
+	maxCoord := 0
+	for c := range sizes {
+		maxCoord = Max(maxCoord, c)
+	}
+	for c := range g.grid {
+		maxCoord = Max(maxCoord, axis(c))
+	}
+
+	mirrored := make(map[int]int, len(sizes))
+	for c, size := range sizes {
+		mirrored[maxCoord-c] = size
+	}
+	if horizontal {
+		g.columnWidth = mirrored
+	} else {
+		g.rowHeight = mirrored
+	}
+
+	grid := make(map[gridCoord]*node, len(g.grid))
+	for c, n := range g.grid {
+		grid[flip(c, maxCoord)] = n
+	}
+	g.grid = grid
+
+	// A node's grid coord is its top-left corner, which becomes the far
+	// corner of its 3x3 block once mirrored
+	for _, n := range g.nodes {
+		c := flip(*n.gridCoord, maxCoord-2)
+		n.gridCoord = &c
+	}
+
+	for _, e := range g.edges {
+		for i, c := range e.path {
+			e.path[i] = flip(c, maxCoord)
+		}
+		for i, c := range e.labelLine {
+			e.labelLine[i] = flip(c, maxCoord)
+		}
+		e.startDir = flipDir(e.startDir)
+		e.endDir = flipDir(e.endDir)
+	}
+}
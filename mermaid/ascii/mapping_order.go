@@ -0,0 +1,172 @@
+package ascii
+
+import (
+	"slices"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// orderSweeps is how many times orderLevels sweeps down and up the levels.
+const orderSweeps = 4
+
+// orderLevels reorders the nodes within each level of a TD layout to reduce
+// the number of edges crossing each other. It's the barycenter heuristic:
+// sweeping down the levels, each node moves towards the average position of
+// its neighbors in the levels above, then sweeping up, towards those below.
+// Nodes keep the positions their level already had, only their order
+// changes, and layouts are only changed if they have fewer crossings.
+func (g *graph) orderLevels() {
+	if g.layout != "TD" || len(g.subgraphs) > 0 {
+		return
+	}
+
+	levels := g.levels()
+	if len(levels) < 2 {
+		return
+	}
+	slots := make(map[int][]int, len(levels))
+	for _, level := range levels {
+		for _, n := range level {
+			slots[n.gridCoord.y] = append(slots[n.gridCoord.y], n.gridCoord.x)
+		}
+	}
+
+	initialCrossings := g.countCrossings()
+	best, bestCrossings := g.positions(), initialCrossings
+	for sweep := 0; sweep < orderSweeps && bestCrossings > 0; sweep++ {
+		down := sweep%2 == 0
+		for i := range levels {
+			level := levels[i]
+			if !down {
+				level = levels[len(levels)-1-i]
+			}
+			g.orderLevel(level, slots[level[0].gridCoord.y], down)
+		}
+		if crossings := g.countCrossings(); crossings < bestCrossings {
+			best, bestCrossings = g.positions(), crossings
+		}
+	}
+
+	for n, c := range best {
+		n.gridCoord.x = c.x
+	}
+	if bestCrossings < initialCrossings {
+		log.Debugf("Reordered levels from %d to %d crossings", initialCrossings, bestCrossings)
+	}
+
+	// Move the reservations along with the nodes
+	g.grid = make(map[gridCoord]*node, len(g.grid))
+	for _, n := range g.nodes {
+		if n.gridCoord != nil {
+			g.reserveSpotInGrid(n, n.gridCoord)
+		}
+	}
+}
+
+// levels returns the nodes of the layout grouped by row, each ordered left to
+// right, with the rows ordered top to bottom.
+func (g *graph) levels() [][]*node {
+	byRow := make(map[int][]*node)
+	for _, n := range g.nodes {
+		if n.gridCoord != nil {
+			byRow[n.gridCoord.y] = append(byRow[n.gridCoord.y], n)
+		}
+	}
+	rows := make([]int, 0, len(byRow))
+	for y := range byRow {
+		rows = append(rows, y)
+	}
+	slices.Sort(rows)
+
+	levels := make([][]*node, 0, len(rows))
+	for _, y := range rows {
+		level := byRow[y]
+		sort.SliceStable(level, func(i, j int) bool { return level[i].gridCoord.x < level[j].gridCoord.x })
+		levels = append(levels, level)
+	}
+	return levels
+}
+
+// orderLevel sorts a level by the barycenter of each node's neighbors above
+// it (or below, if not down) and moves the nodes into the level's slots in
+// that order. Nodes without such neighbors sort by where they are.
+func (g *graph) orderLevel(level []*node, slots []int, down bool) {
+	y := level[0].gridCoord.y
+	barycenter := make(map[*node]float64, len(level))
+	for _, n := range level {
+		sum, count := 0, 0
+		for _, e := range g.edges {
+			var other *node
+			switch {
+			case e.from == e.to:
+				continue
+			case e.from == n:
+				other = e.to
+			case e.to == n:
+				other = e.from
+			default:
+				continue
+			}
+			if (down && other.gridCoord.y < y) || (!down && other.gridCoord.y > y) {
+				sum += other.gridCoord.x
+				count++
+			}
+		}
+		barycenter[n] = float64(n.gridCoord.x)
+		if count > 0 {
+			barycenter[n] = float64(sum) / float64(count)
+		}
+	}
+
+	sort.SliceStable(level, func(i, j int) bool { return barycenter[level[i]] < barycenter[level[j]] })
+	for i, n := range level {
+		n.gridCoord.x = slots[i]
+	}
+}
+
+// countCrossings returns the number of pairs of edges that cross, taking
+// each edge as a straight line between the centers of its nodes.
+func (g *graph) countCrossings() int {
+	crossings := 0
+	for i, e := range g.edges {
+		for _, f := range g.edges[i+1:] {
+			if e.from == f.from || e.from == f.to || e.to == f.from || e.to == f.to {
+				continue
+			}
+			if segmentsCross(*e.from.gridCoord, *e.to.gridCoord, *f.from.gridCoord, *f.to.gridCoord) {
+				crossings++
+			}
+		}
+	}
+	return crossings
+}
+
+// segmentsCross reports whether the line from a to b properly crosses the
+// line from c to d.
+func segmentsCross(a, b, c, d gridCoord) bool {
+	orientation := func(p, q, r gridCoord) int {
+		v := (q.x-p.x)*(r.y-p.y) - (q.y-p.y)*(r.x-p.x)
+		switch {
+		case v > 0:
+			return 1
+		case v < 0:
+			return -1
+		}
+		return 0
+	}
+	o1, o2 := orientation(a, b, c), orientation(a, b, d)
+	o3, o4 := orientation(c, d, a), orientation(c, d, b)
+	return o1*o2 < 0 && o3*o4 < 0
+}
+
+// positions returns the grid coordinates of the laid out nodes.
+func (g *graph) positions() map[*node]gridCoord {
+	positions := make(map[*node]gridCoord, len(g.nodes))
+	for _, n := range g.nodes {
+		if n.gridCoord != nil {
+			positions[n] = *n.gridCoord
+		}
+	}
+	return positions
+}
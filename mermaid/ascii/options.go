@@ -0,0 +1,47 @@
+package ascii
+
+import "github.com/hholst80/glow/mermaid/ascii/diagram"
+
+// RenderOptions configures how a flowchart is laid out and drawn. Each render
+// carries its own options, so diagrams with different settings can be
+// rendered concurrently.
+type RenderOptions struct {
+	// BoxBorderPadding is the padding between text and border in nodes
+	BoxBorderPadding int
+	// PaddingX is the horizontal space between nodes
+	PaddingX int
+	// PaddingY is the vertical space between nodes
+	PaddingY int
+	// UseAscii disables extended Unicode characters
+	UseAscii bool
+	// ShowCoords wraps the drawing in grid coordinates, for debugging
+	ShowCoords bool
+}
+
+// DefaultRenderOptions returns the options used unless configured.
+func DefaultRenderOptions() RenderOptions {
+	return RenderOptions{
+		BoxBorderPadding: 1,
+		PaddingX:         5,
+		PaddingY:         5,
+	}
+}
+
+// renderOptions returns the options set by config. Padding set by directives
+// in the diagram source takes precedence over config's.
+func (gp *graphProperties) renderOptions(config *diagram.Config) RenderOptions {
+	opts := RenderOptions{
+		BoxBorderPadding: config.BoxBorderPadding,
+		PaddingX:         config.PaddingBetweenX,
+		PaddingY:         config.PaddingBetweenY,
+		UseAscii:         config.UseAscii,
+		ShowCoords:       config.ShowCoords,
+	}
+	if gp.paddingXSet {
+		opts.PaddingX = gp.options.PaddingX
+	}
+	if gp.paddingYSet {
+		opts.PaddingY = gp.options.PaddingY
+	}
+	return opts
+}
@@ -6,8 +6,10 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/elliotchance/orderedmap/v2"
+	"github.com/hholst80/glow/mermaid/ascii/diagram"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -16,15 +18,22 @@ type graphProperties struct {
 	styleClasses   *map[string]styleClass
 	graphDirection string
 	styleType      string
-	paddingX       int
-	paddingY       int
+	options        RenderOptions
+	paddingXSet    bool // Whether the source set paddingX
+	paddingYSet    bool // Whether the source set paddingY
 	subgraphs      []*textSubgraph
-	useAscii       bool
+	nodeClasses    map[string]string            // Class assigned with ::: or class
+	nodeStyles     map[string]map[string]string // Styles set with style
+	linkStyles     map[int]map[string]string    // Styles set with linkStyle, by edge index
+	edgeCount      int
+	palette        *diagram.Palette // Theme colors for cli output
+	labelWidth     int              // Width node labels wrap at; 0 doesn't wrap
 }
 
 type textNode struct {
 	name        string // Node ID used for matching/lookup
 	displayName string // Display text shown in rendered output (empty = use name)
+	shape       nodeShape
 	styleClass  string
 }
 
@@ -32,6 +41,8 @@ type textEdge struct {
 	parent textNode
 	child  textNode
 	label  string
+	style  edgeStyle
+	index  int // Order of definition, as used by linkStyle
 }
 
 type textSubgraph struct {
@@ -47,7 +58,8 @@ type textSubgraph struct {
 var nodeWithLabelRegex = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_]*)(\[.*\]|\(.*\)|\{.*\}|>.*\])$`)
 
 // extractLabelText extracts the text content from a Mermaid label, removing shape brackets.
-// Examples: "[Label]" -> "Label", "[(db)]" -> "db", "([stadium])" -> "stadium"
+// Examples: "[Label]" -> "Label", "[(db)]" -> "db", "([stadium])" -> "stadium",
+// `["quoted"]` -> "quoted"
 func extractLabelText(label string) string {
 	if len(label) < 2 {
 		return label
@@ -66,7 +78,12 @@ func extractLabelText(label string) string {
 			break
 		}
 	}
-	return strings.TrimSpace(inner)
+	// Quotes allow text that would otherwise end the label
+	inner = strings.TrimSpace(inner)
+	if len(inner) >= 2 && inner[0] == '"' && inner[len(inner)-1] == '"' {
+		inner = inner[1 : len(inner)-1]
+	}
+	return inner
 }
 
 func parseNode(line string) textNode {
@@ -87,7 +104,7 @@ func parseNode(line string) textNode {
 	if match := nodeWithLabelRegex.FindStringSubmatch(trimmedLine); match != nil {
 		nodeID := strings.TrimSpace(match[1])
 		labelText := extractLabelText(match[2])
-		return textNode{name: nodeID, displayName: labelText, styleClass: ""}
+		return textNode{name: nodeID, displayName: labelText, shape: parseNodeShape(match[2]), styleClass: ""}
 	}
 
 	// Plain node without label - use name for both ID and display
@@ -95,30 +112,72 @@ func parseNode(line string) textNode {
 }
 
 func parseStyleClass(matchedLine []string) styleClass {
-	className := matchedLine[0]
-	styles := matchedLine[1]
-	// Styles are comma separated and key-values are separated by colon
-	// Example: fill:#f9f,stroke:#333,stroke-width:4px
+	return styleClass{matchedLine[0], parseStyles(matchedLine[1])}
+}
+
+// parseStyles parses a mermaid style list into a map.
+// Styles are comma separated and key-values are separated by colon
+// Example: fill:#f9f,stroke:#333,stroke-width:4px
+func parseStyles(styles string) map[string]string {
 	styleMap := make(map[string]string)
-	for _, style := range strings.Split(styles, ",") {
-		kv := strings.Split(style, ":")
-		styleMap[kv[0]] = kv[1]
+	for _, style := range strings.Split(strings.TrimSuffix(strings.TrimSpace(styles), ";"), ",") {
+		key, value, ok := strings.Cut(style, ":")
+		if !ok {
+			continue
+		}
+		styleMap[strings.TrimSpace(key)] = strings.TrimSpace(value)
 	}
-	return styleClass{className, styleMap}
+	return styleMap
 }
 
-func setArrowWithLabel(lhs, rhs []textNode, label string, data *orderedmap.OrderedMap[string, []textEdge]) []textNode {
+func (gp *graphProperties) setArrowWithLabel(lhs, rhs []textNode, label string, style edgeStyle) []textNode {
 	log.Debug("Setting arrow from ", lhs, " to ", rhs, " with label ", label)
 	for _, l := range lhs {
+		gp.setClass(l)
 		for _, r := range rhs {
-			setData(l, textEdge{l, r, label}, data)
+			gp.setClass(r)
+			setData(l, textEdge{parent: l, child: r, label: label, style: style, index: gp.edgeCount}, gp.data)
+			gp.edgeCount++
 		}
 	}
 	return rhs
 }
 
-func setArrow(lhs, rhs []textNode, data *orderedmap.OrderedMap[string, []textEdge]) []textNode {
-	return setArrowWithLabel(lhs, rhs, "", data)
+func (gp *graphProperties) setArrow(lhs, rhs []textNode, style edgeStyle) []textNode {
+	return gp.setArrowWithLabel(lhs, rhs, "", style)
+}
+
+// setDirection sets the direction of the graph, reporting whether it is
+// supported. TB is an alias for TD.
+func (gp *graphProperties) setDirection(direction string) bool {
+	switch direction {
+	case "LR", "RL", "TD", "BT":
+		gp.graphDirection = direction
+	case "TB":
+		gp.graphDirection = "TD"
+	default:
+		return false
+	}
+	return true
+}
+
+// layoutDirection returns the direction the layout passes work in. RL and BT
+// are laid out as LR and TD and mirrored afterwards.
+func (gp *graphProperties) layoutDirection() string {
+	switch gp.graphDirection {
+	case "RL":
+		return "LR"
+	case "BT":
+		return "TD"
+	}
+	return gp.graphDirection
+}
+
+// setClass records the class of a node annotated with :::class.
+func (gp *graphProperties) setClass(node textNode) {
+	if node.styleClass != "" {
+		gp.nodeClasses[node.name] = node.styleClass
+	}
 }
 
 func addNode(node textNode, data *orderedmap.OrderedMap[string, []textEdge]) {
@@ -162,19 +221,24 @@ func (gp *graphProperties) parseString(line string) ([]textNode, error) {
 			},
 		},
 		{
-			regex: regexp.MustCompile(`^(.+)\s+-->\s+(.+)$`),
+			// Label inside the link: A -- label --> B, A == label ==> B,
+			// A -. label .-> B
+			regex: regexp.MustCompile(`^(.+?)\s+(<?(?:--|==|-\.))\s+(.+?)\s+(-->|---|==>|===|\.->|\.-)\s*(.+)$`),
 			handler: func(match []string) ([]textNode, error) {
+				// Chains like A -- x --> B --> C are left to the plain arrow
+				// pattern, which splits off the last arrow first.
+				if arrowRegex.MatchString(match[4]) {
+					return nil, errors.New("chained arrow")
+				}
 				if lhs, err = gp.parseString(match[0]); err != nil {
 					lhs = []textNode{parseNode(match[0])}
 				}
-				if rhs, err = gp.parseString(match[1]); err != nil {
-					rhs = []textNode{parseNode(match[1])}
-				}
-				return setArrow(lhs, rhs, gp.data), nil
+				rhs = []textNode{parseNode(match[4])}
+				return gp.setArrowWithLabel(lhs, rhs, strings.TrimSpace(match[2]), parseEdgeStyle(match[1]+match[3])), nil
 			},
 		},
 		{
-			regex: regexp.MustCompile(`^(.+)\s+-->\|(.+)\|\s+(.+)$`),
+			regex: regexp.MustCompile(`^(.+)\s+(` + arrowPattern + `)\s+(.+)$`),
 			handler: func(match []string) ([]textNode, error) {
 				if lhs, err = gp.parseString(match[0]); err != nil {
 					lhs = []textNode{parseNode(match[0])}
@@ -182,14 +246,67 @@ func (gp *graphProperties) parseString(line string) ([]textNode, error) {
 				if rhs, err = gp.parseString(match[2]); err != nil {
 					rhs = []textNode{parseNode(match[2])}
 				}
-				return setArrowWithLabel(lhs, rhs, match[1], gp.data), nil
+				return gp.setArrow(lhs, rhs, parseEdgeStyle(match[1])), nil
 			},
 		},
 		{
-			regex: regexp.MustCompile(`^classDef\s+(.+)\s+(.+)$`),
+			regex: regexp.MustCompile(`^(.+?)\s*(` + arrowPattern + `)\|(.+?)\|\s*(.+)$`),
+			handler: func(match []string) ([]textNode, error) {
+				if lhs, err = gp.parseString(match[0]); err != nil {
+					lhs = []textNode{parseNode(match[0])}
+				}
+				if rhs, err = gp.parseString(match[3]); err != nil {
+					rhs = []textNode{parseNode(match[3])}
+				}
+				return gp.setArrowWithLabel(lhs, rhs, strings.TrimSpace(match[2]), parseEdgeStyle(match[1])), nil
+			},
+		},
+		{
+			regex: regexp.MustCompile(`^classDef\s+(\S+)\s+(.+)$`),
 			handler: func(match []string) ([]textNode, error) {
 				s := parseStyleClass(match)
-				(*gp.styleClasses)[s.name] = s
+				// One definition may name several classes: classDef a,b fill:#f9f
+				for _, name := range strings.Split(s.name, ",") {
+					(*gp.styleClasses)[name] = styleClass{name, s.styles}
+				}
+				return []textNode{}, nil
+			},
+		},
+		{
+			// class A,B className
+			regex: regexp.MustCompile(`^class\s+(\S+)\s+(\S+)$`),
+			handler: func(match []string) ([]textNode, error) {
+				for _, name := range strings.Split(match[0], ",") {
+					gp.nodeClasses[strings.TrimSpace(name)] = match[1]
+				}
+				return []textNode{}, nil
+			},
+		},
+		{
+			// style A fill:#f9f,stroke:#333
+			regex: regexp.MustCompile(`^style\s+(\S+)\s+(.+)$`),
+			handler: func(match []string) ([]textNode, error) {
+				gp.nodeStyles[match[0]] = parseStyles(match[1])
+				return []textNode{}, nil
+			},
+		},
+		{
+			// linkStyle 0,2 stroke:#f00 or linkStyle default stroke:#f00
+			regex: regexp.MustCompile(`^linkStyle\s+(\S+)\s+(.+)$`),
+			handler: func(match []string) ([]textNode, error) {
+				styles := parseStyles(match[1])
+				for _, index := range strings.Split(match[0], ",") {
+					if index == "default" {
+						gp.linkStyles[defaultLinkStyle] = styles
+						continue
+					}
+					i, err := strconv.Atoi(index)
+					if err != nil {
+						log.Debugf("Ignoring invalid linkStyle index %q", index)
+						continue
+					}
+					gp.linkStyles[i] = styles
+				}
 				return []textNode{}, nil
 			},
 		},
@@ -226,9 +343,11 @@ func mermaidFileToMap(mermaid, styleType string) (*graphProperties, error) {
 	newlinePattern := regexp.MustCompile(`\n|\\n`)
 	rawLines := newlinePattern.Split(string(mermaid), -1)
 
-	// Process lines to remove comments
+	// Process lines to remove comments, remembering where each line was for
+	// errors
 	lines := []string{}
-	for _, line := range rawLines {
+	lineNumbers := []int{}
+	for i, line := range rawLines {
 		// Stop processing at "---" separator (used in test files)
 		if line == "---" {
 			break
@@ -247,6 +366,7 @@ func mermaidFileToMap(mermaid, styleType string) (*graphProperties, error) {
 		// Skip empty lines after comment removal
 		if len(strings.TrimSpace(line)) > 0 {
 			lines = append(lines, line)
+			lineNumbers = append(lineNumbers, i+1)
 		}
 	}
 
@@ -257,9 +377,11 @@ func mermaidFileToMap(mermaid, styleType string) (*graphProperties, error) {
 		styleClasses:   &styleClasses,
 		graphDirection: "",
 		styleType:      styleType,
-		paddingX:       paddingBetweenX,
-		paddingY:       paddingBetweenY,
+		options:        DefaultRenderOptions(),
 		subgraphs:      []*textSubgraph{},
+		nodeClasses:    make(map[string]string),
+		nodeStyles:     make(map[string]map[string]string),
+		linkStyles:     make(map[int]map[string]string),
 	}
 
 	// Pick up optional padding directives before the graph definition
@@ -267,7 +389,7 @@ func mermaidFileToMap(mermaid, styleType string) (*graphProperties, error) {
 	for len(lines) > 0 {
 		trimmed := strings.TrimSpace(lines[0])
 		if trimmed == "" {
-			lines = lines[1:]
+			lines, lineNumbers = lines[1:], lineNumbers[1:]
 			continue
 		}
 		if match := paddingRegex.FindStringSubmatch(trimmed); match != nil {
@@ -276,11 +398,13 @@ func mermaidFileToMap(mermaid, styleType string) (*graphProperties, error) {
 				return &properties, err
 			}
 			if strings.EqualFold(match[1], "x") {
-				properties.paddingX = paddingValue
+				properties.options.PaddingX = paddingValue
+				properties.paddingXSet = true
 			} else {
-				properties.paddingY = paddingValue
+				properties.options.PaddingY = paddingValue
+				properties.paddingYSet = true
 			}
-			lines = lines[1:]
+			lines, lineNumbers = lines[1:], lineNumbers[1:]
 			continue
 		}
 		break
@@ -290,14 +414,20 @@ func mermaidFileToMap(mermaid, styleType string) (*graphProperties, error) {
 		return &properties, errors.New("missing graph definition")
 	}
 
-	// First line should either say "graph TD" or "graph LR"
-	switch lines[0] {
-	case "graph LR", "flowchart LR":
-		graphDirection = "LR"
-	case "graph TD", "flowchart TD", "graph TB", "flowchart TB":
-		graphDirection = "TD"
-	default:
-		return &properties, fmt.Errorf("unsupported graph type '%s'. Supported types: graph TD, graph TB, graph LR, flowchart TD, flowchart TB, flowchart LR", lines[0])
+	// First line should declare the graph and its direction, e.g. "graph TD"
+	// or "flowchart RL"
+	header := strings.Fields(lines[0])
+	if len(header) != 2 || (header[0] != "graph" && header[0] != "flowchart") || !properties.setDirection(header[1]) {
+		err := fmt.Errorf("unsupported graph type '%s'. Supported types: graph or flowchart followed by TD, TB, BT, LR or RL", strings.TrimSpace(lines[0]))
+		token := strings.TrimSpace(lines[0])
+		if len(header) > 0 {
+			token = header[0]
+			if len(header) > 1 && (header[0] == "graph" || header[0] == "flowchart") {
+				token = header[1]
+			}
+		}
+		column := utf8.RuneCountInString(lines[0][:strings.Index(lines[0], token)]) + 1
+		return &properties, &diagram.ParseError{Line: lineNumbers[0], Column: column, Token: token, Err: err}
 	}
 	lines = lines[1:]
 
@@ -358,10 +488,12 @@ func mermaidFileToMap(mermaid, styleType string) (*graphProperties, error) {
 		if err != nil {
 			log.Debugf("Parsing remaining text to node %v", line)
 			node := parseNode(line)
+			properties.setClass(node)
 			addNode(node, properties.data)
 		} else {
 			// Ensure all returned nodes are in the map
 			for _, node := range nodes {
+				properties.setClass(node)
 				addNode(node, properties.data)
 			}
 		}
@@ -0,0 +1,47 @@
+package quadrant
+
+// BoxChars defines the characters used for drawing the chart.
+type BoxChars struct {
+	TopLeft     rune
+	TopRight    rune
+	BottomLeft  rune
+	BottomRight rune
+	Horizontal  rune
+	Vertical    rune
+	TeeDown     rune
+	TeeUp       rune
+	TeeRight    rune
+	TeeLeft     rune
+	Cross       rune
+	Point       rune
+}
+
+var ASCII = BoxChars{
+	TopLeft:     '+',
+	TopRight:    '+',
+	BottomLeft:  '+',
+	BottomRight: '+',
+	Horizontal:  '-',
+	Vertical:    '|',
+	TeeDown:     '+',
+	TeeUp:       '+',
+	TeeRight:    '+',
+	TeeLeft:     '+',
+	Cross:       '+',
+	Point:       '*',
+}
+
+var Unicode = BoxChars{
+	TopLeft:     '┌',
+	TopRight:    '┐',
+	BottomLeft:  '└',
+	BottomRight: '┘',
+	Horizontal:  '─',
+	Vertical:    '│',
+	TeeDown:     '┬',
+	TeeUp:       '┴',
+	TeeRight:    '├',
+	TeeLeft:     '┤',
+	Cross:       '┼',
+	Point:       '●',
+}
@@ -0,0 +1,136 @@
+package quadrant
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hholst80/glow/mermaid/ascii/diagram"
+)
+
+const QuadrantChartKeyword = "quadrantChart"
+
+var (
+	// axisRegex matches axis declarations: x-axis [Low] --> [High]
+	axisRegex = regexp.MustCompile(`^\s*([xy])-axis\s+(.*?)(?:\s*-->\s*(.*))?$`)
+
+	// quadrantRegex matches quadrant labels: quadrant-[1-4] [Label]
+	quadrantRegex = regexp.MustCompile(`^\s*quadrant-([1-4])\s+(.+)$`)
+
+	// pointRegex matches points: [Label]: [x, y], ignoring any trailing styling
+	pointRegex = regexp.MustCompile(`^\s*(.+?)\s*:\s*\[\s*([0-9.]+)\s*,\s*([0-9.]+)\s*\]`)
+)
+
+// QuadrantChart represents a parsed quadrant chart.
+type QuadrantChart struct {
+	Title string
+
+	XAxisLow  string
+	XAxisHigh string
+	YAxisLow  string
+	YAxisHigh string
+
+	// Quadrants holds the labels of quadrants 1 to 4: top-right, top-left,
+	// bottom-left and bottom-right, as in mermaid.
+	Quadrants [4]string
+
+	Points []*Point
+}
+
+// Point is a labeled point with coordinates between 0 and 1.
+type Point struct {
+	Label string
+	X     float64
+	Y     float64
+}
+
+func IsQuadrantChart(input string) bool {
+	lines := strings.Split(input, "\n")
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "%%") {
+			continue
+		}
+		return strings.HasPrefix(trimmed, QuadrantChartKeyword)
+	}
+	return false
+}
+
+func Parse(input string) (*QuadrantChart, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, fmt.Errorf("empty input")
+	}
+
+	// Line numbers count from the start of input, so that errors point at
+	// the source as written
+	lines := diagram.NumberedLines(input)
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("no content found")
+	}
+
+	if !strings.HasPrefix(lines[0].Text, QuadrantChartKeyword) {
+		return nil, lines[0].ErrorAt(fmt.Errorf("expected %q keyword", QuadrantChartKeyword), strings.Fields(lines[0].Text)[0])
+	}
+	lines = lines[1:]
+
+	qc := &QuadrantChart{}
+
+	for _, line := range lines {
+		trimmed := line.Text
+
+		if title, ok := strings.CutPrefix(trimmed, "title "); ok {
+			qc.Title = strings.TrimSpace(title)
+			continue
+		}
+
+		if match := axisRegex.FindStringSubmatch(trimmed); match != nil {
+			low, high := unquote(match[2]), unquote(match[3])
+			if match[1] == "x" {
+				qc.XAxisLow, qc.XAxisHigh = low, high
+			} else {
+				qc.YAxisLow, qc.YAxisHigh = low, high
+			}
+			continue
+		}
+
+		if match := quadrantRegex.FindStringSubmatch(trimmed); match != nil {
+			n, _ := strconv.Atoi(match[1])
+			qc.Quadrants[n-1] = unquote(match[2])
+			continue
+		}
+
+		if match := pointRegex.FindStringSubmatch(trimmed); match != nil {
+			p, err := parsePoint(match)
+			if err != nil {
+				return nil, line.ErrorAt(err, "")
+			}
+			qc.Points = append(qc.Points, p)
+			continue
+		}
+
+		return nil, line.ErrorAt(errors.New("invalid syntax"), trimmed)
+	}
+
+	return qc, nil
+}
+
+func parsePoint(match []string) (*Point, error) {
+	x, err := strconv.ParseFloat(match[2], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid x coordinate %q", match[2])
+	}
+	y, err := strconv.ParseFloat(match[3], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid y coordinate %q", match[3])
+	}
+	if x < 0 || x > 1 || y < 0 || y > 1 {
+		return nil, fmt.Errorf("point %q: coordinates must be between 0 and 1", match[1])
+	}
+	return &Point{Label: unquote(match[1]), X: x, Y: y}, nil
+}
+
+func unquote(s string) string {
+	return strings.Trim(strings.TrimSpace(s), `"`)
+}
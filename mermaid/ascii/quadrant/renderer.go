@@ -0,0 +1,240 @@
+package quadrant
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/hholst80/glow/mermaid/ascii/diagram"
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	minQuadrantWidth    = 20
+	quadrantHeight      = 6
+	quadrantLabelMargin = 2
+	pointLabelGap       = 1
+)
+
+// canvas is a grid of terminal cells. Wide characters occupy their own cell
+// followed by an empty continuation cell.
+type canvas [][]string
+
+func newCanvas(width, height int) canvas {
+	c := make(canvas, height)
+	for y := range c {
+		c[y] = make([]string, width)
+		for x := range c[y] {
+			c[y][x] = " "
+		}
+	}
+	return c
+}
+
+// put writes s starting at column x of row y, clipping at the right edge.
+func (c canvas) put(x, y int, s string) {
+	if y < 0 || y >= len(c) {
+		return
+	}
+	row := c[y]
+	for _, r := range s {
+		w := runewidth.RuneWidth(r)
+		if w == 0 {
+			continue
+		}
+		if x < 0 || x+w > len(row) {
+			return
+		}
+		row[x] = string(r)
+		if w == 2 {
+			row[x+1] = ""
+		}
+		x += w
+	}
+}
+
+func (c canvas) String() string {
+	lines := make([]string, len(c))
+	for y, row := range c {
+		lines[y] = strings.TrimRight(strings.Join(row, ""), " ")
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func Render(qc *QuadrantChart, config *diagram.Config) (string, error) {
+	if qc == nil {
+		return "", fmt.Errorf("no chart")
+	}
+	if config == nil {
+		config = diagram.DefaultConfig()
+	}
+
+	chars := Unicode
+	if config.UseAscii {
+		chars = ASCII
+	}
+
+	// Each quadrant is wide enough for its label
+	qw := minQuadrantWidth
+	for _, label := range qc.Quadrants {
+		qw = max(qw, runewidth.StringWidth(label)+2*quadrantLabelMargin)
+	}
+	qh := quadrantHeight
+
+	// The y-axis labels sit in a gutter to the left of the grid
+	gutter := max(runewidth.StringWidth(qc.YAxisHigh), runewidth.StringWidth(qc.YAxisLow))
+	if gutter > 0 {
+		gutter++
+	}
+
+	gridWidth := 2*qw + 1
+	gridHeight := 2*qh + 1
+
+	top := 0
+	if qc.Title != "" {
+		top = 2
+	}
+	height := top + gridHeight
+	if qc.XAxisLow != "" || qc.XAxisHigh != "" {
+		height++
+	}
+
+	c := newCanvas(gutter+gridWidth, height)
+
+	if qc.Title != "" {
+		title := runewidth.Truncate(qc.Title, gridWidth, "…")
+		c.put(gutter+(gridWidth-runewidth.StringWidth(title))/2, 0, title)
+	}
+
+	drawGrid(c, gutter, top, qw, qh, chars)
+
+	// Quadrant labels, centered at the top of each quadrant: 1 is top-right,
+	// 2 top-left, 3 bottom-left and 4 bottom-right.
+	origins := [4][2]int{{qw, 0}, {0, 0}, {0, qh}, {qw, qh}}
+	for i, label := range qc.Quadrants {
+		if label == "" {
+			continue
+		}
+		label = runewidth.Truncate(label, qw-2, "…")
+		x := gutter + origins[i][0] + (qw+1-runewidth.StringWidth(label))/2
+		c.put(x, top+origins[i][1]+1, label)
+	}
+
+	// Plot all markers before labeling them so labels can avoid them
+	for _, p := range qc.Points {
+		x, y := pointPosition(p, gutter, top, qw, qh)
+		c.put(x, y, string(chars.Point))
+	}
+	for _, p := range qc.Points {
+		if p.Label == "" {
+			continue
+		}
+		x, y := pointPosition(p, gutter, top, qw, qh)
+		drawPointLabel(c, p.Label, x, y, gutter, top, gutter+gridWidth-1, top+gridHeight-1, chars)
+	}
+
+	// Axis labels
+	if qc.YAxisHigh != "" {
+		c.put(gutter-1-runewidth.StringWidth(qc.YAxisHigh), top+1, qc.YAxisHigh)
+	}
+	if qc.YAxisLow != "" {
+		c.put(gutter-1-runewidth.StringWidth(qc.YAxisLow), top+gridHeight-2, qc.YAxisLow)
+	}
+	if qc.XAxisLow != "" {
+		c.put(gutter, top+gridHeight, qc.XAxisLow)
+	}
+	if qc.XAxisHigh != "" {
+		c.put(gutter+gridWidth-runewidth.StringWidth(qc.XAxisHigh), top+gridHeight, qc.XAxisHigh)
+	}
+
+	return c.String(), nil
+}
+
+// drawGrid draws the outer border and the center cross of the chart.
+func drawGrid(c canvas, left, top, qw, qh int, chars BoxChars) {
+	right := left + 2*qw
+	bottom := top + 2*qh
+	midX := left + qw
+	midY := top + qh
+
+	for x := left + 1; x < right; x++ {
+		c[top][x] = string(chars.Horizontal)
+		c[midY][x] = string(chars.Horizontal)
+		c[bottom][x] = string(chars.Horizontal)
+	}
+	for y := top + 1; y < bottom; y++ {
+		c[y][left] = string(chars.Vertical)
+		c[y][midX] = string(chars.Vertical)
+		c[y][right] = string(chars.Vertical)
+	}
+
+	c[top][left] = string(chars.TopLeft)
+	c[top][right] = string(chars.TopRight)
+	c[bottom][left] = string(chars.BottomLeft)
+	c[bottom][right] = string(chars.BottomRight)
+	c[top][midX] = string(chars.TeeDown)
+	c[bottom][midX] = string(chars.TeeUp)
+	c[midY][left] = string(chars.TeeRight)
+	c[midY][right] = string(chars.TeeLeft)
+	c[midY][midX] = string(chars.Cross)
+}
+
+// pointPosition returns the cell of a point inside the grid.
+func pointPosition(p *Point, left, top, qw, qh int) (int, int) {
+	innerWidth := 2*qw - 1
+	innerHeight := 2*qh - 1
+
+	x := left + 1 + int(math.Round(p.X*float64(innerWidth-1)))
+	y := top + 1 + int(math.Round((1-p.Y)*float64(innerHeight-1)))
+	return x, y
+}
+
+// drawPointLabel labels the point at x, y. The label goes to the right of the
+// marker if there is room, otherwise to the left, avoiding other labels and
+// markers. If neither side of the point's row is free, the rows directly
+// above and below are tried before falling back to overwriting.
+func drawPointLabel(c canvas, label string, x, y, left, top, right, bottom int, chars BoxChars) {
+	labelWidth := runewidth.StringWidth(label)
+
+	for _, row := range []int{y, y - 1, y + 1} {
+		if row <= top || row >= bottom {
+			continue
+		}
+		if rx := x + 1 + pointLabelGap; rx+labelWidth <= right && c.free(rx, row, labelWidth, chars) {
+			c.put(rx, row, label)
+			return
+		}
+		if lx := x - pointLabelGap - labelWidth; lx > left && c.free(lx, row, labelWidth, chars) {
+			c.put(lx, row, label)
+			return
+		}
+	}
+
+	// Not enough free room; truncate on the wider side
+	if right-x > x-left {
+		c.put(x+1+pointLabelGap, y, runewidth.Truncate(label, right-x-1-pointLabelGap, "…"))
+	} else {
+		label = runewidth.Truncate(label, x-left-1-pointLabelGap, "…")
+		c.put(x-pointLabelGap-runewidth.StringWidth(label), y, label)
+	}
+}
+
+// free reports whether the w cells starting at x, y hold nothing but
+// whitespace and grid lines, with a one cell margin on either side.
+func (c canvas) free(x, y, w int, chars BoxChars) bool {
+	if y < 0 || y >= len(c) {
+		return false
+	}
+	for i := x - 1; i <= x+w; i++ {
+		if i < 0 || i >= len(c[y]) {
+			continue
+		}
+		switch cell := c[y][i]; cell {
+		case " ", string(chars.Horizontal), string(chars.Vertical), string(chars.Cross),
+			string(chars.TeeDown), string(chars.TeeUp), string(chars.TeeLeft), string(chars.TeeRight):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -11,6 +11,14 @@ func RenderDiagram(input string, config *diagram.Config) (string, error) {
 		config = diagram.DefaultConfig()
 	}
 
+	directive, input, err := diagram.ParseDirectives(input)
+	if err != nil {
+		return "", err
+	}
+	config = directive.Apply(config)
+	input = diagram.StripComments(input)
+	captions, input := diagram.ParseCaptions(input)
+
 	diag, err := DiagramFactory(input)
 	if err != nil {
 		return "", fmt.Errorf("failed to detect diagram type: %w", err)
@@ -25,5 +33,5 @@ func RenderDiagram(input string, config *diagram.Config) (string, error) {
 		return "", fmt.Errorf("failed to render %s diagram: %w", diag.Type(), err)
 	}
 
-	return output, nil
+	return captions.Render(output, config), nil
 }
@@ -18,6 +18,10 @@ type BoxChars struct {
 	DottedLine   rune
 	SelfTopRight rune
 	SelfBottom   rune
+	ActorHead    rune
+	ActorArmL    rune
+	ActorBody    rune
+	ActorArmR    rune
 }
 
 var ASCII = BoxChars{
@@ -37,6 +41,10 @@ var ASCII = BoxChars{
 	DottedLine:   '.',
 	SelfTopRight: '+',
 	SelfBottom:   '+',
+	ActorHead:    'o',
+	ActorArmL:    '/',
+	ActorBody:    '|',
+	ActorArmR:    '\\',
 }
 
 var Unicode = BoxChars{
@@ -56,4 +64,8 @@ var Unicode = BoxChars{
 	DottedLine:   '┈',
 	SelfTopRight: '┐',
 	SelfBottom:   '┘',
+	ActorHead:    '○',
+	ActorArmL:    '╱',
+	ActorBody:    '│',
+	ActorArmR:    '╲',
 }
@@ -1,8 +1,10 @@
 package sequence
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/hholst80/glow/mermaid/ascii/diagram"
@@ -15,23 +17,40 @@ const (
 )
 
 var (
-	// participantRegex matches participant declarations: participant [ID] [as Label]
-	participantRegex = regexp.MustCompile(`^\s*participant\s+(?:"([^"]+)"|(\S+))(?:\s+as\s+(.+))?$`)
+	// participantRegex matches participant declarations: participant|actor [ID] [as Label]
+	participantRegex = regexp.MustCompile(`^\s*(participant|actor)\s+(?:"([^"]+)"|(\S+))(?:\s+as\s+(.+))?$`)
 
 	// messageRegex matches messages: [From]->>[To]: [Label]
 	messageRegex = regexp.MustCompile(`^\s*(?:"([^"]+)"|([^\s\->]+))\s*(-->>|->>)\s*(?:"([^"]+)"|([^\s\->]+))\s*:\s*(.*)$`)
+
+	// autonumberRegex matches the autonumber directive: autonumber [start [step]] or autonumber off
+	autonumberRegex = regexp.MustCompile(`^\s*autonumber(?:\s+(off|\d+)(?:\s+(\d+))?)?\s*$`)
+
+	// noteRegex matches notes: Note left of|right of|over [A[,B]]: [Text]
+	noteRegex = regexp.MustCompile(`^\s*(?i:note)\s+(left of|right of|over)\s+([^:]+?)\s*:\s*(.*)$`)
+
+	// blockRegex matches control block keywords: loop|alt|else|opt|par|and [Label], and end
+	blockRegex = regexp.MustCompile(`^\s*(loop|alt|else|opt|par|and|end)(?:\s+(.*))?$`)
 )
 
 // SequenceDiagram represents a parsed sequence diagram.
 type SequenceDiagram struct {
 	Participants []*Participant
 	Messages     []*Message
+	Blocks       []*Block
+
+	Notes []*Note
+
+	// events lists block boundaries and notes in source order, for the
+	// renderer
+	events []event
 }
 
 type Participant struct {
 	ID    string
 	Label string
 	Index int
+	Actor bool // Declared with the actor keyword
 }
 
 type Message struct {
@@ -39,6 +58,17 @@ type Message struct {
 	To        *Participant
 	Label     string
 	ArrowType ArrowType
+	Number    int  // Sequence number from autonumber, if Numbered
+	Numbered  bool // Whether autonumber was on for the message
+}
+
+// DisplayLabel returns the label as shown in the diagram, prefixed with the
+// sequence number when autonumbering is on.
+func (m *Message) DisplayLabel() string {
+	if !m.Numbered {
+		return m.Label
+	}
+	return strings.TrimSpace(fmt.Sprintf("%d. %s", m.Number, m.Label))
 }
 
 type ArrowType int
@@ -59,6 +89,95 @@ func (a ArrowType) String() string {
 	}
 }
 
+type BlockKind int
+
+const (
+	LoopBlock BlockKind = iota
+	AltBlock
+	OptBlock
+	ParBlock
+)
+
+func (k BlockKind) String() string {
+	switch k {
+	case LoopBlock:
+		return "loop"
+	case AltBlock:
+		return "alt"
+	case OptBlock:
+		return "opt"
+	case ParBlock:
+		return "par"
+	default:
+		return fmt.Sprintf("BlockKind(%d)", k)
+	}
+}
+
+// Block is a loop, alt, opt or par block. It encloses the messages from
+// index Start up to, but not including, End.
+type Block struct {
+	Kind     BlockKind
+	Label    string
+	Start    int
+	End      int
+	Depth    int // Nesting level, 0 for top-level blocks
+	Sections []*BlockSection
+}
+
+// BlockSection is an else branch of an alt block or an and branch of a par
+// block, starting at message index Start.
+type BlockSection struct {
+	Label string
+	Start int
+}
+
+type eventKind int
+
+const (
+	blockStart eventKind = iota
+	blockSection
+	blockEnd
+	noteEvent
+)
+
+// event marks a block boundary or a note before the message at index pos.
+type event struct {
+	kind    eventKind
+	pos     int
+	block   *Block
+	section *BlockSection
+	note    *Note
+}
+
+type NotePosition int
+
+const (
+	NoteLeftOf NotePosition = iota
+	NoteRightOf
+	NoteOver
+)
+
+func (p NotePosition) String() string {
+	switch p {
+	case NoteLeftOf:
+		return "left of"
+	case NoteRightOf:
+		return "right of"
+	case NoteOver:
+		return "over"
+	default:
+		return fmt.Sprintf("NotePosition(%d)", p)
+	}
+}
+
+// Note is a note placed beside a participant or spanning one or more
+// participants.
+type Note struct {
+	Position     NotePosition
+	Participants []*Participant
+	Text         string
+}
+
 func IsSequenceDiagram(input string) bool {
 	lines := strings.Split(input, "\n")
 	for _, line := range lines {
@@ -72,19 +191,19 @@ func IsSequenceDiagram(input string) bool {
 }
 
 func Parse(input string) (*SequenceDiagram, error) {
-	input = strings.TrimSpace(input)
-	if input == "" {
+	if strings.TrimSpace(input) == "" {
 		return nil, fmt.Errorf("empty input")
 	}
 
-	rawLines := diagram.SplitLines(input)
-	lines := diagram.RemoveComments(rawLines)
+	// Line numbers count from the start of input, so that errors point at
+	// the source as written
+	lines := diagram.NumberedLines(input)
 	if len(lines) == 0 {
 		return nil, fmt.Errorf("no content found")
 	}
 
-	if !strings.HasPrefix(strings.TrimSpace(lines[0]), SequenceDiagramKeyword) {
-		return nil, fmt.Errorf("expected %q keyword", SequenceDiagramKeyword)
+	if !strings.HasPrefix(lines[0].Text, SequenceDiagramKeyword) {
+		return nil, lines[0].ErrorAt(fmt.Errorf("expected %q keyword", SequenceDiagramKeyword), strings.Fields(lines[0].Text)[0])
 	}
 	lines = lines[1:]
 
@@ -93,26 +212,53 @@ func Parse(input string) (*SequenceDiagram, error) {
 		Messages:     []*Message{},
 	}
 	participantMap := make(map[string]*Participant)
+	var open []*Block
+	opened := make(map[*Block]diagram.Line)
+	var numbering autonumber
 
-	for i, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if trimmed == "" {
-			continue
-		}
+	for _, line := range lines {
+		trimmed := line.Text
 
 		if matched, err := sd.parseParticipant(trimmed, participantMap); err != nil {
-			return nil, fmt.Errorf("line %d: %w", i+2, err)
+			return nil, line.ErrorAt(err, "")
 		} else if matched {
 			continue
 		}
 
+		if numbering.parse(trimmed) {
+			continue
+		}
+
 		if matched, err := sd.parseMessage(trimmed, participantMap); err != nil {
-			return nil, fmt.Errorf("line %d: %w", i+2, err)
+			return nil, line.ErrorAt(err, "")
+		} else if matched {
+			msg := sd.Messages[len(sd.Messages)-1]
+			msg.Number, msg.Numbered = numbering.next()
+			continue
+		}
+
+		if matched, err := sd.parseNote(trimmed, participantMap); err != nil {
+			return nil, line.ErrorAt(err, "")
+		} else if matched {
+			continue
+		}
+
+		depth := len(open)
+		if matched, err := sd.parseBlock(trimmed, &open); err != nil {
+			return nil, line.ErrorAt(err, strings.Fields(trimmed)[0])
 		} else if matched {
+			if len(open) > depth {
+				opened[open[len(open)-1]] = line
+			}
 			continue
 		}
 
-		return nil, fmt.Errorf("line %d: invalid syntax: %q", i+2, trimmed)
+		return nil, line.ErrorAt(errors.New("invalid syntax"), trimmed)
+	}
+
+	if len(open) > 0 {
+		block := open[len(open)-1]
+		return nil, opened[block].ErrorAt(fmt.Errorf("unclosed %s block", block.Kind), "")
 	}
 
 	if len(sd.Participants) == 0 {
@@ -128,11 +274,11 @@ func (sd *SequenceDiagram) parseParticipant(line string, participants map[string
 		return false, nil
 	}
 
-	id := match[2]
-	if match[1] != "" {
-		id = match[1]
+	id := match[3]
+	if match[2] != "" {
+		id = match[2]
 	}
-	label := match[3]
+	label := strings.TrimSpace(match[4])
 	if label == "" {
 		label = id
 	}
@@ -146,6 +292,7 @@ func (sd *SequenceDiagram) parseParticipant(line string, participants map[string
 		ID:    id,
 		Label: label,
 		Index: len(sd.Participants),
+		Actor: match[1] == "actor",
 	}
 	sd.Participants = append(sd.Participants, p)
 	participants[id] = p
@@ -190,6 +337,118 @@ func (sd *SequenceDiagram) parseMessage(line string, participants map[string]*Pa
 	return true, nil
 }
 
+// autonumber tracks the state of the autonumber directive while parsing.
+type autonumber struct {
+	on      bool
+	current int
+	step    int
+}
+
+// parse handles an autonumber directive, returning false for other lines.
+func (a *autonumber) parse(line string) bool {
+	match := autonumberRegex.FindStringSubmatch(line)
+	if match == nil {
+		return false
+	}
+	if match[1] == "off" {
+		a.on = false
+		return true
+	}
+	a.on = true
+	a.current, a.step = 1, 1
+	if match[1] != "" {
+		a.current, _ = strconv.Atoi(match[1])
+	}
+	if match[2] != "" {
+		a.step, _ = strconv.Atoi(match[2])
+	}
+	return true
+}
+
+// next returns the number of the next message, or false when numbering is
+// off.
+func (a *autonumber) next() (int, bool) {
+	if !a.on {
+		return 0, false
+	}
+	n := a.current
+	a.current += a.step
+	return n, true
+}
+
+func (sd *SequenceDiagram) parseNote(line string, participants map[string]*Participant) (bool, error) {
+	match := noteRegex.FindStringSubmatch(line)
+	if match == nil {
+		return false, nil
+	}
+
+	position := map[string]NotePosition{"left of": NoteLeftOf, "right of": NoteRightOf, "over": NoteOver}[match[1]]
+	ids := strings.Split(match[2], ",")
+	if len(ids) > 2 || (position != NoteOver && len(ids) > 1) {
+		return true, fmt.Errorf("note %s %q: too many participants", position, match[2])
+	}
+
+	note := &Note{Position: position, Text: strings.TrimSpace(match[3])}
+	for _, id := range ids {
+		id = strings.Trim(strings.TrimSpace(id), `"`)
+		if id == "" {
+			return true, fmt.Errorf("note %s: missing participant", position)
+		}
+		note.Participants = append(note.Participants, sd.getParticipant(id, participants))
+	}
+	sd.Notes = append(sd.Notes, note)
+	sd.events = append(sd.events, event{kind: noteEvent, pos: len(sd.Messages), note: note})
+	return true, nil
+}
+
+// parseBlock handles the keywords that open, divide and close control blocks.
+// open is the stack of blocks that haven't been closed yet.
+func (sd *SequenceDiagram) parseBlock(line string, open *[]*Block) (bool, error) {
+	match := blockRegex.FindStringSubmatch(line)
+	if match == nil {
+		return false, nil
+	}
+
+	keyword, label := match[1], strings.TrimSpace(match[2])
+	pos := len(sd.Messages)
+
+	var current *Block
+	if len(*open) > 0 {
+		current = (*open)[len(*open)-1]
+	}
+
+	switch keyword {
+	case "else", "and":
+		want := AltBlock
+		if keyword == "and" {
+			want = ParBlock
+		}
+		if current == nil || current.Kind != want {
+			return true, fmt.Errorf("%q outside of %s block", keyword, want)
+		}
+		section := &BlockSection{Label: label, Start: pos}
+		current.Sections = append(current.Sections, section)
+		sd.events = append(sd.events, event{kind: blockSection, pos: pos, block: current, section: section})
+	case "end":
+		if label != "" {
+			return false, nil
+		}
+		if current == nil {
+			return true, fmt.Errorf("\"end\" without an open block")
+		}
+		current.End = pos
+		*open = (*open)[:len(*open)-1]
+		sd.events = append(sd.events, event{kind: blockEnd, pos: pos, block: current})
+	default:
+		kind := map[string]BlockKind{"loop": LoopBlock, "alt": AltBlock, "opt": OptBlock, "par": ParBlock}[keyword]
+		b := &Block{Kind: kind, Label: label, Start: pos, Depth: len(*open)}
+		sd.Blocks = append(sd.Blocks, b)
+		*open = append(*open, b)
+		sd.events = append(sd.events, event{kind: blockStart, pos: pos, block: b})
+	}
+	return true, nil
+}
+
 func (sd *SequenceDiagram) getParticipant(id string, participants map[string]*Participant) *Participant {
 	if p, exists := participants[id]; exists {
 		return p
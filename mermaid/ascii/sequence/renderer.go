@@ -6,6 +6,7 @@ import (
 
 	"github.com/hholst80/glow/mermaid/ascii/diagram"
 	"github.com/mattn/go-runewidth"
+	"github.com/muesli/reflow/wordwrap"
 )
 
 const (
@@ -17,9 +18,12 @@ const (
 	boxBorderWidth            = 2
 	labelLeftMargin           = 2
 	labelBufferSpace          = 10
+	notePadding               = 1
+	wrapWidth                 = 20
 )
 
 type diagramLayout struct {
+	wrap               bool
 	participantWidths  []int
 	participantCenters []int
 	totalWidth         int
@@ -42,21 +46,46 @@ func calculateLayout(sd *SequenceDiagram, config *diagram.Config) *diagramLayout
 		widths[i] = w
 	}
 
+	// Notes beside a participant need room between lifelines; minCenter[i]
+	// is the least distance of center i from center i-1, or from the left
+	// edge for the first participant.
+	minCenter := make([]int, len(sd.Participants))
+	last := len(sd.Participants) - 1
+	for _, n := range sd.Notes {
+		w := noteWidth(n, config.SequenceWrap)
+		i := n.Participants[0].Index
+		switch {
+		case n.Position == NoteLeftOf && i == 0:
+			minCenter[i] = max(minCenter[i], w+1)
+		case n.Position == NoteLeftOf:
+			minCenter[i] = max(minCenter[i], w+3)
+		case n.Position == NoteRightOf && i < last:
+			minCenter[i+1] = max(minCenter[i+1], w+3)
+		case n.Position == NoteOver && len(n.Participants) == 1:
+			if i == 0 {
+				minCenter[i] = max(minCenter[i], w/2)
+			} else {
+				minCenter[i] = max(minCenter[i], w/2+1)
+			}
+			if i < last {
+				minCenter[i+1] = max(minCenter[i+1], w-w/2+1)
+			}
+		}
+	}
+
 	centers := make([]int, len(sd.Participants))
 	currentX := 0
 	for i := range sd.Participants {
 		boxWidth := widths[i] + boxBorderWidth
 		if i == 0 {
-			centers[i] = boxWidth / 2
-			currentX = boxWidth
+			centers[i] = max(boxWidth/2, minCenter[i])
 		} else {
 			currentX += participantSpacing
-			centers[i] = currentX + boxWidth/2
-			currentX += boxWidth
+			centers[i] = max(currentX+boxWidth/2, centers[i-1]+minCenter[i])
 		}
+		currentX = centers[i] - boxWidth/2 + boxWidth
 	}
 
-	last := len(sd.Participants) - 1
 	totalWidth := centers[last] + (widths[last]+boxBorderWidth)/2
 
 	msgSpacing := config.SequenceMessageSpacing
@@ -74,6 +103,7 @@ func calculateLayout(sd *SequenceDiagram, config *diagram.Config) *diagramLayout
 		totalWidth:         totalWidth,
 		messageSpacing:     msgSpacing,
 		selfMessageWidth:   selfWidth,
+		wrap:               config.SequenceWrap,
 	}
 }
 
@@ -93,6 +123,8 @@ func Render(sd *SequenceDiagram, config *diagram.Config) (string, error) {
 	layout := calculateLayout(sd, config)
 	var lines []string
 
+	lines = append(lines, actorFigures(sd.Participants, layout, chars)...)
+
 	lines = append(lines, buildLine(sd.Participants, layout, func(i int) string {
 		return string(chars.TopLeft) + strings.Repeat(string(chars.Horizontal), layout.participantWidths[i]) + string(chars.TopRight)
 	}))
@@ -112,7 +144,30 @@ func Render(sd *SequenceDiagram, config *diagram.Config) (string, error) {
 			string(chars.BottomRight)
 	}))
 
-	for _, msg := range sd.Messages {
+	// Block frames are drawn once all lines are known; until then each frame
+	// line is a plain lifeline whose index is recorded in frames.
+	var frames []frameLine
+	addEvents := func(pos int) {
+		for _, ev := range sd.events {
+			if ev.pos != pos {
+				continue
+			}
+			if ev.kind == blockStart || ev.kind == noteEvent {
+				for i := 0; i < layout.messageSpacing; i++ {
+					lines = append(lines, buildLifeline(layout, chars))
+				}
+			}
+			if ev.kind == noteEvent {
+				lines = append(lines, renderNote(ev.note, layout, chars)...)
+				continue
+			}
+			frames = append(frames, frameLine{line: len(lines), event: ev})
+			lines = append(lines, buildLifeline(layout, chars))
+		}
+	}
+
+	for idx, msg := range sd.Messages {
+		addEvents(idx)
 		for i := 0; i < layout.messageSpacing; i++ {
 			lines = append(lines, buildLifeline(layout, chars))
 		}
@@ -124,10 +179,158 @@ func Render(sd *SequenceDiagram, config *diagram.Config) (string, error) {
 		}
 	}
 
+	addEvents(len(sd.Messages))
+
 	lines = append(lines, buildLifeline(layout, chars))
+	if len(frames) > 0 {
+		lines = drawBlocks(lines, frames, sd.Blocks, chars)
+	}
 	return strings.Join(lines, "\n") + "\n", nil
 }
 
+// frameLine records which line holds the top, divider or bottom of a block.
+type frameLine struct {
+	line  int
+	event event
+}
+
+// drawBlocks draws the frames of control blocks. The diagram is shifted right
+// to make room for one frame edge per nesting level, and frames extend past
+// the widest line so that they enclose all message labels.
+func drawBlocks(lines []string, frames []frameLine, blocks []*Block, chars BoxChars) []string {
+	maxDepth := 0
+	for _, b := range blocks {
+		maxDepth = max(maxDepth, b.Depth)
+	}
+	indent := maxDepth + 1
+
+	isFrame := make(map[int]bool, len(frames))
+	for _, f := range frames {
+		isFrame[f.line] = true
+	}
+
+	grid := make([][]rune, len(lines))
+	width := 0
+	for i, l := range lines {
+		grid[i] = toCells(strings.Repeat(" ", indent) + l)
+		if !isFrame[i] {
+			width = max(width, len(grid[i]))
+		}
+	}
+	for _, f := range frames {
+		width = max(width, f.event.block.Depth+runewidth.StringWidth(frameTitle(f.event))+3)
+	}
+
+	right := func(b *Block) int {
+		return width + 1 + maxDepth - b.Depth
+	}
+	put := func(y, x int, r rune) {
+		for len(grid[y]) <= x {
+			grid[y] = append(grid[y], ' ')
+		}
+		grid[y][x] = r
+	}
+
+	// Sides first, so that the dividers of a block can overwrite them
+	top := make(map[*Block]int)
+	for _, f := range frames {
+		b := f.event.block
+		switch f.event.kind {
+		case blockStart:
+			top[b] = f.line
+		case blockEnd:
+			for y := top[b] + 1; y < f.line; y++ {
+				put(y, b.Depth, chars.Vertical)
+				put(y, right(b), chars.Vertical)
+			}
+		}
+	}
+
+	for _, f := range frames {
+		b := f.event.block
+		left, end := b.Depth, right(b)
+		fill, first, last := chars.Horizontal, chars.TopLeft, chars.TopRight
+		switch f.event.kind {
+		case blockSection:
+			fill, first, last = chars.DottedLine, chars.TeeRight, chars.TeeLeft
+		case blockEnd:
+			first, last = chars.BottomLeft, chars.BottomRight
+		}
+
+		put(f.line, left, first)
+		for x := left + 1; x < end; x++ {
+			if x < len(grid[f.line]) && grid[f.line][x] == chars.Vertical {
+				grid[f.line][x] = chars.Cross
+			} else {
+				put(f.line, x, fill)
+			}
+		}
+		put(f.line, end, last)
+
+		x := left + 2
+		for _, r := range toCells(frameTitle(f.event)) {
+			put(f.line, x, r)
+			x++
+		}
+	}
+
+	for i := range grid {
+		lines[i] = fromCells(grid[i])
+	}
+	return lines
+}
+
+// frameTitle returns the text shown on a frame line, such as
+// " loop [Every minute] ". Block ends have no title.
+func frameTitle(ev event) string {
+	var keyword, label string
+	switch ev.kind {
+	case blockStart:
+		keyword, label = ev.block.Kind.String(), ev.block.Label
+	case blockSection:
+		keyword, label = "else", ev.section.Label
+		if ev.block.Kind == ParBlock {
+			keyword = "and"
+		}
+	default:
+		return ""
+	}
+	if label == "" {
+		return " " + keyword + " "
+	}
+	return " " + keyword + " [" + label + "] "
+}
+
+// actorFigures returns the stick figures drawn above the boxes of actors, or
+// nothing if the diagram has no actors.
+func actorFigures(participants []*Participant, layout *diagramLayout, chars BoxChars) []string {
+	hasActor := false
+	for _, p := range participants {
+		hasActor = hasActor || p.Actor
+	}
+	if !hasActor {
+		return nil
+	}
+
+	figure := []string{
+		string(chars.ActorHead),
+		string([]rune{chars.ActorArmL, chars.ActorBody, chars.ActorArmR}),
+	}
+	lines := make([]string, len(figure))
+	for row, part := range figure {
+		lines[row] = strings.TrimRight(buildLine(participants, layout, func(i int) string {
+			boxWidth := layout.participantWidths[i] + boxBorderWidth
+			if !participants[i].Actor {
+				return strings.Repeat(" ", boxWidth)
+			}
+			// Center the figure on the lifeline
+			left := boxWidth/2 - runewidth.StringWidth(part)/2
+			return strings.Repeat(" ", left) + part + strings.Repeat(" ", boxWidth-left-runewidth.StringWidth(part))
+		}), " ")
+	}
+	return lines
+}
+
 func buildLine(participants []*Participant, layout *diagramLayout, draw func(int) string) string {
 	var sb strings.Builder
 	for i := range participants {
@@ -160,11 +363,11 @@ func renderMessage(msg *Message, layout *diagramLayout, chars BoxChars) []string
 	var lines []string
 	from, to := layout.participantCenters[msg.From.Index], layout.participantCenters[msg.To.Index]
 
-	if msg.Label != "" {
+	for _, label := range wrapText(msg.DisplayLabel(), wrapWidth, layout.wrap) {
 		start := min(from, to) + labelLeftMargin
-		labelWidth := runewidth.StringWidth(msg.Label)
+		labelWidth := runewidth.StringWidth(label)
 		w := max(layout.totalWidth, start+labelWidth) + labelBufferSpace
-		line := []rune(buildLifeline(layout, chars))
+		line := toCells(buildLifeline(layout, chars))
 		if len(line) < w {
 			padding := make([]rune, w-len(line))
 			for k := range padding {
@@ -173,14 +376,8 @@ func renderMessage(msg *Message, layout *diagramLayout, chars BoxChars) []string
 			line = append(line, padding...)
 		}
 
-		col := start
-		for _, r := range msg.Label {
-			if col < len(line) {
-				line[col] = r
-				col++
-			}
-		}
-		lines = append(lines, strings.TrimRight(string(line), " "))
+		putText(line, start, label)
+		lines = append(lines, fromCells(line))
 	}
 
 	line := []rune(buildLifeline(layout, chars))
@@ -215,7 +412,7 @@ func renderSelfMessage(msg *Message, layout *diagramLayout, chars BoxChars) []st
 
 	ensureWidth := func(l string) []rune {
 		target := layout.totalWidth + width + 1
-		r := []rune(l)
+		r := toCells(l)
 		if len(r) < target {
 			pad := make([]rune, target-len(r))
 			for i := range pad {
@@ -226,10 +423,10 @@ func renderSelfMessage(msg *Message, layout *diagramLayout, chars BoxChars) []st
 		return r
 	}
 
-	if msg.Label != "" {
+	for _, label := range wrapText(msg.DisplayLabel(), wrapWidth, layout.wrap) {
 		line := ensureWidth(buildLifeline(layout, chars))
 		start := center + labelLeftMargin
-		labelWidth := runewidth.StringWidth(msg.Label)
+		labelWidth := runewidth.StringWidth(label)
 		needed := start + labelWidth + labelBufferSpace
 		if len(line) < needed {
 			pad := make([]rune, needed-len(line))
@@ -238,14 +435,8 @@ func renderSelfMessage(msg *Message, layout *diagramLayout, chars BoxChars) []st
 			}
 			line = append(line, pad...)
 		}
-		col := start
-		for _, c := range msg.Label {
-			if col < len(line) {
-				line[col] = c
-				col++
-			}
-		}
-		lines = append(lines, strings.TrimRight(string(line), " "))
+		putText(line, start, label)
+		lines = append(lines, fromCells(line))
 	}
 
 	l1 := ensureWidth(buildLifeline(layout, chars))
@@ -271,3 +462,108 @@ func renderSelfMessage(msg *Message, layout *diagramLayout, chars BoxChars) []st
 
 	return lines
 }
+
+// wideFill follows a wide character in a line of cells, standing for the
+// second terminal column it takes up.
+const wideFill = '\x00'
+
+// toCells splits line into one rune per terminal column, so that labels of
+// CJK characters and emoji can be drawn over lines by column.
+func toCells(line string) []rune {
+	cells := make([]rune, 0, len(line))
+	for _, r := range line {
+		cells = append(cells, r)
+		if runewidth.RuneWidth(r) == 2 {
+			cells = append(cells, wideFill)
+		}
+	}
+	return cells
+}
+
+// fromCells joins a line of cells, without its trailing spaces.
+func fromCells(cells []rune) string {
+	return strings.TrimRight(strings.ReplaceAll(string(cells), string(wideFill), ""), " ")
+}
+
+// putText writes text over cells from column col, as far as they go.
+func putText(cells []rune, col int, text string) {
+	for _, r := range toCells(text) {
+		if col < len(cells) {
+			cells[col] = r
+		}
+		col++
+	}
+}
+
+// wrapText splits text into lines no wider than width when wrap is set.
+// Empty text has no lines.
+func wrapText(text string, width int, wrap bool) []string {
+	if text == "" {
+		return nil
+	}
+	if !wrap {
+		return []string{text}
+	}
+	return strings.Split(wordwrap.String(text, width), "\n")
+}
+
+// noteWidth returns the width of a note box, including its border.
+func noteWidth(n *Note, wrap bool) int {
+	w := 0
+	for _, line := range wrapText(n.Text, wrapWidth, wrap) {
+		w = max(w, runewidth.StringWidth(line))
+	}
+	return w + 2*notePadding + boxBorderWidth
+}
+
+// noteBounds returns the leftmost column and the width of a note box.
+func noteBounds(n *Note, layout *diagramLayout) (int, int) {
+	w := noteWidth(n, layout.wrap)
+	first := layout.participantCenters[n.Participants[0].Index]
+	switch n.Position {
+	case NoteLeftOf:
+		return first - 1 - w, w
+	case NoteRightOf:
+		return first + 2, w
+	}
+	if len(n.Participants) == 1 {
+		return first - w/2, w
+	}
+	// A note over two participants spans both lifelines
+	second := layout.participantCenters[n.Participants[1].Index]
+	left, right := min(first, second), max(first, second)
+	span := right - left + 1 + 2*notePadding + boxBorderWidth
+	if span >= w {
+		return left - notePadding - 1, span
+	}
+	return left - notePadding - 1 - (w-span)/2, w
+}
+
+func renderNote(n *Note, layout *diagramLayout, chars BoxChars) []string {
+	x, w := noteBounds(n, layout)
+	x = max(x, 0)
+
+	rows := []string{string(chars.TopLeft) + strings.Repeat(string(chars.Horizontal), w-2) + string(chars.TopRight)}
+	text := wrapText(n.Text, wrapWidth, layout.wrap)
+	if len(text) == 0 {
+		text = []string{""}
+	}
+	for _, t := range text {
+		textWidth := runewidth.StringWidth(t)
+		pad := (w - boxBorderWidth - textWidth) / 2
+		rows = append(rows, string(chars.Vertical)+strings.Repeat(" ", pad)+t+
+			strings.Repeat(" ", w-boxBorderWidth-pad-textWidth)+string(chars.Vertical))
+	}
+	rows = append(rows, string(chars.BottomLeft)+strings.Repeat(string(chars.Horizontal), w-2)+string(chars.BottomRight))
+
+	lines := make([]string, len(rows))
+	for i, row := range rows {
+		line := toCells(buildLifeline(layout, chars))
+		for len(line) < x+w {
+			line = append(line, ' ')
+		}
+		putText(line, x, row)
+		lines[i] = fromCells(line)
+	}
+	return lines
+}
@@ -0,0 +1,72 @@
+package ascii
+
+import "strings"
+
+// nodeShape is the shape of a flowchart node, as given by its label brackets.
+type nodeShape int
+
+const (
+	shapeRect       nodeShape = iota // A[text]
+	shapeRound                       // A(text)
+	shapeStadium                     // A([text])
+	shapeCircle                      // A((text))
+	shapeSubroutine                  // A[[text]]
+	shapeDecision                    // A{text}
+)
+
+// parseNodeShape determines the node shape from a bracketed label such as
+// "{decision}" or "((circle))". Unknown shapes are drawn as rectangles.
+func parseNodeShape(label string) nodeShape {
+	switch {
+	case strings.HasPrefix(label, "((") && strings.HasSuffix(label, "))"):
+		return shapeCircle
+	case strings.HasPrefix(label, "([") && strings.HasSuffix(label, "])"):
+		return shapeStadium
+	case strings.HasPrefix(label, "[[") && strings.HasSuffix(label, "]]"):
+		return shapeSubroutine
+	case strings.HasPrefix(label, "{{"):
+		return shapeRect
+	case strings.HasPrefix(label, "{") && strings.HasSuffix(label, "}"):
+		return shapeDecision
+	case strings.HasPrefix(label, "[("):
+		return shapeRect
+	case strings.HasPrefix(label, "(") && strings.HasSuffix(label, ")"):
+		return shapeRound
+	}
+	return shapeRect
+}
+
+// boxBorder holds the characters used to draw the border of a node.
+type boxBorder struct {
+	topLeft, topRight, bottomLeft, bottomRight string
+	horizontal, left, right                    string
+}
+
+// Rounded and circular nodes share the rounded border, since a grid of
+// terminal cells can't tell them apart; stadiums add parentheses on the sides.
+var (
+	unicodeBorders = map[nodeShape]boxBorder{
+		shapeRect:       {"┌", "┐", "└", "┘", "─", "│", "│"},
+		shapeRound:      {"╭", "╮", "╰", "╯", "─", "│", "│"},
+		shapeStadium:    {"╭", "╮", "╰", "╯", "─", "(", ")"},
+		shapeCircle:     {"╭", "╮", "╰", "╯", "─", "│", "│"},
+		shapeSubroutine: {"╓", "╖", "╙", "╜", "─", "║", "║"},
+		shapeDecision:   {"╱", "╲", "╲", "╱", "─", "│", "│"},
+	}
+	asciiBorders = map[nodeShape]boxBorder{
+		shapeRect:       {"+", "+", "+", "+", "-", "|", "|"},
+		shapeRound:      {".", ".", "'", "'", "-", "|", "|"},
+		shapeStadium:    {".", ".", "'", "'", "-", "(", ")"},
+		shapeCircle:     {".", ".", "'", "'", "-", "|", "|"},
+		shapeSubroutine: {"+", "+", "+", "+", "-", "[", "]"},
+		shapeDecision:   {"/", "\\", "\\", "/", "-", "|", "|"},
+	}
+)
+
+// border returns the border characters for the given shape.
+func border(shape nodeShape, useAscii bool) boxBorder {
+	if useAscii {
+		return asciiBorders[shape]
+	}
+	return unicodeBorders[shape]
+}
@@ -0,0 +1,118 @@
+package ascii
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/hholst80/glow/mermaid/ascii/diagram"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultLinkStyle is the linkStyles key for "linkStyle default".
+const defaultLinkStyle = -1
+
+// styleText colors text with the given foreground and background colors,
+// which are CSS colors for html and terminal colors for cli.
+func styleText(text, fg, bg, styleType string) string {
+	if fg == "" && bg == "" {
+		return text
+	}
+	switch styleType {
+	case "html":
+		var css []string
+		if fg != "" {
+			css = append(css, "color: "+fg)
+		}
+		if bg != "" {
+			css = append(css, "background-color: "+bg)
+		}
+		return fmt.Sprintf("<span style='%s'>%s</span>", strings.Join(css, "; "), text)
+	case "cli":
+		style := lipgloss.NewStyle()
+		if fg != "" {
+			style = style.Foreground(lipgloss.Color(fg))
+		}
+		if bg != "" {
+			style = style.Background(lipgloss.Color(bg))
+		}
+		return style.Render(text)
+	default:
+		log.Warnf("Unknown style type %s", styleType)
+		return text
+	}
+}
+
+// cellStyle is the foreground and background color of a drawing cell.
+type cellStyle struct {
+	fg, bg string
+}
+
+// applyStyles colors the finished drawing according to classDef, class,
+// style and linkStyle directives: node borders take the stroke color, node
+// interiors the fill and text color, and edges their stroke and label color.
+// With a palette, everything left uncolored takes the theme's colors.
+// Colors are applied last so that they don't interfere with merging lines.
+func (g *graph) applyStyles() {
+	cells := make(map[drawingCoord]cellStyle)
+	var palette diagram.Palette
+	if g.palette != nil {
+		palette = *g.palette
+	}
+
+	for _, n := range g.nodes {
+		styles := n.styleClass.styles
+		if (len(styles) == 0 && g.palette == nil) || n.drawing == nil || n.drawingCoord == nil {
+			continue
+		}
+		w, h := getDrawingSize(n.drawing)
+		for x := 0; x <= w; x++ {
+			for y := 0; y <= h; y++ {
+				c := drawingCoord{n.drawingCoord.x + x, n.drawingCoord.y + y}
+				if x == 0 || y == 0 || x == w || y == h {
+					cells[c] = cellStyle{fg: styleColor(styles["stroke"], palette.Border)}
+				} else {
+					cells[c] = cellStyle{fg: styleColor(styles["color"], palette.Label), bg: styleColor(styles["fill"], "")}
+				}
+			}
+		}
+	}
+
+	for _, e := range g.edges {
+		if len(e.styles) == 0 && g.palette == nil {
+			continue
+		}
+		for _, c := range e.cells {
+			cells[c] = cellStyle{fg: styleColor(e.styles["stroke"], palette.Arrow)}
+		}
+		for _, c := range e.textCells {
+			cells[c] = cellStyle{fg: styleColor(e.styles["color"], palette.Label)}
+		}
+	}
+
+	d := *g.drawing
+	for x := range d {
+		for y := range d[x] {
+			style, ok := cells[drawingCoord{x, y}]
+			if !ok && g.palette != nil {
+				// Subgraphs and anything else drawn outside nodes and edges
+				for _, r := range d[x][y] {
+					style.fg = g.palette.ColorOf(r, g.options.UseAscii)
+				}
+			}
+			if d[x][y] == " " && style.bg == "" {
+				continue
+			}
+			d[x][y] = styleText(d[x][y], style.fg, style.bg, g.styleType)
+		}
+	}
+}
+
+// styleColor returns the color set by a style as hex, or fallback if it
+// sets none.
+func styleColor(css, fallback string) string {
+	if c := diagram.CSSColor(css); c != "" {
+		return c
+	}
+	return fallback
+}
@@ -0,0 +1,178 @@
+package mermaid
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// cacheVersion is part of every cache key. Bump it when rendered output
+// changes so that stale diagrams aren't shown.
+const cacheVersion = 2
+
+// Cache files of diagrams wider than the maximum width have this suffix.
+const wideSuffix = ".wide"
+
+// DefaultCacheSize is how many bytes of diagrams a CachedRenderer keeps; the
+// ones used longest ago are removed beyond it.
+const DefaultCacheSize = 64 << 20
+
+// CachedRenderer caches the output of another renderer on disk, keyed by the
+// diagram source, width, backend and style, so that re-opening a document or
+// resizing the terminal doesn't lay out its diagrams again. Only successful
+// renders and diagrams too wide for the page are cached, and not those a
+// renderer gave its fallback renderer, which may render next time.
+type CachedRenderer struct {
+	renderer Renderer
+	dir      string
+	size     int64
+}
+
+// NewCachedRenderer creates a CachedRenderer that stores the output of
+// renderer in dir, up to DefaultCacheSize bytes of it.
+func NewCachedRenderer(renderer Renderer, dir string) *CachedRenderer {
+	return &CachedRenderer{renderer: renderer, dir: dir, size: DefaultCacheSize}
+}
+
+// Render returns the cached output for the diagram, or renders and caches it.
+func (r *CachedRenderer) Render(source string, maxWidth int, style string) (string, error) {
+	return r.RenderContext(context.Background(), source, maxWidth, style)
+}
+
+// RenderContext renders like Render, giving up with ErrTimeout once ctx is
+// done. Diagrams that time out aren't cached.
+func (r *CachedRenderer) RenderContext(ctx context.Context, source string, maxWidth int, style string) (string, error) {
+	path := filepath.Join(r.dir, r.key(source, maxWidth, style))
+	if out, ok := r.get(path); ok {
+		return out, nil
+	}
+	if out, ok := r.get(path + wideSuffix); ok {
+		return "", &WidthError{Output: out}
+	}
+
+	fellBack := new(atomic.Bool)
+	out, err := renderContext(context.WithValue(ctx, fallbackKey{}, fellBack), r.renderer, source, maxWidth, style)
+	var wide *WidthError
+	switch {
+	case fellBack.Load():
+	case err == nil:
+		r.store(path, out)
+	case errors.As(err, &wide):
+		r.store(path+wideSuffix, wide.Output)
+	}
+	return out, err
+}
+
+// key returns the cache file name of a diagram.
+func (r *CachedRenderer) key(source string, maxWidth int, style string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s\x00%d\x00%s\x00%s", cacheVersion, backendName(r.renderer), maxWidth, style, source)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// get returns the contents of a cache file, marking it used, or false if
+// there's none.
+func (r *CachedRenderer) get(path string) (string, bool) {
+	out, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return string(out), true
+}
+
+// store writes a cache file, removing the files used longest ago beyond the
+// cache's size. The cache is best effort, so errors are ignored; the file is
+// renamed into place so readers never see half of it.
+func (r *CachedRenderer) store(path, out string) {
+	if err := os.MkdirAll(r.dir, 0o755); err != nil { //nolint:gosec
+		return
+	}
+	f, err := os.CreateTemp(r.dir, ".tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.WriteString(out)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return
+	}
+	r.prune()
+}
+
+// prune removes the cache files used longest ago until the rest fit in the
+// cache's size.
+func (r *CachedRenderer) prune() {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return
+	}
+	var (
+		files []os.FileInfo
+		total int64
+	)
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			files = append(files, info)
+			total += info.Size()
+		}
+	}
+	slices.SortFunc(files, func(a, b os.FileInfo) int { return a.ModTime().Compare(b.ModTime()) })
+	for _, f := range files {
+		if total <= r.size {
+			return
+		}
+		if os.Remove(filepath.Join(r.dir, f.Name())) == nil {
+			total -= f.Size()
+		}
+	}
+}
+
+// fallbackKey is the context key of the flag renderers set when they hand a
+// diagram to their fallback renderer, so that it isn't cached.
+type fallbackKey struct{}
+
+// fallBack renders a diagram with the fallback renderer of another,
+// flagging that it did for a CachedRenderer.
+func fallBack(ctx context.Context, fallback Renderer, source string, maxWidth int, style string) (string, error) {
+	if fellBack, ok := ctx.Value(fallbackKey{}).(*atomic.Bool); ok {
+		fellBack.Store(true)
+	}
+	return renderContext(ctx, fallback, source, maxWidth, style)
+}
+
+// backendName describes a renderer for cache keys, so that output of
+// different backends isn't mixed up.
+func backendName(r Renderer) string {
+	switch r := r.(type) {
+	case *DefaultRenderer:
+		name := "ascii"
+		if r.plain {
+			name = "ascii-plain"
+		}
+		return fmt.Sprintf("%s force=%t %+v", name, r.force, r.limits)
+	case *ImageRenderer:
+		return fmt.Sprintf("image-%d %s %s fit=%t", r.protocol, r.command, imageTheme(), r.fit)
+	case *CommandRenderer:
+		return "command " + strings.Join(r.command, " ")
+	}
+	return fmt.Sprintf("%T", r)
+}
@@ -0,0 +1,89 @@
+package mermaid
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCommandTimeout is how long an external renderer may take for one
+// diagram before it is given up on.
+const DefaultCommandTimeout = 10 * time.Second
+
+// CommandRenderer renders diagrams with an external program, such as mmdc,
+// mermaid-ascii or a custom script. The diagram source is piped to the
+// program and its output is shown in place of the diagram. The program also
+// gets the maximum width and glamour style in GLOW_WIDTH and GLOW_STYLE.
+//
+// Diagrams the program fails on, or takes too long with, are passed to the
+// fallback renderer, if there is one.
+type CommandRenderer struct {
+	command  []string
+	timeout  time.Duration
+	fallback Renderer
+}
+
+// NewCommandRenderer creates a CommandRenderer for a command line, split on
+// spaces, that falls back to the ASCII renderer.
+func NewCommandRenderer(command string) *CommandRenderer {
+	return &CommandRenderer{
+		command:  strings.Fields(command),
+		timeout:  DefaultCommandTimeout,
+		fallback: NewRenderer(),
+	}
+}
+
+// Render runs the command on the diagram source and returns its output, or
+// renders the diagram with the fallback renderer if the command fails.
+func (r *CommandRenderer) Render(source string, maxWidth int, style string) (string, error) {
+	return r.RenderContext(context.Background(), source, maxWidth, style)
+}
+
+// RenderContext renders like Render, killing the command once ctx is done.
+func (r *CommandRenderer) RenderContext(ctx context.Context, source string, maxWidth int, style string) (string, error) {
+	out, err := r.run(ctx, source, maxWidth, style)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ErrTimeout
+		}
+		if r.fallback == nil {
+			return "", err
+		}
+		return fallBack(ctx, r.fallback, source, maxWidth, style)
+	}
+	return out, nil
+}
+
+// run runs the command with source on stdin and returns its stdout.
+func (r *CommandRenderer) run(ctx context.Context, source string, maxWidth int, style string) (string, error) {
+	if len(r.command) == 0 {
+		return "", errors.New("no mermaid command")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, r.command[0], r.command[1:]...) //nolint:gosec
+	cmd.Stdin = strings.NewReader(source)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(), "GLOW_WIDTH="+strconv.Itoa(maxWidth), "GLOW_STYLE="+style)
+	// Don't wait on children that keep the output open after a timeout
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s: %w: %s", r.command[0], err, strings.TrimSpace(stderr.String()))
+	}
+	out := strings.TrimRight(stdout.String(), "\n")
+	if strings.TrimSpace(out) == "" {
+		return "", fmt.Errorf("%s: no output", r.command[0])
+	}
+	return out, nil
+}
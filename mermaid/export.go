@@ -0,0 +1,64 @@
+package mermaid
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+
+	"github.com/charmbracelet/glamour/styles"
+)
+
+// ExportFormat is a file format diagrams can be exported to.
+type ExportFormat string
+
+// Export formats.
+const (
+	// FormatSVG is an SVG image, rendered with the mermaid CLI
+	FormatSVG ExportFormat = "svg"
+
+	// FormatPNG is a PNG image, rendered with the mermaid CLI
+	FormatPNG ExportFormat = "png"
+
+	// FormatTXT is the ASCII rendering as plain text
+	FormatTXT ExportFormat = "txt"
+)
+
+// ParseExportFormat returns the export format named s.
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch f := ExportFormat(s); f {
+	case FormatSVG, FormatPNG, FormatTXT:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid export format %q: must be %q, %q or %q", s, FormatSVG, FormatPNG, FormatTXT)
+}
+
+// Exporter renders diagrams to files: images with the mermaid CLI, or text
+// with the ASCII renderer.
+type Exporter struct {
+	format  ExportFormat
+	command string
+}
+
+// NewExporter creates an Exporter for the given format.
+func NewExporter(format ExportFormat) *Exporter {
+	return &Exporter{format: format, command: DefaultImageCommand}
+}
+
+// Export renders a diagram and returns the file contents. Text is rendered
+// however complex the diagram is, without colors or a width limit.
+func (e *Exporter) Export(ctx context.Context, source string) ([]byte, error) {
+	if e.format == FormatTXT {
+		r := NewRenderer()
+		r.Force()
+		out, err := renderContext(ctx, r, source, 0, styles.NoTTYStyle)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(out + "\n"), nil
+	}
+
+	if _, err := exec.LookPath(e.command); err != nil {
+		return nil, fmt.Errorf("exporting %s needs the mermaid CLI (%s): %w", e.format, e.command, err)
+	}
+	return runMermaidCLI(ctx, e.command, source, string(e.format), "default")
+}
@@ -0,0 +1,98 @@
+package mermaid
+
+import "strings"
+
+// fence is a fenced code block of a markdown document.
+type fence struct {
+	// start and end are the byte offsets of the block, from the fence
+	// characters opening it to those closing it
+	start, end int
+
+	// language is the first word of the info string, and info the rest
+	language string
+	info     string
+
+	// source is the content of the block, trimmed
+	source string
+}
+
+// scanFences returns the closed fenced code blocks of markdown, in document
+// order, following CommonMark: fences are runs of at least three backticks or
+// tildes indented by at most three spaces, and a block is closed by a fence
+// of the same character at least as long as the one that opened it. Fences
+// inside other code blocks, such as a mermaid example in a ```` block, and
+// indented code blocks aren't blocks of their own.
+func scanFences(markdown string) []fence {
+	var fences []fence
+	var open *fence
+	var marker string
+	var indent int
+	var content []string
+
+	offset := 0
+	for offset < len(markdown) {
+		end := strings.IndexByte(markdown[offset:], '\n')
+		next := offset + end + 1
+		if end < 0 {
+			end = len(markdown) - offset
+			next = len(markdown)
+		}
+		line := markdown[offset : offset+end]
+		lineOffset := offset
+		offset = next
+
+		spaces := len(line) - len(strings.TrimLeft(line, " "))
+		if open == nil {
+			if spaces > 3 {
+				continue
+			}
+			run, info, ok := openingFence(line[spaces:])
+			if !ok {
+				continue
+			}
+			language, rest := strings.TrimSpace(info), ""
+			if i := strings.IndexAny(language, " \t"); i >= 0 {
+				language, rest = language[:i], strings.TrimSpace(language[i:])
+			}
+			open = &fence{start: lineOffset + spaces, language: language, info: rest}
+			marker, indent, content = run, spaces, nil
+			continue
+		}
+
+		if spaces <= 3 && closesFence(line[spaces:], marker) {
+			open.end = lineOffset + len(strings.TrimRight(line, " \t\r"))
+			open.source = strings.TrimSpace(strings.Join(content, "\n"))
+			fences = append(fences, *open)
+			open = nil
+			continue
+		}
+		// Content loses as much indentation as the opening fence had
+		content = append(content, line[min(spaces, indent):])
+	}
+	return fences
+}
+
+// openingFence returns the fence characters and info string of a line that
+// opens a fenced code block, with its indentation removed.
+func openingFence(line string) (string, string, bool) {
+	if line == "" || (line[0] != '`' && line[0] != '~') {
+		return "", "", false
+	}
+	n := len(line) - len(strings.TrimLeft(line, line[:1]))
+	if n < 3 {
+		return "", "", false
+	}
+	info := line[n:]
+	if line[0] == '`' && strings.Contains(info, "`") {
+		// Backticks in the info string make it inline code
+		return "", "", false
+	}
+	return line[:n], info, true
+}
+
+// closesFence reports whether a line, with its indentation removed, closes a
+// fenced code block opened by marker.
+func closesFence(line, marker string) bool {
+	rest := strings.TrimLeft(line, marker[:1])
+	return len(line)-len(rest) >= len(marker) && strings.TrimSpace(rest) == ""
+}
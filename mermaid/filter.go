@@ -0,0 +1,101 @@
+package mermaid
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FilterOutput is what the output of a code block filter does to the block.
+type FilterOutput string
+
+// Filter outputs.
+const (
+	// FilterReplace shows the output in place of the block's code, still
+	// highlighted as its language, as a formatter's
+	FilterReplace FilterOutput = "replace"
+
+	// FilterAppend keeps the block and shows the output below it, as the
+	// result of running the code
+	FilterAppend FilterOutput = "append"
+
+	// FilterMarkdown replaces the block with the output, rendered as
+	// markdown, such as a table a query prints
+	FilterMarkdown FilterOutput = "markdown"
+)
+
+// ParseFilterOutput returns the filter output named s, or FilterReplace for
+// "".
+func ParseFilterOutput(s string) (FilterOutput, error) {
+	switch o := FilterOutput(s); o {
+	case "":
+		return FilterReplace, nil
+	case FilterReplace, FilterAppend, FilterMarkdown:
+		return o, nil
+	}
+	return "", fmt.Errorf("invalid filter output %q: must be %q, %q or %q", s, FilterReplace, FilterAppend, FilterMarkdown)
+}
+
+// Filter is an external command that code blocks of a language are piped
+// through, such as a formatter, and what its output does to them.
+type Filter struct {
+	Command string
+	Output  FilterOutput
+}
+
+// RegisterFilters registers filters by code fence language. Like diagram
+// commands, each reads a block on stdin, with GLOW_WIDTH and GLOW_STYLE set,
+// and gets DefaultCommandTimeout. Unlike them, its output isn't cached, since
+// it may change from one run to the next. Filters whose programs aren't
+// installed, and empty commands, are skipped.
+func (r *Registry) RegisterFilters(filters map[string]Filter) {
+	for name, filter := range filters {
+		exec := NewExecRenderer(filter.Command)
+		if exec == nil {
+			continue
+		}
+		output := filter.Output
+		if output == "" {
+			output = FilterReplace
+		}
+		r.languages[name] = language{renderer: exec, timeout: DefaultCommandTimeout, filter: output}
+	}
+}
+
+// NewFilterPreprocessor creates a Preprocessor that pipes the code blocks of
+// the languages of a registry through their filters, without rendering
+// mermaid diagrams. It's meant to run before the diagrams are rendered, so
+// filters can produce diagrams too.
+func NewFilterPreprocessor(filters *Registry, maxWidth int, style string) *Preprocessor {
+	p := &Preprocessor{registry: NewRegistry(), maxWidth: maxWidth, style: style}
+	p.registry.Include(filters)
+	return p
+}
+
+// filterReplacement returns what a code block is replaced with once its
+// filter has run: its output as the block's code, the block with the output
+// below it, or the output as markdown. Blocks the filter fails on are kept
+// below a warning.
+func filterReplacement(match string, result renderResult, output FilterOutput) string {
+	if result.err != nil {
+		// Commands that print nothing on stderr leave the error ending in ": "
+		msg := strings.TrimSuffix(strings.TrimSpace(result.err.Error()), ":")
+		return "> ⚠ **" + escapeMarkdown(result.language) + " filter error:** " + escapeMarkdown(msg) + "\n\n" + match
+	}
+	switch output {
+	case FilterAppend:
+		return match + "\n\n" + codeBlock("", result.rendered)
+	case FilterMarkdown:
+		return result.rendered
+	}
+	return codeBlock(result.language, result.rendered)
+}
+
+// codeBlock returns text as a fenced code block of a language, fenced with
+// more backticks than any run of them in text.
+func codeBlock(language, text string) string {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence + language + "\n" + text + "\n" + fence
+}
@@ -0,0 +1,262 @@
+package mermaid
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi/kitty"
+)
+
+// ImageProtocol is a way of showing images inline in a terminal.
+type ImageProtocol int
+
+// Supported image protocols.
+const (
+	ProtocolNone ImageProtocol = iota
+	ProtocolKitty
+	ProtocolITerm2
+	ProtocolSixel
+)
+
+// DetectImageProtocol returns the image protocol supported by the current
+// terminal, judging by its environment, or ProtocolNone.
+func DetectImageProtocol() ImageProtocol {
+	return detectImageProtocol(os.Getenv)
+}
+
+func detectImageProtocol(getenv func(string) string) ImageProtocol {
+	term := getenv("TERM")
+	program := getenv("TERM_PROGRAM")
+
+	switch {
+	case getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty" || program == "ghostty":
+		return ProtocolKitty
+	case program == "iTerm.app" || program == "WezTerm" || getenv("LC_TERMINAL") == "iTerm2":
+		return ProtocolITerm2
+	case strings.Contains(term, "sixel") || strings.HasPrefix(term, "foot") || strings.HasPrefix(term, "mlterm"):
+		return ProtocolSixel
+	}
+	return ProtocolNone
+}
+
+// DefaultImageCommand is the mermaid CLI used to render diagrams to PNG.
+const DefaultImageCommand = "mmdc"
+
+// ImageRenderer renders diagrams to PNG with the mermaid CLI and shows them
+// inline with a terminal image protocol. Diagrams it can't render are passed
+// to the fallback renderer.
+type ImageRenderer struct {
+	protocol ImageProtocol
+	command  string
+	fallback Renderer
+	fit      bool
+
+	// lookup looks for command once, and found is whether it's installed
+	lookup sync.Once
+	found  bool
+}
+
+// NewImageRenderer creates an ImageRenderer for the given protocol that
+// falls back to the ASCII renderer.
+func NewImageRenderer(protocol ImageProtocol) *ImageRenderer {
+	return &ImageRenderer{
+		protocol: protocol,
+		command:  DefaultImageCommand,
+		fallback: NewRenderer(),
+	}
+}
+
+// FitCells makes the renderer lay images out on terminal cells, no wider
+// than the page, for a pager to scroll with the text. Only kitty images can
+// be, placed with Unicode placeholders on a line for each row they cover;
+// other protocols move the cursor past their images.
+func (r *ImageRenderer) FitCells() {
+	r.fit = true
+}
+
+// Available reports whether images can be shown: the terminal supports an
+// image protocol, which fits cells if asked to, and the mermaid CLI is
+// installed.
+func (r *ImageRenderer) Available() bool {
+	if r.protocol == ProtocolNone || r.fit && r.protocol != ProtocolKitty {
+		return false
+	}
+	r.lookup.Do(func() {
+		_, err := exec.LookPath(r.command)
+		r.found = err == nil
+	})
+	return r.found
+}
+
+// Render renders the diagram to an image escape sequence, or with the
+// fallback renderer if that fails.
+func (r *ImageRenderer) Render(source string, maxWidth int, style string) (string, error) {
+	return r.RenderContext(context.Background(), source, maxWidth, style)
+}
+
+// RenderContext renders like Render, killing the mermaid CLI once ctx is
+// done.
+func (r *ImageRenderer) RenderContext(ctx context.Context, source string, maxWidth int, style string) (string, error) {
+	if !r.Available() {
+		return fallBack(ctx, r.fallback, source, maxWidth, style)
+	}
+	img, err := runMermaidCLI(ctx, r.command, source, "png", imageTheme())
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ErrTimeout
+		}
+		return fallBack(ctx, r.fallback, source, maxWidth, style)
+	}
+	if r.fit {
+		return placeImage(img, maxWidth)
+	}
+	return encodeImage(img, r.protocol)
+}
+
+// imageTheme returns the mermaid theme for the terminal's background, which
+// shows through the transparent background of images.
+func imageTheme() string {
+	if lipgloss.HasDarkBackground() {
+		return "dark"
+	}
+	return "default"
+}
+
+// runMermaidCLI runs the mermaid CLI command on source and returns the image
+// it wrote in the format of ext, "png" or "svg".
+func runMermaidCLI(ctx context.Context, command, source, ext, theme string) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "glow-mermaid")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	in := filepath.Join(dir, "diagram.mmd")
+	out := filepath.Join(dir, "diagram."+ext)
+	if err := os.WriteFile(in, []byte(source), 0o600); err != nil {
+		return nil, err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command, "-i", in, "-o", out, "-t", theme, "-b", "transparent") //nolint:gosec
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	return os.ReadFile(out)
+}
+
+// isImage reports whether rendered output is an image escape sequence rather
+// than text.
+func isImage(rendered string) bool {
+	for _, prefix := range []string{"\x1b_G", "\x1b]1337;", "\x1bP"} {
+		if strings.HasPrefix(rendered, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// kittyChunkSize is the largest payload of a kitty graphics escape.
+const kittyChunkSize = 4096
+
+// encodeImage returns the escape sequence that shows a PNG image with the
+// given protocol.
+func encodeImage(img []byte, protocol ImageProtocol) (string, error) {
+	data := base64.StdEncoding.EncodeToString(img)
+
+	switch protocol {
+	case ProtocolKitty:
+		return encodeKitty(data, "f=100,a=T"), nil
+	case ProtocolITerm2:
+		return fmt.Sprintf("\x1b]1337;File=inline=1;size=%d;preserveAspectRatio=1:%s\a", len(img), data), nil
+	case ProtocolSixel:
+		decoded, err := png.Decode(bytes.NewReader(img))
+		if err != nil {
+			return "", err
+		}
+		return encodeSixel(decoded), nil
+	}
+	return "", fmt.Errorf("unsupported image protocol %d", protocol)
+}
+
+// encodeKitty returns the kitty graphics escapes that transmit base64 PNG
+// data in chunks, the first one with the given keys.
+func encodeKitty(data, keys string) string {
+	var b strings.Builder
+	for i := 0; i < len(data); i += kittyChunkSize {
+		chunk := data[i:min(i+kittyChunkSize, len(data))]
+		more := 0
+		if i+kittyChunkSize < len(data) {
+			more = 1
+		}
+		if i == 0 {
+			fmt.Fprintf(&b, "\x1b_G%s,m=%d;%s\x1b\\", keys, more, chunk)
+		} else {
+			fmt.Fprintf(&b, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+	}
+	return b.String()
+}
+
+// The size in pixels of a typical terminal cell, which fitted images are
+// laid out by.
+const (
+	cellWidth  = 8
+	cellHeight = 16
+)
+
+// maxImageRows is how many rows a fitted image may cover, one for each of
+// the diacritics numbering them.
+const maxImageRows = 256
+
+// placeImage returns a PNG image fitted to terminal cells no more than
+// maxWidth columns wide: the kitty escapes transmitting it, followed by a
+// line of Unicode placeholders for each row it covers. The placeholders are
+// colored with the image's ID, taken from its data so that showing it again
+// replaces it.
+func placeImage(img []byte, maxWidth int) (string, error) {
+	config, err := png.DecodeConfig(bytes.NewReader(img))
+	if err != nil {
+		return "", err
+	}
+	if config.Width == 0 || config.Height == 0 {
+		return "", errors.New("empty image")
+	}
+	cols := (config.Width + cellWidth - 1) / cellWidth
+	if maxWidth > 0 {
+		cols = min(cols, maxWidth)
+	}
+	rows := (config.Height*cols*cellWidth + config.Width*cellHeight - 1) / (config.Width * cellHeight)
+	rows = min(max(rows, 1), maxImageRows)
+
+	h := fnv.New32a()
+	h.Write(img) //nolint:errcheck
+	id := h.Sum32()&0xffffff | 1
+
+	var b strings.Builder
+	b.WriteString(encodeKitty(base64.StdEncoding.EncodeToString(img),
+		fmt.Sprintf("f=100,a=T,U=1,q=2,i=%d,c=%d,r=%d", id, cols, rows)))
+	for row := range rows {
+		if row > 0 {
+			b.WriteByte('\n')
+		}
+		// Cells after the first of a row follow on from it
+		fmt.Fprintf(&b, "\x1b[38;2;%d;%d;%dm%c%c%c", id>>16, id>>8&0xff, id&0xff,
+			kitty.Placeholder, kitty.Diacritic(row), kitty.Diacritic(0))
+		b.WriteString(strings.Repeat(string(kitty.Placeholder), cols-1))
+		b.WriteString("\x1b[39m")
+	}
+	return b.String(), nil
+}
@@ -1,56 +1,127 @@
 // Package mermaid provides rendering of Mermaid diagrams to ASCII art.
 //
 // Supported diagram types:
-//   - Flowcharts: graph LR (left-to-right) and graph TD (top-down)
-//   - Sequence diagrams: sequenceDiagram with participants and messages
+//   - Flowcharts: graph or flowchart, in LR, RL, TD/TB and BT directions
+//   - Sequence diagrams: sequenceDiagram with participants, actors, messages,
+//     notes and loop/alt/opt/par blocks
+//   - Quadrant charts: quadrantChart with axes, quadrant labels and points
 //
 // The package uses the mermaid-ascii library for rendering, which produces
 // Unicode box-drawing characters for clean terminal output.
 package mermaid
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"regexp"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 	"github.com/hholst80/glow/mermaid/ascii"
+	"github.com/hholst80/glow/mermaid/ascii/diagram"
+	"github.com/hholst80/glow/sourcemap"
 )
 
 // ErrTooComplex is returned when a diagram is too complex for ASCII rendering.
 var ErrTooComplex = errors.New("diagram too complex for ASCII rendering")
 
+// WidthError is returned when a rendered diagram is wider than the maximum
+// width. It matches ErrTooComplex, and holds the diagram for callers that
+// can show wide content, such as the pager.
+type WidthError struct {
+	Output string
+}
+
+func (e *WidthError) Error() string {
+	return ErrTooComplex.Error()
+}
+
+// Is reports whether target is ErrTooComplex.
+func (e *WidthError) Is(target error) bool {
+	return target == ErrTooComplex
+}
+
 // Renderer defines the interface for rendering Mermaid diagrams.
 // This interface enables dependency injection for testing.
 type Renderer interface {
 	// Render converts a Mermaid diagram source to ASCII art.
 	// Returns the rendered output or an error if rendering fails.
 	// maxWidth specifies the maximum allowed output width (0 = no limit).
-	Render(source string, maxWidth int) (string, error)
+	// style is the active glamour style, whose colors the diagram takes.
+	Render(source string, maxWidth int, style string) (string, error)
 }
 
 // DefaultRenderer implements Renderer using the mermaid-ascii library.
-type DefaultRenderer struct{}
+type DefaultRenderer struct {
+	limits Limits
+	force  bool
+	plain  bool
+}
 
-// NewRenderer creates a new DefaultRenderer.
+// NewRenderer creates a new DefaultRenderer with the default complexity
+// limits.
 func NewRenderer() *DefaultRenderer {
-	return &DefaultRenderer{}
+	return &DefaultRenderer{limits: DefaultLimits()}
+}
+
+// SetLimits sets the complexity limits beyond which diagrams are shown as
+// source rather than rendered.
+func (r *DefaultRenderer) SetLimits(limits Limits) {
+	r.limits = limits
+}
+
+// Force makes the renderer attempt every diagram, however complex. Single
+// diagrams can ask for the same with a %%glow:force%% line.
+func (r *DefaultRenderer) Force() {
+	r.force = true
+}
+
+// Plain makes the renderer draw with plain ASCII characters (+, -, | and >)
+// instead of Unicode box-drawing characters, for terminals and fonts that
+// show those poorly.
+func (r *DefaultRenderer) Plain() {
+	r.plain = true
 }
 
+// forceRegex matches the line that makes a diagram render regardless of its
+// complexity.
+var forceRegex = regexp.MustCompile(`(?m)^\s*%%\s*glow:force\s*%%\s*$`)
+
 // Render converts a Mermaid diagram source to ASCII art using mermaid-ascii.
-// Returns ErrTooComplex if the diagram is too complex or the output exceeds maxWidth.
-func (r *DefaultRenderer) Render(source string, maxWidth int) (string, error) {
-	if isTooComplex(source) {
+// Returns ErrTooComplex if the diagram is too complex, or a *WidthError if the
+// output exceeds maxWidth.
+func (r *DefaultRenderer) Render(source string, maxWidth int, style string) (out string, err error) {
+	force := r.force || forceRegex.MatchString(source)
+	if !force && isTooComplex(source, r.limits) {
 		return "", ErrTooComplex
 	}
-	// Pass nil config to use defaults (Unicode box-drawing characters)
-	result, err := ascii.RenderDiagram(source, nil)
+	// Forced diagrams may be beyond what the layout handles
+	defer func() {
+		if p := recover(); p != nil {
+			out, err = "", fmt.Errorf("failed to render diagram: %v", p)
+		}
+	}()
+
+	// Unicode box-drawing characters unless plain, colored like the rest of
+	// the document
+	config := diagram.DefaultConfig()
+	config.UseAscii = r.plain
+	config.Palette = paletteForStyle(style)
+	config.MaxWidth = maxWidth
+	result, err := ascii.RenderDiagram(source, config)
 	if err != nil {
 		return "", err
 	}
 
 	// Check if output exceeds max width
 	if maxWidth > 0 && getMaxLineWidth(result) > maxWidth {
-		return "", ErrTooComplex
+		return "", &WidthError{Output: result}
 	}
 
 	return result, nil
@@ -60,8 +131,8 @@ func (r *DefaultRenderer) Render(source string, maxWidth int) (string, error) {
 func getMaxLineWidth(text string) int {
 	maxWidth := 0
 	for _, line := range strings.Split(text, "\n") {
-		// Count runes for proper Unicode handling
-		width := len([]rune(line))
+		// Count terminal columns, ignoring colors: CJK and emoji take two
+		width := ansi.StringWidth(line)
 		if width > maxWidth {
 			maxWidth = width
 		}
@@ -69,113 +140,559 @@ func getMaxLineWidth(text string) int {
 	return maxWidth
 }
 
-// Complexity thresholds for flowcharts with subgraphs.
-// The mermaid-ascii library can handle moderate complexity if node names
-// are used consistently (avoid mixing A[Label] with plain A references).
-const (
-	maxSubgraphsWithEdges = 2  // Max subgraphs when diagram has cross-subgraph edges
-	maxEdgesWithSubgraphs = 10 // Max edges when subgraphs are present
-	maxTotalEdges         = 20 // Max edges for any flowchart
-)
+// paletteForStyle returns diagram colors matching a glamour style: boxes take
+// the heading color, arrows the link color and labels the text color. Styles
+// without colors, and custom styles, give no palette.
+func paletteForStyle(style string) *diagram.Palette {
+	if style == styles.AutoStyle {
+		style = styles.LightStyle
+		if lipgloss.HasDarkBackground() {
+			style = styles.DarkStyle
+		}
+	}
+	config, ok := styles.DefaultStyles[style]
+	if !ok {
+		return nil
+	}
+
+	p := &diagram.Palette{}
+	if c := config.Heading.Color; c != nil {
+		p.Border = *c
+	}
+	if c := config.Link.Color; c != nil {
+		p.Arrow = *c
+	}
+	if c := config.Document.Color; c != nil {
+		p.Label = *c
+	}
+	if *p == (diagram.Palette{}) {
+		return nil
+	}
+	return p
+}
+
+// Limits are the complexity thresholds beyond which flowcharts are shown as
+// source rather than rendered. The mermaid-ascii library can handle moderate
+// complexity if node names are used consistently (avoid mixing A[Label] with
+// plain A references). A limit of 0 means no limit.
+type Limits struct {
+	// MaxNodes is the most nodes a flowchart may have
+	MaxNodes int `mapstructure:"maxNodes"`
+
+	// MaxEdges is the most edges a flowchart may have
+	MaxEdges int `mapstructure:"maxEdges"`
+
+	// MaxLines is the most lines a flowchart may have, not counting
+	// comments and blank lines
+	MaxLines int `mapstructure:"maxLines"`
+
+	// MaxSubgraphs and MaxEdgesWithSubgraphs limit flowcharts with both
+	// subgraphs and edges, whose cross-subgraph edges render poorly: only
+	// one of them may be exceeded
+	MaxSubgraphs          int `mapstructure:"maxSubgraphs"`
+	MaxEdgesWithSubgraphs int `mapstructure:"maxEdgesWithSubgraphs"`
+}
+
+// DefaultLimits returns the complexity limits used unless configured.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxEdges:              20,
+		MaxSubgraphs:          2,
+		MaxEdgesWithSubgraphs: 10,
+	}
+}
 
 // Regex patterns for complexity detection
 var (
 	flowchartRegex = regexp.MustCompile(`(?i)^\s*(graph|flowchart)\s+(LR|RL|TD|TB|BT)`)
 	subgraphRegex  = regexp.MustCompile(`(?i)\bsubgraph\b`)
 	edgeRegex      = regexp.MustCompile(`-->|--[^>]|-.->|-\.-|==>|~~~|&`)
+	// nodeRegex matches the node IDs that start a statement or follow an
+	// edge or &, skipping edge labels
+	nodeRegex = regexp.MustCompile(`(?m)(?:^|-->|---|-\.->|==>|&)\s*(?:\|[^|]*\|\s*)?([\p{L}\p{N}_]+)`)
 )
 
+// flowchartKeywords are words that start a flowchart statement rather than
+// name a node.
+var flowchartKeywords = map[string]bool{
+	"graph": true, "flowchart": true, "subgraph": true, "end": true,
+	"direction": true, "classDef": true, "class": true, "style": true,
+	"linkStyle": true, "click": true,
+}
+
 // isTooComplex checks if a diagram is too complex for the ASCII renderer.
 // Flowcharts with multiple subgraphs and cross-subgraph edges render poorly
 // due to node duplication bugs in the mermaid-ascii library.
-func isTooComplex(source string) bool {
+func isTooComplex(source string, limits Limits) bool {
+	// Commented out edges and subgraphs aren't drawn
+	source = diagram.StripComments(source)
+
 	// Only apply complexity checks to flowcharts
 	if !flowchartRegex.MatchString(source) {
 		return false
 	}
 
+	exceeds := func(count, limit int) bool {
+		return limit > 0 && count > limit
+	}
+
 	subgraphCount := len(subgraphRegex.FindAllString(source, -1))
 	edgeCount := len(edgeRegex.FindAllString(source, -1))
 
-	// Too many edges overall
-	if edgeCount > maxTotalEdges {
+	// Too many nodes, edges or lines overall
+	if exceeds(edgeCount, limits.MaxEdges) ||
+		exceeds(countNodes(source), limits.MaxNodes) ||
+		exceeds(countLines(source), limits.MaxLines) {
 		return true
 	}
 
 	// Subgraphs with significant edges cause layout issues
-	if subgraphCount > maxSubgraphsWithEdges && edgeCount > maxEdgesWithSubgraphs {
+	if exceeds(subgraphCount, limits.MaxSubgraphs) && exceeds(edgeCount, limits.MaxEdgesWithSubgraphs) {
 		return true
 	}
 
 	return false
 }
 
-// codeBlockRegex matches fenced code blocks with mermaid language identifier.
-// Matches: ```mermaid ... ``` or ~~~mermaid ... ~~~
-var codeBlockRegex = regexp.MustCompile("(?s)```mermaid\\s*\n(.*?)```|~~~mermaid\\s*\n(.*?)~~~")
+// countNodes returns roughly how many distinct nodes a flowchart has.
+func countNodes(source string) int {
+	nodes := make(map[string]bool)
+	for _, line := range strings.Split(source, "\n") {
+		for _, m := range nodeRegex.FindAllStringSubmatch(strings.TrimSpace(line), -1) {
+			if !flowchartKeywords[m[1]] {
+				nodes[m[1]] = true
+			}
+		}
+	}
+	return len(nodes)
+}
+
+// countLines returns the number of non-blank lines of a diagram.
+func countLines(source string) int {
+	n := 0
+	for _, line := range strings.Split(source, "\n") {
+		if strings.TrimSpace(line) != "" {
+			n++
+		}
+	}
+	return n
+}
 
-// Preprocessor handles preprocessing of markdown to render Mermaid diagrams.
+// Preprocessor handles preprocessing of markdown to render Mermaid diagrams,
+// and diagrams of other languages added to its registry.
 type Preprocessor struct {
-	renderer Renderer
+	registry *Registry
 	maxWidth int
+	style    string
+	figures  bool
+
+	// Lines of wide diagrams kept by KeepWide, and images, by placeholder
+	overflow bool
+	raw      map[string]string
+	rawCount int
+
+	// Errors of the diagrams the last Process failed to render
+	failures []error
+
+	// ctx stops rendering diagrams once it's done; nil never stops
+	ctx context.Context
 }
 
 // NewPreprocessor creates a new Preprocessor with the given renderer.
 // maxWidth specifies the maximum allowed output width (0 = no limit).
-func NewPreprocessor(renderer Renderer, maxWidth int) *Preprocessor {
-	return &Preprocessor{renderer: renderer, maxWidth: maxWidth}
+// style is the active glamour style, e.g. "dark" or "dracula".
+func NewPreprocessor(renderer Renderer, maxWidth int, style string) *Preprocessor {
+	p := &Preprocessor{registry: NewRegistry(), maxWidth: maxWidth, style: style}
+	p.registry.Register("mermaid", renderer, DefaultRenderTimeout)
+	return p
+}
+
+// AddLanguage makes Process render code blocks of another language, such as
+// d2, with renderer, giving each diagram timeout to render (0 = no limit).
+func (p *Preprocessor) AddLanguage(name string, renderer Renderer, timeout time.Duration) {
+	p.registry.Register(name, renderer, timeout)
+}
+
+// AddLanguages makes Process render code blocks of the languages of a
+// registry, such as one filled by RegisterCommands.
+func (p *Preprocessor) AddLanguages(registry *Registry) {
+	p.registry.Include(registry)
+}
+
+// SetTimeout sets how long each mermaid diagram may take to render before
+// its source is shown instead (0 = no limit). It defaults to
+// DefaultRenderTimeout.
+func (p *Preprocessor) SetTimeout(timeout time.Duration) {
+	if l, ok := p.registry.languages["mermaid"]; ok {
+		p.registry.Register("mermaid", l.renderer, timeout)
+	}
+}
+
+// LeaveMermaid makes Process leave mermaid blocks as source, while diagrams
+// of the other languages added are still rendered.
+func (p *Preprocessor) LeaveMermaid() {
+	delete(p.registry.languages, "mermaid")
+}
+
+// SetContext makes Process stop rendering diagrams once ctx is done, such as
+// when the document they're in is rendered again. Diagrams left are shown as
+// source.
+func (p *Preprocessor) SetContext(ctx context.Context) {
+	p.ctx = ctx
+}
+
+// KeepWide makes Process keep diagrams that are wider than maxWidth instead
+// of showing their source. Glamour would wrap their lines, so each line is
+// replaced by a placeholder that Restore swaps back after rendering; callers
+// are expected to let the user pan the wide lines.
+func (p *Preprocessor) KeepWide() {
+	p.overflow = true
+}
+
+// NumberFigures makes Process number the diagrams it renders, with a caption
+// such as "Figure 3: Login flow" below each. The caption's text is taken from
+// the fence info string (```mermaid Login flow, or caption="Login flow") or
+// else from a trailing comment such as %% caption: Login flow. Captions of
+// markdown marked by sourcemap.Mark are marked with the line of their
+// diagram's fence.
+func (p *Preprocessor) NumberFigures() {
+	p.figures = true
+}
+
+// placeholderPrefix starts the placeholder of a line of a wide diagram or of
+// an image.
+const placeholderPrefix = "@glow-diagram-"
+
+// placeholder returns the placeholder for a line of a wide diagram or image.
+func placeholder(diagram, line int) string {
+	return fmt.Sprintf("%s%d-%d@", placeholderPrefix, diagram, line)
 }
 
 // tooComplexNote is shown when a diagram cannot be rendered in ASCII.
 const tooComplexNote = "  ⚠ [Diagram too complex for terminal - view in markdown renderer]"
 
+// renderResult is the outcome of rendering one diagram code block.
+type renderResult struct {
+	language string
+	caption  string
+	rendered string
+	err      error
+}
+
 // Process finds and renders all Mermaid code blocks in the given markdown.
 // Returns the markdown with Mermaid blocks replaced by their ASCII rendering.
 // If rendering fails for a block, it is left unchanged with an error comment.
 // If a diagram is too complex, it shows the original source with a note.
+//
+// Blocks are rendered concurrently, so the renderer must be safe for
+// concurrent use.
 func (p *Preprocessor) Process(markdown string) string {
-	return codeBlockRegex.ReplaceAllStringFunc(markdown, func(match string) string {
-		// Extract the diagram source from the code block
-		source := extractDiagramSource(match)
-		if source == "" {
-			return match
-		}
-
-		// Render the diagram
-		rendered, err := p.renderer.Render(source, p.maxWidth)
-		if err != nil {
-			if errors.Is(err, ErrTooComplex) {
-				// Show original source with a visual cue
-				return tooComplexNote + "\n" + match
+	p.failures = nil
+	var blocks []fence
+	for _, f := range scanFences(markdown) {
+		if _, ok := p.registry.languages[f.language]; ok {
+			blocks = append(blocks, f)
+		}
+	}
+	if len(blocks) == 0 {
+		return markdown
+	}
+	results := p.renderAll(blocks)
+	for i, r := range results {
+		if p.registry.languages[blocks[i].language].filter != "" {
+			continue
+		}
+		if r.err != nil && !errors.Is(r.err, ErrTooComplex) {
+			p.failures = append(p.failures, fmt.Errorf("%s: %w", languageName(r.language), r.err))
+		}
+	}
+
+	// Splice the results back in document order, which also numbers the
+	// placeholders in order
+	var b strings.Builder
+	last, figure := 0, 0
+	for i, block := range blocks {
+		b.WriteString(markdown[last:block.start])
+		last = block.end
+		if filter := p.registry.languages[block.language].filter; filter != "" {
+			if results[i].rendered != "" || results[i].err != nil {
+				b.WriteString(filterReplacement(markdown[block.start:block.end], results[i], filter))
+			} else {
+				b.WriteString(markdown[block.start:block.end])
 			}
-			// If rendering fails, keep the original code block with an error note
-			return match + "\n<!-- mermaid rendering error: " + err.Error() + " -->"
+			continue
 		}
+		b.WriteString(p.replacement(markdown[block.start:block.end], results[i]))
+		if p.figures && p.shown(results[i]) {
+			figure++
+			caption := figureCaption(figure, results[i].caption)
+			if line, ok := sourcemap.LineAt(markdown, block.start); ok {
+				caption = sourcemap.MarkAs(caption, line)
+			}
+			b.WriteString("\n\n" + caption)
+		}
+	}
+	b.WriteString(markdown[last:])
+	return b.String()
+}
 
-		// Return the rendered diagram as a preformatted block
-		return "```\n" + strings.TrimSpace(rendered) + "\n```"
-	})
+// Failures returns the errors of the diagrams the last call to Process
+// couldn't render, which it left as source below a warning. Diagrams too
+// complex to draw aren't failures.
+func (p *Preprocessor) Failures() []error {
+	return p.failures
 }
 
-// extractDiagramSource extracts the diagram content from a mermaid code block.
-func extractDiagramSource(block string) string {
-	matches := codeBlockRegex.FindStringSubmatch(block)
-	if len(matches) < 2 {
-		return ""
+// renderAll renders the diagrams of the given blocks with a bounded pool of
+// workers, returning the results in block order.
+func (p *Preprocessor) renderAll(blocks []fence) []renderResult {
+	ctx := p.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	results := make([]renderResult, len(blocks))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(runtime.GOMAXPROCS(0), len(blocks)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					return
+				}
+				block := blocks[i]
+				if block.source == "" {
+					continue
+				}
+				l := p.registry.languages[block.language]
+				source := block.source
+				if l.filter != "" {
+					// Filters get the block as a file, ending in a newline
+					source += "\n"
+				}
+				rendered, err := renderTimeout(ctx, l.renderer, l.timeout, source, p.maxWidth, p.style)
+				results[i] = renderResult{block.language, captionText(block.info, block.source), rendered, err}
+			}
+		}()
 	}
-	// Check both capture groups (for ``` and ~~~)
-	if matches[1] != "" {
-		return strings.TrimSpace(matches[1])
+	// Blocks left once ctx is done stay as they are
+send:
+	for i := range blocks {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// replacement returns what a Mermaid code block is replaced with once its
+// diagram is rendered.
+func (p *Preprocessor) replacement(match string, result renderResult) string {
+	rendered, err := result.rendered, result.err
+	if rendered == "" && err == nil {
+		// Empty block
+		return match
+	}
+
+	var widthErr *WidthError
+	if p.overflow && errors.As(err, &widthErr) {
+		return p.placeholders(widthErr.Output)
 	}
-	if len(matches) > 2 && matches[2] != "" {
-		return strings.TrimSpace(matches[2])
+	if err != nil {
+		if errors.Is(err, ErrTooComplex) {
+			// Show original source with a visual cue
+			return tooComplexNote + "\n" + match
+		}
+		// If rendering fails, keep the original code block below a warning
+		return errorNote(result.language, err) + "\n\n" + match
+	}
+
+	// Images must bypass glamour, which would count and wrap them as text
+	if isImage(rendered) {
+		return p.placeholders(rendered)
+	}
+
+	// Return the rendered diagram as a preformatted block
+	return "```\n" + strings.Trim(rendered, "\n") + "\n```"
+}
+
+// shown reports whether a diagram is shown rendered rather than as source.
+func (p *Preprocessor) shown(result renderResult) bool {
+	var widthErr *WidthError
+	if p.overflow && errors.As(result.err, &widthErr) {
+		return true
+	}
+	return result.err == nil && result.rendered != ""
+}
+
+var (
+	// captionAttrRegex matches a caption attribute in a fence info string:
+	// caption="Login flow"
+	captionAttrRegex = regexp.MustCompile(`\bcaption\s*=\s*"([^"]*)"`)
+
+	// captionCommentRegex matches a caption comment in any of the diagram
+	// languages' comment styles: %% caption: Login flow
+	captionCommentRegex = regexp.MustCompile(`^\s*(?:%%|#|//|')\s*caption\s*:\s*(.*?)\s*$`)
+)
+
+// captionText returns the caption of a diagram from the info string of its
+// fence, or else from a caption comment on its last line.
+func captionText(info, source string) string {
+	info = strings.TrimSpace(info)
+	if match := captionAttrRegex.FindStringSubmatch(info); match != nil {
+		return strings.TrimSpace(match[1])
+	}
+	if info != "" {
+		return info
+	}
+	lines := strings.Split(source, "\n")
+	if match := captionCommentRegex.FindStringSubmatch(lines[len(lines)-1]); match != nil {
+		return match[1]
 	}
 	return ""
 }
 
+// figureCaption returns the numbered caption shown below a diagram, in
+// emphasis that glamour styles.
+func figureCaption(n int, caption string) string {
+	text := fmt.Sprintf("Figure %d", n)
+	if caption != "" {
+		text += ": " + escapeMarkdown(caption)
+	}
+	return "*" + text + "*"
+}
+
+// placeholders records a wide diagram or image and returns a code block with
+// one placeholder for each of its lines.
+func (p *Preprocessor) placeholders(rendered string) string {
+	if p.raw == nil {
+		p.raw = make(map[string]string)
+	}
+	n := p.rawCount
+	p.rawCount++
+
+	var b strings.Builder
+	b.WriteString("```\n")
+	for i, line := range strings.Split(strings.Trim(rendered, "\n"), "\n") {
+		key := placeholder(n, i)
+		p.raw[key] = line
+		b.WriteString(key + "\n")
+	}
+	b.WriteString("```")
+	return b.String()
+}
+
+// Restore replaces the placeholders of wide diagrams and images in rendered
+// output with the diagram lines and image escapes, keeping the indentation in
+// front of each placeholder.
+func (p *Preprocessor) Restore(rendered string) string {
+	if len(p.raw) == 0 {
+		return rendered
+	}
+	lines := strings.Split(rendered, "\n")
+	for i, line := range lines {
+		idx := strings.Index(line, placeholderPrefix)
+		if idx < 0 {
+			continue
+		}
+		end := strings.Index(line[idx+len(placeholderPrefix):], "@")
+		if end < 0 {
+			continue
+		}
+		diagramLine, ok := p.raw[line[idx:idx+len(placeholderPrefix)+end+1]]
+		if !ok {
+			continue
+		}
+		prefix := line[:idx]
+		if strings.Contains(prefix, "\x1b[") {
+			// End the code block's styling before the diagram's own
+			prefix += "\x1b[0m"
+		}
+		lines[i] = prefix + diagramLine
+	}
+	return strings.Join(lines, "\n")
+}
+
+// languageNames are the names of diagram languages shown in error notes.
+var languageNames = map[string]string{"mermaid": "Mermaid", "d2": "D2", "dot": "Graphviz", "plantuml": "PlantUML"}
+
+// languageName returns the name of a diagram language, such as "Graphviz" for
+// dot.
+func languageName(language string) string {
+	if name, ok := languageNames[language]; ok {
+		return name
+	}
+	return language
+}
+
+// errorNote returns the warning shown above a diagram of the given language
+// that failed to render, as a blockquote that glamour styles. Syntax errors
+// point at their line and column.
+func errorNote(language string, err error) string {
+	name := languageName(language)
+	var parseErr *diagram.ParseError
+	if !errors.As(err, &parseErr) {
+		return "> ⚠ **" + name + " rendering error:** " + escapeMarkdown(err.Error())
+	}
+
+	note := fmt.Sprintf("> ⚠ **%s syntax error** at line %d, column %d: %s",
+		name, parseErr.Line, parseErr.Column, escapeMarkdown(parseErr.Err.Error()))
+	if parseErr.Token != "" {
+		note += " near " + codeSpan(parseErr.Token)
+	}
+	return note
+}
+
+// markdownEscaper escapes the characters that would style text in markdown.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`,
+)
+
+// escapeMarkdown returns text that shows as is in markdown.
+func escapeMarkdown(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+// codeSpan returns text as inline code, fenced with enough backticks.
+func codeSpan(text string) string {
+	fence := "`"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence + " " + text + " " + fence
+}
+
+// extractDiagramSource extracts the diagram content from a mermaid code block.
+func extractDiagramSource(block string) string {
+	for _, f := range scanFences(block) {
+		if f.language == "mermaid" {
+			return f.source
+		}
+	}
+	return ""
+}
+
+// ExtractDiagrams returns the source of every Mermaid code block in the
+// given markdown, in document order.
+func ExtractDiagrams(markdown string) []string {
+	var sources []string
+	for _, f := range scanFences(markdown) {
+		if f.language == "mermaid" && f.source != "" {
+			sources = append(sources, f.source)
+		}
+	}
+	return sources
+}
+
 // ProcessMarkdown is a convenience function that processes markdown with the default renderer.
 // maxWidth specifies the maximum allowed output width (0 = no limit).
-func ProcessMarkdown(markdown string, maxWidth int) string {
-	p := NewPreprocessor(NewRenderer(), maxWidth)
+// style is the active glamour style, used to color the diagrams.
+func ProcessMarkdown(markdown string, maxWidth int, style string) string {
+	p := NewPreprocessor(NewRenderer(), maxWidth, style)
 	return p.Process(markdown)
 }
@@ -1,22 +1,47 @@
 package mermaid
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/ansi/kitty"
+	"github.com/hholst80/glow/sourcemap"
+	"github.com/muesli/termenv"
 )
 
 // MockRenderer is a mock implementation of Renderer for testing.
 type MockRenderer struct {
+	mu sync.Mutex
 	// RenderFunc allows customizing the render behavior in tests.
 	RenderFunc func(source string) (string, error)
 	// Calls records all calls to Render for verification.
 	Calls []string
+	// Styles records the style passed to each call.
+	Styles []string
 }
 
 // Render implements Renderer interface.
-func (m *MockRenderer) Render(source string, maxWidth int) (string, error) {
+func (m *MockRenderer) Render(source string, maxWidth int, style string) (string, error) {
+	m.mu.Lock()
 	m.Calls = append(m.Calls, source)
+	m.Styles = append(m.Styles, style)
+	m.mu.Unlock()
 	if m.RenderFunc != nil {
 		return m.RenderFunc(source)
 	}
@@ -115,7 +140,7 @@ More text.`,
 			renderFunc: func(s string) (string, error) {
 				return "", errors.New("parse error")
 			},
-			wantContains: []string{"```mermaid", "invalid", "mermaid rendering error"},
+			wantContains: []string{"```mermaid", "invalid", "Mermaid rendering error:** parse error"},
 			wantCalls:    1,
 		},
 	}
@@ -123,7 +148,7 @@ More text.`,
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mock := &MockRenderer{RenderFunc: tt.renderFunc}
-			p := NewPreprocessor(mock, 0) // 0 = no width limit
+			p := NewPreprocessor(mock, 0, "") // 0 = no width limit
 
 			result := p.Process(tt.markdown)
 
@@ -171,7 +196,7 @@ func TestDefaultRenderer_Render(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := r.Render(tt.source, 0) // 0 = no width limit
+			result, err := r.Render(tt.source, 0, "") // 0 = no width limit
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Render() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -183,44 +208,92 @@ func TestDefaultRenderer_Render(t *testing.T) {
 	}
 }
 
-func TestCodeBlockRegex(t *testing.T) {
+func TestScanFences(t *testing.T) {
 	tests := []struct {
-		name    string
-		input   string
-		matches bool
+		name  string
+		input string
+		want  []string // language and source of each block
 	}{
 		{
-			name:    "backtick mermaid",
-			input:   "```mermaid\ngraph LR\n```",
-			matches: true,
+			name:  "backtick mermaid",
+			input: "```mermaid\ngraph LR\n```",
+			want:  []string{"mermaid", "graph LR"},
+		},
+		{
+			name:  "tilde mermaid",
+			input: "~~~mermaid\ngraph LR\n~~~",
+			want:  []string{"mermaid", "graph LR"},
+		},
+		{
+			name:  "other language",
+			input: "```go\nfunc main() {}\n```",
+			want:  []string{"go", "func main() {}"},
 		},
 		{
-			name:    "tilde mermaid",
-			input:   "~~~mermaid\ngraph LR\n~~~",
-			matches: true,
+			name:  "mermaid with extra space",
+			input: "```mermaid \ngraph LR\n```",
+			want:  []string{"mermaid", "graph LR"},
 		},
 		{
-			name:    "other language",
-			input:   "```go\nfunc main() {}\n```",
-			matches: false,
+			name:  "example in a longer fence",
+			input: "````markdown\n```mermaid\ngraph LR\n```\n````\n\n```mermaid\ngraph TD\n```",
+			want:  []string{"markdown", "```mermaid\ngraph LR\n```", "mermaid", "graph TD"},
 		},
 		{
-			name:    "mermaid with extra space",
-			input:   "```mermaid \ngraph LR\n```",
-			matches: true,
+			name:  "example in a tilde fence",
+			input: "~~~\n```mermaid\ngraph LR\n```\n~~~",
+			want:  []string{"", "```mermaid\ngraph LR\n```"},
+		},
+		{
+			name:  "indented code block",
+			input: "Example:\n\n    ```mermaid\n    graph LR\n    ```",
+		},
+		{
+			name:  "indented fence",
+			input: "- item\n\n  ```mermaid\n  graph LR\n    A --> B\n  ```",
+			want:  []string{"mermaid", "graph LR\n  A --> B"},
+		},
+		{
+			name:  "unclosed fence",
+			input: "```mermaid\ngraph LR",
+		},
+		{
+			name:  "closing fence with text",
+			input: "```mermaid\ngraph LR\n``` not closed\n```",
+			want:  []string{"mermaid", "graph LR\n``` not closed"},
+		},
+		{
+			name:  "backticks in the info string",
+			input: "```mermaid `x`\ngraph LR\n```",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := codeBlockRegex.MatchString(tt.input)
-			if result != tt.matches {
-				t.Errorf("codeBlockRegex.MatchString(%q) = %v, want %v", tt.input, result, tt.matches)
+			var got []string
+			for _, f := range scanFences(tt.input) {
+				got = append(got, f.language, f.source)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("scanFences(%q) = %q, want %q", tt.input, got, tt.want)
 			}
 		})
 	}
 }
 
+func TestPreprocessor_NestedFences(t *testing.T) {
+	mock := &MockRenderer{}
+	markdown := "````markdown\n```mermaid\ngraph LR\n```\n````\n\n    ```mermaid\n    graph LR\n    ```\n\n```mermaid\ngraph TD\n```\nAfter"
+	result := NewPreprocessor(mock, 0, "").Process(markdown)
+	if len(mock.Calls) != 1 || mock.Calls[0] != "graph TD" {
+		t.Errorf("Expected only the top-level diagram to render, got %q", mock.Calls)
+	}
+	if !strings.HasPrefix(result, "````markdown\n```mermaid\ngraph LR\n```\n````\n\n    ```mermaid\n") ||
+		!strings.HasSuffix(result, "```\nAfter") {
+		t.Errorf("Expected the examples to be kept, got:\n%s", result)
+	}
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -240,7 +313,7 @@ func TestSupportedDiagramTypes(t *testing.T) {
     B -->|Yes| C[OK]
     B -->|No| D[Cancel]`
 
-		result, err := r.Render(source, 0)
+		result, err := r.Render(source, 0, "")
 		if err != nil {
 			t.Fatalf("Flowchart LR rendering failed: %v", err)
 		}
@@ -270,7 +343,7 @@ func TestSupportedDiagramTypes(t *testing.T) {
     A[Top] --> B[Middle]
     B --> C[Bottom]`
 
-		result, err := r.Render(source, 0)
+		result, err := r.Render(source, 0, "")
 		if err != nil {
 			t.Fatalf("Flowchart TD rendering failed: %v", err)
 		}
@@ -295,7 +368,7 @@ func TestSupportedDiagramTypes(t *testing.T) {
     Alice->>Bob: How are you?
     Bob-->>Alice: I'm good!`
 
-		result, err := r.Render(source, 0)
+		result, err := r.Render(source, 0, "")
 		if err != nil {
 			t.Fatalf("Sequence diagram rendering failed: %v", err)
 		}
@@ -338,7 +411,7 @@ func TestFlowchartWithLabels(t *testing.T) {
     A --> |label1| B
     B --> |label2| C`
 
-	result, err := r.Render(source, 0)
+	result, err := r.Render(source, 0, "")
 	if err != nil {
 		t.Fatalf("Flowchart with labels rendering failed: %v", err)
 	}
@@ -368,7 +441,7 @@ func TestNodeLabelDeduplication(t *testing.T) {
     agent[mx-agent] --> tenants
     agent --> domains`
 
-	result, err := r.Render(source, 0)
+	result, err := r.Render(source, 0, "")
 	if err != nil {
 		t.Fatalf("Rendering failed: %v", err)
 	}
@@ -404,7 +477,7 @@ And here's a sequence diagram:
 
 The end.`
 
-	result := ProcessMarkdown(markdown, 0) // 0 = no width limit
+	result := ProcessMarkdown(markdown, 0, "") // 0 = no width limit
 
 	// The mermaid blocks should be replaced with rendered output
 	if strings.Contains(result, "```mermaid") {
@@ -496,6 +569,22 @@ func TestIsTooComplex(t *testing.T) {
     b1 --> a1`,
 			expected: true, // 3 subgraphs, 11 edges - over limit (>2 AND >10)
 		},
+		{
+			name: "commented out edges are not counted",
+			source: `%% a leading comment
+graph LR
+    A --> B --> C --> D --> E --> F --> G --> H --> I --> J --> K
+    %% K --> L --> M --> N --> O --> P --> Q --> R --> S --> T --> U --> V`,
+			expected: false, // 10 edges once the comment is removed
+		},
+		{
+			name: "leading comment before an over-limit flowchart",
+			source: `%% a leading comment
+graph LR
+    A --> B --> C --> D --> E --> F --> G --> H --> I --> J --> K
+    K --> L --> M --> N --> O --> P --> Q --> R --> S --> T --> U --> V`,
+			expected: true,
+		},
 		{
 			name: "too many edges overall",
 			source: `graph LR
@@ -507,7 +596,7 @@ func TestIsTooComplex(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := isTooComplex(tt.source)
+			result := isTooComplex(tt.source, DefaultLimits())
 			if result != tt.expected {
 				t.Errorf("isTooComplex() = %v, want %v", result, tt.expected)
 			}
@@ -566,13 +655,13 @@ func TestComplexDiagramFromEXAMPLE(t *testing.T) {
     manager --> servo
     servoDriver -->|/servo/cmd/trajectory<br/>JointTrajectory| servo`
 
-	if !isTooComplex(source) {
+	if !isTooComplex(source, DefaultLimits()) {
 		t.Error("EXAMPLE.md flowchart should be detected as too complex")
 	}
 
 	// Also verify that the renderer returns ErrTooComplex
 	r := NewRenderer()
-	_, err := r.Render(source, 0)
+	_, err := r.Render(source, 0, "")
 	if !errors.Is(err, ErrTooComplex) {
 		t.Errorf("Render() should return ErrTooComplex for complex diagram, got: %v", err)
 	}
@@ -586,7 +675,7 @@ func TestWidthLimitEnforcement(t *testing.T) {
     A[Start] --> B[End]`
 
 	// Should succeed with no width limit
-	result, err := r.Render(source, 0)
+	result, err := r.Render(source, 0, "")
 	if err != nil {
 		t.Fatalf("Render with no limit should succeed: %v", err)
 	}
@@ -600,13 +689,13 @@ func TestWidthLimitEnforcement(t *testing.T) {
 	}
 
 	// Should succeed with generous width limit
-	_, err = r.Render(source, actualWidth+10)
+	_, err = r.Render(source, actualWidth+10, "")
 	if err != nil {
 		t.Errorf("Render with generous limit should succeed: %v", err)
 	}
 
 	// Should fail with tight width limit
-	_, err = r.Render(source, 10)
+	_, err = r.Render(source, 10, "")
 	if !errors.Is(err, ErrTooComplex) {
 		t.Errorf("Render with tight limit should return ErrTooComplex, got: %v", err)
 	}
@@ -619,7 +708,7 @@ func TestTooComplexShowsOriginalWithNote(t *testing.T) {
 			return "", ErrTooComplex
 		},
 	}
-	p := NewPreprocessor(mock, 0)
+	p := NewPreprocessor(mock, 0, "")
 
 	markdown := "# Title\n\n```mermaid\ngraph LR\n    A --> B\n```\n\nText"
 	result := p.Process(markdown)
@@ -646,7 +735,7 @@ func TestTooComplexShowsOriginalWithNote(t *testing.T) {
 // ====================
 
 func TestComplexityBoundary_MaxTotalEdges(t *testing.T) {
-	// maxTotalEdges = 20, test at boundaries
+	// MaxEdges = 20, test at boundaries
 	tests := []struct {
 		name      string
 		edgeCount int
@@ -674,7 +763,7 @@ func TestComplexityBoundary_MaxTotalEdges(t *testing.T) {
 				edges.WriteString("\n")
 			}
 
-			result := isTooComplex(edges.String())
+			result := isTooComplex(edges.String(), DefaultLimits())
 			if result != tt.tooComplex {
 				t.Errorf("isTooComplex() with %d edges = %v, want %v", tt.edgeCount, result, tt.tooComplex)
 			}
@@ -683,7 +772,7 @@ func TestComplexityBoundary_MaxTotalEdges(t *testing.T) {
 }
 
 func TestComplexityBoundary_SubgraphsWithEdges(t *testing.T) {
-	// maxSubgraphsWithEdges = 2, maxEdgesWithSubgraphs = 10
+	// MaxSubgraphs = 2, MaxEdgesWithSubgraphs = 10
 	// Complex if subgraphs > 2 AND edges > 10
 	tests := []struct {
 		name           string
@@ -724,7 +813,7 @@ func TestComplexityBoundary_SubgraphsWithEdges(t *testing.T) {
 				src.WriteString("\n")
 			}
 
-			result := isTooComplex(src.String())
+			result := isTooComplex(src.String(), DefaultLimits())
 			if result != tt.tooComplex {
 				t.Errorf("isTooComplex() with %d subgraphs and %d edges = %v, want %v",
 					tt.subgraphCount, tt.edgeCount, result, tt.tooComplex)
@@ -774,7 +863,7 @@ func TestComplexity_NonFlowchartNeverComplex(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if isTooComplex(tt.source) {
+			if isTooComplex(tt.source, DefaultLimits()) {
 				t.Errorf("Non-flowchart should never be too complex: %s", tt.name)
 			}
 		})
@@ -802,7 +891,7 @@ func TestUnicodeContentInDiagrams(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := r.Render(tt.source, 0)
+			result, err := r.Render(tt.source, 0, "")
 			if err != nil {
 				t.Skipf("Unicode rendering not supported: %v", err)
 			}
@@ -840,7 +929,7 @@ func TestUnicodeContentInDiagrams_NonASCII(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := r.Render(tt.source, 0)
+			result, err := r.Render(tt.source, 0, "")
 			if err != nil {
 				t.Logf("Non-ASCII rendering failed (expected in some environments): %v", err)
 				return
@@ -860,7 +949,7 @@ func TestNestedCodeBlocksInMarkdown(t *testing.T) {
 			return "RENDERED", nil
 		},
 	}
-	p := NewPreprocessor(mock, 0)
+	p := NewPreprocessor(mock, 0, "")
 	result := p.Process(markdown)
 
 	// The inner mermaid block might still be detected by our regex
@@ -892,7 +981,7 @@ func TestEmptyAndWhitespaceBlocks(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mock.Calls = nil
-			p := NewPreprocessor(mock, 0)
+			p := NewPreprocessor(mock, 0, "")
 			_ = p.Process(tt.markdown)
 
 			if len(mock.Calls) > 0 {
@@ -912,7 +1001,7 @@ func TestGetMaxLineWidth(t *testing.T) {
 		{"single line", "hello", 5},
 		{"multi line", "short\nlonger line\nmed", 11},
 		{"unicode chars", "héllo wörld", 11},
-		{"wide unicode", "日本語", 3}, // 3 runes, though display width varies
+		{"wide unicode", "日本語", 6}, // 3 runes, two columns each
 	}
 
 	for _, tt := range tests {
@@ -948,3 +1037,888 @@ func TestEdgePatternDetection(t *testing.T) {
 		})
 	}
 }
+
+func TestStyleColors(t *testing.T) {
+	if p := paletteForStyle("dracula"); p == nil || p.Border != "#bd93f9" || p.Arrow != "#8be9fd" || p.Label != "#f8f8f2" {
+		t.Errorf("Unexpected dracula palette: %+v", p)
+	}
+	for _, style := range []string{"notty", "ascii", "", "/path/to/style.json"} {
+		if p := paletteForStyle(style); p != nil {
+			t.Errorf("Expected no palette for %q, got %+v", style, p)
+		}
+	}
+
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	defer lipgloss.SetColorProfile(termenv.Ascii)
+
+	r := NewRenderer()
+	for _, source := range []string{
+		"graph LR\n    A --> B",
+		"sequenceDiagram\n    Alice->>Bob: Hi",
+		"quadrantChart\n    A: [0.3, 0.6]",
+	} {
+		plain, err := r.Render(source, 0, "notty")
+		if err != nil {
+			t.Fatalf("Render() error: %v", err)
+		}
+		colored, err := r.Render(source, 0, "dracula")
+		if err != nil {
+			t.Fatalf("Render() error: %v", err)
+		}
+		if colored == plain {
+			t.Errorf("Expected colored output for %q", source)
+		}
+		if ansi.Strip(colored) != plain {
+			t.Errorf("Colors changed the layout:\n%s\nwant:\n%s", ansi.Strip(colored), plain)
+		}
+		if getMaxLineWidth(colored) != getMaxLineWidth(plain) {
+			t.Errorf("Colors counted towards the width of %q", source)
+		}
+	}
+
+	mock := &MockRenderer{}
+	NewPreprocessor(mock, 0, "dracula").Process("```mermaid\ngraph LR\n  A --> B\n```")
+	if len(mock.Styles) != 1 || mock.Styles[0] != "dracula" {
+		t.Errorf("Expected the style to be passed to the renderer, got %v", mock.Styles)
+	}
+}
+
+func TestKeepWide(t *testing.T) {
+	wide := "┌───┐     ┌───┐\n│ A ├────►│ B │\n└───┘     └───┘"
+	mock := &MockRenderer{RenderFunc: func(string) (string, error) {
+		return "", &WidthError{Output: wide}
+	}}
+	markdown := "```mermaid\ngraph LR\n  A --> B\n```"
+
+	// Without KeepWide the source is shown
+	if result := NewPreprocessor(mock, 10, "").Process(markdown); !strings.Contains(result, tooComplexNote) {
+		t.Errorf("Expected the too complex note, got:\n%s", result)
+	}
+
+	p := NewPreprocessor(mock, 10, "")
+	p.KeepWide()
+	result := p.Process(markdown)
+	if strings.Contains(result, "│ A ├") || !strings.Contains(result, placeholder(0, 1)) {
+		t.Errorf("Expected placeholders, got:\n%s", result)
+	}
+
+	// Simulate glamour indenting and padding the code block
+	var rendered []string
+	for _, line := range strings.Split(result, "\n") {
+		rendered = append(rendered, "  "+line+"   ")
+	}
+	restored := p.Restore(strings.Join(rendered, "\n"))
+	for _, line := range strings.Split(wide, "\n") {
+		if !strings.Contains(restored, "\n  "+line+"\n") {
+			t.Errorf("Expected %q to be restored, got:\n%s", line, restored)
+		}
+	}
+}
+
+func TestExtractDiagrams(t *testing.T) {
+	markdown := "# Doc\n\n```mermaid\ngraph LR\n  A --> B\n```\n\n```go\nfunc main() {}\n```\n\n~~~mermaid\nsequenceDiagram\n  A->>B: Hi\n~~~\n\n```mermaid\n```\n"
+	got := ExtractDiagrams(markdown)
+	want := []string{"graph LR\n  A --> B", "sequenceDiagram\n  A->>B: Hi"}
+	if len(got) != len(want) {
+		t.Fatalf("ExtractDiagrams() = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ExtractDiagrams()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDetectImageProtocol(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		want ImageProtocol
+	}{
+		{map[string]string{"TERM": "xterm-kitty"}, ProtocolKitty},
+		{map[string]string{"KITTY_WINDOW_ID": "1", "TERM": "xterm-256color"}, ProtocolKitty},
+		{map[string]string{"TERM_PROGRAM": "iTerm.app"}, ProtocolITerm2},
+		{map[string]string{"TERM_PROGRAM": "WezTerm"}, ProtocolITerm2},
+		{map[string]string{"TERM": "foot"}, ProtocolSixel},
+		{map[string]string{"TERM": "xterm-256color"}, ProtocolNone},
+		{map[string]string{}, ProtocolNone},
+	}
+	for _, tt := range tests {
+		got := detectImageProtocol(func(key string) string { return tt.env[key] })
+		if got != tt.want {
+			t.Errorf("detectImageProtocol(%v) = %v, want %v", tt.env, got, tt.want)
+		}
+	}
+}
+
+// testPNG returns a 2x7 PNG whose left column is red and right column
+// transparent.
+func testPNG(t *testing.T) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 7))
+	for y := 0; y < 7; y++ {
+		img.Set(0, y, color.NRGBA{R: 255, A: 255})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestEncodeImage(t *testing.T) {
+	img := testPNG(t)
+
+	kitty, err := encodeImage(bytes.Repeat(img, 100), ProtocolKitty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(kitty, "\x1b_Gf=100,a=T,m=1;") || !strings.Contains(kitty, "\x1b_Gm=0;") {
+		t.Errorf("Expected a chunked kitty image, got %q", kitty[:40])
+	}
+
+	iterm, err := encodeImage(img, ProtocolITerm2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(iterm, "\x1b]1337;File=inline=1;") || !strings.HasSuffix(iterm, "\a") {
+		t.Errorf("Unexpected iTerm2 image %q", iterm)
+	}
+
+	sixel, err := encodeImage(img, ProtocolSixel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Red is register 180; the first band fills six rows, the second one
+	if !strings.HasSuffix(sixel, "#180~?-#180@?-\x1b\\") {
+		t.Errorf("Unexpected sixel image %q", sixel[len(sixel)-30:])
+	}
+	for _, s := range []string{kitty, iterm, sixel} {
+		if !isImage(s) {
+			t.Errorf("Expected %q to be detected as an image", s[:10])
+		}
+	}
+}
+
+// fakeMermaidCLI returns a fake mermaid CLI that writes the PNG of testPNG,
+// and records its arguments in a file named args next to it.
+func fakeMermaidCLI(t *testing.T) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the mermaid CLI")
+	}
+
+	dir := t.TempDir()
+	fixture := filepath.Join(dir, "fixture.png")
+	if err := os.WriteFile(fixture, testPNG(t), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\necho \"$@\" > " + filepath.Join(dir, "args") + "\n" +
+		"while [ $# -gt 0 ]; do [ \"$1\" = -o ] && out=$2; shift; done\ncp " + fixture + " \"$out\"\n"
+	command := filepath.Join(dir, "mmdc")
+	if err := os.WriteFile(command, []byte(script), 0o700); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+	return command
+}
+
+func TestImageRenderer(t *testing.T) {
+	command := fakeMermaidCLI(t)
+	dir := filepath.Dir(command)
+
+	r := NewImageRenderer(ProtocolITerm2)
+	r.command = command
+	p := NewPreprocessor(r, 80, "dark")
+	result := p.Process("Before\n\n```mermaid\ngraph LR\n  A --> B\n```\n\nAfter")
+	if strings.Contains(result, "\x1b") || !strings.Contains(result, placeholder(0, 0)) {
+		t.Errorf("Expected a placeholder for the image, got:\n%s", result)
+	}
+	if restored := p.Restore(result); !strings.Contains(restored, "\x1b]1337;File=inline=1;") {
+		t.Errorf("Expected the image after restoring, got:\n%s", restored)
+	}
+
+	// Without a terminal image protocol, or the mermaid CLI, diagrams are ASCII
+	for _, r := range []*ImageRenderer{NewImageRenderer(ProtocolNone), {protocol: ProtocolKitty, command: filepath.Join(dir, "missing"), fallback: NewRenderer()}} {
+		if r.protocol == ProtocolNone {
+			r.command = command
+		}
+		out, err := r.Render("graph LR\n  A --> B", 80, "dark")
+		if err != nil || !strings.Contains(out, "│ A ├") {
+			t.Errorf("Expected the ASCII fallback, got %q, %v", out, err)
+		}
+	}
+}
+
+// TestImageRenderer_Theme tests that images are themed for the terminal's
+// background, whatever the style.
+func TestImageRenderer_Theme(t *testing.T) {
+	command := fakeMermaidCLI(t)
+	defer lipgloss.SetHasDarkBackground(lipgloss.HasDarkBackground())
+
+	for _, dark := range []bool{true, false} {
+		lipgloss.SetHasDarkBackground(dark)
+		r := NewImageRenderer(ProtocolITerm2)
+		r.command = command
+		if _, err := r.Render("graph LR\n  A --> B", 80, "light"); err != nil {
+			t.Fatal(err)
+		}
+		args, _ := os.ReadFile(filepath.Join(filepath.Dir(command), "args"))
+		want := "-t default"
+		if dark {
+			want = "-t dark"
+		}
+		if !strings.Contains(string(args), want) {
+			t.Errorf("Expected %q on a dark background (%t), got %q", want, dark, args)
+		}
+	}
+}
+
+// TestImageRenderer_Available tests that the mermaid CLI is looked for once,
+// and that only kitty images fit cells.
+func TestImageRenderer_Available(t *testing.T) {
+	command := fakeMermaidCLI(t)
+
+	r := NewImageRenderer(ProtocolKitty)
+	r.command = command
+	if !r.Available() {
+		t.Fatal("Expected images with the mermaid CLI installed")
+	}
+	if err := os.Remove(command); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Available() {
+		t.Error("Expected the mermaid CLI to be looked for once")
+	}
+
+	for protocol, want := range map[ImageProtocol]bool{ProtocolKitty: true, ProtocolITerm2: false, ProtocolSixel: false} {
+		r := NewImageRenderer(protocol)
+		r.command = "sh"
+		r.FitCells()
+		if got := r.Available(); got != want {
+			t.Errorf("Expected images fitting cells with protocol %d to be available: %t, got %t", protocol, want, got)
+		}
+	}
+}
+
+func TestPlaceImage(t *testing.T) {
+	// 2x7 pixels take a column and two rows
+	out, err := placeImage(testPNG(t), 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(out, "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected a line for each of 2 rows, got %q", out)
+	}
+	if !strings.HasPrefix(lines[0], "\x1b_Gf=100,a=T,U=1,q=2,i=") || !strings.Contains(lines[0], ",c=1,r=2,m=0;") {
+		t.Errorf("Expected a virtual placement of 1x2 cells, got %q", lines[0])
+	}
+	for row, line := range lines {
+		cell := string([]rune{kitty.Placeholder, kitty.Diacritic(row), kitty.Diacritic(0)})
+		if !strings.Contains(line, cell) || ansi.StringWidth(line) != 1 {
+			t.Errorf("Expected row %d to be one placeholder, got %q", row, line)
+		}
+	}
+	if !isImage(out) {
+		t.Error("Expected a placed image to be detected as an image")
+	}
+
+	// Wide images are no wider than the page
+	img := image.NewNRGBA(image.Rect(0, 0, 800, 100))
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	out, err = placeImage(buf.Bytes(), 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(out, "\n"); !strings.Contains(lines[0], ",c=40,r=3,") || ansi.StringWidth(lines[2]) != 40 {
+		t.Errorf("Expected 40x3 cells, got %q", lines[0][:60])
+	}
+}
+
+func TestExport(t *testing.T) {
+	if _, err := ParseExportFormat("gif"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+
+	out, err := NewExporter(FormatTXT).Export(context.Background(), "graph LR\n  A --> B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "│ A ├") || strings.Contains(string(out), "\x1b") {
+		t.Errorf("Expected an uncolored text diagram, got:\n%s", out)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	// A fake mermaid CLI that writes the file extension it was asked for
+	dir := t.TempDir()
+	script := "#!/bin/sh\nwhile [ $# -gt 0 ]; do [ \"$1\" = -o ] && out=$2; shift; done\necho \"${out##*.}\" > \"$out\"\n"
+	command := filepath.Join(dir, "mmdc")
+	if err := os.WriteFile(command, []byte(script), 0o700); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+	for _, format := range []ExportFormat{FormatSVG, FormatPNG} {
+		e := NewExporter(format)
+		e.command = command
+		out, err := e.Export(context.Background(), "graph LR\n  A --> B")
+		if err != nil || strings.TrimSpace(string(out)) != string(format) {
+			t.Errorf("Export(%s) = %q, %v", format, out, err)
+		}
+	}
+
+	e := NewExporter(FormatSVG)
+	e.command = filepath.Join(dir, "missing")
+	if _, err := e.Export(context.Background(), "graph LR\n  A --> B"); err == nil {
+		t.Error("Expected an error without the mermaid CLI")
+	}
+}
+
+func TestPreprocessor_AddLanguage(t *testing.T) {
+	mermaidRenderer := &MockRenderer{}
+	d2Renderer := &MockRenderer{RenderFunc: func(source string) (string, error) {
+		if strings.Contains(source, "bad") {
+			return "", errors.New("syntax error")
+		}
+		return "d2: " + source, nil
+	}}
+	p := NewPreprocessor(mermaidRenderer, 80, "")
+	p.AddLanguage("d2", d2Renderer, time.Second)
+
+	result := p.Process("```mermaid\ngraph LR\n```\n\n```d2\nx -> y\n```\n\n~~~d2\nbad\n~~~\n\n```go\nx := 1\n```")
+	if len(mermaidRenderer.Calls) != 1 || len(d2Renderer.Calls) != 2 {
+		t.Errorf("Expected 1 mermaid and 2 d2 renders, got %q and %q", mermaidRenderer.Calls, d2Renderer.Calls)
+	}
+	for _, want := range []string{"```\nd2: x -> y\n```", "> ⚠ **D2 rendering error:** syntax error\n\n~~~d2\nbad\n~~~", "```go\nx := 1\n```"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("Expected %q in:\n%s", want, result)
+		}
+	}
+}
+
+func TestPreprocessor_LeaveMermaid(t *testing.T) {
+	mermaidRenderer := &MockRenderer{}
+	d2Renderer := &MockRenderer{RenderFunc: func(source string) (string, error) {
+		return "d2: " + source, nil
+	}}
+	p := NewPreprocessor(mermaidRenderer, 80, "")
+	p.AddLanguage("d2", d2Renderer, time.Second)
+	p.LeaveMermaid()
+	p.SetTimeout(time.Second)
+
+	input := "```mermaid\ngraph LR\n```\n\n```d2\nx -> y\n```"
+	result := p.Process(input)
+	if len(mermaidRenderer.Calls) != 0 || len(d2Renderer.Calls) != 1 {
+		t.Errorf("Expected only 1 d2 render, got %q and %q", mermaidRenderer.Calls, d2Renderer.Calls)
+	}
+	for _, want := range []string{"```mermaid\ngraph LR\n```", "```\nd2: x -> y\n```"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("Expected %q in:\n%s", want, result)
+		}
+	}
+}
+
+func TestPreprocessor_Failures(t *testing.T) {
+	p := NewPreprocessor(&MockRenderer{RenderFunc: func(source string) (string, error) {
+		switch {
+		case strings.Contains(source, "bad"):
+			return "", errors.New("syntax error")
+		case strings.Contains(source, "huge"):
+			return "", ErrTooComplex
+		}
+		return "ok", nil
+	}}, 80, "")
+
+	p.Process("```mermaid\ngraph LR\n```\n\n```mermaid\nhuge\n```\n\n```mermaid\nbad\n```")
+	if failures := p.Failures(); len(failures) != 1 || failures[0].Error() != "Mermaid: syntax error" {
+		t.Errorf("Expected only the bad diagram to fail, got %v", failures)
+	}
+	if p.Process("```mermaid\ngraph LR\n```"); p.Failures() != nil {
+		t.Errorf("Expected no failures once processed again, got %v", p.Failures())
+	}
+}
+
+func TestPreprocessor_NumberFigures(t *testing.T) {
+	renderer := &MockRenderer{RenderFunc: func(source string) (string, error) {
+		if strings.Contains(source, "bad") {
+			return "", errors.New("syntax error")
+		}
+		return "diagram", nil
+	}}
+	p := NewPreprocessor(renderer, 80, "")
+	p.NumberFigures()
+
+	result := p.Process("```mermaid Login *flow*\ngraph LR\n```\n\n" +
+		"```mermaid\nbad\n```\n\n" +
+		"~~~mermaid caption=\"Checkout\" {.wide}\ngraph LR\n~~~\n\n" +
+		"```mermaid\ngraph LR\n  %% caption: Logout\n```\n\n" +
+		"```mermaid\ngraph LR\n```")
+	for _, want := range []string{
+		"```\ndiagram\n```\n\n*Figure 1: Login \\*flow\\**",
+		"```mermaid\nbad\n```\n\n```\ndiagram\n```\n\n*Figure 2: Checkout*",
+		"*Figure 3: Logout*",
+		"```\ndiagram\n```\n\n*Figure 4*",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("Expected %q in:\n%s", want, result)
+		}
+	}
+	if strings.Contains(result, "Figure 5") {
+		t.Errorf("Expected the failed diagram not to be numbered:\n%s", result)
+	}
+
+	// Without numbering, the info string doesn't change anything
+	p = NewPreprocessor(renderer, 80, "")
+	if result := p.Process("```mermaid Login flow\ngraph LR\n```"); result != "```\ndiagram\n```" {
+		t.Errorf("Process() = %q", result)
+	}
+
+	// Captions of marked markdown are marked with the line of their fence
+	p.NumberFigures()
+	result = p.Process(sourcemap.Mark("# Title\n\n```mermaid\ngraph LR\n```", 5))
+	if want := sourcemap.MarkAs("*Figure 1*", 7); !strings.HasSuffix(result, "\n\n"+want) {
+		t.Errorf("Expected %q at the end of:\n%q", want, result)
+	}
+}
+
+func TestExecRenderer(t *testing.T) {
+	if r := NewExecRenderer("glow-missing-d2 -"); r != nil {
+		t.Error("Expected no renderer without the command")
+	}
+	if r := NewExecRenderer(""); r != nil {
+		t.Error("Expected no renderer without a command")
+	}
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	r := NewExecRenderer("tr a-z A-Z")
+	if r == nil {
+		t.Fatal("Expected a renderer")
+	}
+	if out, err := r.Render("x -> y", 80, ""); err != nil || out != "X -> Y" {
+		t.Errorf("Render() = %q, %v", out, err)
+	}
+
+	// Failures aren't rendered as mermaid
+	r = NewExecRenderer("false")
+	if out, err := r.Render("x -> y", 80, ""); err == nil {
+		t.Errorf("Expected an error, got %q", out)
+	}
+}
+
+func TestRegistry_RegisterCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell commands")
+	}
+
+	r := NewRegistry()
+	r.RegisterCommands(map[string]string{
+		"mermaid":  "tr a-z A-Z",
+		"shout":    "tr a-z A-Z",
+		"missing":  "glow-missing-renderer",
+		"d2":       "",
+		"dot":      "",
+		"plantuml": "",
+	}, t.TempDir())
+	if _, ok := r.languages["shout"]; !ok || len(r.languages) != 1 {
+		t.Fatalf("Expected only shout to be registered, got %v", r.languages)
+	}
+
+	p := NewPreprocessor(&MockRenderer{}, 80, "")
+	p.AddLanguages(r)
+	result := p.Process("```shout\nhello\n```\n\n```missing\nhello\n```")
+	for _, want := range []string{"```\nHELLO\n```", "```missing\nhello\n```"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("Expected %q in:\n%s", want, result)
+		}
+	}
+}
+
+func TestFilterPreprocessor(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell commands")
+	}
+
+	r := NewRegistry()
+	r.RegisterFilters(map[string]Filter{
+		"sql":     {Command: "tr a-z A-Z"},
+		"sh":      {Command: "sh", Output: FilterAppend},
+		"table":   {Command: "sed s/,/|/g", Output: FilterMarkdown},
+		"broken":  {Command: "false"},
+		"missing": {Command: "glow-missing-filter"},
+	})
+	if _, ok := r.languages["missing"]; ok {
+		t.Error("Expected no filter without the command")
+	}
+
+	p := NewFilterPreprocessor(r, 80, "")
+	input := "```sql\nselect 1\n```\n\n```sh\necho hi\n```\n\n```table\n|a,b|\n```\n\n```broken\nx\n```\n\n```mermaid\ngraph LR\n```"
+	want := "```sql\nSELECT 1\n```\n\n```sh\necho hi\n```\n\n```\nhi\n```\n\n|a|b|\n\n" +
+		"> ⚠ **broken filter error:** false: exit status 1\n\n```broken\nx\n```\n\n```mermaid\ngraph LR\n```"
+	if got := p.Process(input); got != want {
+		t.Errorf("Process() = %q, want %q", got, want)
+	}
+	if len(p.Failures()) != 0 {
+		t.Errorf("Expected filters not to count as failed diagrams, got %v", p.Failures())
+	}
+}
+
+func TestParseFilterOutput(t *testing.T) {
+	for input, want := range map[string]FilterOutput{"": FilterReplace, "replace": FilterReplace, "append": FilterAppend, "markdown": FilterMarkdown} {
+		if got, err := ParseFilterOutput(input); err != nil || got != want {
+			t.Errorf("ParseFilterOutput(%q) = %q, %v, want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseFilterOutput("prepend"); err == nil {
+		t.Error("Expected an error for an unknown output")
+	}
+}
+
+func TestCommandRenderer(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses unix commands as the external renderer")
+	}
+
+	r := NewCommandRenderer("tr a-z A-Z")
+	out, err := r.Render("graph LR\n  a --> b", 80, "dark")
+	if err != nil || out != "GRAPH LR\n  A --> B" {
+		t.Errorf("Expected the command's output, got %q, %v", out, err)
+	}
+
+	// Failing, silent and slow commands fall back to the ASCII renderer
+	slow := NewCommandRenderer("sleep 5")
+	slow.timeout = 50 * time.Millisecond
+	for _, r := range []*CommandRenderer{NewCommandRenderer("false"), NewCommandRenderer("true"), NewCommandRenderer(""), slow} {
+		out, err := r.Render("graph LR\n  A --> B", 80, "dark")
+		if err != nil || !strings.Contains(out, "│ A ├") {
+			t.Errorf("Expected the ASCII fallback for %v, got %q, %v", r.command, out, err)
+		}
+	}
+}
+
+func TestCachedRenderer(t *testing.T) {
+	dir := t.TempDir()
+	mock := &MockRenderer{RenderFunc: func(source string) (string, error) {
+		switch source {
+		case "wide":
+			return "", &WidthError{Output: "a very wide diagram"}
+		case "broken":
+			return "", errors.New("parse error")
+		}
+		return "rendered " + source, nil
+	}}
+	r := NewCachedRenderer(mock, dir)
+
+	for i := 0; i < 2; i++ {
+		if out, err := r.Render("graph", 80, "dark"); err != nil || out != "rendered graph" {
+			t.Errorf("Render() = %q, %v", out, err)
+		}
+		var wide *WidthError
+		if _, err := r.Render("wide", 80, "dark"); !errors.As(err, &wide) || wide.Output != "a very wide diagram" {
+			t.Errorf("Expected a WidthError with the diagram, got %v", err)
+		}
+		if _, err := r.Render("broken", 80, "dark"); err == nil {
+			t.Error("Expected an error for a broken diagram")
+		}
+	}
+	// Errors are rendered again, but not what was cached
+	if got := strings.Join(mock.Calls, ","); got != "graph,wide,broken,broken" {
+		t.Errorf("Expected cached diagrams not to be rendered again, got calls %s", got)
+	}
+
+	// Width and style are part of the key
+	r.Render("graph", 60, "dark")  //nolint:errcheck
+	r.Render("graph", 80, "light") //nolint:errcheck
+	if len(mock.Calls) != 6 {
+		t.Errorf("Expected a new width or style to render again, got calls %v", mock.Calls)
+	}
+
+	// So is the backend, and how the ASCII renderer is set up
+	if NewCachedRenderer(NewRenderer(), dir).key("graph", 80, "dark") == r.key("graph", 80, "dark") {
+		t.Error("Expected different backends to use different keys")
+	}
+	forced, limited := NewRenderer(), NewRenderer()
+	forced.Force()
+	limited.SetLimits(Limits{MaxNodes: 1})
+	ascii := NewCachedRenderer(NewRenderer(), dir).key("graph", 80, "dark")
+	if NewCachedRenderer(forced, dir).key("graph", 80, "dark") == ascii || NewCachedRenderer(limited, dir).key("graph", 80, "dark") == ascii {
+		t.Error("Expected the limits and force of the ASCII renderer in the key")
+	}
+}
+
+func TestCachedRendererFallback(t *testing.T) {
+	fallback := &MockRenderer{RenderFunc: func(source string) (string, error) { return "ascii " + source, nil }}
+	r := NewCachedRenderer(&CommandRenderer{command: []string{"false"}, timeout: time.Second, fallback: fallback}, t.TempDir())
+	for i := 0; i < 2; i++ {
+		if out, err := r.Render("graph", 80, "dark"); err != nil || out != "ascii graph" {
+			t.Errorf("Render() = %q, %v", out, err)
+		}
+	}
+	if len(fallback.Calls) != 2 {
+		t.Errorf("Expected diagrams the fallback rendered not cached, got calls %v", fallback.Calls)
+	}
+}
+
+func TestCachedRendererSize(t *testing.T) {
+	dir := t.TempDir()
+	mock := &MockRenderer{RenderFunc: func(source string) (string, error) { return strings.Repeat(source, 10), nil }}
+	r := NewCachedRenderer(mock, dir)
+	r.size = 25
+
+	r.Render("a", 80, "dark") //nolint:errcheck
+	r.Render("b", 80, "dark") //nolint:errcheck
+	// Using a makes b the one used longest ago
+	old := time.Now().Add(-time.Hour)
+	_ = os.Chtimes(filepath.Join(dir, r.key("a", 80, "dark")), old, old)
+	_ = os.Chtimes(filepath.Join(dir, r.key("b", 80, "dark")), old, old)
+	r.Render("a", 80, "dark") //nolint:errcheck
+	r.Render("c", 80, "dark") //nolint:errcheck
+
+	for source, cached := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, err := os.Stat(filepath.Join(dir, r.key(source, 80, "dark"))); (err == nil) != cached {
+			t.Errorf("Expected %q cached: %t", source, cached)
+		}
+	}
+}
+
+func TestRenderTimeout(t *testing.T) {
+	slow := &MockRenderer{RenderFunc: func(string) (string, error) {
+		time.Sleep(time.Second)
+		return "too late", nil
+	}}
+
+	p := NewPreprocessor(slow, 80, "dark")
+	p.SetTimeout(20 * time.Millisecond)
+	input := "```mermaid\ngraph LR\n  A --> B\n```"
+	start := time.Now()
+	result := p.Process(input)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected Process to give up after the timeout, took %v", elapsed)
+	}
+	if !strings.HasSuffix(result, input) || !strings.Contains(result, ErrTimeout.Error()) {
+		t.Errorf("Expected the original block with a timeout note, got:\n%s", result)
+	}
+
+	// Fast diagrams render as usual
+	if _, err := RenderTimeout(NewRenderer(), time.Second, "graph LR\n  A --> B", 80, ""); err != nil {
+		t.Errorf("Expected a quick diagram to render, got %v", err)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	// External commands are killed rather than left running
+	start = time.Now()
+	_, err := RenderTimeout(NewCommandRenderer("sleep 5"), 20*time.Millisecond, "graph LR\n  A --> B", 80, "")
+	if !errors.Is(err, ErrTimeout) || time.Since(start) > 2*time.Second {
+		t.Errorf("Expected the command to time out, got %v after %v", err, time.Since(start))
+	}
+}
+
+func TestPreprocessor_SetContext(t *testing.T) {
+	mock := &MockRenderer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := NewPreprocessor(mock, 80, "dark")
+	p.SetContext(ctx)
+	input := "```mermaid\ngraph LR\n  A --> B\n```"
+	if result := p.Process(input); result != input {
+		t.Errorf("Expected the diagram left as source once cancelled, got:\n%s", result)
+	}
+	if len(mock.Calls) != 0 {
+		t.Errorf("Expected no diagrams rendered once cancelled, got calls %v", mock.Calls)
+	}
+}
+
+func TestPreprocessor_SetContextStopsWorkers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mock := &MockRenderer{RenderFunc: func(source string) (string, error) {
+		cancel()
+		return "diagram", nil
+	}}
+
+	var input strings.Builder
+	for range 100 {
+		input.WriteString("```mermaid\ngraph LR\n  A --> B\n```\n\n")
+	}
+	p := NewPreprocessor(mock, 80, "dark")
+	p.SetContext(ctx)
+	p.Process(input.String())
+
+	// Only the diagrams already taken by workers are rendered
+	if workers := runtime.GOMAXPROCS(0); len(mock.Calls) > workers {
+		t.Errorf("Expected at most %d diagrams rendered once cancelled, got %d", workers, len(mock.Calls))
+	}
+}
+
+func TestPreprocessor_ProcessConcurrently(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	mock := &MockRenderer{RenderFunc: func(source string) (string, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		// Later diagrams finish first
+		delay := time.Duration(5-len(source)) * 20 * time.Millisecond
+		time.Sleep(delay)
+		return "diagram " + source, nil
+	}}
+
+	var input strings.Builder
+	for _, source := range []string{"A", "BB", "CCC", "DDDD"} {
+		input.WriteString("Text\n\n```mermaid\n" + source + "\n```\n\n")
+	}
+	result := NewPreprocessor(mock, 80, "").Process(input.String())
+
+	want := "Text\n\n```\ndiagram A\n```\n\nText\n\n```\ndiagram BB\n```\n\n" +
+		"Text\n\n```\ndiagram CCC\n```\n\nText\n\n```\ndiagram DDDD\n```\n\n"
+	if result != want {
+		t.Errorf("Expected diagrams in document order, got:\n%s", result)
+	}
+	if runtime.GOMAXPROCS(0) > 1 && maxInFlight.Load() < 2 {
+		t.Error("Expected diagrams to be rendered concurrently")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	source := "graph LR\n  A --> B\n  B --> C\n  C --> D & E"
+	tests := []struct {
+		name   string
+		limits Limits
+		want   bool
+	}{
+		{"defaults", DefaultLimits(), false},
+		{"no limits", Limits{}, false},
+		{"nodes", Limits{MaxNodes: 4}, true},
+		{"nodes within limit", Limits{MaxNodes: 5}, false},
+		{"edges", Limits{MaxEdges: 3}, true},
+		{"lines", Limits{MaxLines: 3}, true},
+		{"lines within limit", Limits{MaxLines: 4}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTooComplex(source, tt.limits); got != tt.want {
+				t.Errorf("isTooComplex() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Node IDs are counted once, with keywords and edge labels skipped
+	if n := countNodes("graph TD\n  subgraph One\n  A[Start] -->|yes| B\n  A --> C\n  end\n  style A fill:#f9f"); n != 3 {
+		t.Errorf("countNodes() = %d, want 3", n)
+	}
+}
+
+func TestForceRender(t *testing.T) {
+	var edges strings.Builder
+	edges.WriteString("graph LR\n")
+	for i := 0; i < 25; i++ {
+		fmt.Fprintf(&edges, "  N%d --> N%d\n", i, i+1)
+	}
+	source := edges.String()
+
+	if _, err := NewRenderer().Render(source, 0, ""); !errors.Is(err, ErrTooComplex) {
+		t.Fatalf("Expected the diagram to be too complex, got %v", err)
+	}
+
+	r := NewRenderer()
+	r.Force()
+	if _, err := r.Render(source, 0, ""); err != nil {
+		t.Errorf("Expected Force to render the diagram, got %v", err)
+	}
+
+	out, err := NewRenderer().Render("%%glow:force%%\n"+source, 0, "")
+	if err != nil {
+		t.Errorf("Expected %%%%glow:force%%%% to render the diagram, got %v", err)
+	}
+	if strings.Contains(out, "glow:force") {
+		t.Errorf("Expected the directive not to be drawn, got:\n%s", out)
+	}
+
+	r = NewRenderer()
+	r.SetLimits(Limits{MaxEdges: 30})
+	if _, err := r.Render(source, 0, ""); err != nil {
+		t.Errorf("Expected raised limits to render the diagram, got %v", err)
+	}
+}
+
+func TestPlainRender(t *testing.T) {
+	sources := []string{
+		"graph LR\n    A --> B",
+		"sequenceDiagram\n    Alice->>Bob: Hello",
+	}
+	for _, source := range sources {
+		r := NewRenderer()
+		r.Plain()
+		out, err := r.Render(source, 0, "notty")
+		if err != nil {
+			t.Fatalf("Render(%q) error = %v", source, err)
+		}
+		if strings.ContainsAny(out, "┌─│►▶") {
+			t.Errorf("Expected plain ASCII output, got:\n%s", out)
+		}
+		if !strings.ContainsAny(out, "+-|") {
+			t.Errorf("Expected ASCII box drawing, got:\n%s", out)
+		}
+	}
+
+	plain := NewRenderer()
+	plain.Plain()
+	if backendName(NewRenderer()) == backendName(plain) {
+		t.Error("Expected plain output to be cached separately")
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for input, want := range map[string]Mode{"": ModeImage, "off": ModeOff, "ascii": ModeASCII, "image": ModeImage} {
+		if got, err := ParseMode(input); err != nil || got != want {
+			t.Errorf("ParseMode(%q) = %q, %v, want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseMode("svg"); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
+
+func TestDocumentMode(t *testing.T) {
+	tests := []struct {
+		setting  string
+		fallback Mode
+		want     Mode
+	}{
+		{"", ModeASCII, ModeASCII},
+		{"off", ModeImage, ModeOff},
+		{"ascii", ModeOff, ModeASCII},
+		{"on", ModeASCII, ModeASCII},
+		{"on", ModeOff, DefaultMode},
+		{"svg", ModeOff, ModeOff},
+	}
+	for _, tt := range tests {
+		if got := DocumentMode(tt.setting, tt.fallback); got != tt.want {
+			t.Errorf("DocumentMode(%q, %q) = %q, want %q", tt.setting, tt.fallback, got, tt.want)
+		}
+	}
+}
+
+func TestErrorNote(t *testing.T) {
+	input := "```mermaid\nsequenceDiagram\n  A->>B: Hi\n  A *=> B\n```"
+	result := NewPreprocessor(NewRenderer(), 80, "").Process(input)
+	want := "> ⚠ **Mermaid syntax error** at line 3, column 3: invalid syntax near ` A *=> B `\n\n" + input
+	if result != want {
+		t.Errorf("Expected a warning above the code block, got:\n%s", result)
+	}
+
+	if note := errorNote("mermaid", errors.New("bad *node* [x]")); note != `> ⚠ **Mermaid rendering error:** bad \*node\* \[x\]` {
+		t.Errorf("Expected markdown in errors to be escaped, got %s", note)
+	}
+	if span := codeSpan("a`b"); span != "`` a`b ``" {
+		t.Errorf("codeSpan() = %s", span)
+	}
+}
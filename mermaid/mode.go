@@ -0,0 +1,48 @@
+package mermaid
+
+import "fmt"
+
+// Mode selects how mermaid diagrams are shown.
+type Mode string
+
+// Diagram modes.
+const (
+	// ModeOff leaves mermaid blocks as they are, showing their source
+	ModeOff Mode = "off"
+
+	// ModeASCII renders diagrams as text
+	ModeASCII Mode = "ascii"
+
+	// ModeImage shows diagrams as images where the terminal and output
+	// allow, and as text elsewhere
+	ModeImage Mode = "image"
+)
+
+// DefaultMode is the mode used unless configured.
+const DefaultMode = ModeImage
+
+// ParseMode returns the mode named s, or DefaultMode for "".
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case "":
+		return DefaultMode, nil
+	case ModeOff, ModeASCII, ModeImage:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid mermaid mode %q: must be %q, %q or %q", s, ModeOff, ModeASCII, ModeImage)
+}
+
+// DocumentMode returns the mode a document's setting asks for, or fallback if
+// it sets none: "off", "ascii" or "image", or "on" for fallback's mode, or
+// DefaultMode if fallback is off.
+func DocumentMode(setting string, fallback Mode) Mode {
+	switch m := Mode(setting); m {
+	case ModeOff, ModeASCII, ModeImage:
+		return m
+	case "on":
+		if fallback == ModeOff {
+			return DefaultMode
+		}
+	}
+	return fallback
+}
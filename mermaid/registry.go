@@ -0,0 +1,104 @@
+package mermaid
+
+import (
+	"maps"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultCommands are the programs that render diagrams of languages other
+// than mermaid, by code fence language. Each reads a diagram on stdin and
+// prints it as text. They're only used if installed.
+var DefaultCommands = map[string]string{
+	"d2":       "d2 --stdout-format txt - -",
+	"dot":      "graph-easy --from=dot --as=boxart",
+	"plantuml": "plantuml -tutxt -pipe",
+}
+
+// Registry maps code fence languages to the renderers of their diagrams, so
+// that diagrams of every language share detection, caching, concurrent
+// rendering and showing their source when they fail.
+type Registry struct {
+	languages map[string]language
+}
+
+// language is a code fence language whose blocks are rendered as diagrams.
+type language struct {
+	renderer Renderer
+	timeout  time.Duration
+
+	// filter is what the output of a code block filter does to the block,
+	// or "" for diagrams
+	filter FilterOutput
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{languages: make(map[string]language)}
+}
+
+// Register makes code blocks of a language render with renderer, giving each
+// diagram timeout to render (0 = no limit). It replaces any renderer the
+// language had.
+func (r *Registry) Register(name string, renderer Renderer, timeout time.Duration) {
+	r.languages[name] = language{renderer: renderer, timeout: timeout}
+}
+
+// RegisterCommands registers the programs of DefaultCommands, and commands,
+// which take precedence, for the languages they render. Programs that aren't
+// installed, and empty commands, are skipped, leaving those diagrams as
+// source. Output is cached in cacheDir, unless it's empty. Mermaid is
+// configured separately, so a mermaid command is ignored.
+func (r *Registry) RegisterCommands(commands map[string]string, cacheDir string) {
+	all := maps.Clone(DefaultCommands)
+	maps.Copy(all, commands)
+	for name, command := range all {
+		if name == "mermaid" {
+			continue
+		}
+		exec := NewExecRenderer(command)
+		if exec == nil {
+			continue
+		}
+		var renderer Renderer = exec
+		if cacheDir != "" {
+			renderer = NewCachedRenderer(exec, cacheDir)
+		}
+		r.Register(name, renderer, DefaultCommandTimeout)
+	}
+}
+
+// Applies reports whether markdown has code blocks of the registry's
+// languages.
+func (r *Registry) Applies(markdown string) bool {
+	for _, f := range scanFences(markdown) {
+		if _, ok := r.languages[f.language]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Include registers the languages of another registry, replacing those this
+// one has.
+func (r *Registry) Include(other *Registry) {
+	for name, l := range other.languages {
+		r.languages[name] = l
+	}
+}
+
+// NewExecRenderer creates a renderer that runs command, split on spaces, for
+// diagrams of languages other than mermaid. Diagrams the command fails on are
+// shown as source with the error. It returns nil if command is empty or its
+// program isn't installed.
+func NewExecRenderer(command string) *CommandRenderer {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil
+	}
+	if _, err := exec.LookPath(fields[0]); err != nil {
+		return nil
+	}
+	return &CommandRenderer{command: fields, timeout: DefaultCommandTimeout}
+}
@@ -0,0 +1,84 @@
+package mermaid
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+// The sixel palette is a 6x6x6 color cube.
+const sixelLevels = 6
+
+// sixelColor returns the palette index closest to a pixel, or -1 for
+// transparent pixels.
+func sixelColor(img image.Image, x, y int) int {
+	r, g, b, a := img.At(x, y).RGBA()
+	if a < 0x8000 {
+		return -1
+	}
+	level := func(c uint32) int {
+		return int(c*(sixelLevels-1)+0x7fff) / 0xffff
+	}
+	return (level(r)*sixelLevels+level(g))*sixelLevels + level(b)
+}
+
+// encodeSixel returns the sixel escape sequence for an image. Transparent
+// pixels are left as they are on screen.
+func encodeSixel(img image.Image) string {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\x1bP0;1q\"1;1;%d;%d", w, h)
+	for i := 0; i < sixelLevels*sixelLevels*sixelLevels; i++ {
+		r, g, bl := i/(sixelLevels*sixelLevels), i/sixelLevels%sixelLevels, i%sixelLevels
+		fmt.Fprintf(&b, "#%d;2;%d;%d;%d", i, r*100/(sixelLevels-1), g*100/(sixelLevels-1), bl*100/(sixelLevels-1))
+	}
+
+	// Each band is six pixel rows, drawn once per color it uses
+	for top := 0; top < h; top += 6 {
+		bands := make(map[int][]byte)
+		var colors []int
+		for x := 0; x < w; x++ {
+			for dy := 0; dy < 6 && top+dy < h; dy++ {
+				c := sixelColor(img, bounds.Min.X+x, bounds.Min.Y+top+dy)
+				if c < 0 {
+					continue
+				}
+				if bands[c] == nil {
+					bands[c] = make([]byte, w)
+					colors = append(colors, c)
+				}
+				bands[c][x] |= 1 << dy
+			}
+		}
+		for i, c := range colors {
+			if i > 0 {
+				b.WriteByte('$')
+			}
+			fmt.Fprintf(&b, "#%d", c)
+			writeSixelRun(&b, bands[c])
+		}
+		b.WriteByte('-')
+	}
+
+	b.WriteString("\x1b\\")
+	return b.String()
+}
+
+// writeSixelRun writes a row of sixels, compressing repeats.
+func writeSixelRun(b *strings.Builder, row []byte) {
+	for x := 0; x < len(row); {
+		n := 1
+		for x+n < len(row) && row[x+n] == row[x] {
+			n++
+		}
+		char := byte('?' + row[x])
+		if n > 3 {
+			fmt.Fprintf(b, "!%d%c", n, char)
+		} else {
+			b.WriteString(strings.Repeat(string(char), n))
+		}
+		x += n
+	}
+}
@@ -0,0 +1,70 @@
+package mermaid
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultRenderTimeout is how long a diagram may take to render before its
+// source is shown instead, so a pathological diagram can't hang loading the
+// document.
+const DefaultRenderTimeout = 500 * time.Millisecond
+
+// ErrTimeout is returned when a diagram takes too long to render.
+var ErrTimeout = errors.New("diagram took too long to render")
+
+// ContextRenderer is a Renderer that stops rendering when its context is
+// cancelled, such as one that runs an external program.
+type ContextRenderer interface {
+	Renderer
+
+	// RenderContext renders like Render, returning ErrTimeout once ctx is
+	// done.
+	RenderContext(ctx context.Context, source string, maxWidth int, style string) (string, error)
+}
+
+// RenderTimeout renders source with r, giving up with ErrTimeout after
+// timeout (0 = no limit).
+func RenderTimeout(r Renderer, timeout time.Duration, source string, maxWidth int, style string) (string, error) {
+	return renderTimeout(context.Background(), r, timeout, source, maxWidth, style)
+}
+
+// renderTimeout renders source with r until ctx is done or timeout passes
+// (0 = no limit).
+func renderTimeout(ctx context.Context, r Renderer, timeout time.Duration, source string, maxWidth int, style string) (string, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if ctx.Done() == nil {
+		return r.Render(source, maxWidth, style)
+	}
+	return renderContext(ctx, r, source, maxWidth, style)
+}
+
+// renderContext renders source with r until ctx is done. Renderers that
+// can't be cancelled are left to finish in the background.
+func renderContext(ctx context.Context, r Renderer, source string, maxWidth int, style string) (string, error) {
+	if cr, ok := r.(ContextRenderer); ok {
+		return cr.RenderContext(ctx, source, maxWidth, style)
+	}
+
+	type result struct {
+		out string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		out, err := r.Render(source, maxWidth, style)
+		done <- result{out, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.out, res.err
+	case <-ctx.Done():
+		return "", ErrTimeout
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hholst80/glow/mermaid"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportFormat string
+	exportDir    string
+
+	mermaidCmd = &cobra.Command{
+		Use:   "mermaid",
+		Short: "Work with the mermaid diagrams of a document",
+		Args:  cobra.NoArgs,
+	}
+
+	mermaidExportCmd = &cobra.Command{
+		Use:     "export SOURCE",
+		Short:   "Write the mermaid diagrams of a document to files",
+		Long:    paragraph(fmt.Sprintf("\n%s every mermaid diagram of a document to a file, named after the document and numbered in order. SVG and PNG need the mermaid CLI (mmdc).", keyword("Write"))),
+		Example: paragraph("glow mermaid export README.md\nglow mermaid export README.md --format svg --out diagrams/"),
+		Args:    cobra.ExactArgs(1),
+		RunE:    exportDiagrams,
+	}
+)
+
+func exportDiagrams(cmd *cobra.Command, args []string) error {
+	format, err := mermaid.ParseExportFormat(exportFormat)
+	if err != nil {
+		return err
+	}
+
+	src, err := sourceFromArg(args[0])
+	if err != nil {
+		return err
+	}
+	defer src.reader.Close() //nolint:errcheck
+	b, err := io.ReadAll(src.reader)
+	if err != nil {
+		return fmt.Errorf("unable to read from reader: %w", err)
+	}
+
+	diagrams := mermaid.ExtractDiagrams(string(b))
+	if len(diagrams) == 0 {
+		return fmt.Errorf("no mermaid diagrams in %s", args[0])
+	}
+	if err := os.MkdirAll(exportDir, 0o755); err != nil { //nolint:gosec
+		return fmt.Errorf("unable to create output directory: %w", err)
+	}
+
+	name := exportName(args[0])
+	exporter := mermaid.NewExporter(format)
+	for i, source := range diagrams {
+		out, err := exporter.Export(context.Background(), source)
+		if err != nil {
+			return fmt.Errorf("unable to export diagram %d: %w", i+1, err)
+		}
+		path := filepath.Join(exportDir, fmt.Sprintf("%s-%d.%s", name, i+1, format))
+		if err := os.WriteFile(path, out, 0o644); err != nil { //nolint:gosec
+			return fmt.Errorf("unable to write diagram %d: %w", i+1, err)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), path)
+	}
+	return nil
+}
+
+// exportName returns the name exported diagrams of a document start with:
+// the file name without its extension, or "diagram" for stdin.
+func exportName(arg string) string {
+	name := strings.TrimSuffix(filepath.Base(arg), filepath.Ext(arg))
+	if arg == "-" || name == "" || name == "." || name == "/" {
+		return "diagram"
+	}
+	return name
+}
+
+func init() {
+	mermaidExportCmd.Flags().StringVar(&exportFormat, "format", string(mermaid.FormatTXT), "file format: svg, png or txt")
+	mermaidExportCmd.Flags().StringVar(&exportDir, "out", ".", "directory to write the diagrams to")
+	mermaidCmd.AddCommand(mermaidExportCmd)
+}
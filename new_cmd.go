@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/charmbracelet/x/editor"
+	"github.com/hholst80/glow/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// defaultNoteTemplate is the template of notes when no other is given and
+// the templates directory has no default.md.
+const defaultNoteTemplate = "# {{.Title}}\n"
+
+var (
+	newTemplate string
+	newTUI      bool
+
+	newCmd = &cobra.Command{
+		Use:     "new TITLE",
+		Short:   "Create a note from a template",
+		Long:    paragraph(fmt.Sprintf("\n%s a markdown note named after its title in the notes directory, from a template in the templates directory, and open it in EDITOR, or in the TUI with --tui. Templates are markdown files in which {{.Title}}, {{.Date}}, {{.Time}} and {{.Now.Format \"Monday\"}} are replaced.", keyword("Create"))),
+		Example: paragraph("glow new \"Weekly sync\" --template meeting\nglow new Ideas --tui"),
+		Args:    cobra.ExactArgs(1),
+		RunE:    newNote,
+	}
+)
+
+// noteData is what note templates are executed with.
+type noteData struct {
+	Title string
+	Date  string // 2006-01-02
+	Time  string // 15:04
+	Now   time.Time
+}
+
+func newNote(cmd *cobra.Command, args []string) error {
+	dir := utils.ExpandPath(viper.GetString("notesDir"))
+	if dir == "" {
+		dir = "."
+	}
+	path, err := createNote(dir, templatesDir(), newTemplate, args[0], time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), path)
+
+	if newTUI {
+		if err := validateOptions(cmd); err != nil {
+			return err
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("unable to get absolute path: %w", err)
+		}
+		return runTUI(abs, "", nil, nil)
+	}
+	c, err := editor.Cmd("Glow", path)
+	if err != nil {
+		return fmt.Errorf("unable to open editor: %w", err)
+	}
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("unable to run command: %w", err)
+	}
+	return nil
+}
+
+// templatesDir returns the directory of note templates: the templatesDir
+// setting, or templates next to the config file.
+func templatesDir() string {
+	if dir := viper.GetString("templatesDir"); dir != "" {
+		return utils.ExpandPath(dir)
+	}
+	file := viper.ConfigFileUsed()
+	if file == "" {
+		file = configFile
+	}
+	return filepath.Join(filepath.Dir(file), "templates")
+}
+
+// createNote writes a note titled title in dir from the template called name
+// in templates, and returns its path. An empty name uses default.md, or a
+// heading of the title if there's none.
+func createNote(dir, templates, name, title string, now time.Time) (string, error) {
+	text := defaultNoteTemplate
+	file := filepath.Join(templates, strings.TrimSuffix(name, ".md")+".md")
+	if name == "" {
+		file = filepath.Join(templates, "default.md")
+	}
+	b, err := os.ReadFile(file)
+	switch {
+	case err == nil:
+		text = string(b)
+	case name != "" && errors.Is(err, fs.ErrNotExist):
+		return "", withExitCode(exitNotFound, fmt.Errorf("no template %q in %s", name, templates))
+	case name != "":
+		return "", fmt.Errorf("unable to read template: %w", err)
+	}
+	tmpl, err := template.New(filepath.Base(file)).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+	var note bytes.Buffer
+	data := noteData{title, now.Format("2006-01-02"), now.Format("15:04"), now}
+	if err := tmpl.Execute(&note, data); err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+
+	path := filepath.Join(dir, noteFileName(title))
+	if err := os.MkdirAll(dir, 0o755); err != nil { //nolint:gosec
+		return "", fmt.Errorf("unable to create notes directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644) //nolint:gosec
+	if errors.Is(err, fs.ErrExist) {
+		return "", fmt.Errorf("%s already exists", path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to create note: %w", err)
+	}
+	if _, err := f.Write(note.Bytes()); err != nil {
+		_ = f.Close()
+		return "", fmt.Errorf("unable to write note: %w", err)
+	}
+	return path, f.Close() //nolint:wrapcheck
+}
+
+// noteFileName returns the file name of a note, its title in lower case with
+// dashes between words, such as weekly-sync.md for "Weekly Sync".
+func noteFileName(title string) string {
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return "note.md"
+	}
+	return strings.Join(words, "-") + ".md"
+}
+
+func init() {
+	newCmd.Flags().StringVarP(&newTemplate, "template", "T", "", "template to create the note from, a file in the templates directory (default default.md)")
+	newCmd.Flags().BoolVarP(&newTUI, "tui", "t", false, "open the note in the TUI rather than EDITOR")
+}
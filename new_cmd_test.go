@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNoteFileName(t *testing.T) {
+	for title, want := range map[string]string{
+		"Weekly Sync":         "weekly-sync.md",
+		"  Q3: plans & ideas": "q3-plans-ideas.md",
+		"Café notes":          "café-notes.md",
+		"!!!":                 "note.md",
+	} {
+		if got := noteFileName(title); got != want {
+			t.Errorf("noteFileName(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
+
+func TestCreateNote(t *testing.T) {
+	templates := t.TempDir()
+	if err := os.WriteFile(filepath.Join(templates, "meeting.md"), []byte("# {{.Title}}\n\n{{.Date}} {{.Time}}, {{.Now.Format \"Monday\"}}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "notes")
+	now := time.Date(2024, 5, 6, 9, 30, 0, 0, time.UTC)
+
+	path, err := createNote(dir, templates, "meeting", "Weekly Sync", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "weekly-sync.md"); path != want {
+		t.Errorf("Expected note %s, got %s", want, path)
+	}
+	b, _ := os.ReadFile(path)
+	if want := "# Weekly Sync\n\n2024-05-06 09:30, Monday\n"; string(b) != want {
+		t.Errorf("Expected note %q, got %q", want, b)
+	}
+
+	// Notes aren't overwritten
+	if _, err := createNote(dir, templates, "meeting", "Weekly sync", now); err == nil {
+		t.Error("Expected an error for an existing note")
+	}
+
+	// Without a template or default.md, the note is its title
+	path, err = createNote(dir, templates, "", "Ideas", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "# Ideas\n" {
+		t.Errorf("Expected the default template, got %q", b)
+	}
+
+	_, err = createNote(dir, templates, "missing", "Other", now)
+	if exitCode(err) != exitNotFound {
+		t.Errorf("Expected exit code %d for a missing template, got %v", exitNotFound, err)
+	}
+}
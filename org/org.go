@@ -0,0 +1,425 @@
+// Package org converts Org documents to markdown, so they're rendered, and
+// their headings outlined, like markdown documents: headings with their TODO
+// keywords, lists, blocks, tables, links, footnotes and emphasis.
+package org
+
+import (
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hholst80/glow/dedent"
+)
+
+// Extension is the extension of Org documents.
+const Extension = ".org"
+
+var (
+	// headingRegex matches a heading: its stars, and its title with any
+	// priority and tags.
+	headingRegex = regexp.MustCompile(`^(\*+)\s+(.*?)\s*$`)
+
+	// tagsRegex matches the tags at the end of a heading, such as :work:.
+	tagsRegex = regexp.MustCompile(`\s+(:[\w@#%]+)+:$`)
+
+	// keywordRegex matches a keyword line, such as #+TITLE: Notes.
+	keywordRegex = regexp.MustCompile(`^\s*#\+(\w+):\s*(.*)$`)
+
+	// blockRegex matches the line opening a block, and blockEndRegex the one
+	// closing it.
+	blockRegex    = regexp.MustCompile(`(?i)^\s*#\+begin_(\w+)\s*(.*)$`)
+	blockEndRegex = regexp.MustCompile(`(?i)^\s*#\+end_(\w+)\s*$`)
+
+	// drawerRegex matches the line opening a drawer, such as :PROPERTIES:.
+	drawerRegex    = regexp.MustCompile(`^\s*:[\w-]+:\s*$`)
+	drawerEndRegex = regexp.MustCompile(`(?i)^\s*:end:\s*$`)
+
+	// planningRegex matches a line scheduling a heading.
+	planningRegex = regexp.MustCompile(`^\s*(SCHEDULED|DEADLINE|CLOSED):`)
+
+	// listRegex matches a list item: its indentation, bullet, checkbox and
+	// text.
+	listRegex = regexp.MustCompile(`^(\s*)([-+]|\s\*|\d+[.)])\s+(\[[ xX-]\]\s+)?(.*)$`)
+
+	// definitionRegex matches the term of a description list item.
+	definitionRegex = regexp.MustCompile(`^(.*?)\s+::(\s+|$)`)
+
+	// fixedRegex matches a line of fixed-width text.
+	fixedRegex = regexp.MustCompile(`^\s*:(\s|$)`)
+
+	// ruleRegex matches a horizontal rule.
+	ruleRegex = regexp.MustCompile(`^\s*-{5,}\s*$`)
+
+	// tableRuleRegex matches a rule between the rows of a table.
+	tableRuleRegex = regexp.MustCompile(`^\s*\|-`)
+
+	// footnoteRegex matches the definition of a footnote.
+	footnoteRegex = regexp.MustCompile(`^\[fn:([\w-]+)\]\s*(.*)$`)
+
+	// linkRegex matches a link, and footnoteRefRegex a footnote reference,
+	// at the start of text.
+	linkRegex        = regexp.MustCompile(`^\[\[([^\]]+)\](?:\[([^\]]+)\])?\]`)
+	footnoteRefRegex = regexp.MustCompile(`^\[fn:([\w-]+)\]`)
+)
+
+// IsOrgFile reports whether a file is an Org document, by its extension.
+func IsOrgFile(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), Extension)
+}
+
+// ToMarkdown returns an Org document as markdown. Its title becomes front
+// matter and the heading over the others, and its other keywords, comments
+// and drawers are left out.
+func ToMarkdown(org string) string {
+	lines := strings.Split(strings.ReplaceAll(org, "\r\n", "\n"), "\n")
+	todo, done := todoKeywords(lines)
+
+	var title string
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if m := blockRegex.FindStringSubmatch(line); m != nil {
+			end := i + 1
+			for end < len(lines) && !isBlockEnd(lines[end], m[1]) {
+				end++
+			}
+			out = append(out, block(strings.ToLower(m[1]), m[2], dedent.Lines(lines[i+1:min(end, len(lines))]))...)
+			i = end
+			continue
+		}
+		if m := keywordRegex.FindStringSubmatch(line); m != nil {
+			if strings.EqualFold(m[1], "title") && title == "" {
+				title = strings.TrimSpace(m[2])
+			}
+			continue
+		}
+		if drawerRegex.MatchString(line) && !drawerEndRegex.MatchString(line) {
+			end := i + 1
+			for end < len(lines) && !drawerEndRegex.MatchString(lines[end]) && !headingRegex.MatchString(lines[end]) {
+				end++
+			}
+			if end < len(lines) && drawerEndRegex.MatchString(lines[end]) {
+				i = end
+				continue
+			}
+		}
+
+		switch {
+		case strings.HasPrefix(line, "#") && (len(line) == 1 || line[1] == ' '):
+			// A comment
+		case headingRegex.MatchString(line):
+			m := headingRegex.FindStringSubmatch(line)
+			out = append(out, "", heading(len(m[1]), m[2], title != "", todo, done), "")
+		case planningRegex.MatchString(line):
+			out = append(out, "*"+strings.TrimSpace(line)+"*", "")
+		default:
+			// The body of a section, up to the next heading, without the
+			// indentation it has under its heading
+			end := i
+			for end < len(lines) && !headingRegex.MatchString(lines[end]) && !blockRegex.MatchString(lines[end]) &&
+				!(drawerRegex.MatchString(lines[end]) && !drawerEndRegex.MatchString(lines[end])) {
+				end++
+			}
+			out = append(out, body(dedent.Lines(lines[i:end]))...)
+			i = end - 1
+		}
+	}
+
+	text := strings.TrimLeft(strings.Join(out, "\n"), "\n")
+	if title != "" {
+		text = "---\ntitle: " + quote(title) + "\n---\n\n# " + inline(title) + "\n\n" + text
+	}
+	return text
+}
+
+// todoKeywords returns the keywords of headings to do and done: those the
+// document sets with #+TODO: lines, or TODO and DONE.
+func todoKeywords(lines []string) (todo, done []string) {
+	for _, line := range lines {
+		m := keywordRegex.FindStringSubmatch(line)
+		if m == nil || (!strings.EqualFold(m[1], "todo") && !strings.EqualFold(m[1], "seq_todo") && !strings.EqualFold(m[1], "typ_todo")) {
+			continue
+		}
+		active, finished, found := strings.Cut(m[2], "|")
+		words := strings.Fields(active)
+		if !found && len(words) > 0 {
+			// The last keyword is the done one
+			active, finished = strings.Join(words[:len(words)-1], " "), words[len(words)-1]
+		}
+		for _, w := range strings.Fields(active) {
+			todo = append(todo, keyword(w))
+		}
+		for _, w := range strings.Fields(finished) {
+			done = append(done, keyword(w))
+		}
+	}
+	if len(todo) == 0 && len(done) == 0 {
+		return []string{"TODO"}, []string{"DONE"}
+	}
+	return todo, done
+}
+
+// keyword returns a TODO keyword without the key it's given in #+TODO: lines,
+// such as (t).
+func keyword(w string) string {
+	if i := strings.Index(w, "("); i > 0 {
+		return w[:i]
+	}
+	return w
+}
+
+// heading returns a heading as markdown, one level deeper if the document's
+// title is the first, with its TODO keyword marked and its tags left out.
+func heading(level int, title string, titled bool, todo, done []string) string {
+	if titled {
+		level++
+	}
+	title = tagsRegex.ReplaceAllString(title, "")
+	word, rest, _ := strings.Cut(title, " ")
+	switch {
+	case slices.Contains(todo, word):
+		title = "☐ " + word + " " + rest
+	case slices.Contains(done, word):
+		title = "☑ " + word + " " + rest
+	}
+	return strings.Repeat("#", min(level, 6)) + " " + inline(strings.TrimSpace(title))
+}
+
+// body returns the lines of a section's body as markdown.
+func body(lines []string) []string {
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "#") && (len(line) == 1 || line[1] == ' '):
+			// A comment
+		case keywordRegex.MatchString(line):
+		case ruleRegex.MatchString(line):
+			out = append(out, "", "---", "")
+		case fixedRegex.MatchString(line):
+			out = append(out, "```")
+			for ; i < len(lines) && fixedRegex.MatchString(lines[i]); i++ {
+				out = append(out, strings.TrimPrefix(strings.TrimPrefix(strings.TrimLeft(lines[i], " \t"), ":"), " "))
+			}
+			out = append(out, "```")
+			i--
+		case strings.HasPrefix(strings.TrimSpace(line), "|"):
+			var rows []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), "|"); i++ {
+				rows = append(rows, lines[i])
+			}
+			out = append(out, table(rows)...)
+			i--
+		case footnoteRegex.MatchString(line):
+			m := footnoteRegex.FindStringSubmatch(line)
+			out = append(out, "[^"+m[1]+"]: "+inline(m[2]))
+		case listRegex.MatchString(line):
+			out = append(out, item(listRegex.FindStringSubmatch(line)))
+		default:
+			out = append(out, inline(line))
+		}
+	}
+	return out
+}
+
+// item returns a list item as markdown, with its checkbox, and the term of a
+// description list item in bold.
+func item(m []string) string {
+	bullet := "-"
+	if m[2] != "-" && m[2] != "+" && strings.TrimSpace(m[2]) != "*" {
+		bullet = strings.TrimSuffix(strings.TrimSuffix(m[2], ")"), ".") + "."
+	}
+	indent := m[1]
+	if strings.TrimSpace(m[2]) == "*" {
+		indent += " "
+	}
+	text := m[4]
+	if d := definitionRegex.FindStringSubmatch(text); d != nil && m[3] == "" {
+		text = "**" + inline(d[1]) + "**: " + inline(text[len(d[0]):])
+	} else {
+		text = inline(text)
+	}
+	switch strings.TrimSpace(m[3]) {
+	case "[ ]", "[-]":
+		text = "[ ] " + text
+	case "[x]", "[X]":
+		text = "[x] " + text
+	}
+	return indent + bullet + " " + text
+}
+
+// block returns a block as markdown: source and example blocks as code
+// blocks, quotes as blockquotes, and the contents of others as they are.
+func block(kind, args string, lines []string) []string {
+	switch kind {
+	case "src", "example", "export":
+		language := ""
+		if kind == "src" {
+			language = strings.ToLower(strings.Fields(args + " ")[0])
+		}
+		fence := "```"
+		for _, line := range lines {
+			for strings.Contains(line, fence) {
+				fence += "`"
+			}
+		}
+		out := append([]string{"", fence + language}, lines...)
+		return append(out, fence, "")
+	case "quote", "verse":
+		out := []string{""}
+		for _, line := range lines {
+			if kind == "verse" && strings.TrimSpace(line) != "" {
+				line += "\\"
+			}
+			out = append(out, strings.TrimRight("> "+inline(line), " "))
+		}
+		return append(out, "")
+	case "comment":
+		return nil
+	}
+	return append(append([]string{""}, body(lines)...), "")
+}
+
+// table returns the rows of a table as a markdown table, its first row the
+// header.
+func table(rows []string) []string {
+	var cells [][]string
+	columns := 0
+	for _, row := range rows {
+		if tableRuleRegex.MatchString(row) {
+			continue
+		}
+		row = strings.TrimSpace(row)
+		row = strings.TrimSuffix(strings.TrimPrefix(row, "|"), "|")
+		var rowCells []string
+		for _, c := range strings.Split(row, "|") {
+			rowCells = append(rowCells, inline(strings.TrimSpace(c)))
+		}
+		cells = append(cells, rowCells)
+		columns = max(columns, len(rowCells))
+	}
+	if len(cells) == 0 {
+		return nil
+	}
+
+	line := func(row []string) string {
+		padded := make([]string, columns)
+		copy(padded, row)
+		return "| " + strings.Join(padded, " | ") + " |"
+	}
+	out := []string{"", line(cells[0]), "|" + strings.Repeat("---|", columns)}
+	for _, row := range cells[1:] {
+		out = append(out, line(row))
+	}
+	return append(out, "")
+}
+
+// markers are the markers of emphasis and verbatim text, and their
+// markdown; verbatim text is a code span, and underlined text is shown in
+// italics.
+var markers = map[byte]string{'*': "**", '/': "*", '_': "_", '+': "~~", '=': "`", '~': "`"}
+
+// inline returns text with its links, footnote references, emphasis and
+// verbatim text as markdown.
+func inline(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if m := linkRegex.FindStringSubmatch(text[i:]); m != nil {
+			b.WriteString(link(m[1], m[2]))
+			i += len(m[0]) - 1
+			continue
+		}
+		if m := footnoteRefRegex.FindStringSubmatch(text[i:]); m != nil {
+			b.WriteString("[^" + m[1] + "]")
+			i += len(m[0]) - 1
+			continue
+		}
+		if end := emphasisEnd(text, i); end > 0 {
+			inner := text[i+1 : end]
+			if markers[text[i]] == "`" {
+				b.WriteString(code(inner))
+			} else {
+				b.WriteString(markers[text[i]] + inline(inner) + markers[text[i]])
+			}
+			i = end
+			continue
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
+
+// emphasisEnd returns the index of the marker closing the emphasis or
+// verbatim text opened at i, or 0 if none is. Markers open after spaces and
+// opening punctuation, and close before spaces and closing punctuation, with
+// no space inside them.
+func emphasisEnd(text string, i int) int {
+	marker := text[i]
+	if _, ok := markers[marker]; !ok || i+2 >= len(text) || isSpace(text[i+1]) {
+		return 0
+	}
+	if i > 0 && !strings.ContainsRune(" \t('\"{-", rune(text[i-1])) {
+		return 0
+	}
+	for j := i + 1; j < len(text); j++ {
+		if text[j] != marker || isSpace(text[j-1]) || j == i+1 {
+			continue
+		}
+		if j+1 == len(text) || strings.ContainsRune(" \t)'\"}-.,;:!?[]", rune(text[j+1])) {
+			return j
+		}
+	}
+	return 0
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+// link returns a link as markdown: to a URL or file, or the text of one to a
+// heading or target of the document.
+func link(target, description string) string {
+	target = strings.TrimSpace(target)
+	text := description
+	switch {
+	case strings.HasPrefix(target, "*"), strings.HasPrefix(target, "#"):
+		if text == "" {
+			text = strings.TrimLeft(target, "*# ")
+		}
+		return inline(text)
+	case strings.HasPrefix(target, "file:"):
+		target = strings.TrimPrefix(target, "file:")
+		if path, _, found := strings.Cut(target, "::"); found {
+			target = path
+		}
+	}
+	if text == "" {
+		text = target
+	}
+	return "[" + inline(text) + "](" + strings.ReplaceAll(target, " ", "%20") + ")"
+}
+
+// code returns verbatim text as a code span.
+func code(text string) string {
+	fence := "`"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		return fence + " " + text + " " + fence
+	}
+	return fence + text + fence
+}
+
+// isBlockEnd reports whether a line closes a block of a kind.
+func isBlockEnd(line, kind string) bool {
+	m := blockEndRegex.FindStringSubmatch(line)
+	return m != nil && strings.EqualFold(m[1], kind)
+}
+
+// quote returns a string quoted for YAML front matter.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,62 @@
+package org
+
+import "testing"
+
+func TestToMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "headings",
+			in:   "* TODO Write :work:\n** DONE [#A] Ship\n*** Plain\n",
+			want: "# ☐ TODO Write\n\n\n## ☑ DONE [#A] Ship\n\n\n### Plain\n\n",
+		},
+		{
+			name: "title",
+			in:   "#+TITLE: My \"Notes\"\n#+OPTIONS: toc:nil\n* One\n",
+			want: "---\ntitle: \"My \\\"Notes\\\"\"\n---\n\n# My \"Notes\"\n\n## One\n\n",
+		},
+		{
+			name: "custom keywords",
+			in:   "#+TODO: NEXT WAIT(w) | GONE\n* WAIT For it\n* GONE Away\n* TODO Not one\n",
+			want: "# ☐ WAIT For it\n\n\n# ☑ GONE Away\n\n\n# TODO Not one\n\n",
+		},
+		{
+			name: "emphasis and links",
+			in:   "*a* *b*, /it/, _u_, +s+, =v *x*=, ~c~ [[https://x.dev][site]] [[file:a.org]] [[*One]] note[fn:1]\n2 * 3 * 4 and /usr/ok\n",
+			want: "**a** **b**, *it*, _u_, ~~s~~, `v *x*`, `c` [site](https://x.dev) [a.org](a.org) One note[^1]\n2 * 3 * 4 and /usr/ok\n",
+		},
+		{
+			name: "indented body, drawers and planning",
+			in:   "* Task\n  DEADLINE: <2026-01-01 Thu>\n  :PROPERTIES:\n  :ID: x\n  :END:\n  Text.\n  - [ ] open\n  - [X] done\n    1) nested\n  - term :: meaning\n",
+			want: "# Task\n\n*DEADLINE: <2026-01-01 Thu>*\n\nText.\n- [ ] open\n- [x] done\n  1. nested\n- **term**: meaning\n",
+		},
+		{
+			name: "blocks",
+			in:   "#+BEGIN_SRC Go :results none\nx := \"```\"\n#+END_SRC\n#+begin_quote\nSaid /this/.\n#+end_quote\n#+BEGIN_COMMENT\nhidden\n#+END_COMMENT\n: fixed\n: width\n",
+			want: "````go\nx := \"```\"\n````\n\n\n> Said *this*.\n\n```\nfixed\nwidth\n```\n",
+		},
+		{
+			name: "tables, rules, comments and footnotes",
+			in:   "| A | B |\n|---+---|\n| 1 | *2* |\n# comment\n-----\n[fn:1] The note.\n",
+			want: "| A | B |\n|---|---|\n| 1 | **2** |\n\n\n---\n\n[^1]: The note.\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ToMarkdown(tt.in); got != tt.want {
+				t.Errorf("ToMarkdown() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsOrgFile(t *testing.T) {
+	for name, want := range map[string]bool{"notes.org": true, "NOTES.ORG": true, "notes.md": false, "org": false} {
+		if got := IsOrgFile(name); got != want {
+			t.Errorf("IsOrgFile(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hholst80/glow/document"
+	"github.com/spf13/cobra"
+)
+
+var (
+	parseJSON bool
+
+	parseCmd = &cobra.Command{
+		Use:     "parse SOURCE",
+		Short:   "Print the structure of a document",
+		Long:    paragraph(fmt.Sprintf("\n%s the headings, links, images, code blocks, tasks, footnotes, wiki links and front matter of a document with the lines they're on. With --json they're printed as JSON, for editor plugins and scripts.", keyword("Print"))),
+		Example: paragraph("glow parse README.md\nglow parse README.md --json | jq '.headings[].text'"),
+		Args:    cobra.ExactArgs(1),
+		RunE:    parseDocument,
+	}
+)
+
+func parseDocument(cmd *cobra.Command, args []string) error {
+	src, err := sourceFromArg(args[0])
+	if err != nil {
+		return err
+	}
+	defer src.reader.Close() //nolint:errcheck
+	b, err := io.ReadAll(src.reader)
+	if err != nil {
+		return fmt.Errorf("unable to read from reader: %w", err)
+	}
+
+	doc := document.Parse(b)
+	if parseJSON {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc) //nolint:wrapcheck
+	}
+
+	// Everything in the order it's in the document
+	type entry struct {
+		line       int
+		kind, text string
+	}
+	var entries []entry
+	if len(doc.Frontmatter) > 0 {
+		keys := make([]string, 0, len(doc.Frontmatter))
+		for k := range doc.Frontmatter {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		entries = append(entries, entry{1, "frontmatter", strings.Join(keys, ", ")})
+	}
+	for _, h := range doc.Headings {
+		entries = append(entries, entry{h.Line, fmt.Sprintf("h%d", h.Level), h.Text})
+	}
+	for _, l := range doc.Links {
+		entries = append(entries, entry{l.Line, "link", l.URL})
+	}
+	for _, l := range doc.Images {
+		entries = append(entries, entry{l.Line, "image", l.URL})
+	}
+	for _, c := range doc.CodeBlocks {
+		entries = append(entries, entry{c.Line, "code", c.Language})
+	}
+	for _, t := range doc.Tasks {
+		mark := "[ ] "
+		if t.Checked {
+			mark = "[x] "
+		}
+		entries = append(entries, entry{t.Line, "task", mark + t.Text})
+	}
+	for _, f := range doc.Footnotes {
+		entries = append(entries, entry{f.Line, "footnote", "[^" + f.Label + "] " + f.Text})
+	}
+	for _, l := range doc.WikiLinks {
+		entries = append(entries, entry{l.Line, "wikilink", l.Label})
+	}
+	slices.SortStableFunc(entries, func(a, b entry) int { return a.line - b.line })
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	for _, e := range entries {
+		fmt.Fprintf(w, "%d\t%s\t%s\n", e.line, e.kind, e.text)
+	}
+	return w.Flush() //nolint:wrapcheck
+}
+
+func init() {
+	parseCmd.Flags().BoolVar(&parseJSON, "json", false, "print the structure as JSON")
+}
@@ -0,0 +1,704 @@
+// Package rst converts reStructuredText documents to markdown, so the docs
+// of Python projects are rendered like markdown documents: sections, lists,
+// literal and code blocks, admonitions, images, tables, links and footnotes.
+package rst
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hholst80/glow/dedent"
+)
+
+// Extension is the extension of reStructuredText documents.
+const Extension = ".rst"
+
+// adornments are the characters section titles are underlined with.
+const adornments = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+// alerts are the kinds of GitHub alerts admonitions are shown as.
+var alerts = map[string]string{
+	"note": "NOTE", "seealso": "NOTE", "tip": "TIP", "hint": "TIP",
+	"important": "IMPORTANT", "warning": "WARNING", "attention": "WARNING",
+	"caution": "CAUTION", "danger": "CAUTION", "error": "CAUTION",
+}
+
+// versions are the notes of the directives marking changes in versions.
+var versions = map[string]string{
+	"versionadded":   "New in version",
+	"versionchanged": "Changed in version",
+	"deprecated":     "Deprecated since version",
+}
+
+var (
+	// directiveRegex matches a directive: its name and arguments.
+	directiveRegex = regexp.MustCompile(`^\.\.\s+([\w:-]+)::\s*(.*)$`)
+
+	// targetRegex matches a hyperlink target, and substitutionRegex the
+	// definition of a substitution.
+	targetRegex       = regexp.MustCompile(`^\.\.\s+_([^:]+|` + "`[^`]+`" + `):\s*(.*)$`)
+	substitutionRegex = regexp.MustCompile(`^\.\.\s+\|([^|]+)\|\s+([\w-]+)::\s*(.*)$`)
+
+	// footnoteRegex matches a footnote or citation: its label and text.
+	footnoteRegex = regexp.MustCompile(`^\.\.\s+\[(#?[\w-]*|\*)\]\s*(.*)$`)
+
+	// optionRegex matches an option of a directive, and fieldRegex a field
+	// of a field list.
+	optionRegex = regexp.MustCompile(`^:([\w-]+):\s*(.*)$`)
+	fieldRegex  = regexp.MustCompile(`^:([^:]+):(\s+(.*)|$)`)
+
+	// listRegex matches a list item: its indentation, bullet and text.
+	listRegex = regexp.MustCompile(`^(\s*)([-*+•]|\d+[.)]|\(\d+\)|#[.)])\s+(.*)$`)
+
+	// simpleBorderRegex matches the border of a simple table.
+	simpleBorderRegex = regexp.MustCompile(`^=+( +=+)+\s*$`)
+
+	// gridBorderRegex matches a border of a grid table.
+	gridBorderRegex = regexp.MustCompile(`^\+([-=]+\+)+\s*$`)
+
+	// literalRegex matches an inline literal, roleRegex text with a role,
+	// and referenceRegex a reference to a hyperlink, footnote or citation.
+	literalRegex   = regexp.MustCompile("``([^`]+)``")
+	roleRegex      = regexp.MustCompile(":([\\w:.-]+):`([^`]+)`")
+	referenceRegex = regexp.MustCompile("`([^`]+)`__?|\\[(#?[\\w-]*|\\*)\\]_|\\b([A-Za-z0-9][\\w.-]*[A-Za-z0-9]|[A-Za-z0-9])_(\\W|$)")
+
+	// embeddedRegex matches the text of a reference with its target
+	// embedded, such as "Python <https://python.org>".
+	embeddedRegex = regexp.MustCompile(`^(.*?)\s*<([^<>]+)>$`)
+
+	// codeRoles are the roles shown as code.
+	codeRoles = regexp.MustCompile(`^(py:|c:|cpp:|js:|std:)?(code|literal|func|meth|class|mod|attr|data|obj|exc|const|var|type|member|macro|envvar|file|samp|command|program|option|kbd|math|token|keyword)$`)
+
+	// signatureDirectives are the directives describing an API, whose
+	// arguments are shown as code.
+	signatureDirectives = regexp.MustCompile(`^(py:)?(function|class|method|attribute|data|exception|decorator|decoratormethod|classmethod|staticmethod|property|module)$`)
+)
+
+// IsRSTFile reports whether a file is a reStructuredText document, by its
+// extension.
+func IsRSTFile(name string) bool {
+	ext := filepath.Ext(name)
+	return strings.EqualFold(ext, Extension) || strings.EqualFold(ext, ".rest")
+}
+
+// converter converts a document, keeping what applies across it: the order
+// section styles are first used in, which gives their levels, and the targets
+// and substitutions references are resolved with.
+type converter struct {
+	styles        []string
+	targets       map[string]string
+	substitutions map[string]string
+	footnotes     int // footnotes numbered automatically, referenced
+	definitions   int // and defined
+}
+
+// ToMarkdown returns a reStructuredText document as markdown. Comments,
+// targets and directives that only affect how documents are built, such as
+// toctree options, are left out.
+func ToMarkdown(rst string) string {
+	rst = strings.ReplaceAll(strings.ReplaceAll(rst, "\r\n", "\n"), "\t", "        ")
+	lines := strings.Split(rst, "\n")
+	c := &converter{targets: map[string]string{}, substitutions: map[string]string{}}
+	for _, line := range lines {
+		if m := targetRegex.FindStringSubmatch(line); m != nil && m[2] != "" {
+			c.targets[strings.ToLower(strings.Trim(m[1], "`"))] = m[2]
+		}
+		if m := substitutionRegex.FindStringSubmatch(line); m != nil && m[2] == "replace" {
+			c.substitutions[m[1]] = m[3]
+		}
+	}
+	return strings.Trim(strings.Join(collapseBlank(c.convert(lines)), "\n"), "\n") + "\n"
+}
+
+// collapseBlank returns lines with the runs of blank lines the conversion
+// leaves between blocks made single blank lines, except in code blocks.
+func collapseBlank(lines []string) []string {
+	out := make([]string, 0, len(lines))
+	fence := ""
+	for _, line := range lines {
+		switch {
+		case fence == "" && strings.HasPrefix(line, "```"):
+			fence = strings.TrimRight(line, "abcdefghijklmnopqrstuvwxyz0123456789+-_.")
+		case fence != "" && line == fence:
+			fence = ""
+		case fence == "" && line == "" && len(out) > 0 && out[len(out)-1] == "":
+			continue
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// convert returns lines of a document, or of the content of a directive, as
+// markdown.
+func (c *converter) convert(lines []string) []string {
+	var out []string
+	list, literal := false, false
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			out = append(out, "")
+			i++
+			continue
+		}
+
+		if indentation(line) > 0 {
+			end := blockEnd(lines, i, 1)
+			block := trimBlank(lines[i:end])
+			switch {
+			case literal:
+				out = append(out, fenced("", dedent.Lines(block))...)
+			case list:
+				// The rest of a list item
+				for _, l := range block {
+					out = append(out, c.listLine(l))
+				}
+			default:
+				out = append(out, quote(c.convert(dedent.Lines(block)))...)
+			}
+			literal = false
+			i += len(block)
+			continue
+		}
+		list, literal = false, false
+
+		switch {
+		case i+2 < len(lines) && isAdornment(line) && strings.TrimSpace(lines[i+1]) != "" && isAdornment(lines[i+2]) && lines[i+2][0] == line[0]:
+			out = append(out, "", c.heading("over"+line[:1], lines[i+1]), "")
+			i += 3
+		case i+1 < len(lines) && !isAdornment(line) && isAdornment(lines[i+1]) && width(lines[i+1]) >= min(width(strings.TrimSpace(line)), 3):
+			out = append(out, "", c.heading(lines[i+1][:1], line), "")
+			i += 2
+		case isAdornment(line) && width(line) >= 4:
+			out = append(out, "", "---", "")
+			i++
+		case strings.HasPrefix(line, ".. ") || strings.TrimSpace(line) == "..":
+			end := blockEnd(lines, i+1, 1)
+			out = append(out, c.explicit(line, lines[i+1:end])...)
+			i = end
+		case gridBorderRegex.MatchString(line):
+			end := i + 1
+			for end < len(lines) && (strings.HasPrefix(lines[end], "|") || strings.HasPrefix(lines[end], "+")) {
+				end++
+			}
+			out = append(out, c.gridTable(lines[i:end])...)
+			i = end
+		case simpleBorderRegex.MatchString(line):
+			end := simpleTableEnd(lines, i)
+			out = append(out, c.simpleTable(lines[i:end])...)
+			i = end
+		case strings.HasPrefix(line, ">>>"):
+			end := i
+			for end < len(lines) && strings.TrimSpace(lines[end]) != "" {
+				end++
+			}
+			out = append(out, fenced("python", lines[i:end])...)
+			i = end
+		case listRegex.MatchString(line):
+			out = append(out, c.listLine(line))
+			list = true
+			i++
+		case fieldRegex.MatchString(line):
+			m := fieldRegex.FindStringSubmatch(line)
+			out = append(out, "- **"+c.inline(m[1])+":** "+c.inline(m[3]))
+			list = true
+			i++
+		case strings.HasPrefix(line, "| ") || line == "|":
+			// A line block, its lines kept apart
+			for ; i < len(lines) && (strings.HasPrefix(lines[i], "| ") || lines[i] == "|"); i++ {
+				out = append(out, c.inline(strings.TrimPrefix(strings.TrimPrefix(lines[i], "|"), " "))+"\\")
+			}
+			out[len(out)-1] = strings.TrimSuffix(out[len(out)-1], "\\")
+		default:
+			end := i
+			for end < len(lines) && strings.TrimSpace(lines[end]) != "" && indentation(lines[end]) == 0 {
+				end++
+			}
+			paragraph := lines[i:end]
+			if end == i+1 && end < len(lines) && indentation(lines[end]) > 0 && !strings.HasSuffix(line, "::") {
+				// The term of a definition, its definition below
+				out = append(out, "**"+c.inline(strings.TrimSpace(line))+"**\\")
+				defEnd := blockEnd(lines, end, 1)
+				out = append(out, c.convert(dedent.Lines(trimBlank(lines[end:defEnd])))...)
+				i = defEnd
+				continue
+			}
+			last := strings.TrimRight(paragraph[len(paragraph)-1], " ")
+			if strings.HasSuffix(last, "::") {
+				// A literal block follows
+				literal = true
+				switch trimmed := strings.TrimSpace(strings.TrimSuffix(last, "::")); {
+				case trimmed == "":
+					paragraph = paragraph[:len(paragraph)-1]
+				case strings.HasSuffix(last, " ::"):
+					paragraph = append(paragraph[:len(paragraph)-1:len(paragraph)-1], trimmed)
+				default:
+					paragraph = append(paragraph[:len(paragraph)-1:len(paragraph)-1], strings.TrimSuffix(last, ":"))
+				}
+			}
+			for _, l := range paragraph {
+				out = append(out, c.inline(l))
+			}
+			i = end
+		}
+	}
+	return out
+}
+
+// heading returns a section title as a markdown heading, its level given by
+// the order its style was first used in.
+func (c *converter) heading(style, title string) string {
+	level := 0
+	for level < len(c.styles) && c.styles[level] != style {
+		level++
+	}
+	if level == len(c.styles) {
+		c.styles = append(c.styles, style)
+	}
+	return strings.Repeat("#", min(level+1, 6)) + " " + c.inline(strings.TrimSpace(title))
+}
+
+// explicit returns an explicit markup block, the line opening it and the
+// lines indented under it, as markdown: directives, footnotes and citations.
+// Comments, targets and substitutions are left out.
+func (c *converter) explicit(line string, block []string) []string {
+	if m := footnoteRegex.FindStringSubmatch(line); m != nil && !strings.HasPrefix(m[1], "_") {
+		text := []string{c.inline(m[2])}
+		for _, l := range dedent.Lines(trimBlank(block)) {
+			text = append(text, "    "+c.inline(l))
+		}
+		text[0] = "[^" + c.footnoteLabel(m[1], true) + "]: " + text[0]
+		return append([]string{""}, text...)
+	}
+	m := directiveRegex.FindStringSubmatch(line)
+	if m == nil || substitutionRegex.MatchString(line) {
+		return nil
+	}
+	name, args := strings.ToLower(m[1]), strings.TrimSpace(m[2])
+
+	// Options come first, up to the content
+	options := map[string]string{}
+	block = dedent.Lines(block)
+	for len(block) > 0 {
+		o := optionRegex.FindStringSubmatch(strings.TrimSpace(block[0]))
+		if o == nil {
+			break
+		}
+		options[o[1]] = o[2]
+		block = block[1:]
+	}
+	content := trimBlank(block)
+
+	switch {
+	case name == "code-block" || name == "code" || name == "sourcecode":
+		return fenced(args, content)
+	case name == "math":
+		return fenced("latex", append(strings.Fields(args), content...))
+	case name == "image" || name == "figure":
+		out := []string{"", "![" + options["alt"] + "](" + args + ")", ""}
+		if name == "figure" && len(content) > 0 {
+			out = append(out, c.convert(content)...)
+		}
+		return out
+	case alerts[name] != "":
+		body := c.convert(content)
+		if args != "" && len(body) > 0 {
+			body = append([]string{c.inline(args), ""}, body...)
+		} else if args != "" {
+			body = []string{c.inline(args)}
+		}
+		return quote(append([]string{"[!" + alerts[name] + "]"}, body...))
+	case name == "admonition":
+		return quote(append([]string{"**" + c.inline(args) + "**", ""}, c.convert(content)...))
+	case versions[name] != "":
+		note := "*" + versions[name] + " " + args + ".*"
+		if len(content) > 0 {
+			note = "*" + versions[name] + " " + args + ":* " + strings.Join(c.convert(content), "\n")
+		}
+		return []string{"", note, ""}
+	case name == "toctree":
+		var out []string
+		for _, entry := range content {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				out = append(out, "- "+c.toctreeEntry(entry))
+			}
+		}
+		return append(append([]string{""}, out...), "")
+	case signatureDirectives.MatchString(name):
+		out := []string{""}
+		for _, signature := range append([]string{args}, content[:signatureEnd(content)]...) {
+			if signature = strings.TrimSpace(signature); signature != "" {
+				out = append(out, "**"+code(signature)+"**\\")
+			}
+		}
+		out[len(out)-1] = strings.TrimSuffix(out[len(out)-1], "\\")
+		return append(append(out, ""), c.convert(dedent.Lines(content[signatureEnd(content):]))...)
+	case name == "contents" || name == "highlight" || name == "index" || name == "meta" ||
+		name == "raw" || name == "include" || name == "literalinclude" || name == "only" ||
+		name == "sectionauthor" || name == "moduleauthor" || name == "currentmodule" || name == "default-role":
+		return nil
+	}
+	return append([]string{""}, c.convert(content)...)
+}
+
+// signatureEnd returns how many lines at the start of a signature directive's
+// content are further signatures, rather than its description.
+func signatureEnd(content []string) int {
+	for i, line := range content {
+		if strings.TrimSpace(line) == "" || indentation(line) > 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+// toctreeEntry returns an entry of a toctree as a link to its document.
+func (c *converter) toctreeEntry(entry string) string {
+	title, target := entry, entry
+	if m := embeddedRegex.FindStringSubmatch(entry); m != nil {
+		title, target = m[1], m[2]
+	}
+	if filepath.Ext(target) == "" {
+		target += Extension
+	}
+	return "[" + c.inline(title) + "](" + target + ")"
+}
+
+// listLine returns a line of a list as markdown, its bullet or number in
+// markdown's form.
+func (c *converter) listLine(line string) string {
+	m := listRegex.FindStringSubmatch(line)
+	if m == nil {
+		return c.inline(line)
+	}
+	marker := "-"
+	switch number := strings.Trim(m[2], "().#"); {
+	case strings.HasPrefix(m[2], "#"):
+		marker = "1."
+	case number != "" && number[0] >= '0' && number[0] <= '9':
+		marker = number + "."
+	}
+	return m[1] + marker + " " + c.inline(m[3])
+}
+
+// gridTable returns the lines of a grid table as a markdown table, the rows
+// above its = border the header.
+func (c *converter) gridTable(lines []string) []string {
+	var columns []int // offsets of the + of the top border
+	for i, r := range lines[0] {
+		if r == '+' {
+			columns = append(columns, i)
+		}
+	}
+	var rows [][]string
+	header := 0
+	var row []string
+	for _, line := range lines[1:] {
+		if gridBorderRegex.MatchString(line) {
+			if row != nil {
+				rows = append(rows, row)
+				row = nil
+			}
+			if strings.Contains(line, "=") {
+				header = len(rows)
+			}
+			continue
+		}
+		if row == nil {
+			row = make([]string, len(columns)-1)
+		}
+		for j := range row {
+			cell := strings.TrimSpace(slice(line, columns[j]+1, columns[j+1]))
+			row[j] = strings.TrimSpace(row[j] + " " + cell)
+		}
+	}
+	if row != nil {
+		rows = append(rows, row)
+	}
+	return c.table(rows, header)
+}
+
+// simpleTableEnd returns the index of the line after a simple table starting
+// at i: the one after its last border.
+func simpleTableEnd(lines []string, i int) int {
+	end, borders := i+1, 1
+	for j := i + 1; j < len(lines); j++ {
+		if simpleBorderRegex.MatchString(lines[j]) {
+			borders++
+			end = j + 1
+			if borders >= 2 && (j+1 == len(lines) || strings.TrimSpace(lines[j+1]) == "") {
+				if borders == 3 || j+1 == len(lines) || !hasLaterBorder(lines, j+1) {
+					return end
+				}
+			}
+		}
+	}
+	return end
+}
+
+// hasLaterBorder reports whether a simple table's border follows the blank
+// lines and rows after j, before any other blank line.
+func hasLaterBorder(lines []string, j int) bool {
+	for ; j < len(lines) && strings.TrimSpace(lines[j]) == ""; j++ {
+	}
+	for ; j < len(lines) && strings.TrimSpace(lines[j]) != ""; j++ {
+		if simpleBorderRegex.MatchString(lines[j]) {
+			return true
+		}
+	}
+	return false
+}
+
+// simpleTable returns the lines of a simple table as a markdown table, the
+// rows between its first two borders the header if it has three.
+func (c *converter) simpleTable(lines []string) []string {
+	var starts []int
+	border := lines[0]
+	for i := 0; i < len(border); i++ {
+		if border[i] == '=' && (i == 0 || border[i-1] == ' ') {
+			starts = append(starts, i)
+		}
+	}
+	var rows [][]string
+	header, borders := 0, 0
+	for _, line := range lines[1:] {
+		if simpleBorderRegex.MatchString(line) {
+			borders++
+			if borders == 1 {
+				header = len(rows)
+			}
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		row := make([]string, len(starts))
+		for j, start := range starts {
+			end := len(line)
+			if j+1 < len(starts) {
+				end = starts[j+1]
+			}
+			row[j] = strings.TrimSpace(slice(line, start, end))
+		}
+		if row[0] == "" && len(rows) > 0 {
+			// A line continuing the row above
+			for j := range row {
+				rows[len(rows)-1][j] = strings.TrimSpace(rows[len(rows)-1][j] + " " + row[j])
+			}
+			continue
+		}
+		rows = append(rows, row)
+	}
+	if borders < 2 {
+		header = 0
+	}
+	return c.table(rows, header)
+}
+
+// table returns rows as a markdown table, the first header of them joined
+// into its header, or the first row if none are.
+func (c *converter) table(rows [][]string, header int) []string {
+	if len(rows) == 0 {
+		return nil
+	}
+	if header > 1 {
+		joined := rows[0]
+		for _, row := range rows[1:header] {
+			for j := range joined {
+				joined[j] = strings.TrimSpace(joined[j] + " " + row[j])
+			}
+		}
+		rows = append([][]string{joined}, rows[header:]...)
+	}
+	line := func(row []string) string {
+		cells := make([]string, len(row))
+		for j, cell := range row {
+			cells[j] = strings.ReplaceAll(c.inline(cell), "|", `\|`)
+		}
+		return "| " + strings.Join(cells, " | ") + " |"
+	}
+	out := []string{"", line(rows[0]), "|" + strings.Repeat("---|", len(rows[0]))}
+	for _, row := range rows[1:] {
+		out = append(out, line(row))
+	}
+	return append(out, "")
+}
+
+// inline returns text with its inline markup as markdown: literals as code
+// spans, roles by their text, references as links and footnote references,
+// and substitutions replaced.
+func (c *converter) inline(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range literalRegex.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(c.markup(text[last:loc[0]]))
+		b.WriteString(code(text[loc[2]:loc[3]]))
+		last = loc[1]
+	}
+	b.WriteString(c.markup(text[last:]))
+	return b.String()
+}
+
+// markup returns text without literals with its roles, references and
+// substitutions as markdown.
+func (c *converter) markup(text string) string {
+	text = roleRegex.ReplaceAllStringFunc(text, func(s string) string {
+		m := roleRegex.FindStringSubmatch(s)
+		role, content := strings.ToLower(m[1]), m[2]
+		title, target := content, ""
+		if e := embeddedRegex.FindStringSubmatch(content); e != nil {
+			title, target = e[1], e[2]
+		}
+		switch {
+		case role == "strong":
+			return "**" + content + "**"
+		case role == "emphasis" || role == "dfn" || role == "title-reference" || role == "t":
+			return "*" + content + "*"
+		case codeRoles.MatchString(role):
+			if target == "" {
+				title = strings.TrimPrefix(title, "!")
+				if strings.HasPrefix(title, "~") {
+					title = title[strings.LastIndex(title, ".")+1:]
+				}
+			}
+			return code(title)
+		case role == "doc" && target != "":
+			return "[" + title + "](" + target + Extension + ")"
+		}
+		return title
+	})
+	text = referenceRegex.ReplaceAllStringFunc(text, func(s string) string {
+		m := referenceRegex.FindStringSubmatch(s)
+		switch {
+		case strings.HasPrefix(s, "`"):
+			title, target := m[1], c.targets[strings.ToLower(m[1])]
+			if e := embeddedRegex.FindStringSubmatch(m[1]); e != nil {
+				title, target = e[1], e[2]
+				if title == "" {
+					title = target
+				}
+			}
+			if target == "" {
+				return title
+			}
+			return "[" + title + "](" + target + ")"
+		case strings.HasPrefix(s, "["):
+			return "[^" + c.footnoteLabel(m[2], false) + "]"
+		}
+		if target := c.targets[strings.ToLower(m[3])]; target != "" {
+			return "[" + m[3] + "](" + target + ")" + m[4]
+		}
+		return m[3] + m[4]
+	})
+	for name, value := range c.substitutions {
+		text = strings.ReplaceAll(text, "|"+name+"|", value)
+	}
+	return text
+}
+
+// footnoteLabel returns the label of a footnote in markdown. Footnotes
+// numbered automatically, [#] and [*], are numbered in the order they're
+// defined and referenced, each reference and definition counted once.
+func (c *converter) footnoteLabel(label string, definition bool) string {
+	switch label {
+	case "#", "*":
+		// Kept apart from footnotes labeled with numbers
+		if !definition {
+			c.footnotes++
+			return fmt.Sprint("auto-", c.footnotes)
+		}
+		c.definitions++
+		return fmt.Sprint("auto-", c.definitions)
+	}
+	return strings.TrimPrefix(label, "#")
+}
+
+// fenced returns lines as a fenced code block of a language.
+func fenced(language string, lines []string) []string {
+	fence := "```"
+	for _, line := range lines {
+		for strings.Contains(line, fence) {
+			fence += "`"
+		}
+	}
+	if fields := strings.Fields(language); len(fields) > 0 {
+		language = strings.ToLower(fields[0])
+	}
+	out := append([]string{"", fence + language}, lines...)
+	return append(out, fence, "")
+}
+
+// quote returns lines as a blockquote.
+func quote(lines []string) []string {
+	out := []string{""}
+	for _, line := range lines {
+		out = append(out, strings.TrimRight("> "+line, " "))
+	}
+	return append(out, "")
+}
+
+// code returns text as a code span.
+func code(text string) string {
+	fence := "`"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		return fence + " " + text + " " + fence
+	}
+	return fence + text + fence
+}
+
+// isAdornment reports whether a line is a section title's underline or
+// overline, or a transition: one punctuation character repeated.
+func isAdornment(line string) bool {
+	line = strings.TrimRight(line, " ")
+	if len(line) < 2 || !strings.ContainsRune(adornments, rune(line[0])) {
+		return false
+	}
+	return strings.Count(line, line[:1]) == len(line)
+}
+
+// blockEnd returns the index of the line ending the block from i of lines
+// indented at least indent columns, blank lines in it included.
+func blockEnd(lines []string, i, indent int) int {
+	for ; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != "" && indentation(lines[i]) < indent {
+			break
+		}
+	}
+	return i
+}
+
+// trimBlank returns lines without the blank lines at their end.
+func trimBlank(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	return lines
+}
+
+// indentation returns how many spaces a line starts with.
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// width returns how many characters a line is.
+func width(line string) int {
+	return utf8.RuneCountInString(strings.TrimRight(line, " "))
+}
+
+// slice returns the bytes of a line from start to end, as far as the line
+// goes.
+func slice(line string, start, end int) string {
+	if start >= len(line) {
+		return ""
+	}
+	return line[start:min(end, len(line))]
+}
@@ -0,0 +1,77 @@
+package rst
+
+import "testing"
+
+func TestToMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "sections",
+			in:   "=====\nTitle\n=====\n\nOne\n===\n\nTwo\n---\n\nThree\n===\n\n----\n\nEnd.\n",
+			want: "# Title\n\n## One\n\n### Two\n\n## Three\n\n---\n\nEnd.\n",
+		},
+		{
+			name: "literal and code blocks",
+			in:   "Run this::\n\n    pip install x\n\nAlone:\n\n::\n\n    raw\n\n.. code-block:: Python\n   :linenos:\n\n   print(\"hi\")\n\n>>> 1 + 1\n2\n",
+			want: "Run this:\n\n```\npip install x\n```\n\nAlone:\n\n```\nraw\n```\n\n```python\nprint(\"hi\")\n```\n\n```python\n>>> 1 + 1\n2\n```\n",
+		},
+		{
+			name: "admonitions",
+			in:   ".. note::\n\n   A *note*\n   over lines.\n\n.. danger:: Hot.\n\n.. admonition:: Aside\n\n   Said.\n",
+			want: "> [!NOTE]\n> A *note*\n> over lines.\n\n> [!CAUTION]\n> Hot.\n\n> **Aside**\n>\n> Said.\n",
+		},
+		{
+			name: "lists and fields",
+			in:   "- one\n- two\n  more\n\n#. first\n#. second\n\n3) third\n\n:Author: Me\n:Version: ``1.0``\n\nterm\n    its definition\n",
+			want: "- one\n- two\n  more\n\n1. first\n1. second\n\n3. third\n\n- **Author:** Me\n- **Version:** `1.0`\n\n**term**\\\nits definition\n",
+		},
+		{
+			name: "quotes and line blocks",
+			in:   "Said:\n\n    Quoted text.\n\n| one\n| two\n",
+			want: "Said:\n\n> Quoted text.\n\none\\\ntwo\n",
+		},
+		{
+			name: "inline markup and references",
+			in:   "A ``lit``, :func:`~pkg.run`, :ref:`intro`, :doc:`Guide <guide>`, `site <https://x.dev>`_, Python_ and |v|.\n\n.. _Python: https://python.org\n.. |v| replace:: 1.0\n.. a comment\n",
+			want: "A `lit`, `run`, intro, [Guide](guide.rst), [site](https://x.dev), [Python](https://python.org) and 1.0.\n",
+		},
+		{
+			name: "footnotes",
+			in:   "Noted [#]_ and [1]_.\n\n.. [#] Automatic.\n.. [1] Numbered\n   on lines.\n",
+			want: "Noted [^auto-1] and [^1].\n\n[^auto-1]: Automatic.\n\n[^1]: Numbered\n    on lines.\n",
+		},
+		{
+			name: "grid table",
+			in:   "+-----+-------+\n| A   | B     |\n+=====+=======+\n| 1   | two   |\n|     | lines |\n+-----+-------+\n",
+			want: "| A | B |\n|---|---|\n| 1 | two lines |\n",
+		},
+		{
+			name: "simple table",
+			in:   "=====  =====\nCol1   Col2\n=====  =====\nx      a|b\ny      z\n=====  =====\n",
+			want: "| Col1 | Col2 |\n|---|---|\n| x | a\\|b |\n| y | z |\n",
+		},
+		{
+			name: "directives",
+			in:   ".. image:: logo.png\n   :alt: Logo\n\n.. versionadded:: 2.0\n\n.. py:function:: run(x)\n\n   Runs *x*.\n\n.. toctree::\n   :maxdepth: 2\n\n   intro\n   Usage <usage>\n\n.. contents::\n",
+			want: "![Logo](logo.png)\n\n*New in version 2.0.*\n\n**`run(x)`**\n\nRuns *x*.\n\n- [intro](intro.rst)\n- [Usage](usage.rst)\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ToMarkdown(tt.in); got != tt.want {
+				t.Errorf("ToMarkdown() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsRSTFile(t *testing.T) {
+	for name, want := range map[string]bool{"index.rst": true, "README.RST": true, "notes.rest": true, "notes.md": false, "rst": false} {
+		if got := IsRSTFile(name); got != want {
+			t.Errorf("IsRSTFile(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
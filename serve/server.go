@@ -0,0 +1,323 @@
+// Package serve previews markdown documents in a browser, rendered as they're
+// exported, and reloads them as they change.
+package serve
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/fsnotify/fsnotify"
+	"github.com/hholst80/glow/export"
+	"github.com/hholst80/glow/utils"
+	"golang.org/x/net/websocket"
+)
+
+// reloadPath is where pages connect to be told to reload.
+const reloadPath = "/_glow/reload"
+
+// debounce is how long changes to a file are gathered before pages reload,
+// since editors often write a file more than once as they save it.
+const debounce = 100 * time.Millisecond
+
+// reloadScript reloads a page when its document, or a file in its directory
+// listing, changes.
+const reloadScript = `(function() {
+  var scheme = location.protocol === "https:" ? "wss://" : "ws://";
+  var ws = new WebSocket(scheme + location.host + "` + reloadPath + `");
+  ws.onmessage = function(e) {
+    var page = location.pathname;
+    if (e.data === page || (page.endsWith("/") && e.data.startsWith(page))) {
+      location.reload();
+    }
+  };
+})();`
+
+// ignoredDirs are directories that are neither listed nor watched.
+var ignoredDirs = []string{"node_modules"}
+
+// Options control how documents are served.
+type Options struct {
+	// Glamour style name or JSON path the pages take their colors from
+	Style string
+}
+
+// Server serves the markdown files of a directory as HTML pages, and the
+// other files as they are.
+type Server struct {
+	root    string
+	opts    Options
+	watcher *fsnotify.Watcher
+
+	mu      sync.Mutex
+	clients map[chan string]struct{}
+	pending map[string]bool // changed files waiting for the debounce
+}
+
+// New returns a server of the files in root, watching them for changes.
+// Symlinks are followed only to files under root.
+func New(root string, opts Options) (*Server, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(root); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("unable to watch files: %w", err)
+	}
+
+	s := &Server{
+		root:    root,
+		opts:    opts,
+		watcher: watcher,
+		clients: make(map[chan string]struct{}),
+		pending: make(map[string]bool),
+	}
+	s.watchTree(root)
+	go s.watch()
+	return s, nil
+}
+
+// Close stops watching the files.
+func (s *Server) Close() error {
+	return s.watcher.Close()
+}
+
+// ignored reports whether a file or directory isn't served: hidden ones,
+// such as .git, and ignoredDirs.
+func ignored(name string) bool {
+	return strings.HasPrefix(name, ".") || slices.Contains(ignoredDirs, name)
+}
+
+// watchTree watches a directory and the directories below it.
+func (s *Server) watchTree(dir string) {
+	_ = filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil //nolint:nilerr
+		}
+		if p != dir && ignored(d.Name()) {
+			return filepath.SkipDir
+		}
+		if err := s.watcher.Add(p); err != nil {
+			log.Debug("unable to watch directory", "dir", p, "error", err)
+		}
+		return nil
+	})
+}
+
+// watch tells pages about the markdown files that change, and watches new
+// directories.
+func (s *Server) watch() {
+	for {
+		select {
+		case event, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+			log.Debug("fsnotify event", "file", event.Name, "event", event.Op)
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() && !ignored(info.Name()) {
+					s.watchTree(event.Name)
+					continue
+				}
+			}
+			if event.Has(fsnotify.Chmod) || !isMarkdown(event.Name) {
+				continue
+			}
+			s.changed(event.Name)
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Debug("fsnotify error", "error", err)
+		}
+	}
+}
+
+// changed reloads the pages of a file once it settles.
+func (s *Server) changed(file string) {
+	rel, err := filepath.Rel(s.root, file)
+	if err != nil {
+		return
+	}
+	page := (&url.URL{Path: "/" + filepath.ToSlash(rel)}).EscapedPath()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending[page] {
+		return
+	}
+	s.pending[page] = true
+	time.AfterFunc(debounce, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.pending, page)
+		for c := range s.clients {
+			select {
+			case c <- page:
+			default: // the page is reloading already
+			}
+		}
+	})
+}
+
+// ServeHTTP serves a markdown file as a page, a directory as a listing of
+// its markdown files, and other files as they are.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == reloadPath {
+		websocket.Handler(s.reload).ServeHTTP(w, r)
+		return
+	}
+
+	name := path.Clean("/" + r.URL.Path)
+	if slices.ContainsFunc(strings.Split(name, "/"), ignored) {
+		http.NotFound(w, r)
+		return
+	}
+	file, err := filepath.EvalSymlinks(filepath.Join(s.root, filepath.FromSlash(name)))
+	if err != nil || !s.contains(file) {
+		http.NotFound(w, r)
+		return
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case info.IsDir():
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+			return
+		}
+		s.serveListing(w, r, file, name)
+	case isMarkdown(file):
+		s.serveDocument(w, r, file)
+	default:
+		http.ServeFile(w, r, file)
+	}
+}
+
+// contains reports whether file is the root or under it.
+func (s *Server) contains(file string) bool {
+	rel, err := filepath.Rel(s.root, file)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// serveDocument serves a markdown file as a page.
+func (s *Server) serveDocument(w http.ResponseWriter, r *http.Request, file string) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.servePage(w, r, b, "")
+}
+
+// serveListing serves a directory's subdirectories and markdown files as a
+// page.
+func (s *Server) serveListing(w http.ResponseWriter, r *http.Request, dir, name string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var md bytes.Buffer
+	fmt.Fprintf(&md, "# %s\n\n", escapeMarkdown(name))
+	if name != "/" {
+		md.WriteString("- [../](../)\n")
+	}
+	for _, e := range entries {
+		if ignored(e.Name()) || (!e.IsDir() && !isMarkdown(e.Name())) {
+			continue
+		}
+		label := e.Name()
+		if e.IsDir() {
+			label += "/"
+		}
+		link := (&url.URL{Path: label}).EscapedPath()
+		fmt.Fprintf(&md, "- [%s](%s)\n", escapeMarkdown(label), link)
+	}
+	s.servePage(w, r, md.Bytes(), name)
+}
+
+// servePage serves markdown as an exported page that reloads as it changes.
+// Its diagrams stop rendering once the request is cancelled.
+func (s *Server) servePage(w http.ResponseWriter, r *http.Request, markdown []byte, title string) {
+	var page bytes.Buffer
+	// Only files under the root are served, so their raw HTML is trusted
+	opts := export.Options{Style: s.opts.Style, Title: title, Script: reloadScript, Unsafe: true}
+	if err := export.HTML(r.Context(), &page, markdown, opts); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(page.Bytes())
+}
+
+// reload tells a page which files change, until it goes away.
+func (s *Server) reload(ws *websocket.Conn) {
+	c := make(chan string, 1)
+	s.mu.Lock()
+	s.clients[c] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c)
+		s.mu.Unlock()
+	}()
+
+	gone := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(io.Discard, ws)
+		close(gone)
+	}()
+	for {
+		select {
+		case page := <-c:
+			if err := websocket.Message.Send(ws, page); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		}
+	}
+}
+
+// isMarkdown reports whether a file has a markdown extension.
+func isMarkdown(file string) bool {
+	return filepath.Ext(file) != "" && utils.IsMarkdownFile(file)
+}
+
+// escapeMarkdown escapes the characters of a name that markdown would take
+// as formatting.
+func escapeMarkdown(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("\\`*_{}[]()#+-.!<>|", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
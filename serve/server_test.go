@@ -0,0 +1,170 @@
+package serve
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"README.md":      "# Home\n\nWelcome.\n",
+		"docs/guide.md":  "# Guide\n\n![logo](logo.png)\n",
+		"docs/logo.png":  "png",
+		"docs/notes.txt": "notes",
+		".git/config":    "secret",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := New(dir, Options{Style: "dark"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s)
+	t.Cleanup(func() {
+		ts.Close()
+		_ = s.Close()
+	})
+	return ts, dir
+}
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	res, err := http.Get(url) //nolint:gosec,noctx
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close() //nolint:errcheck
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode, string(b)
+}
+
+func TestServeHTTP(t *testing.T) {
+	ts, _ := newTestServer(t)
+
+	for _, tt := range []struct {
+		path     string
+		status   int
+		contains []string
+		excludes []string
+	}{
+		{"/README.md", http.StatusOK, []string{`<h1 id="home">Home</h1>`, reloadPath, "background: #1e1e1e"}, nil},
+		{"/", http.StatusOK, []string{`<a href="README.md">README.md</a>`, `<a href="docs/">docs/</a>`}, []string{".git", "../"}},
+		{"/docs/", http.StatusOK, []string{`<a href="guide.md">guide.md</a>`, `<a href="../">../</a>`}, []string{"notes.txt", "logo.png"}},
+		{"/docs/logo.png", http.StatusOK, []string{"png"}, nil},
+		{"/.git/config", http.StatusNotFound, nil, []string{"secret"}},
+		{"/missing.md", http.StatusNotFound, nil, nil},
+	} {
+		status, body := get(t, ts.URL+tt.path)
+		if status != tt.status {
+			t.Errorf("GET %s = %d, want %d", tt.path, status, tt.status)
+		}
+		for _, s := range tt.contains {
+			if !strings.Contains(body, s) {
+				t.Errorf("GET %s is missing %q:\n%s", tt.path, s, body)
+			}
+		}
+		for _, s := range tt.excludes {
+			if strings.Contains(body, s) {
+				t.Errorf("GET %s has %q:\n%s", tt.path, s, body)
+			}
+		}
+	}
+
+	// Directories are redirected to their listing
+	status, body := get(t, ts.URL+"/docs")
+	if status != http.StatusOK || !strings.Contains(body, "guide.md") {
+		t.Errorf("GET /docs = %d:\n%s", status, body)
+	}
+}
+
+func TestServeHTTP_Symlinks(t *testing.T) {
+	ts, dir := newTestServer(t)
+
+	outside := filepath.Join(t.TempDir(), "secret.md")
+	if err := os.WriteFile(outside, []byte("# Secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{
+		"inside.md":  filepath.Join(dir, "README.md"),
+		"outside.md": outside,
+		"up":         filepath.Dir(outside),
+	} {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Skipf("unable to create symlinks: %v", err)
+		}
+	}
+
+	// Links are followed to files under the root only
+	for path, want := range map[string]int{
+		"/inside.md":     http.StatusOK,
+		"/outside.md":    http.StatusNotFound,
+		"/up/secret.md":  http.StatusNotFound,
+		"/up/":           http.StatusNotFound,
+		"/docs/guide.md": http.StatusOK,
+	} {
+		status, body := get(t, ts.URL+path)
+		if status != want {
+			t.Errorf("GET %s = %d, want %d", path, status, want)
+		}
+		if strings.Contains(body, "Secret") {
+			t.Errorf("GET %s has the file outside the root:\n%s", path, body)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	ts, dir := newTestServer(t)
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + reloadPath
+	ws, err := websocket.Dial(wsURL, "", ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close() //nolint:errcheck
+
+	// A new directory is watched, and a file written in it several times
+	// reloads its page once
+	if err := os.Mkdir(filepath.Join(dir, "new dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	file := filepath.Join(dir, "new dir", "page.md")
+	for range 3 {
+		if err := os.WriteFile(file, []byte("# Page\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var page string
+	_ = ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := websocket.Message.Receive(ws, &page); err != nil {
+		t.Fatal(err)
+	}
+	if page != "/new%20dir/page.md" {
+		t.Errorf("reloaded page is %q, want %q", page, "/new%20dir/page.md")
+	}
+	_ = ws.SetReadDeadline(time.Now().Add(3 * debounce))
+	if err := websocket.Message.Receive(ws, &page); err == nil {
+		t.Errorf("page reloaded again for %q", page)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/hholst80/glow/serve"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	serveAddr  string
+	serveStyle string
+
+	serveCmd = &cobra.Command{
+		Use:     "serve [DIR]",
+		Short:   "Preview the markdown files of a directory in a browser",
+		Long:    paragraph(fmt.Sprintf("\n%s the markdown files of a directory, rendered as HTML, with listings of its directories. Open pages reload as their files change.", keyword("Serve"))),
+		Example: paragraph("glow serve\nglow serve docs --addr localhost:8000 --style dark"),
+		Args:    cobra.MaximumNArgs(1),
+		RunE:    serveFiles,
+	}
+)
+
+func serveFiles(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	style := serveStyle
+	if !cmd.Flags().Changed("style") {
+		style = viper.GetString("style")
+	}
+
+	s, err := serve.New(dir, serve.Options{Style: style})
+	if err != nil {
+		return fmt.Errorf("unable to serve %s: %w", dir, err)
+	}
+	defer s.Close() //nolint:errcheck
+
+	l, err := net.Listen("tcp", serveAddr)
+	if err != nil {
+		return fmt.Errorf("unable to listen: %w", err)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Serving %s at http://%s/\n", dir, l.Addr())
+
+	srv := &http.Server{Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	return srv.Serve(l) //nolint:wrapcheck
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "localhost:6419", "address to listen on")
+	serveCmd.Flags().StringVarP(&serveStyle, "style", "s", "", "style name or JSON path (defaults to the configured style)")
+}
@@ -0,0 +1,162 @@
+package sourcemap
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/glamour/ansi"
+	"github.com/yuin/goldmark"
+	emoji "github.com/yuin/goldmark-emoji"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Render renders markdown as glamour does with the given options, and with
+// emoji shortcodes replaced if emoji is set. Marks are taken out of the
+// markdown before it's parsed, so they can't change what it's parsed into,
+// such as what's emphasized or linked, and are put back in front of the
+// text of the headings and paragraphs that start on their lines, for
+// Extract to read from the output. Marks on other lines are dropped.
+func Render(markdown string, options ansi.Options, emojis bool) (string, error) {
+	return Parse(markdown, emojis).Render(options)
+}
+
+// Parsed is markdown parsed as Render parses it, with its marks taken out,
+// for its structure to be read before it's rendered.
+type Parsed struct {
+	Doc    ast.Node
+	Source []byte
+
+	md goldmark.Markdown
+
+	// line numbers the marked lines of Source were marked with, by their
+	// index, and the indexes in order
+	marks  map[int]int
+	marked []int
+}
+
+// Parse parses markdown as Render does, with emoji shortcodes replaced if
+// emoji is set.
+func Parse(markdown string, emojis bool) *Parsed {
+	md := goldmark.New(
+		goldmark.WithExtensions(
+			extension.GFM,
+			extension.DefinitionList,
+		),
+		goldmark.WithParserOptions(
+			parser.WithAutoHeadingID(),
+		),
+	)
+	if emojis {
+		emoji.New().Extend(md)
+	}
+
+	stripped, marks := strip(markdown)
+	p := &Parsed{Source: []byte(stripped), md: md, marks: marks}
+	p.Doc = md.Parser().Parse(text.NewReader(p.Source))
+	for i := range marks {
+		p.marked = append(p.marked, i)
+	}
+	sort.Ints(p.marked)
+	return p
+}
+
+// Line returns the line of the document a line of Source is, both counted
+// from 1: the line the nearest marked line before it was marked with,
+// offset by how far it is from it. The lines of markdown that wasn't marked
+// are their own.
+func (p *Parsed) Line(n int) int {
+	if len(p.marked) == 0 {
+		return n
+	}
+	i := max(sort.SearchInts(p.marked, n)-1, 0)
+	return max(p.marks[p.marked[i]]+n-1-p.marked[i], 0) + 1
+}
+
+// Render renders the document as Render does with options. The marks are
+// put into the document, so it's rendered once, after it's been read.
+func (p *Parsed) Render(options ansi.Options) (string, error) {
+	p.md.SetRenderer(
+		renderer.NewRenderer(
+			renderer.WithNodeRenderers(
+				// glamour's priority, over goldmark's HTML renderer
+				util.Prioritized(ansi.NewRenderer(options), 1000),
+			),
+		),
+	)
+
+	source := p.Source
+	if len(p.marks) > 0 {
+		source = annotate(p.Doc, source, maps.Clone(p.marks))
+	}
+
+	var buf bytes.Buffer
+	if err := p.md.Renderer().Render(&buf, source, p.Doc); err != nil {
+		return "", fmt.Errorf("error rendering markdown: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// strip removes the marks from markdown, returning it and the line numbers
+// its marked lines were marked with, by their index.
+func strip(markdown string) (string, map[int]int) {
+	if !Marked(markdown) {
+		return markdown, nil
+	}
+	marks := map[int]int{}
+	lines := strings.Split(markdown, "\n")
+	for i, line := range lines {
+		var numbers []int
+		if lines[i], numbers = unmark(line); len(numbers) > 0 {
+			marks[i] = numbers[0]
+		}
+	}
+	return strings.Join(lines, "\n"), marks
+}
+
+// annotate puts the marks of the lines of source in front of the text of
+// the headings and paragraphs of doc that start on them, returning source
+// with the text of the marks added to its end.
+func annotate(doc ast.Node, source []byte, marks map[int]int) []byte {
+	starts := []int{0}
+	for i, b := range source {
+		if b == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n.Kind() {
+		case ast.KindHeading, ast.KindParagraph, ast.KindTextBlock:
+		default:
+			return ast.WalkContinue, nil
+		}
+		if n.Lines().Len() == 0 {
+			return ast.WalkContinue, nil
+		}
+
+		start := n.Lines().At(0).Start
+		line := sort.Search(len(starts), func(i int) bool { return starts[i] > start }) - 1
+		number, ok := marks[line]
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+		delete(marks, line)
+
+		m := mark(number)
+		n.InsertBefore(n, n.FirstChild(), ast.NewTextSegment(text.NewSegment(len(source), len(source)+len(m))))
+		source = append(source, m...)
+		return ast.WalkContinue, nil
+	})
+	return source
+}
@@ -0,0 +1,284 @@
+// Package sourcemap tracks where the lines of a markdown document end up
+// once it's rendered, so a heading, a search hit or the top of the view can
+// be found in the rendered document exactly, rather than by searching its
+// text or guessing from proportions. Lines are marked with invisible
+// characters that take no room, which Render keeps out of what's parsed and
+// puts back into the headings and paragraphs of their lines, and the marks
+// are read back from the rendered document.
+package sourcemap
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Marks are zero-width characters: a byte order mark around the line's
+// number, written in base 3 with zero-width spaces and joiners.
+const (
+	delimiter = '\uFEFF'
+	digits    = "\u200B\u200C\u200D"
+)
+
+var (
+	atxHeadingRegex    = regexp.MustCompile(`^ {0,3}#{1,6}[ \t]+\S`)
+	listItemRegex      = regexp.MustCompile(`^\d{1,9}[.)](\s|$)`)
+	thematicRegex      = regexp.MustCompile(`^(?:[*_-][ \t]*){3,}$`)
+	headingPrefixRegex = regexp.MustCompile(`^ {0,3}#{1,6}[ \t]+`)
+)
+
+// Line is a line of a document's markdown and the line it was rendered on.
+type Line struct {
+	Source   int
+	Rendered int
+}
+
+// Map holds the rendered lines of the marked lines of a document, in order.
+type Map []Line
+
+// Mark marks the headings and the first lines of the paragraphs of markdown,
+// outside code blocks, with their line numbers, counting from first. Lines
+// whose marks could change what they are, such as the rows of tables and
+// link definitions, aren't marked.
+func Mark(markdown string, first int) string {
+	lines := strings.Split(markdown, "\n")
+	fence := ""
+	start := true // whether a paragraph may start on the line
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if f := fenceOf(trimmed); fence == "" && f != "" && indent(line) < 4 {
+			fence = f
+			continue
+		} else if fence != "" {
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+				start = true
+			}
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			start = true
+			continue
+		case atxHeadingRegex.MatchString(line):
+			n := len(headingPrefixRegex.FindString(line))
+			lines[i] = line[:n] + mark(first+i) + line[n:]
+			start = true
+			continue
+		case start && indent(line) < 4 && paragraphStart(trimmed):
+			n := len(line) - len(strings.TrimLeft(line, " \t"))
+			lines[i] = line[:n] + mark(first+i) + line[n:]
+		}
+		start = false
+	}
+	return strings.Join(lines, "\n")
+}
+
+// MarkAs marks text that starts a paragraph added to marked markdown, such
+// as the caption of a diagram, with the line number of what it belongs to.
+func MarkAs(text string, line int) string {
+	return mark(line) + text
+}
+
+// LineAt returns the line of the document the line of marked markdown at
+// offset is: the line the nearest marked line before it was marked with,
+// offset by how far it is from it. It's false if no line before it is
+// marked.
+func LineAt(markdown string, offset int) (int, bool) {
+	if !Marked(markdown[:offset]) {
+		return 0, false
+	}
+	lines := strings.Split(markdown[:offset], "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if _, numbers := unmark(lines[i]); len(numbers) > 0 {
+			return numbers[0] + len(lines) - 1 - i, true
+		}
+	}
+	return 0, false
+}
+
+// Marked reports whether markdown has been marked.
+func Marked(markdown string) bool {
+	return strings.ContainsRune(markdown, delimiter)
+}
+
+// Extract removes the marks from a rendered document, returning it and
+// where its marked lines were rendered.
+func Extract(rendered string) (string, Map) {
+	if !Marked(rendered) {
+		return rendered, nil
+	}
+	var m Map
+	lines := strings.Split(rendered, "\n")
+	for i, line := range lines {
+		var numbers []int
+		lines[i], numbers = unmark(line)
+		for _, n := range numbers {
+			m = append(m, Line{Source: n, Rendered: i})
+		}
+	}
+	sort.SliceStable(m, func(i, j int) bool { return m[i].Source < m[j].Source })
+	return strings.Join(lines, "\n"), m
+}
+
+// Rendered returns the line the source line was rendered on, and whether it
+// was marked. Lines that weren't are placed on the line of the last marked
+// line before them; -1 if there's none.
+func (m Map) Rendered(source int) (int, bool) {
+	i := sort.Search(len(m), func(i int) bool { return m[i].Source > source })
+	if i == 0 {
+		return -1, false
+	}
+	return m[i-1].Rendered, m[i-1].Source == source
+}
+
+// Source returns the last marked source line rendered on or before the
+// rendered line, or -1 if there's none.
+func (m Map) Source(rendered int) int {
+	source := -1
+	for _, l := range m {
+		if l.Rendered <= rendered {
+			source = max(source, l.Source)
+		}
+	}
+	return source
+}
+
+// Fences returns the lines of the map whose source lines open code blocks of
+// markdown. Mark never marks those, so they're the marks of MarkAs, such as
+// those of the captions of diagrams.
+func (m Map) Fences(markdown string) Map {
+	lines := strings.Split(markdown, "\n")
+	var out Map
+	for _, l := range m {
+		if l.Source >= 0 && l.Source < len(lines) && fenceOf(strings.TrimSpace(lines[l.Source])) != "" {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// Offset returns the map with its rendered lines moved down by n, as when
+// the document it maps is shown below another.
+func (m Map) Offset(n int) Map {
+	out := make(Map, len(m))
+	for i, l := range m {
+		out[i] = Line{Source: l.Source, Rendered: l.Rendered + n}
+	}
+	return out
+}
+
+// unmark removes the marks from a line, returning it and the line numbers
+// they held.
+func unmark(line string) (string, []int) {
+	if !strings.ContainsRune(line, delimiter) {
+		return line, nil
+	}
+	var (
+		b       strings.Builder
+		numbers []int
+	)
+	for {
+		start := strings.IndexRune(line, delimiter)
+		if start < 0 {
+			break
+		}
+		n, size, ok := number(line[start+utf8.RuneLen(delimiter):])
+		if !ok {
+			// A stray byte order mark
+			b.WriteString(line[:start+utf8.RuneLen(delimiter)])
+			line = line[start+utf8.RuneLen(delimiter):]
+			continue
+		}
+		b.WriteString(line[:start])
+		line = line[start+utf8.RuneLen(delimiter)+size:]
+		numbers = append(numbers, n)
+	}
+	b.WriteString(line)
+	return b.String(), numbers
+}
+
+// mark returns the mark of a line.
+func mark(line int) string {
+	var b strings.Builder
+	b.WriteRune(delimiter)
+	if line == 0 {
+		b.WriteString(digits[:3])
+	}
+	var ds []string
+	for n := line; n > 0; n /= 3 {
+		ds = append(ds, digits[n%3*3:n%3*3+3])
+	}
+	for i := len(ds) - 1; i >= 0; i-- {
+		b.WriteString(ds[i])
+	}
+	b.WriteRune(delimiter)
+	return b.String()
+}
+
+// number reads the line number of a mark, after its opening delimiter,
+// returning it and the length of the rest of the mark.
+func number(s string) (int, int, bool) {
+	n, size := 0, 0
+	for size < len(s) {
+		r, w := utf8.DecodeRuneInString(s[size:])
+		if r == delimiter {
+			return n, size + w, size > 0
+		}
+		d := strings.IndexRune(digits, r)
+		if d < 0 {
+			return 0, 0, false
+		}
+		n = n*3 + d/3
+		size += w
+	}
+	return 0, 0, false
+}
+
+// fenceOf returns the fence a line opens a code block with, or "".
+func fenceOf(trimmed string) string {
+	for _, c := range []string{"`", "~"} {
+		if strings.HasPrefix(trimmed, c+c+c) {
+			n := len(trimmed) - len(strings.TrimLeft(trimmed, c))
+			return strings.Repeat(c, n)
+		}
+	}
+	return ""
+}
+
+// indent returns the width of a line's indentation, tabs counting as 4.
+func indent(line string) int {
+	n := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			n++
+		case '\t':
+			n += 4
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// paragraphStart reports whether a line, trimmed, starts a paragraph that
+// a mark in front of doesn't change: text, rather than the syntax of a
+// block such as a list, a table or a link definition.
+func paragraphStart(trimmed string) bool {
+	if strings.Contains(trimmed, "|") || thematicRegex.MatchString(trimmed) || listItemRegex.MatchString(trimmed) {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(trimmed)
+	switch {
+	case unicode.IsLetter(r) || unicode.IsDigit(r):
+		return true
+	case r == '*' || r == '_':
+		// Emphasis, rather than a list item
+		return len(trimmed) > 1 && trimmed[1] != ' ' && trimmed[1] != '\t'
+	}
+	return strings.ContainsRune(`"'(:`, r)
+}
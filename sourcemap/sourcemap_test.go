@@ -0,0 +1,171 @@
+package sourcemap
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/ansi"
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/muesli/termenv"
+)
+
+func TestMark(t *testing.T) {
+	input := strings.Join([]string{
+		"# Title",   // 0
+		"",          // 1
+		"Some text", // 2
+		"wrapped.",  // 3
+		"",          // 4
+		"- item",    // 5
+		"",          // 6
+		"```",       // 7
+		"# not a heading",
+		"",
+		"code",
+		"```",         // 11
+		"",            // 12
+		"a | b",       // 13
+		"--|--",       // 14
+		"",            // 15
+		"[ref]: /url", // 16
+		"",            // 17
+		"**Bold** start.",
+	}, "\n")
+
+	var marked []int
+	for i, line := range strings.Split(Mark(input, 10), "\n") {
+		if Marked(line) {
+			marked = append(marked, i)
+		}
+	}
+	if want := []int{0, 2, 18}; !reflect.DeepEqual(marked, want) {
+		t.Errorf("expected lines %v marked, got %v", want, marked)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	rendered := strings.Join([]string{
+		"",
+		"  \x1b[1m# " + mark(0) + "Title\x1b[0m",
+		"",
+		"  " + mark(12) + "Some text",
+		"  wrapped.",
+		"  " + mark(345) + "More " + mark(346) + "text",
+		"  \uFEFFstray",
+	}, "\n")
+
+	out, m := Extract(rendered)
+	if Marked(strings.ReplaceAll(out, "\uFEFFstray", "")) {
+		t.Errorf("expected the marks removed, got %q", out)
+	}
+	if !strings.Contains(out, "\uFEFFstray") {
+		t.Error("expected a stray byte order mark kept")
+	}
+	want := Map{{0, 1}, {12, 3}, {345, 5}, {346, 5}}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("expected %v, got %v", want, m)
+	}
+
+	for _, tt := range []struct {
+		source, rendered int
+		exact            bool
+	}{
+		{0, 1, true},
+		{12, 3, true},
+		{13, 3, false},
+		{400, 5, false},
+	} {
+		if got, exact := m.Rendered(tt.source); got != tt.rendered || exact != tt.exact {
+			t.Errorf("Rendered(%d): expected %d, %v, got %d, %v", tt.source, tt.rendered, tt.exact, got, exact)
+		}
+	}
+	if got, _ := (Map{{5, 2}}).Rendered(4); got != -1 {
+		t.Errorf("expected -1 before the first mark, got %d", got)
+	}
+	if got := m.Source(4); got != 12 {
+		t.Errorf("expected source line 12 at rendered line 4, got %d", got)
+	}
+	if got := m.Offset(10)[1]; got != (Line{12, 13}) {
+		t.Errorf("expected the offset map to move rendered lines, got %v", got)
+	}
+}
+
+// TestMark_RoundTrip tests that marking markdown and extracting the marks
+// gives back the same text.
+func TestMarkAs(t *testing.T) {
+	markdown := Mark("# Title\n\nText\n\n```mermaid\ngraph LR\n```\n", 10)
+	start := strings.Index(markdown, "```mermaid")
+	line, ok := LineAt(markdown, start)
+	if !ok || line != 14 {
+		t.Fatalf("expected the fence on line 14, got %d, %v", line, ok)
+	}
+	if _, ok := LineAt("Text\n\n```mermaid\n", 6); ok {
+		t.Error("expected no line in unmarked markdown")
+	}
+
+	markdown = markdown[:start] + "```\nA\n```\n\n" + MarkAs("*Figure 1*", line) + "\n"
+	out, err := Render(markdown, ansi.Options{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, m := Extract(out)
+	captions := m.Fences(strings.Repeat("\n", 10) + "# Title\n\nText\n\n```mermaid\ngraph LR\n```\n")
+	if len(captions) != 1 || captions[0].Source != 14 || !strings.Contains(strings.Split(out, "\n")[captions[0].Rendered], "Figure 1") {
+		t.Errorf("expected the caption on the fence's line, got %v in:\n%s", captions, out)
+	}
+}
+
+func TestMark_RoundTrip(t *testing.T) {
+	input := "# Title\n\nText\n\n## Next\n\nMore text.\n"
+	out, m := Extract(Mark(input, 0))
+	if out != input {
+		t.Errorf("expected %q, got %q", input, out)
+	}
+	if want := (Map{{0, 0}, {2, 2}, {4, 4}, {6, 6}}); !reflect.DeepEqual(m, want) {
+		t.Errorf("expected %v, got %v", want, m)
+	}
+}
+
+// TestRender tests that marks don't change how markdown renders, and that
+// they're read back from where its headings and paragraphs were rendered.
+func TestRender(t *testing.T) {
+	options := ansi.Options{WordWrap: 80, ColorProfile: termenv.TrueColor, Styles: styles.NoTTYStyleConfig}
+	tr, err := glamour.NewTermRenderer(glamour.WithStyles(styles.NoTTYStyleConfig), glamour.WithWordWrap(80))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, input := range []string{
+		"_x_ y",
+		"__bold__ start",
+		"www.example.org",
+		"Term\n\n: definition",
+		"# Title\n\nSome text\n\n- item\n- other\n\n> quoted\n\nSetext\n===",
+	} {
+		want, err := tr.Render(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Render(Mark(input, 0), options, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, m := Extract(got)
+		if out != want {
+			t.Errorf("%q: expected\n%q\ngot\n%q", input, want, out)
+		}
+		if len(m) == 0 {
+			t.Errorf("%q: expected its lines mapped", input)
+		}
+		lines := strings.Split(out, "\n")
+		for _, l := range m {
+			word := strings.FieldsFunc(strings.Split(input, "\n")[l.Source], func(r rune) bool { return !unicode.IsLetter(r) })[0]
+			if !strings.Contains(lines[l.Rendered], word) {
+				t.Errorf("%q: expected line %d rendered on %q", input, l.Source, lines[l.Rendered])
+			}
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hholst80/glow/utils"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// stdinStreamDelay is how long piped markdown may take to arrive before what
+// has come of it is previewed while the rest is awaited.
+const stdinStreamDelay = 250 * time.Millisecond
+
+// stdinLine is a line read from stdin, and the error reading it, if any.
+type stdinLine struct {
+	text string
+	err  error
+}
+
+// executeStdin renders markdown piped to stdin as a whole once it's all
+// arrived. If it arrives slowly, as the output of a program that writes
+// slowly does, its complete blocks are previewed on the terminal meanwhile,
+// and cleared to render it all: later blocks can change how earlier ones
+// render, such as by defining the links they refer to. With --tui the pager
+// follows the end of it; the pager, and code shown with --language, wait for
+// all of it.
+func executeStdin(cmd *cobra.Command, src *source, w io.Writer) error {
+	_, isCode := utils.CodeLanguage("", language)
+	switch {
+	case tui:
+		return runTUI("", "", nil, src.reader)
+	case pager || isCode:
+		return executeCLI(cmd, src, w)
+	case raw:
+		if _, err := io.Copy(w, src.reader); err != nil {
+			return fmt.Errorf("unable to write to writer: %w", err)
+		}
+		return nil
+	}
+
+	// Reading stops once this returns, such as on an error, rather than
+	// waiting to send a line that's never received
+	lines := make(chan stdinLine)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		r := bufio.NewReader(src.reader)
+		for {
+			line, err := r.ReadString('\n')
+			select {
+			case lines <- stdinLine{line, err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var (
+		doc     strings.Builder
+		preview *stdinPreview
+	)
+	slow := time.After(stdinStreamDelay)
+	for {
+		select {
+		case line := <-lines:
+			doc.WriteString(line.text)
+			if line.err != nil {
+				preview.clear()
+				if !errors.Is(line.err, io.EOF) {
+					return fmt.Errorf("unable to read from reader: %w", line.err)
+				}
+				return executeCLI(cmd, &source{reader: io.NopCloser(strings.NewReader(doc.String()))}, w)
+			}
+			if err := preview.update(cmd, doc.String()); err != nil {
+				return err
+			}
+		case <-slow:
+			preview = newStdinPreview(w)
+			if err := preview.update(cmd, doc.String()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// stdinPreview shows the complete blocks of slowly piped markdown on the
+// terminal while the rest is awaited. It shows no more than fits on the
+// terminal, so that it can be cleared once the rest arrives.
+type stdinPreview struct {
+	w      io.Writer
+	height int
+
+	shown string // the markdown previewed
+	lines int    // how many line breaks the preview has
+	ended bool   // whether it filled the terminal, so it's no longer updated
+}
+
+// newStdinPreview returns a preview on w, or nil if w isn't a terminal or
+// diagrams must render (--strict), which isn't known until all of the
+// document has.
+func newStdinPreview(w io.Writer) *stdinPreview {
+	f, ok := w.(*os.File)
+	if !ok || strict || !term.IsTerminal(int(f.Fd())) {
+		return nil
+	}
+	_, height, err := term.GetSize(int(f.Fd()))
+	if err != nil || height < 2 {
+		return nil
+	}
+	return &stdinPreview{w: w, height: height - 1}
+}
+
+// update previews the complete blocks of markdown, if there are more than
+// were previewed.
+func (p *stdinPreview) update(cmd *cobra.Command, markdown string) error {
+	if p == nil || p.ended {
+		return nil
+	}
+	md := markdown[:completeBlocks(markdown)]
+	if md == p.shown || strings.TrimSpace(md) == "" {
+		return nil
+	}
+
+	var out strings.Builder
+	if err := executeCLI(cmd, &source{reader: io.NopCloser(strings.NewReader(md))}, &out); err != nil {
+		return err
+	}
+	rendered := out.String()
+	if n := strings.Count(rendered, "\n"); n > p.height {
+		p.ended = true
+		return nil
+	}
+
+	p.clear()
+	if _, err := io.WriteString(p.w, rendered); err != nil {
+		return fmt.Errorf("unable to write to writer: %w", err)
+	}
+	p.shown, p.lines = md, strings.Count(rendered, "\n")
+	return nil
+}
+
+// clear removes the preview from the terminal.
+func (p *stdinPreview) clear() {
+	if p == nil || p.shown == "" {
+		return
+	}
+	if p.lines > 0 {
+		// to the start of the preview's first line
+		_, _ = fmt.Fprintf(p.w, "\x1b[%dF", p.lines)
+	}
+	_, _ = io.WriteString(p.w, "\r\x1b[J")
+	p.shown, p.lines = "", 0
+}
+
+// completeBlocks returns how much of s is made of complete markdown blocks,
+// which end with a blank line outside of code blocks.
+func completeBlocks(s string) int {
+	var end, pos int
+	inFence := false
+	for {
+		i := strings.IndexByte(s[pos:], '\n')
+		if i < 0 {
+			return end
+		}
+		line := strings.TrimSpace(s[pos : pos+i])
+		pos += i + 1
+
+		switch {
+		case strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~"):
+			inFence = !inFence
+		case line == "" && !inFence:
+			end = pos
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func TestCompleteBlocks(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want string
+	}{
+		{"# Title\n\nA paragraph", "# Title\n\n"},
+		{"A paragraph\nstill going\n", ""},
+		{"- a\n- b\n\n  \nNext\n", "- a\n- b\n\n  \n"},
+		{"Intro\n\n```go\nx := 1\n\ny := 2\n", "Intro\n\n"},
+		{"```go\nx := 1\n\n```\n\n", "```go\nx := 1\n\n```\n\n"},
+	} {
+		if got := tt.in[:completeBlocks(tt.in)]; got != tt.want {
+			t.Errorf("completeBlocks(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExecuteStdin(t *testing.T) {
+	oldPager, oldStyle, oldWidth := pager, style, width
+	defer func() { pager, style, width = oldPager, oldStyle, oldWidth }()
+	pager, style, width = false, "dark", 80
+
+	doc := "# Title\n\nSee [the docs][docs].\n\n- an item\n\n  with more\n\n[docs]: https://example.com\n"
+	var want strings.Builder
+	if err := executeCLI(&cobra.Command{}, &source{reader: io.NopCloser(strings.NewReader(doc))}, &want); err != nil {
+		t.Fatal(err)
+	}
+
+	// Slowly piped markdown is rendered as a whole too, so the link in the
+	// first block resolves to the definition in the last
+	r, w := io.Pipe()
+	go func() {
+		blocks := strings.SplitAfter(doc, "\n\n")
+		_, _ = io.WriteString(w, blocks[0])
+		time.Sleep(stdinStreamDelay + 50*time.Millisecond)
+		_, _ = io.WriteString(w, strings.Join(blocks[1:], ""))
+		_ = w.Close()
+	}()
+	var got strings.Builder
+	if err := executeStdin(&cobra.Command{}, &source{reader: r}, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("piped markdown rendered as %q, want %q", got.String(), want.String())
+	}
+}
@@ -0,0 +1,112 @@
+package tables
+
+import (
+	"encoding/csv"
+	"strconv"
+	"strings"
+
+	"github.com/hholst80/glow/utils"
+)
+
+// separators are the field separators of the code block languages that are
+// shown as tables.
+var separators = map[string]rune{"csv": ',', "tsv": '\t'}
+
+// cellEscaper escapes the characters of a field that would be taken for
+// markdown in a cell.
+var cellEscaper = strings.NewReplacer(
+	`\`, `\\`, "|", `\|`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`,
+)
+
+// ProcessCSV replaces the csv and tsv code blocks of markdown with tables,
+// the first record as their header. Columns of numbers are aligned right.
+// Tables take the blank lines of the fences around them, so they're blocks
+// of their own, and the indentation of the opening fence, so they stay in
+// the list items they're in. Blocks that don't parse are left as they are.
+func ProcessCSV(markdown string) string {
+	lines := strings.Split(markdown, "\n")
+	out := make([]string, 0, len(lines))
+	fence := ""
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		inCode := fence != ""
+		fence = utils.NextFence(fence, line)
+		sep, ok := separators[utils.FenceLanguage(fence)]
+		if inCode || !ok {
+			out = append(out, line)
+			continue
+		}
+
+		end := i + 1
+		for end < len(lines) && utils.NextFence(fence, lines[end]) != "" {
+			end++
+		}
+		table, ok := csvTable(strings.Join(lines[i+1:end], "\n"), sep)
+		if !ok {
+			out = append(out, line)
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		out = append(out, "")
+		for _, row := range table {
+			out = append(out, indent+row)
+		}
+		out = append(out, "")
+		fence = ""
+		i = end
+	}
+	return strings.Join(out, "\n")
+}
+
+// csvTable returns the lines of a markdown table of the records of data, or
+// false if it doesn't parse or has none.
+func csvTable(data string, sep rune) ([]string, bool) {
+	r := csv.NewReader(strings.NewReader(data))
+	r.Comma = sep
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	records, err := r.ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil, false
+	}
+
+	columns := 0
+	for _, record := range records {
+		columns = max(columns, len(record))
+	}
+	// Columns of numbers, and of empty fields, with at least one number
+	numeric := make([]bool, columns)
+	for i := range numeric {
+		numbers, others := 0, 0
+		for _, record := range records[1:] {
+			if f := strings.TrimSpace(cell(record, i)); f != "" {
+				if _, err := strconv.ParseFloat(f, 64); err == nil {
+					numbers++
+				} else {
+					others++
+				}
+			}
+		}
+		numeric[i] = numbers > 0 && others == 0
+	}
+
+	row := func(record []string) string {
+		cells := make([]string, columns)
+		for i := range cells {
+			cells[i] = cellEscaper.Replace(strings.TrimSpace(cell(record, i)))
+		}
+		return "| " + strings.Join(cells, " | ") + " |"
+	}
+	delimiters := make([]string, columns)
+	for i, n := range numeric {
+		delimiters[i] = "---"
+		if n {
+			delimiters[i] = "--:"
+		}
+	}
+	lines := []string{row(records[0]), "|" + strings.Join(delimiters, "|") + "|"}
+	for _, record := range records[1:] {
+		lines = append(lines, row(record))
+	}
+	return lines, true
+}
@@ -0,0 +1,49 @@
+package tables
+
+import "testing"
+
+func TestProcessCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "csv",
+			in:   "Before\n\n```csv\nname,count\n\"a, b\",12\nc|d,3.5\n```\n\nAfter\n",
+			want: "Before\n\n\n| name | count |\n|---|--:|\n| a, b | 12 |\n| c\\|d | 3.5 |\n\n\nAfter\n",
+		},
+		{
+			name: "tsv with short rows",
+			in:   "~~~ TSV\na\tb\tc\n*x*\n~~~\n",
+			want: "\n| a | b | c |\n|---|---|---|\n| \\*x\\* |  |  |\n\n",
+		},
+		{
+			name: "text after the block, in a list item",
+			in:   "- Counts:\n\n  ```csv\n  a,b\n  1,2\n  ```\n  Text after.\n",
+			want: "- Counts:\n\n\n  | a | b |\n  |--:|--:|\n  | 1 | 2 |\n\n  Text after.\n",
+		},
+		{
+			name: "other languages",
+			in:   "```go\na,b\n```\n",
+			want: "```go\na,b\n```\n",
+		},
+		{
+			name: "empty",
+			in:   "```csv\n```\n",
+			want: "```csv\n```\n",
+		},
+		{
+			name: "csv in another code block",
+			in:   "````markdown\n```csv\na,b\n```\n````\n",
+			want: "````markdown\n```csv\na,b\n```\n````\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ProcessCSV(tt.in); got != tt.want {
+				t.Errorf("ProcessCSV() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
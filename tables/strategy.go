@@ -0,0 +1,63 @@
+package tables
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hholst80/glow/utils"
+	"go.yaml.in/yaml/v3"
+)
+
+// Strategy selects how tables wider than the page are laid out.
+type Strategy string
+
+// Table strategies.
+const (
+	// StrategyOff leaves tables to glamour, which squeezes them to fit
+	StrategyOff Strategy = "off"
+
+	// StrategyShrink narrows the columns in proportion to their widths,
+	// wrapping the text of their cells
+	StrategyShrink Strategy = "shrink"
+
+	// StrategyRecords shows each row as a record, a list of its fields
+	StrategyRecords Strategy = "records"
+
+	// StrategyAuto shrinks tables, or shows them as records if their columns
+	// can't be narrowed enough without breaking words
+	StrategyAuto Strategy = "auto"
+)
+
+// DefaultStrategy is the strategy used unless configured.
+const DefaultStrategy = StrategyAuto
+
+// ParseStrategy returns the strategy named s, or DefaultStrategy for "".
+func ParseStrategy(s string) (Strategy, error) {
+	switch st := Strategy(s); st {
+	case "":
+		return DefaultStrategy, nil
+	case StrategyOff, StrategyShrink, StrategyRecords, StrategyAuto:
+		return st, nil
+	}
+	return "", fmt.Errorf("invalid table strategy %q: must be %q, %q, %q or %q", s, StrategyAuto, StrategyShrink, StrategyRecords, StrategyOff)
+}
+
+// DocumentStrategy returns the strategy set by the tables key of a document's
+// front matter, or fallback if it sets none.
+func DocumentStrategy(markdown []byte, fallback Strategy) Strategy {
+	fm := utils.Frontmatter(markdown)
+	if fm == nil {
+		return fallback
+	}
+	var meta struct {
+		Tables string `yaml:"tables"`
+	}
+	if err := yaml.Unmarshal(fm, &meta); err != nil || meta.Tables == "" {
+		return fallback
+	}
+	st, err := ParseStrategy(strings.ToLower(strings.TrimSpace(meta.Tables)))
+	if err != nil {
+		return fallback
+	}
+	return st
+}
@@ -0,0 +1,326 @@
+// Package tables lays out markdown tables wider than the page, which glamour
+// would squeeze by cutting off their headers and breaking words, either with
+// their columns narrowed in proportion or with each row shown as a record. It
+// also shows csv and tsv code blocks as tables.
+package tables
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/hholst80/glow/utils"
+)
+
+// margin is how much narrower than the page glamour leaves the tables it
+// draws, and codeMargin the code blocks that tables laid out ahead of
+// rendering are kept in.
+const (
+	margin     = 4
+	codeMargin = 6
+)
+
+// placeholderPrefix starts the placeholder of a line of a table laid out
+// ahead of rendering.
+const placeholderPrefix = "@glow-table-"
+
+var (
+	// delimiterRegex matches the row below a table's header.
+	delimiterRegex = regexp.MustCompile(`^ {0,3}\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+
+	// imageRegex and linkRegex match images and links in a cell.
+	imageRegex = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	linkRegex  = regexp.MustCompile(`\[([^\]]*)\]\(([^)\s]*)[^)]*\)`)
+
+	// autolinkRegex matches an autolink, such as <https://example.com>.
+	autolinkRegex = regexp.MustCompile(`<((?:https?|mailto):[^>]*)>`)
+
+	// escapeRegex matches a character escaped with a backslash.
+	escapeRegex = regexp.MustCompile(`\\([[:punct:]])`)
+)
+
+// table is a table of a document.
+type table struct {
+	header []string
+	align  []lipgloss.Position
+	rows   [][]string
+}
+
+// Preprocessor lays out the tables of a document too wide for the page
+// before glamour renders it. Process replaces them, and Restore puts the ones
+// it laid out itself in place of their placeholders after rendering.
+type Preprocessor struct {
+	width    int
+	strategy Strategy
+	raw      map[string]string // placeholder to the line of a table
+	tables   int
+}
+
+// NewPreprocessor returns a Preprocessor for a page width columns wide; 0
+// leaves tables as they are.
+func NewPreprocessor(width int, strategy Strategy) *Preprocessor {
+	return &Preprocessor{width: width, strategy: strategy, raw: map[string]string{}}
+}
+
+// Process returns markdown with the tables too wide for the page laid out by
+// the strategy.
+func (p *Preprocessor) Process(markdown string) string {
+	if p.width <= 0 || p.strategy == StrategyOff {
+		return markdown
+	}
+	lines := strings.Split(markdown, "\n")
+	out := make([]string, 0, len(lines))
+	fence := ""
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		inCode := fence != ""
+		if fence = utils.NextFence(fence, line); inCode || fence != "" {
+			out = append(out, line)
+			continue
+		}
+		if i+1 >= len(lines) || strings.HasPrefix(line, "    ") || !strings.Contains(line, "|") || !delimiterRegex.MatchString(lines[i+1]) {
+			out = append(out, line)
+			continue
+		}
+		t := table{header: cells(line)}
+		for _, d := range cells(lines[i+1]) {
+			t.align = append(t.align, alignment(d))
+		}
+		if len(t.header) != len(t.align) {
+			out = append(out, line)
+			continue
+		}
+		end := i + 2
+		for ; end < len(lines) && strings.TrimSpace(lines[end]) != "" && strings.Contains(lines[end], "|"); end++ {
+			t.rows = append(t.rows, cells(lines[end]))
+		}
+		out = append(out, p.layout(t, lines[i:end])...)
+		i = end - 1
+	}
+	return strings.Join(out, "\n")
+}
+
+// layout returns the lines of markdown to show a table as, its source if it
+// fits the page.
+func (p *Preprocessor) layout(t table, source []string) []string {
+	room := p.width - margin
+	natural := make([]int, len(t.header))
+	minimum := make([]int, len(t.header))
+	for _, row := range append([][]string{t.header}, t.rows...) {
+		for i := range natural {
+			text := plain(cell(row, i))
+			natural[i] = max(natural[i], ansi.StringWidth(text))
+			for _, word := range strings.Fields(text) {
+				minimum[i] = max(minimum[i], ansi.StringWidth(word))
+			}
+		}
+	}
+	if tableWidth(natural) <= room {
+		return source
+	}
+	room = p.width - codeMargin
+	if p.strategy == StrategyRecords || (p.strategy == StrategyAuto && tableWidth(minimum) > room) {
+		return records(t)
+	}
+	return p.placeholders(render(t, fit(natural, minimum, room)))
+}
+
+// placeholders returns a code block of placeholders for the lines of a
+// table, which glamour keeps one to a line, keeping the lines for Restore.
+func (p *Preprocessor) placeholders(lines []string) []string {
+	out := []string{"", "```"}
+	for i, line := range lines {
+		key := fmt.Sprintf("%s%d-%d@", placeholderPrefix, p.tables, i)
+		p.raw[key] = line
+		out = append(out, key)
+	}
+	p.tables++
+	return append(out, "```", "")
+}
+
+// Restore replaces the placeholders of tables in rendered output with their
+// lines.
+func (p *Preprocessor) Restore(rendered string) string {
+	if len(p.raw) == 0 {
+		return rendered
+	}
+	lines := strings.Split(rendered, "\n")
+	for i, line := range lines {
+		idx := strings.Index(line, placeholderPrefix)
+		if idx < 0 {
+			continue
+		}
+		end := strings.Index(line[idx+len(placeholderPrefix):], "@")
+		if end < 0 {
+			continue
+		}
+		tableLine, ok := p.raw[line[idx:idx+len(placeholderPrefix)+end+1]]
+		if !ok {
+			continue
+		}
+		prefix := line[:idx]
+		if strings.Contains(prefix, "\x1b[") {
+			// End the code block's styling before the table's
+			prefix += "\x1b[0m"
+		}
+		lines[i] = prefix + tableLine
+	}
+	return strings.Join(lines, "\n")
+}
+
+// fit returns the widths of columns narrowed to fit room, from their natural
+// widths and those of their longest words. The columns keep their longest
+// words whole if they can, and share the rest of the room in proportion to
+// how much wider they'd be.
+func fit(natural, minimum []int, room int) []int {
+	room -= tableWidth(make([]int, len(natural)))
+	widths := make([]int, len(natural))
+	if sum(minimum) > room {
+		// Words have to break
+		total := sum(natural)
+		for i, n := range natural {
+			widths[i] = max(1, room*n/total)
+		}
+		return widths
+	}
+	extra, slack := room-sum(minimum), sum(natural)-sum(minimum)
+	for i := range natural {
+		widths[i] = minimum[i] + extra*(natural[i]-minimum[i])/slack
+	}
+	return widths
+}
+
+// render returns the lines of a table with columns of the given widths,
+// drawn like glamour draws tables.
+func render(t table, widths []int) []string {
+	row := func(cells []string) []string {
+		wrapped := make([][]string, len(widths))
+		height := 1
+		for i, w := range widths {
+			wrapped[i] = strings.Split(ansi.Wrap(plain(cell(cells, i)), w, ""), "\n")
+			height = max(height, len(wrapped[i]))
+		}
+		lines := make([]string, height)
+		for l := range lines {
+			parts := make([]string, len(widths))
+			for i, w := range widths {
+				text := ""
+				if l < len(wrapped[i]) {
+					text = wrapped[i][l]
+				}
+				parts[i] = " " + lipgloss.PlaceHorizontal(w, t.align[i], text) + " "
+			}
+			lines[l] = strings.Join(parts, "│")
+		}
+		return lines
+	}
+
+	lines := row(t.header)
+	rules := make([]string, len(widths))
+	for i, w := range widths {
+		rules[i] = strings.Repeat("─", w+2)
+	}
+	lines = append(lines, strings.Join(rules, "┼"))
+	for _, r := range t.rows {
+		lines = append(lines, row(r)...)
+	}
+	return lines
+}
+
+// records returns markdown showing each row of a table as a record: its first
+// cell in bold, and a list of the others named by their headers.
+func records(t table) []string {
+	var out []string
+	for _, row := range t.rows {
+		out = append(out, "", "**"+cell(row, 0)+"**", "")
+		for i := 1; i < len(t.header); i++ {
+			if c := cell(row, i); c != "" {
+				out = append(out, "- **"+t.header[i]+":** "+c)
+			}
+		}
+	}
+	return append(out, "")
+}
+
+// cells returns the cells of a row of a table, split at the pipes outside of
+// code spans that aren't escaped.
+func cells(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var b strings.Builder
+	code := false
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && i+1 < len(line):
+			b.WriteString(line[i : i+2])
+			i++
+		case c == '`':
+			code = !code
+			b.WriteByte(c)
+		case c == '|' && !code:
+			cells = append(cells, strings.TrimSpace(b.String()))
+			b.Reset()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return append(cells, strings.TrimSpace(b.String()))
+}
+
+// cell returns the ith cell of a row, or "" if the row is short of it.
+func cell(row []string, i int) string {
+	if i < len(row) {
+		return row[i]
+	}
+	return ""
+}
+
+// alignment returns the alignment a cell of the row below the header sets
+// for its column.
+func alignment(delimiter string) lipgloss.Position {
+	left, right := strings.HasPrefix(delimiter, ":"), strings.HasSuffix(delimiter, ":")
+	switch {
+	case left && right:
+		return lipgloss.Center
+	case right:
+		return lipgloss.Right
+	}
+	return lipgloss.Left
+}
+
+// plain returns the text of a cell as glamour shows it, without the markup
+// of its emphasis and code, and with links followed by their URLs.
+func plain(s string) string {
+	s = imageRegex.ReplaceAllString(s, "$1")
+	s = linkRegex.ReplaceAllStringFunc(s, func(link string) string {
+		m := linkRegex.FindStringSubmatch(link)
+		if m[1] == "" || m[1] == m[2] {
+			return m[2]
+		}
+		return m[1] + " " + m[2]
+	})
+	s = autolinkRegex.ReplaceAllString(s, "$1")
+	s = strings.NewReplacer("**", "", "__", "", "~~", "", "`", "").Replace(s)
+	return escapeRegex.ReplaceAllString(s, "$1")
+}
+
+// tableWidth returns the width of a table with columns of the given widths,
+// padded and divided like glamour draws them.
+func tableWidth(widths []int) int {
+	return sum(widths) + 3*len(widths) - 1
+}
+
+func sum(ns []int) int {
+	total := 0
+	for _, n := range ns {
+		total += n
+	}
+	return total
+}
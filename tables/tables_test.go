@@ -0,0 +1,107 @@
+package tables
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+const wideTable = "| Name | Description |\n|---|:-:|\n| alpha | a description that goes on and on and on |\n| beta | short |\n"
+
+func TestProcessFits(t *testing.T) {
+	p := NewPreprocessor(80, StrategyAuto)
+	if got := p.Process(wideTable); got != wideTable {
+		t.Errorf("Expected a table that fits to be left as it is, got:\n%s", got)
+	}
+}
+
+func TestProcessShrink(t *testing.T) {
+	p := NewPreprocessor(40, StrategyAuto)
+	out := p.Process("Before\n\n" + wideTable + "\nAfter\n")
+	if strings.Contains(out, "| alpha |") || !strings.Contains(out, "```\n"+placeholderPrefix+"0-0@\n") {
+		t.Fatalf("Expected the table replaced by placeholders, got:\n%s", out)
+	}
+
+	lines := strings.Split(p.Restore(out), "\n")
+	var table []string
+	for _, line := range lines {
+		if strings.Contains(line, "│") || strings.Contains(line, "┼") {
+			table = append(table, line)
+			if w := ansi.StringWidth(line); w > 40-codeMargin {
+				t.Errorf("Expected lines no wider than %d, got %d: %q", 40-codeMargin, w, line)
+			}
+		}
+	}
+	if len(table) < 4 || !strings.HasPrefix(table[0], " Name  │") {
+		t.Errorf("Expected the table drawn with its header first, got:\n%s", strings.Join(table, "\n"))
+	}
+	if !strings.Contains(strings.Join(table, "\n"), "beta") {
+		t.Errorf("Expected every row drawn, got:\n%s", strings.Join(table, "\n"))
+	}
+}
+
+func TestProcessRecords(t *testing.T) {
+	p := NewPreprocessor(20, StrategyAuto)
+	got := p.Process("| Name | Region |\n|---|---|\n| alpha | eu-west-1-production |\n| beta | |\n")
+	want := "\n**alpha**\n\n- **Region:** eu-west-1-production\n\n**beta**\n\n\n"
+	if got != want {
+		t.Errorf("Process() = %q, want %q", got, want)
+	}
+}
+
+func TestProcessStrategies(t *testing.T) {
+	in := "```\n" + wideTable + "```\n"
+	if got := NewPreprocessor(40, StrategyAuto).Process(in); got != in {
+		t.Errorf("Expected a table in a code block to be left as it is, got:\n%s", got)
+	}
+	if got := NewPreprocessor(40, StrategyOff).Process(wideTable); got != wideTable {
+		t.Errorf("Expected off to leave tables as they are, got:\n%s", got)
+	}
+	if got := NewPreprocessor(40, StrategyRecords).Process(wideTable); !strings.Contains(got, "**alpha**") {
+		t.Errorf("Expected records, got:\n%s", got)
+	}
+}
+
+func TestFit(t *testing.T) {
+	// Room for the longest words only, then some to share by how much wider
+	// each column would be
+	if got, want := fit([]int{10, 20}, []int{4, 4}, 13), []int{4, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("fit() = %v, want %v", got, want)
+	}
+	if got, want := fit([]int{10, 22}, []int{4, 4}, 17), []int{5, 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("fit() = %v, want %v", got, want)
+	}
+}
+
+func TestCells(t *testing.T) {
+	got := cells("| a | `b|c` | d\\|e |")
+	want := []string{"a", "`b|c`", "d\\|e"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cells() = %q, want %q", got, want)
+	}
+	if got := plain("**[docs](https://example.com)** and `d\\|e`"); got != "docs https://example.com and d|e" {
+		t.Errorf("plain() = %q", got)
+	}
+}
+
+func TestDocumentStrategy(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Strategy
+	}{
+		{"# Doc\n", StrategyShrink},
+		{"---\ntables: records\n---\n# Doc\n", StrategyRecords},
+		{"---\ntables: Off\n---\n", StrategyOff},
+		{"---\ntables: sideways\n---\n", StrategyShrink},
+	}
+	for _, tt := range tests {
+		if got := DocumentStrategy([]byte(tt.in), StrategyShrink); got != tt.want {
+			t.Errorf("DocumentStrategy(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if _, err := ParseStrategy("sideways"); err == nil {
+		t.Error("Expected an error for an unknown strategy")
+	}
+}
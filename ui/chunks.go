@@ -0,0 +1,265 @@
+package ui
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hholst80/glow/footnotes"
+	"github.com/hholst80/glow/htmlblocks"
+	"github.com/hholst80/glow/sourcemap"
+	"github.com/hholst80/glow/utils"
+)
+
+// chunkThreshold is the size of a document, in bytes, beyond which the pager
+// renders it in chunks as it's scrolled through, rather than all at once.
+const chunkThreshold = 512 * 1024
+
+// chunkSize is about how much markdown is rendered at a time. Chunks end
+// before a top-level heading, or before a blank line in sections several
+// times longer.
+const chunkSize = 64 * 1024
+
+// chunkLookahead is how many screens below the view are kept rendered.
+const chunkLookahead = 2
+
+// Match link reference definitions, e.g. [glow]: https://github.com/..., but
+// not footnotes
+var linkDefinitionRegex = regexp.MustCompile(`^ {0,3}\[[^\]^][^\]]*\]:\s*\S`)
+
+// documentChunk is part of a document rendered on its own, the line of the
+// document it starts on, and how many <details> sections come before it, so
+// they're folded and unfolded by their number in the whole document.
+type documentChunk struct {
+	markdown string
+	line     int
+	details  int
+}
+
+// pendingChunks are the chunks of a document left to render, and the width
+// the first was rendered at, which the rest are rendered at too. Every render
+// of a document makes new ones, so chunks rendered for an earlier render are
+// told apart and dropped.
+type pendingChunks struct {
+	chunks    []documentChunk
+	width     int
+	rendering bool
+
+	// ctx is the context of the render that made them, which stops
+	// rendering them once it's cancelled
+	ctx context.Context
+}
+
+// chunksRenderedMsg holds the first chunk of a long document rendered, the
+// chunks left to render, and the structure of the whole document if it was
+// kept from an earlier render.
+type chunksRenderedMsg struct {
+	content   string
+	sourceMap sourcemap.Map
+	pending   *pendingChunks
+	parsed    *parsedDocument
+}
+
+// chunkRenderedMsg holds a chunk of a document rendered to be appended to
+// what's shown of it.
+type chunkRenderedMsg struct {
+	content   string
+	sourceMap sourcemap.Map
+	pending   *pendingChunks
+}
+
+// renderChunked reports whether a document is rendered in chunks: markdown
+// beyond chunkThreshold that isn't still streaming in, and whose lines and
+// figures aren't numbered, since numbers run through the whole document.
+func (m pagerModel) renderChunked(md string) bool {
+	return len(md) > chunkThreshold &&
+		m.common.cfg.GlamourEnabled &&
+		m.isMarkdownFile() &&
+		m.stream == nil &&
+		!m.common.cfg.ShowLineNumbers &&
+		!m.common.cfg.Figures
+}
+
+// splitChunks splits markdown into chunks of about chunkSize before its
+// top-level headings and the files it's joined from, falling back to blank
+// lines outside code blocks in longer sections. Link reference definitions
+// are added to every chunk, so links resolve wherever they're defined, and
+// footnotes are numbered and collected at the end over the whole document
+// before it's split, leaving the lines of their definitions blank. The
+// document isn't parsed for its headings, which would hold up showing the
+// first chunk: the lines opening with #s are enough to split it.
+func splitChunks(markdown string, files []utils.JoinedFile) []documentChunk {
+	lines := strings.SplitAfter(footnotes.ProcessInPlace(markdown), "\n")
+
+	// Chunks start at headings of the highest level, or at joined files
+	var definitions strings.Builder
+	levels := map[int]int{}
+	top := 7
+	inCodeBlock := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			inCodeBlock = !inCodeBlock
+		case inCodeBlock:
+		case linkDefinitionRegex.MatchString(line):
+			definitions.WriteString(strings.TrimRight(line, "\n") + "\n")
+		default:
+			if level := headingLevel(line); level > 0 {
+				levels[i] = level
+				top = min(top, level)
+			}
+		}
+	}
+	for _, f := range files {
+		levels[f.Line] = 0
+	}
+	breaks := map[int]bool{}
+	for i, level := range levels {
+		if level == 0 || level == top {
+			breaks[i] = true
+		}
+	}
+
+	var (
+		chunks  []documentChunk
+		b       strings.Builder
+		line    int
+		details int
+	)
+	flush := func() {
+		if b.Len() == 0 {
+			return
+		}
+		md := b.String()
+		if definitions.Len() > 0 {
+			md = strings.TrimRight(md, "\n") + "\n\n" + definitions.String()
+		}
+		chunks = append(chunks, documentChunk{markdown: md, line: line, details: details})
+		line += strings.Count(b.String(), "\n")
+		details += len(htmlblocks.Find(b.String()))
+		b.Reset()
+	}
+
+	inCodeBlock = false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !inCodeBlock && b.Len() >= chunkSize &&
+			(breaks[i] || (trimmed == "" && b.Len() >= 4*chunkSize)) {
+			flush()
+		}
+		b.WriteString(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCodeBlock = !inCodeBlock
+		}
+	}
+	flush()
+	return chunks
+}
+
+// headingLevel returns the level of the ATX heading a line is, or 0 if it
+// isn't one.
+func headingLevel(line string) int {
+	if indent := len(line) - len(strings.TrimLeft(line, " ")); indent > 3 {
+		return 0
+	}
+	trimmed := strings.TrimSpace(line)
+	level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+	if level == 0 || level > 6 || (len(trimmed) > level && trimmed[level] != ' ' && trimmed[level] != '\t') {
+		return 0
+	}
+	return level
+}
+
+// renderChunks renders the first chunk of a long document, leaving the rest
+// to render as it's scrolled through. Its structure is parsed once the first
+// chunk is shown, unless it's kept.
+func renderChunks(ctx context.Context, m pagerModel, md string, kept *parsedDocument) tea.Cmd {
+	return func() tea.Msg {
+		chunks := splitChunks(md, m.currentDocument.files)
+		width := renderWidth(m, md)
+		s, sourceMap, _, err := glamourRenderChunk(ctx, m, chunks[0], width)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return errMsg{err}
+		}
+		var pending *pendingChunks
+		if len(chunks) > 1 {
+			pending = &pendingChunks{chunks: chunks[1:], width: width, ctx: ctx}
+		}
+		return chunksRenderedMsg{content: s, sourceMap: sourceMap, pending: pending, parsed: kept}
+	}
+}
+
+// renderNextChunk renders the next chunk of the document if the view is
+// within chunkLookahead screens of what's rendered, or if the whole
+// document is needed, such as to search it or to jump to a heading that
+// isn't rendered yet. One chunk is rendered at a time.
+func (m *pagerModel) renderNextChunk() tea.Cmd {
+	p := m.pending
+	if p == nil || p.rendering || len(p.chunks) == 0 {
+		return nil
+	}
+	ahead := m.viewport.TotalLineCount() - m.viewport.YOffset - m.viewport.Height
+	if ahead > chunkLookahead*m.viewport.Height && !m.searchActive() && m.pendingHeading == 0 {
+		return nil
+	}
+
+	p.rendering = true
+	chunk, pm := p.chunks[0], *m
+	return func() tea.Msg {
+		s, sourceMap, _, err := glamourRenderChunk(p.ctx, pm, chunk, p.width)
+		if p.ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return errMsg{err}
+		}
+		return chunkRenderedMsg{content: s, sourceMap: sourceMap, pending: p}
+	}
+}
+
+// appendChunk adds a rendered chunk to what's shown of the document, keeping
+// the view where it is, and maps headings and search matches again.
+func (m *pagerModel) appendChunk(msg chunkRenderedMsg) tea.Cmd {
+	if msg.pending != m.pending {
+		return nil
+	}
+	m.pending.rendering = false
+	m.pending.chunks = m.pending.chunks[1:]
+	if len(m.pending.chunks) == 0 {
+		m.pending = nil
+	}
+
+	yOffset := m.viewport.YOffset
+	offset := strings.Count(m.renderedContent, "\n") + 1
+	m.setContent(m.renderedContent + "\n" + msg.content)
+	m.viewport.YOffset = yOffset
+	m.sourceMap = append(m.sourceMap, msg.sourceMap.Offset(offset)...)
+
+	if m.isMarkdownFile() {
+		m.outline.mapHeadings(m.sourceMap)
+	}
+	if m.searchActive() {
+		index := m.searchIndex
+		m.applySearch(m.searchQuery)
+		m.searchIndex = min(index, max(0, len(m.searchMatches)-1))
+	}
+
+	// Jump to the heading asked for once it's rendered
+	if i := m.pendingHeading; i > 0 && i < len(m.outline.headings) {
+		if m.outline.headings[i].RenderedLine >= 0 || m.pending == nil {
+			m.pendingHeading = 0
+			m.jumpToHeading(i)
+		}
+	}
+
+	if m.viewport.HighPerformanceRendering {
+		return viewport.Sync(m.viewport)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hholst80/glow/sourcemap"
+)
+
+// chunkMsg runs cmd and returns the rendered chunk among its messages, or nil
+// if there's none.
+func chunkMsg(cmd tea.Cmd) tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+	switch msg := cmd().(type) {
+	case chunkRenderedMsg:
+		return msg
+	case tea.BatchMsg:
+		for _, c := range msg {
+			if msg := chunkMsg(c); msg != nil {
+				return msg
+			}
+		}
+	}
+	return nil
+}
+
+// longDocument returns a document of sections of about size bytes each.
+func longDocument(sections, size int) string {
+	var b strings.Builder
+	for i := range sections {
+		fmt.Fprintf(&b, "# Section %d\n\n", i)
+		for b.Len() < (i+1)*size {
+			b.WriteString("Some text in a paragraph of the section.\n\n")
+		}
+	}
+	return b.String()
+}
+
+// TestSplitChunks tests that long documents are split before their top-level
+// headings, outside code blocks, with link definitions in every chunk.
+func TestSplitChunks(t *testing.T) {
+	code := "```\n" + strings.Repeat("# not a heading\n", chunkSize/8) + "```\n"
+	doc := longDocument(20, chunkSize/2) + code + "# Last\n\nSee [glow].\n\n[glow]: https://github.com/charmbracelet/glow\n"
+
+	chunks := splitChunks(doc, nil)
+	if len(chunks) < 5 {
+		t.Fatalf("expected the document in several chunks, got %d", len(chunks))
+	}
+
+	var joined strings.Builder
+	for i, c := range chunks {
+		if i > 0 && !strings.HasPrefix(c.markdown, "# Section") && !strings.HasPrefix(c.markdown, "# Last") {
+			t.Errorf("expected chunk %d to start at a heading, got %q", i, c.markdown[:20])
+		}
+		if strings.Count(c.markdown, "```")%2 != 0 {
+			t.Errorf("expected chunk %d not to split a code block", i)
+		}
+		if !strings.HasSuffix(c.markdown, "[glow]: https://github.com/charmbracelet/glow\n") {
+			t.Errorf("expected chunk %d to end with the link definitions", i)
+		}
+		joined.WriteString(c.markdown)
+	}
+	if !strings.Contains(joined.String(), code) {
+		t.Error("expected the code block whole in a chunk")
+	}
+}
+
+// TestSplitChunks_Footnotes tests that footnotes are numbered over the whole
+// document, whichever chunks their references and definitions are in.
+func TestSplitChunks_Footnotes(t *testing.T) {
+	doc := "# First\n\nA note[^a].\n\n" + longDocument(1, chunkSize) + "# Next\n\nAnother[^b].\n\n[^a]: First note.\n[^b]: Second note.\n"
+
+	chunks := splitChunks(doc, nil)
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(chunks))
+	}
+	if !strings.Contains(chunks[0].markdown, "A note¹") || !strings.Contains(chunks[1].markdown, "Another²") {
+		t.Errorf("expected the references numbered in both chunks")
+	}
+	if !strings.Contains(chunks[1].markdown, "1. First note.\n2. Second note.") {
+		t.Errorf("expected the footnotes at the end of the last chunk, got %q", chunks[1].markdown)
+	}
+	if next := strings.Count(doc[:strings.Index(doc, "# Next")], "\n"); chunks[1].line != next {
+		t.Errorf("expected the second chunk to start on line %d, got %d", next, chunks[1].line)
+	}
+}
+
+// TestSplitChunks_Details tests that chunks know how many <details> sections
+// come before them, so they're toggled by their number in the document.
+func TestSplitChunks_Details(t *testing.T) {
+	section := "<details>\n<summary>More</summary>\n\nHidden.\n\n</details>\n\n"
+	doc := longDocument(1, chunkSize) + section + section + "# Next\n\n" + section
+
+	chunks := splitChunks(doc, nil)
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(chunks))
+	}
+	if chunks[0].details != 0 || chunks[1].details != 2 {
+		t.Errorf("expected 0 and 2 sections before the chunks, got %d and %d", chunks[0].details, chunks[1].details)
+	}
+}
+
+// TestPager_RenderChunks tests that long documents show their first chunk,
+// render more as they're scrolled toward the end, and drop chunks of earlier
+// renders.
+func TestPager_RenderChunks(t *testing.T) {
+	m := newTestPagerModel()
+	m.currentDocument.Body = longDocument(40, chunkSize/2)
+
+	msg := renderWithGlamour(m, m.currentDocument.Body)()
+	first, ok := msg.(chunksRenderedMsg)
+	if !ok {
+		t.Fatalf("expected the document rendered in chunks, got %T", msg)
+	}
+	m, _ = m.update(first)
+	if m.pending == nil || len(m.renderedContent) >= len(m.currentDocument.Body) {
+		t.Fatal("expected only part of the document rendered")
+	}
+	if m.renderNextChunk() != nil {
+		t.Error("expected no chunk rendered while the view is far from its end")
+	}
+
+	m.viewport.GotoBottom()
+	cmd := m.renderNextChunk()
+	if cmd == nil {
+		t.Fatal("expected the next chunk rendered near the end")
+	}
+	if m.renderNextChunk() != nil {
+		t.Error("expected one chunk rendered at a time")
+	}
+	shown, offset := len(m.renderedContent), m.viewport.YOffset
+	m, _ = m.update(cmd())
+	if len(m.renderedContent) <= shown || m.viewport.YOffset != offset {
+		t.Error("expected the chunk appended where the view was")
+	}
+
+	// Chunks of a render made before the document was rendered again are
+	// dropped
+	m.viewport.GotoBottom()
+	stale := m.renderNextChunk()
+	m, _ = m.update(renderWithGlamour(m, m.currentDocument.Body)())
+	shown = len(m.renderedContent)
+	m, _ = m.update(stale())
+	if len(m.renderedContent) != shown {
+		t.Error("expected a chunk of an earlier render dropped")
+	}
+}
+
+// markedRenderer renders markdown as is, telling where its lines were
+// rendered from their marks.
+type markedRenderer struct {
+	TestMarkdownRenderer
+}
+
+// RenderSourceMap implements SourceMapRenderer.
+func (r *markedRenderer) RenderSourceMap(_ context.Context, markdown string, _ int, _ string, _ string, _ string, _ bool) (string, sourcemap.Map, error) {
+	out, sourceMap := sourcemap.Extract(markdown)
+	return out, sourceMap, nil
+}
+
+// TestPager_RenderChunksSourceMap tests that the lines of chunks are mapped
+// to where they're shown in the whole document.
+func TestPager_RenderChunksSourceMap(t *testing.T) {
+	m := newTestPagerModel()
+	m.common.renderer = &markedRenderer{}
+	m.currentDocument.Body = longDocument(40, chunkSize/2)
+
+	m, _ = m.update(renderWithGlamour(m, m.currentDocument.Body)())
+	m.viewport.GotoBottom()
+	m, _ = m.update(chunkMsg(m.renderNextChunk()))
+
+	lines := strings.Split(m.renderedContent, "\n")
+	mapped := 0
+	for _, h := range parseHeadings(m.currentDocument.Body) {
+		line, exact := m.sourceMap.Rendered(h.Line)
+		if !exact {
+			continue
+		}
+		mapped++
+		if line < 0 || line >= len(lines) || !strings.HasSuffix(lines[line], h.Text) {
+			t.Errorf("expected %q mapped to a line showing it, got line %d", h.Text, line)
+		}
+	}
+	if chunks := len(splitChunks(m.currentDocument.Body, nil)); mapped == 0 || m.pending == nil || len(m.pending.chunks) != chunks-2 {
+		t.Fatalf("expected the headings of two chunks mapped, got %d headings", mapped)
+	}
+}
+
+// TestPager_RenderChunksShort tests that short documents render whole.
+func TestPager_RenderChunksShort(t *testing.T) {
+	m := newTestPagerModel()
+	if _, ok := renderWithGlamour(m, "# Short")().(contentRenderedMsg); !ok {
+		t.Error("expected a short document rendered whole")
+	}
+}
+
+// TestPager_RenderChunksJump tests that jumping to a heading that isn't
+// rendered yet renders chunks until it is.
+func TestPager_RenderChunksJump(t *testing.T) {
+	m := newTestPagerModel()
+	m.common.renderer = &markedRenderer{}
+	m.currentDocument.Body = longDocument(40, chunkSize/2)
+	m, _ = m.update(renderWithGlamour(m, m.currentDocument.Body)())
+	if len(m.outline.headings) != 0 || !m.parsing {
+		t.Fatal("expected the outline empty until the document is parsed")
+	}
+	m, _ = m.update(parseDocumentModel(m)())
+
+	last := len(m.outline.headings) - 1
+	m.jumpToHeading(last)
+	if m.pendingHeading != last {
+		t.Fatalf("expected the jump to wait for heading %d, got %d", last, m.pendingHeading)
+	}
+	cmd := m.renderNextChunk()
+	for cmd != nil {
+		m, cmd = m.update(chunkMsg(cmd))
+	}
+	if m.pendingHeading != 0 {
+		t.Fatal("expected the heading rendered")
+	}
+	if h := m.outline.headings[last]; m.viewport.YOffset != h.RenderedLine-m.scrollContext() {
+		t.Errorf("expected the view at line %d, got %d", h.RenderedLine-m.scrollContext(), m.viewport.YOffset)
+	}
+}
@@ -0,0 +1,135 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/dustin/go-humanize"
+)
+
+// column is a metadata column of the file listing.
+type column string
+
+// Metadata columns.
+const (
+	columnSize     column = "size"
+	columnModified column = "modified"
+	columnWords    column = "words"
+	columnHeadings column = "headings"
+)
+
+// allColumns are the columns shown when none are configured.
+var allColumns = []column{columnSize, columnModified, columnWords, columnHeadings}
+
+const (
+	columnGap = 2
+
+	// minTitleWidth is the narrowest the file name gets before columns are
+	// dropped to make room for it.
+	minTitleWidth = 20
+)
+
+// parseColumns returns the comma or space separated columns of s, skipping
+// unknown ones.
+func parseColumns(s string) []column {
+	var columns []column
+	for _, name := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		c := column(strings.ToLower(name))
+		switch c {
+		case columnSize, columnModified, columnWords, columnHeadings:
+			columns = append(columns, c)
+		default:
+			log.Warn("unknown column", "column", name)
+		}
+	}
+	return columns
+}
+
+// minWidth returns how wide the column's typical values are, which it's at
+// least as wide as, so that it doesn't shift as files are indexed.
+func (c column) minWidth() int {
+	switch c {
+	case columnSize:
+		return len("999.9 kB")
+	case columnModified:
+		return len("2006-01-02")
+	case columnWords:
+		return len("99999 words")
+	case columnHeadings:
+		return len("999 headings")
+	}
+	return 0
+}
+
+// value returns the column's value for md. Word and heading counts come from
+// the search index, and are blank until it's built.
+func (c column) value(md *markdown, doc *indexedDocument) string {
+	switch c {
+	case columnSize:
+		return humanize.Bytes(uint64(max(0, md.Size))) //nolint:gosec
+	case columnModified:
+		return md.Modtime.Format("2006-01-02")
+	case columnWords:
+		if doc != nil {
+			return plural(doc.words, "word")
+		}
+	case columnHeadings:
+		if doc != nil {
+			return plural(len(doc.headings), "heading")
+		}
+	}
+	return ""
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// toggleColumns shows or hides the metadata columns.
+func (m *stashModel) toggleColumns() {
+	m.showColumns = !m.showColumns
+}
+
+// columnsView returns the metadata columns of md that fit in width along
+// with the file name, right-aligned, and how wide they are. Columns are
+// dropped from the end on narrow terminals.
+func (m stashModel) columnsView(md *markdown, width int) (string, int) {
+	if !m.showColumns {
+		return "", 0
+	}
+
+	var b strings.Builder
+	used := 0
+	for _, c := range m.columns {
+		w := m.columnWidth(c) + columnGap
+		if used+w > width-minTitleWidth {
+			break
+		}
+		fmt.Fprintf(&b, "%*s", w, c.value(md, m.indexed(md)))
+		used += w
+	}
+	return b.String(), used
+}
+
+// columnWidth returns how wide the column's widest value among the listed
+// files is, or its minimum width.
+func (m stashModel) columnWidth(c column) int {
+	w := c.minWidth()
+	for _, md := range m.markdowns {
+		w = max(w, len(c.value(md, m.indexed(md))))
+	}
+	return w
+}
+
+// indexed returns the search index's document of md, or nil until it's
+// indexed.
+func (m stashModel) indexed(md *markdown) *indexedDocument {
+	if d, ok := m.textSearch.index[md]; ok {
+		return &d
+	}
+	return nil
+}
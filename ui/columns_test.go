@@ -0,0 +1,85 @@
+package ui
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseColumns(t *testing.T) {
+	got := parseColumns("Size, words  bogus,headings")
+	if want := []column{columnSize, columnWords, columnHeadings}; !slices.Equal(got, want) {
+		t.Errorf("parseColumns() = %v, want %v", got, want)
+	}
+}
+
+func TestColumnsView(t *testing.T) {
+	initSections()
+	md := &markdown{
+		Note:    "notes/a.md",
+		Size:    2048,
+		Modtime: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+	m := newStashModel(&commonModel{width: 100, height: 40, cfg: Config{Columns: "size,modified,words,headings"}})
+	m.addMarkdowns(md)
+
+	// Counts are blank until the files are indexed
+	view, width := m.columnsView(md, 94)
+	if !strings.Contains(view, "2.0 kB") || !strings.Contains(view, "2024-05-01") || strings.Contains(view, "word") {
+		t.Errorf("columnsView() before indexing = %q", view)
+	}
+	if width != len(view) {
+		t.Errorf("columnsView() width = %d, want %d", width, len(view))
+	}
+
+	m, _ = m.update(searchIndexMsg{md: newIndexedDocument("# One\n\nTwo words\n\n## Three")})
+	view, _ = m.columnsView(md, 94)
+	if !strings.Contains(view, "4 words") || !strings.Contains(view, "2 headings") {
+		t.Errorf("columnsView() after indexing = %q", view)
+	}
+
+	// Narrow terminals drop columns from the end
+	view, _ = m.columnsView(md, 50)
+	if !strings.Contains(view, "kB") || strings.Contains(view, "words") {
+		t.Errorf("columnsView() on a narrow terminal = %q", view)
+	}
+	if view, width := m.columnsView(md, minTitleWidth); view != "" || width != 0 {
+		t.Errorf("columnsView() without room = %q", view)
+	}
+
+	// The columns are toggled
+	m.toggleColumns()
+	if view, _ := m.columnsView(md, 94); view != "" {
+		t.Errorf("Expected hidden columns, got %q", view)
+	}
+	var b strings.Builder
+	m.toggleColumns()
+	stashItemView(&b, m, 0, md)
+	if line := stripANSI(strings.Split(b.String(), "\n")[0]); !strings.HasSuffix(line, "2 headings") {
+		t.Errorf("Expected the columns at the end of the line, got %q", line)
+	}
+}
+
+// TestColumnsView_LargeCounts tests that the columns widen to fit the
+// largest value, keeping the gap between them and their alignment.
+func TestColumnsView_LargeCounts(t *testing.T) {
+	initSections()
+	small := &markdown{Note: "a.md", Size: 10}
+	large := &markdown{Note: "b.md", Size: 10}
+	m := newStashModel(&commonModel{width: 100, height: 40, cfg: Config{Columns: "words,size"}})
+	m.addMarkdowns(small, large)
+	m, _ = m.update(searchIndexMsg{
+		small: newIndexedDocument("one"),
+		large: newIndexedDocument(strings.Repeat("word ", 123456)),
+	})
+
+	smallView, smallWidth := m.columnsView(small, 94)
+	largeView, largeWidth := m.columnsView(large, 94)
+	if !strings.Contains(largeView, "  123456 words") || smallWidth != largeWidth {
+		t.Errorf("Expected columns as wide for %q as for %q", smallView, largeView)
+	}
+	if i, j := strings.Index(smallView, "10 B"), strings.Index(largeView, "10 B"); i != j {
+		t.Errorf("Expected the sizes aligned, got %q and %q", smallView, largeView)
+	}
+}
@@ -1,21 +1,153 @@
 package ui
 
+import (
+	"io"
+	"time"
+
+	"github.com/hholst80/glow/mermaid"
+	"github.com/hholst80/glow/tables"
+	"github.com/hholst80/glow/utils"
+)
+
 // Config contains TUI-specific configuration.
 type Config struct {
 	ShowAllFiles     bool
+	ShowHidden       bool
+	NoIgnore         bool
+	MaxDepth         int
+	FollowSymlinks   bool
+	Sort             string
+	Columns          string
+	ShowTitles       bool
 	ShowLineNumbers  bool
-	ShowOutline      bool   `env:"GLOW_SHOW_OUTLINE"`
+	ShowOutline      bool
+	OutlineWidth     string
+	OutlineDepth     int
 	Gopath           string `env:"GOPATH"`
 	HomeDir          string `env:"HOME"`
 	GlamourMaxWidth  uint
-	GlamourStyle     string `env:"GLAMOUR_STYLE"`
+	GlamourStyle     string
 	EnableMouse      bool
 	PreserveNewLines bool
 
+	// Render each document as wide as its tables and code, between
+	// utils.MinAutoWidth and the width of the pager
+	AutoWidth bool
+
+	// Lines kept visible above a heading jumped to; nil uses the default
+	ScrollOff *int
+
+	// How mermaid diagrams are shown: "off", "ascii" or "image". The TUI
+	// shows images as text.
+	MermaidMode mermaid.Mode
+
+	// External program that renders mermaid diagrams, e.g. "mmdc"
+	MermaidCommand string
+
+	// Directory rendered diagrams are cached in; empty disables the cache
+	DiagramCacheDir string
+
+	// Directory rendered documents are cached in; empty disables the cache
+	RenderCacheDir string
+
+	// How long a diagram may take to render; 0 uses the default
+	MermaidTimeout time.Duration
+
+	// Complexity limits for diagrams; nil uses the defaults
+	MermaidLimits *mermaid.Limits
+
+	// Render diagrams however complex they are
+	MermaidForce bool
+
+	// Draw diagrams with plain ASCII instead of box-drawing characters
+	MermaidPlain bool
+
+	// Number rendered diagrams with captions below them
+	Figures bool
+
+	// How tables wider than the page are laid out: "auto", "shrink",
+	// "records" or "off"; a document's front matter may set its own
+	Tables tables.Strategy
+
+	// Re-indent JSON code blocks written on one line
+	FormatJSON bool
+
+	// Color the keys of JSON and YAML code blocks by depth, and their values
+	// by type
+	HighlightData bool
+
+	// Replace shortcodes such as :rocket: with their emoji
+	Emoji bool
+
+	// List the numbered diagrams in the outline sidebar
+	OutlineFigures bool
+
+	// File the favorites pinned in the file listing are kept in; empty
+	// doesn't keep them
+	FavoritesFile string
+
+	// File the tags assigned in the file listing are kept in; empty doesn't
+	// keep them
+	TagsFile string
+
+	// File the files opened from the listing are counted in, to list those
+	// opened lately first; empty doesn't keep them
+	HistoryFile string
+
+	// Saves the order chosen in the file listing; nil doesn't save it
+	SaveSort func(sort string) error
+
+	// Programs that render diagrams of other languages, by code fence
+	// language, over mermaid.DefaultCommands; an empty command disables one
+	DiagramCommands map[string]string
+
+	// Programs that code blocks are piped through, by code fence language
+	CodeFilters map[string]mermaid.Filter
+
+	// Colors for the TUI chrome
+	Theme Theme
+
+	// Keys of the pager's actions, by action name, in place of the defaults
+	Keys map[string][]string
+
+	// Language documents are shown as code of, over the one their extension
+	// gives, e.g. "python"; "markdown" renders them as markdown
+	Language string
+
 	// Working directory or file path
 	Path string
 
+	// Where the files of the document shown start, if it's made of several
+	// joined together
+	Files []utils.JoinedFile
+
+	// Files opened as tabs of the pager, in place of Path
+	Tabs []string
+
+	// Document read as it arrives, such as a pipe to stdin; nil if there's
+	// none
+	Stream io.Reader
+
+	// Flags given on the command line, by name, such as "width"; the style,
+	// width and mermaid mode they give override documents' front matter
+	Flags map[string]bool
+
 	// For debugging the UI
 	HighPerformancePager bool `env:"GLOW_HIGH_PERFORMANCE_PAGER" envDefault:"true"`
 	GlamourEnabled       bool `env:"GLOW_ENABLE_GLAMOUR"         envDefault:"true"`
 }
+
+// documentOptions returns the render options of a document's front matter
+// without those that flags given on the command line override.
+func (cfg Config) documentOptions(options utils.RenderOptions) utils.RenderOptions {
+	if cfg.Flags["style"] {
+		options.Style = ""
+	}
+	if cfg.Flags["width"] {
+		options.Width = 0
+	}
+	if cfg.Flags["mermaid"] {
+		options.Mermaid = ""
+	}
+	return options
+}
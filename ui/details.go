@@ -0,0 +1,46 @@
+package ui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hholst80/glow/htmlblocks"
+)
+
+// toggleDetailsMsg folds the nth <details> section of the document if it's
+// open, and opens it if it's folded.
+type toggleDetailsMsg int
+
+// detailsOpen reports whether the nth <details> section of the document is
+// shown open. Sections are folded to begin with, unless they're marked open.
+func (m pagerModel) detailsOpen(n int, d htmlblocks.Details) bool {
+	return d.Open != m.toggledDetails[n]
+}
+
+// detailsHints returns the hints folding and unfolding the <details> sections
+// on screen.
+func (m pagerModel) detailsHints() []hint {
+	details := htmlblocks.Find(m.currentDocument.Body)
+	summaries := make([]string, len(details))
+	for i, d := range details {
+		marker := htmlblocks.FoldedMarker
+		if m.detailsOpen(i, d) {
+			marker = htmlblocks.OpenMarker
+		}
+		summaries[i] = marker + " " + d.Summary
+	}
+	var hints []hint
+	for _, i := range m.onScreen(summaries) {
+		hints = append(hints, hint{hintKeys[len(hints) : len(hints)+1], details[i].Summary, toggleDetailsMsg(i)})
+	}
+	return hints
+}
+
+// toggleDetails folds or unfolds the nth <details> section, and renders the
+// document again.
+func (m *pagerModel) toggleDetails(n int) tea.Cmd {
+	if m.toggledDetails == nil {
+		m.toggledDetails = map[int]bool{}
+	}
+	m.toggledDetails[n] = !m.toggledDetails[n]
+	m.anchorScroll()
+	return renderWithGlamour(*m, m.currentDocument.Body)
+}
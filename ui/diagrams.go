@@ -0,0 +1,191 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hholst80/glow/mermaid"
+)
+
+// diagramPanStep is the number of columns the diagram view scrolls sideways.
+const diagramPanStep = 4
+
+// diagramRenderer returns the renderer for mermaid diagrams: the configured
+// external command, or the ASCII renderer, cached if a cache is configured.
+func diagramRenderer(cfg Config) mermaid.Renderer {
+	ascii := mermaid.NewRenderer()
+	if cfg.MermaidLimits != nil {
+		ascii.SetLimits(*cfg.MermaidLimits)
+	}
+	if cfg.MermaidForce {
+		ascii.Force()
+	}
+	if cfg.MermaidPlain {
+		ascii.Plain()
+	}
+
+	var r mermaid.Renderer = ascii
+	if cfg.MermaidCommand != "" {
+		r = mermaid.NewCommandRenderer(cfg.MermaidCommand)
+	}
+	if cfg.DiagramCacheDir != "" {
+		r = mermaid.NewCachedRenderer(r, cfg.DiagramCacheDir)
+	}
+	return r
+}
+
+// diagramImages returns the renderer of mermaid diagrams as images, if the
+// terminal can show them in the pager and no command renders diagrams
+// instead, or nil.
+func diagramImages(cfg Config, protocol mermaid.ImageProtocol) mermaid.Renderer {
+	if cfg.MermaidCommand != "" {
+		return nil
+	}
+	images := mermaid.NewImageRenderer(protocol)
+	images.FitCells()
+	if !images.Available() {
+		return nil
+	}
+	if cfg.DiagramCacheDir != "" {
+		return mermaid.NewCachedRenderer(images, cfg.DiagramCacheDir)
+	}
+	return images
+}
+
+// diagramLanguages returns the renderers of diagram languages other than
+// mermaid, such as d2, whose programs are installed.
+func diagramLanguages(cfg Config) *mermaid.Registry {
+	languages := mermaid.NewRegistry()
+	languages.RegisterCommands(cfg.DiagramCommands, cfg.DiagramCacheDir)
+	return languages
+}
+
+// codeFilters returns the filters of code blocks whose programs are
+// installed.
+func codeFilters(cfg Config) *mermaid.Registry {
+	filters := mermaid.NewRegistry()
+	filters.RegisterFilters(cfg.CodeFilters)
+	return filters
+}
+
+// diagramTimeout returns how long a diagram may take to render: the
+// configured timeout, or a default that leaves external commands time to run.
+func diagramTimeout(cfg Config) time.Duration {
+	switch {
+	case cfg.MermaidTimeout > 0:
+		return cfg.MermaidTimeout
+	case cfg.MermaidCommand != "":
+		return mermaid.DefaultCommandTimeout
+	}
+	return mermaid.DefaultRenderTimeout
+}
+
+// imageTimeout returns how long a diagram may take to render as an image:
+// the configured timeout, or a default that leaves the mermaid CLI time to
+// run.
+func imageTimeout(cfg Config) time.Duration {
+	if cfg.MermaidTimeout > 0 {
+		return cfg.MermaidTimeout
+	}
+	return mermaid.DefaultCommandTimeout
+}
+
+// capturesKeys reports whether the pager handles every key itself, such as
+// while typing a search or viewing a diagram.
+func (m pagerModel) capturesKeys() bool {
+	switch m.state { //nolint:exhaustive
+	case pagerStateSearch, pagerStateDiagramList, pagerStateDiagram:
+		return true
+	}
+	return false
+}
+
+// openDiagramList lists the mermaid diagrams of the current document.
+func (m *pagerModel) openDiagramList() tea.Cmd {
+	m.diagrams = mermaid.ExtractDiagrams(m.currentDocument.Body)
+	if len(m.diagrams) == 0 {
+		return m.showStatusMessage(pagerStatusMessage{"No diagrams in this document", false})
+	}
+	m.diagramCursor = min(m.diagramCursor, len(m.diagrams)-1)
+	m.state = pagerStateDiagramList
+	return nil
+}
+
+// openDiagram shows the selected diagram full screen, rendered without a
+// width limit.
+func (m *pagerModel) openDiagram() {
+	cfg := m.common.cfg
+	out, err := mermaid.RenderTimeout(diagramRenderer(cfg), diagramTimeout(cfg), m.diagrams[m.diagramCursor], 0, cfg.GlamourStyle)
+	if err != nil {
+		out = fmt.Sprintf("Unable to render diagram: %v\n\n%s", err, m.diagrams[m.diagramCursor])
+	}
+
+	vp := viewport.New(m.common.width, max(0, m.common.height-statusBarHeight))
+	vp.SetHorizontalStep(diagramPanStep)
+	vp.SetContent(out)
+	m.diagramViewport = vp
+	m.state = pagerStateDiagram
+}
+
+// updateDiagrams handles key presses in the diagram list and diagram view.
+// Leaving returns to the document where it was left.
+func (m pagerModel) updateDiagrams(msg tea.KeyMsg) (pagerModel, tea.Cmd) {
+	switch msg.String() {
+	case "q", keyEsc:
+		if m.state == pagerStateDiagram {
+			m.state = pagerStateDiagramList
+		} else {
+			m.state = pagerStateBrowse
+		}
+		return m, nil
+	}
+
+	if m.state == pagerStateDiagram {
+		var cmd tea.Cmd
+		m.diagramViewport, cmd = m.diagramViewport.Update(msg)
+		return m, cmd
+	}
+
+	switch msg.String() {
+	case "j", "down":
+		m.diagramCursor = min(m.diagramCursor+1, len(m.diagrams)-1)
+	case "k", "up":
+		m.diagramCursor = max(m.diagramCursor-1, 0)
+	case keyEnter:
+		m.openDiagram()
+	}
+	return m, nil
+}
+
+// diagramTitle describes a diagram by its first line, e.g. "graph LR".
+func diagramTitle(source string) string {
+	title, _, _ := strings.Cut(source, "\n")
+	return strings.TrimSpace(title)
+}
+
+// diagramsView renders the diagram list or the selected diagram.
+func (m pagerModel) diagramsView() string {
+	if m.state == pagerStateDiagram {
+		return m.diagramViewport.View()
+	}
+
+	var b strings.Builder
+	b.WriteString("\n  Diagrams\n\n")
+	for i, source := range m.diagrams {
+		line := fmt.Sprintf("%d. %s", i+1, diagramTitle(source))
+		if i == m.diagramCursor {
+			b.WriteString("  " + fuchsiaFg("> "+line) + "\n")
+		} else {
+			b.WriteString("    " + line + "\n")
+		}
+	}
+	b.WriteString("\n  " + grayFg("enter: open • esc: back"))
+
+	// Fill the content area so the status bar stays at the bottom
+	lines := strings.Count(b.String(), "\n") + 1
+	b.WriteString(strings.Repeat("\n", max(0, m.viewport.Height-lines)))
+	return b.String()
+}
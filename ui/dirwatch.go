@@ -0,0 +1,166 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+	"github.com/fsnotify/fsnotify"
+	"github.com/muesli/gitcha"
+)
+
+type (
+	localFileCreatedMsg gitcha.SearchResult
+	localFileRemovedMsg string
+)
+
+// dirWatcher watches the directories of the file listing, so files created,
+// removed and renamed while Glow runs are shown without scanning again.
+type dirWatcher struct {
+	watcher *fsnotify.Watcher
+	scanner *scanner
+	root    string
+
+	// Files found in a new directory that are yet to be reported
+	pending []gitcha.SearchResult
+}
+
+// newDirWatcher returns a watcher of the files scanned in dir with opts. The
+// directories the scan walks are to be passed to watch.
+func newDirWatcher(dir string, opts scanOptions) (*dirWatcher, error) {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &dirWatcher{watcher: watcher, root: root}
+	opts.walked = w.watch
+	w.scanner = newScanner(root, opts, func(res gitcha.SearchResult) {
+		w.pending = append(w.pending, res)
+	})
+	return w, nil
+}
+
+// watch adds a directory to the watcher.
+func (w *dirWatcher) watch(dir string) {
+	if err := w.watcher.Add(dir); err != nil {
+		log.Debug("unable to watch directory", "dir", dir, "error", err)
+	}
+}
+
+func (w *dirWatcher) close() {
+	if err := w.watcher.Close(); err != nil {
+		log.Debug("unable to close directory watcher", "error", err)
+	}
+}
+
+// next waits for a markdown file to be created or removed.
+func (w *dirWatcher) next() tea.Msg {
+	for {
+		if len(w.pending) > 0 {
+			res := w.pending[0]
+			w.pending = w.pending[1:]
+			return localFileCreatedMsg(res)
+		}
+
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return nil
+			}
+			log.Debug("fsnotify event", "file", event.Name, "event", event.Op)
+			if msg := w.handle(event); msg != nil {
+				return msg
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Debug("fsnotify error", "error", err)
+		}
+	}
+}
+
+// handle returns the message for an event, if it's about a markdown file.
+// The files in new directories are queued, and the directories watched.
+func (w *dirWatcher) handle(event fsnotify.Event) tea.Msg {
+	path := event.Name
+	switch {
+	case event.Has(fsnotify.Create):
+		stat := os.Lstat
+		if w.scanner.followSymlinks {
+			stat = os.Stat
+		}
+		info, err := stat(path)
+		if err != nil || w.scanner.ignored(path, info.IsDir()) {
+			return nil
+		}
+		if !info.IsDir() {
+			if !isMarkdownFile(path) {
+				return nil
+			}
+			return localFileCreatedMsg{Path: path, Info: info}
+		}
+		if depth := w.depth(path); w.scanner.maxDepth <= 0 || depth <= w.scanner.maxDepth {
+			w.scanner.walk(path, depth)
+		}
+
+	case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+		// Editors often save by replacing a file, which is still there
+		if _, err := os.Lstat(path); err == nil {
+			return nil
+		}
+		return localFileRemovedMsg(path)
+	}
+	return nil
+}
+
+// depth returns how deep the entries of dir are, as the scanner counts.
+func (w *dirWatcher) depth(dir string) int {
+	rel, err := filepath.Rel(w.root, dir)
+	if err != nil {
+		return 1
+	}
+	return strings.Count(rel, string(os.PathSeparator)) + 2
+}
+
+// addLocalFile lists a file that was created, or updates it if it's listed.
+func (m *stashModel) addLocalFile(res gitcha.SearchResult) tea.Cmd {
+	for _, md := range m.markdowns {
+		if md.localPath == res.Path {
+			m.statFile(md)
+			return nil
+		}
+	}
+
+	md := localFileToMarkdown(m.common.cwd, res)
+	m.statFile(md)
+	m.addMarkdowns(md)
+	if m.filterApplied() {
+		md.buildFilterValue()
+	}
+	cmds := []tea.Cmd{loadTags([]*markdown{md})}
+	if m.needsHeadings() {
+		cmds = append(cmds, loadHeadings([]*markdown{md}))
+	}
+	if m.shouldUpdateFilter() {
+		cmds = append(cmds, filterMarkdowns(*m))
+	}
+	return tea.Batch(cmds...)
+}
+
+// removeLocalPath takes a removed file, or the files of a removed directory,
+// off the listing. Unlike deleting a file, this keeps it a favorite, should
+// it come back.
+func (m *stashModel) removeLocalPath(path string) {
+	prefix := path + string(os.PathSeparator)
+	m.removeMarkdowns(func(md *markdown) bool {
+		return md.localPath != "" && (md.localPath == path || strings.HasPrefix(md.localPath, prefix))
+	})
+}
@@ -0,0 +1,116 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/muesli/gitcha"
+)
+
+func TestDirWatcher(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := scanOptions{ignorePatterns: []string{".*"}}
+	w, err := newDirWatcher(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.close()
+	opts.walked = w.watch
+	ch, err := findMarkdownFiles(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range ch {
+	}
+
+	next := func() tea.Msg {
+		t.Helper()
+		msgs := make(chan tea.Msg, 1)
+		go func() { msgs <- w.next() }()
+		select {
+		case msg := <-msgs:
+			return msg
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for a file event")
+			return nil
+		}
+	}
+	write := func(name string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("# "+name), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// Only markdown files that aren't ignored are reported
+	write("notes.txt")
+	write(".hidden.md")
+	path := write("README.md")
+	if msg, ok := next().(localFileCreatedMsg); !ok || msg.Path != path {
+		t.Fatalf("Expected %s to be created, got %+v", path, msg)
+	}
+
+	// The files of new directories are found, and the directories watched
+	if err := os.MkdirAll(filepath.Join(dir, "docs"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	sub := write(filepath.Join("docs", "a.md"))
+	if msg, ok := next().(localFileCreatedMsg); !ok || msg.Path != sub {
+		t.Fatalf("Expected %s to be created, got %+v", sub, msg)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		// Writing to the new file may be reported too
+		msg := next()
+		if _, ok := msg.(localFileCreatedMsg); ok {
+			continue
+		}
+		if msg != localFileRemovedMsg(path) {
+			t.Fatalf("Expected %s to be removed, got %+v", path, msg)
+		}
+		break
+	}
+}
+
+func TestStashLocalFileChanges(t *testing.T) {
+	dir := t.TempDir()
+	initSections()
+	m := newStashModel(&commonModel{width: 80, height: 40, cwd: dir})
+	add := func(name string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("# "+name), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		info, _ := os.Stat(path)
+		m.addLocalFile(gitcha.SearchResult{Path: path, Info: info})
+	}
+
+	add("a.md")
+	add("docs/b.md")
+	add("docs/c.md")
+	add("a.md")
+	if len(m.markdowns) != 3 {
+		t.Fatalf("Expected 3 files without duplicates, got %d", len(m.markdowns))
+	}
+
+	// Removing a directory removes its files
+	m.removeLocalPath(filepath.Join(dir, "docs"))
+	if len(m.markdowns) != 1 || m.markdowns[0].Note != "a.md" {
+		t.Fatalf("Expected only a.md to be left, got %d files", len(m.markdowns))
+	}
+}
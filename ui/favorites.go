@@ -0,0 +1,150 @@
+package ui
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+)
+
+const pinnedIcon = "★ "
+
+// foundFavoritesMsg holds the favorites the scan of the working directory
+// didn't find.
+type foundFavoritesMsg []*markdown
+
+// loadFavorites reads the paths of the favorite files, one per line. A
+// missing file has no favorites.
+func loadFavorites(file string) (map[string]bool, error) {
+	favorites := make(map[string]bool)
+	if file == "" {
+		return favorites, nil
+	}
+	f, err := os.Open(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return favorites, nil
+	}
+	if err != nil {
+		return favorites, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if path := strings.TrimSpace(scanner.Text()); path != "" {
+			favorites[path] = true
+		}
+	}
+	return favorites, scanner.Err()
+}
+
+// saveFavorites writes the paths of the favorite files, sorted.
+func saveFavorites(file string, favorites map[string]bool) error {
+	if file == "" {
+		return nil
+	}
+	paths := make([]string, 0, len(favorites))
+	for path, ok := range favorites {
+		if ok {
+			paths = append(paths, path)
+		}
+	}
+	slices.Sort(paths)
+
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return err
+	}
+	var content string
+	if len(paths) > 0 {
+		content = strings.Join(paths, "\n") + "\n"
+	}
+	return os.WriteFile(file, []byte(content), 0o600)
+}
+
+// findFavorites looks up the favorites that aren't among the listed files,
+// such as those in other directories, so they're listed too.
+func findFavorites(favorites map[string]bool, mds []*markdown, cwd string) tea.Cmd {
+	listed := make(map[string]bool, len(mds))
+	for _, md := range mds {
+		listed[md.localPath] = true
+	}
+	var missing []string
+	for path, ok := range favorites {
+		if ok && !listed[path] {
+			missing = append(missing, path)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return func() tea.Msg {
+		var found []*markdown
+		for _, path := range missing {
+			info, err := os.Stat(path)
+			if err != nil || info.IsDir() {
+				log.Debug("favorite not found", "path", path, "error", err)
+				continue
+			}
+			found = append(found, &markdown{
+				localPath: path,
+				Note:      stripAbsolutePath(path, cwd),
+				Modtime:   info.ModTime(),
+				Size:      info.Size(),
+				pinned:    true,
+			})
+		}
+		return foundFavoritesMsg(found)
+	}
+}
+
+// togglePinned pins the selected file to the top of the listing, or unpins
+// it, and saves the favorites.
+func (m *stashModel) togglePinned() tea.Cmd {
+	md := m.selectedMarkdown()
+	if md == nil {
+		return nil
+	}
+	md.pinned = !md.pinned
+	m.favorites[md.localPath] = md.pinned
+	if !md.pinned {
+		delete(m.favorites, md.localPath)
+	}
+	if err := saveFavorites(m.common.cfg.FavoritesFile, m.favorites); err != nil {
+		log.Error("unable to save favorites", "error", err)
+		return m.newStatusMessage(statusMessage{errorStatusMessage, "Couldn’t save favorites"})
+	}
+
+	if !m.filterApplied() {
+		sortMarkdowns(m.markdowns, m.sort)
+		m.selectMarkdown(md)
+	}
+	return nil
+}
+
+// selectMarkdown moves the cursor to md, if it's shown.
+func (m *stashModel) selectMarkdown(md *markdown) {
+	i := slices.Index(m.getVisibleMarkdowns(), md)
+	if i < 0 {
+		return
+	}
+	perPage := max(1, m.paginator().PerPage)
+	m.paginator().Page = i / perPage
+	m.setCursor(i % perPage)
+}
+
+// pinnedCount returns the number of pinned files in the listing.
+func (m stashModel) pinnedCount() int {
+	var n int
+	for _, md := range m.markdowns {
+		if md.pinned {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,98 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestFavorites(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "glow", "favorites")
+	favorites, err := loadFavorites(file)
+	if err != nil || len(favorites) != 0 {
+		t.Fatalf("loadFavorites() of a missing file = %v, %v", favorites, err)
+	}
+
+	favorites["/notes/b.md"] = true
+	favorites["/notes/a.md"] = true
+	favorites["/notes/c.md"] = false
+	if err := saveFavorites(file, favorites); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/notes/a.md\n/notes/b.md\n"; string(data) != want {
+		t.Errorf("saved favorites = %q, want %q", data, want)
+	}
+
+	loaded, err := loadFavorites(file)
+	if err != nil || len(loaded) != 2 || !loaded["/notes/a.md"] || !loaded["/notes/b.md"] {
+		t.Errorf("loadFavorites() = %v, %v", loaded, err)
+	}
+}
+
+func TestTogglePinned(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "favorites")
+	initSections()
+	m := newStashModel(&commonModel{width: 80, height: 40, cfg: Config{FavoritesFile: file}})
+	a := &markdown{Note: "a.md", localPath: "/docs/a.md"}
+	b := &markdown{Note: "b.md", localPath: "/docs/b.md"}
+	m.addMarkdowns(a, b)
+
+	// Pinning b moves it to the top, keeping it selected
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyDown})
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("p")})
+	if !b.pinned || m.markdowns[0] != b || m.selectedMarkdown() != b {
+		t.Fatalf("Expected b to be pinned first and selected, got %v", m.markdowns)
+	}
+	if favorites, _ := loadFavorites(file); !favorites["/docs/b.md"] {
+		t.Errorf("Expected b to be saved as a favorite, got %v", favorites)
+	}
+
+	// Favorites are pinned when they're listed again
+	m = newStashModel(&commonModel{width: 80, height: 40, cfg: Config{FavoritesFile: file}})
+	b = &markdown{Note: "b.md", localPath: "/docs/b.md"}
+	m.addMarkdowns(&markdown{Note: "a.md", localPath: "/docs/a.md"}, b)
+	if !b.pinned || m.markdowns[0] != b {
+		t.Fatal("Expected the saved favorite to be pinned")
+	}
+
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("p")})
+	if b.pinned || m.markdowns[1] != b {
+		t.Error("Expected b to be unpinned")
+	}
+	if favorites, _ := loadFavorites(file); len(favorites) != 0 {
+		t.Errorf("Expected no favorites, got %v", favorites)
+	}
+}
+
+func TestFindFavorites(t *testing.T) {
+	dir := t.TempDir()
+	elsewhere := filepath.Join(dir, "elsewhere.md")
+	if err := os.WriteFile(elsewhere, []byte("# Elsewhere"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	listed := &markdown{localPath: filepath.Join(dir, "listed.md")}
+	favorites := map[string]bool{
+		elsewhere:          true,
+		listed.localPath:   true,
+		"/missing/gone.md": true,
+	}
+
+	cmd := findFavorites(favorites, []*markdown{listed}, "/")
+	if cmd == nil {
+		t.Fatal("Expected the unlisted favorites to be looked up")
+	}
+	found, ok := cmd().(foundFavoritesMsg)
+	if !ok || len(found) != 1 || found[0].localPath != elsewhere || !found[0].pinned {
+		t.Errorf("findFavorites() = %v", found)
+	}
+
+	if cmd := findFavorites(map[string]bool{listed.localPath: true}, []*markdown{listed}, "/"); cmd != nil {
+		t.Error("Expected no lookup when every favorite is listed")
+	}
+}
@@ -0,0 +1,253 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+)
+
+// fileOp is a file operation of the listing that's waiting for input.
+type fileOp int
+
+const (
+	noFileOp fileOp = iota
+	creatingFile
+	renamingFile
+	deletingFile
+)
+
+// fileOpsModel is the prompt of the file operations: creating, renaming and
+// deleting files.
+type fileOpsModel struct {
+	op     fileOp
+	target *markdown // file renamed or deleted
+	input  textinput.Model
+
+	// File created, which is updated once it's been edited
+	created *markdown
+}
+
+func newFileOpsModel() fileOpsModel {
+	ti := textinput.New()
+	ti.PromptStyle = stashInputPromptStyle
+	ti.Cursor.Style = stashInputCursorStyle
+	return fileOpsModel{input: ti}
+}
+
+// startFileOp opens the prompt of a file operation on target, which is nil
+// when creating a file.
+func (m *stashModel) startFileOp(op fileOp, target *markdown) tea.Cmd {
+	m.fileOps.op = op
+	m.fileOps.target = target
+	m.fileOps.input.Reset()
+	switch op {
+	case creatingFile:
+		m.fileOps.input.Prompt = "New file:"
+	case renamingFile:
+		m.fileOps.input.Prompt = "Rename to:"
+		m.fileOps.input.SetValue(target.Note)
+	case deletingFile:
+		m.fileOps.input.Prompt = fmt.Sprintf("Delete %s? (y/N)", target.Note)
+	}
+	m.fileOps.input.Width = m.common.width - stashViewHorizontalPadding*2 - len(m.fileOps.input.Prompt)
+	m.fileOps.input.CursorEnd()
+	m.fileOps.input.Focus()
+	return textinput.Blink
+}
+
+// handleFileOp handles keys while the prompt of a file operation is open.
+func (m *stashModel) handleFileOp(msg tea.Msg) tea.Cmd {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return nil
+	}
+
+	op := m.fileOps.op
+	switch {
+	case key.String() == keyEsc:
+		m.endFileOp()
+		return nil
+	case op == deletingFile:
+		target := m.fileOps.target
+		m.endFileOp()
+		if key.String() != "y" && key.String() != "Y" {
+			return nil
+		}
+		return m.deleteFile(target)
+	case key.String() == keyEnter:
+		value := strings.TrimSpace(m.fileOps.input.Value())
+		target := m.fileOps.target
+		m.endFileOp()
+		if value == "" {
+			return nil
+		}
+		if op == creatingFile {
+			return m.createFile(value)
+		}
+		return m.renameFile(target, value)
+	}
+
+	var cmd tea.Cmd
+	m.fileOps.input, cmd = m.fileOps.input.Update(msg)
+	return cmd
+}
+
+func (m *stashModel) endFileOp() {
+	m.fileOps.op = noFileOp
+	m.fileOps.target = nil
+	m.fileOps.input.Blur()
+}
+
+// filePath returns the absolute path of a name typed in the prompt, relative
+// to the listing's directory. Names without an extension get ".md".
+func (m stashModel) filePath(name string) string {
+	if filepath.Ext(name) == "" {
+		name += ".md"
+	}
+	if filepath.IsAbs(name) {
+		return filepath.Clean(name)
+	}
+	return filepath.Join(m.common.cwd, name)
+}
+
+// createFile creates a markdown file titled after its name, lists it and
+// opens it in the editor.
+func (m *stashModel) createFile(name string) tea.Cmd {
+	path := m.filePath(name)
+	if _, err := os.Stat(path); err == nil {
+		return m.newStatusMessage(statusMessage{errorStatusMessage, "File already exists"})
+	}
+
+	title := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	err := os.MkdirAll(filepath.Dir(path), 0o755) //nolint:gosec
+	if err == nil {
+		err = os.WriteFile(path, []byte("# "+title+"\n"), 0o644) //nolint:gosec
+	}
+	if err != nil {
+		log.Error("unable to create file", "path", path, "error", err)
+		return m.newStatusMessage(statusMessage{errorStatusMessage, "Couldn’t create file"})
+	}
+
+	md := &markdown{localPath: path, Note: stripAbsolutePath(path, m.common.cwd)}
+	m.statFile(md)
+	m.addMarkdowns(md)
+	m.selectMarkdown(md)
+	m.fileOps.created = md
+	return openEditor(path, 0)
+}
+
+// renameFile moves a file, keeping it pinned and tagged.
+func (m *stashModel) renameFile(md *markdown, name string) tea.Cmd {
+	path := m.filePath(name)
+	if path == md.localPath {
+		return nil
+	}
+	if _, err := os.Stat(path); err == nil {
+		return m.newStatusMessage(statusMessage{errorStatusMessage, "File already exists"})
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0o755) //nolint:gosec
+	if err == nil {
+		err = os.Rename(md.localPath, path)
+	}
+	if err != nil {
+		log.Error("unable to rename file", "from", md.localPath, "to", path, "error", err)
+		return m.newStatusMessage(statusMessage{errorStatusMessage, "Couldn’t rename file"})
+	}
+
+	old := md.localPath
+	md.localPath = path
+	md.Note = stripAbsolutePath(path, m.common.cwd)
+	md.buildFilterValue()
+	m.movePathData(old, path)
+
+	if !m.filterApplied() {
+		sortMarkdowns(m.markdowns, m.sort)
+		m.selectMarkdown(md)
+	}
+	return m.newStatusMessage(statusMessage{normalStatusMessage, "Renamed " + md.Note})
+}
+
+// deleteFile removes a file and takes it off the listing.
+func (m *stashModel) deleteFile(md *markdown) tea.Cmd {
+	if err := os.Remove(md.localPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Error("unable to delete file", "path", md.localPath, "error", err)
+		return m.newStatusMessage(statusMessage{errorStatusMessage, "Couldn’t delete file"})
+	}
+
+	m.removeMarkdowns(func(other *markdown) bool { return other == md })
+	m.movePathData(md.localPath, "")
+	return m.newStatusMessage(statusMessage{normalStatusMessage, "Deleted " + md.Note})
+}
+
+// removeMarkdowns takes the files matching remove off the listing.
+func (m *stashModel) removeMarkdowns(remove func(*markdown) bool) {
+	for _, md := range m.markdowns {
+		if remove(md) {
+			delete(m.textSearch.index, md)
+		}
+	}
+	m.markdowns = slices.DeleteFunc(m.markdowns, remove)
+	m.filteredMarkdowns = slices.DeleteFunc(m.filteredMarkdowns, remove)
+	m.updateRecent()
+
+	m.updatePagination()
+	itemsOnPage := m.paginator().ItemsOnPage(len(m.getVisibleMarkdowns()))
+	m.setCursor(max(0, min(m.cursor(), itemsOnPage-1)))
+}
+
+// movePathData moves the favorite, tags and history of a renamed file to its
+// new path, or drops them if the file was deleted and to is empty.
+func (m *stashModel) movePathData(from, to string) {
+	if m.favorites[from] {
+		delete(m.favorites, from)
+		if to != "" {
+			m.favorites[to] = true
+		}
+		if err := saveFavorites(m.common.cfg.FavoritesFile, m.favorites); err != nil {
+			log.Error("unable to save favorites", "error", err)
+		}
+	}
+	if tags, ok := m.tags.user[from]; ok {
+		delete(m.tags.user, from)
+		if to != "" {
+			m.tags.user[to] = tags
+		}
+		if err := saveUserTags(m.common.cfg.TagsFile, m.tags.user); err != nil {
+			log.Error("unable to save tags", "error", err)
+		}
+	}
+	if r, ok := m.history[from]; ok {
+		delete(m.history, from)
+		if to != "" {
+			m.history[to] = r
+		}
+		if err := saveHistory(m.common.cfg.HistoryFile, m.history); err != nil {
+			log.Error("unable to save history", "error", err)
+		}
+	}
+}
+
+// statFile updates the size and modification time of a file and its entry
+// in the search index.
+func (m *stashModel) statFile(md *markdown) {
+	info, err := os.Stat(md.localPath)
+	if err != nil {
+		return
+	}
+	md.Size, md.Modtime = info.Size(), info.ModTime()
+	if m.textSearch.index == nil {
+		return
+	}
+	if data, err := os.ReadFile(md.localPath); err == nil {
+		m.textSearch.index[md] = newIndexedDocument(string(data))
+	}
+}
@@ -0,0 +1,68 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestFileOps(t *testing.T) {
+	dir := t.TempDir()
+	favorites := filepath.Join(t.TempDir(), "favorites")
+	initSections()
+	m := newStashModel(&commonModel{width: 80, height: 40, cwd: dir, cfg: Config{FavoritesFile: favorites}})
+	typeKeys := func(s string) {
+		t.Helper()
+		m, _ = m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)})
+	}
+
+	// Creating a file adds ".md" and a title, and opens the editor
+	typeKeys("n")
+	if !m.capturesKeys() {
+		t.Fatal("Expected n to open the new file prompt")
+	}
+	typeKeys("notes/todo")
+	var cmd tea.Cmd
+	m, cmd = m.update(tea.KeyMsg{Type: tea.KeyEnter})
+	path := filepath.Join(dir, "notes", "todo.md")
+	if data, err := os.ReadFile(path); err != nil || string(data) != "# todo\n" {
+		t.Fatalf("Expected a new file with a title, got %q, %v", data, err)
+	}
+	md := m.selectedMarkdown()
+	if cmd == nil || md == nil || md.localPath != path || md.Note != filepath.Join("notes", "todo.md") {
+		t.Fatalf("Expected the new file to be listed, selected and edited, got %+v", md)
+	}
+
+	// Renaming the file keeps it pinned
+	typeKeys("p")
+	typeKeys("R")
+	for range len("todo.md") {
+		m, _ = m.update(tea.KeyMsg{Type: tea.KeyBackspace})
+	}
+	typeKeys("done.md")
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyEnter})
+	renamed := filepath.Join(dir, "notes", "done.md")
+	if _, err := os.Stat(renamed); err != nil || md.localPath != renamed {
+		t.Fatalf("Expected the file to be renamed, got %q, %v", md.localPath, err)
+	}
+	if saved, _ := loadFavorites(favorites); !saved[renamed] || saved[path] {
+		t.Errorf("Expected the favorite to follow the rename, got %v", saved)
+	}
+
+	// Deleting asks first
+	typeKeys("D")
+	typeKeys("n")
+	if _, err := os.Stat(renamed); err != nil || len(m.markdowns) != 1 {
+		t.Fatal("Expected n to keep the file")
+	}
+	typeKeys("D")
+	typeKeys("y")
+	if _, err := os.Stat(renamed); !os.IsNotExist(err) || len(m.markdowns) != 0 {
+		t.Fatal("Expected y to delete the file")
+	}
+	if saved, _ := loadFavorites(favorites); len(saved) != 0 {
+		t.Errorf("Expected the favorite to be dropped, got %v", saved)
+	}
+}
@@ -0,0 +1,105 @@
+package ui
+
+import (
+	"io"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hholst80/glow/utils"
+)
+
+// finderReadLimit is how much of each file the finder reads looking for its
+// first heading.
+const finderReadLimit = 16 * 1024
+
+// headingsLoadedMsg holds the first headings and titles of the files the
+// finder searches.
+type headingsLoadedMsg map[*markdown]fileHeadings
+
+// fileHeadings are the first heading and the title of a file.
+type fileHeadings struct {
+	heading, title string
+}
+
+// startFinder opens the ctrl+p finder, which narrows the file listing by
+// path and first heading as the user types and opens the chosen file.
+func (m *stashModel) startFinder() tea.Cmd {
+	m.finding = true
+	m.filterInput.Prompt = "Open:"
+	m.setSize(m.common.width, m.common.height)
+	return tea.Batch(m.startFiltering(), loadHeadings(m.markdowns))
+}
+
+// handleFinding handles keys while the finder is open. Unlike the filter,
+// the arrow keys choose among the matches and enter opens the chosen one.
+// It reports whether the key was handled.
+func (m *stashModel) handleFinding(msg tea.KeyMsg) (tea.Cmd, bool) {
+	switch msg.String() {
+	case "ctrl+k", "ctrl+p", "up":
+		m.moveCursorUp()
+	case "ctrl+j", "ctrl+n", "down":
+		m.moveCursorDown()
+	case keyEnter:
+		md := m.selectedMarkdown()
+		m.resetFiltering()
+		if md == nil {
+			return nil, true
+		}
+		return m.openMarkdown(md), true
+	default:
+		return nil, false
+	}
+	return nil, true
+}
+
+// loadHeadings reads the first heading and title of each file that hasn't
+// had them read.
+func loadHeadings(mds []*markdown) tea.Cmd {
+	var pending []*markdown
+	for _, md := range mds {
+		if !md.headingLoaded {
+			pending = append(pending, md)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	return func() tea.Msg {
+		headings := make(headingsLoadedMsg, len(pending))
+		for _, md := range pending {
+			headings[md] = readHeadings(md.localPath)
+		}
+		return headings
+	}
+}
+
+// readHeadings returns the first heading of a file and its title: its front
+// matter title, if it has one, is both, or else its first heading and first
+// level 1 heading are. They're "" if it has none or can't be read.
+func readHeadings(path string) fileHeadings {
+	f, err := os.Open(path)
+	if err != nil {
+		return fileHeadings{}
+	}
+	defer f.Close() //nolint:errcheck
+
+	data, err := io.ReadAll(io.LimitReader(f, finderReadLimit))
+	if err != nil {
+		return fileHeadings{}
+	}
+	data = utils.DocumentContent(path, data)
+	if title := utils.FrontmatterTitle(data); title != "" {
+		return fileHeadings{title, title}
+	}
+	var h fileHeadings
+	for _, heading := range parseHeadings(string(utils.RemoveFrontmatter(data))) {
+		if h.heading == "" {
+			h.heading = heading.Text
+		}
+		if heading.Level == 1 {
+			h.title = heading.Text
+			break
+		}
+	}
+	return h
+}
@@ -0,0 +1,77 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestReadHeadings(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+		want    fileHeadings
+	}{
+		{"heading", "Intro text\n\n## Setup\n\n# Later", fileHeadings{"Setup", "Later"}},
+		{"no title", "## Setup\n\n### Steps", fileHeadings{"Setup", ""}},
+		{"front matter title", "---\ntitle: Notes\n---\n# Heading", fileHeadings{"Notes", "Notes"}},
+		{"front matter comment", "---\n# not a heading\ndraft: true\n---\n# Heading", fileHeadings{"Heading", "Heading"}},
+		{"heading in code", "```\n# comment\n```\n# Heading", fileHeadings{"Heading", "Heading"}},
+		{"no heading", "Just text", fileHeadings{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".md")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if got := readHeadings(path); got != tt.want {
+				t.Errorf("readHeadings() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if got := readHeadings(filepath.Join(dir, "missing.md")); got != (fileHeadings{}) {
+		t.Errorf("readHeadings() of a missing file = %+v", got)
+	}
+}
+
+func TestFinder(t *testing.T) {
+	initSections()
+	m := newStashModel(&commonModel{width: 80, height: 40})
+	a := &markdown{Note: "notes/a.md", localPath: "a.md"}
+	b := &markdown{Note: "notes/b.md", localPath: "b.md"}
+	m.addMarkdowns(a, b)
+
+	if cmd := m.startFinder(); cmd == nil {
+		t.Fatal("Expected the finder to load headings")
+	}
+	if !m.finding || m.filterState != filtering || m.filterInput.Prompt != "Open:" {
+		t.Fatalf("Expected the finder to be open, got finding=%v state=%v", m.finding, m.filterState)
+	}
+
+	// Headings are matched along with paths
+	m, _ = m.update(headingsLoadedMsg{a: {heading: "Alpha"}, b: {heading: "Beta"}})
+	if b.filterValue != "notes/b.md Beta" || !b.headingLoaded {
+		t.Errorf("filterValue = %q", b.filterValue)
+	}
+	if cmd := loadHeadings(m.markdowns); cmd != nil {
+		t.Error("Expected loaded headings not to be read again")
+	}
+
+	// The arrow keys choose a file and enter opens it
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyDown})
+	if got := m.selectedMarkdown(); got != b {
+		t.Fatalf("Expected b to be chosen, got %v", got)
+	}
+	m, cmd := m.update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil || m.viewState != stashStateLoadingDocument {
+		t.Error("Expected enter to open the chosen file")
+	}
+	if m.finding || m.filterState != unfiltered || m.filterInput.Prompt != "Find:" {
+		t.Errorf("Expected the finder to close, got finding=%v state=%v", m.finding, m.filterState)
+	}
+}
@@ -0,0 +1,259 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/muesli/reflow/truncate"
+)
+
+// searchIndexMaxSize is the size above which files aren't indexed for the
+// full-text search.
+const searchIndexMaxSize = 1 << 20
+
+// indexedDocument is the content of a file the full-text search looks in.
+type indexedDocument struct {
+	lines    []string
+	lower    []string // lines in lower case, which queries are matched against
+	headings []Heading
+	words    int
+}
+
+// searchIndex holds the contents of the listed files, read when the scan
+// finishes.
+type searchIndex map[*markdown]indexedDocument
+
+type searchIndexMsg searchIndex
+
+// searchHit is a line of a file that contains the query.
+type searchHit struct {
+	md      *markdown
+	heading string // heading of the section the line is in, if any
+	line    int    // line in the file, 0-indexed
+	text    string
+	nth     int // index of the hit among those in the same section
+}
+
+// openSearchHitMsg opens the file of a hit in the pager, searching for query
+// and scrolled to the hit.
+type openSearchHitMsg struct {
+	searchHit
+	query string
+}
+
+// textSearchModel is the ctrl+f search through the contents of the listed
+// files.
+type textSearchModel struct {
+	active bool
+	input  textinput.Model
+	index  searchIndex
+	hits   []searchHit
+	cursor int
+}
+
+func newTextSearchModel() textSearchModel {
+	si := textinput.New()
+	si.Prompt = "Search:"
+	si.PromptStyle = stashInputPromptStyle
+	si.Cursor.Style = stashInputCursorStyle
+	return textSearchModel{input: si}
+}
+
+// buildSearchIndex reads the files of the listing into a search index.
+func buildSearchIndex(mds []*markdown) tea.Cmd {
+	mds = append([]*markdown(nil), mds...)
+	return func() tea.Msg {
+		index := make(searchIndex, len(mds))
+		for _, md := range mds {
+			if md.Size > searchIndexMaxSize {
+				continue
+			}
+			data, err := os.ReadFile(md.localPath)
+			if err != nil {
+				continue
+			}
+			index[md] = newIndexedDocument(string(data))
+		}
+		return searchIndexMsg(index)
+	}
+}
+
+func newIndexedDocument(content string) indexedDocument {
+	lines := strings.Split(content, "\n")
+	lower := make([]string, len(lines))
+	for i, line := range lines {
+		lower[i] = strings.ToLower(line)
+	}
+	return indexedDocument{
+		lines:    lines,
+		lower:    lower,
+		headings: parseHeadings(content),
+		words:    countWords(content),
+	}
+}
+
+// countWords counts the words of content, leaving out markup such as the #
+// of headings.
+func countWords(content string) int {
+	var n int
+	for _, field := range strings.Fields(content) {
+		if strings.IndexFunc(field, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// search returns the lines containing query, ignoring case, grouped by file
+// in the order of mds and by section within each file.
+func (index searchIndex) search(mds []*markdown, query string) []searchHit {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+
+	var hits []searchHit
+	for _, md := range mds {
+		doc, ok := index[md]
+		if !ok {
+			continue
+		}
+		heading, next, nth := "", 0, 0
+		for i, line := range doc.lower {
+			for next < len(doc.headings) && doc.headings[next].Line <= i {
+				heading, nth = doc.headings[next].Text, 0
+				next++
+			}
+			if !strings.Contains(line, query) {
+				continue
+			}
+			hits = append(hits, searchHit{
+				md:      md,
+				heading: heading,
+				line:    i,
+				text:    strings.TrimSpace(doc.lines[i]),
+				nth:     nth,
+			})
+			nth++
+		}
+	}
+	return hits
+}
+
+// startTextSearch opens the full-text search.
+func (m *stashModel) startTextSearch() tea.Cmd {
+	m.textSearch.active = true
+	m.textSearch.input.Width = m.common.width - stashViewHorizontalPadding*2 -
+		len(m.textSearch.input.Prompt)
+	m.textSearch.input.CursorEnd()
+	m.textSearch.input.Focus()
+	m.updateTextSearch()
+	return textinput.Blink
+}
+
+// updateTextSearch searches the index for the query typed.
+func (m *stashModel) updateTextSearch() {
+	m.textSearch.hits = m.textSearch.index.search(m.markdowns, m.textSearch.input.Value())
+	m.textSearch.cursor = min(m.textSearch.cursor, max(0, len(m.textSearch.hits)-1))
+}
+
+// handleTextSearch handles keys while the full-text search is open: the
+// arrow keys choose a hit and enter opens its file at the hit.
+func (m *stashModel) handleTextSearch(msg tea.Msg) tea.Cmd {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case keyEsc:
+			m.textSearch.active = false
+			m.textSearch.input.Blur()
+			return nil
+		case "ctrl+k", "ctrl+p", "up":
+			m.textSearch.cursor = max(0, m.textSearch.cursor-1)
+			return nil
+		case "ctrl+j", "ctrl+n", "down":
+			m.textSearch.cursor = min(len(m.textSearch.hits)-1, m.textSearch.cursor+1)
+			m.textSearch.cursor = max(0, m.textSearch.cursor)
+			return nil
+		case keyEnter:
+			if len(m.textSearch.hits) == 0 {
+				return nil
+			}
+			hit := openSearchHitMsg{
+				searchHit: m.textSearch.hits[m.textSearch.cursor],
+				query:     strings.TrimSpace(m.textSearch.input.Value()),
+			}
+			m.textSearch.active = false
+			m.textSearch.input.Blur()
+			return func() tea.Msg { return hit }
+		}
+	}
+
+	value := m.textSearch.input.Value()
+	var cmd tea.Cmd
+	m.textSearch.input, cmd = m.textSearch.input.Update(msg)
+	if m.textSearch.input.Value() != value {
+		m.textSearch.cursor = 0
+		m.updateTextSearch()
+	}
+	return cmd
+}
+
+// textSearchHeaderView summarizes the hits of the full-text search.
+func (m stashModel) textSearchHeaderView() string {
+	switch {
+	case m.textSearch.index == nil:
+		return grayFg("Indexing files...")
+	case m.textSearch.input.Value() == "":
+		return grayFg(fmt.Sprintf("%d files indexed", len(m.textSearch.index)))
+	}
+	files := make(map[*markdown]struct{})
+	for _, hit := range m.textSearch.hits {
+		files[hit.md] = struct{}{}
+	}
+	return grayFg(fmt.Sprintf("%d matches in %d files", len(m.textSearch.hits), len(files)))
+}
+
+// textSearchView lists the hits of the full-text search under their files
+// and headings, scrolled to keep the chosen hit in view.
+func (m stashModel) textSearchView() string {
+	if len(m.textSearch.hits) == 0 {
+		if m.textSearch.input.Value() != "" && m.textSearch.index != nil {
+			return "  " + grayFg("Nothing found.")
+		}
+		return ""
+	}
+
+	truncateTo := uint(max(0, m.common.width-stashViewHorizontalPadding*2)) //nolint:gosec
+	var lines []string
+	selected := 0
+	for i, hit := range m.textSearch.hits {
+		newFile := i == 0 || hit.md != m.textSearch.hits[i-1].md
+		if newFile {
+			if i > 0 {
+				lines = append(lines, "")
+			}
+			lines = append(lines, "  "+greenFg(fileListingStashIcon)+
+				truncate.StringWithTail(hit.md.Note, truncateTo, ellipsis))
+		}
+		if hit.heading != "" && (newFile || hit.heading != m.textSearch.hits[i-1].heading) {
+			lines = append(lines, "    "+grayFg(truncate.StringWithTail(hit.heading, truncateTo, ellipsis)))
+		}
+
+		text := truncate.StringWithTail(fmt.Sprintf("%d: %s", hit.line+1, hit.text), truncateTo, ellipsis)
+		if i == m.textSearch.cursor {
+			selected = len(lines)
+			lines = append(lines, dullFuchsiaFg(verticalLine)+"     "+fuchsiaFg(text))
+		} else {
+			lines = append(lines, "      "+dimNormalFg(text))
+		}
+	}
+
+	// Show as many lines as the file listing would, around the chosen hit
+	height := max(1, m.paginator().PerPage*stashViewItemHeight-1)
+	start := max(0, min(selected-height/2, len(lines)-height))
+	end := min(len(lines), start+height)
+	return strings.Join(lines[start:end], "\n")
+}
@@ -0,0 +1,127 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hholst80/glow/sourcemap"
+)
+
+const searchTestDocument = `# Guide
+
+Install the tool.
+
+## Setup
+
+Run the installer.
+Then install plugins.
+
+## Usage
+
+Nothing to see here.`
+
+func TestSearchIndex(t *testing.T) {
+	a := &markdown{Note: "a.md"}
+	b := &markdown{Note: "b.md"}
+	index := searchIndex{
+		a: newIndexedDocument(searchTestDocument),
+		b: newIndexedDocument("install me"),
+	}
+
+	hits := index.search([]*markdown{b, a}, "  INSTALL ")
+	want := []searchHit{
+		{md: b, line: 0, text: "install me"},
+		{md: a, heading: "Guide", line: 2, text: "Install the tool."},
+		{md: a, heading: "Setup", line: 6, text: "Run the installer."},
+		{md: a, heading: "Setup", line: 7, text: "Then install plugins.", nth: 1},
+	}
+	if len(hits) != len(want) {
+		t.Fatalf("search() = %d hits, want %d: %+v", len(hits), len(want), hits)
+	}
+	for i := range want {
+		if hits[i] != want[i] {
+			t.Errorf("hit %d = %+v, want %+v", i, hits[i], want[i])
+		}
+	}
+
+	if hits := index.search([]*markdown{a, b}, " "); hits != nil {
+		t.Errorf("search() of an empty query = %v", hits)
+	}
+}
+
+func TestTextSearch(t *testing.T) {
+	initSections()
+	m := newStashModel(&commonModel{width: 80, height: 40})
+	a := &markdown{Note: "a.md"}
+	m.addMarkdowns(a)
+
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyCtrlF})
+	if !m.textSearch.active {
+		t.Fatal("Expected ctrl+f to open the search")
+	}
+	if !strings.Contains(m.headerView(), "Indexing") {
+		t.Errorf("Expected the header to show the index is being built, got %q", m.headerView())
+	}
+
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("install")})
+	m, _ = m.update(searchIndexMsg{a: newIndexedDocument(searchTestDocument)})
+	if len(m.textSearch.hits) != 3 {
+		t.Fatalf("Expected 3 hits once indexed, got %d", len(m.textSearch.hits))
+	}
+	view := stripANSI(m.populatedView())
+	for _, s := range []string{"a.md", "Setup", "7: Run the installer."} {
+		if !strings.Contains(view, s) {
+			t.Errorf("Expected the results to contain %q:\n%s", s, view)
+		}
+	}
+
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyDown})
+	m, cmd := m.update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.textSearch.active {
+		t.Error("Expected enter to close the search")
+	}
+	if cmd == nil {
+		t.Fatal("Expected enter to open the hit")
+	}
+	hit, ok := cmd().(openSearchHitMsg)
+	if !ok || hit.md != a || hit.line != 6 || hit.query != "install" {
+		t.Errorf("Expected the second hit to open, got %+v", hit)
+	}
+}
+
+func TestJumpToSearchHit(t *testing.T) {
+	m := newTestPagerModel()
+	lines := make([]string, 60)
+	for i := range lines {
+		lines[i] = "line"
+	}
+	lines[0] = "# First"
+	lines[3] = "needle"
+	lines[20] = "## Second"
+	lines[25] = "needle"
+	lines[30] = "needle"
+	body := strings.Join(lines, "\n")
+	m.currentDocument.Body = body
+
+	m.pendingHit = &openSearchHitMsg{searchHit: searchHit{line: 30, nth: 1}, query: "needle"}
+	m, _ = m.update(contentRenderedMsg{content: body, sourceMap: sourcemap.Map{{Source: 0, Rendered: 0}, {Source: 20, Rendered: 20}}})
+
+	if m.pendingHit != nil {
+		t.Error("Expected the hit to be consumed")
+	}
+	if m.searchQuery != "needle" || m.searchIndex != 2 {
+		t.Errorf("Expected match 2 of %q, got %d of %q", "needle", m.searchIndex, m.searchQuery)
+	}
+	if m.viewport.YOffset != 30-scrollOff {
+		t.Errorf("Expected YOffset=%d, got %d", 30-scrollOff, m.viewport.YOffset)
+	}
+
+	// The lines of hits count the front matter of the file, which the body
+	// doesn't have
+	m.currentDocument.bodyLine = 20
+	m.jumpToSearchHit(openSearchHitMsg{searchHit: searchHit{line: 23}, query: "needle"})
+	if m.searchIndex != 0 {
+		t.Errorf("Expected the match of the first section, got %d", m.searchIndex)
+	}
+}
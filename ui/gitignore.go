@@ -0,0 +1,163 @@
+package ui
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/hholst80/glow/utils"
+	"github.com/muesli/gitcha"
+	ignore "github.com/sabhiram/go-gitignore"
+)
+
+// gitIgnore holds the patterns of an ignore file and the directory they're
+// relative to.
+type gitIgnore struct {
+	dir      string
+	patterns *ignore.GitIgnore
+}
+
+// ignores reports whether path, a directory if dir is set, matches the
+// patterns.
+func (g gitIgnore) ignores(path string, dir bool) bool {
+	rel, err := filepath.Rel(g.dir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	if dir {
+		rel += "/"
+	}
+	return g.patterns.MatchesPath(rel)
+}
+
+// gitIgnores are the ignore files that apply to the directories of a git
+// repository: the global excludes file, the repository's info/exclude and
+// the .gitignore of each directory and its parents.
+type gitIgnores struct {
+	root string
+	dirs map[string][]gitIgnore
+}
+
+// newGitIgnores returns the ignore files of the repository path is in, or nil
+// if it isn't in one.
+func newGitIgnores(path string) *gitIgnores {
+	root, err := gitcha.GitRepoForPath(path)
+	if err != nil || root == "" {
+		return nil
+	}
+
+	var base []gitIgnore
+	for _, file := range []string{globalExcludesFile(), filepath.Join(root, ".git", "info", "exclude")} {
+		if g, ok := loadGitIgnore(file, root); ok {
+			base = append(base, g)
+		}
+	}
+	return &gitIgnores{
+		root: root,
+		dirs: map[string][]gitIgnore{filepath.Dir(root): base},
+	}
+}
+
+// forDir returns the ignore files that apply to the entries of dir.
+func (g *gitIgnores) forDir(dir string) []gitIgnore {
+	if ignores, ok := g.dirs[dir]; ok {
+		return ignores
+	}
+	var ignores []gitIgnore
+	if parent := filepath.Dir(dir); parent != dir {
+		ignores = g.forDir(parent)
+	}
+	if gi, ok := loadGitIgnore(filepath.Join(dir, ".gitignore"), dir); ok {
+		ignores = append(ignores[:len(ignores):len(ignores)], gi)
+	}
+	g.dirs[dir] = ignores
+	return ignores
+}
+
+// ignores reports whether git ignores path, a directory if dir is set.
+func (g *gitIgnores) ignores(path string, dir bool) bool {
+	if dir && filepath.Base(path) == ".git" {
+		return true
+	}
+	for _, gi := range g.forDir(filepath.Dir(path)) {
+		if gi.ignores(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadGitIgnore reads the ignore file at path, whose patterns are relative to
+// dir. It reports false if there's no such file.
+func loadGitIgnore(path, dir string) (gitIgnore, bool) {
+	if path == "" {
+		return gitIgnore{}, false
+	}
+	patterns, err := ignore.CompileIgnoreFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Debug("unable to read ignore file", "path", path, "error", err)
+		}
+		return gitIgnore{}, false
+	}
+	return gitIgnore{dir: dir, patterns: patterns}, true
+}
+
+// globalExcludesFile returns the path of git's global excludes file: the
+// core.excludesFile of the user's git config, ~/.gitconfig taking precedence
+// over git/config in the XDG config directory, or else git/ignore in the XDG
+// config directory.
+func globalExcludesFile() string {
+	home, _ := os.UserHomeDir()
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" && home != "" {
+		configDir = filepath.Join(home, ".config")
+	}
+
+	var configs []string
+	if home != "" {
+		configs = append(configs, filepath.Join(home, ".gitconfig"))
+	}
+	if configDir != "" {
+		configs = append(configs, filepath.Join(configDir, "git", "config"))
+	}
+	for _, config := range configs {
+		if file := gitConfigExcludesFile(config); file != "" {
+			return utils.ExpandPath(file)
+		}
+	}
+
+	if configDir == "" {
+		return ""
+	}
+	return filepath.Join(configDir, "git", "ignore")
+}
+
+// gitConfigExcludesFile returns the core.excludesFile setting of a git config
+// file, or "" if it has none.
+func gitConfigExcludesFile(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close() //nolint:errcheck
+
+	var section, file string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			section = strings.ToLower(strings.Trim(line, "[] "))
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != "core" || !strings.EqualFold(strings.TrimSpace(key), "excludesfile") {
+			continue
+		}
+		file = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return file
+}
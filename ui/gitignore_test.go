@@ -0,0 +1,150 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFindMarkdownFiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+
+	repo := t.TempDir()
+	files := map[string]string{
+		".git/info/exclude":      "local.md\n",
+		".gitignore":             "node_modules/\nbuild/\n*.tmp.md\n",
+		"README.md":              "",
+		"notes.tmp.md":           "",
+		"local.md":               "",
+		"global.md":              "",
+		"build/out.md":           "",
+		"node_modules/pkg/a.md":  "",
+		"docs/guide.md":          "",
+		"docs/.gitignore":        "drafts/\n",
+		"docs/drafts/wip.md":     "",
+		"docs/api/reference.md":  "",
+		".hidden/secret.md":      "",
+		"docs/api/reference.txt": "",
+	}
+	for name, content := range files {
+		path := filepath.Join(repo, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	globalIgnore := filepath.Join(home, ".config", "git", "ignore")
+	if err := os.MkdirAll(filepath.Dir(globalIgnore), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(globalIgnore, []byte("global.md\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	find := func(dir string, patterns []string, respectGitIgnore bool) []string {
+		t.Helper()
+		ch, err := findMarkdownFiles(dir, scanOptions{ignorePatterns: patterns, respectGitIgnore: respectGitIgnore})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found []string
+		for res := range ch {
+			rel, _ := filepath.Rel(dir, res.Path)
+			found = append(found, filepath.ToSlash(rel))
+		}
+		slices.Sort(found)
+		return found
+	}
+
+	tests := []struct {
+		name             string
+		dir              string
+		patterns         []string
+		respectGitIgnore bool
+		want             []string
+	}{
+		{
+			name:             "respecting git",
+			dir:              repo,
+			patterns:         []string{".*"},
+			respectGitIgnore: true,
+			want:             []string{"README.md", "docs/api/reference.md", "docs/guide.md"},
+		},
+		{
+			name:             "subdirectory",
+			dir:              filepath.Join(repo, "docs"),
+			respectGitIgnore: true,
+			want:             []string{"api/reference.md", "guide.md"},
+		},
+		{
+			name:     "no ignore",
+			dir:      repo,
+			patterns: []string{".*", "node_modules"},
+			want: []string{
+				"README.md", "build/out.md", "docs/api/reference.md", "docs/drafts/wip.md",
+				"docs/guide.md", "global.md", "local.md", "notes.tmp.md",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := find(tt.dir, tt.patterns, tt.respectGitIgnore); !slices.Equal(got, tt.want) {
+				t.Errorf("findMarkdownFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitConfigExcludesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".gitconfig")
+	config := "[user]\n\texcludesfile = wrong\n[core]\n\teditor = vim\n\texcludesFile = \"~/.excludes\"\n"
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got := gitConfigExcludesFile(path); got != "~/.excludes" {
+		t.Errorf("gitConfigExcludesFile() = %q, want %q", got, "~/.excludes")
+	}
+}
+
+func TestGlobalExcludesFile(t *testing.T) {
+	home, xdg := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+
+	// git/ignore in the XDG config directory is the default
+	if got, want := globalExcludesFile(), filepath.Join(xdg, "git", "ignore"); got != want {
+		t.Errorf("globalExcludesFile() = %q, want %q", got, want)
+	}
+
+	// The XDG git config is read, and ~/.gitconfig over it
+	if err := os.MkdirAll(filepath.Join(xdg, "git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		path, want string
+	}{
+		{filepath.Join(xdg, "git", "config"), "/xdg-excludes"},
+		{filepath.Join(home, ".gitconfig"), "/home-excludes"},
+	} {
+		if err := os.WriteFile(tt.path, []byte("[core]\n\texcludesFile = "+tt.want+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if got := globalExcludesFile(); got != tt.want {
+			t.Errorf("globalExcludesFile() with %s = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestShowAllFilesIgnoresGitIgnore(t *testing.T) {
+	if opts := newScanOptions(Config{ShowAllFiles: true}); opts.respectGitIgnore {
+		t.Error("Expected --all to list the files git ignores")
+	}
+	if opts := newScanOptions(Config{}); !opts.respectGitIgnore {
+		t.Error("Expected the files git ignores to be skipped by default")
+	}
+}
@@ -0,0 +1,115 @@
+package ui
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// globFilter narrows the file listing to the files matching a set of globs.
+// A file is shown if it matches any of the patterns, or there are only
+// negated ones, and matches none of the patterns negated with "!".
+type globFilter struct {
+	include []string
+	exclude []string
+}
+
+// parseGlobFilter parses the space separated patterns of the glob prompt.
+// Patterns without a slash match file names anywhere in the tree, "**"
+// matches any number of directories, and a bare extension such as ".txt" is
+// short for "*.txt".
+func parseGlobFilter(s string) globFilter {
+	var f globFilter
+	for _, pattern := range strings.Fields(s) {
+		exclude := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+		if pattern == "" {
+			continue
+		}
+		if strings.HasPrefix(pattern, ".") && !strings.ContainsAny(pattern, "/*?[") {
+			pattern = "*" + pattern
+		}
+		if exclude {
+			f.exclude = append(f.exclude, pattern)
+		} else {
+			f.include = append(f.include, pattern)
+		}
+	}
+	return f
+}
+
+// match reports whether the file at name, relative to the listing's
+// directory, passes the filter.
+func (f globFilter) match(name string) bool {
+	name = strings.TrimPrefix(filepath.ToSlash(name), "./")
+	for _, pattern := range f.exclude {
+		if matchGlob(pattern, name) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, pattern := range f.include {
+		if matchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob reports whether a slash separated path matches pattern. A
+// pattern without a slash is matched against the file name alone.
+func matchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// matchSegments matches the directories and file name of a path against those
+// of a pattern, where a "**" segment matches zero or more of them.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// startGlobbing opens the glob prompt, which narrows the file listing to the
+// files matching the patterns typed, keeping their order.
+func (m *stashModel) startGlobbing() tea.Cmd {
+	m.globbing = true
+	m.filterInput.Prompt = "Glob:"
+	m.setSize(m.common.width, m.common.height)
+	return m.startFiltering()
+}
+
+// globMarkdowns returns the files matching the glob prompt.
+func globMarkdowns(mds []*markdown, patterns string) []*markdown {
+	f := parseGlobFilter(patterns)
+	filtered := []*markdown{}
+	for _, md := range mds {
+		if f.match(md.Note) {
+			filtered = append(filtered, md)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,79 @@
+package ui
+
+import (
+	"slices"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestGlobFilter(t *testing.T) {
+	tests := []struct {
+		patterns string
+		name     string
+		want     bool
+	}{
+		{"", "README.md", true},
+		{"*.md", "docs/guide/intro.md", true},
+		{"*.md", "notes.txt", false},
+		{".txt", "docs/notes.txt", true},
+		{"docs/*.md", "docs/intro.md", true},
+		{"docs/*.md", "docs/guide/intro.md", false},
+		{"docs/**/*.md", "docs/intro.md", true},
+		{"docs/**/*.md", "docs/guide/deep/intro.md", true},
+		{"docs/**/*.md", "src/intro.md", false},
+		{"**/drafts/*", "a/b/drafts/x.md", true},
+		{"!drafts/*", "drafts/wip.md", false},
+		{"!drafts/*", "docs/wip.md", true},
+		{"docs/** !docs/drafts/**", "docs/drafts/old/x.md", false},
+		{"docs/** !docs/drafts/**", "docs/intro.md", true},
+		{"*.txt README.md", "README.md", true},
+		{"docs/*.md", "./docs/intro.md", true},
+	}
+	for _, tt := range tests {
+		if got := parseGlobFilter(tt.patterns).match(tt.name); got != tt.want {
+			t.Errorf("%q matching %q = %v, want %v", tt.patterns, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGlobMarkdowns(t *testing.T) {
+	mds := []*markdown{
+		{Note: "README.md"},
+		{Note: "docs/b.md"},
+		{Note: "docs/drafts/a.md"},
+		{Note: "docs/a.md"},
+	}
+	var got []string
+	for _, md := range globMarkdowns(mds, "docs/** !drafts/*") {
+		got = append(got, md.Note)
+	}
+	// "drafts/*" has a slash, so it only matches a top level drafts directory
+	want := []string{"docs/b.md", "docs/drafts/a.md", "docs/a.md"}
+	if !slices.Equal(got, want) {
+		t.Errorf("globMarkdowns() = %v, want %v", got, want)
+	}
+}
+
+func TestGlobbing(t *testing.T) {
+	initSections()
+	m := newStashModel(&commonModel{width: 80, height: 40})
+	m.addMarkdowns(&markdown{Note: "a.md"}, &markdown{Note: "b.txt"}, &markdown{Note: "c.md"})
+
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("*")})
+	if !m.globbing || m.filterState != filtering || m.filterInput.Prompt != "Glob:" {
+		t.Fatalf("Expected the glob prompt to be open, got globbing=%v state=%v", m.globbing, m.filterState)
+	}
+
+	m.filterInput.SetValue(".md")
+	msg := filterMarkdowns(m)()
+	m, _ = m.update(msg)
+	if n := len(m.getVisibleMarkdowns()); n != 2 {
+		t.Fatalf("Expected 2 files to match, got %d", n)
+	}
+
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.globbing || m.filterState != unfiltered || m.filterInput.Prompt != "Find:" {
+		t.Errorf("Expected the glob prompt to close, got globbing=%v state=%v", m.globbing, m.filterState)
+	}
+}
@@ -0,0 +1,82 @@
+package ui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// hintKeys are the keys of the hints on screen, in the order they're in.
+const hintKeys = "asdfghjklqwertyuiopzxcvbnm"
+
+// hint is something on screen to pick by a key shown in the status bar, such
+// as a link to follow.
+type hint struct {
+	key   string
+	label string
+	msg   tea.Msg // sent when it's picked
+}
+
+// startHints shows the keys of hints in the status bar, or picks the only one
+// if pickOnly is set. With none, it shows none as the status.
+func (m *pagerModel) startHints(hints []hint, none string, pickOnly bool) tea.Cmd {
+	if len(hints) == 0 {
+		return m.showStatusMessage(pagerStatusMessage{none, false})
+	}
+	if pickOnly && len(hints) == 1 {
+		msg := hints[0].msg
+		return func() tea.Msg { return msg }
+	}
+	m.hints = hints
+	m.state = pagerStateHints
+	return nil
+}
+
+// updateHints handles key presses while the keys of hints are shown. Enter
+// picks the first, and any key but those of hints cancels.
+func (m pagerModel) updateHints(msg tea.KeyMsg) (pagerModel, tea.Cmd) {
+	m.state = pagerStateBrowse
+	key := msg.String()
+	if key == keyEnter {
+		key = m.hints[0].key
+	}
+	for _, h := range m.hints {
+		if h.key == key {
+			picked := h.msg
+			return m, func() tea.Msg { return picked }
+		}
+	}
+	return m, nil
+}
+
+// hintsStatus returns the status bar note listing the hints by their keys.
+func (m pagerModel) hintsStatus() string {
+	items := make([]string, len(m.hints))
+	for i, h := range m.hints {
+		items[i] = h.key + " " + h.label
+	}
+	return strings.Join(items, " · ")
+}
+
+// onScreen returns the indices of the texts found on screen, each looked for
+// in the rendered document after the one before it, up to one for each of
+// the hint keys.
+func (m pagerModel) onScreen(texts []string) []int {
+	lines := strings.Split(m.renderedContent, "\n")
+	top, bottom := m.viewport.YOffset, m.viewport.YOffset+m.viewport.Height
+	var found []int
+	from := 0
+	for t, text := range texts {
+		for i := from; i < len(lines); i++ {
+			if !strings.Contains(stripANSI(lines[i]), text) {
+				continue
+			}
+			from = i
+			if i >= top && i < bottom && len(found) < len(hintKeys) {
+				found = append(found, t)
+			}
+			break
+		}
+	}
+	return found
+}
@@ -3,10 +3,9 @@
 
 package ui
 
-func ignorePatterns(m commonModel) []string {
+func ignorePatterns(cfg Config) []string {
 	return []string{
-		m.cfg.Gopath,
+		cfg.Gopath,
 		"node_modules",
-		".*",
 	}
 }
@@ -0,0 +1,65 @@
+package ui
+
+import (
+	"os"
+
+	"github.com/hholst80/glow/utils"
+)
+
+const markedIcon = "✓ "
+
+// toggleMarked marks or unmarks the selected file to be opened along with
+// the other marked files, and moves on to the next one.
+func (m *stashModel) toggleMarked() {
+	md := m.selectedMarkdown()
+	if md == nil {
+		return
+	}
+	md.marked = !md.marked
+	m.moveCursorDown()
+}
+
+// clearMarked unmarks every file, reporting whether any were marked.
+func (m *stashModel) clearMarked() bool {
+	var cleared bool
+	for _, md := range m.markdowns {
+		cleared = cleared || md.marked
+		md.marked = false
+	}
+	return cleared
+}
+
+// markedMarkdowns returns the marked files, in the order they're listed.
+func (m stashModel) markedMarkdowns() []*markdown {
+	var mds []*markdown
+	for _, md := range m.markdowns {
+		if md.marked {
+			mds = append(mds, md)
+		}
+	}
+	return mds
+}
+
+// joinedMarkdown returns a document of the files joined together. Its path
+// is the first file's, which is the one edited and watched.
+func joinedMarkdown(mds []*markdown) *markdown {
+	return &markdown{
+		localPath: mds[0].localPath,
+		Note:      plural(len(mds), "file"),
+		parts:     mds,
+	}
+}
+
+// joinParts reads the files of a joined document into its body.
+func joinParts(md *markdown) error {
+	files := make([]utils.File, 0, len(md.parts))
+	for _, part := range md.parts {
+		data, err := os.ReadFile(part.localPath)
+		if err != nil {
+			return err
+		}
+		files = append(files, utils.File{Name: part.Note, Content: data})
+	}
+	md.Body, md.files = utils.JoinFiles(files)
+	return nil
+}
@@ -0,0 +1,82 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hholst80/glow/document"
+	"github.com/hholst80/glow/utils"
+)
+
+func TestJoinedMarkdown(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.md":    "---\ntitle: A\n---\n# Alpha\n\n## Usage\n",
+		"b.md":    "# Beta\n\n**📄 a.md**\n",
+		"main.go": "package main\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	initSections()
+	m := newStashModel(&commonModel{width: 80, height: 40, cwd: dir})
+	a := &markdown{Note: "a.md", localPath: filepath.Join(dir, "a.md")}
+	b := &markdown{Note: "b.md", localPath: filepath.Join(dir, "b.md")}
+	code := &markdown{Note: "main.go", localPath: filepath.Join(dir, "main.go")}
+	m.addMarkdowns(a, b, code)
+
+	// Space marks a file and moves on; esc clears the marks
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")})
+	if !a.marked || m.selectedMarkdown() != b {
+		t.Fatal("Expected space to mark a and select b")
+	}
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyEsc})
+	if a.marked {
+		t.Fatal("Expected esc to clear the marks")
+	}
+
+	// Marked files are joined in the order they're listed
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")})
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyUp})
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyUp})
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")})
+	marked := m.markedMarkdowns()
+	if !slices.Equal(marked, []*markdown{a, b}) {
+		t.Fatalf("markedMarkdowns() = %v", marked)
+	}
+	if header := stripANSI(m.headerView()); !strings.Contains(header, "2 marked") {
+		t.Errorf("Expected the header to count the marked files, got %q", header)
+	}
+
+	md := joinedMarkdown(append(marked, code))
+	if err := joinParts(md); err != nil {
+		t.Fatal(err)
+	}
+	want := "**📄 a.md**\n\n# Alpha\n\n## Usage\n\n---\n\n**📄 b.md**\n\n# Beta\n\n**📄 a.md**\n\n---\n\n" +
+		"**📄 main.go**\n\n```.go\npackage main\n```\n"
+	if md.Body != want {
+		t.Errorf("joined body = %q, want %q", md.Body, want)
+	}
+
+	// The files are roots of the outline, with their headings nested. A
+	// line of a file naming another isn't taken for the start of one.
+	wantFiles := []utils.JoinedFile{{Name: "a.md", Line: 0}, {Name: "b.md", Line: 8}, {Name: "main.go", Line: 16}}
+	if !slices.Equal(md.files, wantFiles) {
+		t.Errorf("joined files = %v, want %v", md.files, wantFiles)
+	}
+	var got []string
+	for _, h := range modelHeadings(document.Parse([]byte(md.Body)), md.files) {
+		got = append(got, strings.Repeat("  ", headingDepth(h, true))+h.Text)
+	}
+	wantOutline := []string{"a.md", "  Alpha", "    Usage", "b.md", "  Beta", "main.go"}
+	if !slices.Equal(got, wantOutline) {
+		t.Errorf("outline = %q, want %q", got, wantOutline)
+	}
+}
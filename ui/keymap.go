@@ -0,0 +1,147 @@
+package ui
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// action is something a key does in the pager.
+type action string
+
+// Actions of the pager that keys can be bound to.
+const (
+	noAction            action = ""
+	actionTop           action = "top"
+	actionBottom        action = "bottom"
+	actionHalfPageUp    action = "halfPageUp"
+	actionHalfPageDown  action = "halfPageDown"
+	actionCopy          action = "copy"
+	actionEdit          action = "edit"
+	actionReload        action = "reload"
+	actionHelp          action = "help"
+	actionToggleOutline action = "toggleOutline"
+	actionFocusOutline  action = "focusOutline"
+	actionNextHeading   action = "nextHeading"
+	actionPrevHeading   action = "prevHeading"
+	actionSearch        action = "search"
+	actionNextMatch     action = "nextMatch"
+	actionPrevMatch     action = "prevMatch"
+	actionPanLeft       action = "panLeft"
+	actionPanRight      action = "panRight"
+	actionDiagrams      action = "diagrams"
+	actionLanguage      action = "language"
+	actionNextTab       action = "nextTab"
+	actionPrevTab       action = "prevTab"
+	actionFollowLink    action = "followLink"
+	actionToggleDetails action = "toggleDetails"
+)
+
+// defaultPagerKeys are the keys of each action unless they're configured.
+var defaultPagerKeys = map[action][]string{
+	actionTop:           {"g", "home"},
+	actionBottom:        {"G", "end"},
+	actionHalfPageUp:    {"u"},
+	actionHalfPageDown:  {"d"},
+	actionCopy:          {"c"},
+	actionEdit:          {"e"},
+	actionReload:        {"r"},
+	actionHelp:          {"?"},
+	actionToggleOutline: {"o"},
+	actionFocusOutline:  {"tab"},
+	actionNextHeading:   {"]"},
+	actionPrevHeading:   {"["},
+	actionSearch:        {"/"},
+	actionNextMatch:     {"n"},
+	actionPrevMatch:     {"N"},
+	actionPanLeft:       {"<"},
+	actionPanRight:      {">"},
+	actionDiagrams:      {"D"},
+	actionLanguage:      {"L"},
+	actionNextTab:       {"}"},
+	actionPrevTab:       {"{"},
+	actionFollowLink:    {"F"},
+	actionToggleDetails: {"z"},
+}
+
+// keyMap holds the keys of the pager's actions, the defaults with those
+// configured in their place.
+type keyMap struct {
+	actions map[string]action   // by key
+	keys    map[action][]string // by action, in the order they're shown
+}
+
+// newKeyMap binds the configured keys, by action name, in place of their
+// defaults. Action names are matched regardless of case, since the config
+// file's keys are lowercased, and "space" stands for the space bar.
+func newKeyMap(custom map[string][]string) keyMap {
+	km := keyMap{
+		actions: make(map[string]action),
+		keys:    make(map[action][]string, len(defaultPagerKeys)),
+	}
+	configured := make(map[action]bool)
+	for a, keys := range defaultPagerKeys {
+		km.keys[a] = keys
+	}
+	for name, keys := range custom {
+		a := findAction(name)
+		if a == noAction {
+			log.Warn("unknown action in keys config", "action", name)
+			continue
+		}
+		bound := make([]string, 0, len(keys))
+		for _, k := range keys {
+			if strings.EqualFold(k, "space") {
+				k = " "
+			}
+			bound = append(bound, k)
+		}
+		km.keys[a] = bound
+		configured[a] = true
+	}
+
+	// Configured keys take over from the actions they're defaults of
+	for _, custom := range []bool{false, true} {
+		for a, keys := range km.keys {
+			if configured[a] == custom {
+				for _, k := range keys {
+					km.actions[k] = a
+				}
+			}
+		}
+	}
+	for a, keys := range km.keys {
+		km.keys[a] = slices.DeleteFunc(slices.Clone(keys), func(k string) bool { return km.actions[k] != a })
+	}
+	return km
+}
+
+// findAction returns the action named name, regardless of case.
+func findAction(name string) action {
+	for a := range defaultPagerKeys {
+		if strings.EqualFold(string(a), name) {
+			return a
+		}
+	}
+	return noAction
+}
+
+// action returns what a key does.
+func (km keyMap) action(key string) action {
+	return km.actions[key]
+}
+
+// help returns the keys of actions as the help shows them, e.g. "]/[".
+func (km keyMap) help(actions ...action) string {
+	var keys []string
+	for _, a := range actions {
+		for _, k := range km.keys[a] {
+			if k == " " {
+				k = "space"
+			}
+			keys = append(keys, k)
+		}
+	}
+	return strings.Join(keys, "/")
+}
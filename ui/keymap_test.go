@@ -0,0 +1,80 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestKeyMap(t *testing.T) {
+	// Config file keys are lowercased
+	km := newKeyMap(map[string][]string{
+		"toggleoutline": {"O"},
+		"nextHeading":   {"J", "space"},
+		"search":        {"n"},
+		"bogus":         {"x"},
+	})
+
+	tests := map[string]action{
+		"O": actionToggleOutline,
+		"o": noAction,
+		"J": actionNextHeading,
+		" ": actionNextHeading,
+		"]": noAction,
+		"n": actionSearch,
+		"/": noAction,
+		"N": actionPrevMatch,
+		"g": actionTop,
+		"x": noAction,
+	}
+	for key, want := range tests {
+		if got := km.action(key); got != want {
+			t.Errorf("action(%q) = %q, want %q", key, got, want)
+		}
+	}
+
+	// A key taken by another action isn't shown for its default one
+	if got := km.help(actionNextMatch, actionPrevMatch); got != "N" {
+		t.Errorf("Expected the help to show N for next/prev match, got %q", got)
+	}
+	if got := km.help(actionNextHeading); got != "J/space" {
+		t.Errorf("Expected the help to show J/space, got %q", got)
+	}
+}
+
+func TestPagerCustomKeys(t *testing.T) {
+	m := newTestPagerModel()
+	m.keys = newKeyMap(map[string][]string{"help": {"H"}})
+
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("?")})
+	if m.showHelp {
+		t.Fatal("Expected ? to no longer show the help")
+	}
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("H")})
+	if !m.showHelp {
+		t.Fatal("Expected H to show the help")
+	}
+	if !strings.Contains(m.helpView(), "g/home  go to top") {
+		t.Error("Expected the help to list the default keys")
+	}
+}
+
+// TestPagerBoundKeysDontScroll tests that keys bound to actions, such as j
+// bound to another action or d to half a page down, don't scroll the
+// viewport as well.
+func TestPagerBoundKeysDontScroll(t *testing.T) {
+	m := newTestPagerModel()
+	m.keys = newKeyMap(map[string][]string{"panRight": {"j"}})
+	m.setContent(strings.Repeat("line\n", 100))
+
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	want := m.viewport.Height / 2
+	if m.viewport.YOffset != want {
+		t.Errorf("Expected d to scroll half a page to line %d, got %d", want, m.viewport.YOffset)
+	}
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("j")})
+	if m.viewport.YOffset != want {
+		t.Errorf("Expected j to pan without scrolling, got line %d", m.viewport.YOffset)
+	}
+}
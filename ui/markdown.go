@@ -3,11 +3,14 @@ package ui
 import (
 	"fmt"
 	"math"
+	"path/filepath"
 	"time"
 	"unicode"
 
 	"github.com/charmbracelet/log"
 	"github.com/dustin/go-humanize"
+	"github.com/hholst80/glow/tables"
+	"github.com/hholst80/glow/utils"
 	"golang.org/x/text/runes"
 	"golang.org/x/text/transform"
 	"golang.org/x/text/unicode/norm"
@@ -23,20 +26,88 @@ type markdown struct {
 	// field is ephemeral, and should only be referenced during filtering.
 	filterValue string
 
-	Body    string
+	// Title from the document's front matter, if any.
+	Title string
+
+	// How the document's front matter asks for wide tables to be laid out,
+	// if it does.
+	tables tables.Strategy
+
+	// Render settings the document's front matter overrides
+	options utils.RenderOptions
+
+	// First heading of the document, or its front matter title, which the
+	// finder matches along with the path. It's read when the finder opens.
+	heading       string
+	headingLoaded bool
+
+	// Front matter title of the document, or else its first level 1
+	// heading, which titles shown in the listing label it by. It's read
+	// with heading.
+	title string
+
+	// Whether the file is a favorite, pinned to the top of the listing
+	pinned bool
+
+	// Rank of the file among those opened lately, listed after the pinned
+	// ones, from 1; 0 if it isn't one of them
+	recent int
+
+	// Tags from the document's front matter, read after the scan
+	tags       []string
+	tagsLoaded bool
+
+	// Whether the file is marked to be opened along with others
+	marked bool
+
+	// Files of a document joined from several, in the order they're shown
+	parts []*markdown
+
+	// Where each file of a joined document starts in its body
+	files []utils.JoinedFile
+
+	// Body of the document, without its front matter, which starts on line
+	// bodyLine of the file
+	Body     string
+	bodyLine int
+
 	Note    string
 	Modtime time.Time
+	Size    int64
 }
 
 // Generate the value we're doing to filter against.
 func (m *markdown) buildFilterValue() {
-	note, err := normalize(m.Note)
+	value := m.Note
+	if m.heading != "" {
+		value += " " + m.heading
+	}
+	normalized, err := normalize(value)
 	if err != nil {
 		log.Error("error normalizing", "note", m.Note, "error", err)
-		m.filterValue = m.Note
+		m.filterValue = value
+		return
 	}
 
-	m.filterValue = note
+	m.filterValue = normalized
+}
+
+// displayName returns the front matter title if there is one, otherwise the
+// note.
+func (m markdown) displayName() string {
+	if m.Title != "" {
+		return m.Title
+	}
+	return m.Note
+}
+
+// sortTitle returns what the file listing sorts by title: the first heading,
+// or the file name if the file has none or it hasn't been read.
+func (m markdown) sortTitle() string {
+	if m.heading != "" {
+		return m.heading
+	}
+	return filepath.Base(m.Note)
 }
 
 func (m markdown) relativeTime() string {
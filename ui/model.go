@@ -0,0 +1,117 @@
+package ui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hholst80/glow/document"
+	"github.com/hholst80/glow/wikilinks"
+)
+
+// parsedDocument is the structure of a version of the document shown, parsed
+// while it's rendered so the outline and the link hints don't parse it
+// again. It's shared by the copies of the pager.
+type parsedDocument struct {
+	body string
+	doc  *document.Document
+}
+
+// parsedModel returns the structure of md kept by the pager, or nil if it
+// has none.
+func (m pagerModel) parsedModel(md string) *parsedDocument {
+	if p := m.parsed; p != nil && p.doc != nil && p.body == md {
+		return &parsedDocument{body: p.body, doc: p.doc}
+	}
+	return nil
+}
+
+// renderedModel returns the structure of md for a render of it: the one
+// kept if there's one, or else doc, read by the render. Only markdown has
+// one.
+func renderedModel(m pagerModel, kept *parsedDocument, md string, doc document.Document) *parsedDocument {
+	if kept != nil || !m.isMarkdownFile() {
+		return kept
+	}
+	return &parsedDocument{body: md, doc: &doc}
+}
+
+// documentParsedMsg holds the structure of a long document, parsed once its
+// first chunk was shown.
+type documentParsedMsg struct {
+	parsed *parsedDocument
+}
+
+// parseDocumentModel parses the document shown off the UI's goroutine. Long
+// documents are parsed whole, since they're rendered a chunk at a time.
+func parseDocumentModel(m pagerModel) tea.Cmd {
+	body := m.currentDocument.Body
+	return func() tea.Msg {
+		doc := document.Parse([]byte(body))
+		return documentParsedMsg{&parsedDocument{body: body, doc: &doc}}
+	}
+}
+
+// documentParsed keeps the structure of a long document and shows its
+// headings in the outline. The document is rendered again if the outline
+// needs another width for them.
+func (m *pagerModel) documentParsed(msg documentParsedMsg) tea.Cmd {
+	if !m.parsing || msg.parsed.body != m.currentDocument.Body {
+		return nil
+	}
+	m.parsing = false
+	m.keepModel(msg.parsed)
+	m.updateOutline()
+
+	width := m.viewport.Width
+	m.setSize(m.common.width, m.common.height)
+	if m.viewport.Width != width {
+		return renderWithGlamour(*m, m.currentDocument.Body)
+	}
+	return nil
+}
+
+// updateOutline shows the headings of the document in the outline, mapped to
+// the lines they were rendered on.
+func (m *pagerModel) updateOutline() {
+	m.outline.setHeadings(m.headings(), m.currentDocument.Title)
+	m.outline.mapHeadings(m.sourceMap)
+	if m.common.cfg.OutlineFigures {
+		m.outline.addFigures(m.renderedContent, m.sourceMap.Fences(m.currentDocument.Body))
+	}
+}
+
+// keepModel keeps the structure of a rendered document.
+func (m *pagerModel) keepModel(p *parsedDocument) {
+	if p != nil && m.parsed != nil {
+		*m.parsed = *p
+	}
+}
+
+// documentModel returns the structure of the document shown, as its render
+// read it. It's parsed here if it wasn't rendered, and every time by a pager
+// without a parsedDocument. A long document has none until it's parsed after
+// being shown.
+func (m pagerModel) documentModel() document.Document {
+	if m.parsing {
+		return document.Document{}
+	}
+	body := m.currentDocument.Body
+	if p := m.parsedModel(body); p != nil {
+		return *p.doc
+	}
+	doc := document.Parse([]byte(body))
+	m.keepModel(&parsedDocument{body: body, doc: &doc})
+	return doc
+}
+
+// headings returns the headings of the document shown.
+func (m pagerModel) headings() []Heading {
+	return modelHeadings(m.documentModel(), m.currentDocument.files)
+}
+
+// wikiLinks returns the wiki links of the document shown, outside of code.
+func (m pagerModel) wikiLinks() []wikilinks.Link {
+	var links []wikilinks.Link
+	for _, l := range m.documentModel().WikiLinks {
+		links = append(links, wikilinks.Link{Target: l.Target, Heading: l.Heading, Label: l.Label})
+	}
+	return links
+}
@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hholst80/glow/document"
+	"github.com/hholst80/glow/sourcemap"
+	"github.com/hholst80/glow/wikilinks"
+)
+
+// modelRenderer is a TestMarkdownRenderer that renders markdown as is,
+// reading the structure of what it renders and counting its renders.
+type modelRenderer struct {
+	TestMarkdownRenderer
+	renders int
+}
+
+// RenderSourceMap implements SourceMapRenderer.
+func (r *modelRenderer) RenderSourceMap(ctx context.Context, markdown string, width int, style string, filename string, language string, preserveNewLines bool) (string, sourcemap.Map, error) {
+	out, sourceMap, _, err := r.RenderModel(ctx, markdown, width, style, filename, language, preserveNewLines)
+	return out, sourceMap, err
+}
+
+// RenderModel implements ModelRenderer.
+func (r *modelRenderer) RenderModel(_ context.Context, markdown string, _ int, _ string, _ string, _ string, _ bool) (string, sourcemap.Map, document.Document, error) {
+	r.renders++
+	out, sourceMap := sourcemap.Extract(markdown)
+	return out, sourceMap, parseMarked(markdown, false), nil
+}
+
+// TestPager_DocumentModel tests that the pager reads the headings and wiki
+// links of a document from the structure its render read, and parses new
+// versions of it that weren't rendered.
+func TestPager_DocumentModel(t *testing.T) {
+	m := newTestPagerModel()
+	r := &modelRenderer{}
+	m.common.renderer = r
+	m.parsed = &parsedDocument{}
+	m.currentDocument.Body = "Title\n=====\n\nSee [[Note]], not `[[Code]]`.\n\n    [[Indented]]\n\n## Next\n"
+
+	m, _ = m.update(renderWithGlamour(m, m.currentDocument.Body)())
+	if r.renders != 1 || m.parsed.doc == nil || m.parsed.body != m.currentDocument.Body {
+		t.Fatalf("expected the structure of the render kept, got %+v after %d renders", m.parsed, r.renders)
+	}
+	want := []Heading{{Level: 1, Text: "Title", Line: 0}, {Level: 2, Text: "Next", Line: 7}}
+	if got := m.headings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("headings are %+v, want %+v", got, want)
+	}
+	if got, want := m.wikiLinks(), []wikilinks.Link{{Target: "Note", Label: "Note"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wiki links are %+v, want %+v", got, want)
+	}
+
+	// A new version of the document is parsed if it isn't rendered
+	m.currentDocument.Body += "\n## Last\n"
+	if got := m.headings(); len(got) != 3 || r.renders != 1 {
+		t.Errorf("expected the new heading without rendering, got %+v after %d renders", got, r.renders)
+	}
+}
@@ -1,13 +1,19 @@
 package ui
 
 import (
-	"regexp"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
-	"github.com/muesli/reflow/truncate"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/hholst80/glow/document"
+	"github.com/hholst80/glow/sourcemap"
+	"github.com/hholst80/glow/utils"
+	runewidth "github.com/mattn/go-runewidth"
 )
 
 const (
@@ -15,20 +21,29 @@ const (
 	outlineMaxWidth     = 40
 	outlineWidthPercent = 25
 	minTerminalWidth    = 80
+
+	// outlineWidthAuto sizes the outline to fit its longest entry.
+	outlineWidthAuto = "auto"
+
+	// outlineFixedMinWidth is the narrowest outline width that can be set.
+	outlineFixedMinWidth = 10
 )
 
 // Heading represents a markdown heading extracted from the document.
 type Heading struct {
-	Level        int    // 1-6 for # through ######
+	Level        int    // 1-6 for # through ######, 0 for the front matter title and files
 	Text         string // The heading text (without # prefix)
 	Line         int    // Line number in raw markdown (0-indexed)
 	RenderedLine int    // Line number in rendered content (-1 if not mapped)
+	Figure       bool   // A numbered diagram rather than a heading
+	File         bool   // The start of a file of a joined document
 }
 
 // outlineModel manages the outline sidebar state.
 type outlineModel struct {
 	common   *commonModel
 	headings []Heading
+	title    string         // Front matter title shown as the root entry
 	cursor   int            // Currently selected heading (when focused)
 	current  int            // Current heading based on scroll position
 	width    int            // Sidebar width
@@ -36,13 +51,10 @@ type outlineModel struct {
 	visible  bool           // Whether outline is shown
 	focused  bool           // Whether outline has keyboard focus
 	viewport viewport.Model // For scrollable outline when many headings
-}
 
-// Regex patterns for heading extraction.
-var (
-	// Match ATX headings: # through ######
-	headingRegex = regexp.MustCompile(`(?m)^(#{1,6})\s+(.+?)(?:\s+#+)?$`)
-)
+	matches     []int // Rendered lines of the active search matches
+	matchCounts []int // Number of search matches in each heading's section
+}
 
 func newOutlineModel(common *commonModel) outlineModel {
 	vp := viewport.New(0, 0)
@@ -56,93 +68,191 @@ func newOutlineModel(common *commonModel) outlineModel {
 
 // parseHeadings extracts headings from raw markdown content.
 func parseHeadings(markdown string) []Heading {
-	var headings []Heading
-	lines := strings.Split(markdown, "\n")
-
-	// Track which lines are in code blocks
-	inCodeBlock := false
-	for i, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
-			inCodeBlock = !inCodeBlock
-			continue
-		}
-		if inCodeBlock {
-			continue
-		}
+	return modelHeadings(document.Parse([]byte(markdown)), nil)
+}
 
-		// Check for heading
-		match := headingRegex.FindStringSubmatch(line)
-		if match != nil {
-			level := len(match[1])
-			text := strings.TrimSpace(match[2])
-			headings = append(headings, Heading{
-				Level: level,
-				Text:  text,
-				Line:  i,
-			})
+// modelHeadings returns the headings of a document from its structure, with
+// the files of a joined document, which open with lines of their own, as
+// root entries.
+func modelHeadings(doc document.Document, files []utils.JoinedFile) []Heading {
+	var headings []Heading
+	next := 0
+	add := func(line int) {
+		for ; next < len(doc.Headings) && doc.Headings[next].Line-1 < line; next++ {
+			h := doc.Headings[next]
+			if h.Text != "" {
+				headings = append(headings, Heading{Level: h.Level, Text: h.Text, Line: h.Line - 1})
+			}
 		}
 	}
 
+	for _, f := range files {
+		add(f.Line)
+		headings = append(headings, Heading{Text: f.Name, Line: f.Line, File: true})
+	}
+	add(math.MaxInt)
 	return headings
 }
 
 // setContent updates the outline with new markdown content.
 func (m *outlineModel) setContent(markdown string) {
-	m.headings = parseHeadings(markdown)
+	m.setContentWithTitle(markdown, "")
+}
+
+// setContentWithTitle updates the outline with new markdown content. If title
+// is not empty it is shown as a root entry with the document's headings
+// nested underneath.
+func (m *outlineModel) setContentWithTitle(markdown, title string) {
+	m.setHeadings(parseHeadings(markdown), title)
+}
+
+// setHeadings updates the outline with the headings of a document, nested
+// under its title if it's not empty.
+func (m *outlineModel) setHeadings(headings []Heading, title string) {
+	m.title = title
+	m.headings = limitDepth(headings, m.common.cfg.OutlineDepth)
+	if title != "" {
+		root := Heading{Level: 0, Text: title, Line: 0, RenderedLine: 0}
+		m.headings = append([]Heading{root}, m.headings...)
+	}
 	m.cursor = 0
 	m.current = 0
+	m.matches = nil
+	m.matchCounts = nil
 	m.updateViewport()
 }
 
-// mapHeadingsToRenderedLines updates the RenderedLine field for each heading
-// by finding the heading text in the rendered content.
-func (m *outlineModel) mapHeadingsToRenderedLines(renderedContent string) {
-	if renderedContent == "" || len(m.headings) == 0 {
-		return
+// setMatches records the rendered lines of the active search matches and
+// counts how many fall into each heading's section. Headings must already be
+// mapped to rendered lines. A nil slice clears the counts.
+func (m *outlineModel) setMatches(lines []int) {
+	m.matches = lines
+	m.matchCounts = nil
+	if lines != nil {
+		m.matchCounts = make([]int, len(m.headings))
+		for i := range m.headings {
+			start, end := m.sectionBounds(i)
+			if start < 0 {
+				continue
+			}
+			for _, l := range lines {
+				if l >= start && (end < 0 || l < end) {
+					m.matchCounts[i]++
+				}
+			}
+		}
 	}
+	m.updateViewport()
+}
 
-	lines := strings.Split(renderedContent, "\n")
+// sectionBounds returns the rendered line range [start, end) covered by the
+// heading at the given index, up to the next mapped heading. start is -1 if
+// the heading isn't mapped and end is -1 if the section runs to the end of
+// the document.
+func (m *outlineModel) sectionBounds(index int) (start, end int) {
+	start = m.headings[index].RenderedLine
+	if start < 0 {
+		return -1, -1
+	}
+	for _, h := range m.headings[index+1:] {
+		// A heading's section includes the figures in it
+		if h.Figure && !m.headings[index].Figure {
+			continue
+		}
+		if h.RenderedLine > start {
+			return start, h.RenderedLine
+		}
+	}
+	return start, -1
+}
 
-	// For each heading, find its position in the rendered content
-	// Start searching from where we expect it to be based on document order
-	searchStart := 0
-	for i := range m.headings {
-		m.headings[i].RenderedLine = -1 // Default to not found
-		headingLower := strings.ToLower(strings.TrimSpace(m.headings[i].Text))
-
-		// Search from searchStart to find this heading
-		for j := searchStart; j < len(lines); j++ {
-			stripped := stripANSI(lines[j])
-			strippedLower := strings.ToLower(strings.TrimSpace(stripped))
-
-			if strings.Contains(strippedLower, headingLower) {
-				m.headings[i].RenderedLine = j
-				searchStart = j + 1 // Next heading must be after this one
-				break
-			}
+// firstMatch returns the rendered line of the first search match in the
+// section of the heading at the given index, or -1 if there is none.
+func (m *outlineModel) firstMatch(index int) int {
+	if index < 0 || index >= len(m.matchCounts) || m.matchCounts[index] == 0 {
+		return -1
+	}
+	start, end := m.sectionBounds(index)
+	for _, l := range m.matches {
+		if l >= start && (end < 0 || l < end) {
+			return l
 		}
 	}
+	return -1
 }
 
-// stripANSI removes ANSI escape codes from a string.
-func stripANSI(s string) string {
-	var result strings.Builder
-	inEscape := false
-	for _, r := range s {
-		if r == '\x1b' {
-			inEscape = true
+// mapHeadings updates the RenderedLine field of each heading to the line
+// the source map of the rendered document has its line on; -1 for headings
+// the map doesn't mark, such as those of chunks yet to be rendered.
+func (m *outlineModel) mapHeadings(sourceMap sourcemap.Map) {
+	for i, h := range m.headings {
+		switch {
+		case h.Figure:
+			// Figures are found in the rendered document
+			continue
+		case h.Level == 0 && !h.File:
+			// The title root always points at the top of the document
+			m.headings[i].RenderedLine = 0
 			continue
 		}
-		if inEscape {
-			if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
-				inEscape = false
-			}
+		m.headings[i].RenderedLine = -1
+		if line, exact := sourceMap.Rendered(h.Line); exact {
+			m.headings[i].RenderedLine = line
+		}
+	}
+}
+
+// addFigures adds the numbered diagrams of the rendered content to the
+// outline, each nested under the heading of its section. Headings must
+// already be mapped to rendered lines. captions are where the captions of
+// the diagrams were rendered, by the lines of their fences, as
+// sourcemap.Map.Fences returns them; the outline shows the captions as
+// rendered.
+func (m *outlineModel) addFigures(renderedContent string, captions sourcemap.Map) {
+	lines := strings.Split(renderedContent, "\n")
+	var figures []Heading
+	for _, c := range captions {
+		if c.Rendered < 0 || c.Rendered >= len(lines) {
 			continue
 		}
-		result.WriteRune(r)
+		figures = append(figures, Heading{
+			// In asterisks with styles that don't show emphasis
+			Text:         strings.Trim(strings.TrimSpace(stripANSI(lines[c.Rendered])), "*"),
+			Line:         c.Source,
+			RenderedLine: c.Rendered,
+			Figure:       true,
+		})
+	}
+	if len(figures) == 0 {
+		return
+	}
+
+	headings := make([]Heading, 0, len(m.headings)+len(figures))
+	parent := Heading{Level: 0}
+	add := func(f Heading) {
+		f.Level = parent.Level + 1
+		f.Line = max(f.Line, parent.Line)
+		headings = append(headings, f)
+	}
+	for _, h := range m.headings {
+		for len(figures) > 0 && h.RenderedLine >= 0 && figures[0].RenderedLine < h.RenderedLine {
+			add(figures[0])
+			figures = figures[1:]
+		}
+		headings = append(headings, h)
+		parent = h
+	}
+	for _, f := range figures {
+		add(f)
 	}
-	return result.String()
+	m.headings = headings
+	m.updateViewport()
+}
+
+// stripANSI removes ANSI escape codes from a string, including the OSC 8
+// sequences of hyperlinks.
+func stripANSI(s string) string {
+	return ansi.Strip(s)
 }
 
 // setSize updates the outline dimensions.
@@ -169,6 +279,102 @@ func calculateOutlineWidth(termWidth int) int {
 	return width
 }
 
+// ValidateOutlineWidth checks an outline width setting: "auto", empty for
+// the default, a number of columns such as "30", or a percentage of the
+// terminal such as "30%".
+func ValidateOutlineWidth(s string) error {
+	_, _, err := parseOutlineWidth(s)
+	return err
+}
+
+// parseOutlineWidth returns the columns or percentage of a fixed outline
+// width setting; both are 0 for "auto" and the default.
+func parseOutlineWidth(s string) (columns, percent int, err error) {
+	if s == "" || s == outlineWidthAuto {
+		return 0, 0, nil
+	}
+	if p, ok := strings.CutSuffix(s, "%"); ok {
+		percent, err = strconv.Atoi(p)
+		if err != nil || percent < 1 || percent > 50 {
+			return 0, 0, fmt.Errorf("invalid outline width %q: percentages must be from 1%% to 50%%", s)
+		}
+		return 0, percent, nil
+	}
+	columns, err = strconv.Atoi(s)
+	if err != nil || columns < outlineFixedMinWidth {
+		return 0, 0, fmt.Errorf("invalid outline width %q: must be \"auto\", a number of columns from %d, or a percentage such as \"30%%\"", s, outlineFixedMinWidth)
+	}
+	return columns, 0, nil
+}
+
+// calculateFixedOutlineWidth returns the outline width for a given terminal
+// width of a setting of columns or a percentage, leaving at least half the
+// terminal to the document.
+func calculateFixedOutlineWidth(termWidth int, setting string) int {
+	if termWidth < minTerminalWidth {
+		return 0
+	}
+	columns, percent, err := parseOutlineWidth(setting)
+	if err != nil {
+		return calculateOutlineWidth(termWidth)
+	}
+	if percent > 0 {
+		columns = termWidth * percent / 100
+	}
+	return max(min(columns, termWidth/2), outlineFixedMinWidth)
+}
+
+// limitDepth returns the headings no deeper than depth, and the root
+// entries; 0 keeps them all.
+func limitDepth(headings []Heading, depth int) []Heading {
+	if depth <= 0 {
+		return headings
+	}
+	return slices.DeleteFunc(headings, func(h Heading) bool {
+		return h.Level > depth
+	})
+}
+
+// calculateAutoOutlineWidth returns an outline width that fits the longest
+// heading entry, clamped between the minimum and maximum outline widths.
+// nested reports whether the headings sit underneath a title root entry.
+func calculateAutoOutlineWidth(termWidth int, headings []Heading, nested bool) int {
+	if termWidth < minTerminalWidth {
+		return 0
+	}
+	width := 0
+	for _, h := range headings {
+		// indent + prefix + text + padding
+		w := 2*headingDepth(h, nested) + 2 + runewidth.StringWidth(h.Text) + 2
+		if w > width {
+			width = w
+		}
+	}
+	if width < outlineMinWidth {
+		width = outlineMinWidth
+	}
+	if width > outlineMaxWidth {
+		width = outlineMaxWidth
+	}
+	return width
+}
+
+// nested reports whether headings has root entries, the front matter title
+// or the files of a joined document, which the other headings are nested
+// under.
+func nested(headings []Heading) bool {
+	return slices.ContainsFunc(headings, func(h Heading) bool { return h.Level == 0 })
+}
+
+// headingDepth returns the indentation depth of a heading in the outline.
+// Headings are nested one level deeper when root entries are shown.
+func headingDepth(h Heading, nested bool) int {
+	if nested {
+		return h.Level
+	}
+	return h.Level - 1
+}
+
 // updateViewport refreshes the viewport content.
 func (m *outlineModel) updateViewport() {
 	if len(m.headings) == 0 {
@@ -190,7 +396,7 @@ func (m *outlineModel) updateViewport() {
 // renderHeadingLine renders a single heading line with appropriate styling.
 func (m *outlineModel) renderHeadingLine(index int, h Heading) string {
 	// Indentation based on heading level
-	indent := strings.Repeat("  ", h.Level-1)
+	indent := strings.Repeat("  ", headingDepth(h, nested(m.headings)))
 
 	// Prefix indicator
 	prefix := "  "
@@ -198,22 +404,30 @@ func (m *outlineModel) renderHeadingLine(index int, h Heading) string {
 		prefix = "> "
 	}
 
-	// Calculate available width for text
-	availWidth := m.width - len(indent) - len(prefix) - 2 // -2 for padding
+	// Search hit count, right-aligned
+	var count string
+	if index < len(m.matchCounts) && m.matchCounts[index] > 0 {
+		count = fmt.Sprintf(" %d ", m.matchCounts[index])
+	}
+
+	// Calculate available width for text. Widths are measured in terminal
+	// cells so that wide (CJK, emoji) and combining characters line up.
+	availWidth := m.width - runewidth.StringWidth(indent) - runewidth.StringWidth(prefix) - len(count) - 2 // -2 for padding
 	if availWidth < 5 {
 		availWidth = 5
 	}
 
 	// Truncate text if needed
-	text := truncate.StringWithTail(h.Text, uint(availWidth), "…")
+	text := runewidth.Truncate(h.Text, availWidth, "…")
 
 	// Full line content
 	content := indent + prefix + text
 
 	// Pad to full width
-	if len(content) < m.width {
-		content += strings.Repeat(" ", m.width-len(content))
+	if w := runewidth.StringWidth(content) + len(count); w < m.width {
+		content += strings.Repeat(" ", m.width-w)
 	}
+	content += count
 
 	// Apply styling
 	if m.focused && index == m.cursor {
@@ -364,29 +578,3 @@ func (m outlineModel) View() string {
 	// Apply panel styling with left border
 	return outlinePanelStyle.Height(m.height).Render(content)
 }
-
-// Outline styles.
-var (
-	outlineBorderColor = lipgloss.AdaptiveColor{Light: "#DCDCDC", Dark: "#3C3C3C"}
-
-	outlineTitleStyle = lipgloss.NewStyle().
-				Bold(true).
-				Foreground(fuchsia).
-				Padding(0, 1)
-
-	outlineNormalStyle = lipgloss.NewStyle().
-				Foreground(gray)
-
-	outlineCurrentStyle = lipgloss.NewStyle().
-				Foreground(yellowGreen).
-				Bold(true)
-
-	outlineCursorStyle = lipgloss.NewStyle().
-				Background(darkGray).
-				Foreground(cream)
-
-	outlinePanelStyle = lipgloss.NewStyle().
-				BorderLeft(true).
-				BorderStyle(lipgloss.NormalBorder()).
-				BorderForeground(outlineBorderColor)
-)
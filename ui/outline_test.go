@@ -1,8 +1,12 @@
 package ui
 
 import (
+	"slices"
 	"strings"
 	"testing"
+
+	"github.com/hholst80/glow/sourcemap"
+	runewidth "github.com/mattn/go-runewidth"
 )
 
 func TestParseHeadings(t *testing.T) {
@@ -76,6 +80,15 @@ More text
 				{Level: 6, Text: "H6", Line: 5},
 			},
 		},
+		{
+			name:     "setext headings and inline markup",
+			markdown: "Title\n=====\n\n## The `glow` *pager*\n\nSection\n-------",
+			expected: []Heading{
+				{Level: 1, Text: "Title", Line: 0},
+				{Level: 2, Text: "The glow pager", Line: 3},
+				{Level: 2, Text: "Section", Line: 5},
+			},
+		},
 		{
 			name:     "empty markdown",
 			markdown: "",
@@ -130,6 +143,38 @@ func TestCalculateOutlineWidth(t *testing.T) {
 	}
 }
 
+func TestCalculateAutoOutlineWidth(t *testing.T) {
+	tests := []struct {
+		name      string
+		termWidth int
+		headings  []Heading
+		nested    bool
+		expected  int
+	}{
+		{"too narrow", 60, []Heading{{Level: 1, Text: "Title"}}, false, 0},
+		{"short headings use minimum", 120, []Heading{{Level: 1, Text: "Intro"}}, false, 20},
+		{"fits longest heading", 120, []Heading{
+			{Level: 1, Text: "Title"},
+			{Level: 2, Text: "A somewhat longer heading"}, // 2 + 2 + 25 + 2
+		}, false, 31},
+		{"nested under title", 120, []Heading{
+			{Level: 0, Text: "Doc"},
+			{Level: 2, Text: "A somewhat longer heading"}, // 4 + 2 + 25 + 2
+		}, true, 33},
+		{"wide characters", 120, []Heading{{Level: 1, Text: strings.Repeat("漢", 10)}}, false, 24},
+		{"clamped to maximum", 200, []Heading{{Level: 1, Text: strings.Repeat("x", 80)}}, false, 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := calculateAutoOutlineWidth(tt.termWidth, tt.headings, tt.nested)
+			if got != tt.expected {
+				t.Errorf("calculateAutoOutlineWidth() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
+
 func TestOutlineModelNavigation(t *testing.T) {
 	common := &commonModel{}
 	m := newOutlineModel(common)
@@ -284,6 +329,8 @@ func TestPagerIsMarkdownFile(t *testing.T) {
 	}{
 		{"README.md", true},
 		{"doc.markdown", true},
+		{"notes.org", true},
+		{"notes.rst", true},
 		{"file.txt", false},
 		{"code.go", false},
 		{"", true}, // Empty extension defaults to markdown
@@ -468,117 +515,72 @@ func TestPagerViewWithHighPerfRendering(t *testing.T) {
 }
 
 // ====================
-// mapHeadingsToRenderedLines TESTS
+// mapHeadings TESTS
 // ====================
 
-func TestMapHeadingsToRenderedLines_Basic(t *testing.T) {
+func TestMapHeadings(t *testing.T) {
 	common := &commonModel{}
 	m := newOutlineModel(common)
 
-	// Parse headings from raw markdown
 	rawMd := "# Title\nSome text\n## Section 1\nMore text\n### Subsection"
 	m.setContent(rawMd)
 
-	// Simulate rendered content (similar structure but different lines)
-	renderedContent := "Title\n\nSome text\n\nSection 1\n\nMore text\n\nSubsection"
-	m.mapHeadingsToRenderedLines(renderedContent)
+	// Subsection is in a chunk that isn't rendered yet
+	m.mapHeadings(sourcemap.Map{{Source: 0, Rendered: 1}, {Source: 1, Rendered: 3}, {Source: 2, Rendered: 5}})
 
-	// Verify mappings
 	if len(m.headings) != 3 {
 		t.Fatalf("Expected 3 headings, got %d", len(m.headings))
 	}
-
-	// Title should be found on line 0
-	if m.headings[0].RenderedLine != 0 {
-		t.Errorf("Title RenderedLine = %d, want 0", m.headings[0].RenderedLine)
-	}
-
-	// Section 1 should be found (line 4)
-	if m.headings[1].RenderedLine < 0 {
-		t.Errorf("Section 1 RenderedLine = %d, should be >= 0", m.headings[1].RenderedLine)
-	}
-
-	// Subsection should be found (line 8)
-	if m.headings[2].RenderedLine < 0 {
-		t.Errorf("Subsection RenderedLine = %d, should be >= 0", m.headings[2].RenderedLine)
+	for i, want := range []int{1, 5, -1} {
+		if got := m.headings[i].RenderedLine; got != want {
+			t.Errorf("%s RenderedLine = %d, want %d", m.headings[i].Text, got, want)
+		}
 	}
 
-	// Rendered lines should be in order
-	if m.headings[1].RenderedLine <= m.headings[0].RenderedLine {
-		t.Errorf("Headings should be in order: %d <= %d", m.headings[1].RenderedLine, m.headings[0].RenderedLine)
+	// Without a map, nothing is mapped
+	m.mapHeadings(nil)
+	for _, h := range m.headings {
+		if h.RenderedLine != -1 {
+			t.Errorf("%s RenderedLine = %d without a source map, want -1", h.Text, h.RenderedLine)
+		}
 	}
 }
 
-func TestMapHeadingsToRenderedLines_WithANSI(t *testing.T) {
+func TestOutlineAddFigures(t *testing.T) {
 	common := &commonModel{}
 	m := newOutlineModel(common)
 
-	rawMd := "# Bold Title\n## Styled Section"
+	rawMd := "# Title\n```mermaid\ngraph LR\n```\n## Section\n~~~d2\nx\n~~~\nFigure 3 is below"
 	m.setContent(rawMd)
+	renderedContent := "Title\n\n┌─┐\n\x1b[3mAbbildung 1: Intro\x1b[0m\n\nSection\n\n*Figure 2*\n\nFigure 3 is below"
+	sourceMap := sourcemap.Map{{Source: 0, Rendered: 0}, {Source: 1, Rendered: 3}, {Source: 4, Rendered: 5}, {Source: 5, Rendered: 7}, {Source: 8, Rendered: 9}}
+	m.mapHeadings(sourceMap)
+	m.addFigures(renderedContent, sourceMap.Fences(rawMd))
 
-	// Rendered content with ANSI codes
-	renderedContent := "\x1b[1mBold Title\x1b[0m\n\nSome content\n\n\x1b[38;5;208mStyled Section\x1b[0m"
-	m.mapHeadingsToRenderedLines(renderedContent)
-
-	if len(m.headings) != 2 {
-		t.Fatalf("Expected 2 headings, got %d", len(m.headings))
+	want := []Heading{
+		{Level: 1, Text: "Title", Line: 0, RenderedLine: 0},
+		{Level: 2, Text: "Abbildung 1: Intro", Line: 1, RenderedLine: 3, Figure: true},
+		{Level: 2, Text: "Section", Line: 4, RenderedLine: 5},
+		{Level: 3, Text: "Figure 2", Line: 5, RenderedLine: 7, Figure: true},
 	}
-
-	// Both headings should be found despite ANSI codes
-	if m.headings[0].RenderedLine < 0 {
-		t.Errorf("Bold Title should be found, got RenderedLine = %d", m.headings[0].RenderedLine)
-	}
-	if m.headings[1].RenderedLine < 0 {
-		t.Errorf("Styled Section should be found, got RenderedLine = %d", m.headings[1].RenderedLine)
-	}
-}
-
-func TestMapHeadingsToRenderedLines_Empty(t *testing.T) {
-	common := &commonModel{}
-	m := newOutlineModel(common)
-
-	// Empty content
-	m.setContent("")
-	m.mapHeadingsToRenderedLines("")
-
-	if len(m.headings) != 0 {
-		t.Errorf("Expected 0 headings for empty content, got %d", len(m.headings))
+	if len(m.headings) != len(want) {
+		t.Fatalf("Expected %d entries, got %+v", len(want), m.headings)
 	}
-
-	// Empty rendered but with headings - should return early without modifying
-	m.setContent("# Title")
-	initialRenderedLine := m.headings[0].RenderedLine
-	m.mapHeadingsToRenderedLines("")
-
-	// Should not crash and headings should remain unchanged (returns early)
-	if m.headings[0].RenderedLine != initialRenderedLine {
-		t.Errorf("RenderedLine should remain unchanged for empty rendered content, got %d", m.headings[0].RenderedLine)
+	for i, h := range m.headings {
+		if h != want[i] {
+			t.Errorf("Entry %d = %+v, want %+v", i, h, want[i])
+		}
 	}
 
-	// When called with non-empty content but heading not found, should be -1
-	m.mapHeadingsToRenderedLines("no matching content here")
-	if m.headings[0].RenderedLine != -1 {
-		t.Errorf("RenderedLine should be -1 when heading not found, got %d", m.headings[0].RenderedLine)
+	// The title's section includes its figure
+	if start, end := m.sectionBounds(0); start != 0 || end != 5 {
+		t.Errorf("sectionBounds(0) = %d, %d, want 0, 5", start, end)
 	}
-}
-
-func TestMapHeadingsToRenderedLines_CaseInsensitive(t *testing.T) {
-	common := &commonModel{}
-	m := newOutlineModel(common)
-
-	rawMd := "# UPPERCASE\n## MixedCase"
-	m.setContent(rawMd)
 
-	// Rendered with different case (some renderers might alter case)
-	renderedContent := "uppercase\nmixedcase"
-	m.mapHeadingsToRenderedLines(renderedContent)
-
-	// Both should be found due to case-insensitive matching
-	if m.headings[0].RenderedLine < 0 {
-		t.Errorf("UPPERCASE heading should match 'uppercase', got RenderedLine = %d", m.headings[0].RenderedLine)
-	}
-	if m.headings[1].RenderedLine < 0 {
-		t.Errorf("MixedCase heading should match 'mixedcase', got RenderedLine = %d", m.headings[1].RenderedLine)
+	// Figures keep their lines when headings are mapped again
+	m.mapHeadings(sourceMap)
+	if h := m.headings[1]; h.RenderedLine != 3 {
+		t.Errorf("Figure RenderedLine = %d after mapping headings again, want 3", h.RenderedLine)
 	}
 }
 
@@ -606,7 +608,8 @@ func TestJumpToHeading_Basic(t *testing.T) {
 
 	// Parse and map headings
 	m.outline.setContent(m.currentDocument.Body)
-	m.outline.mapHeadingsToRenderedLines(m.viewport.View())
+	m.sourceMap = sourcemap.Map{{Source: 0, Rendered: 0}, {Source: 3, Rendered: 3}, {Source: 6, Rendered: 6}}
+	m.outline.mapHeadings(m.sourceMap)
 
 	// Jump to heading 1 (H2)
 	initialOffset := m.viewport.YOffset
@@ -624,6 +627,25 @@ func TestJumpToHeading_Basic(t *testing.T) {
 	t.Logf("Initial offset: %d, After jump: %d", initialOffset, m.viewport.YOffset)
 }
 
+// TestScrollContext tests that a scrolloff of 0 keeps no lines above
+// headings jumped to, while an unset one uses the default.
+func TestScrollContext(t *testing.T) {
+	zero, three := 0, 3
+	for _, tt := range []struct {
+		scrollOff *int
+		want      int
+	}{
+		{nil, scrollOff},
+		{&zero, 0},
+		{&three, 3},
+	} {
+		m := newPagerModel(&commonModel{cfg: Config{ScrollOff: tt.scrollOff}})
+		if got := m.scrollContext(); got != tt.want {
+			t.Errorf("scrollContext() = %d, want %d", got, tt.want)
+		}
+	}
+}
+
 func TestJumpToHeading_OutOfBounds(t *testing.T) {
 	common := &commonModel{
 		cfg:    Config{},
@@ -655,7 +677,7 @@ func TestJumpToHeading_OutOfBounds(t *testing.T) {
 	}
 }
 
-func TestJumpToHeading_FallbackRatio(t *testing.T) {
+func TestJumpToHeading_Unmapped(t *testing.T) {
 	common := &commonModel{
 		cfg:    Config{},
 		width:  100,
@@ -671,16 +693,13 @@ func TestJumpToHeading_FallbackRatio(t *testing.T) {
 	m.setSize(common.width, common.height)
 	m.viewport.SetContent(strings.Repeat("Content line\n", 100))
 
-	// Parse headings but don't map rendered lines
+	// Headings the renderer didn't map aren't guessed at
 	m.outline.setContent(m.currentDocument.Body)
-	// Don't call mapHeadingsToRenderedLines - forces fallback
-
-	// H2 is at the end, so jumping should use ratio-based approximation
+	m.outline.mapHeadings(nil)
 	m.jumpToHeading(1)
 
-	// Should update current without crashing
-	if m.outline.current != 1 {
-		t.Errorf("outline.current = %d, want 1", m.outline.current)
+	if m.viewport.YOffset != 0 {
+		t.Errorf("YOffset = %d after jumping to an unmapped heading, want 0", m.viewport.YOffset)
 	}
 }
 
@@ -815,6 +834,7 @@ func TestStripANSI(t *testing.T) {
 		{"multiple codes", "\x1b[1m\x1b[31mred bold\x1b[0m", "red bold"},
 		{"empty string", "", ""},
 		{"only ANSI", "\x1b[1m\x1b[0m", ""},
+		{"hyperlink", "\x1b]8;;https://x.dev\x1b\\link\x1b]8;;\x1b\\", "link"},
 	}
 
 	for _, tt := range tests {
@@ -849,3 +869,109 @@ func TestOutline_HeadingWithSpecialChars(t *testing.T) {
 		t.Errorf("Heading should contain '&' and '/': %q", m.headings[2].Text)
 	}
 }
+
+func TestOutline_FrontmatterTitleRoot(t *testing.T) {
+	common := &commonModel{}
+	m := newOutlineModel(common)
+
+	m.setContentWithTitle("# H1\n## H2", "My Document")
+	m.setSize(30, 10)
+	m.visible = true
+
+	if len(m.headings) != 3 {
+		t.Fatalf("Expected 3 headings (title + 2), got %d", len(m.headings))
+	}
+	if m.headings[0].Level != 0 || m.headings[0].Text != "My Document" {
+		t.Errorf("First heading should be the title root, got %+v", m.headings[0])
+	}
+
+	// H1 should be nested underneath the title
+	root := stripANSI(m.renderHeadingLine(0, m.headings[0]))
+	h1 := stripANSI(m.renderHeadingLine(1, m.headings[1]))
+	if !strings.HasPrefix(root, "> My Document") {
+		t.Errorf("Title root should not be indented, got %q", root)
+	}
+	if !strings.HasPrefix(h1, "    H1") {
+		t.Errorf("H1 should be indented below the title root, got %q", h1)
+	}
+
+	// The title root always maps to the top of the rendered document
+	m.mapHeadings(sourcemap.Map{{Source: 0, Rendered: 1}, {Source: 1, Rendered: 2}})
+	if m.headings[0].RenderedLine != 0 {
+		t.Errorf("Title root RenderedLine = %d, want 0", m.headings[0].RenderedLine)
+	}
+	if m.headings[1].RenderedLine != 1 {
+		t.Errorf("H1 RenderedLine = %d, want 1", m.headings[1].RenderedLine)
+	}
+}
+
+func TestOutline_WideCharacterHeadings(t *testing.T) {
+	common := &commonModel{}
+	m := newOutlineModel(common)
+
+	m.setContent("# 日本語の見出しがとても長い場合のテスト\n## 🚀 Launch\n## Café")
+	m.setSize(24, 10)
+	m.visible = true
+
+	for i, h := range m.headings {
+		line := stripANSI(m.renderHeadingLine(i, h))
+		if w := runewidth.StringWidth(line); w != m.width {
+			t.Errorf("heading %d rendered %d cells wide, want %d: %q", i, w, m.width, line)
+		}
+	}
+
+	// Long CJK headings are truncated on a cell boundary
+	line := stripANSI(m.renderHeadingLine(0, m.headings[0]))
+	if !strings.Contains(line, "…") {
+		t.Errorf("long CJK heading should be truncated, got %q", line)
+	}
+}
+
+func TestCalculateFixedOutlineWidth(t *testing.T) {
+	tests := []struct {
+		termWidth int
+		setting   string
+		expected  int
+	}{
+		{60, "30", 0}, // Too narrow
+		{120, "30", 30},
+		{120, "30%", 36},
+		{120, "90", 60}, // Half the terminal at most
+		{200, "50%", 100},
+		{100, "1%", outlineFixedMinWidth},
+	}
+
+	for _, tt := range tests {
+		got := calculateFixedOutlineWidth(tt.termWidth, tt.setting)
+		if got != tt.expected {
+			t.Errorf("calculateFixedOutlineWidth(%d, %q) = %d, want %d", tt.termWidth, tt.setting, got, tt.expected)
+		}
+	}
+}
+
+func TestValidateOutlineWidth(t *testing.T) {
+	for _, s := range []string{"", "auto", "10", "40", "1%", "50%"} {
+		if err := ValidateOutlineWidth(s); err != nil {
+			t.Errorf("ValidateOutlineWidth(%q) = %v", s, err)
+		}
+	}
+	for _, s := range []string{"wide", "9", "-5", "0%", "51%", "x%"} {
+		if err := ValidateOutlineWidth(s); err == nil {
+			t.Errorf("ValidateOutlineWidth(%q) isn't an error", s)
+		}
+	}
+}
+
+func TestOutlineDepth(t *testing.T) {
+	common := &commonModel{cfg: Config{OutlineDepth: 2}}
+	m := newOutlineModel(common)
+	m.setContentWithTitle("# One\n\n## Two\n\n### Three\n\n## Four\n", "Title")
+
+	var got []string
+	for _, h := range m.headings {
+		got = append(got, h.Text)
+	}
+	if want := []string{"Title", "One", "Two", "Four"}; !slices.Equal(got, want) {
+		t.Errorf("headings = %v, want %v", got, want)
+	}
+}
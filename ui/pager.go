@@ -1,14 +1,21 @@
 package ui
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hholst80/glow/document"
+	"github.com/hholst80/glow/htmlblocks"
+	"github.com/hholst80/glow/images"
+	"github.com/hholst80/glow/sourcemap"
+	"github.com/hholst80/glow/tables"
 	"github.com/hholst80/glow/utils"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
@@ -23,60 +30,17 @@ const (
 	lineNumberWidth = 4
 )
 
-var (
-	pagerHelpHeight int
-
-	mintGreen = lipgloss.AdaptiveColor{Light: "#89F0CB", Dark: "#89F0CB"}
-	darkGreen = lipgloss.AdaptiveColor{Light: "#1C8760", Dark: "#1C8760"}
-
-	lineNumberFg = lipgloss.AdaptiveColor{Light: "#656565", Dark: "#7D7D7D"}
-
-	statusBarNoteFg = lipgloss.AdaptiveColor{Light: "#656565", Dark: "#7D7D7D"}
-	statusBarBg     = lipgloss.AdaptiveColor{Light: "#E6E6E6", Dark: "#242424"}
-
-	statusBarScrollPosStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.AdaptiveColor{Light: "#949494", Dark: "#5A5A5A"}).
-				Background(statusBarBg).
-				Render
-
-	statusBarNoteStyle = lipgloss.NewStyle().
-				Foreground(statusBarNoteFg).
-				Background(statusBarBg).
-				Render
-
-	statusBarHelpStyle = lipgloss.NewStyle().
-				Foreground(statusBarNoteFg).
-				Background(lipgloss.AdaptiveColor{Light: "#DCDCDC", Dark: "#323232"}).
-				Render
-
-	statusBarMessageStyle = lipgloss.NewStyle().
-				Foreground(mintGreen).
-				Background(darkGreen).
-				Render
-
-	statusBarMessageScrollPosStyle = lipgloss.NewStyle().
-					Foreground(mintGreen).
-					Background(darkGreen).
-					Render
-
-	statusBarMessageHelpStyle = lipgloss.NewStyle().
-					Foreground(lipgloss.Color("#B6FFE4")).
-					Background(green).
-					Render
-
-	helpViewStyle = lipgloss.NewStyle().
-			Foreground(statusBarNoteFg).
-			Background(lipgloss.AdaptiveColor{Light: "#f2f2f2", Dark: "#1B1B1B"}).
-			Render
-
-	lineNumberStyle = lipgloss.NewStyle().
-			Foreground(lineNumberFg).
-			Render
-)
+var pagerHelpHeight int
 
 type (
-	contentRenderedMsg string
-	reloadMsg          struct{}
+	// contentRenderedMsg holds a rendered document, where the lines of its
+	// markdown were rendered, and its structure, parsed as it was rendered
+	contentRenderedMsg struct {
+		content   string
+		sourceMap sourcemap.Map
+		parsed    *parsedDocument
+	}
+	reloadMsg struct{}
 )
 
 type pagerState int
@@ -84,6 +48,11 @@ type pagerState int
 const (
 	pagerStateBrowse pagerState = iota
 	pagerStateStatusMessage
+	pagerStateSearch
+	pagerStateDiagramList
+	pagerStateDiagram
+	pagerStateLanguage
+	pagerStateHints
 )
 
 type pagerModel struct {
@@ -91,6 +60,7 @@ type pagerModel struct {
 	viewport viewport.Model
 	state    pagerState
 	showHelp bool
+	keys     keyMap
 
 	statusMessage      string
 	statusMessageTimer *time.Timer
@@ -105,6 +75,74 @@ type pagerModel struct {
 	outline        outlineModel
 	showOutline    bool
 	outlineFocused bool
+
+	// Search within the rendered document
+	renderedContent string
+
+	// Where the lines of the document were rendered; nil if the renderer
+	// doesn't tell. The view is kept at the anchor when the document is
+	// rendered again, at a new size or with a section folded.
+	sourceMap sourcemap.Map
+	anchor    *scrollAnchor
+
+	searchInput   textinput.Model
+	searchQuery   string
+	searchMatches []int // Rendered line of each match
+	searchIndex   int   // Index of the current match
+
+	// Hit of the stash's full-text search to scroll to once rendered
+	pendingHit *openSearchHitMsg
+
+	// Chunks of a long document left to render as it's scrolled through,
+	// and the heading to jump to once it's rendered; 0 for none, as the
+	// first is always rendered
+	pending        *pendingChunks
+	pendingHeading int
+
+	// Render in progress, cancelled when another starts, and the resizes of
+	// the terminal: how many there were, the last the document was rendered
+	// at, and whether more are expected
+	renders        *renderGroup
+	resizes        int
+	resizeRendered int
+	resizing       bool
+
+	// Structure of the document, parsed once for each version of it, and
+	// whether a long document is being parsed after its first chunk is shown
+	parsed  *parsedDocument
+	parsing bool
+
+	// Document streamed in as it's rendered, whether more of it is awaited
+	// and whether the view follows its end
+	stream        *streamReader
+	streamWaiting bool
+	follow        bool
+
+	// Language the document is shown as a code block of, over the one its
+	// extension gives; "" detects it
+	language      string
+	languageInput textinput.Model
+
+	// Things on screen to pick by the keys shown in the status bar, such as
+	// links to follow
+	hints []hint
+
+	// Sections of <details> shown open or folded the other way than they're
+	// shown to begin with
+	toggledDetails map[int]bool
+
+	// Files opened as tabs, the one shown, and the scroll position of each
+	tabs       []*markdown
+	tab        int
+	tabOffsets []int
+
+	// Horizontal pan of lines wider than the viewport, e.g. wide diagrams
+	xOffset int
+
+	// Mermaid diagrams of the document, and the full-screen diagram view
+	diagrams        []string
+	diagramCursor   int
+	diagramViewport viewport.Model
 }
 
 func newPagerModel(common *commonModel) pagerModel {
@@ -114,11 +152,17 @@ func newPagerModel(common *commonModel) pagerModel {
 	vp.HighPerformanceRendering = config.HighPerformancePager
 
 	m := pagerModel{
-		common:      common,
-		state:       pagerStateBrowse,
-		viewport:    vp,
-		outline:     newOutlineModel(common),
-		showOutline: common.cfg.ShowOutline,
+		common:        common,
+		state:         pagerStateBrowse,
+		viewport:      vp,
+		outline:       newOutlineModel(common),
+		showOutline:   common.cfg.ShowOutline,
+		searchInput:   newSearchInput(),
+		language:      common.cfg.Language,
+		languageInput: newLanguageInput(),
+		keys:          newKeyMap(common.cfg.Keys),
+		renders:       &renderGroup{},
+		parsed:        &parsedDocument{},
 	}
 	m.initWatcher()
 	return m
@@ -130,7 +174,7 @@ func (m *pagerModel) setSize(w, h int) {
 
 	// Calculate outline width if visible and viewing markdown
 	if m.showOutline && m.isMarkdownFile() {
-		outlineWidth = calculateOutlineWidth(w)
+		outlineWidth = m.outlineWidth(w)
 		if outlineWidth > 0 {
 			contentWidth = w - outlineWidth
 			m.outline.visible = true
@@ -152,6 +196,16 @@ func (m *pagerModel) setSize(w, h int) {
 
 	m.viewport.Width = contentWidth
 	m.viewport.Height = h - statusBarHeight
+	m.viewport.YPosition = 0
+	if m.hasTabs() {
+		m.viewport.Height -= tabBarHeight
+		m.viewport.YPosition = tabBarHeight
+		if m.outline.visible {
+			m.outline.setSize(outlineWidth, h-statusBarHeight-tabBarHeight)
+		}
+	}
+	m.diagramViewport.Width = w
+	m.diagramViewport.Height = h - statusBarHeight
 
 	if m.showHelp {
 		if pagerHelpHeight == 0 {
@@ -161,13 +215,51 @@ func (m *pagerModel) setSize(w, h int) {
 	}
 }
 
+// outlineWidth returns the width of the outline sidebar for the given
+// terminal width, according to the configured outline width mode.
+func (m *pagerModel) outlineWidth(termWidth int) int {
+	switch setting := m.common.cfg.OutlineWidth; setting {
+	case outlineWidthAuto:
+		headings := limitDepth(m.headings(), m.common.cfg.OutlineDepth)
+		title := m.currentDocument.Title
+		if title != "" {
+			headings = append(headings, Heading{Level: 0, Text: title})
+		}
+		return calculateAutoOutlineWidth(termWidth, headings, nested(headings))
+	case "":
+		return calculateOutlineWidth(termWidth)
+	default:
+		return calculateFixedOutlineWidth(termWidth, setting)
+	}
+}
+
+// applyOutlineOption shows or hides the outline as a document opened after
+// prev asks in its front matter. If it doesn't ask but prev did, the outline
+// goes back to the configured setting.
+func (m *pagerModel) applyOutlineOption(prev, next markdown) {
+	switch {
+	case next.options.Outline != nil:
+		m.showOutline = *next.options.Outline
+	case prev.options.Outline != nil:
+		m.showOutline = m.common.cfg.ShowOutline
+	default:
+		return
+	}
+	if !m.showOutline {
+		m.outlineFocused = false
+		m.outline.focused = false
+	}
+}
+
 func (m *pagerModel) setContent(s string) {
-	m.viewport.SetContent(s)
+	m.renderedContent = s
+	m.pan(0)
 }
 
 // isMarkdownFile returns true if the current document is a markdown file.
 func (m *pagerModel) isMarkdownFile() bool {
-	return utils.IsMarkdownFile(m.currentDocument.Note)
+	_, isCode := utils.CodeLanguage(m.currentDocument.Note, m.language)
+	return !isCode
 }
 
 func (m *pagerModel) toggleHelp() {
@@ -207,49 +299,159 @@ func (m *pagerModel) unload() {
 		m.statusMessageTimer.Stop()
 	}
 	m.state = pagerStateBrowse
-	m.viewport.SetContent("")
+	m.diagramCursor = 0
+	m.pendingHit = nil
+	m.pending, m.pendingHeading = nil, 0
+	m.sourceMap, m.anchor = nil, nil
+	m.parsing = false
+	m.renders.stop()
+	m.resizing = false
+	m.clearSearch()
+	m.setContent("")
 	m.viewport.YOffset = 0
+	m.xOffset = 0
+	m.language = m.common.cfg.Language
+	m.toggledDetails = nil
 	m.unwatchFile()
+	m.tabs, m.tab, m.tabOffsets = nil, 0, nil
 }
 
 func (m pagerModel) update(msg tea.Msg) (pagerModel, tea.Cmd) {
 	var (
 		cmd  tea.Cmd
 		cmds []tea.Cmd
+
+		// bound is whether a key does an action, which the viewport then
+		// doesn't scroll for too, such as j bound to another action
+		bound bool
 	)
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.state == pagerStateSearch {
+			return m.updateSearch(msg)
+		}
+		if m.state == pagerStateLanguage {
+			return m.updateLanguage(msg)
+		}
+		if m.state == pagerStateHints {
+			return m.updateHints(msg)
+		}
+		if m.state == pagerStateDiagramList || m.state == pagerStateDiagram {
+			return m.updateDiagrams(msg)
+		}
+
 		switch msg.String() {
 		case "q", keyEsc:
 			if m.state != pagerStateBrowse {
 				m.state = pagerStateBrowse
 				return m, nil
 			}
-		case "home", "g":
+			if msg.String() == keyEsc && m.searchActive() {
+				m.clearSearch()
+				return m, nil
+			}
+
+		case keyEnter:
+			// Jump to selected heading when outline is focused. While
+			// searching, jump to the section's first match instead.
+			if m.outlineFocused && m.outline.visible {
+				if !m.searchActive() || !m.jumpToSectionMatch(m.outline.cursor) {
+					m.jumpToHeading(m.outline.cursor)
+				}
+				if m.viewport.HighPerformanceRendering {
+					cmds = append(cmds, viewport.Sync(m.viewport))
+				}
+			} else {
+				cmds = append(cmds, m.startHints(m.linkHints(), "No links on screen", true))
+			}
+
+		case "j", "down":
+			if m.outlineFocused && m.outline.visible {
+				m.outline.moveCursorDown()
+				return m, nil
+			}
+
+		case "k", "up":
+			if m.outlineFocused && m.outline.visible {
+				m.outline.moveCursorUp()
+				return m, nil
+			}
+		}
+
+		a := m.keys.action(msg.String())
+		bound = a != noAction
+		switch a {
+		case actionSearch:
+			return m, m.startSearch()
+
+		case actionDiagrams:
+			return m, m.openDiagramList()
+
+		case actionLanguage:
+			return m, m.startLanguage()
+
+		case actionFollowLink:
+			return m, m.startHints(m.linkHints(), "No links on screen", false)
+
+		case actionToggleDetails:
+			return m, m.startHints(m.detailsHints(), "No details on screen", true)
+
+		case actionNextTab:
+			return m, m.switchTab(m.tab + 1)
+
+		case actionPrevTab:
+			return m, m.switchTab(m.tab - 1)
+
+		case actionNextMatch:
+			m.nextMatch()
+			if m.viewport.HighPerformanceRendering {
+				cmds = append(cmds, viewport.Sync(m.viewport))
+			}
+
+		case actionPrevMatch:
+			m.prevMatch()
+			if m.viewport.HighPerformanceRendering {
+				cmds = append(cmds, viewport.Sync(m.viewport))
+			}
+
+		case actionPanLeft:
+			m.pan(-panStep)
+			if m.viewport.HighPerformanceRendering {
+				cmds = append(cmds, viewport.Sync(m.viewport))
+			}
+
+		case actionPanRight:
+			m.pan(panStep)
+			if m.viewport.HighPerformanceRendering {
+				cmds = append(cmds, viewport.Sync(m.viewport))
+			}
+
+		case actionTop:
 			m.viewport.GotoTop()
 			if m.viewport.HighPerformanceRendering {
 				cmds = append(cmds, viewport.Sync(m.viewport))
 			}
-		case "end", "G":
+
+		case actionBottom:
 			m.viewport.GotoBottom()
 			if m.viewport.HighPerformanceRendering {
 				cmds = append(cmds, viewport.Sync(m.viewport))
 			}
 
-		case "d":
+		case actionHalfPageDown:
 			m.viewport.HalfViewDown()
 			if m.viewport.HighPerformanceRendering {
 				cmds = append(cmds, viewport.Sync(m.viewport))
 			}
 
-		case "u":
+		case actionHalfPageUp:
 			m.viewport.HalfViewUp()
 			if m.viewport.HighPerformanceRendering {
 				cmds = append(cmds, viewport.Sync(m.viewport))
 			}
 
-		case "e":
+		case actionEdit:
 			lineno := int(math.RoundToEven(float64(m.viewport.TotalLineCount()) * m.viewport.ScrollPercent()))
 			if m.viewport.AtTop() {
 				lineno = 0
@@ -261,23 +463,23 @@ func (m pagerModel) update(msg tea.Msg) (pagerModel, tea.Cmd) {
 			)
 			return m, openEditor(m.currentDocument.localPath, lineno)
 
-		case "c":
+		case actionCopy:
 			// Copy using OSC 52
 			m.common.terminal.CopyOSC52(m.currentDocument.Body)
 			// Copy using native system clipboard
 			_ = m.common.terminal.CopyClipboard(m.currentDocument.Body)
 			cmds = append(cmds, m.showStatusMessage(pagerStatusMessage{"Copied contents", false}))
 
-		case "r":
+		case actionReload:
 			return m, loadLocalMarkdown(&m.currentDocument)
 
-		case "?":
+		case actionHelp:
 			m.toggleHelp()
 			if m.viewport.HighPerformanceRendering {
 				cmds = append(cmds, viewport.Sync(m.viewport))
 			}
 
-		case "o":
+		case actionToggleOutline:
 			// Toggle outline visibility (only for markdown files)
 			if m.isMarkdownFile() {
 				m.showOutline = !m.showOutline
@@ -285,14 +487,14 @@ func (m pagerModel) update(msg tea.Msg) (pagerModel, tea.Cmd) {
 					m.outlineFocused = false
 				} else {
 					// Parse headings immediately when enabling outline
-					m.outline.setContent(m.currentDocument.Body)
+					m.outline.setHeadings(m.headings(), m.currentDocument.Title)
 				}
 				m.setSize(m.common.width, m.common.height)
 				// Re-render content at new width
 				return m, renderWithGlamour(m, m.currentDocument.Body)
 			}
 
-		case "tab":
+		case actionFocusOutline:
 			// Toggle focus between content and outline
 			if m.showOutline && m.outline.visible {
 				m.outlineFocused = !m.outlineFocused
@@ -304,7 +506,7 @@ func (m pagerModel) update(msg tea.Msg) (pagerModel, tea.Cmd) {
 				m.outline.updateViewport()
 			}
 
-		case "]":
+		case actionNextHeading:
 			// Jump to next heading
 			if m.showOutline && len(m.outline.headings) > 0 {
 				nextIdx := m.outline.nextHeadingIndex()
@@ -316,7 +518,7 @@ func (m pagerModel) update(msg tea.Msg) (pagerModel, tea.Cmd) {
 				}
 			}
 
-		case "[":
+		case actionPrevHeading:
 			// Jump to previous heading
 			if m.showOutline && len(m.outline.headings) > 0 {
 				prevIdx := m.outline.prevHeadingIndex()
@@ -327,47 +529,35 @@ func (m pagerModel) update(msg tea.Msg) (pagerModel, tea.Cmd) {
 					}
 				}
 			}
-
-		case "enter":
-			// Jump to selected heading when outline is focused
-			if m.outlineFocused && m.outline.visible {
-				m.jumpToHeading(m.outline.cursor)
-				if m.viewport.HighPerformanceRendering {
-					cmds = append(cmds, viewport.Sync(m.viewport))
-				}
-			}
-
-		case "j", "down":
-			if m.outlineFocused && m.outline.visible {
-				m.outline.moveCursorDown()
-				return m, nil
-			}
-
-		case "k", "up":
-			if m.outlineFocused && m.outline.visible {
-				m.outline.moveCursorUp()
-				return m, nil
-			}
 		}
 
 	// Glow has rendered the content
+	case toggleDetailsMsg:
+		return m, m.toggleDetails(int(msg))
+
 	case contentRenderedMsg:
-		log.Info("content rendered", "state", m.state)
+		m.pending, m.parsing = nil, false
+		m.keepModel(msg.parsed)
+		cmds = append(cmds, m.contentRendered(msg.content, msg.sourceMap)...)
+
+	// The first chunk of a long document has rendered
+	case chunksRenderedMsg:
+		m.pending = msg.pending
+		m.keepModel(msg.parsed)
+		m.parsing = msg.parsed == nil && m.isMarkdownFile()
+		cmds = append(cmds, m.contentRendered(msg.content, msg.sourceMap)...)
+		if m.parsing {
+			cmds = append(cmds, parseDocumentModel(m))
+		}
 
-		m.setSize(m.common.width, m.common.height)
-		m.setContent(string(msg))
+	case documentParsedMsg:
+		cmds = append(cmds, m.documentParsed(msg))
 
-		if m.viewport.HighPerformanceRendering {
-			cmds = append(cmds, viewport.Sync(m.viewport))
-		}
-		cmds = append(cmds, m.watchFile)
+	case chunkRenderedMsg:
+		cmds = append(cmds, m.appendChunk(msg))
 
-		// Always parse headings for markdown files (needed for navigation)
-		// Then map them to rendered line positions
-		if m.isMarkdownFile() {
-			m.outline.setContent(m.currentDocument.Body)
-			m.outline.mapHeadingsToRenderedLines(string(msg))
-		}
+	case streamChunkMsg:
+		cmds = append(cmds, m.appendStream(msg))
 
 	// The file was changed on disk and we're reloading it
 	case reloadMsg:
@@ -382,14 +572,31 @@ func (m pagerModel) update(msg tea.Msg) (pagerModel, tea.Cmd) {
 	// We've received terminal dimensions, either for the first time or
 	// after a resize
 	case tea.WindowSizeMsg:
-		return m, renderWithGlamour(m, m.currentDocument.Body)
+		return m, m.resize()
+
+	case resizeSettledMsg:
+		return m, m.resizeSettled(int(msg))
 
 	case statusMessageTimeoutMsg:
 		m.state = pagerStateBrowse
 	}
 
-	m.viewport, cmd = m.viewport.Update(msg)
-	cmds = append(cmds, cmd)
+	if !bound {
+		m.viewport, cmd = m.viewport.Update(msg)
+		cmds = append(cmds, cmd)
+	}
+
+	// Scrolling up stops following a streamed document, and scrolling back
+	// to the end follows it again
+	if m.stream != nil {
+		switch msg.(type) {
+		case tea.KeyMsg, tea.MouseMsg:
+			m.follow = m.viewport.AtBottom()
+		}
+	}
+
+	// Render more of a long document as it's scrolled toward
+	cmds = append(cmds, m.renderNextChunk())
 
 	// Update current heading based on scroll position
 	if m.showOutline && m.outline.visible && !m.outlineFocused {
@@ -399,12 +606,67 @@ func (m pagerModel) update(msg tea.Msg) (pagerModel, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// contentRendered shows a rendered document, mapping its headings and search
+// matches to the rendered lines, and returns the commands to run next.
+// sourceMap tells where the lines of the document were rendered, if the
+// renderer does.
+func (m *pagerModel) contentRendered(s string, sourceMap sourcemap.Map) []tea.Cmd {
+	log.Info("content rendered", "state", m.state)
+	m.pendingHeading = 0
+	var cmds []tea.Cmd
+
+	m.setSize(m.common.width, m.common.height)
+	m.setContent(s)
+	m.sourceMap = sourceMap
+	m.restoreScroll()
+
+	if m.viewport.HighPerformanceRendering {
+		cmds = append(cmds, viewport.Sync(m.viewport))
+	}
+	cmds = append(cmds, m.watchFile)
+
+	// Always parse headings for markdown files (needed for navigation)
+	// Then map them to rendered line positions
+	if m.isMarkdownFile() {
+		m.updateOutline()
+	}
+
+	// Line positions change when the document is re-rendered
+	if m.searchActive() {
+		m.applySearch(m.searchQuery)
+	}
+	if m.pendingHit != nil {
+		m.jumpToSearchHit(*m.pendingHit)
+		m.pendingHit = nil
+	}
+
+	// Wait for more of a streamed document once this much is shown
+	if m.follow {
+		m.viewport.GotoBottom()
+		m.follow = m.stream != nil
+	}
+	if m.stream != nil && !m.streamWaiting {
+		m.streamWaiting = true
+		cmds = append(cmds, m.stream.next)
+	}
+	return cmds
+}
+
 // scrollOff is the number of lines to keep visible above/below when jumping to headings.
 // Similar to Vim's scrolloff setting.
 const scrollOff = 5
 
+// scrollContext returns how many lines are kept visible above a heading
+// jumped to: the configured scrolloff, or else scrollOff.
+func (m pagerModel) scrollContext() int {
+	if m.common.cfg.ScrollOff != nil {
+		return max(*m.common.cfg.ScrollOff, 0)
+	}
+	return scrollOff
+}
+
 // jumpToHeading scrolls the viewport to show the heading at the given index.
-// The heading is positioned with scrollOff lines of context above it.
+// The heading is positioned with scrollContext lines of context above it.
 func (m *pagerModel) jumpToHeading(headingIndex int) {
 	if headingIndex < 0 || headingIndex >= len(m.outline.headings) {
 		return
@@ -412,20 +674,34 @@ func (m *pagerModel) jumpToHeading(headingIndex int) {
 
 	heading := m.outline.headings[headingIndex]
 
-	// Use pre-computed rendered line position
+	// Wait for the chunk of a long document the heading is in to render
+	if heading.RenderedLine < 0 && m.pending != nil {
+		m.pendingHeading = headingIndex
+		m.outline.cursor = headingIndex
+		m.outline.updateViewport()
+		return
+	}
+
+	// Use pre-computed rendered line position, or else the rendered line
+	// of the last line before the heading the source map knows
 	targetLine := heading.RenderedLine
 	if targetLine < 0 {
-		// Fallback to ratio-based approximation if not mapped
-		totalRawLines := strings.Count(m.currentDocument.Body, "\n") + 1
-		if totalRawLines == 0 {
-			return
-		}
-		ratio := float64(heading.Line) / float64(totalRawLines)
-		targetLine = int(ratio * float64(m.viewport.TotalLineCount()))
+		targetLine, _ = m.sourceMap.Rendered(heading.Line)
+	}
+	if targetLine < 0 {
+		return
 	}
 
-	// Apply scroll offset so heading appears scrollOff lines from top
-	scrollTarget := targetLine - scrollOff
+	m.scrollToLine(targetLine)
+	m.outline.current = headingIndex
+	m.outline.cursor = headingIndex
+	m.outline.updateViewport()
+}
+
+// scrollToLine scrolls the viewport so the given rendered line appears
+// scrollContext lines from the top.
+func (m *pagerModel) scrollToLine(targetLine int) {
+	scrollTarget := targetLine - m.scrollContext()
 	if scrollTarget < 0 {
 		scrollTarget = 0
 	}
@@ -440,31 +716,48 @@ func (m *pagerModel) jumpToHeading(headingIndex int) {
 	}
 
 	m.viewport.YOffset = scrollTarget
-	m.outline.current = headingIndex
-	m.outline.cursor = headingIndex
-	m.outline.updateViewport()
 }
 
-// updateCurrentHeading updates the outline's current heading based on scroll position.
-func (m *pagerModel) updateCurrentHeading() {
-	if len(m.outline.headings) == 0 {
+// scrollAnchor is a line of the document the view is kept at when it's
+// rendered again, and how many rendered lines below it the view starts.
+type scrollAnchor struct {
+	line   int
+	offset int
+}
+
+// anchorScroll keeps the view at the line of the document at its top once
+// the document is rendered again.
+func (m *pagerModel) anchorScroll() {
+	line := m.sourceMap.Source(m.viewport.YOffset)
+	if line < 0 || m.viewport.YOffset == 0 {
+		m.anchor = nil
 		return
 	}
+	rendered, _ := m.sourceMap.Rendered(line)
+	m.anchor = &scrollAnchor{line: line, offset: m.viewport.YOffset - rendered}
+}
 
-	// Calculate current line from scroll position
-	currentLine := m.viewport.YOffset
-
-	// Convert to approximate raw markdown line
-	totalRenderedLines := m.viewport.TotalLineCount()
-	if totalRenderedLines == 0 {
+// restoreScroll scrolls the view back to its anchor, if it has one.
+func (m *pagerModel) restoreScroll() {
+	if m.anchor == nil {
 		return
 	}
+	if rendered, _ := m.sourceMap.Rendered(m.anchor.line); rendered >= 0 {
+		m.viewport.SetYOffset(rendered + m.anchor.offset)
+	}
+	m.anchor = nil
+}
 
-	totalRawLines := strings.Count(m.currentDocument.Body, "\n") + 1
-	ratio := float64(currentLine) / float64(totalRenderedLines)
-	rawLine := int(ratio * float64(totalRawLines))
+// updateCurrentHeading updates the outline's current heading based on scroll position.
+func (m *pagerModel) updateCurrentHeading() {
+	if len(m.outline.headings) == 0 {
+		return
+	}
 
-	m.outline.updateCurrent(rawLine)
+	// The line of the document at the top of the view
+	if line := m.sourceMap.Source(m.viewport.YOffset); line >= 0 {
+		m.outline.updateCurrent(line)
+	}
 }
 
 func (m pagerModel) View() string {
@@ -473,11 +766,17 @@ func (m pagerModel) View() string {
 	// Main content
 	content := m.viewport.View()
 
-	// Add outline sidebar if visible
-	if m.outline.visible && len(m.outline.headings) > 0 {
+	if m.state == pagerStateDiagramList || m.state == pagerStateDiagram {
+		// Diagrams take the full screen
+		content = m.diagramsView()
+	} else if m.outline.visible && len(m.outline.headings) > 0 {
+		// Add outline sidebar if visible
 		content = m.joinContentAndOutline(content, m.outline.View())
 	}
 
+	if m.hasTabs() && m.state != pagerStateDiagramList && m.state != pagerStateDiagram {
+		fmt.Fprint(&b, m.tabBarView()+"\n")
+	}
 	fmt.Fprint(&b, content+"\n")
 
 	// Footer
@@ -552,19 +851,40 @@ func (m pagerModel) statusBarView(b *strings.Builder) {
 	}
 
 	// "Help" note
-	var helpNote string
+	helpNote := " " + m.keys.help(actionHelp) + " Help "
 	if showStatusMessage {
-		helpNote = statusBarMessageHelpStyle(" ? Help ")
+		helpNote = statusBarMessageHelpStyle(helpNote)
 	} else {
-		helpNote = statusBarHelpStyle(" ? Help ")
+		helpNote = statusBarHelpStyle(helpNote)
+	}
+
+	// Search and language prompts
+	if m.state == pagerStateSearch || m.state == pagerStateLanguage {
+		input := &m.searchInput
+		if m.state == pagerStateLanguage {
+			input = &m.languageInput
+		}
+		input.Width = max(0, m.common.width-
+			ansi.PrintableRuneWidth(logo)-
+			ansi.PrintableRuneWidth(input.Prompt)-1)
+		prompt := input.View()
+		padding := max(0, m.common.width-ansi.PrintableRuneWidth(logo)-ansi.PrintableRuneWidth(prompt))
+		fmt.Fprintf(b, "%s%s%s", logo, prompt, strings.Repeat(" ", padding))
+		return
 	}
 
 	// Note
 	var note string
 	if showStatusMessage {
 		note = m.statusMessage
+	} else if m.state == pagerStateHints {
+		note = m.hintsStatus()
+	} else if m.state == pagerStateDiagram {
+		note = fmt.Sprintf("Diagram %d of %d", m.diagramCursor+1, len(m.diagrams))
+	} else if m.searchActive() {
+		note = m.searchStatus()
 	} else {
-		note = m.currentDocument.Note
+		note = m.currentDocument.displayName()
 	}
 	note = truncate.StringWithTail(" "+note+" ", uint(max(0, //nolint:gosec
 		m.common.width-
@@ -603,20 +923,33 @@ func (m pagerModel) statusBarView(b *strings.Builder) {
 }
 
 func (m pagerModel) helpView() (s string) {
+	k := m.keys.help
+	item := func(keys, desc string) string {
+		return fmt.Sprintf("%-7s %s", keys, desc)
+	}
+
 	col1 := []string{
-		"g/home  go to top",
-		"G/end   go to bottom",
-		"c       copy contents",
-		"e       edit this document",
-		"r       reload this document",
-		"esc     back to files",
-		"q       quit",
+		item(k(actionTop), "go to top"),
+		item(k(actionBottom), "go to bottom"),
+		item(k(actionCopy), "copy contents"),
+		item(k(actionEdit), "edit this document"),
+		item(k(actionReload), "reload this document"),
+		item("esc", "back to files"),
+		item("q", "quit"),
 	}
 
 	col2 := []string{
-		"o       toggle outline",
-		"tab     focus outline",
-		"]/[     next/prev heading",
+		item(k(actionToggleOutline), "toggle outline"),
+		item(k(actionFocusOutline), "focus outline"),
+		item(k(actionNextHeading, actionPrevHeading), "next/prev heading"),
+		item(k(actionSearch), "search"),
+		item(k(actionNextMatch, actionPrevMatch), "next/prev match"),
+		item(k(actionPanLeft, actionPanRight), "pan wide diagrams"),
+		item(k(actionDiagrams), "view diagrams"),
+		item(k(actionLanguage), "change language"),
+		item(k(actionFollowLink), "follow a link"),
+		item(k(actionToggleDetails), "fold/unfold details"),
+		item(k(actionNextTab, actionPrevTab), "next/prev tab"),
 	}
 
 	s += "\n"
@@ -624,8 +957,8 @@ func (m pagerModel) helpView() (s string) {
 	s += "j/↓      down                " + col1[1] + "\n"
 	s += "b/pgup   page up             " + col1[2] + "\n"
 	s += "f/pgdn   page down           " + col1[3] + "\n"
-	s += "u        ½ page up           " + col1[4] + "\n"
-	s += "d        ½ page down         " + col1[5] + "\n"
+	s += fmt.Sprintf("%-8s ½ page up           ", k(actionHalfPageUp)) + col1[4] + "\n"
+	s += fmt.Sprintf("%-8s ½ page down         ", k(actionHalfPageDown)) + col1[5] + "\n"
 	s += "                             " + col1[6] + "\n"
 	s += "\n"
 	for _, item := range col2 {
@@ -652,38 +985,107 @@ func (m pagerModel) helpView() (s string) {
 // COMMANDS
 
 func renderWithGlamour(m pagerModel, md string) tea.Cmd {
+	ctx := m.renders.start()
+	kept := m.parsedModel(md)
+	if m.renderChunked(md) {
+		return renderChunks(ctx, m, md, kept)
+	}
 	return func() tea.Msg {
-		s, err := glamourRender(m, md)
+		s, sourceMap, doc, err := glamourRenderChunk(ctx, m, documentChunk{markdown: md}, renderWidth(m, md))
+		if ctx.Err() != nil {
+			// Another render replaced this one
+			return nil
+		}
 		if err != nil {
 			log.Error("error rendering with Glamour", "error", err)
 			return errMsg{err}
 		}
-		return contentRenderedMsg(s)
+		return contentRenderedMsg{content: s, sourceMap: sourceMap, parsed: renderedModel(m, kept, md, doc)}
 	}
 }
 
 // This is where the magic happens.
 func glamourRender(m pagerModel, markdown string) (string, error) {
+	s, _, _, err := glamourRenderChunk(context.Background(), m, documentChunk{markdown: markdown}, renderWidth(m, markdown))
+	return s, err
+}
+
+// renderWidth returns the width a document is rendered at.
+func renderWidth(m pagerModel, markdown string) int {
+	width := max(0, min(int(m.common.cfg.GlamourMaxWidth), m.viewport.Width)) //nolint:gosec
+	if m.common.cfg.AutoWidth {
+		width = utils.AutoWidth(markdown, m.viewport.Width)
+	}
+	if w := m.common.cfg.documentOptions(m.currentDocument.options).Width; w > 0 {
+		width = min(w, m.viewport.Width)
+	}
+	return width
+}
+
+// glamourRenderChunk renders a document, or a chunk of one, at width, until
+// ctx is done. It returns where the lines of the document were rendered too,
+// if the renderer tells, and the structure of what was rendered.
+func glamourRenderChunk(ctx context.Context, m pagerModel, chunk documentChunk, width int) (string, sourcemap.Map, document.Document, error) {
 	trunc := lipgloss.NewStyle().MaxWidth(m.viewport.Width - lineNumberWidth).Render
+	markdown := chunk.markdown
 
 	if !m.common.cfg.GlamourEnabled {
-		return markdown, nil
+		return markdown, nil, document.Parse([]byte(markdown)), nil
 	}
 
-	isCode := !utils.IsMarkdownFile(m.currentDocument.Note)
-	width := max(0, min(int(m.common.cfg.GlamourMaxWidth), m.viewport.Width)) //nolint:gosec
+	_, isCode := utils.CodeLanguage(m.currentDocument.Note, m.language)
+	options := m.common.cfg.documentOptions(m.currentDocument.options)
 
-	// Use the injected renderer
-	out, err := m.common.renderer.Render(
-		markdown,
+	// Use the injected renderer, in the style the document asks for if it
+	// does
+	renderer := m.common.renderer
+	if r, ok := renderer.(DocumentRenderer); ok {
+		renderer = r.ForDocument(options)
+	}
+
+	// Lay out wide tables, as the document's front matter asks if it does
+	strategy := m.common.cfg.Tables
+	switch {
+	case isCode:
+		strategy = tables.StrategyOff
+	case m.currentDocument.tables != "":
+		strategy = m.currentDocument.tables
+	}
+	wide := tables.NewPreprocessor(width, strategy)
+
+	// Show images as boxes, their sources resolved against the document's
+	// directory
+	imageWidth := width
+	if isCode {
+		imageWidth = 0
+	} else {
+		// Mark the lines of the document before they're moved about, so
+		// they're found once rendered, if the renderer tells where
+		if _, ok := renderer.(SourceMapRenderer); ok {
+			markdown = sourcemap.Mark(markdown, chunk.line)
+		}
+		markdown = htmlblocks.Process(markdown, func(n int, d htmlblocks.Details) bool {
+			return m.detailsOpen(chunk.details+n, d)
+		})
+	}
+	pictures := images.NewPreprocessor(imageWidth, filepath.Dir(m.currentDocument.localPath), true)
+
+	style := m.common.cfg.GlamourStyle
+	if options.Style != "" {
+		style = options.Style
+	}
+	out, sourceMap, doc, err := renderModel(ctx, renderer,
+		wide.Process(tables.ProcessCSV(pictures.Process(markdown))),
 		width,
-		m.common.cfg.GlamourStyle,
+		style,
 		m.currentDocument.Note,
+		m.language,
 		m.common.cfg.PreserveNewLines,
 	)
 	if err != nil {
-		return "", err
+		return "", nil, document.Document{}, err
 	}
+	out = pictures.Restore(wide.Restore(out))
 
 	// trim lines
 	lines := strings.Split(out, "\n")
@@ -703,7 +1105,7 @@ func glamourRender(m pagerModel, markdown string) (string, error) {
 		}
 	}
 
-	return content.String(), nil
+	return content.String(), sourceMap, doc, nil
 }
 
 func (m *pagerModel) initWatcher() {
@@ -747,6 +1149,9 @@ func (m *pagerModel) watchFile() tea.Msg {
 }
 
 func (m *pagerModel) unwatchFile() {
+	if m.watcher == nil {
+		return
+	}
 	dir := m.localDir()
 
 	err := m.watcher.Remove(dir)
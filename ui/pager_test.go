@@ -1,18 +1,23 @@
 package ui
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
 
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hholst80/glow/utils"
 )
 
 // newTestPagerModel creates a pagerModel configured for testing.
 // It uses a TestMarkdownRenderer and TestTerminal and sets up minimal state.
 func newTestPagerModel() pagerModel {
 	renderer := &TestMarkdownRenderer{
-		RenderFunc: func(markdown string, width int, style string, filename string, preserveNewLines bool) (string, error) {
+		RenderFunc: func(markdown string, width int, style string, filename string, language string, preserveNewLines bool) (string, error) {
 			// Return markdown as-is for predictable test behavior
 			return markdown, nil
 		},
@@ -40,10 +45,13 @@ func newTestPagerModel() pagerModel {
 	vp.YPosition = 0
 
 	return pagerModel{
-		common:   common,
-		state:    pagerStateBrowse,
-		viewport: vp,
-		outline:  newOutlineModel(common),
+		common:        common,
+		state:         pagerStateBrowse,
+		viewport:      vp,
+		outline:       newOutlineModel(common),
+		searchInput:   newSearchInput(),
+		languageInput: newLanguageInput(),
+		keys:          newKeyMap(nil),
 		currentDocument: markdown{
 			Note: "test.md",
 			Body: "# Test\n\nContent",
@@ -240,7 +248,7 @@ func TestPagerUpdate_ContentRenderedMsg(t *testing.T) {
 	m.currentDocument.Body = "# Test Heading\n\nSome content."
 
 	content := "rendered content\nline 2\nline 3"
-	msg := contentRenderedMsg(content)
+	msg := contentRenderedMsg{content: content}
 	newM, _ := m.update(msg)
 
 	// Viewport should have the rendered content
@@ -357,6 +365,186 @@ func TestPagerUpdate_EnterOnFocusedOutline(t *testing.T) {
 	}
 }
 
+// TestPagerUpdate_Search tests searching the document and the outline's
+// per-section hit counts.
+func TestPagerUpdate_Search(t *testing.T) {
+	m := newTestPagerModel()
+	m.showOutline = true
+	m.outline.visible = true
+	m.outline.headings = []Heading{
+		{Level: 1, Text: "First", Line: 0, RenderedLine: 0},
+		{Level: 2, Text: "Second", Line: 20, RenderedLine: 20},
+		{Level: 2, Text: "Third", Line: 40, RenderedLine: 40},
+	}
+
+	lines := make([]string, 60)
+	for i := range lines {
+		lines[i] = "line"
+	}
+	lines[3] = "a Needle here"
+	lines[25] = "needle"
+	lines[30] = "NEEDLE again"
+	m.setContent(strings.Join(lines, "\n"))
+
+	// Open the prompt, type the query and confirm
+	newM, _ := m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("/")})
+	if newM.state != pagerStateSearch {
+		t.Fatalf("expected search state, got %v", newM.state)
+	}
+	newM, _ = newM.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("needle")})
+	newM, _ = newM.update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	if newM.state != pagerStateBrowse {
+		t.Errorf("expected browse state after enter, got %v", newM.state)
+	}
+	if want := []int{3, 25, 30}; !reflect.DeepEqual(newM.searchMatches, want) {
+		t.Errorf("expected matches %v, got %v", want, newM.searchMatches)
+	}
+	if want := []int{1, 2, 0}; !reflect.DeepEqual(newM.outline.matchCounts, want) {
+		t.Errorf("expected match counts %v, got %v", want, newM.outline.matchCounts)
+	}
+
+	// n moves to the next match
+	newM, _ = newM.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	if newM.searchIndex != 1 {
+		t.Errorf("expected searchIndex=1, got %d", newM.searchIndex)
+	}
+
+	// enter on a focused outline entry jumps to the section's first match
+	newM.outlineFocused = true
+	newM.outline.cursor = 1
+	newM.searchIndex = 0
+	newM, _ = newM.update(tea.KeyMsg{Type: tea.KeyEnter})
+	if newM.searchIndex != 1 {
+		t.Errorf("expected jump to match 1, got %d", newM.searchIndex)
+	}
+	if newM.viewport.YOffset != 25-scrollOff {
+		t.Errorf("expected YOffset=%d, got %d", 25-scrollOff, newM.viewport.YOffset)
+	}
+
+	// esc clears the search
+	newM.outlineFocused = false
+	newM, _ = newM.update(tea.KeyMsg{Type: tea.KeyEsc})
+	if newM.searchActive() || newM.outline.matchCounts != nil {
+		t.Error("expected esc to clear the search")
+	}
+}
+
+// TestPagerUpdate_Language tests changing the language the document is
+// shown as.
+func TestPagerUpdate_Language(t *testing.T) {
+	m := newTestPagerModel()
+	renderer := m.common.renderer.(*TestMarkdownRenderer)
+
+	newM, _ := m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("L")})
+	if newM.state != pagerStateLanguage {
+		t.Fatalf("expected language state, got %v", newM.state)
+	}
+	newM, _ = newM.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("python")})
+	newM, cmd := newM.update(tea.KeyMsg{Type: tea.KeyEnter})
+	if newM.state != pagerStateBrowse || newM.language != "python" {
+		t.Fatalf("expected browse state with language python, got %v with %q", newM.state, newM.language)
+	}
+	if newM.isMarkdownFile() {
+		t.Error("expected the document to be shown as code")
+	}
+	if cmd == nil {
+		t.Fatal("expected the document to be rendered again")
+	}
+	cmd()
+	if n := len(renderer.RenderCalls); n == 0 || renderer.RenderCalls[n-1].Language != "python" {
+		t.Errorf("expected a render in python, got %+v", renderer.RenderCalls)
+	}
+
+	// An empty language detects it from the extension again
+	newM, _ = newM.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("L")})
+	for range "python" {
+		newM, _ = newM.update(tea.KeyMsg{Type: tea.KeyBackspace})
+	}
+	newM, _ = newM.update(tea.KeyMsg{Type: tea.KeyEnter})
+	if newM.language != "" || !newM.isMarkdownFile() {
+		t.Errorf("expected the language to be detected again, got %q", newM.language)
+	}
+}
+
+// TestPagerUpdate_Tabs tests switching between files opened as tabs.
+func TestPagerUpdate_Tabs(t *testing.T) {
+	dir := t.TempDir()
+	var tabs []*markdown
+	for _, name := range []string{"a.md", "b.md"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("# "+name+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		tabs = append(tabs, &markdown{localPath: path, Note: name})
+	}
+	m := newTestPagerModel()
+	m.tabs, m.tabOffsets = tabs, make([]int, len(tabs))
+	m.currentDocument = *tabs[0]
+	m.setSize(80, 24)
+	if m.viewport.Height != 24-statusBarHeight-tabBarHeight {
+		t.Errorf("expected room for the tab bar, got viewport height %d", m.viewport.Height)
+	}
+	if bar := stripANSI(m.tabBarView()); !strings.Contains(bar, "a.md") || !strings.Contains(bar, "b.md") {
+		t.Errorf("expected both tabs in the tab bar, got %q", bar)
+	}
+
+	m.viewport.YOffset = 3
+	newM, cmd := m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("}")})
+	if newM.tab != 1 || newM.tabOffsets[0] != 3 || newM.viewport.YOffset != 0 {
+		t.Errorf("expected tab 1 with tab 0 kept at 3, got tab %d, offsets %v, offset %d", newM.tab, newM.tabOffsets, newM.viewport.YOffset)
+	}
+	if md, ok := cmd().(fetchedMarkdownMsg); !ok || md.Body != "# b.md\n" {
+		t.Errorf("expected b.md to be loaded, got %+v", md)
+	}
+
+	// Tabs wrap around
+	newM, _ = newM.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("}")})
+	if newM.tab != 0 || newM.viewport.YOffset != 3 {
+		t.Errorf("expected tab 0 at its offset 3, got tab %d at %d", newM.tab, newM.viewport.YOffset)
+	}
+}
+
+func TestBackgroundTabs(t *testing.T) {
+	initSections()
+	s := newStashModel(&commonModel{width: 80, height: 40})
+	a := &markdown{Note: "a.md", localPath: "/docs/a.md"}
+	b := &markdown{Note: "b.md", localPath: "/docs/b.md"}
+	s.addMarkdowns(a, b)
+
+	// O opens the file in a tab without leaving the listing, once
+	for range 2 {
+		s, _ = s.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("O")})
+	}
+	if !slices.Equal(s.tabs, []*markdown{a}) || s.viewState != stashStateReady {
+		t.Fatalf("expected a in a background tab, got %v in state %d", s.tabs, s.viewState)
+	}
+	if header := stripANSI(s.headerView()); !strings.Contains(header, "1 in tabs") {
+		t.Errorf("expected the header to count the tabs, got %q", header)
+	}
+
+	// The file opened next is shown after them, each keeping its offset
+	m := newTestPagerModel()
+	m.addTabs(s.tabs)
+	m.selectTab(b)
+	if !slices.Equal(m.tabs, []*markdown{a, b}) || m.tab != 1 {
+		t.Fatalf("expected b shown after a, got tab %d of %v", m.tab, m.tabs)
+	}
+	m.viewport.YOffset = 5
+	m.selectTab(&markdown{Note: "b.md", localPath: "/docs/b.md"})
+	m.selectTab(a)
+	if len(m.tabs) != 2 || m.tab != 0 || m.tabOffsets[1] != 5 {
+		t.Errorf("expected a shown with b kept at 5, got tab %d of %v, offsets %v", m.tab, m.tabs, m.tabOffsets)
+	}
+
+	// Files already open, or queued twice, get no tab of their own
+	c := &markdown{Note: "c.md", localPath: "/docs/c.md"}
+	m.addTabs([]*markdown{{Note: "a.md", localPath: "/docs/a.md"}, c, c})
+	if !slices.Equal(m.tabs, []*markdown{a, b, c}) || len(m.tabOffsets) != 3 || m.tabOffsets[1] != 5 {
+		t.Errorf("expected c added once, got %v, offsets %v", m.tabs, m.tabOffsets)
+	}
+}
+
 // TestPagerUpdate_CursorMovement tests j/k keys when outline is focused.
 func TestPagerUpdate_CursorMovement(t *testing.T) {
 	m := newTestPagerModel()
@@ -494,3 +682,254 @@ func TestGlamourRender_UsesInjectedRenderer(t *testing.T) {
 		t.Errorf("expected filename='test.md', got %q", call.Filename)
 	}
 }
+
+// TestGlamourRender_DocumentOptions tests that a document's front matter
+// sets the style and width it's rendered with, up to the viewport's width.
+func TestGlamourRender_DocumentOptions(t *testing.T) {
+	m := newTestPagerModel()
+	renderer := m.common.renderer.(*TestMarkdownRenderer)
+
+	m.currentDocument.options = utils.RenderOptions{Style: "light", Width: 60}
+	_, _ = glamourRender(m, "# Test")
+	m.currentDocument.options = utils.RenderOptions{Width: 200}
+	_, _ = glamourRender(m, "# Test")
+
+	if len(renderer.RenderCalls) != 2 {
+		t.Fatalf("expected 2 render calls, got %d", len(renderer.RenderCalls))
+	}
+	if call := renderer.RenderCalls[0]; call.Style != "light" || call.Width != 60 {
+		t.Errorf("expected style='light' and width=60, got %q and %d", call.Style, call.Width)
+	}
+	if call := renderer.RenderCalls[1]; call.Style != "dark" || call.Width != 80 {
+		t.Errorf("expected style='dark' and width=80, got %q and %d", call.Style, call.Width)
+	}
+
+	// Flags given on the command line override the front matter
+	m.common.cfg.Flags = map[string]bool{"style": true, "width": true}
+	m.currentDocument.options = utils.RenderOptions{Style: "light", Width: 60}
+	_, _ = glamourRender(m, "# Test")
+	if call := renderer.RenderCalls[2]; call.Style != "dark" || call.Width != 80 {
+		t.Errorf("expected the flags' style='dark' and width=80, got %q and %d", call.Style, call.Width)
+	}
+}
+
+// TestLocalDocument_RenderOptions tests that the render options under the
+// glow key of a document's front matter are read, skipping invalid ones.
+func TestLocalDocument_RenderOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc.md")
+	content := "---\nglow: {style: Light, width: wide, outline: yes, mermaid: off}\n---\n# Doc\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := localDocument(path, filepath.Dir(path), info).options
+	if got.Style != "light" || got.Width != 0 || got.Mermaid != "off" || got.Outline == nil || !*got.Outline {
+		t.Errorf("unexpected options: %+v", got)
+	}
+}
+
+// TestApplyOutlineOption tests that documents show or hide the outline as
+// they ask, and the configured setting returns after them.
+func TestApplyOutlineOption(t *testing.T) {
+	m := newTestPagerModel()
+	show, hide := true, false
+	shown := markdown{options: utils.RenderOptions{Outline: &show}}
+	hidden := markdown{options: utils.RenderOptions{Outline: &hide}}
+
+	m.applyOutlineOption(markdown{}, shown)
+	if !m.showOutline {
+		t.Error("expected the outline to be shown")
+	}
+	m.outlineFocused = true
+	m.applyOutlineOption(shown, hidden)
+	if m.showOutline || m.outlineFocused {
+		t.Error("expected the outline to be hidden and unfocused")
+	}
+
+	m.common.cfg.ShowOutline = true
+	m.applyOutlineOption(hidden, markdown{})
+	if !m.showOutline {
+		t.Error("expected the configured outline setting after a document that set its own")
+	}
+	m.showOutline = false
+	m.applyOutlineOption(markdown{}, markdown{})
+	if m.showOutline {
+		t.Error("expected the outline to stay as it is between documents that don't set it")
+	}
+}
+
+// TestPanLines tests that only lines wider than the viewport pan.
+func TestPanLines(t *testing.T) {
+	content := "short\n0123456789abcdef"
+
+	tests := []struct {
+		offset int
+		want   string
+	}{
+		{0, "short\n0123456›"},
+		{4, "short\n‹56789a›"},
+		{8, "short\n‹9abcdef"},
+	}
+	for _, tt := range tests {
+		if got := panLines(content, 8, tt.offset); got != tt.want {
+			t.Errorf("panLines(%d) = %q, want %q", tt.offset, got, tt.want)
+		}
+	}
+
+	if got := maxPanOffset(content, 8); got != 8 {
+		t.Errorf("maxPanOffset() = %d, want 8", got)
+	}
+}
+
+// TestPagerUpdate_Pan tests panning wide lines with < and >.
+func TestPagerUpdate_Pan(t *testing.T) {
+	m := newTestPagerModel()
+	m.viewport.Width = 10
+	m.setContent("text\n" + strings.Repeat("─", 12) + "end")
+
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(">")})
+	if m.xOffset != 5 {
+		t.Errorf("Expected to pan to the end of the widest line, got offset %d", m.xOffset)
+	}
+	if !strings.Contains(m.viewport.View(), "end") {
+		t.Errorf("Expected the end of the wide line to be visible:\n%s", m.viewport.View())
+	}
+
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("<")})
+	if m.xOffset != 0 {
+		t.Errorf("Expected to pan back to the start, got offset %d", m.xOffset)
+	}
+	if !strings.HasPrefix(m.viewport.View(), "text") {
+		t.Errorf("Expected narrow lines to stay put:\n%s", m.viewport.View())
+	}
+}
+
+// TestPagerUpdate_Diagrams tests listing diagrams and opening one full screen.
+func TestPagerUpdate_Diagrams(t *testing.T) {
+	m := newTestPagerModel()
+	m.currentDocument.Body = "# Test\n\n```mermaid\ngraph LR\n  A --> B\n```\n\n```mermaid\nsequenceDiagram\n  Alice->>Bob: Hi\n```\n"
+	m.setContent(strings.Repeat("line\n", 100))
+	m.viewport.YOffset = 42
+
+	key := func(s string) tea.KeyMsg {
+		switch s {
+		case "enter":
+			return tea.KeyMsg{Type: tea.KeyEnter}
+		case "esc":
+			return tea.KeyMsg{Type: tea.KeyEsc}
+		}
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+	}
+
+	m, _ = m.update(key("D"))
+	if m.state != pagerStateDiagramList || len(m.diagrams) != 2 {
+		t.Fatalf("Expected a list of 2 diagrams, got state %v with %d", m.state, len(m.diagrams))
+	}
+	if !m.capturesKeys() {
+		t.Error("Expected the diagram list to capture keys")
+	}
+	if view := m.View(); !strings.Contains(view, "1. graph LR") || !strings.Contains(view, "2. sequenceDiagram") {
+		t.Errorf("Expected the diagrams to be listed:\n%s", view)
+	}
+
+	m, _ = m.update(key("j"))
+	m, _ = m.update(key("enter"))
+	if m.state != pagerStateDiagram {
+		t.Fatalf("Expected the diagram view, got state %v", m.state)
+	}
+	if view := m.View(); !strings.Contains(view, "Alice") || !strings.Contains(view, "Diagram 2 of 2") {
+		t.Errorf("Expected the second diagram full screen:\n%s", view)
+	}
+
+	m, _ = m.update(key("esc"))
+	m, _ = m.update(key("q"))
+	if m.state != pagerStateBrowse {
+		t.Errorf("Expected to be back in the document, got state %v", m.state)
+	}
+	if m.viewport.YOffset != 42 {
+		t.Errorf("Expected the document position to be kept, got %d", m.viewport.YOffset)
+	}
+}
+
+// TestPagerUpdate_NoDiagrams tests the message shown without diagrams.
+func TestPagerUpdate_NoDiagrams(t *testing.T) {
+	m := newTestPagerModel()
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("D")})
+	if m.state != pagerStateStatusMessage || m.statusMessage != "No diagrams in this document" {
+		t.Errorf("Expected a status message, got state %v: %q", m.state, m.statusMessage)
+	}
+}
+
+// TestPagerUpdate_FollowLink tests following the wiki links on screen.
+func TestPagerUpdate_FollowLink(t *testing.T) {
+	m := newTestPagerModel()
+	m.currentDocument.Body = "# Notes\n\nSee [[Plan]] and [[Ideas|ideas]]."
+	m, _ = m.update(contentRenderedMsg{content: "Notes\n\nSee Plan and ideas."})
+
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("F")})
+	if m.state != pagerStateHints {
+		t.Fatalf("expected state=pagerStateHints, got %v", m.state)
+	}
+	if got := m.hintsStatus(); got != "a Plan · s ideas" {
+		t.Errorf("hintsStatus() = %q", got)
+	}
+
+	m, cmd := m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	if m.state != pagerStateBrowse {
+		t.Errorf("expected state=pagerStateBrowse, got %v", m.state)
+	}
+	if cmd == nil {
+		t.Fatal("expected a command to open the link")
+	}
+	if msg, ok := cmd().(openWikiLinkMsg); !ok || msg.Target != "Ideas" {
+		t.Errorf("expected a link to Ideas, got %#v", cmd())
+	}
+}
+
+// TestPagerUpdate_EnterWithoutLinks tests the message shown when there are no
+// links on screen to follow.
+func TestPagerUpdate_EnterWithoutLinks(t *testing.T) {
+	m := newTestPagerModel()
+	m, _ = m.update(contentRenderedMsg{content: "Test\n\nContent"})
+
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.state != pagerStateStatusMessage || m.statusMessage != "No links on screen" {
+		t.Errorf("Expected a status message, got state %v: %q", m.state, m.statusMessage)
+	}
+}
+
+// TestPagerUpdate_ToggleDetails tests unfolding the <details> section on
+// screen.
+func TestPagerUpdate_ToggleDetails(t *testing.T) {
+	m := newTestPagerModel()
+	m.currentDocument.Body = "# Notes\n\n<details>\n<summary>More</summary>\n\nHidden.\n\n</details>\n"
+	rendered, err := glamourRender(m, m.currentDocument.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(rendered, "Hidden.") {
+		t.Fatal("expected the section to be folded")
+	}
+	m, _ = m.update(contentRenderedMsg{content: rendered})
+
+	// The only section on screen is toggled without asking which
+	m, cmd := m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("z")})
+	if cmd == nil {
+		t.Fatal("expected a command to toggle the section")
+	}
+	msg, ok := cmd().(toggleDetailsMsg)
+	if !ok || msg != 0 {
+		t.Fatalf("expected the first section toggled, got %#v", msg)
+	}
+	m, cmd = m.update(msg)
+	if cmd == nil {
+		t.Fatal("expected a command to render the document again")
+	}
+	if out, ok := cmd().(contentRenderedMsg); !ok || !strings.Contains(out.content, "Hidden.") {
+		t.Errorf("expected the section unfolded, got %q", out.content)
+	}
+}
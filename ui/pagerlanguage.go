@@ -0,0 +1,51 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func newLanguageInput() textinput.Model {
+	li := textinput.New()
+	li.Prompt = " Language: "
+	li.Placeholder = "detect from the extension"
+	li.PromptStyle = stashInputPromptStyle
+	li.Cursor.Style = stashInputCursorStyle
+	return li
+}
+
+// startLanguage opens the prompt for the language the document is shown as.
+func (m *pagerModel) startLanguage() tea.Cmd {
+	m.state = pagerStateLanguage
+	m.languageInput.SetValue(m.language)
+	m.languageInput.CursorEnd()
+	m.languageInput.Focus()
+	return textinput.Blink
+}
+
+// updateLanguage handles key presses while the language prompt is open. The
+// document is rendered again in the language entered, or in the one its
+// extension gives if none is.
+func (m pagerModel) updateLanguage(msg tea.KeyMsg) (pagerModel, tea.Cmd) {
+	switch msg.String() {
+	case keyEsc:
+		m.languageInput.Blur()
+		m.state = pagerStateBrowse
+		return m, nil
+	case keyEnter:
+		m.languageInput.Blur()
+		m.state = pagerStateBrowse
+		m.language = strings.TrimSpace(m.languageInput.Value())
+		if !m.isMarkdownFile() {
+			m.outlineFocused = false
+		}
+		m.setSize(m.common.width, m.common.height)
+		return m, renderWithGlamour(m, m.currentDocument.Body)
+	}
+
+	var cmd tea.Cmd
+	m.languageInput, cmd = m.languageInput.Update(msg)
+	return m, cmd
+}
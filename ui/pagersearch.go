@@ -0,0 +1,192 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func newSearchInput() textinput.Model {
+	si := textinput.New()
+	si.Prompt = " /"
+	si.PromptStyle = stashInputPromptStyle
+	si.Cursor.Style = stashInputCursorStyle
+	return si
+}
+
+// findMatches returns the indices of the rendered lines that contain query.
+// Matching is case-insensitive and ignores ANSI styling.
+func findMatches(content, query string) []int {
+	if content == "" || query == "" {
+		return nil
+	}
+
+	query = strings.ToLower(query)
+
+	var matches []int
+	for i, line := range strings.Split(content, "\n") {
+		if strings.Contains(strings.ToLower(stripANSI(line)), query) {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+// searchActive reports whether a search query is currently applied.
+func (m pagerModel) searchActive() bool {
+	return m.searchQuery != ""
+}
+
+// startSearch opens the search prompt in the status bar.
+func (m *pagerModel) startSearch() tea.Cmd {
+	m.state = pagerStateSearch
+	m.searchInput.SetValue(m.searchQuery)
+	m.searchInput.CursorEnd()
+	m.searchInput.Focus()
+	return textinput.Blink
+}
+
+// updateSearch handles key presses while the search prompt is open.
+func (m pagerModel) updateSearch(msg tea.KeyMsg) (pagerModel, tea.Cmd) {
+	switch msg.String() {
+	case keyEsc:
+		m.searchInput.Blur()
+		m.state = pagerStateBrowse
+		return m, nil
+	case keyEnter:
+		m.searchInput.Blur()
+		m.state = pagerStateBrowse
+		m.applySearch(strings.TrimSpace(m.searchInput.Value()))
+		if len(m.searchMatches) > 0 {
+			m.searchIndex = m.matchIndexFrom(m.viewport.YOffset)
+			m.jumpToMatch(m.searchIndex)
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.searchInput, cmd = m.searchInput.Update(msg)
+	return m, cmd
+}
+
+// applySearch searches the rendered document for query and updates the
+// outline's per-section hit counts. An empty query clears the search.
+func (m *pagerModel) applySearch(query string) {
+	m.searchQuery = query
+	m.searchMatches = findMatches(m.renderedContent, query)
+	m.searchIndex = 0
+	m.outline.setMatches(m.searchMatches)
+}
+
+// clearSearch removes the active search.
+func (m *pagerModel) clearSearch() {
+	m.searchInput.Reset()
+	m.applySearch("")
+}
+
+// matchIndexFrom returns the index of the first match at or after the given
+// rendered line, wrapping around to the first match.
+func (m pagerModel) matchIndexFrom(line int) int {
+	for i, l := range m.searchMatches {
+		if l >= line {
+			return i
+		}
+	}
+	return 0
+}
+
+// nextMatch moves to the next search match, wrapping around at the end.
+func (m *pagerModel) nextMatch() {
+	if len(m.searchMatches) == 0 {
+		return
+	}
+	m.searchIndex = (m.searchIndex + 1) % len(m.searchMatches)
+	m.jumpToMatch(m.searchIndex)
+}
+
+// prevMatch moves to the previous search match, wrapping around at the start.
+func (m *pagerModel) prevMatch() {
+	if len(m.searchMatches) == 0 {
+		return
+	}
+	m.searchIndex = (m.searchIndex - 1 + len(m.searchMatches)) % len(m.searchMatches)
+	m.jumpToMatch(m.searchIndex)
+}
+
+// jumpToMatch scrolls the viewport to the match at the given index.
+func (m *pagerModel) jumpToMatch(index int) {
+	if index < 0 || index >= len(m.searchMatches) {
+		return
+	}
+	m.searchIndex = index
+	m.scrollToLine(m.searchMatches[index])
+}
+
+// jumpToSectionMatch scrolls to the first search match in the section of the
+// heading at the given index. It returns false if the section has no matches.
+func (m *pagerModel) jumpToSectionMatch(headingIndex int) bool {
+	line := m.outline.firstMatch(headingIndex)
+	if line < 0 {
+		return false
+	}
+	for i, l := range m.searchMatches {
+		if l == line {
+			m.jumpToMatch(i)
+			break
+		}
+	}
+	m.outline.current = headingIndex
+	m.outline.cursor = headingIndex
+	m.outline.updateViewport()
+	return true
+}
+
+// jumpToSearchHit searches the document for the query of a full-text search
+// hit and scrolls to the match of the hit, the nth one in the section of its
+// heading, or else to the first match.
+func (m *pagerModel) jumpToSearchHit(hit openSearchHitMsg) {
+	m.searchInput.SetValue(hit.query)
+	m.applySearch(hit.query)
+	if len(m.searchMatches) == 0 {
+		return
+	}
+
+	// The section is found among the headings of the document's structure,
+	// which counts lines from the end of its front matter
+	start, end := 0, -1
+	line := hit.line - m.currentDocument.bodyLine
+	for _, h := range m.documentModel().Headings {
+		rendered, exact := m.sourceMap.Rendered(h.Line - 1)
+		if !exact {
+			continue
+		}
+		if h.Line-1 > line {
+			end = rendered
+			break
+		}
+		start = rendered
+	}
+
+	n := 0
+	for i, l := range m.searchMatches {
+		if l < start || (end >= 0 && l >= end) {
+			continue
+		}
+		m.searchIndex = i
+		if n == hit.nth {
+			break
+		}
+		n++
+	}
+	m.jumpToMatch(m.searchIndex)
+}
+
+// searchStatus returns the status bar note for the active search.
+func (m pagerModel) searchStatus() string {
+	if len(m.searchMatches) == 0 {
+		return fmt.Sprintf("“%s” not found", m.searchQuery)
+	}
+	return fmt.Sprintf("“%s” %d/%d", m.searchQuery, m.searchIndex+1, len(m.searchMatches))
+}
@@ -0,0 +1,62 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// panStep is the number of columns wide lines move per key press.
+const panStep = 8
+
+// Markers shown where a panned line continues beyond the viewport.
+const (
+	panMarkerLeft  = "‹"
+	panMarkerRight = "›"
+)
+
+// panLines returns content with every line wider than width, such as the
+// lines of a diagram too wide for the page, shifted left by offset columns
+// and cut to width. Lines that fit are left alone, so only wide blocks pan.
+func panLines(content string, width, offset int) string {
+	if width <= 1 {
+		return content
+	}
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		lineWidth := ansi.StringWidth(line)
+		if lineWidth <= width {
+			continue
+		}
+
+		left, right := offset, offset+width
+		var prefix, suffix string
+		if left > 0 {
+			prefix = panMarkerLeft
+			left++
+		}
+		if right < lineWidth {
+			suffix = panMarkerRight
+			right--
+		}
+		lines[i] = prefix + ansi.Cut(line, left, right) + suffix
+	}
+	return strings.Join(lines, "\n")
+}
+
+// maxPanOffset returns how far content can be panned so that its widest line
+// ends at the right edge of the viewport.
+func maxPanOffset(content string, width int) int {
+	widest := 0
+	for _, line := range strings.Split(content, "\n") {
+		widest = max(widest, ansi.StringWidth(line))
+	}
+	return max(0, widest-width)
+}
+
+// pan moves wide lines n columns to the right, or left for negative n.
+func (m *pagerModel) pan(n int) {
+	m.xOffset = max(0, min(m.xOffset+n, maxPanOffset(m.renderedContent, m.viewport.Width)))
+	m.viewport.SetContent(panLines(m.renderedContent, m.viewport.Width, m.xOffset))
+}
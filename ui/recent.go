@@ -0,0 +1,150 @@
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const recentIcon = "↺ "
+
+const (
+	// How many of the files opened lately are listed first
+	recentLimit = 5
+
+	// How many files the history keeps at most
+	historyLimit = 20
+)
+
+// openRecord is how often and when a file was last opened from the listing.
+type openRecord struct {
+	Count int       `json:"count"`
+	Last  time.Time `json:"last"`
+}
+
+// frecency ranks a file by how often it's opened, weighing recent opens more,
+// much like browsers rank their history.
+func (r openRecord) frecency(now time.Time) int {
+	var weight int
+	switch age := now.Sub(r.Last); {
+	case age < 4*24*time.Hour:
+		weight = 100
+	case age < 14*24*time.Hour:
+		weight = 70
+	case age < 31*24*time.Hour:
+		weight = 50
+	case age < 90*24*time.Hour:
+		weight = 30
+	default:
+		weight = 10
+	}
+	return r.Count * weight
+}
+
+// loadHistory reads the files opened from the listing, by path. A missing
+// file has no history.
+func loadHistory(file string) (map[string]openRecord, error) {
+	history := make(map[string]openRecord)
+	if file == "" {
+		return history, nil
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return history, nil
+	}
+	if err != nil {
+		return history, err
+	}
+	if err := json.Unmarshal(data, &history); err != nil {
+		return make(map[string]openRecord), fmt.Errorf("unable to parse %s: %w", file, err)
+	}
+	return history, nil
+}
+
+// saveHistory writes the files opened from the listing.
+func saveHistory(file string, history map[string]openRecord) error {
+	if file == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0o600)
+}
+
+// pruneHistory drops the files with the lowest frecency from the history
+// until it keeps no more than historyLimit.
+func pruneHistory(history map[string]openRecord, now time.Time) {
+	if len(history) <= historyLimit {
+		return
+	}
+	paths := slices.Collect(maps.Keys(history))
+	slices.SortFunc(paths, func(a, b string) int {
+		return compareOpens(history[a], history[b], now)
+	})
+	for _, path := range paths[historyLimit:] {
+		delete(history, path)
+	}
+}
+
+// compareOpens orders the records of files by frecency, highest first, and
+// then by when they were last opened.
+func compareOpens(a, b openRecord, now time.Time) int {
+	if d := b.frecency(now) - a.frecency(now); d != 0 {
+		return d
+	}
+	return b.Last.Compare(a.Last)
+}
+
+// recordOpen counts an opening of a file and saves the history. Documents
+// joined from several files aren't counted.
+func (m *stashModel) recordOpen(md *markdown) {
+	if md.localPath == "" || len(md.parts) > 0 {
+		return
+	}
+	now := time.Now()
+	r := m.history[md.localPath]
+	r.Count++
+	r.Last = now
+	m.history[md.localPath] = r
+	pruneHistory(m.history, now)
+	if err := saveHistory(m.common.cfg.HistoryFile, m.history); err != nil {
+		log.Error("unable to save history", "error", err)
+	}
+	m.updateRecent()
+	m.selectMarkdown(md)
+}
+
+// updateRecent ranks the listed files that were opened before by frecency,
+// for the first few to be listed after the pinned files.
+func (m *stashModel) updateRecent() {
+	now := time.Now()
+	var recent []*markdown
+	for _, md := range m.markdowns {
+		md.recent = 0
+		if _, ok := m.history[md.localPath]; ok {
+			recent = append(recent, md)
+		}
+	}
+	slices.SortStableFunc(recent, func(a, b *markdown) int {
+		return compareOpens(m.history[a.localPath], m.history[b.localPath], now)
+	})
+	for i, md := range recent[:min(len(recent), recentLimit)] {
+		md.recent = i + 1
+	}
+	if !m.filterApplied() {
+		sortMarkdowns(m.markdowns, m.sort)
+	}
+}
@@ -0,0 +1,80 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFrecency(t *testing.T) {
+	now := time.Now()
+	often := openRecord{Count: 5, Last: now.Add(-60 * 24 * time.Hour)}
+	lately := openRecord{Count: 2, Last: now.Add(-time.Hour)}
+	if often.frecency(now) >= lately.frecency(now) {
+		t.Errorf("Expected a file opened lately to outrank one opened often long ago")
+	}
+	if (openRecord{Count: 10, Last: now}).frecency(now) <= lately.frecency(now) {
+		t.Errorf("Expected a file opened more often to rank higher")
+	}
+}
+
+func TestRecentFiles(t *testing.T) {
+	dir := t.TempDir()
+	history := filepath.Join(t.TempDir(), "history.json")
+	initSections()
+	m := newStashModel(&commonModel{width: 80, height: 40, cwd: dir, cfg: Config{HistoryFile: history}})
+	a := &markdown{localPath: filepath.Join(dir, "a.md"), Note: "a.md"}
+	b := &markdown{localPath: filepath.Join(dir, "b.md"), Note: "b.md"}
+	c := &markdown{localPath: filepath.Join(dir, "c.md"), Note: "c.md"}
+	d := &markdown{localPath: filepath.Join(dir, "d.md"), Note: "d.md", pinned: true}
+	m.addMarkdowns(a, b, c, d)
+	m, _ = m.update(localFileSearchFinished{})
+	if mds := m.getVisibleMarkdowns(); !slices.Equal(mds, []*markdown{d, a, b, c}) {
+		t.Fatalf("Expected the pinned file, then by path, got %v", mds)
+	}
+
+	// Files opened lately follow the pinned ones, by frecency
+	m.recordOpen(c)
+	m.recordOpen(b)
+	m.recordOpen(b)
+	if mds := m.getVisibleMarkdowns(); !slices.Equal(mds, []*markdown{d, b, c, a}) {
+		t.Fatalf("Expected d, b, c, then a, got %v", mds)
+	}
+	if len(m.sections) != 1 {
+		t.Errorf("Expected no section for the files opened lately, got %d sections", len(m.sections))
+	}
+	if md := m.selectedMarkdown(); md != b {
+		t.Errorf("Expected the opened file to stay selected, got %v", md)
+	}
+
+	// The history is kept for the next run
+	saved, err := loadHistory(history)
+	if err != nil || saved[b.localPath].Count != 2 || saved[c.localPath].Count != 1 {
+		t.Fatalf("Expected the opens to be saved, got %v, %v", saved, err)
+	}
+
+	// Files that go away leave the history
+	m.removeLocalPath(b.localPath)
+	if mds := m.getVisibleMarkdowns(); !slices.Equal(mds, []*markdown{d, c, a}) {
+		t.Fatalf("Expected d, c, then a, got %v", mds)
+	}
+}
+
+func TestPruneHistory(t *testing.T) {
+	now := time.Now()
+	history := map[string]openRecord{}
+	for i := range historyLimit + 5 {
+		history[fmt.Sprint(i)] = openRecord{Count: i + 1, Last: now}
+	}
+	pruneHistory(history, now)
+	if len(history) != historyLimit {
+		t.Fatalf("Expected %d files kept, got %d", historyLimit, len(history))
+	}
+	for i := range 5 {
+		if _, ok := history[fmt.Sprint(i)]; ok {
+			t.Errorf("Expected file %d, opened least, to be dropped", i)
+		}
+	}
+}
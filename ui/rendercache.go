@@ -0,0 +1,124 @@
+package ui
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// renderCacheVersion is part of every render cache key. Bump it when
+// rendered output changes so that stale documents aren't shown.
+const renderCacheVersion = 1
+
+// renderCacheEntries is how many rendered documents the cache keeps; the
+// ones used longest ago are removed beyond it.
+const renderCacheEntries = 200
+
+// renderCache keeps rendered documents on disk, keyed by a hash of their
+// markdown and of everything else their output depends on, so re-opening a
+// document, or going back to one in the file listing, doesn't render it
+// again. Changing a document changes its key, so stale entries are never
+// read; they're removed once they're the ones used longest ago.
+type renderCache struct {
+	dir string
+
+	// settings are the settings of the whole session that output depends
+	// on, such as the mermaid renderer, which are part of every key
+	settings string
+}
+
+// newRenderCache returns a cache in dir, keying documents by settings too.
+// It returns nil if dir is empty, which disables the cache.
+func newRenderCache(dir string, settings ...any) *renderCache {
+	if dir == "" {
+		return nil
+	}
+	return &renderCache{dir: dir, settings: fmt.Sprintf("%#v", settings)}
+}
+
+// key returns the cache file name of a document rendered from markdown with
+// options, such as its width and style.
+func (c *renderCache) key(markdown string, options ...any) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s\x00%#v\x00%s", renderCacheVersion, c.settings, options, markdown)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// get returns the rendered document of a key, marking it used, or false if
+// it isn't cached.
+func (c *renderCache) get(key string) (string, bool) {
+	path := filepath.Join(c.dir, key)
+	out, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return string(out), true
+}
+
+// put caches the rendered document of a key, removing the entries used
+// longest ago beyond renderCacheEntries. The cache is best effort, so errors
+// are ignored; the file is renamed into place so readers never see half of
+// it.
+func (c *renderCache) put(key, out string) {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil { //nolint:gosec
+		return
+	}
+	f, err := os.CreateTemp(c.dir, ".tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.WriteString(out)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(c.dir, key))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return
+	}
+	c.prune()
+}
+
+// prune removes the entries used longest ago beyond renderCacheEntries.
+func (c *renderCache) prune() {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil || len(entries) <= renderCacheEntries {
+		return
+	}
+	type entry struct {
+		name string
+		used time.Time
+	}
+	var files []entry
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			files = append(files, entry{e.Name(), info.ModTime()})
+		}
+	}
+	slices.SortFunc(files, func(a, b entry) int { return b.used.Compare(a.used) })
+	for _, f := range files[min(len(files), renderCacheEntries):] {
+		_ = os.Remove(filepath.Join(c.dir, f.name))
+	}
+}
+
+// renderSettings returns the settings of a session that rendered documents
+// depend on besides those RealMarkdownRenderer has: how diagrams are drawn.
+func renderSettings(cfg Config) []any {
+	settings := []any{cfg.MermaidCommand, cfg.MermaidForce, cfg.MermaidPlain, cfg.DiagramCommands}
+	if cfg.MermaidLimits != nil {
+		settings = append(settings, *cfg.MermaidLimits)
+	}
+	return settings
+}
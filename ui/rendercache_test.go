@@ -0,0 +1,108 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/hholst80/glow/mermaid"
+)
+
+// TestRealMarkdownRenderer_Cache tests that rendered documents are read from
+// the cache while their markdown and options stay the same.
+func TestRealMarkdownRenderer_Cache(t *testing.T) {
+	dir := t.TempDir()
+	r := NewMarkdownRenderer()
+	r.cache = newRenderCache(dir)
+
+	input := "# Cached\n\nText."
+	if _, err := r.Render(input, 80, "dark", "test.md", "", false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 cached document, got %d", len(entries))
+	}
+
+	// Mark the entry, so reading it shows
+	path := filepath.Join(dir, entries[0].Name())
+	if err := os.WriteFile(path, []byte("from the cache"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := r.Render(input, 80, "dark", "test.md", "", false); got != "from the cache" {
+		t.Errorf("expected the cached document, got %q", got)
+	}
+
+	for name, render := range map[string]func() (string, error){
+		"markdown": func() (string, error) { return r.Render(input+"!", 80, "dark", "test.md", "", false) },
+		"width":    func() (string, error) { return r.Render(input, 60, "dark", "test.md", "", false) },
+		"style":    func() (string, error) { return r.Render(input, 80, "light", "test.md", "", false) },
+		"image protocol": func() (string, error) {
+			r.ImageProtocol = mermaid.ProtocolKitty
+			defer func() { r.ImageProtocol = mermaid.ProtocolNone }()
+			return r.Render(input, 80, "dark", "test.md", "", false)
+		},
+	} {
+		if got, _ := render(); got == "from the cache" {
+			t.Errorf("expected a new %s to render again", name)
+		}
+	}
+	if got, _ := r.Render(input, 80, "dark", "test.md", "", false); got != "from the cache" {
+		t.Errorf("expected the cached document to stay, got %q", got)
+	}
+}
+
+// TestRealMarkdownRenderer_CacheSkipsFilters tests that documents filters
+// apply to aren't cached, since their output may change.
+func TestRealMarkdownRenderer_CacheSkipsFilters(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell commands")
+	}
+
+	dir := t.TempDir()
+	r := NewMarkdownRenderer()
+	r.cache = newRenderCache(dir)
+	r.Filters = mermaid.NewRegistry()
+	r.Filters.RegisterFilters(map[string]mermaid.Filter{"sh": {Command: "sh"}})
+
+	if _, err := r.Render("```sh\necho hi\n```", 80, "dark", "test.md", "", false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected nothing cached, got %d entries", len(entries))
+	}
+}
+
+// TestRenderCache_Prune tests that the entries used longest ago are removed
+// beyond the cache's size.
+func TestRenderCache_Prune(t *testing.T) {
+	dir := t.TempDir()
+	c := newRenderCache(dir)
+	old := time.Now().Add(-time.Hour)
+	for i := range renderCacheEntries {
+		name := filepath.Join(dir, fmt.Sprint(i))
+		if err := os.WriteFile(name, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		_ = os.Chtimes(name, old, old.Add(time.Duration(i)*time.Second))
+	}
+	if _, ok := c.get("0"); !ok {
+		t.Fatal("expected entry 0 to be cached")
+	}
+
+	c.put("new", "rendered")
+	if _, err := os.Stat(filepath.Join(dir, "1")); err == nil {
+		t.Error("expected the entry used longest ago to be removed")
+	}
+	for _, name := range []string{"0", "2", "new"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected entry %s to stay: %v", name, err)
+		}
+	}
+	if newRenderCache("") != nil {
+		t.Error("expected no cache without a directory")
+	}
+}
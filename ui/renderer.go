@@ -1,13 +1,24 @@
 package ui
 
 import (
+	"context"
 	"fmt"
-	"path/filepath"
+	"os"
 	"strings"
+	"time"
 
-	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/ansi"
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/hholst80/glow/alerts"
+	"github.com/hholst80/glow/datablocks"
+	"github.com/hholst80/glow/document"
+	"github.com/hholst80/glow/footnotes"
 	"github.com/hholst80/glow/mermaid"
+	"github.com/hholst80/glow/sourcemap"
 	"github.com/hholst80/glow/utils"
+	"github.com/hholst80/glow/wikilinks"
+	"github.com/muesli/termenv"
 )
 
 // MarkdownRenderer abstracts markdown-to-terminal rendering for testability.
@@ -20,13 +31,139 @@ type MarkdownRenderer interface {
 	//   - width: maximum width for word wrapping (0 = no limit)
 	//   - style: glamour style name (e.g., "dark", "light", "dracula")
 	//   - filename: used to detect code files by extension
+	//   - language: code language overriding the filename's, "" to detect it
 	//   - preserveNewLines: whether to preserve newlines in output
 	// Returns the rendered output or an error.
-	Render(markdown string, width int, style string, filename string, preserveNewLines bool) (string, error)
+	Render(markdown string, width int, style string, filename string, language string, preserveNewLines bool) (string, error)
+}
+
+// ContextRenderer is a MarkdownRenderer that stops rendering when its context
+// is cancelled, such as once the document is rendered again at a new size.
+type ContextRenderer interface {
+	MarkdownRenderer
+
+	// RenderContext renders like Render, returning ctx's error once it's
+	// done.
+	RenderContext(ctx context.Context, markdown string, width int, style string, filename string, language string, preserveNewLines bool) (string, error)
+}
+
+// renderContext renders markdown with r until ctx is done. Renderers that
+// can't be cancelled render unless ctx is done before they start.
+func renderContext(ctx context.Context, r MarkdownRenderer, markdown string, width int, style string, filename string, language string, preserveNewLines bool) (string, error) {
+	if cr, ok := r.(ContextRenderer); ok {
+		return cr.RenderContext(ctx, markdown, width, style, filename, language, preserveNewLines)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err //nolint:wrapcheck
+	}
+	return r.Render(markdown, width, style, filename, language, preserveNewLines)
+}
+
+// SourceMapRenderer is a MarkdownRenderer that also returns where the lines
+// of the markdown were rendered, so headings and the view's position can be
+// found in the output exactly.
+type SourceMapRenderer interface {
+	MarkdownRenderer
+
+	// RenderSourceMap renders like Render until ctx is done, returning the
+	// output without the marks of sourcemap.Mark and where the lines they
+	// marked were rendered. Markdown that isn't marked is marked from its
+	// first line, unless it's shown as code.
+	RenderSourceMap(ctx context.Context, markdown string, width int, style string, filename string, language string, preserveNewLines bool) (string, sourcemap.Map, error)
+}
+
+// renderSourceMap renders markdown with r until ctx is done, returning where
+// its lines were rendered too if r tells; nil if it doesn't.
+func renderSourceMap(ctx context.Context, r MarkdownRenderer, markdown string, width int, style string, filename string, language string, preserveNewLines bool) (string, sourcemap.Map, error) {
+	if sr, ok := r.(SourceMapRenderer); ok {
+		return sr.RenderSourceMap(ctx, markdown, width, style, filename, language, preserveNewLines)
+	}
+	out, err := renderContext(ctx, r, markdown, width, style, filename, language, preserveNewLines)
+	return out, nil, err
+}
+
+// ModelRenderer is a SourceMapRenderer that also returns the structure of
+// the documents it renders, read from the parse they're rendered from, which
+// the outline, the link hints and the search use rather than each reading
+// the markdown for what they need.
+type ModelRenderer interface {
+	SourceMapRenderer
+
+	// RenderModel renders like RenderSourceMap, returning the structure of
+	// markdown too: its headings, links, images, code blocks, tasks and wiki
+	// links, on the lines of markdown they were marked with.
+	RenderModel(ctx context.Context, markdown string, width int, style string, filename string, language string, preserveNewLines bool) (string, sourcemap.Map, document.Document, error)
+}
+
+// renderModel renders markdown with r until ctx is done, returning where its
+// lines were rendered and its structure too. Renderers that don't tell its
+// structure leave it to be parsed from markdown, on the lines it's marked
+// with.
+func renderModel(ctx context.Context, r MarkdownRenderer, markdown string, width int, style string, filename string, language string, preserveNewLines bool) (string, sourcemap.Map, document.Document, error) {
+	if mr, ok := r.(ModelRenderer); ok {
+		return mr.RenderModel(ctx, markdown, width, style, filename, language, preserveNewLines)
+	}
+	out, sourceMap, err := renderSourceMap(ctx, r, markdown, width, style, filename, language, preserveNewLines)
+	if err != nil {
+		return "", nil, document.Document{}, err
+	}
+	return out, sourceMap, parseMarked(markdown, false), nil
+}
+
+// parseMarked returns the structure of markdown on the lines it's marked
+// with, if it is.
+func parseMarked(markdown string, emojis bool) document.Document {
+	p := sourcemap.Parse(markdown, emojis)
+	return document.FromAST(p.Doc, p.Source, p.Line)
 }
 
 // RealMarkdownRenderer implements MarkdownRenderer using glamour and mermaid.
-type RealMarkdownRenderer struct{}
+// Documents are rendered as glamour renders them, through sourcemap.Render,
+// so the marks of sourcemap.Mark never change how they're parsed.
+type RealMarkdownRenderer struct {
+	// Diagrams renders mermaid diagrams; nil uses the ASCII renderer
+	Diagrams mermaid.Renderer
+
+	// DiagramTimeout limits how long each diagram may take to render;
+	// 0 uses mermaid.DefaultRenderTimeout
+	DiagramTimeout time.Duration
+
+	// DiagramMode mermaid.ModeOff leaves mermaid diagrams as source, while
+	// those of Languages are still rendered
+	DiagramMode mermaid.Mode
+
+	// Images renders mermaid diagrams as terminal images in
+	// mermaid.ModeImage, limited to ImageTimeout each; nil renders them with
+	// Diagrams
+	Images       mermaid.Renderer
+	ImageTimeout time.Duration
+
+	// ImageProtocol is how the terminal shows Images, which rendered
+	// documents are cached by; mermaid.ProtocolNone without them
+	ImageProtocol mermaid.ImageProtocol
+
+	// Languages renders diagrams of languages other than mermaid, such as
+	// d2; nil leaves them as source
+	Languages *mermaid.Registry
+
+	// Filters pipes code blocks of their languages through programs, such
+	// as formatters, before diagrams are rendered; nil leaves them be
+	Filters *mermaid.Registry
+
+	// Figures numbers the rendered diagrams, with captions below them
+	Figures bool
+
+	// Emoji replaces shortcodes such as :rocket: with their emoji
+	Emoji bool
+
+	// FormatJSON re-indents JSON code blocks written on one line, and
+	// HighlightData colors the keys and values of JSON and YAML code blocks
+	FormatJSON    bool
+	HighlightData bool
+
+	// cache keeps rendered documents on disk; nil renders every time
+	cache *renderCache
+}
 
 // NewMarkdownRenderer creates a new RealMarkdownRenderer.
 func NewMarkdownRenderer() *RealMarkdownRenderer {
@@ -34,8 +171,21 @@ func NewMarkdownRenderer() *RealMarkdownRenderer {
 }
 
 // Render converts markdown to styled terminal output using glamour.
-func (r *RealMarkdownRenderer) Render(markdown string, width int, style string, filename string, preserveNewLines bool) (string, error) {
-	isCode := !utils.IsMarkdownFile(filename)
+func (r *RealMarkdownRenderer) Render(markdown string, width int, style string, filename string, language string, preserveNewLines bool) (string, error) {
+	return r.RenderContext(context.Background(), markdown, width, style, filename, language, preserveNewLines)
+}
+
+// RenderContext renders like Render, stopping between its steps once ctx is
+// done. Diagrams being rendered by external commands are stopped too.
+func (r *RealMarkdownRenderer) RenderContext(ctx context.Context, markdown string, width int, style string, filename string, language string, preserveNewLines bool) (string, error) {
+	return r.render(ctx, markdown, width, style, filename, language, preserveNewLines, nil)
+}
+
+// render renders like RenderContext, reading the structure of the document
+// into model too if it isn't nil, from the parse it's rendered from, or else
+// from markdown if it's cached.
+func (r *RealMarkdownRenderer) render(ctx context.Context, markdown string, width int, style string, filename string, language string, preserveNewLines bool, model *document.Document) (string, error) {
+	lang, isCode := utils.CodeLanguage(filename, language)
 
 	// For code files, don't apply width limit
 	renderWidth := width
@@ -43,40 +193,164 @@ func (r *RealMarkdownRenderer) Render(markdown string, width int, style string,
 		renderWidth = 0
 	}
 
-	options := []glamour.TermRendererOption{
-		utils.GlamourStyle(style, isCode),
-		glamour.WithWordWrap(renderWidth),
-	}
-
-	if preserveNewLines {
-		options = append(options, glamour.WithPreservedNewLines())
-	}
-
-	renderer, err := glamour.NewTermRenderer(options...)
+	styleConfig, err := utils.GlamourStyleConfig(style, isCode)
 	if err != nil {
 		return "", fmt.Errorf("error creating glamour renderer: %w", err)
 	}
+	options := ansi.Options{
+		WordWrap:         renderWidth,
+		PreserveNewLines: preserveNewLines,
+		ColorProfile:     termenv.TrueColor,
+		Styles:           styleConfig,
+	}
 
 	// For code files, wrap in a code block
 	content := markdown
 	if isCode {
-		content = utils.WrapCodeBlock(markdown, filepath.Ext(filename))
+		content = utils.WrapCodeBlock(markdown, lang)
 	}
 
-	// Preprocess mermaid diagrams before rendering
-	content = mermaid.ProcessMarkdown(content, renderWidth)
+	// Documents rendered before come from the cache, unless filters apply
+	// to them, whose output may change from one run to the next
+	var key string
+	if r.cache != nil && (r.Filters == nil || !r.Filters.Applies(content)) {
+		key = r.cache.key(content, renderWidth, styleKey(style), isCode, preserveNewLines,
+			r.DiagramMode, r.Figures, r.Emoji, r.FormatJSON, r.HighlightData, r.ImageProtocol, lipgloss.ColorProfile())
+		if out, ok := r.cache.get(key); ok {
+			if model != nil {
+				*model = parseMarked(content, r.Emoji)
+			}
+			return out, nil
+		}
+	}
 
-	out, err := renderer.Render(content)
+	if r.Filters != nil {
+		filters := mermaid.NewFilterPreprocessor(r.Filters, renderWidth, style)
+		filters.SetContext(ctx)
+		content = filters.Process(content)
+	}
+
+	// Preprocess mermaid diagrams before rendering. Diagrams too wide for
+	// the page are kept whole for the pager to pan.
+	var diagramRenderer mermaid.Renderer = mermaid.NewRenderer()
+	diagramTimeout := r.DiagramTimeout
+	switch {
+	case r.DiagramMode == mermaid.ModeImage && r.Images != nil:
+		diagramRenderer = r.Images
+		diagramTimeout = r.ImageTimeout
+	case r.Diagrams != nil:
+		diagramRenderer = r.Diagrams
+	}
+	diagrams := mermaid.NewPreprocessor(diagramRenderer, renderWidth, style)
+	if diagramTimeout > 0 {
+		diagrams.SetTimeout(diagramTimeout)
+	}
+	if r.Languages != nil {
+		diagrams.AddLanguages(r.Languages)
+	}
+	if r.Figures {
+		diagrams.NumberFigures()
+	}
+	diagrams.KeepWide()
+	diagrams.SetContext(ctx)
+	if r.DiagramMode == mermaid.ModeOff {
+		diagrams.LeaveMermaid()
+	}
+	content = diagrams.Process(content)
+	if err := ctx.Err(); err != nil {
+		return "", err //nolint:wrapcheck
+	}
+
+	data := datablocks.NewPreprocessor(r.FormatJSON && !isCode, r.HighlightData && !isCode)
+	parsed := sourcemap.Parse(alerts.Process(footnotes.Process(wikilinks.Process(data.Process(content)))), r.Emoji)
+	if model != nil {
+		*model = document.FromAST(parsed.Doc, parsed.Source, parsed.Line)
+	}
+	out, err := parsed.Render(options)
 	if err != nil {
-		return "", fmt.Errorf("error rendering markdown: %w", err)
+		return "", err //nolint:wrapcheck
 	}
+	if err := ctx.Err(); err != nil {
+		// Diagrams left as source aren't failures, so it isn't cached
+		return "", err //nolint:wrapcheck
+	}
+	out = alerts.Restore(data.Restore(diagrams.Restore(out)))
 
 	if isCode {
 		out = strings.TrimSpace(out)
 	}
 
+	// Diagrams that failed, such as by timing out, may render next time
+	if key != "" && len(diagrams.Failures()) == 0 {
+		r.cache.put(key, out)
+	}
 	return out, nil
 }
 
-// Ensure RealMarkdownRenderer implements MarkdownRenderer.
-var _ MarkdownRenderer = (*RealMarkdownRenderer)(nil)
+// RenderSourceMap renders like RenderContext, returning where the lines of
+// markdown were rendered too. The marks are cached with the output, so
+// documents from the cache come with their maps.
+func (r *RealMarkdownRenderer) RenderSourceMap(ctx context.Context, markdown string, width int, style string, filename string, language string, preserveNewLines bool) (string, sourcemap.Map, error) {
+	out, sourceMap, _, err := r.RenderModel(ctx, markdown, width, style, filename, language, preserveNewLines)
+	return out, sourceMap, err
+}
+
+// RenderModel renders like RenderSourceMap, reading the structure of markdown
+// from the parse it's rendered from. Documents from the cache are parsed
+// without rendering them, as they are before their diagrams are rendered.
+func (r *RealMarkdownRenderer) RenderModel(ctx context.Context, markdown string, width int, style string, filename string, language string, preserveNewLines bool) (string, sourcemap.Map, document.Document, error) {
+	if _, isCode := utils.CodeLanguage(filename, language); !isCode && !sourcemap.Marked(markdown) {
+		markdown = sourcemap.Mark(markdown, 0)
+	}
+	var model document.Document
+	out, err := r.render(ctx, markdown, width, style, filename, language, preserveNewLines, &model)
+	if err != nil {
+		return "", nil, document.Document{}, err
+	}
+	out, sourceMap := sourcemap.Extract(out)
+	return out, sourceMap, model, nil
+}
+
+// styleKey returns what the output of a glamour style depends on, for cache
+// keys: auto as the style it picks for the terminal's background, and a
+// style file with the time it was changed.
+func styleKey(style string) string {
+	if style == styles.AutoStyle {
+		if lipgloss.HasDarkBackground() {
+			return styles.DarkStyle
+		}
+		return styles.LightStyle
+	}
+	if _, ok := styles.DefaultStyles[style]; ok {
+		return style
+	}
+	if info, err := os.Stat(utils.ExpandPath(style)); err == nil {
+		return style + "@" + info.ModTime().String()
+	}
+	return style
+}
+
+// DocumentRenderer is a MarkdownRenderer that applies the render options of
+// a document's front matter which aren't arguments to Render.
+type DocumentRenderer interface {
+	MarkdownRenderer
+
+	// ForDocument returns the renderer for a document with options.
+	ForDocument(options utils.RenderOptions) MarkdownRenderer
+}
+
+// ForDocument returns a copy of the renderer showing mermaid diagrams as the
+// document's options ask.
+func (r *RealMarkdownRenderer) ForDocument(options utils.RenderOptions) MarkdownRenderer {
+	doc := *r
+	doc.DiagramMode = mermaid.DocumentMode(options.Mermaid, r.DiagramMode)
+	return &doc
+}
+
+// Ensure RealMarkdownRenderer implements DocumentRenderer, SourceMapRenderer
+// and ModelRenderer.
+var (
+	_ DocumentRenderer  = (*RealMarkdownRenderer)(nil)
+	_ SourceMapRenderer = (*RealMarkdownRenderer)(nil)
+	_ ModelRenderer     = (*RealMarkdownRenderer)(nil)
+)
@@ -1,15 +1,23 @@
 package ui
 
 import (
+	"context"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/hholst80/glow/document"
+	"github.com/hholst80/glow/mermaid"
+	"github.com/hholst80/glow/sourcemap"
+	"github.com/hholst80/glow/utils"
 )
 
 // TestMarkdownRenderer is a test double for MarkdownRenderer.
 // It allows tests to control rendering behavior and track calls.
 type TestMarkdownRenderer struct {
 	// RenderFunc allows tests to control the render output
-	RenderFunc func(markdown string, width int, style string, filename string, preserveNewLines bool) (string, error)
+	RenderFunc func(markdown string, width int, style string, filename string, language string, preserveNewLines bool) (string, error)
 
 	// RenderCalls tracks all calls to Render for verification
 	RenderCalls []TestRenderCall
@@ -21,21 +29,23 @@ type TestRenderCall struct {
 	Width           int
 	Style           string
 	Filename        string
+	Language        string
 	PreserveNewLines bool
 }
 
 // Render implements MarkdownRenderer.
-func (r *TestMarkdownRenderer) Render(markdown string, width int, style string, filename string, preserveNewLines bool) (string, error) {
+func (r *TestMarkdownRenderer) Render(markdown string, width int, style string, filename string, language string, preserveNewLines bool) (string, error) {
 	r.RenderCalls = append(r.RenderCalls, TestRenderCall{
 		Markdown:        markdown,
 		Width:           width,
 		Style:           style,
 		Filename:        filename,
+		Language:        language,
 		PreserveNewLines: preserveNewLines,
 	})
 
 	if r.RenderFunc != nil {
-		return r.RenderFunc(markdown, width, style, filename, preserveNewLines)
+		return r.RenderFunc(markdown, width, style, filename, language, preserveNewLines)
 	}
 
 	// Default: return markdown as-is
@@ -50,7 +60,7 @@ func TestRealMarkdownRenderer_MarkdownFile(t *testing.T) {
 	r := NewMarkdownRenderer()
 
 	input := "# Hello World\n\nThis is a **test**."
-	out, err := r.Render(input, 80, "dark", "test.md", false)
+	out, err := r.Render(input, 80, "dark", "test.md", "", false)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -71,7 +81,7 @@ func TestRealMarkdownRenderer_CodeFile(t *testing.T) {
 	r := NewMarkdownRenderer()
 
 	input := "package main\n\nfunc main() {}\n"
-	out, err := r.Render(input, 80, "dark", "test.go", false)
+	out, err := r.Render(input, 80, "dark", "test.go", "", false)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -88,7 +98,7 @@ func TestRealMarkdownRenderer_PreserveNewLines(t *testing.T) {
 	r := NewMarkdownRenderer()
 
 	input := "Line 1\n\n\n\nLine 2"
-	_, err := r.Render(input, 80, "dark", "test.md", true)
+	_, err := r.Render(input, 80, "dark", "test.md", "", true)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -104,7 +114,7 @@ func TestRealMarkdownRenderer_Styles(t *testing.T) {
 
 	for _, style := range styles {
 		t.Run(style, func(t *testing.T) {
-			_, err := r.Render(input, 80, style, "test.md", false)
+			_, err := r.Render(input, 80, style, "test.md", "", false)
 			if err != nil {
 				t.Errorf("style %q: unexpected error: %v", style, err)
 			}
@@ -117,7 +127,7 @@ func TestRealMarkdownRenderer_ZeroWidth(t *testing.T) {
 	r := NewMarkdownRenderer()
 
 	input := "# Test\n\nSome text that is longer than usual."
-	_, err := r.Render(input, 0, "dark", "test.md", false)
+	_, err := r.Render(input, 0, "dark", "test.md", "", false)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -129,7 +139,7 @@ func TestRealMarkdownRenderer_MermaidDiagram(t *testing.T) {
 	r := NewMarkdownRenderer()
 
 	input := "# Diagram\n\n```mermaid\ngraph LR\n    A --> B\n```\n"
-	out, err := r.Render(input, 80, "dark", "test.md", false)
+	out, err := r.Render(input, 80, "dark", "test.md", "", false)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -144,8 +154,8 @@ func TestRealMarkdownRenderer_MermaidDiagram(t *testing.T) {
 func TestTestMarkdownRenderer_TracksCalls(t *testing.T) {
 	r := &TestMarkdownRenderer{}
 
-	_, _ = r.Render("test content", 80, "dark", "test.md", true)
-	_, _ = r.Render("more content", 40, "light", "other.txt", false)
+	_, _ = r.Render("test content", 80, "dark", "test.md", "", true)
+	_, _ = r.Render("more content", 40, "light", "other.txt", "", false)
 
 	if len(r.RenderCalls) != 2 {
 		t.Fatalf("expected 2 calls, got %d", len(r.RenderCalls))
@@ -180,12 +190,12 @@ func TestTestMarkdownRenderer_TracksCalls(t *testing.T) {
 // TestTestMarkdownRenderer_CustomRenderFunc tests injecting custom render behavior.
 func TestTestMarkdownRenderer_CustomRenderFunc(t *testing.T) {
 	r := &TestMarkdownRenderer{
-		RenderFunc: func(markdown string, width int, style string, filename string, preserveNewLines bool) (string, error) {
+		RenderFunc: func(markdown string, width int, style string, filename string, language string, preserveNewLines bool) (string, error) {
 			return "CUSTOM: " + markdown, nil
 		},
 	}
 
-	out, err := r.Render("input", 80, "dark", "test.md", false)
+	out, err := r.Render("input", 80, "dark", "test.md", "", false)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -194,3 +204,158 @@ func TestTestMarkdownRenderer_CustomRenderFunc(t *testing.T) {
 		t.Errorf("expected 'CUSTOM: input', got %q", out)
 	}
 }
+
+// TestRealMarkdownRenderer_WideMermaidDiagram tests that diagrams wider than
+// the page are kept unwrapped.
+func TestRealMarkdownRenderer_WideMermaidDiagram(t *testing.T) {
+	r := NewMarkdownRenderer()
+
+	input := "```mermaid\ngraph LR\n    Alpha --> Bravo --> Charlie --> Delta\n```\n"
+	out, err := r.Render(input, 30, "notty", "test.md", "", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(out, "│ Alpha ├─►│ Bravo ├─►│ Charlie ├─►│ Delta │") {
+		t.Errorf("expected the diagram on unwrapped lines, got:\n%s", out)
+	}
+	if strings.Contains(out, "glow-diagram") {
+		t.Errorf("expected placeholders to be replaced, got:\n%s", out)
+	}
+}
+
+// TestRealMarkdownRenderer_MermaidOff tests that diagrams are left as source
+// when mermaid rendering is off.
+func TestRealMarkdownRenderer_MermaidOff(t *testing.T) {
+	r := NewMarkdownRenderer()
+	r.DiagramMode = mermaid.ModeOff
+
+	input := "```mermaid\ngraph LR\n    A --> B\n```\n"
+	out, err := r.Render(input, 80, "notty", "test.md", "", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(out, "A --> B") || strings.Contains(out, "│ A ├") {
+		t.Errorf("expected the diagram source, got:\n%s", out)
+	}
+}
+
+// imageDiagrams renders every diagram as the same two rows of a kitty image.
+type imageDiagrams struct{}
+
+func (imageDiagrams) Render(string, int, string) (string, error) {
+	return "\x1b_Ga=T;\x1b\\\U0010EEEE\n\U0010EEEE", nil
+}
+
+// TestRealMarkdownRenderer_Images tests that diagrams are rendered as images
+// in image mode only, and that their rows come through rendering whole.
+func TestRealMarkdownRenderer_Images(t *testing.T) {
+	r := NewMarkdownRenderer()
+	r.Images = imageDiagrams{}
+
+	input := "```mermaid\ngraph LR\n    A --> B\n```\n"
+	for mode, want := range map[mermaid.Mode]bool{mermaid.ModeImage: true, mermaid.ModeASCII: false} {
+		r.DiagramMode = mode
+		out, err := r.Render(input, 80, "notty", "test.md", "", false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := strings.Contains(out, "\x1b_Ga=T;\x1b\\\U0010EEEE\n") && strings.Count(out, "\U0010EEEE") == 2; got != want {
+			t.Errorf("expected an image in mode %s: %t, got:\n%s", mode, want, out)
+		}
+	}
+}
+
+// TestRealMarkdownRenderer_ForDocument tests that a document's mermaid
+// setting applies to its renderer only.
+func TestRealMarkdownRenderer_ForDocument(t *testing.T) {
+	r := NewMarkdownRenderer()
+	r.DiagramMode = mermaid.ModeASCII
+
+	doc, ok := r.ForDocument(utils.RenderOptions{Mermaid: "off"}).(*RealMarkdownRenderer)
+	if !ok {
+		t.Fatal("expected a RealMarkdownRenderer")
+	}
+	if doc.DiagramMode != mermaid.ModeOff {
+		t.Errorf("expected the document's renderer to leave diagrams as source, got %q", doc.DiagramMode)
+	}
+	if r.DiagramMode != mermaid.ModeASCII {
+		t.Errorf("expected the renderer to keep its mode, got %q", r.DiagramMode)
+	}
+
+	input := "```mermaid\ngraph LR\n    A --> B\n```\n"
+	out, err := doc.Render(input, 80, "notty", "test.md", "", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "A --> B") {
+		t.Errorf("expected the diagram source, got:\n%s", out)
+	}
+}
+
+// TestRealMarkdownRenderer_RenderSourceMap tests that the renderer returns
+// the lines headings and paragraphs were rendered on, without the marks.
+func TestRealMarkdownRenderer_RenderSourceMap(t *testing.T) {
+	r := NewMarkdownRenderer()
+
+	input := "# Title\n\nIntro text.\n\n```\n# not a heading\n```\n\n## Second\n\nMore text."
+	out, sourceMap, err := r.RenderSourceMap(context.Background(), input, 80, "dark", "test.md", "", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sourcemap.Marked(out) {
+		t.Error("expected the marks removed from the output")
+	}
+
+	lines := strings.Split(ansi.Strip(out), "\n")
+	for source, text := range map[int]string{0: "Title", 2: "Intro text.", 8: "Second", 10: "More text."} {
+		line, exact := sourceMap.Rendered(source)
+		if !exact || line < 0 || line >= len(lines) || !strings.Contains(lines[line], text) {
+			t.Errorf("expected line %d mapped to %q, got line %d (exact %v)", source, text, line, exact)
+		}
+	}
+	if _, exact := sourceMap.Rendered(5); exact {
+		t.Error("expected lines of code blocks not marked")
+	}
+
+	// Code files aren't marked
+	_, sourceMap, err = r.RenderSourceMap(context.Background(), "package main", 80, "dark", "main.go", "", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sourceMap != nil {
+		t.Errorf("expected no map for a code file, got %v", sourceMap)
+	}
+}
+
+// TestRealMarkdownRenderer_RenderModel tests that the renderer reads the
+// structure of a document from the parse it's rendered from, on the lines of
+// the document, after diagrams that render to more lines than their source.
+func TestRealMarkdownRenderer_RenderModel(t *testing.T) {
+	r := NewMarkdownRenderer()
+
+	input := "# Diagram\n\n```mermaid\ngraph TD\n    A --> B\n```\n\n## After :rocket:\n\nSee [[Note#Part]] and [docs](https://example.com).\n"
+	out, _, doc, err := r.RenderModel(context.Background(), input, 80, "dark", "test.md", "", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Count(out, "\n") <= strings.Count(input, "\n") {
+		t.Fatalf("expected the diagram rendered to more lines than its source, got:\n%s", out)
+	}
+
+	want := []document.Heading{
+		{Level: 1, Text: "Diagram", ID: "diagram", Line: 1},
+		{Level: 2, Text: "After :rocket:", ID: "after-rocket", Line: 8},
+	}
+	if !reflect.DeepEqual(doc.Headings, want) {
+		t.Errorf("headings are %+v, want %+v", doc.Headings, want)
+	}
+	wantWiki := []document.WikiLink{{Target: "Note", Heading: "Part", Label: "Note › Part", Line: 10}}
+	if !reflect.DeepEqual(doc.WikiLinks, wantWiki) {
+		t.Errorf("wiki links are %+v, want %+v", doc.WikiLinks, wantWiki)
+	}
+	if wantLinks := []document.Link{{URL: "https://example.com", Text: "docs", Line: 10}}; !reflect.DeepEqual(doc.Links, wantLinks) {
+		t.Errorf("links are %+v, want %+v", doc.Links, wantLinks)
+	}
+}
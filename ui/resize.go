@@ -0,0 +1,88 @@
+package ui
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// resizeDebounce is how long the terminal must stay one size before the
+// document is rendered at it, so dragging a window's edge doesn't render the
+// document at every size it passes through.
+const resizeDebounce = 150 * time.Millisecond
+
+// resizeSettledMsg is sent resizeDebounce after the nth resize of the
+// terminal.
+type resizeSettledMsg int
+
+// renderGroup cancels the render in progress when another starts, since its
+// output would be replaced as soon as it's shown. It's shared by the copies
+// of the pager a command is made with.
+type renderGroup struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+// start cancels the render in progress, if any, and returns the context of
+// a new one. A nil group doesn't cancel renders.
+func (g *renderGroup) start() context.Context {
+	if g == nil {
+		return context.Background()
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.cancel != nil {
+		g.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	g.cancel = cancel
+	return ctx
+}
+
+// stop cancels the render in progress, if any.
+func (g *renderGroup) stop() {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.cancel != nil {
+		g.cancel()
+		g.cancel = nil
+	}
+}
+
+// resize renders the document at the terminal's new size. The first of a
+// run of resizes renders at once; the rest render once the terminal has
+// settled on a size.
+func (m *pagerModel) resize() tea.Cmd {
+	m.resizes++
+	n := m.resizes
+	cmds := []tea.Cmd{tea.Tick(resizeDebounce, func(time.Time) tea.Msg {
+		return resizeSettledMsg(n)
+	})}
+	if !m.resizing {
+		m.resizing = true
+		m.resizeRendered = n
+		m.anchorScroll()
+		cmds = append(cmds, renderWithGlamour(*m, m.currentDocument.Body))
+	}
+	return tea.Batch(cmds...)
+}
+
+// resizeSettled renders the document at the size the terminal settled on,
+// unless it already has been, or it's still being resized.
+func (m *pagerModel) resizeSettled(n int) tea.Cmd {
+	if n != m.resizes {
+		return nil
+	}
+	m.resizing = false
+	if m.resizeRendered == n {
+		return nil
+	}
+	m.resizeRendered = n
+	m.anchorScroll()
+	return renderWithGlamour(*m, m.currentDocument.Body)
+}
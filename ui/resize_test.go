@@ -0,0 +1,91 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// TestPager_ResizeDebounce tests that the first of a run of resizes renders
+// at once, and the rest only once the terminal settles on a size.
+func TestPager_ResizeDebounce(t *testing.T) {
+	m := newTestPagerModel()
+
+	for range 3 {
+		m.resize()
+	}
+	if m.resizeRendered != 1 {
+		t.Errorf("expected the first resize rendered, got resize %d", m.resizeRendered)
+	}
+	if m.resizeSettled(2) != nil || !m.resizing {
+		t.Error("expected no render while resizes keep coming")
+	}
+	if m.resizeSettled(3) == nil || m.resizeRendered != 3 || m.resizing {
+		t.Error("expected the last size rendered once the terminal settles")
+	}
+
+	// A single resize is rendered once
+	m.resize()
+	if m.resizeSettled(4) != nil {
+		t.Error("expected a single resize not rendered again")
+	}
+}
+
+// TestRenderWithGlamour_Cancelled tests that a render is dropped once
+// another starts.
+func TestRenderWithGlamour_Cancelled(t *testing.T) {
+	m := newTestPagerModel()
+	m.renders = &renderGroup{}
+
+	first := renderWithGlamour(m, "# First")
+	second := renderWithGlamour(m, "# Second")
+	if msg := first(); msg != nil {
+		t.Errorf("expected the first render dropped, got %T", msg)
+	}
+	if _, ok := second().(contentRenderedMsg); !ok {
+		t.Error("expected the second render shown")
+	}
+	if calls := m.common.renderer.(*TestMarkdownRenderer).RenderCalls; len(calls) != 1 {
+		t.Errorf("expected the first render not started, got %d renders", len(calls))
+	}
+
+	// Leaving the document drops its render
+	third := renderWithGlamour(m, "# Third")
+	m.unload()
+	if msg := third(); msg != nil {
+		t.Errorf("expected the render dropped on leaving, got %T", msg)
+	}
+}
+
+// TestPager_ResizeKeepsPosition tests that the line of the document at the
+// top of the view stays there once it's rendered at a new size.
+func TestPager_ResizeKeepsPosition(t *testing.T) {
+	m := newTestPagerModel()
+	m.common.renderer = NewMarkdownRenderer()
+	var b strings.Builder
+	for i := range 30 {
+		fmt.Fprintf(&b, "## Section %d\n\n%s\n\n", i, strings.Repeat("Words to wrap at a narrower width. ", 6))
+	}
+	m.currentDocument.Body = b.String()
+	m, _ = m.update(renderWithGlamour(m, m.currentDocument.Body)())
+
+	// Section 20 starts on line 80 of the document
+	line, exact := m.sourceMap.Rendered(80)
+	if !exact {
+		t.Fatal("expected section 20 mapped")
+	}
+	m.viewport.SetYOffset(line)
+
+	m.common.width = 50
+	m.setSize(m.common.width, m.common.height)
+	m.resizes, m.resizing = 1, true
+	m, _ = m.update(m.resizeSettled(1)())
+	if top := ansi.Strip(strings.Split(m.renderedContent, "\n")[m.viewport.YOffset]); !strings.Contains(top, "Section 20") {
+		t.Errorf("expected section 20 at the top of the view, got %q", top)
+	}
+	if m.viewport.YOffset <= line {
+		t.Errorf("expected the section further down once narrower, got line %d, was %d", m.viewport.YOffset, line)
+	}
+}
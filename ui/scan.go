@@ -0,0 +1,188 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/muesli/gitcha"
+)
+
+// scanOptions control which files the scan for markdown files finds.
+type scanOptions struct {
+	// Paths to skip, as in ignorePatterns
+	ignorePatterns []string
+
+	// Skip what git ignores
+	respectGitIgnore bool
+
+	// How many directories deep to look; 0 doesn't limit it
+	maxDepth int
+
+	// Follow symlinks to directories and files
+	followSymlinks bool
+
+	// Called with each directory walked, if set, e.g. to watch it
+	walked func(dir string)
+}
+
+// newScanOptions returns the options of the scan the settings of cfg ask
+// for: system files are skipped unless all files are shown, hidden files
+// unless they're shown, and the files git ignores unless that's turned off.
+func newScanOptions(cfg Config) scanOptions {
+	var patterns []string
+	if !cfg.ShowAllFiles {
+		patterns = ignorePatterns(cfg)
+	}
+	if !cfg.ShowHidden {
+		patterns = append(patterns, hiddenPattern)
+	}
+	return scanOptions{
+		ignorePatterns:   patterns,
+		respectGitIgnore: !cfg.NoIgnore && !cfg.ShowAllFiles,
+		maxDepth:         cfg.MaxDepth,
+		followSymlinks:   cfg.FollowSymlinks,
+	}
+}
+
+// FindMarkdownFiles returns the paths of the markdown files under dir that
+// the TUI lists with cfg, sorted.
+func FindMarkdownFiles(dir string, cfg Config) ([]string, error) {
+	ch, err := findMarkdownFiles(dir, newScanOptions(cfg))
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for res := range ch {
+		paths = append(paths, res.Path)
+	}
+	slices.Sort(paths)
+	return paths, nil
+}
+
+// findMarkdownFiles walks dir for markdown files.
+func findMarkdownFiles(dir string, opts scanOptions) (chan gitcha.SearchResult, error) {
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan gitcha.SearchResult)
+	s := newScanner(dir, opts, func(res gitcha.SearchResult) { ch <- res })
+	go func() {
+		defer close(ch)
+		s.walk(dir, 1)
+	}()
+
+	return ch, nil
+}
+
+// scanner walks a directory tree for markdown files.
+type scanner struct {
+	scanOptions
+	ignores *gitIgnores
+	found   func(gitcha.SearchResult) // called with each markdown file
+
+	// Real paths of the directories walked, so symlinks don't loop
+	visited map[string]bool
+}
+
+// newScanner returns a scanner of the tree at root, whose real path it is.
+func newScanner(root string, opts scanOptions, found func(gitcha.SearchResult)) *scanner {
+	s := &scanner{
+		scanOptions: opts,
+		found:       found,
+		visited:     map[string]bool{root: true},
+	}
+	if opts.respectGitIgnore {
+		s.ignores = newGitIgnores(root)
+	}
+	return s
+}
+
+// walk sends the markdown files of dir, whose entries are depth directories
+// below the top, and walks its subdirectories.
+func (s *scanner) walk(dir string, depth int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Debug("unable to read directory", "dir", dir, "error", err)
+		return
+	}
+	if s.walked != nil {
+		s.walked(dir)
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		// Unless they're followed, symlinks are listed like files
+		if info.Mode()&os.ModeSymlink != 0 && s.followSymlinks {
+			if info, err = os.Stat(path); err != nil {
+				continue
+			}
+		}
+
+		if s.ignored(path, info.IsDir()) {
+			continue
+		}
+		if !info.IsDir() {
+			if isMarkdownFile(path) {
+				s.found(gitcha.SearchResult{Path: path, Info: info})
+			}
+			continue
+		}
+		if s.maxDepth > 0 && depth >= s.maxDepth {
+			continue
+		}
+
+		if s.followSymlinks {
+			real, err := filepath.EvalSymlinks(path)
+			if err != nil || s.visited[real] {
+				continue
+			}
+			s.visited[real] = true
+		}
+		s.walk(path, depth+1)
+	}
+}
+
+// ignored reports whether path, a directory if dir is set, is skipped.
+func (s *scanner) ignored(path string, dir bool) bool {
+	return matchesIgnorePattern(path, s.ignorePatterns) ||
+		(s.ignores != nil && s.ignores.ignores(path, dir))
+}
+
+// matchesIgnorePattern reports whether path matches one of patterns. Like
+// gitcha, patterns without a path separator match the entries of any
+// directory.
+func matchesIgnorePattern(path string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		if !strings.Contains(pattern, string(os.PathSeparator)) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		if matched, _ := filepath.Match(pattern, path); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// isMarkdownFile reports whether the name of path matches one of the
+// markdown extensions.
+func isMarkdownFile(path string) bool {
+	name := strings.ToLower(filepath.Base(path))
+	for _, ext := range markdownExtensions {
+		if matched, _ := filepath.Match(strings.ToLower(ext), name); matched {
+			return true
+		}
+	}
+	return false
+}
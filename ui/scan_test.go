@@ -0,0 +1,107 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestScanOptions(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes := filepath.Join(root, "notes")
+	vault := filepath.Join(root, "vault")
+	for _, name := range []string{
+		"notes/top.md",
+		"notes/a/one.md",
+		"notes/a/b/two.md",
+		"vault/linked.md",
+	} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(vault, filepath.Join(notes, "vault")); err != nil {
+		t.Skipf("symlinks aren't supported: %v", err)
+	}
+	// A link back up the tree would loop forever if it were followed twice
+	if err := os.Symlink(notes, filepath.Join(notes, "a", "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts scanOptions
+		want []string
+	}{
+		{"unlimited", scanOptions{}, []string{"a/b/two.md", "a/one.md", "top.md"}},
+		{"depth 1", scanOptions{maxDepth: 1}, []string{"top.md"}},
+		{"depth 2", scanOptions{maxDepth: 2}, []string{"a/one.md", "top.md"}},
+		{"symlinks", scanOptions{followSymlinks: true}, []string{"a/b/two.md", "a/one.md", "top.md", "vault/linked.md"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch, err := findMarkdownFiles(notes, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var found []string
+			for res := range ch {
+				rel, _ := filepath.Rel(notes, res.Path)
+				found = append(found, filepath.ToSlash(rel))
+			}
+			slices.Sort(found)
+			if !slices.Equal(found, tt.want) {
+				t.Errorf("findMarkdownFiles() = %v, want %v", found, tt.want)
+			}
+		})
+	}
+}
+
+func TestShowHidden(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"README.md", ".notes/todo.md", ".draft.md"} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	find := func(cfg Config) []string {
+		t.Helper()
+		cfg.Path = root
+		msg, ok := findLocalFiles(commonModel{cfg: cfg})().(initLocalFileSearchMsg)
+		if !ok {
+			t.Fatal("Expected the search to start")
+		}
+		if msg.watcher != nil {
+			defer msg.watcher.close()
+		}
+		var found []string
+		for res := range msg.ch {
+			rel, _ := filepath.Rel(root, res.Path)
+			found = append(found, filepath.ToSlash(rel))
+		}
+		slices.Sort(found)
+		return found
+	}
+	if got := find(Config{}); !slices.Equal(got, []string{"README.md"}) {
+		t.Errorf("Expected hidden files to be skipped, got %v", got)
+	}
+	if got := find(Config{ShowHidden: true}); !slices.Equal(got, []string{".draft.md", ".notes/todo.md", "README.md"}) {
+		t.Errorf("Expected hidden files to be listed, got %v", got)
+	}
+}
@@ -3,10 +3,72 @@ package ui
 import (
 	"cmp"
 	"slices"
+	"strings"
+
+	"github.com/charmbracelet/log"
 )
 
-func sortMarkdowns(mds []*markdown) {
+// sortMode is the order of the file listing.
+type sortMode string
+
+// File listing orders.
+const (
+	sortByName     sortMode = "name"     // path, A to Z
+	sortByModified sortMode = "modified" // newest first
+	sortBySize     sortMode = "size"     // largest first
+	sortByTitle    sortMode = "title"    // first heading, A to Z
+)
+
+// sortModes are the orders the sort key cycles through.
+var sortModes = []sortMode{sortByName, sortByModified, sortBySize, sortByTitle}
+
+// parseSortMode returns the order named s, or sortByName if s is empty or
+// not an order.
+func parseSortMode(s string) sortMode {
+	if s == "" {
+		return sortByName
+	}
+	if mode := sortMode(strings.ToLower(s)); slices.Contains(sortModes, mode) {
+		return mode
+	}
+	log.Warn("unknown sort order", "sort", s)
+	return sortByName
+}
+
+// next returns the order after mode.
+func (mode sortMode) next() sortMode {
+	i := slices.Index(sortModes, mode)
+	return sortModes[(i+1)%len(sortModes)]
+}
+
+// sortMarkdowns sorts the file listing, pinned files first, then those opened
+// lately. Files that compare equal keep their order by path.
+func sortMarkdowns(mds []*markdown, mode sortMode) {
 	slices.SortStableFunc(mds, func(a, b *markdown) int {
+		if a.pinned != b.pinned {
+			if a.pinned {
+				return -1
+			}
+			return 1
+		}
+		if a.recent != b.recent {
+			if a.recent == 0 || b.recent == 0 {
+				return cmp.Compare(b.recent, a.recent)
+			}
+			return cmp.Compare(a.recent, b.recent)
+		}
+		var c int
+		switch mode {
+		case sortByModified:
+			c = b.Modtime.Compare(a.Modtime)
+		case sortBySize:
+			c = cmp.Compare(b.Size, a.Size)
+		case sortByTitle:
+			c = cmp.Compare(strings.ToLower(a.sortTitle()), strings.ToLower(b.sortTitle()))
+		}
+		if c != 0 {
+			return c
+		}
 		return cmp.Compare(a.Note, b.Note)
 	})
 }
@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSortMarkdowns(t *testing.T) {
+	now := time.Now()
+	newMarkdowns := func() []*markdown {
+		return []*markdown{
+			{Note: "b.md", Size: 10, Modtime: now.Add(-time.Hour), heading: "Zebra", headingLoaded: true},
+			{Note: "a.md", Size: 30, Modtime: now.Add(-2 * time.Hour), heading: "apple", headingLoaded: true},
+			{Note: "docs/c.md", Size: 10, Modtime: now},
+		}
+	}
+	tests := []struct {
+		mode sortMode
+		want []string
+	}{
+		{sortByName, []string{"a.md", "b.md", "docs/c.md"}},
+		{sortByModified, []string{"docs/c.md", "b.md", "a.md"}},
+		{sortBySize, []string{"a.md", "b.md", "docs/c.md"}},
+		{sortByTitle, []string{"a.md", "docs/c.md", "b.md"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			mds := newMarkdowns()
+			sortMarkdowns(mds, tt.mode)
+			for i, md := range mds {
+				if md.Note != tt.want[i] {
+					t.Fatalf("sortMarkdowns() position %d = %q, want %q", i, md.Note, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseSortMode(t *testing.T) {
+	tests := map[string]sortMode{
+		"":         sortByName,
+		"modified": sortByModified,
+		"Size":     sortBySize,
+		"title":    sortByTitle,
+		"random":   sortByName,
+	}
+	for s, want := range tests {
+		if got := parseSortMode(s); got != want {
+			t.Errorf("parseSortMode(%q) = %q, want %q", s, got, want)
+		}
+	}
+
+	mode := sortByName
+	for range sortModes {
+		mode = mode.next()
+	}
+	if mode != sortByName {
+		t.Errorf("next() doesn't cycle back to %q, got %q", sortByName, mode)
+	}
+}
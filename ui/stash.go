@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"os"
@@ -14,6 +15,8 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
+	"github.com/hholst80/glow/tables"
+	"github.com/hholst80/glow/utils"
 	"github.com/muesli/reflow/ansi"
 	"github.com/muesli/reflow/truncate"
 	"github.com/sahilm/fuzzy"
@@ -143,6 +146,18 @@ type stashModel struct {
 	filterInput        textinput.Model
 	viewState          stashViewState
 	filterState        filterState
+	finding            bool     // the filter is the ctrl+p finder
+	globbing           bool     // the filter matches globs
+	sort               sortMode // order of the file listing
+	textSearch         textSearchModel
+	favorites          map[string]bool // paths of the pinned files
+	history            map[string]openRecord
+	tabs               []*markdown // files opened in background tabs
+	tags               tagsModel
+	fileOps            fileOpsModel
+	columns            []column // metadata columns of the listing
+	showColumns        bool
+	showTitles         bool // files are labeled by their titles
 	showFullHelp       bool
 	showStatusMessage  bool
 	statusMessage      statusMessage
@@ -219,8 +234,14 @@ func (m *stashModel) resetFiltering() {
 	m.filterState = unfiltered
 	m.filterInput.Reset()
 	m.filteredMarkdowns = nil
+	m.tags.filter = ""
+	if m.finding || m.globbing {
+		m.finding, m.globbing = false, false
+		m.filterInput.Prompt = "Find:"
+		m.setSize(m.common.width, m.common.height)
+	}
 
-	sortMarkdowns(m.markdowns)
+	sortMarkdowns(m.markdowns, m.sort)
 
 	// If the filtered section is present (it's always at the end) slice it out
 	// of the sections slice to remove it from the UI.
@@ -243,6 +264,13 @@ func (m stashModel) filterApplied() bool {
 	return m.filterState != unfiltered
 }
 
+// capturesKeys reports whether a prompt or list of the listing takes all keys,
+// including those that otherwise quit or refresh.
+func (m stashModel) capturesKeys() bool {
+	return m.filterState == filtering || m.textSearch.active ||
+		m.tags.choosing || m.tags.editing != nil || m.fileOps.op != noFileOp
+}
+
 // Should we be updating the filter?
 func (m stashModel) shouldUpdateFilter() bool {
 	// If we're in the middle of setting a note don't update the filter so that
@@ -297,9 +325,14 @@ func (m *stashModel) addMarkdowns(mds ...*markdown) {
 		return
 	}
 
+	for _, md := range mds {
+		if m.favorites[md.localPath] {
+			md.pinned = true
+		}
+	}
 	m.markdowns = append(m.markdowns, mds...)
 	if !m.filterApplied() {
-		sortMarkdowns(m.markdowns)
+		sortMarkdowns(m.markdowns, m.sort)
 	}
 
 	m.updatePagination()
@@ -318,10 +351,41 @@ func (m stashModel) getVisibleMarkdowns() []*markdown {
 // alters the model.
 func (m *stashModel) openMarkdown(md *markdown) tea.Cmd {
 	m.viewState = stashStateLoadingDocument
+	m.recordOpen(md)
 	cmd := loadLocalMarkdown(md)
 	return tea.Batch(cmd, m.spinner.Tick)
 }
 
+// toggleHidden shows hidden files and directories in the listing, or hides
+// them, and scans for files again.
+func (m *stashModel) toggleHidden() tea.Cmd {
+	m.common.cfg.ShowHidden = !m.common.cfg.ShowHidden
+	m.markdowns, m.filteredMarkdowns = nil, nil
+	m.loaded = false
+	m.updateRecent()
+
+	msg := "Hiding hidden files"
+	if m.common.cfg.ShowHidden {
+		msg = "Showing hidden files"
+	}
+	return tea.Batch(
+		findLocalFiles(*m.common),
+		m.spinner.Tick,
+		m.newStatusMessage(statusMessage{normalStatusMessage, msg}),
+	)
+}
+
+// Show a status message for a while.
+func (m *stashModel) newStatusMessage(sm statusMessage) tea.Cmd {
+	m.showStatusMessage = true
+	m.statusMessage = sm
+	if m.statusMessageTimer != nil {
+		m.statusMessageTimer.Stop()
+	}
+	m.statusMessageTimer = time.NewTimer(statusMessageTimeout)
+	return waitForStatusMessageTimeout(stashContext, m.statusMessageTimer)
+}
+
 func (m *stashModel) hideStatusMessage() {
 	m.showStatusMessage = false
 	m.statusMessage = statusMessage{}
@@ -394,6 +458,30 @@ func newStashModel(common *commonModel) stashModel {
 		filterInput: si,
 		serverPage:  1,
 		sections:    s,
+		sort:        parseSortMode(common.cfg.Sort),
+		textSearch:  newTextSearchModel(),
+		tags:        newTagsModel(common.cfg.TagsFile),
+		fileOps:     newFileOpsModel(),
+		showTitles:  common.cfg.ShowTitles,
+	}
+
+	favorites, err := loadFavorites(common.cfg.FavoritesFile)
+	if err != nil {
+		log.Error("unable to load favorites", "error", err)
+	}
+	m.favorites = favorites
+
+	history, err := loadHistory(common.cfg.HistoryFile)
+	if err != nil {
+		log.Error("unable to load history", "error", err)
+	}
+	m.history = history
+
+	// Configuring columns shows them; otherwise toggling shows them all
+	m.columns = parseColumns(common.cfg.Columns)
+	m.showColumns = len(m.columns) > 0
+	if !m.showColumns {
+		m.columns = allColumns
 	}
 
 	return m
@@ -419,12 +507,65 @@ func (m stashModel) update(msg tea.Msg) (stashModel, tea.Cmd) {
 	case localFileSearchFinished:
 		// We're finished searching for local files
 		m.loaded = true
+		m.updateRecent()
+		if m.needsHeadings() {
+			cmds = append(cmds, loadHeadings(m.markdowns))
+		}
+		cmds = append(cmds, loadTags(m.markdowns))
+
+		// Favorites from elsewhere are indexed along with the files found
+		if cmd := findFavorites(m.favorites, m.markdowns, m.common.cwd); cmd != nil {
+			cmds = append(cmds, cmd)
+		} else {
+			cmds = append(cmds, buildSearchIndex(m.markdowns))
+		}
+
+	case foundFavoritesMsg:
+		m.addMarkdowns(msg...)
+		m.updateRecent()
+		cmds = append(cmds, buildSearchIndex(m.markdowns), loadTags(msg))
+		if m.needsHeadings() {
+			cmds = append(cmds, loadHeadings(msg))
+		}
+		if m.shouldUpdateFilter() {
+			cmds = append(cmds, filterMarkdowns(m))
+		}
+
+	case tagsLoadedMsg:
+		for md, tags := range msg {
+			md.tags, md.tagsLoaded = tags, true
+		}
+		if m.tags.filter != "" && m.filterState == filterApplied {
+			m.filteredMarkdowns = m.markdownsTagged(m.tags.filter)
+			m.updatePagination()
+		}
+		return m, nil
+
+	case searchIndexMsg:
+		m.textSearch.index = searchIndex(msg)
+		if m.textSearch.active {
+			m.updateTextSearch()
+		}
+		return m, nil
 
 	case filteredMarkdownMsg:
 		m.filteredMarkdowns = msg
 		m.setCursor(0)
 		return m, nil
 
+	case headingsLoadedMsg:
+		for md, h := range msg {
+			md.heading, md.title, md.headingLoaded = h.heading, h.title, true
+			md.buildFilterValue()
+		}
+		if m.filterState == filtering {
+			return m, filterMarkdowns(m)
+		}
+		if !m.filterApplied() {
+			sortMarkdowns(m.markdowns, m.sort)
+		}
+		return m, nil
+
 	case spinner.TickMsg:
 		if m.shouldSpin() {
 			var cmd tea.Cmd
@@ -436,6 +577,37 @@ func (m stashModel) update(msg tea.Msg) (stashModel, tea.Cmd) {
 		if applicationContext(msg) == stashContext {
 			m.hideStatusMessage()
 		}
+
+	case editorFinishedMsg:
+		// Pick up what was written to a file we just created
+		if md := m.fileOps.created; md != nil {
+			m.fileOps.created = nil
+			m.statFile(md)
+			cmds = append(cmds, loadTags([]*markdown{md}))
+			if m.needsHeadings() {
+				cmds = append(cmds, loadHeadings([]*markdown{md}))
+			}
+		}
+	}
+
+	if m.fileOps.op != noFileOp {
+		cmds = append(cmds, m.handleFileOp(msg))
+		return m, tea.Batch(cmds...)
+	}
+
+	if m.textSearch.active {
+		cmds = append(cmds, m.handleTextSearch(msg))
+		return m, tea.Batch(cmds...)
+	}
+
+	if m.tags.editing != nil {
+		cmds = append(cmds, m.handleEditingTags(msg))
+		return m, tea.Batch(cmds...)
+	}
+
+	if m.tags.choosing {
+		cmds = append(cmds, m.handleChoosingTag(msg))
+		return m, tea.Batch(cmds...)
 	}
 
 	if m.filterState == filtering {
@@ -483,12 +655,22 @@ func (m *stashModel) handleDocumentBrowsing(msg tea.Msg) tea.Cmd {
 			m.paginator().Page = m.paginator().TotalPages - 1
 			m.setCursor(m.paginator().ItemsOnPage(numDocs) - 1)
 
-		// Clear filter (if applicable)
+		// Clear filter (if applicable), then the marks
 		case keyEsc:
 			if m.filterApplied() {
 				m.resetFiltering()
+			} else {
+				m.clearMarked()
 			}
 
+		// Mark the file to open it along with others
+		case " ":
+			m.toggleMarked()
+
+		// Open the file in a tab behind the next one opened
+		case "O":
+			return m.openBackgroundTab()
+
 		// Next section
 		case "tab", "L":
 			if len(m.sections) == 0 || m.filterState == filtering {
@@ -515,6 +697,10 @@ func (m *stashModel) handleDocumentBrowsing(msg tea.Msg) tea.Cmd {
 			m.loaded = false
 			return findLocalFiles(*m.common)
 
+		// Show or hide hidden files, which scans for files again
+		case ".":
+			return m.toggleHidden()
+
 		// Edit document in EDITOR
 		case "e":
 			md := m.selectedMarkdown()
@@ -535,27 +721,79 @@ func (m *stashModel) handleDocumentBrowsing(msg tea.Msg) tea.Cmd {
 			}
 
 			// Load the document from the server. We'll handle the message
-			// that comes back in the main update function.
+			// that comes back in the main update function. Marked files
+			// open as one document.
 			md := m.selectedMarkdown()
+			if marked := m.markedMarkdowns(); len(marked) > 0 {
+				md = joinedMarkdown(marked)
+			}
 			cmds = append(cmds, m.openMarkdown(md))
 
 		// Filter your notes
 		case "/":
 			m.hideStatusMessage()
+			return m.startFiltering()
+
+		// Filter your notes by path
+		case "*":
+			m.hideStatusMessage()
+			return m.startGlobbing()
+
+		// Pin the file to the top of the listing
+		case "p":
+			return m.togglePinned()
+
+		// Browse the files by tag
+		case "t":
+			m.hideStatusMessage()
+			m.startChoosingTag()
+			return nil
+
+		// Tag the file
+		case "T":
+			m.hideStatusMessage()
+			return m.startEditingTags()
+
+		// Create a file
+		case "n":
+			m.hideStatusMessage()
+			return m.startFileOp(creatingFile, nil)
 
-			// Build values we'll filter against
-			for _, md := range m.markdowns {
-				md.buildFilterValue()
+		// Rename the file
+		case "R":
+			if md := m.selectedMarkdown(); md != nil {
+				m.hideStatusMessage()
+				return m.startFileOp(renamingFile, md)
 			}
 
-			m.filteredMarkdowns = m.markdowns
+		// Delete the file
+		case "D":
+			if md := m.selectedMarkdown(); md != nil {
+				m.hideStatusMessage()
+				return m.startFileOp(deletingFile, md)
+			}
 
-			m.paginator().Page = 0
-			m.setCursor(0)
-			m.filterState = filtering
-			m.filterInput.CursorEnd()
-			m.filterInput.Focus()
-			return textinput.Blink
+		// Show the metadata columns
+		case "c":
+			m.toggleColumns()
+
+		// Label the files by their titles or paths
+		case "v":
+			return m.toggleTitles()
+
+		// Change the order of the files
+		case "o":
+			return m.cycleSort()
+
+		// Find a file to open
+		case "ctrl+p":
+			m.hideStatusMessage()
+			return m.startFinder()
+
+		// Search the contents of the files
+		case "ctrl+f":
+			m.hideStatusMessage()
+			return m.startTextSearch()
 
 		// Toggle full help
 		case "?":
@@ -597,12 +835,58 @@ func (m *stashModel) handleDocumentBrowsing(msg tea.Msg) tea.Cmd {
 	return tea.Batch(cmds...)
 }
 
+// cycleSort switches the file listing to the next order and saves it. Sorting
+// by title reads the files' first headings.
+func (m *stashModel) cycleSort() tea.Cmd {
+	m.sort = m.sort.next()
+	if !m.filterApplied() {
+		sortMarkdowns(m.markdowns, m.sort)
+	}
+	if save := m.common.cfg.SaveSort; save != nil {
+		if err := save(string(m.sort)); err != nil {
+			log.Error("unable to save sort order", "error", err)
+		}
+	}
+	if m.sort == sortByTitle {
+		return loadHeadings(m.markdowns)
+	}
+	return nil
+}
+
+// startFiltering opens the filter editing interface.
+func (m *stashModel) startFiltering() tea.Cmd {
+	// Searching replaces a tag filter
+	if m.tags.filter != "" {
+		m.tags.filter = ""
+		m.filterInput.Reset()
+	}
+
+	// Build values we'll filter against
+	for _, md := range m.markdowns {
+		md.buildFilterValue()
+	}
+
+	m.filteredMarkdowns = m.markdowns
+
+	m.paginator().Page = 0
+	m.setCursor(0)
+	m.filterState = filtering
+	m.filterInput.CursorEnd()
+	m.filterInput.Focus()
+	return textinput.Blink
+}
+
 // Updates for when a user is in the filter editing interface.
 func (m *stashModel) handleFiltering(msg tea.Msg) tea.Cmd {
 	var cmds []tea.Cmd
 
 	// Handle keys
 	if msg, ok := msg.(tea.KeyMsg); ok { //nolint:nestif
+		if m.finding {
+			if cmd, handled := m.handleFinding(msg); handled {
+				return cmd
+			}
+		}
 		switch msg.String() {
 		case keyEsc:
 			// Cancel filtering
@@ -686,7 +970,13 @@ func (m stashModel) view() string {
 
 		// Rules for the logo, filter and status message.
 		logoOrFilter := " "
-		if m.showStatusMessage && m.filterState == filtering {
+		if m.fileOps.op != noFileOp {
+			logoOrFilter += m.fileOps.input.View()
+		} else if m.textSearch.active {
+			logoOrFilter += m.textSearch.input.View()
+		} else if m.tags.editing != nil {
+			logoOrFilter += m.tags.input.View()
+		} else if m.showStatusMessage && m.filterState == filtering {
 			logoOrFilter += m.statusMessage.String()
 		} else if m.filterState == filtering {
 			logoOrFilter += m.filterInput.View()
@@ -713,7 +1003,7 @@ func (m stashModel) view() string {
 		blankLines := strings.Repeat("\n", max(0, availHeight))
 
 		var pagination string
-		if m.paginator().TotalPages > 1 {
+		if m.paginator().TotalPages > 1 && !m.textSearch.active && !m.tags.choosing {
 			pagination = m.paginator().View()
 
 			// If the dot pagination is wider than the width of the window
@@ -756,6 +1046,13 @@ func (m stashModel) headerView() string {
 
 	var sections []string //nolint:prealloc
 
+	if m.textSearch.active {
+		return m.textSearchHeaderView()
+	}
+	if m.tags.choosing {
+		return grayFg(fmt.Sprintf("%d tags", len(m.tagCounts())))
+	}
+
 	// Filter results
 	if m.filterState == filtering {
 		if localCount == 0 {
@@ -779,6 +1076,15 @@ func (m stashModel) headerView() string {
 		switch v.key {
 		case documentsSection:
 			s = fmt.Sprintf("%d documents", localCount)
+			if pinned := m.pinnedCount(); pinned > 0 {
+				s += fmt.Sprintf(", %d pinned", pinned)
+			}
+			if marked := len(m.markedMarkdowns()); marked > 0 {
+				s += fmt.Sprintf(", %d marked", marked)
+			}
+			if len(m.tabs) > 0 {
+				s += fmt.Sprintf(", %d in tabs", len(m.tabs))
+			}
 
 		case filterSection:
 			s = fmt.Sprintf("%d “%s”", len(m.filteredMarkdowns), m.filterInput.Value())
@@ -792,10 +1098,17 @@ func (m stashModel) headerView() string {
 		sections = append(sections, s)
 	}
 
-	return strings.Join(sections, dividerBar.String())
+	return strings.Join(sections, dividerBar.String()) + dividerDot.String() + grayFg("by "+string(m.sort))
 }
 
 func (m stashModel) populatedView() string {
+	if m.textSearch.active {
+		return m.textSearchView()
+	}
+	if m.tags.choosing {
+		return m.tagsView()
+	}
+
 	mds := m.getVisibleMarkdowns()
 
 	var b strings.Builder
@@ -855,12 +1168,26 @@ func loadLocalMarkdown(md *markdown) tea.Cmd {
 			return errMsg{errors.New("could not load file: missing path")}
 		}
 
+		if len(md.parts) > 0 {
+			if err := joinParts(md); err != nil {
+				log.Debug("error reading local file", "error", err)
+				return errMsg{err}
+			}
+			return fetchedMarkdownMsg(md)
+		}
+
 		data, err := os.ReadFile(md.localPath)
 		if err != nil {
 			log.Debug("error reading local file", "error", err)
 			return errMsg{err}
 		}
-		md.Body = string(data)
+		data = utils.DocumentContent(md.localPath, data)
+		body := utils.RemoveFrontmatter(data)
+		md.Body = string(body)
+		md.bodyLine = bytes.Count(data, []byte("\n")) - bytes.Count(body, []byte("\n"))
+		md.Title = utils.FrontmatterTitle(data)
+		md.tables = tables.DocumentStrategy(data, "")
+		md.options = utils.FrontmatterRenderOptions(data)
 		return fetchedMarkdownMsg(md)
 	}
 }
@@ -871,6 +1198,13 @@ func filterMarkdowns(m stashModel) tea.Cmd {
 			return filteredMarkdownMsg(m.markdowns) // return everything
 		}
 
+		if m.globbing {
+			return filteredMarkdownMsg(globMarkdowns(m.markdowns, m.filterInput.Value()))
+		}
+		if m.tags.filter != "" {
+			return filteredMarkdownMsg(m.markdownsTagged(m.tags.filter))
+		}
+
 		targets := []string{}
 		mds := m.markdowns
 
@@ -83,6 +83,46 @@ func (h helpColumn) maxWidths() (maxKey int, maxVal int) {
 func (m stashModel) helpView() (string, int) {
 	numDocs := len(m.getVisibleMarkdowns())
 
+	// Help for the file operations
+	switch m.fileOps.op { //nolint:exhaustive
+	case creatingFile:
+		return m.renderHelp([]string{"enter", "create", "esc", "cancel"})
+	case renamingFile:
+		return m.renderHelp([]string{"enter", "rename", "esc", "cancel"})
+	case deletingFile:
+		return m.renderHelp([]string{"y", "delete", "n/esc", "cancel"})
+	}
+
+	// Help for the tags
+	if m.tags.editing != nil {
+		return m.renderHelp([]string{"enter", "save", "esc", "cancel"})
+	}
+	if m.tags.choosing {
+		h := []string{"enter", "filter", "esc", "cancel"}
+		if len(m.tagCounts()) > 1 {
+			h = append(h, "j/k ↑/↓", "choose")
+		}
+		return m.renderHelp(h)
+	}
+
+	// Help for the full-text search
+	if m.textSearch.active {
+		h := []string{"enter", "open", "esc", "cancel"}
+		if len(m.textSearch.hits) > 1 {
+			h = append(h, "ctrl+p/ctrl+n ↑/↓", "choose")
+		}
+		return m.renderHelp(h)
+	}
+
+	// Help for the finder
+	if m.finding {
+		h := []string{"enter", "open", "esc", "cancel"}
+		if numDocs > 1 {
+			h = append(h, "ctrl+p/ctrl+n ↑/↓", "choose")
+		}
+		return m.renderHelp(h)
+	}
+
 	// Help for when we're filtering
 	if m.filterState == filtering {
 		var h []string
@@ -109,7 +149,7 @@ func (m stashModel) helpView() (string, int) {
 	)
 
 	if numDocs > 0 && m.showFullHelp {
-		navHelp = []string{"enter", "open", "j/k ↑/↓", "choose"}
+		navHelp = []string{"enter", "open", "j/k ↑/↓", "choose", "space", "mark", "O", "open in tab"}
 	}
 
 	if len(m.sections) > 1 {
@@ -124,11 +164,19 @@ func (m stashModel) helpView() (string, int) {
 		navHelp = append(navHelp, "h/l ←/→", "page")
 	}
 
+	// If files are marked to open together
+	if len(m.markedMarkdowns()) > 0 {
+		selectionHelp = []string{"enter", "open marked"}
+		if !m.filterApplied() {
+			selectionHelp = append(selectionHelp, "esc", "clear marks")
+		}
+	}
+
 	// If we're browsing a filtered set
 	if m.filterApplied() {
 		filterHelp = []string{"/", "edit search", "esc", "clear filter"}
 	} else {
-		filterHelp = []string{"/", "find"}
+		filterHelp = []string{"/", "find", "*", "glob", "ctrl+p", "open file", "ctrl+f", "search"}
 	}
 
 	// If there are errors
@@ -136,11 +184,13 @@ func (m stashModel) helpView() (string, int) {
 		appHelp = append(appHelp, "!", "errors")
 	}
 
-	appHelp = append(appHelp, "r", "refresh")
+	appHelp = append(appHelp, "o", "sort", "c", "columns", "v", "titles", ".", "hidden files", "r", "refresh")
 
 	if numDocs > 0 {
-		appHelp = append(appHelp, "e", "edit")
+		appHelp = append(appHelp, "p", "pin", "t", "tags", "T", "tag file")
+		editHelp = []string{"e", "edit", "R", "rename", "D", "delete"}
 	}
+	editHelp = append(editHelp, "n", "new")
 
 	appHelp = append(appHelp, "q", "quit")
 
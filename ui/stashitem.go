@@ -6,6 +6,7 @@ import (
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
+	"github.com/muesli/reflow/ansi"
 	"github.com/muesli/reflow/truncate"
 	"github.com/sahilm/fuzzy"
 )
@@ -16,19 +17,43 @@ const (
 )
 
 func stashItemView(b *strings.Builder, m stashModel, index int, md *markdown) {
+	columns, columnsWidth := m.columnsView(md, m.common.width-stashViewHorizontalPadding*2)
+
 	var (
-		truncateTo  = uint(m.common.width - stashViewHorizontalPadding*2) //nolint:gosec
+		truncateTo  = uint(m.common.width - stashViewHorizontalPadding*2 - columnsWidth) //nolint:gosec
+		label       = m.label(md)
 		gutter      string
-		title       = truncate.StringWithTail(md.Note, truncateTo, ellipsis)
+		title       = truncate.StringWithTail(label, truncateTo, ellipsis)
 		date        = md.relativeTime()
 		editedBy    = ""
 		hasEditedBy = false
 		icon        = ""
 		separator   = ""
 	)
+	if md.marked {
+		icon = markedIcon
+	}
+	if md.pinned {
+		icon += pinnedIcon
+	} else if md.recent > 0 {
+		icon += recentIcon
+	}
+	if icon != "" {
+		title = truncate.StringWithTail(label, truncateTo-uint(ansi.PrintableRuneWidth(icon)), ellipsis) //nolint:gosec
+	}
+
+	// The columns line up at the right
+	padding := max(0, int(truncateTo)-ansi.PrintableRuneWidth(icon)-ansi.PrintableRuneWidth(title)) //nolint:gosec
+
+	// A tag filter doesn't match the titles
+	query := m.filterInput.Value()
+	if m.tags.filter != "" {
+		query = ""
+	}
 
 	isSelected := index == m.cursor()
-	isFiltering := m.filterState == filtering
+	// The finder highlights the file enter opens, like browsing does
+	isFiltering := m.filterState == filtering && !m.finding
 	singleFilteredItem := isFiltering && len(m.getVisibleMarkdowns()) == 1
 
 	// If there are multiple items being filtered don't highlight a selected
@@ -48,7 +73,7 @@ func stashItemView(b *strings.Builder, m stashModel, index int, md *markdown) {
 			if m.currentSection().key == filterSection &&
 				m.filterState == filterApplied || singleFilteredItem {
 				s := lipgloss.NewStyle().Foreground(fuchsia)
-				title = styleFilteredText(title, m.filterInput.Value(), s, s.Underline(true))
+				title = styleFilteredText(title, query, s, s.Underline(true))
 			} else {
 				title = fuchsiaFg(title)
 				icon = fuchsiaFg(icon)
@@ -75,15 +100,42 @@ func stashItemView(b *strings.Builder, m stashModel, index int, md *markdown) {
 			icon = greenFg(icon)
 
 			s := lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#1a1a1a", Dark: "#dddddd"})
-			title = styleFilteredText(title, m.filterInput.Value(), s, s.Underline(true))
+			title = styleFilteredText(title, query, s, s.Underline(true))
 			date = grayFg(date)
 			editedBy = midGrayFg(editedBy)
 			separator = brightGrayFg(separator)
 		}
 	}
 
-	fmt.Fprintf(b, "%s %s%s%s%s\n", gutter, icon, separator, separator, title)
+	fmt.Fprintf(b, "%s %s%s%s%s", gutter, icon, separator, separator, title)
+	if columns != "" {
+		fmt.Fprintf(b, "%s%s", strings.Repeat(" ", padding), grayFg(columns))
+	}
+	b.WriteString("\n")
 	fmt.Fprintf(b, "%s %s", gutter, date)
+
+	// Titled files show their paths, and the finder shows the headings it
+	// matches
+	var details []string
+	switch {
+	case label != md.Note:
+		details = append(details, md.Note)
+	case m.finding && md.heading != "":
+		details = append(details, md.heading)
+	}
+	if tags := m.tagsOf(md); len(tags) > 0 && (!m.finding || md.heading == "") {
+		details = append(details, "#"+strings.Join(tags, " #"))
+	}
+	width := m.common.width - stashViewHorizontalPadding*2 - ansi.PrintableRuneWidth(date)
+	for _, detail := range details {
+		width -= ansi.PrintableRuneWidth(dividerDot.String())
+		if width <= 0 {
+			break
+		}
+		detail = truncate.StringWithTail(detail, uint(width), ellipsis) //nolint:gosec
+		fmt.Fprintf(b, "%s%s", dividerDot, grayFg(detail))
+		width -= ansi.PrintableRuneWidth(detail)
+	}
 	if hasEditedBy {
 		fmt.Fprintf(b, " %s", editedBy)
 	}
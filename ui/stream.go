@@ -0,0 +1,86 @@
+package ui
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// streamChunkMsg holds what arrived of a streamed document since the last
+// one, and whether the stream has ended.
+type streamChunkMsg struct {
+	data string
+	done bool
+	err  error
+}
+
+// streamReader reads a streamed document, such as a pipe to stdin, in the
+// background, holding what arrived until the pager is ready to render it.
+// Chunks that arrive while a render is in progress are rendered together.
+type streamReader struct {
+	mu    sync.Mutex
+	buf   strings.Builder
+	done  bool
+	err   error
+	ready chan struct{}
+}
+
+func newStreamReader(r io.Reader) *streamReader {
+	s := &streamReader{ready: make(chan struct{}, 1)}
+	go s.read(r)
+	return s
+}
+
+func (s *streamReader) read(r io.Reader) {
+	p := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(p)
+
+		s.mu.Lock()
+		s.buf.Write(p[:n])
+		if err != nil {
+			s.done = true
+			if !errors.Is(err, io.EOF) {
+				s.err = err
+			}
+		}
+		s.mu.Unlock()
+
+		// Wake up next, unless it's already been woken
+		select {
+		case s.ready <- struct{}{}:
+		default:
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// next waits for more of the document to arrive.
+func (s *streamReader) next() tea.Msg {
+	<-s.ready
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg := streamChunkMsg{data: s.buf.String(), done: s.done, err: s.err}
+	s.buf.Reset()
+	return msg
+}
+
+// appendStream adds a chunk of the streamed document and renders it again.
+func (m *pagerModel) appendStream(msg streamChunkMsg) tea.Cmd {
+	m.streamWaiting = false
+	m.currentDocument.Body += msg.data
+	if msg.done {
+		m.stream = nil
+	}
+	cmds := []tea.Cmd{renderWithGlamour(*m, m.currentDocument.Body)}
+	if msg.err != nil {
+		cmds = append(cmds, m.showStatusMessage(pagerStatusMessage{"Couldn’t read input: " + msg.err.Error(), true}))
+	}
+	return tea.Batch(cmds...)
+}
@@ -0,0 +1,66 @@
+package ui
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestStreamedDocument(t *testing.T) {
+	r, w := io.Pipe()
+	m := newTestPagerModel()
+	m.currentDocument = markdown{}
+	m.stream = newStreamReader(r)
+	m.follow = true
+
+	// Each render waits for more of the document, once
+	m, _ = m.update(contentRenderedMsg{content: ""})
+	if !m.streamWaiting {
+		t.Fatal("Expected the pager to wait for the stream")
+	}
+	receive := func(s string) {
+		t.Helper()
+		go func() { _, _ = io.WriteString(w, s) }()
+		msg, ok := m.stream.next().(streamChunkMsg)
+		if !ok || msg.data != s {
+			t.Fatalf("Expected a chunk of %q, got %+v", s, msg)
+		}
+		m, _ = m.update(msg)
+		m, _ = m.update(contentRenderedMsg{content: m.currentDocument.Body})
+	}
+
+	receive(strings.Repeat("line\n", 30))
+	if !m.viewport.AtBottom() || !m.follow {
+		t.Fatal("Expected the pager to follow the end of the document")
+	}
+
+	// Scrolling up stops following
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyUp})
+	offset := m.viewport.YOffset
+	receive(strings.Repeat("more\n", 10))
+	if m.follow || m.viewport.YOffset != offset {
+		t.Fatalf("Expected the pager to stay at %d, got %d", offset, m.viewport.YOffset)
+	}
+
+	// Scrolling back to the end follows again
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("G")})
+	receive(strings.Repeat("end\n", 10))
+	if !m.viewport.AtBottom() {
+		t.Fatal("Expected the pager to follow the end again")
+	}
+
+	_ = w.Close()
+	msg := m.stream.next().(streamChunkMsg)
+	if !msg.done || msg.err != nil {
+		t.Fatalf("Expected the stream to end, got %+v", msg)
+	}
+	m, _ = m.update(msg)
+	if m.stream != nil {
+		t.Error("Expected the pager to stop reading the stream")
+	}
+	if got := strings.Count(m.currentDocument.Body, "\n"); got != 50 {
+		t.Errorf("Expected 50 lines, got %d", got)
+	}
+}
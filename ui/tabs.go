@@ -0,0 +1,105 @@
+package ui
+
+import (
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/muesli/reflow/truncate"
+)
+
+// tabBarHeight is the height of the bar naming the files opened as tabs.
+const tabBarHeight = 1
+
+// hasTabs reports whether several files are open as tabs.
+func (m pagerModel) hasTabs() bool {
+	return len(m.tabs) > 1
+}
+
+// switchTab shows the tab at index, wrapping around at either end. Each tab
+// keeps its scroll position.
+func (m *pagerModel) switchTab(index int) tea.Cmd {
+	if !m.hasTabs() {
+		return nil
+	}
+	index = (index%len(m.tabs) + len(m.tabs)) % len(m.tabs)
+	if index == m.tab {
+		return nil
+	}
+
+	m.tabOffsets[m.tab] = m.viewport.YOffset
+	m.tab = index
+	m.state = pagerStateBrowse
+	m.outlineFocused = false
+	m.clearSearch()
+	m.xOffset = 0
+	m.unwatchFile()
+
+	// Set directly, since the offset may be past the end of the tab left
+	m.viewport.YOffset = m.tabOffsets[index]
+	return loadLocalMarkdown(m.tabs[index])
+}
+
+// sameTab reports whether a and b are the same file, so open in one tab.
+func sameTab(a, b *markdown) bool {
+	return a == b || (a.localPath == b.localPath && len(a.parts) == 0 && len(b.parts) == 0)
+}
+
+// tabIndex returns the index of the tab md is open in, or -1 if it isn't.
+func (m pagerModel) tabIndex(md *markdown) int {
+	return slices.IndexFunc(m.tabs, func(tab *markdown) bool {
+		return sameTab(tab, md)
+	})
+}
+
+// addTabs opens tabs for the files in mds that aren't open yet.
+func (m *pagerModel) addTabs(mds []*markdown) {
+	for _, md := range mds {
+		if m.tabIndex(md) < 0 {
+			m.tabs = append(m.tabs, md)
+			m.tabOffsets = append(m.tabOffsets, 0)
+		}
+	}
+}
+
+// selectTab marks md as the tab shown, adding a tab for it if it isn't open.
+func (m *pagerModel) selectTab(md *markdown) {
+	index := m.tabIndex(md)
+	if index < 0 {
+		m.addTabs([]*markdown{md})
+		index = len(m.tabs) - 1
+	}
+	if index != m.tab && m.tab < len(m.tabOffsets) {
+		m.tabOffsets[m.tab] = m.viewport.YOffset
+	}
+	m.tab = index
+}
+
+// openBackgroundTab opens the selected file in a tab without leaving the
+// listing. The tabs are shown along with the next file opened.
+func (m *stashModel) openBackgroundTab() tea.Cmd {
+	md := m.selectedMarkdown()
+	if md == nil {
+		return nil
+	}
+	if slices.ContainsFunc(m.tabs, func(tab *markdown) bool { return sameTab(tab, md) }) {
+		return m.newStatusMessage(statusMessage{normalStatusMessage, md.Note + " is already in a tab"})
+	}
+	m.tabs = append(m.tabs, md)
+	return m.newStatusMessage(statusMessage{normalStatusMessage, "Opened " + md.Note + " in a tab"})
+}
+
+// tabBarView returns the bar naming the tabs, with the current one
+// highlighted.
+func (m pagerModel) tabBarView() string {
+	names := make([]string, len(m.tabs))
+	for i, md := range m.tabs {
+		if i == m.tab {
+			names[i] = selectedTabStyle.Render(md.Note)
+		} else {
+			names[i] = tabStyle.Render(md.Note)
+		}
+	}
+	bar := " " + strings.Join(names, dividerBar.String())
+	return truncate.StringWithTail(bar, uint(max(0, m.common.width)), ellipsis) //nolint:gosec
+}
@@ -0,0 +1,297 @@
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+	"github.com/hholst80/glow/utils"
+	"github.com/muesli/reflow/truncate"
+)
+
+// tagsLoadedMsg holds the front matter tags of files.
+type tagsLoadedMsg map[*markdown][]string
+
+// tagCount is a tag and the number of files that have it.
+type tagCount struct {
+	tag   string
+	files int
+}
+
+// tagsModel is the state of tagging in the file listing: the tags assigned
+// to files in the listing, the list of tags to filter by and the prompt that
+// edits a file's tags.
+type tagsModel struct {
+	user     map[string][]string // tags assigned in the listing, by path
+	choosing bool                // the list of tags is open
+	cursor   int
+	filter   string // tag the listing is filtered by
+	editing  *markdown
+	input    textinput.Model
+}
+
+func newTagsModel(file string) tagsModel {
+	ti := textinput.New()
+	ti.Prompt = "Tags:"
+	ti.PromptStyle = stashInputPromptStyle
+	ti.Cursor.Style = stashInputCursorStyle
+
+	user, err := loadUserTags(file)
+	if err != nil {
+		log.Error("unable to load tags", "error", err)
+	}
+	return tagsModel{user: user, input: ti}
+}
+
+// loadUserTags reads the tags assigned in the listing. A missing file has
+// none.
+func loadUserTags(file string) (map[string][]string, error) {
+	tags := make(map[string][]string)
+	if file == "" {
+		return tags, nil
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return tags, nil
+	}
+	if err != nil {
+		return tags, err
+	}
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return make(map[string][]string), fmt.Errorf("unable to parse %s: %w", file, err)
+	}
+	return tags, nil
+}
+
+// saveUserTags writes the tags assigned in the listing.
+func saveUserTags(file string, tags map[string][]string) error {
+	if file == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(tags, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0o600)
+}
+
+// parseTags splits a comma or space separated list of tags, dropping any
+// leading "#" and duplicates.
+func parseTags(s string) []string {
+	var tags []string
+	for _, t := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if t = strings.TrimPrefix(t, "#"); t != "" && !slices.Contains(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// loadTags reads the front matter tags of the files that haven't had them
+// read.
+func loadTags(mds []*markdown) tea.Cmd {
+	var pending []*markdown
+	for _, md := range mds {
+		if !md.tagsLoaded {
+			pending = append(pending, md)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	return func() tea.Msg {
+		tags := make(tagsLoadedMsg, len(pending))
+		for _, md := range pending {
+			tags[md] = frontmatterTags(md.localPath)
+		}
+		return tags
+	}
+}
+
+// frontmatterTags returns the tags in the front matter of a file.
+func frontmatterTags(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close() //nolint:errcheck
+
+	data, err := io.ReadAll(io.LimitReader(f, finderReadLimit))
+	if err != nil {
+		return nil
+	}
+	return utils.FrontmatterTags(data)
+}
+
+// tagsOf returns the tags of a file, from its front matter and assigned in
+// the listing, sorted.
+func (m stashModel) tagsOf(md *markdown) []string {
+	tags := slices.Concat(md.tags, m.tags.user[md.localPath])
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
+// tagCounts returns the tags of the listed files, sorted.
+func (m stashModel) tagCounts() []tagCount {
+	counts := make(map[string]int)
+	for _, md := range m.markdowns {
+		for _, tag := range m.tagsOf(md) {
+			counts[tag]++
+		}
+	}
+	tags := make([]tagCount, 0, len(counts))
+	for tag, n := range counts {
+		tags = append(tags, tagCount{tag, n})
+	}
+	slices.SortFunc(tags, func(a, b tagCount) int {
+		return strings.Compare(strings.ToLower(a.tag), strings.ToLower(b.tag))
+	})
+	return tags
+}
+
+// markdownsTagged returns the listed files that have tag.
+func (m stashModel) markdownsTagged(tag string) []*markdown {
+	filtered := []*markdown{}
+	for _, md := range m.markdowns {
+		if slices.Contains(m.tagsOf(md), tag) {
+			filtered = append(filtered, md)
+		}
+	}
+	return filtered
+}
+
+// startChoosingTag opens the list of tags to filter the listing by.
+func (m *stashModel) startChoosingTag() {
+	m.tags.choosing = true
+	m.tags.cursor = 0
+}
+
+// filterByTag narrows the listing to the files that have tag, like a filter.
+func (m *stashModel) filterByTag(tag string) {
+	m.tags.choosing = false
+	m.tags.filter = tag
+	m.filterInput.SetValue("#" + tag)
+	m.filteredMarkdowns = m.markdownsTagged(tag)
+	m.filterState = filterApplied
+
+	if m.sections[len(m.sections)-1].key != filterSection {
+		m.sections = append(m.sections, sections[filterSection])
+	}
+	m.sectionIndex = len(m.sections) - 1
+	m.paginator().Page = 0
+	m.setCursor(0)
+	m.updatePagination()
+}
+
+// handleChoosingTag handles keys while the list of tags is open.
+func (m *stashModel) handleChoosingTag(msg tea.Msg) tea.Cmd {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return nil
+	}
+	tags := m.tagCounts()
+	switch key.String() {
+	case keyEsc, "t", "q":
+		m.tags.choosing = false
+	case "k", "ctrl+k", "up":
+		m.tags.cursor = max(0, m.tags.cursor-1)
+	case "j", "ctrl+j", "down":
+		m.tags.cursor = max(0, min(len(tags)-1, m.tags.cursor+1))
+	case keyEnter:
+		if m.tags.cursor < len(tags) {
+			m.filterByTag(tags[m.tags.cursor].tag)
+		}
+	}
+	return nil
+}
+
+// startEditingTags opens the prompt that edits the tags assigned to the
+// selected file.
+func (m *stashModel) startEditingTags() tea.Cmd {
+	md := m.selectedMarkdown()
+	if md == nil {
+		return nil
+	}
+	m.tags.editing = md
+	m.tags.input.SetValue(strings.Join(m.tags.user[md.localPath], " "))
+	m.tags.input.Width = m.common.width - stashViewHorizontalPadding*2 - len(m.tags.input.Prompt)
+	m.tags.input.CursorEnd()
+	m.tags.input.Focus()
+	return textinput.Blink
+}
+
+// handleEditingTags handles keys while the tags prompt is open. Enter saves
+// the tags typed.
+func (m *stashModel) handleEditingTags(msg tea.Msg) tea.Cmd {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.String() {
+		case keyEsc:
+			m.tags.editing = nil
+			m.tags.input.Blur()
+			return nil
+		case keyEnter:
+			md := m.tags.editing
+			m.tags.editing = nil
+			m.tags.input.Blur()
+			if tags := parseTags(m.tags.input.Value()); len(tags) > 0 {
+				m.tags.user[md.localPath] = tags
+			} else {
+				delete(m.tags.user, md.localPath)
+			}
+			if err := saveUserTags(m.common.cfg.TagsFile, m.tags.user); err != nil {
+				log.Error("unable to save tags", "error", err)
+				return m.newStatusMessage(statusMessage{errorStatusMessage, "Couldn’t save tags"})
+			}
+			if m.tags.filter != "" {
+				m.filterByTag(m.tags.filter)
+			}
+			return nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.tags.input, cmd = m.tags.input.Update(msg)
+	return cmd
+}
+
+// tagsView lists the tags to filter the listing by.
+func (m stashModel) tagsView() string {
+	tags := m.tagCounts()
+	if len(tags) == 0 {
+		return "  " + grayFg("No tags found.")
+	}
+
+	truncateTo := uint(max(0, m.common.width-stashViewHorizontalPadding*2)) //nolint:gosec
+	lines := make([]string, 0, len(tags))
+	for i, t := range tags {
+		files := "files"
+		if t.files == 1 {
+			files = "file"
+		}
+		tag := truncate.StringWithTail("#"+t.tag, truncateTo, ellipsis)
+		count := fmt.Sprintf("%d %s", t.files, files)
+		if i == m.tags.cursor {
+			lines = append(lines, dullFuchsiaFg(verticalLine)+" "+fuchsiaFg(tag)+dividerDot.String()+dimFuchsiaFg(count))
+		} else {
+			lines = append(lines, "  "+greenFg(tag)+dividerDot.String()+grayFg(count))
+		}
+	}
+
+	// Show as many lines as the file listing would, around the chosen tag
+	height := max(1, m.paginator().PerPage*stashViewItemHeight-1)
+	start := max(0, min(m.tags.cursor-height/2, len(lines)-height))
+	end := min(len(lines), start+height)
+	return strings.Join(lines[start:end], "\n")
+}
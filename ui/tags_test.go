@@ -0,0 +1,99 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestFrontmatterTags(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"list", "---\ntags: [go, \"#cli\"]\n---\n# Notes", []string{"go", "cli"}},
+		{"block list", "---\ntags:\n  - go\n  - tui\n---\n", []string{"go", "tui"}},
+		{"string", "---\ntags: go, cli tui\n---\n", []string{"go", "cli", "tui"}},
+		{"no tags", "---\ntitle: Notes\n---\n", nil},
+		{"no front matter", "# tags: go", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".md")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if got := frontmatterTags(path); !slices.Equal(got, tt.want) {
+				t.Errorf("frontmatterTags() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	if got, want := parseTags(" #go, cli  go,,tui "), []string{"go", "cli", "tui"}; !slices.Equal(got, want) {
+		t.Errorf("parseTags() = %q, want %q", got, want)
+	}
+}
+
+func TestTagFilter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tags.json")
+	initSections()
+	m := newStashModel(&commonModel{width: 80, height: 40, cfg: Config{TagsFile: file}})
+	a := &markdown{Note: "a.md", localPath: "/docs/a.md"}
+	b := &markdown{Note: "b.md", localPath: "/docs/b.md"}
+	c := &markdown{Note: "c.md", localPath: "/docs/c.md"}
+	m.addMarkdowns(a, b, c)
+	m, _ = m.update(tagsLoadedMsg{a: {"go"}, b: {"go", "tui"}, c: nil})
+
+	// Tag c in the listing
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyDown})
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyDown})
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("T")})
+	if m.tags.editing != c {
+		t.Fatal("Expected the tags prompt to edit c")
+	}
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("tui, #ideas")})
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyEnter})
+	if got := m.tagsOf(c); !slices.Equal(got, []string{"ideas", "tui"}) {
+		t.Errorf("tagsOf(c) = %q", got)
+	}
+	if saved, _ := loadUserTags(file); !slices.Equal(saved["/docs/c.md"], []string{"tui", "ideas"}) {
+		t.Errorf("saved tags = %v", saved)
+	}
+
+	// The tag list counts the files of each tag
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("t")})
+	if !m.tags.choosing {
+		t.Fatal("Expected t to open the tag list")
+	}
+	want := []tagCount{{"go", 2}, {"ideas", 1}, {"tui", 2}}
+	if got := m.tagCounts(); !slices.Equal(got, want) {
+		t.Errorf("tagCounts() = %v, want %v", got, want)
+	}
+	if view := stripANSI(m.tagsView()); !strings.Contains(view, "#tui • 2 files") {
+		t.Errorf("Expected the tag list to show the count of tui:\n%s", view)
+	}
+
+	// Choosing a tag filters the listing
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyDown})
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyDown})
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.tags.choosing || m.tags.filter != "tui" || m.filterState != filterApplied {
+		t.Fatalf("Expected a tui filter, got %q", m.tags.filter)
+	}
+	if got := m.getVisibleMarkdowns(); !slices.Equal(got, []*markdown{b, c}) {
+		t.Errorf("Expected b and c to be shown, got %v", got)
+	}
+
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.tags.filter != "" || len(m.getVisibleMarkdowns()) != 3 {
+		t.Error("Expected esc to clear the tag filter")
+	}
+}
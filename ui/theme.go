@@ -0,0 +1,133 @@
+package ui
+
+import "github.com/charmbracelet/lipgloss"
+
+// Theme contains user-overridable colors for the TUI chrome: the outline
+// sidebar, the status bar and line numbers. Colors can be hex values
+// ("#ECFD65") or ANSI color numbers ("205"). Empty values keep the default.
+type Theme struct {
+	OutlineTitle    string
+	OutlineText     string
+	OutlineCurrent  string
+	OutlineCursorFg string
+	OutlineCursorBg string
+	OutlineBorder   string
+
+	StatusBarFg        string
+	StatusBarBg        string
+	StatusBarMessageFg string
+	StatusBarMessageBg string
+
+	LineNumber string
+}
+
+// Default chrome colors.
+var (
+	mintGreen          = lipgloss.AdaptiveColor{Light: "#89F0CB", Dark: "#89F0CB"}
+	darkGreen          = lipgloss.AdaptiveColor{Light: "#1C8760", Dark: "#1C8760"}
+	lineNumberFg       = lipgloss.AdaptiveColor{Light: "#656565", Dark: "#7D7D7D"}
+	statusBarNoteFg    = lipgloss.AdaptiveColor{Light: "#656565", Dark: "#7D7D7D"}
+	statusBarBg        = lipgloss.AdaptiveColor{Light: "#E6E6E6", Dark: "#242424"}
+	outlineBorderColor = lipgloss.AdaptiveColor{Light: "#DCDCDC", Dark: "#3C3C3C"}
+)
+
+// Chrome styles. These are built from the active theme by applyTheme.
+var (
+	statusBarScrollPosStyle        func(...string) string
+	statusBarNoteStyle             func(...string) string
+	statusBarHelpStyle             func(...string) string
+	statusBarMessageStyle          func(...string) string
+	statusBarMessageScrollPosStyle func(...string) string
+	statusBarMessageHelpStyle      func(...string) string
+	helpViewStyle                  func(...string) string
+	lineNumberStyle                func(...string) string
+
+	outlineTitleStyle   lipgloss.Style
+	outlineNormalStyle  lipgloss.Style
+	outlineCurrentStyle lipgloss.Style
+	outlineCursorStyle  lipgloss.Style
+	outlinePanelStyle   lipgloss.Style
+)
+
+func init() {
+	applyTheme(Theme{})
+}
+
+// themeColor returns the color for a theme value, falling back to def if the
+// value is empty.
+func themeColor(v string, def lipgloss.TerminalColor) lipgloss.TerminalColor {
+	if v == "" {
+		return def
+	}
+	return lipgloss.Color(v)
+}
+
+// applyTheme builds the chrome styles from the given theme.
+func applyTheme(t Theme) {
+	var (
+		barFg     = themeColor(t.StatusBarFg, statusBarNoteFg)
+		barBg     = themeColor(t.StatusBarBg, statusBarBg)
+		messageFg = themeColor(t.StatusBarMessageFg, mintGreen)
+		messageBg = themeColor(t.StatusBarMessageBg, darkGreen)
+	)
+
+	statusBarScrollPosStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.AdaptiveColor{Light: "#949494", Dark: "#5A5A5A"}).
+		Background(barBg).
+		Render
+
+	statusBarNoteStyle = lipgloss.NewStyle().
+		Foreground(barFg).
+		Background(barBg).
+		Render
+
+	statusBarHelpStyle = lipgloss.NewStyle().
+		Foreground(barFg).
+		Background(lipgloss.AdaptiveColor{Light: "#DCDCDC", Dark: "#323232"}).
+		Render
+
+	statusBarMessageStyle = lipgloss.NewStyle().
+		Foreground(messageFg).
+		Background(messageBg).
+		Render
+
+	statusBarMessageScrollPosStyle = lipgloss.NewStyle().
+		Foreground(messageFg).
+		Background(messageBg).
+		Render
+
+	statusBarMessageHelpStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#B6FFE4")).
+		Background(green).
+		Render
+
+	helpViewStyle = lipgloss.NewStyle().
+		Foreground(barFg).
+		Background(lipgloss.AdaptiveColor{Light: "#f2f2f2", Dark: "#1B1B1B"}).
+		Render
+
+	lineNumberStyle = lipgloss.NewStyle().
+		Foreground(themeColor(t.LineNumber, lineNumberFg)).
+		Render
+
+	outlineTitleStyle = lipgloss.NewStyle().
+		Bold(true).
+		Foreground(themeColor(t.OutlineTitle, fuchsia)).
+		Padding(0, 1)
+
+	outlineNormalStyle = lipgloss.NewStyle().
+		Foreground(themeColor(t.OutlineText, gray))
+
+	outlineCurrentStyle = lipgloss.NewStyle().
+		Foreground(themeColor(t.OutlineCurrent, yellowGreen)).
+		Bold(true)
+
+	outlineCursorStyle = lipgloss.NewStyle().
+		Background(themeColor(t.OutlineCursorBg, darkGray)).
+		Foreground(themeColor(t.OutlineCursorFg, cream))
+
+	outlinePanelStyle = lipgloss.NewStyle().
+		BorderLeft(true).
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(themeColor(t.OutlineBorder, outlineBorderColor))
+}
@@ -0,0 +1,28 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestApplyTheme(t *testing.T) {
+	defer applyTheme(Theme{})
+
+	applyTheme(Theme{
+		OutlineCurrent: "#FF0000",
+		OutlineBorder:  "42",
+	})
+
+	if got := outlineCurrentStyle.GetForeground(); got != lipgloss.Color("#FF0000") {
+		t.Errorf("outline current foreground = %v, want #FF0000", got)
+	}
+	if got := outlinePanelStyle.GetBorderLeftForeground(); got != lipgloss.Color("42") {
+		t.Errorf("outline border foreground = %v, want 42", got)
+	}
+
+	// Unset values keep the defaults
+	if got := outlineNormalStyle.GetForeground(); got != gray {
+		t.Errorf("outline text foreground = %v, want default %v", got, gray)
+	}
+}
@@ -0,0 +1,28 @@
+package ui
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// toggleTitles labels the files of the listing by their titles, or by their
+// paths, reading the titles it hasn't yet.
+func (m *stashModel) toggleTitles() tea.Cmd {
+	m.showTitles = !m.showTitles
+	if m.showTitles {
+		return loadHeadings(m.markdowns)
+	}
+	return nil
+}
+
+// needsHeadings reports whether the listing shows or sorts by the files'
+// first headings, which are then read once the files are found.
+func (m stashModel) needsHeadings() bool {
+	return m.showTitles || m.sort == sortByTitle
+}
+
+// label returns what a file is listed as: its front matter title or first
+// level 1 heading when titles are shown and it has one, or else its path.
+func (m stashModel) label(md *markdown) string {
+	if m.showTitles && md.title != "" {
+		return md.title
+	}
+	return md.Note
+}
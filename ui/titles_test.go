@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestShowTitles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.md")
+	if err := os.WriteFile(path, []byte("---\ntitle: Meeting Notes\n---\n# Agenda\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	initSections()
+	m := newStashModel(&commonModel{width: 80, height: 40, cwd: dir, cfg: Config{ShowTitles: true}})
+	md := &markdown{localPath: path, Note: "notes.md"}
+	m.addMarkdowns(md)
+
+	if !m.needsHeadings() {
+		t.Fatal("Expected the titles to be read once the files are found")
+	}
+	m, _ = m.update(loadHeadings(m.markdowns)())
+	if md.title != "Meeting Notes" {
+		t.Fatalf("Expected the front matter title, got %q", md.title)
+	}
+
+	var b strings.Builder
+	stashItemView(&b, m, 0, md)
+	lines := strings.Split(b.String(), "\n")
+	if !strings.Contains(lines[0], "Meeting Notes") || !strings.Contains(lines[1], "notes.md") {
+		t.Errorf("Expected the title with the path below, got %q", b.String())
+	}
+
+	// v lists the files by path again
+	m, _ = m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("v")})
+	b.Reset()
+	stashItemView(&b, m, 0, md)
+	if lines := strings.Split(b.String(), "\n"); strings.Contains(b.String(), "Meeting Notes") || !strings.Contains(lines[0], "notes.md") {
+		t.Errorf("Expected only the path, got %q", b.String())
+	}
+}
+
+func TestShowTitles_LevelOneHeading(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.md")
+	if err := os.WriteFile(path, []byte("## Agenda\n\nText\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	initSections()
+	m := newStashModel(&commonModel{width: 80, height: 40, cwd: dir, cfg: Config{ShowTitles: true}})
+	md := &markdown{localPath: path, Note: "notes.md"}
+	m.addMarkdowns(md)
+	m, _ = m.update(loadHeadings(m.markdowns)())
+
+	// A file without a level 1 heading has no title
+	if label := m.label(md); label != "notes.md" {
+		t.Errorf("Expected the path, got %q", label)
+	}
+}
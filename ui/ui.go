@@ -10,7 +10,12 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/glamour/styles"
+	"github.com/hholst80/glow/mermaid"
+	"github.com/hholst80/glow/org"
+	"github.com/hholst80/glow/rst"
+	"github.com/hholst80/glow/tables"
 	"github.com/hholst80/glow/utils"
+	"github.com/hholst80/glow/wikilinks"
 	"github.com/charmbracelet/log"
 	"github.com/muesli/gitcha"
 )
@@ -25,12 +30,30 @@ var (
 
 	markdownExtensions = []string{
 		"*.md", "*.mdown", "*.mkdn", "*.mkd", "*.markdown",
+		"*" + org.Extension, "*" + rst.Extension,
 	}
 )
 
 // NewProgram returns a new Tea program using the real terminal and renderer.
 func NewProgram(cfg Config, content string) *tea.Program {
-	return NewProgramWithDeps(cfg, content, RealTerminal{}, NewMarkdownRenderer())
+	renderer := NewMarkdownRenderer()
+	renderer.Diagrams = diagramRenderer(cfg)
+	renderer.DiagramTimeout = diagramTimeout(cfg)
+	renderer.DiagramMode = cfg.MermaidMode
+	protocol := mermaid.DetectImageProtocol()
+	if images := diagramImages(cfg, protocol); images != nil {
+		renderer.Images = images
+		renderer.ImageProtocol = protocol
+	}
+	renderer.ImageTimeout = imageTimeout(cfg)
+	renderer.Languages = diagramLanguages(cfg)
+	renderer.Filters = codeFilters(cfg)
+	renderer.Figures = cfg.Figures
+	renderer.Emoji = cfg.Emoji
+	renderer.FormatJSON = cfg.FormatJSON
+	renderer.HighlightData = cfg.HighlightData
+	renderer.cache = newRenderCache(cfg.RenderCacheDir, renderSettings(cfg)...)
+	return NewProgramWithDeps(cfg, content, RealTerminal{}, renderer)
 }
 
 // NewProgramWithDeps returns a new Tea program with injectable dependencies.
@@ -45,6 +68,7 @@ func NewProgramWithDeps(cfg Config, content string, term Terminal, renderer Mark
 	)
 
 	config = cfg
+	applyTheme(cfg.Theme)
 	opts := []tea.ProgramOption{tea.WithAltScreen()}
 	if cfg.EnableMouse {
 		opts = append(opts, tea.WithMouseCellMotion())
@@ -59,8 +83,9 @@ func (e errMsg) Error() string { return e.err.Error() }
 
 type (
 	initLocalFileSearchMsg struct {
-		cwd string
-		ch  chan gitcha.SearchResult
+		cwd     string
+		ch      chan gitcha.SearchResult
+		watcher *dirWatcher
 	}
 )
 
@@ -116,6 +141,9 @@ type model struct {
 	// Channel that receives paths to local markdown files
 	// (via the github.com/muesli/gitcha package)
 	localFileFinder chan gitcha.SearchResult
+
+	// Watches the listed directories once they've been searched
+	localFileWatcher *dirWatcher
 }
 
 // unloadDocument unloads a document from the pager. Note that while this
@@ -161,10 +189,34 @@ func newModel(cfg Config, content string, term Terminal, renderer MarkdownRender
 		stash:  newStashModel(&common),
 	}
 
+	if len(cfg.Tabs) > 0 {
+		cwd, _ := os.Getwd()
+		for _, path := range cfg.Tabs {
+			info, err := os.Stat(path)
+			if err != nil {
+				log.Error("unable to stat file", "file", path, "error", err)
+				m.fatalErr = err
+				return m
+			}
+			m.pager.tabs = append(m.pager.tabs, localDocument(path, cwd, info))
+		}
+		m.pager.tabOffsets = make([]int, len(m.pager.tabs))
+		m.state = stateShowDocument
+		m.pager.currentDocument = *m.pager.tabs[0]
+		m.pager.applyOutlineOption(markdown{}, m.pager.currentDocument)
+		return m
+	}
+
 	path := cfg.Path
 	if path == "" && content != "" {
 		m.state = stateShowDocument
-		m.pager.currentDocument = markdown{Body: content}
+		m.pager.currentDocument = markdown{Body: content, files: cfg.Files}
+		return m
+	}
+	if cfg.Stream != nil {
+		m.state = stateShowDocument
+		m.pager.stream = newStreamReader(cfg.Stream)
+		m.pager.follow = true
 		return m
 	}
 
@@ -182,23 +234,41 @@ func newModel(cfg Config, content string, term Terminal, renderer MarkdownRender
 	} else {
 		cwd, _ := os.Getwd()
 		m.state = stateShowDocument
-		// Read the file content now so outline can parse it
-		content, err := os.ReadFile(path)
-		var body string
-		if err == nil {
-			body = string(utils.RemoveFrontmatter(content))
-		}
-		m.pager.currentDocument = markdown{
-			localPath: path,
-			Note:      stripAbsolutePath(path, cwd),
-			Modtime:   info.ModTime(),
-			Body:      body,
-		}
+		m.pager.currentDocument = *localDocument(path, cwd, info)
+		m.pager.applyOutlineOption(markdown{}, m.pager.currentDocument)
 	}
 
 	return m
 }
 
+// localDocument returns the document of a file opened from the command line,
+// read now so the outline can parse it.
+func localDocument(path, cwd string, info os.FileInfo) *markdown {
+	content, err := os.ReadFile(path)
+	content = utils.DocumentContent(path, content)
+	var body, title string
+	var bodyLine int
+	var strategy tables.Strategy
+	var options utils.RenderOptions
+	if err == nil {
+		body = string(utils.RemoveFrontmatter(content))
+		bodyLine = strings.Count(string(content), "\n") - strings.Count(body, "\n")
+		title = utils.FrontmatterTitle(content)
+		strategy = tables.DocumentStrategy(content, "")
+		options = utils.FrontmatterRenderOptions(content)
+	}
+	return &markdown{
+		localPath: path,
+		Note:      stripAbsolutePath(path, cwd),
+		Title:     title,
+		tables:    strategy,
+		options:   options,
+		Modtime:   info.ModTime(),
+		Body:      body,
+		bodyLine:  bodyLine,
+	}
+}
+
 func (m model) Init() tea.Cmd {
 	cmds := []tea.Cmd{m.stash.spinner.Tick}
 
@@ -225,8 +295,19 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		// pass through all keys if we're typing a search in the pager
+		if m.state == stateShowDocument && m.pager.capturesKeys() && msg.String() != "ctrl+c" {
+			var cmd tea.Cmd
+			m.pager, cmd = m.pager.update(msg)
+			return m, cmd
+		}
+
 		switch msg.String() {
 		case "esc":
+			// esc clears an active search before leaving the document
+			if m.state == stateShowDocument && m.pager.searchActive() {
+				break
+			}
 			if m.state == stateShowDocument || m.stash.viewState == stashStateLoadingDocument {
 				batch := m.unloadDocument()
 				return m, tea.Batch(batch...)
@@ -235,7 +316,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			var cmd tea.Cmd
 			if m.state == stateShowStash {
 				// pass through all keys if we're editing the filter
-				if m.stash.filterState == filtering {
+				if m.stash.capturesKeys() {
 					m.stash, cmd = m.stash.update(msg)
 					return m, cmd
 				}
@@ -249,7 +330,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			switch m.state { //nolint:exhaustive
 			case stateShowStash:
 				// pass through all keys if we're editing the filter
-				if m.stash.filterState == filtering {
+				if m.stash.capturesKeys() {
 					m.stash, cmd = m.stash.update(msg)
 					return m, cmd
 				}
@@ -280,25 +361,56 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case initLocalFileSearchMsg:
 		m.localFileFinder = msg.ch
+		if m.localFileWatcher != nil {
+			m.localFileWatcher.close()
+		}
+		m.localFileWatcher = msg.watcher
 		m.common.cwd = msg.cwd
 		cmds = append(cmds, findNextLocalFile(m))
 
 	case fetchedMarkdownMsg:
 		// We've loaded a markdown file's contents for rendering
+		if msg.localPath != m.pager.currentDocument.localPath {
+			m.pager.toggledDetails = nil
+			m.pager.applyOutlineOption(m.pager.currentDocument, *msg)
+		}
 		m.pager.currentDocument = *msg
-		body := string(utils.RemoveFrontmatter([]byte(msg.Body)))
-		cmds = append(cmds, renderWithGlamour(m.pager, body))
+		if len(m.stash.tabs) > 0 {
+			m.pager.addTabs(m.stash.tabs)
+			m.stash.tabs = nil
+		}
+		if len(m.pager.tabs) > 0 {
+			m.pager.selectTab(msg)
+		}
+		// Size the pager for the new document before rendering, since the
+		// outline width may depend on its headings.
+		m.pager.setSize(m.common.width, m.common.height)
+		cmds = append(cmds, renderWithGlamour(m.pager, msg.Body))
 
-	case contentRenderedMsg:
+	case contentRenderedMsg, chunksRenderedMsg:
 		m.state = stateShowDocument
 
+	case openSearchHitMsg:
+		m.pager.pendingHit = &msg
+		cmds = append(cmds, m.stash.openMarkdown(msg.md))
+
+	case openWikiLinkMsg:
+		cmds = append(cmds, m.openWikiLink(wikilinks.Link(msg)))
+
 	case localFileSearchFinished:
 		// Always pass these messages to the stash so we can keep it updated
 		// about network activity, even if the user isn't currently viewing
 		// the stash.
 		stashModel, cmd := m.stash.update(msg)
 		m.stash = stashModel
-		return m, cmd
+		return m, tea.Batch(cmd, watchLocalFiles(m))
+
+	case localFileCreatedMsg:
+		cmds = append(cmds, m.stash.addLocalFile(gitcha.SearchResult(msg)), watchLocalFiles(m))
+
+	case localFileRemovedMsg:
+		m.stash.removeLocalPath(string(msg))
+		cmds = append(cmds, watchLocalFiles(m))
 
 	case foundLocalFileMsg:
 		newMd := localFileToMarkdown(m.common.cwd, gitcha.SearchResult(msg))
@@ -391,23 +503,32 @@ func findLocalFiles(m commonModel) tea.Cmd {
 
 		log.Debug("local directory is", "cwd", cwd)
 
-		// Switch between FindFiles and FindAllFiles to bypass .gitignore rules
-		var ch chan gitcha.SearchResult
-		if m.cfg.ShowAllFiles {
-			ch, err = gitcha.FindAllFilesExcept(cwd, markdownExtensions, nil)
+		opts := newScanOptions(m.cfg)
+
+		// Watch the directories searched for files coming and going
+		watcher, err := newDirWatcher(cwd, opts)
+		if err != nil {
+			log.Error("error watching local files", "error", err)
 		} else {
-			ch, err = gitcha.FindFilesExcept(cwd, markdownExtensions, ignorePatterns(m))
+			opts.walked = watcher.watch
 		}
 
+		ch, err := findMarkdownFiles(cwd, opts)
 		if err != nil {
 			log.Error("error finding local files", "error", err)
+			if watcher != nil {
+				watcher.close()
+			}
 			return errMsg{err}
 		}
 
-		return initLocalFileSearchMsg{ch: ch, cwd: cwd}
+		return initLocalFileSearchMsg{ch: ch, cwd: cwd, watcher: watcher}
 	}
 }
 
+// hiddenPattern matches hidden files and directories.
+const hiddenPattern = ".*"
+
 func findNextLocalFile(m model) tea.Cmd {
 	return func() tea.Msg {
 		res, ok := <-m.localFileFinder
@@ -422,6 +543,14 @@ func findNextLocalFile(m model) tea.Cmd {
 	}
 }
 
+// watchLocalFiles waits for local files to be created or removed.
+func watchLocalFiles(m model) tea.Cmd {
+	if m.localFileWatcher == nil {
+		return nil
+	}
+	return m.localFileWatcher.next
+}
+
 func waitForStatusMessageTimeout(appCtx applicationContext, t *time.Timer) tea.Cmd {
 	return func() tea.Msg {
 		<-t.C
@@ -439,6 +568,7 @@ func localFileToMarkdown(cwd string, res gitcha.SearchResult) *markdown {
 		localPath: res.Path,
 		Note:      stripAbsolutePath(res.Path, cwd),
 		Modtime:   res.Info.ModTime(),
+		Size:      res.Info.Size(),
 	}
 }
 
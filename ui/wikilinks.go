@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/hholst80/glow/wikilinks"
+)
+
+// openWikiLinkMsg opens the note a wiki link names.
+type openWikiLinkMsg wikilinks.Link
+
+// linkHints returns the hints following the wiki links on screen.
+func (m pagerModel) linkHints() []hint {
+	links := m.wikiLinks()
+	labels := make([]string, len(links))
+	for i, link := range links {
+		labels[i] = link.Label
+	}
+	var hints []hint
+	for _, i := range m.onScreen(labels) {
+		hints = append(hints, hint{hintKeys[len(hints) : len(hints)+1], links[i].Label, openWikiLinkMsg(links[i])})
+	}
+	return hints
+}
+
+// openWikiLink opens the note a wiki link names: one of the files found in
+// the working directory, or else in the directory of the note linking to it.
+func (m *model) openWikiLink(link wikilinks.Link) tea.Cmd {
+	dir := filepath.Dir(m.pager.currentDocument.localPath)
+	files := make([]string, len(m.stash.markdowns))
+	for i, md := range m.stash.markdowns {
+		files[i] = md.localPath
+	}
+	path := wikilinks.Resolve(link.Target, dir, files)
+	if path == "" && m.pager.currentDocument.localPath != "" {
+		found, err := FindMarkdownFiles(dir, m.common.cfg)
+		if err == nil {
+			path = wikilinks.Resolve(link.Target, dir, found)
+		}
+	}
+	if path == "" {
+		return m.pager.showStatusMessage(pagerStatusMessage{fmt.Sprintf("No note named %s", link.Target), true})
+	}
+
+	for _, md := range m.stash.markdowns {
+		if md.localPath == path {
+			return m.stash.openMarkdown(md)
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return m.pager.showStatusMessage(pagerStatusMessage{fmt.Sprintf("Couldn't open %s", path), true})
+	}
+	cwd := m.common.cwd
+	if cwd == "" {
+		// A file opened by itself, without the stash's search
+		cwd, _ = os.Getwd()
+	}
+	return m.stash.openMarkdown(localDocument(path, cwd, info))
+}
@@ -1,10 +1,19 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
+
+	"github.com/spf13/viper"
 )
 
 const (
@@ -13,6 +22,10 @@ const (
 	protoHTTPS  = "https://"
 )
 
+// githubShorthand matches owner/repo, optionally followed by @ref, with the
+// names GitHub allows for owners and repositories.
+var githubShorthand = regexp.MustCompile(`^[A-Za-z0-9](?:-?[A-Za-z0-9]){0,38}/([A-Za-z0-9._-]{1,100})(@[^/\s]+)?$`)
+
 var (
 	githubURL *url.URL
 	gitlabURL *url.URL
@@ -40,7 +53,10 @@ func readmeURL(path string) (*source, error) {
 		return nil, nil
 	}
 
-	if !strings.HasPrefix(path, protoHTTPS) {
+	if isGitHubShorthand(path) {
+		path = githubURL.Hostname() + "/" + path
+	}
+	if !strings.Contains(path, "://") {
 		path = protoHTTPS + path
 	}
 	u, err := url.Parse(path)
@@ -51,13 +67,72 @@ func readmeURL(path string) (*source, error) {
 	switch {
 	case u.Hostname() == githubURL.Hostname():
 		return findGitHubREADME(u)
-	case u.Hostname() == gitlabURL.Hostname():
+	case isGitLabHost(u.Hostname()):
 		return findGitLabREADME(u)
+	case isGiteaHost(u.Hostname()):
+		return findGiteaREADME(u)
 	}
 
 	return nil, nil
 }
 
+// isGitLabHost reports whether host is gitlab.com or one of the self-hosted
+// GitLab instances of the gitlabHosts setting.
+func isGitLabHost(host string) bool {
+	return host == gitlabURL.Hostname() || slices.Contains(viper.GetStringSlice("gitlabHosts"), host)
+}
+
+// isGiteaHost reports whether host is a known Gitea or Forgejo instance, or
+// one of the giteaHosts setting.
+func isGiteaHost(host string) bool {
+	return slices.Contains(giteaHosts, host) || slices.Contains(viper.GetStringSlice("giteaHosts"), host)
+}
+
+// getWithToken gets a URL, sending the token from the first of the
+// environment variables that's set in header, formatted by format.
+func getWithToken(u, header, format string, vars ...string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil) //nolint:noctx
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	for _, key := range vars {
+		if token := os.Getenv(key); token != "" {
+			req.Header.Set(header, fmt.Sprintf(format, token))
+			break
+		}
+	}
+	return http.DefaultClient.Do(req) //nolint:wrapcheck
+}
+
+// fetchRawFile gets a file of a repository by its raw URL. The caller closes
+// the source.
+func fetchRawFile(get func(string) (*http.Response, error), rawURL string) (*source, error) {
+	resp, err := get(rawURL) //nolint:bodyclose
+	if err != nil {
+		return nil, fmt.Errorf("unable to get url: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, withExitCode(exitNotFound, fmt.Errorf("HTTP status %d", resp.StatusCode))
+		}
+		return nil, fmt.Errorf("HTTP status %d", resp.StatusCode)
+	}
+	return &source{reader: resp.Body, URL: rawURL}, nil
+}
+
+// isGitHubShorthand reports whether path names a GitHub repository as
+// owner/repo, rather than a local file.
+func isGitHubShorthand(path string) bool {
+	m := githubShorthand.FindStringSubmatch(path)
+	// A name with an extension, such as docs/notes.txt, is a missing file
+	if m == nil || filepath.Ext(m[1]) != "" {
+		return false
+	}
+	_, err := os.Stat(path)
+	return errors.Is(err, fs.ErrNotExist)
+}
+
 func githubReadmeURL(path string) *url.URL {
 	path = strings.TrimPrefix(path, protoGithub)
 	parts := strings.Split(path, "/")
@@ -1,6 +1,15 @@
 package main
 
-import "testing"
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+)
 
 func TestURLParser(t *testing.T) {
 	for path, url := range map[string]string{
@@ -28,3 +37,115 @@ func TestURLParser(t *testing.T) {
 		})
 	}
 }
+
+func TestParseGitHubPath(t *testing.T) {
+	for path, want := range map[string][3]string{
+		"/charmbracelet/glow":                 {"charmbracelet", "glow", ""},
+		"/charmbracelet/glow.git":             {"charmbracelet", "glow", ""},
+		"/charmbracelet/glow@v2.0.0":          {"charmbracelet", "glow", "v2.0.0"},
+		"/charmbracelet/glow/tree/main":       {"charmbracelet", "glow", "main"},
+		"/charmbracelet/glow/tree/feature/ui": {"charmbracelet", "glow", "feature/ui"},
+	} {
+		t.Run(path, func(t *testing.T) {
+			owner, repo, ref, ok := parseGitHubPath(path)
+			if !ok || [3]string{owner, repo, ref} != want {
+				t.Errorf("parseGitHubPath() = %q, %q, %q, %v, want %q", owner, repo, ref, ok, want)
+			}
+		})
+	}
+	if _, _, _, ok := parseGitHubPath("/charmbracelet"); ok {
+		t.Error("expected a path without a repository to be invalid")
+	}
+}
+
+func TestIsGitHubShorthand(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.MkdirAll("docs/guide", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]bool{
+		"charmbracelet/glow":      true,
+		"charmbracelet/glow@main": true,
+		"docs/guide":              false, // a local directory
+		"docs/usage.md":           false,
+		"docs/notes.txt":          false,
+		"dir/file.rst":            false,
+		"-owner/repo":             false,
+		"owner--name/repo":        false,
+		"github.com/owner/repo":   false,
+		"README.md":               false,
+	} {
+		if got := isGitHubShorthand(path); got != want {
+			t.Errorf("isGitHubShorthand(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestGitHubRawURL(t *testing.T) {
+	u, _ := url.Parse("https://github.com/charmbracelet/glow/blob/master/docs/usage.md")
+	want := "https://raw.githubusercontent.com/charmbracelet/glow/master/docs/usage.md"
+	if got, ok := githubRawURL(u); !ok || got != want {
+		t.Errorf("githubRawURL() = %q, %v, want %q", got, ok, want)
+	}
+	u, _ = url.Parse("https://github.com/charmbracelet/glow/tree/master")
+	if _, ok := githubRawURL(u); ok {
+		t.Error("expected a tree URL not to be a file")
+	}
+}
+
+func TestParseGitLabPath(t *testing.T) {
+	for path, want := range map[string][3]string{
+		"/caarlos0/test":                             {"caarlos0/test", "", ""},
+		"/group/sub/project@v1":                      {"group/sub/project", "v1", ""},
+		"/group/sub/project/-/tree/develop":          {"group/sub/project", "develop", ""},
+		"/caarlos0/test/-/blob/master/docs/intro.md": {"caarlos0/test", "", "master/docs/intro.md"},
+	} {
+		project, ref, file := parseGitLabPath(path)
+		if got := [3]string{project, ref, file}; got != want {
+			t.Errorf("parseGitLabPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestFindGiteaREADME(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/repos/owner/repo/contents":
+			if r.URL.Query().Get("ref") != "dev" || r.Header.Get("Authorization") != "token secret" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintf(w, `[
+				{"name": "docs", "type": "dir"},
+				{"name": "readme.md", "type": "file", "download_url": "http://%[1]s/lower"},
+				{"name": "README.md", "type": "file", "download_url": "http://%[1]s/owner/repo/raw/branch/dev/README.md"}
+			]`, r.Host)
+		case "/owner/repo/raw/branch/dev/README.md", "/owner/repo/raw/branch/dev/docs/intro.md":
+			fmt.Fprint(w, "# Repo")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	viper.Set("giteaHosts", []string{u.Hostname()})
+	t.Cleanup(func() { viper.Set("giteaHosts", nil) })
+	t.Setenv("GITEA_TOKEN", "secret")
+
+	for path, want := range map[string]string{
+		"/owner/repo@dev":                          "/owner/repo/raw/branch/dev/README.md",
+		"/owner/repo/src/branch/dev":               "/owner/repo/raw/branch/dev/README.md",
+		"/owner/repo/src/branch/dev/docs/intro.md": "/owner/repo/raw/branch/dev/docs/intro.md",
+	} {
+		t.Run(path, func(t *testing.T) {
+			src, err := readmeURL(srv.URL + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer src.reader.Close() //nolint:errcheck
+			if src.URL != srv.URL+want {
+				t.Errorf("readmeURL() = %q, want %q", src.URL, srv.URL+want)
+			}
+		})
+	}
+}
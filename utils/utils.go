@@ -2,6 +2,8 @@
 package utils
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -11,7 +13,12 @@ import (
 	"github.com/charmbracelet/glamour/ansi"
 	"github.com/charmbracelet/glamour/styles"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/hholst80/glow/org"
+	"github.com/hholst80/glow/rst"
 	"github.com/mitchellh/go-homedir"
+	"github.com/muesli/termenv"
+	"go.yaml.in/yaml/v3"
+	"golang.org/x/term"
 )
 
 // RemoveFrontmatter removes the front matter header of a markdown file.
@@ -31,6 +38,150 @@ func detectFrontmatter(c []byte) []int {
 	return []int{-1, -1}
 }
 
+// Frontmatter returns the raw YAML front matter of a markdown file, without
+// the surrounding delimiters. It returns nil if there is no front matter.
+func Frontmatter(content []byte) []byte {
+	matches := yamlPattern.FindAllIndex(content, 2)
+	if len(matches) < 2 || matches[0][0] != 0 {
+		return nil
+	}
+	return content[matches[0][1]:matches[1][0]]
+}
+
+// FrontmatterTitle returns the title declared in the front matter of a
+// markdown file, or an empty string if there is none.
+func FrontmatterTitle(content []byte) string {
+	fm := Frontmatter(content)
+	if fm == nil {
+		return ""
+	}
+
+	var meta struct {
+		Title string `yaml:"title"`
+	}
+	if err := yaml.Unmarshal(fm, &meta); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(meta.Title)
+}
+
+// FrontmatterTags returns the tags declared in the front matter of a markdown
+// file, either as a list or as a string of comma or space separated tags.
+func FrontmatterTags(content []byte) []string {
+	fm := Frontmatter(content)
+	if fm == nil {
+		return nil
+	}
+
+	var meta struct {
+		Tags any `yaml:"tags"`
+	}
+	if err := yaml.Unmarshal(fm, &meta); err != nil {
+		return nil
+	}
+
+	var tags []string
+	switch v := meta.Tags.(type) {
+	case string:
+		tags = strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })
+	case []any:
+		for _, t := range v {
+			if s, ok := t.(string); ok {
+				tags = append(tags, s)
+			}
+		}
+	}
+
+	var cleaned []string
+	for _, t := range tags {
+		if t = strings.TrimPrefix(strings.TrimSpace(t), "#"); t != "" {
+			cleaned = append(cleaned, t)
+		}
+	}
+	return cleaned
+}
+
+// RenderOptions are the render settings a document overrides under the glow
+// key of its front matter, such as "glow: {style: light, width: 100}". The
+// settings it doesn't set, or sets to invalid values, are zero.
+type RenderOptions struct {
+	// Name of a built-in glamour style, such as "light"
+	Style string
+
+	// Width to wrap the document at, up to the terminal's
+	Width int
+
+	// Whether to show the outline sidebar when the document is opened
+	Outline *bool
+
+	// How to show mermaid diagrams: "off", "on", "ascii" or "image"
+	Mermaid string
+}
+
+// FrontmatterRenderOptions returns the render options declared under the glow
+// key of the front matter of a markdown file. Each is read on its own, so an
+// invalid one doesn't discard the others.
+func FrontmatterRenderOptions(content []byte) RenderOptions {
+	var options RenderOptions
+	fm := Frontmatter(content)
+	if fm == nil {
+		return options
+	}
+
+	var meta struct {
+		Glow map[string]any `yaml:"glow"`
+	}
+	if err := yaml.Unmarshal(fm, &meta); err != nil {
+		return options
+	}
+
+	if s, ok := meta.Glow["style"].(string); ok {
+		if s = strings.ToLower(strings.TrimSpace(s)); s == styles.AutoStyle || styles.DefaultStyles[s] != nil {
+			options.Style = s
+		}
+	}
+	if w, ok := meta.Glow["width"].(int); ok && w > 0 {
+		options.Width = w
+	}
+	if show, ok := yamlSwitch(meta.Glow["outline"]); ok {
+		options.Outline = &show
+	}
+	switch v := meta.Glow["mermaid"].(type) {
+	case string:
+		switch v = strings.ToLower(strings.TrimSpace(v)); v {
+		case "off", "on", "ascii", "image":
+			options.Mermaid = v
+		case "no", "false":
+			options.Mermaid = "off"
+		case "yes", "true":
+			options.Mermaid = "on"
+		}
+	case bool:
+		options.Mermaid = "off"
+		if v {
+			options.Mermaid = "on"
+		}
+	}
+	return options
+}
+
+// yamlSwitch returns a YAML value turning something on or off as a bool: true
+// or false, or a word such as "on" or "no", which YAML 1.2 leaves strings.
+func yamlSwitch(v any) (bool, bool) {
+	switch v := v.(type) {
+	case bool:
+		return v, true
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "on", "yes", "true":
+			return true, true
+		case "off", "no", "false":
+			return false, true
+		}
+	}
+	return false, false
+}
+
 // ExpandPath expands tilde and all environment variables from the given path.
 func ExpandPath(path string) string {
 	s, err := homedir.Expand(path)
@@ -45,6 +196,47 @@ func WrapCodeBlock(s, language string) string {
 	return "```" + language + "\n" + s + "```"
 }
 
+// File is a named document joined with others by JoinFiles.
+type File struct {
+	Name    string
+	Content []byte
+}
+
+// JoinedFile is where a file starts in a document made by JoinFiles.
+type JoinedFile struct {
+	Name string
+
+	// Zero-based line of the document naming the file
+	Line int
+}
+
+// JoinFiles joins files into one markdown document, each opened by a line
+// naming it and separated by rules, and returns where each file starts.
+// Front matter is removed, and files that aren't markdown are shown as code
+// blocks.
+func JoinFiles(files []File) (string, []JoinedFile) {
+	var b strings.Builder
+	joined := make([]JoinedFile, 0, len(files))
+	for i, f := range files {
+		if i > 0 {
+			b.WriteString("\n\n---\n\n")
+		}
+		joined = append(joined, JoinedFile{Name: f.Name, Line: strings.Count(b.String(), "\n")})
+		b.WriteString("**📄 " + f.Name + "**\n\n")
+
+		content := string(RemoveFrontmatter(DocumentContent(f.Name, f.Content)))
+		if _, isCode := CodeLanguage(f.Name, ""); isCode {
+			if !strings.HasSuffix(content, "\n") {
+				content += "\n"
+			}
+			content = WrapCodeBlock(content, filepath.Ext(f.Name))
+		}
+		b.WriteString(strings.TrimRight(content, "\n"))
+	}
+	b.WriteString("\n")
+	return b.String(), joined
+}
+
 var markdownExtensions = []string{
 	".md", ".mdown", ".mkdn", ".mkd", ".markdown",
 }
@@ -69,13 +261,62 @@ func IsMarkdownFile(filename string) bool {
 	return false
 }
 
+// DocumentContent returns the content of a file as markdown: Org and
+// reStructuredText documents are converted, and others are left as they are.
+func DocumentContent(name string, content []byte) []byte {
+	switch {
+	case org.IsOrgFile(name):
+		return []byte(org.ToMarkdown(string(content)))
+	case rst.IsRSTFile(name):
+		return []byte(rst.ToMarkdown(string(content)))
+	}
+	return content
+}
+
+// isConvertedFile reports whether a file is a document DocumentContent
+// converts to markdown.
+func isConvertedFile(name string) bool {
+	return org.IsOrgFile(name) || rst.IsRSTFile(name)
+}
+
+// CodeLanguage returns the language a file is shown as a code block of, and
+// whether it's code at all rather than markdown. The language is given by the
+// file's extension unless it's overridden; markdown is named like its
+// extensions, e.g. "md" or "markdown".
+func CodeLanguage(filename, language string) (string, bool) {
+	if language == "" {
+		return filepath.Ext(filename), !IsMarkdownFile(filename) && !isConvertedFile(filename)
+	}
+	return language, !IsMarkdownFile("."+language) && !isConvertedFile("."+language)
+}
+
 // GlamourStyle returns a glamour.TermRendererOption based on the given style.
 func GlamourStyle(style string, isCode bool) glamour.TermRendererOption {
+	styleConfig, err := GlamourStyleConfig(style, isCode)
+	if err != nil {
+		return func(*glamour.TermRenderer) error { return err }
+	}
+	return glamour.WithStyles(styleConfig)
+}
+
+// GlamourStyleConfig returns the styles of the given style as glamour
+// resolves them: the standard styles by name, with the auto style picked by
+// the terminal's background, or else the JSON file the style names.
+func GlamourStyleConfig(style string, isCode bool) (ansi.StyleConfig, error) {
 	if !isCode {
 		if style == styles.AutoStyle {
-			return glamour.WithAutoStyle()
+			switch {
+			case !term.IsTerminal(int(os.Stdout.Fd())):
+				return styles.NoTTYStyleConfig, nil
+			case termenv.HasDarkBackground():
+				return styles.DarkStyleConfig, nil
+			}
+			return styles.LightStyleConfig, nil
+		}
+		if styleConfig, ok := styles.DefaultStyles[style]; ok {
+			return *styleConfig, nil
 		}
-		return glamour.WithStylePath(style)
+		return styleFile(style)
 	}
 
 	// If we are rendering a pure code block, we need to modify the style to
@@ -103,11 +344,103 @@ func GlamourStyle(style string, isCode bool) glamour.TermRendererOption {
 	case styles.TokyoNightStyle:
 		styleConfig = styles.DraculaStyleConfig
 	default:
-		return glamour.WithStylesFromJSONFile(style)
+		return styleFile(style)
 	}
 
 	var margin uint
 	styleConfig.CodeBlock.Margin = &margin
 
-	return glamour.WithStyles(styleConfig)
+	return styleConfig, nil
+}
+
+// styleFile reads the styles of a glamour JSON style file.
+func styleFile(path string) (ansi.StyleConfig, error) {
+	var styleConfig ansi.StyleConfig
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return styleConfig, fmt.Errorf("glamour: error reading file: %w", err)
+	}
+	if err := json.Unmarshal(b, &styleConfig); err != nil {
+		return styleConfig, fmt.Errorf("error parsing style %s: %w", path, err)
+	}
+	return styleConfig, nil
+}
+
+// NextFence returns the fence line of the code block open after line, given
+// the one open before it, or "" if none is.
+func NextFence(fence, line string) string {
+	trimmed := strings.TrimSpace(line)
+	if fence != "" {
+		if strings.HasPrefix(trimmed, fence[:3]) && strings.Trim(trimmed, fence[:1]) == "" {
+			return ""
+		}
+		return fence
+	}
+	if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+		return trimmed
+	}
+	return ""
+}
+
+// OutsideCodeSpans returns line with the text outside of its code spans
+// passed through replace.
+func OutsideCodeSpans(line string, replace func(string) string) string {
+	var b strings.Builder
+	for line != "" {
+		start := strings.Index(line, "`")
+		if start < 0 {
+			b.WriteString(replace(line))
+			break
+		}
+		ticks := len(line[start:]) - len(strings.TrimLeft(line[start:], "`"))
+		end := strings.Index(line[start+ticks:], line[start:start+ticks])
+		if end < 0 {
+			b.WriteString(replace(line))
+			break
+		}
+		end += start + 2*ticks
+		b.WriteString(replace(line[:start]))
+		b.WriteString(line[start:end])
+		line = line[end:]
+	}
+	return b.String()
+}
+
+// FenceLanguage returns the language a code block's fence line names, in
+// lower case.
+func FenceLanguage(fence string) string {
+	info := strings.Fields(strings.TrimLeft(fence, "`~"))
+	if len(info) == 0 {
+		return ""
+	}
+	return strings.ToLower(info[0])
+}
+
+// The widths AutoWidth picks from.
+const (
+	MinAutoWidth = 80  // prose wraps here if nothing is wider
+	MaxAutoWidth = 200 // when the output isn't a terminal
+)
+
+// autoWidthMargin is the room glamour's styles take around code blocks and
+// tables.
+const autoWidthMargin = 4
+
+// AutoWidth returns the width a document fits in: wide enough for its longest
+// line that can't be wrapped, of a code block or table, but at least
+// MinAutoWidth and at most maxWidth.
+func AutoWidth(markdown string, maxWidth int) int {
+	longest := 0
+	fence := ""
+	for _, line := range strings.Split(markdown, "\n") {
+		// Lines of code blocks and tables, but not the fences
+		inCode := fence != ""
+		fence = NextFence(fence, line)
+		if !(inCode && fence != "") && !strings.HasPrefix(strings.TrimSpace(line), "|") {
+			continue
+		}
+		line = strings.ReplaceAll(line, "\t", "    ")
+		longest = max(longest, lipgloss.Width(line)+autoWidthMargin)
+	}
+	return max(min(longest, maxWidth), min(MinAutoWidth, maxWidth))
 }
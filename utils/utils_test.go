@@ -0,0 +1,24 @@
+package utils
+
+import "testing"
+
+func TestFrontmatterTitle(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"with title", "---\ntitle: Hello World\n---\n# Body", "Hello World"},
+		{"quoted title", "---\ntitle: \"Quoted: Title\"\ndate: 2024-01-01\n---\n", "Quoted: Title"},
+		{"no title", "---\nauthor: me\n---\n# Body", ""},
+		{"no frontmatter", "# Body\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FrontmatterTitle([]byte(tt.input)); got != tt.expected {
+				t.Errorf("FrontmatterTitle() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
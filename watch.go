@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+)
+
+// watchDebounce is how long changes to the watched file are gathered before
+// it's rendered again, since editors often save in several writes.
+const watchDebounce = 100 * time.Millisecond
+
+// watchCLI renders a local file to w, and again whenever it changes, on a
+// cleared screen. Its directory is watched rather than the file, so editors
+// that save by replacing the file are followed.
+func watchCLI(cmd *cobra.Command, args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("--watch takes one file")
+	}
+	path, err := filepath.Abs(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to find %s: %w", args[0], err)
+	}
+	if info, err := os.Stat(path); err != nil {
+		return fmt.Errorf("unable to watch %s: %w", args[0], err)
+	} else if info.IsDir() {
+		return fmt.Errorf("unable to watch %s: it's a directory", args[0])
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("unable to watch %s: %w", args[0], err)
+	}
+	defer watcher.Close() //nolint:errcheck
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("unable to watch %s: %w", args[0], err)
+	}
+
+	if err := renderWatched(cmd, path, w); err != nil {
+		return err
+	}
+	var changed <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Name != path || !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+				continue
+			}
+			log.Debug("fsnotify event", "file", event.Name, "event", event.Op)
+			changed = time.After(watchDebounce)
+
+		case <-changed:
+			changed = nil
+			if err := renderWatched(cmd, path, w); err != nil {
+				return err
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Debug("fsnotify error", "file", path, "error", err)
+		}
+	}
+}
+
+// renderWatched clears the screen and renders the watched file. A file that
+// can't be read, such as one removed while it's saved, or rendered is
+// reported in its place until it changes again.
+func renderWatched(cmd *cobra.Command, path string, w io.Writer) error {
+	var out strings.Builder
+	if f, err := os.Open(path); err != nil {
+		fmt.Fprintf(&out, "\n  %s\n", err)
+	} else {
+		err = executeCLI(cmd, &source{reader: f, URL: path}, &out)
+		_ = f.Close()
+		if err != nil {
+			out.Reset()
+			fmt.Fprintf(&out, "\n  %s\n", err)
+		}
+	}
+	if _, err := io.WriteString(w, ansi.CursorHomePosition+ansi.EraseEntireScreen+out.String()); err != nil {
+		return fmt.Errorf("unable to write to writer: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// widthAuto is the width that makes each document pick its own.
+const widthAuto = "auto"
+
+// parseWidth parses a width setting: a number of columns, 0 to not wrap, or
+// "auto" to fit the document.
+func parseWidth(s string) (uint, bool, error) {
+	if s == widthAuto {
+		return 0, true, nil
+	}
+	w, err := strconv.ParseUint(s, 10, 0)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid width %q: use a number of columns or %s", s, widthAuto)
+	}
+	return uint(w), false, nil
+}
+
+// widthValue is the value of --width, which sets both width and whether it's
+// "auto".
+type widthValue struct {
+	width *uint
+	auto  *bool
+}
+
+func (v widthValue) String() string {
+	if *v.auto {
+		return widthAuto
+	}
+	return strconv.FormatUint(uint64(*v.width), 10)
+}
+
+func (v widthValue) Set(s string) error {
+	w, auto, err := parseWidth(s)
+	if err != nil {
+		return err
+	}
+	*v.width, *v.auto = w, auto
+	return nil
+}
+
+func (v widthValue) Type() string { return "columns" }
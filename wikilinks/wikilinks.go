@@ -0,0 +1,134 @@
+// Package wikilinks handles the wiki links of Obsidian-style notes, such as
+// [[Page Name]] and [[page|label]], which link notes by name rather than by
+// path.
+package wikilinks
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hholst80/glow/utils"
+)
+
+// Link is a wiki link.
+type Link struct {
+	Target  string // name of the note linked to, such as "Page Name"
+	Heading string // heading of the note after a #, if any
+	Label   string // text shown, the target unless one is given after a |
+}
+
+// linkRegex matches a wiki link, or an embed such as ![[image.png]].
+var linkRegex = regexp.MustCompile(`!?\[\[([^\[\]|\n]+?)(?:\|([^\[\]\n]+?))?\]\]`)
+
+// labelEscaper escapes the characters of a label that would be taken for
+// markdown in a link's text.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, "*", `\*`, "_", `\_`, "`", "\\`")
+
+// urlPrefix opens the destinations of the links Process replaces wiki links
+// with. They're anchors, which glamour doesn't show, and FromURL reads the
+// wiki links back from them.
+const urlPrefix = "#wiki:"
+
+// destinationEscaper escapes the characters of a target that would end a
+// link destination written between < and >.
+var destinationEscaper = strings.NewReplacer(`\`, `\\`, "<", `\<`, ">", `\>`)
+
+// parse returns the link a match of linkRegex is.
+func parse(m []string) Link {
+	target, heading, _ := strings.Cut(strings.TrimSpace(m[1]), "#")
+	l := Link{Target: strings.TrimSpace(target), Heading: strings.TrimSpace(heading)}
+	switch {
+	case strings.TrimSpace(m[2]) != "":
+		l.Label = strings.TrimSpace(m[2])
+	case l.Target == "":
+		l.Label = l.Heading
+	case l.Heading != "":
+		l.Label = l.Target + " › " + l.Heading
+	default:
+		l.Label = l.Target
+	}
+	return l
+}
+
+// Find returns the wiki links of markdown outside of code, in order.
+func Find(markdown string) []Link {
+	var links []Link
+	each(markdown, func(s string) string {
+		for _, m := range linkRegex.FindAllStringSubmatch(s, -1) {
+			links = append(links, parse(m))
+		}
+		return s
+	})
+	return links
+}
+
+// Process replaces the wiki links of markdown with links glamour styles as
+// links, showing their labels.
+func Process(markdown string) string {
+	return each(markdown, func(s string) string {
+		return linkRegex.ReplaceAllStringFunc(s, func(link string) string {
+			l := parse(linkRegex.FindStringSubmatch(link))
+			url := urlPrefix + l.Target
+			if l.Heading != "" {
+				url += "#" + l.Heading
+			}
+			return "[" + labelEscaper.Replace(l.Label) + "](<" + destinationEscaper.Replace(url) + ">)"
+		})
+	})
+}
+
+// FromURL returns the wiki link a link made by Process is, given its
+// destination and its text, or false if it isn't one.
+func FromURL(url, label string) (Link, bool) {
+	rest, ok := strings.CutPrefix(url, urlPrefix)
+	if !ok {
+		return Link{}, false
+	}
+	target, heading, _ := strings.Cut(rest, "#")
+	return Link{Target: target, Heading: heading, Label: label}, true
+}
+
+// each returns markdown with its text outside of code passed through fn.
+func each(markdown string, fn func(string) string) string {
+	if !strings.Contains(markdown, "[[") {
+		return markdown
+	}
+	lines := strings.Split(markdown, "\n")
+	fence := ""
+	for i, line := range lines {
+		inCode := fence != ""
+		if fence = utils.NextFence(fence, line); inCode || fence != "" {
+			continue
+		}
+		lines[i] = utils.OutsideCodeSpans(line, fn)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Resolve returns the file of files a link's target names, or "" if there's
+// none. A target that's a path from dir, the directory of the note linking to
+// it, is found there; otherwise the file with the shortest path of those named
+// after the target is, as Obsidian finds notes. Names are matched regardless
+// of case, and without their .md extension.
+func Resolve(target, dir string, files []string) string {
+	name := strings.ToLower(filepath.FromSlash(strings.TrimSuffix(target, ".md")))
+	if name == "" {
+		return ""
+	}
+	relative := strings.ToLower(filepath.Join(dir, name))
+	best := ""
+	for _, f := range files {
+		path := strings.ToLower(strings.TrimSuffix(f, filepath.Ext(f)))
+		switch {
+		case path == relative:
+			return f
+		case path == name || strings.HasSuffix(path, string(os.PathSeparator)+name):
+			if best == "" || len(f) < len(best) {
+				best = f
+			}
+		}
+	}
+	return best
+}
@@ -0,0 +1,97 @@
+package wikilinks
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "no links",
+			in:   "Text [link](a.md).\n",
+			want: "Text [link](a.md).\n",
+		},
+		{
+			name: "targets and labels",
+			in:   "See [[Page Name]], [[page|the page]] and [[Page#Setup]].\n",
+			want: "See [Page Name](<#wiki:Page Name>), [the page](<#wiki:page>) and [Page › Setup](<#wiki:Page#Setup>).\n",
+		},
+		{
+			name: "labels escaped",
+			in:   "[[notes/a_b|*star*]]\n",
+			want: "[\\*star\\*](<#wiki:notes/a_b>)\n",
+		},
+		{
+			name: "code left alone",
+			in:   "`[[code]]` [[Page]]\n\n```\n[[Fenced]]\n```\n",
+			want: "`[[code]]` [Page](<#wiki:Page>)\n\n```\n[[Fenced]]\n```\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Process(tt.in); got != tt.want {
+				t.Errorf("Process() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	got := Find("[[A]] `[[B]]` [[C#D|label]]\n\n```\n[[E]]\n```\n[[#Local]]\n")
+	want := []Link{
+		{Target: "A", Label: "A"},
+		{Target: "C", Heading: "D", Label: "label"},
+		{Heading: "Local", Label: "Local"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Find() = %+v, want %+v", got, want)
+	}
+}
+
+func TestFromURL(t *testing.T) {
+	if got, ok := FromURL("#wiki:C#D#E", "label"); !ok || got != (Link{Target: "C", Heading: "D#E", Label: "label"}) {
+		t.Errorf("FromURL() = %+v, %v", got, ok)
+	}
+	if got, ok := FromURL("#wiki:#Local", "Local"); !ok || got != (Link{Heading: "Local", Label: "Local"}) {
+		t.Errorf("FromURL() = %+v, %v", got, ok)
+	}
+	if _, ok := FromURL("#section", "text"); ok {
+		t.Error("expected an anchor that isn't a wiki link's rejected")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	files := []string{
+		filepath.FromSlash("vault/projects/deep/Ideas.md"),
+		filepath.FromSlash("vault/Ideas.md"),
+		filepath.FromSlash("vault/daily/Today.markdown"),
+		filepath.FromSlash("vault/projects/Plan.md"),
+		filepath.FromSlash("vault/Plan.md"),
+	}
+	tests := []struct {
+		target string
+		dir    string
+		want   string
+	}{
+		{"ideas", "vault/daily", "vault/Ideas.md"},
+		{"Today", "vault", "vault/daily/Today.markdown"},
+		{"Plan.md", "vault/projects", "vault/projects/Plan.md"},
+		{"deep/Ideas", "vault", "vault/projects/deep/Ideas.md"},
+		{"Missing", "vault", ""},
+		{"", "vault", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			got := Resolve(tt.target, filepath.FromSlash(tt.dir), files)
+			if got != filepath.FromSlash(tt.want) {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.target, got, tt.want)
+			}
+		})
+	}
+}